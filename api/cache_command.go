@@ -0,0 +1,292 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// runCacheCommand exports the media cache to an archive, or imports it from one,
+// so thumbnails and web versions don't have to be generated again when moving to another host.
+//
+//	photoview cache export -file cache.tar.gz
+//	photoview cache import -file cache.tar.gz
+//
+// Cached files can also be regenerated, see runCacheRegenerateCommand, generated ahead of time, see runCacheWarmCommand,
+// and garbage collected, see runCacheGCCommand.
+func runCacheCommand(db *gorm.DB, args []string) error {
+	action, err := expectSubcommand(args, "export", "import", "regenerate", "warm", "gc")
+	if err != nil {
+		return err
+	}
+
+	if action == "regenerate" {
+		return runCacheRegenerateCommand(db, args[1:])
+	}
+	if action == "warm" {
+		return runCacheWarmCommand(db, args[1:])
+	}
+	if action == "gc" {
+		return runCacheGCCommand(db, args[1:])
+	}
+
+	flags := flag.NewFlagSet("cache "+action, flag.ContinueOnError)
+	file := flags.String("file", "", "path of the cache archive")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		flags.Usage()
+		return errors.New("-file is required")
+	}
+
+	var result *storage.CacheArchiveResult
+	if action == "export" {
+		out, err := os.Create(*file)
+		if err != nil {
+			return errors.Wrap(err, "create cache archive")
+		}
+
+		result, err = storage.ExportCache(db, out)
+		if err != nil {
+			out.Close()
+			return err
+		}
+
+		if err := out.Close(); err != nil {
+			return errors.Wrap(err, "close cache archive")
+		}
+	} else {
+		in, err := os.Open(*file)
+		if err != nil {
+			return errors.Wrap(err, "open cache archive")
+		}
+		defer in.Close()
+
+		result, err = storage.ImportCache(db, in)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info(db.Statement.Context, "Cache "+action+" completed",
+		"files", result.Files,
+		"bytes", result.Bytes,
+		"skipped", result.Skipped)
+
+	return nil
+}
+
+// runCacheRegenerateCommand generates the cached files of media that are missing or incomplete,
+// or all of them with -force, such as after changing the quality of thumbnails.
+//
+//	photoview cache regenerate -user admin -force
+func runCacheRegenerateCommand(db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("cache regenerate", flag.ContinueOnError)
+	albumID := flags.Int("album", 0, "only regenerate the media of this album, not including sub albums")
+	username := flags.String("user", "", "only regenerate the media of this user")
+	force := flags.Bool("force", false, "remove cached files that already exist, so they are generated again")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	query, err := cachedMediaQuery(db, *albumID, *username)
+	if err != nil {
+		return err
+	}
+
+	if err := initializeMediaProcessing(db); err != nil {
+		return err
+	}
+
+	ctx := db.Statement.Context
+	processed, failed, err := scanner.RegenerateCachedFiles(ctx, db, query, *force)
+	if err != nil {
+		return err
+	}
+
+	log.Info(ctx, "Cache regenerate completed", "processed", processed, "failed", failed)
+	return nil
+}
+
+// runCacheWarmCommand generates the cached files of media that are missing or incomplete ahead of time, processing several media
+// at the same time, so browsing a large import doesn't wait for them to be generated on demand. Media whose cached files are all
+// complete are skipped, so it can be run again after every import.
+//
+//	photoview cache warm -concurrency 8
+func runCacheWarmCommand(db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("cache warm", flag.ContinueOnError)
+	albumID := flags.Int("album", 0, "only warm the cache of the media of this album, not including sub albums")
+	username := flags.String("user", "", "only warm the cache of the media of this user")
+	concurrency := flags.Int("concurrency", runtime.NumCPU(), "number of media processed at the same time")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *concurrency < 1 {
+		flags.Usage()
+		return errors.New("-concurrency must be at least 1")
+	}
+
+	query, err := cachedMediaQuery(db, *albumID, *username)
+	if err != nil {
+		return err
+	}
+
+	if err := initializeMediaProcessing(db); err != nil {
+		return err
+	}
+
+	// The thumbnails generated at the same time are otherwise limited by the configuration of the scanner
+	maxJobs := strconv.Itoa(*concurrency)
+	utils.EnvScannerMaxThumbnailJobs.SetOverride(&maxJobs)
+
+	ctx := db.Statement.Context
+	var processed, skipped, failed int64
+
+	mediaToProcess := make(chan *models.Media)
+	workers := sync.WaitGroup{}
+	for i := 0; i < *concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+
+			for media := range mediaToProcess {
+				if err := scanner.ProcessSingleMedia(db, media); err != nil {
+					log.Warn(ctx, "Warming cache of media", "media_id", media.ID, "path", media.Path, "error", err)
+					atomic.AddInt64(&failed, 1)
+					continue
+				}
+				atomic.AddInt64(&processed, 1)
+			}
+		}()
+	}
+
+	var batch []*models.Media
+	err = query.FindInBatches(&batch, 100, func(tx *gorm.DB, _ int) error {
+		for _, media := range batch {
+			if cachedFilesComplete(media) {
+				skipped++
+				continue
+			}
+
+			mediaToProcess <- media
+		}
+
+		log.Info(ctx, "Warming cache", "processed", atomic.LoadInt64(&processed), "skipped", skipped, "failed", atomic.LoadInt64(&failed))
+		return nil
+	}).Error
+
+	close(mediaToProcess)
+	workers.Wait()
+
+	if err != nil {
+		return errors.Wrap(err, "get media from database")
+	}
+
+	log.Info(ctx, "Cache warm completed", "processed", processed, "skipped", skipped, "failed", failed)
+	return nil
+}
+
+// cachedMediaQuery returns the query of the media whose cached files are generated by the cache commands,
+// of the given album and user if they are set, with their media urls
+func cachedMediaQuery(db *gorm.DB, albumID int, username string) (*gorm.DB, error) {
+	query := db.Model(&models.Media{}).Preload("MediaURL").Order("media.id")
+	if albumID != 0 {
+		query = query.Where("media.album_id = ?", albumID)
+	}
+	if username != "" {
+		var user models.User
+		if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+			return nil, errors.Wrapf(err, "find user %s", username)
+		}
+		query = query.Where("media.album_id IN (?)", db.Table("user_albums").Select("album_id").Where("user_id = ?", user.ID))
+	}
+
+	return query, nil
+}
+
+// cachedFilesComplete returns whether the media has been processed, and all of its cached files are complete
+func cachedFilesComplete(media *models.Media) bool {
+	if len(media.MediaURL) == 0 {
+		return false
+	}
+
+	for i := range media.MediaURL {
+		mediaURL := &media.MediaURL[i]
+		if mediaURL.Purpose == models.MediaOriginal {
+			continue
+		}
+
+		mediaURL.Media = media
+		if !mediaURL.CachedFileComplete() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// runCacheGCCommand removes the cache directories of albums and media no longer in the database, the files of the content addressed cache
+// no longer used by any media and the files left incomplete by a crash, and reports the disk space reclaimed.
+// The server does the same in the background periodically.
+//
+//	photoview cache gc -grace 24h
+func runCacheGCCommand(db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("cache gc", flag.ContinueOnError)
+	grace := flags.Duration("grace", storage.CacheGarbageGracePeriod, "how long unused files of the content addressed cache are kept")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	usageBefore, err := storage.GetCacheUsage(db)
+	if err != nil {
+		return err
+	}
+
+	orphaned, err := storage.RemoveOrphanedCacheDirectories(db, func(float64) {})
+	if err != nil {
+		return errors.Wrap(err, "remove orphaned cache directories")
+	}
+
+	removedIncomplete, err := storage.RemoveIncompleteCacheFiles(db)
+	if err != nil {
+		return errors.Wrap(err, "remove incomplete cache files")
+	}
+
+	removedEntries, err := storage.CollectCacheGarbage(db, *grace)
+	if err != nil {
+		return errors.Wrap(err, "collect cache garbage")
+	}
+
+	usageAfter, err := storage.GetCacheUsage(db)
+	if err != nil {
+		return err
+	}
+
+	log.Info(db.Statement.Context, "Cache gc completed",
+		"deleted_albums", orphaned.Albums,
+		"deleted_media", orphaned.Media,
+		"unused_files", removedEntries,
+		"incomplete_files", removedIncomplete,
+		"reclaimed_bytes", usageBefore.UsedBytes-usageAfter.UsedBytes,
+		"used_bytes", usageAfter.UsedBytes)
+
+	return nil
+}
@@ -0,0 +1,189 @@
+// Package cluster coordinates multiple instances of the server sharing the same database,
+// so scans and scheduled jobs only run on one instance at a time, while all instances serve requests.
+//
+// Coordination is done through leases stored in the database, which expire unless they are renewed by their holder,
+// so a lease held by an instance that has stopped is taken over by another instance.
+// Expiry is compared against the clocks of the instances, which should therefore be kept in sync.
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	instanceIDOnce sync.Once
+	instanceID     string
+)
+
+// InstanceID returns the id of this instance of the server, given by PHOTOVIEW_INSTANCE_ID,
+// or made from the hostname and process id
+func InstanceID() string {
+	instanceIDOnce.Do(func() {
+		if id := utils.EnvInstanceID.GetValue(); id != "" {
+			instanceID = id
+			return
+		}
+
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "photoview"
+		}
+
+		instanceID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	})
+
+	return instanceID
+}
+
+// TryAcquire acquires or renews the lease of the given name for this instance, until the ttl has passed.
+// It returns false if the lease is held by another instance.
+//
+// A lease that is not released can be used to run a scheduled job at most once within the ttl across all instances.
+func TryAcquire(db *gorm.DB, name string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	holder := InstanceID()
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Lease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)})
+	if result.Error != nil {
+		return false, errors.Wrapf(result.Error, "create lease %s", name)
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+
+	// Take over the lease if it is ours already or it has expired, in a single statement so only one instance succeeds
+	result = db.Model(&models.Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": now.Add(ttl)})
+	if result.Error != nil {
+		return false, errors.Wrapf(result.Error, "acquire lease %s", name)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// Release gives up the lease of the given name, if it is held by this instance
+func Release(db *gorm.DB, name string) error {
+	err := db.Where("name = ? AND holder = ?", name, InstanceID()).Delete(&models.Lease{}).Error
+	if err != nil {
+		return errors.Wrapf(err, "release lease %s", name)
+	}
+
+	return nil
+}
+
+// Lease is held by this instance for as long as it needs, by renewing it in the background until it is released
+type Lease struct {
+	name  string
+	ttl   time.Duration
+	mutex sync.Mutex
+	// stopRenewal is closed to stop renewing the lease, it is nil when the lease is not held
+	stopRenewal chan bool
+}
+
+// NewLease makes a lease of the given name, which expires after the ttl if this instance stops renewing it
+func NewLease(name string, ttl time.Duration) *Lease {
+	return &Lease{
+		name: name,
+		ttl:  ttl,
+	}
+}
+
+// Acquire acquires the lease and keeps renewing it until it is released.
+// It returns false if the lease is held by another instance.
+func (l *Lease) Acquire(db *gorm.DB) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.stopRenewal != nil {
+		return true, nil
+	}
+
+	acquired, err := TryAcquire(db, l.name, l.ttl)
+	if err != nil || !acquired {
+		return false, err
+	}
+
+	log.Debug(context.Background(), "Lease acquired", "lease", l.name, "instance", InstanceID())
+
+	l.stopRenewal = make(chan bool)
+	go l.renew(db, l.stopRenewal)
+
+	return true, nil
+}
+
+func (l *Lease) renew(db *gorm.DB, stop chan bool) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			renewed, err := TryAcquire(db, l.name, l.ttl)
+			if err != nil {
+				// The lease is kept until it expires, the next renewal may succeed before then
+				log.Warn(context.Background(), "Renewing lease", "lease", l.name, "error", err)
+				continue
+			}
+
+			if !renewed {
+				log.Warn(context.Background(), "Lease has been taken over by another instance", "lease", l.name)
+
+				l.mutex.Lock()
+				if l.stopRenewal == stop {
+					l.stopRenewal = nil
+				}
+				l.mutex.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// Held returns whether the lease is held by this instance
+func (l *Lease) Held() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.stopRenewal != nil
+}
+
+// Release stops renewing the lease and gives it up, so another instance can acquire it right away
+func (l *Lease) Release(db *gorm.DB) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.stopRenewal == nil {
+		return nil
+	}
+
+	close(l.stopRenewal)
+	l.stopRenewal = nil
+
+	log.Debug(context.Background(), "Lease released", "lease", l.name, "instance", InstanceID())
+	return Release(db, l.name)
+}
+
+// GetLeases returns the leases currently held by any instance
+func GetLeases(db *gorm.DB) ([]*models.Lease, error) {
+	var leases []*models.Lease
+	if err := db.Where("expires_at >= ?", time.Now()).Order("name").Find(&leases).Error; err != nil {
+		return nil, errors.Wrap(err, "get leases from database")
+	}
+
+	return leases, nil
+}
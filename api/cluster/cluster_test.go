@@ -0,0 +1,80 @@
+package cluster_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/cluster"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestTryAcquire(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	acquired, err := cluster.TryAcquire(db, "job", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// Renewing a lease held by this instance
+	acquired, err = cluster.TryAcquire(db, "job", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	other := models.Lease{Name: "other", Holder: "other-instance", ExpiresAt: time.Now().Add(time.Minute)}
+	assert.NoError(t, db.Create(&other).Error)
+
+	acquired, err = cluster.TryAcquire(db, "other", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, acquired, "lease held by another instance")
+
+	// Releasing a lease of another instance does nothing
+	assert.NoError(t, cluster.Release(db, "other"))
+
+	leases, err := cluster.GetLeases(db)
+	assert.NoError(t, err)
+	assert.Len(t, leases, 2)
+
+	// Expired leases are taken over
+	assert.NoError(t, db.Model(&other).Update("expires_at", time.Now().Add(-time.Second)).Error)
+
+	acquired, err = cluster.TryAcquire(db, "other", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	var lease models.Lease
+	assert.NoError(t, db.First(&lease, "name = ?", "other").Error)
+	assert.Equal(t, cluster.InstanceID(), lease.Holder)
+}
+
+func TestLease(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	lease := cluster.NewLease("scanner", time.Minute)
+	assert.False(t, lease.Held())
+
+	acquired, err := lease.Acquire(db)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	assert.True(t, lease.Held())
+
+	assert.NoError(t, lease.Release(db))
+	assert.False(t, lease.Held())
+
+	var count int64
+	assert.NoError(t, db.Model(&models.Lease{}).Count(&count).Error)
+	assert.EqualValues(t, 0, count, "released lease is deleted")
+
+	assert.NoError(t, db.Create(&models.Lease{Name: "scanner", Holder: "other-instance", ExpiresAt: time.Now().Add(time.Minute)}).Error)
+
+	acquired, err = lease.Acquire(db)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+	assert.False(t, lease.Held())
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// command is a subcommand of the photoview binary, run instead of the server, so admins can script operations.
+// Commands are run once the database has been migrated and the settings have been loaded.
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(db *gorm.DB, args []string) error
+}
+
+var commands = []*command{
+	{name: "scan", usage: "scan [-user username] [-dry-run [-path directory] | -profile]", description: "Scan the albums of a user, or of all users, and wait for the scan to finish, or list what it would change", run: runScanCommand},
+	{name: "user", usage: "user create|list", description: "Create users and list them", run: runUserCommand},
+	{name: "share", usage: "share list [-user username]", description: "List share links, with the album or media they share", run: runShareCommand},
+	{name: "cache", usage: "cache export|import|regenerate|warm|gc", description: "Move the media cache to another host, regenerate cached files or generate missing ones ahead of time, or remove unused ones", run: runCacheCommand},
+	{name: "db", usage: "db migrate", description: "Migrate the database schema and exit", run: runDatabaseCommand},
+	{name: "demo", usage: "demo seed", description: "Generate a sample library with users owning it, and scan it", run: runDemoCommand},
+	{name: "migrate", usage: "migrate -from photoprism|immich", description: "Migrate favorites, people and albums from PhotoPrism or Immich", run: runMigrateCommand},
+	// The configuration is checked before connecting to the database, see runConfigCommand
+	{name: "config", usage: "config check", description: "Validate the configuration and print the effective value of every option"},
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+// isHelp returns whether the argument asks for the list of commands
+func isHelp(arg string) bool {
+	return arg == "help" || arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: photoview [command]")
+	fmt.Fprintln(out, "\nThe server is started if no command is given. Commands:")
+
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(table, "  %s\t%s\n", cmd.usage, cmd.description)
+	}
+	table.Flush()
+
+	fmt.Fprintln(out, "\nRun photoview [command] -h for the options of a command")
+}
+
+// expectSubcommand returns the subcommand given as the first argument, if it is one of the expected subcommands
+func expectSubcommand(args []string, expected ...string) (string, error) {
+	if len(args) > 0 {
+		for _, subcommand := range expected {
+			if args[0] == subcommand {
+				return subcommand, nil
+			}
+		}
+	}
+
+	return "", errors.Errorf("expected %s", strings.Join(expected, ", "))
+}
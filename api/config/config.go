@@ -0,0 +1,433 @@
+// Package config reads the configuration file of Photoview, a YAML file giving the same settings as the environment variables,
+// grouped into sections:
+//
+//	database:
+//	  driver: sqlite
+//	  sqlite_path: /var/lib/photoview/photoview.db
+//	cache:
+//	  path: /var/cache/photoview
+//
+// Environment variables, including the ones of a .env file, take precedence over the configuration file,
+// and settings changed through the api take precedence over both.
+package config
+
+import (
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFileName is the configuration file read from the working directory, if PHOTOVIEW_CONFIG_FILE is not set
+const DefaultFileName = "photoview.yaml"
+
+type optionKind int
+
+const (
+	kindString optionKind = iota
+	kindBool
+	kindPort
+	kindNumber
+	kindOption
+	kindURL
+)
+
+// option is a key of the configuration file, and the environment variable it sets
+type option struct {
+	key      string
+	variable utils.EnvironmentVariable
+	kind     optionKind
+	// options are the allowed values of a kindOption option
+	options []string
+	// defaultValue is used by the server when the option is not set, it is only shown by the config check command
+	defaultValue string
+	// secret values are not printed
+	secret bool
+}
+
+var options = []*option{
+	{key: "database.driver", variable: utils.EnvDatabaseDriver, kind: kindOption, options: []string{"mysql", "postgres", "sqlite"}, defaultValue: "mysql"},
+	{key: "database.mysql_url", variable: utils.EnvMysqlURL, secret: true},
+	{key: "database.postgres_url", variable: utils.EnvPostgresURL, kind: kindURL, secret: true},
+	{key: "database.sqlite_path", variable: utils.EnvSqlitePath, defaultValue: "photoview.db"},
+
+	{key: "server.listen_ip", variable: utils.EnvListenIP, defaultValue: "127.0.0.1"},
+	{key: "server.listen_port", variable: utils.EnvListenPort, kind: kindPort, defaultValue: "4001"},
+	{key: "server.api_endpoint", variable: utils.EnvAPIEndpoint, kind: kindURL},
+	{key: "server.ui_endpoint", variable: utils.EnvUIEndpoint, kind: kindURL},
+	{key: "server.public_url", variable: utils.EnvPublicURL, kind: kindURL},
+	{key: "server.trusted_proxies", variable: utils.EnvTrustedProxies},
+	{key: "server.serve_ui", variable: utils.EnvServeUI, kind: kindBool, defaultValue: "0"},
+	{key: "server.ui_path", variable: utils.EnvUIPath, defaultValue: "./ui"},
+	{key: "server.ui_base_path", variable: utils.EnvUIBasePath, defaultValue: "/"},
+	{key: "server.development_mode", variable: utils.EnvDevelopmentMode, kind: kindBool, defaultValue: "0"},
+	{key: "server.instance_id", variable: utils.EnvInstanceID},
+
+	{key: "tls.cert", variable: utils.EnvTLSCert},
+	{key: "tls.key", variable: utils.EnvTLSKey},
+	{key: "tls.acme_domains", variable: utils.EnvTLSACMEDomains},
+	{key: "tls.acme_email", variable: utils.EnvTLSACMEEmail},
+	{key: "tls.acme_cache", variable: utils.EnvTLSACMECache, defaultValue: "./certificates"},
+	{key: "tls.acme_directory", variable: utils.EnvTLSACMEDirectory, kind: kindURL},
+	{key: "tls.redirect_port", variable: utils.EnvTLSRedirectPort, kind: kindPort},
+
+	{key: "cache.path", variable: utils.EnvMediaCachePath, defaultValue: "./media_cache"},
+	{key: "cache.thumbnails", variable: utils.EnvMediaCacheThumbnails},
+	{key: "cache.web_versions", variable: utils.EnvMediaCacheWebVersions},
+	{key: "cache.video_transcodes", variable: utils.EnvMediaCacheVideoTranscodes},
+	{key: "cache.warmup_albums", variable: utils.EnvCacheWarmupAlbums, kind: kindNumber, defaultValue: "20"},
+	{key: "cache.thumbnail_sizes", variable: utils.EnvThumbnailSizes, kind: kindString},
+
+	{key: "scanner.face_recognition_models_path", variable: utils.EnvFaceRecognitionModelsPath, defaultValue: "data/models"},
+	{key: "scanner.disable_face_recognition", variable: utils.EnvDisableFaceRecognition, kind: kindBool, defaultValue: "0"},
+	{key: "scanner.disable_video_encoding", variable: utils.EnvDisableVideoEncoding, kind: kindBool, defaultValue: "0"},
+	{key: "scanner.disable_raw_processing", variable: utils.EnvDisableRawProcessing, kind: kindBool, defaultValue: "0"},
+	{key: "scanner.workers", variable: utils.EnvScannerWorkers, kind: kindNumber, defaultValue: "1"},
+	{key: "scanner.max_file_reads", variable: utils.EnvScannerMaxFileReads, kind: kindNumber, defaultValue: "0"},
+	{key: "scanner.max_thumbnail_jobs", variable: utils.EnvScannerMaxThumbnailJobs, kind: kindNumber, defaultValue: "0"},
+	{key: "scanner.read_limit", variable: utils.EnvScannerReadLimit, kind: kindNumber, defaultValue: "0"},
+	{key: "scanner.order", variable: utils.EnvScannerOrder, kind: kindOption, options: []string{"newest-first", "alphabetical", "largest-last"}, defaultValue: "newest-first"},
+	{key: "scanner.watch_library", variable: utils.EnvWatchLibrary, kind: kindBool, defaultValue: "0"},
+	{key: "scanner.min_file_size", variable: utils.EnvScannerMinFileSize, kind: kindNumber, defaultValue: "0"},
+	{key: "scanner.min_image_dimension", variable: utils.EnvScannerMinImageDimension, kind: kindNumber, defaultValue: "0"},
+	{key: "scanner.include_hidden_directories", variable: utils.EnvScanHiddenDirectories, kind: kindBool, defaultValue: "0"},
+	{key: "scanner.exclude_patterns", variable: utils.EnvExcludePatterns, kind: kindString},
+	{key: "scanner.video_codec", variable: utils.EnvVideoCodec, kind: kindOption, options: []string{"h264", "vp9"}, defaultValue: "h264"},
+	{key: "scanner.video_bitrate", variable: utils.EnvVideoBitrate, kind: kindString},
+	{key: "scanner.video_hardware_acceleration", variable: utils.EnvVideoHardwareAcceleration, kind: kindOption, options: []string{"none", "vaapi", "nvenc", "qsv"}, defaultValue: "none"},
+	{key: "scanner.video_vaapi_device", variable: utils.EnvVideoVAAPIDevice, defaultValue: "/dev/dri/renderD128"},
+	{key: "scanner.image_format", variable: utils.EnvImageFormat, kind: kindOption, options: []string{"jpeg", "webp"}, defaultValue: "jpeg"},
+	{key: "scanner.encode_avif", variable: utils.EnvEncodeAVIF, kind: kindBool, defaultValue: "0"},
+	{key: "scanner.image_backend", variable: utils.EnvImageBackend, kind: kindOption, options: []string{"go", "vips"}, defaultValue: "go"},
+	{key: "scanner.thumbnail_quality", variable: utils.EnvThumbnailQuality, kind: kindNumber, defaultValue: "60"},
+	{key: "scanner.thumbnail_max_size", variable: utils.EnvThumbnailMaxSize, kind: kindNumber, defaultValue: "1024"},
+	{key: "scanner.web_version_quality", variable: utils.EnvWebVersionQuality, kind: kindNumber, defaultValue: "70"},
+	{key: "scanner.web_version_max_size", variable: utils.EnvWebVersionMaxSize, kind: kindNumber, defaultValue: "0"},
+
+	{key: "features.webdav_writable", variable: utils.EnvWebDAVWritable, kind: kindBool, defaultValue: "0"},
+	{key: "features.max_upload_size", variable: utils.EnvMaxUploadSize, kind: kindNumber, defaultValue: "4096"},
+	{key: "features.enable_dlna", variable: utils.EnvEnableDLNA, kind: kindBool, defaultValue: "0"},
+	{key: "features.dlna_name", variable: utils.EnvDLNAFriendlyName, defaultValue: "Photoview"},
+	{key: "features.dlna_allowed_networks", variable: utils.EnvDLNAAllowedNetworks},
+	{key: "features.enable_gpu_thumbnails", variable: utils.EnvEnableGPUThumbnails, kind: kindBool, defaultValue: "0"},
+	{key: "features.share_strip_metadata", variable: utils.EnvShareStripMetadata, kind: kindBool, defaultValue: "0"},
+	{key: "features.tracing_enabled", variable: utils.EnvTracingEnabled, kind: kindBool, defaultValue: "0"},
+
+	{key: "log.level", variable: utils.EnvLogLevel, kind: kindOption, options: []string{"debug", "info", "warn", "error"}, defaultValue: "info"},
+	{key: "log.format", variable: utils.EnvLogFormat, kind: kindOption, options: []string{"text", "json"}, defaultValue: "text"},
+
+	{key: "smtp.host", variable: utils.EnvSMTPHost},
+	{key: "smtp.port", variable: utils.EnvSMTPPort, kind: kindPort, defaultValue: "587"},
+	{key: "smtp.username", variable: utils.EnvSMTPUsername},
+	{key: "smtp.password", variable: utils.EnvSMTPPassword, secret: true},
+	{key: "smtp.from", variable: utils.EnvSMTPFrom},
+
+	{key: "mail_in.listen", variable: utils.EnvMailInListen},
+	{key: "mail_in.address", variable: utils.EnvMailInAddress},
+	{key: "mail_in.token", variable: utils.EnvMailInToken},
+	{key: "mail_in.senders", variable: utils.EnvMailInSenders},
+	{key: "mail_in.album", variable: utils.EnvMailInAlbum},
+
+	{key: "demo.enabled", variable: utils.EnvDemoMode, kind: kindBool, defaultValue: "0"},
+	{key: "demo.path", variable: utils.EnvDemoPath, defaultValue: "./demo_library"},
+}
+
+// Source is where the effective value of an option comes from
+type Source string
+
+const (
+	SourceEnvironment Source = "environment"
+	SourceFile        Source = "config file"
+	SourceDefault     Source = "default"
+)
+
+// Value is the effective value of an option
+type Value struct {
+	Key      string
+	Variable utils.EnvironmentVariable
+	Value    string
+	Source   Source
+	Secret   bool
+}
+
+// Variables set from the configuration file by Load, to tell them apart from the ones set by the environment
+var fileVariables = make(map[utils.EnvironmentVariable]bool)
+
+// FilePath returns the path of the configuration file to read, given by PHOTOVIEW_CONFIG_FILE,
+// or photoview.yaml in the working directory if it exists. It returns an empty string if there is no configuration file.
+func FilePath() string {
+	if filePath := utils.EnvConfigFile.GetValue(); filePath != "" {
+		return filePath
+	}
+
+	if _, err := os.Stat(DefaultFileName); err == nil {
+		return DefaultFileName
+	}
+
+	return ""
+}
+
+// Load reads and validates the configuration file, and sets the environment variables of its options
+// that are not already set by the environment. Nothing is set if the file is invalid.
+func Load(filePath string) error {
+	values, err := ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	for variable, value := range values {
+		if _, isSet := os.LookupEnv(variable.GetName()); isSet {
+			continue
+		}
+
+		if err := os.Setenv(variable.GetName(), value); err != nil {
+			return errors.Wrapf(err, "set %s", variable.GetName())
+		}
+		fileVariables[variable] = true
+	}
+
+	return nil
+}
+
+// ReadFile reads the configuration file, and returns the values of the environment variables it sets.
+// Unknown keys and invalid values are reported as errors.
+func ReadFile(filePath string) (map[utils.EnvironmentVariable]string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "read configuration file")
+	}
+
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, errors.Wrapf(err, "parse configuration file (%s)", filePath)
+	}
+
+	flattened := make(map[string]string)
+	if err := flatten("", document, flattened); err != nil {
+		return nil, errors.Wrapf(err, "invalid configuration file (%s)", filePath)
+	}
+
+	keys := make([]string, 0, len(flattened))
+	for key := range flattened {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make(map[utils.EnvironmentVariable]string, len(flattened))
+	problems := make([]string, 0)
+	for _, key := range keys {
+		opt := findOption(key)
+		if opt == nil {
+			problems = append(problems, "unknown option "+key)
+			continue
+		}
+
+		value, err := opt.normalize(flattened[key])
+		if err != nil {
+			problems = append(problems, key+" "+err.Error())
+			continue
+		}
+
+		values[opt.variable] = value
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.Errorf("invalid configuration file (%s): %s", filePath, strings.Join(problems, "; "))
+	}
+
+	return values, nil
+}
+
+// flatten turns the sections of the document into keys such as database.driver
+func flatten(prefix string, document map[string]interface{}, result map[string]string) error {
+	for name, value := range document {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(key, value, result); err != nil {
+				return err
+			}
+		case []interface{}:
+			// Lists are allowed for options taking comma separated values, such as mail_in.senders
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = scalarString(item)
+			}
+			result[key] = strings.Join(items, ",")
+		default:
+			result[key] = scalarString(value)
+		}
+	}
+
+	return nil
+}
+
+func scalarString(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		if value {
+			return "1"
+		}
+		return "0"
+	case int:
+		return strconv.Itoa(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func findOption(key string) *option {
+	for _, opt := range options {
+		if opt.key == key {
+			return opt
+		}
+	}
+
+	return nil
+}
+
+// normalize validates a value of the option, and returns it in the form the server reads it
+func (opt *option) normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	switch opt.kind {
+	case kindBool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", errors.New("must be true or false")
+		}
+		if parsed {
+			return "1", nil
+		}
+		return "0", nil
+	case kindPort:
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return "", errors.New("must be a port number")
+		}
+		return value, nil
+	case kindNumber:
+		if number, err := strconv.Atoi(value); err != nil || number < 0 {
+			return "", errors.New("must be a positive number")
+		}
+		return value, nil
+	case kindOption:
+		value = strings.ToLower(value)
+		for _, option := range opt.options {
+			if value == option {
+				return value, nil
+			}
+		}
+		return "", errors.Errorf("must be one of: %s", strings.Join(opt.options, ", "))
+	case kindURL:
+		if _, err := url.Parse(value); err != nil {
+			return "", errors.New("must be a url")
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// Effective returns the value of every option, and where it comes from
+func Effective() []*Value {
+	result := make([]*Value, len(options))
+	for i, opt := range options {
+		value := &Value{
+			Key:      opt.key,
+			Variable: opt.variable,
+			Value:    opt.variable.GetEnvironmentValue(),
+			Secret:   opt.secret,
+		}
+
+		switch {
+		case fileVariables[opt.variable]:
+			value.Source = SourceFile
+		case value.Value != "":
+			value.Source = SourceEnvironment
+		default:
+			value.Value = opt.defaultValue
+			value.Source = SourceDefault
+		}
+
+		result[i] = value
+	}
+
+	return result
+}
+
+// Validate checks the effective configuration, including the values given by the environment,
+// and returns the problems found
+func Validate() []error {
+	problems := make([]error, 0)
+
+	for _, opt := range options {
+		value := opt.variable.GetEnvironmentValue()
+		if _, err := opt.normalize(value); err != nil {
+			problems = append(problems, errors.Errorf("%s (%s) %s", opt.key, opt.variable.GetName(), err))
+		}
+	}
+
+	driver := strings.ToLower(utils.EnvDatabaseDriver.GetEnvironmentValue())
+	switch driver {
+	case "", "mysql":
+		if utils.EnvMysqlURL.GetEnvironmentValue() == "" {
+			problems = append(problems, errors.New("database.mysql_url is required by the mysql driver"))
+		}
+	case "postgres":
+		if utils.EnvPostgresURL.GetEnvironmentValue() == "" {
+			problems = append(problems, errors.New("database.postgres_url is required by the postgres driver"))
+		}
+	}
+
+	if (utils.EnvTLSCert.GetEnvironmentValue() == "") != (utils.EnvTLSKey.GetEnvironmentValue() == "") {
+		problems = append(problems, errors.New("tls.cert and tls.key must be set together"))
+	}
+	if utils.EnvTLSCert.GetEnvironmentValue() != "" && utils.EnvTLSACMEDomains.GetEnvironmentValue() != "" {
+		problems = append(problems, errors.New("tls.acme_domains can not be used along with tls.cert"))
+	}
+
+	networkOptions := []struct {
+		key      string
+		variable utils.EnvironmentVariable
+	}{
+		{"server.trusted_proxies", utils.EnvTrustedProxies},
+		{"features.dlna_allowed_networks", utils.EnvDLNAAllowedNetworks},
+	}
+	for _, option := range networkOptions {
+		for _, network := range strings.Split(option.variable.GetEnvironmentValue(), ",") {
+			if network = strings.TrimSpace(network); network == "" {
+				continue
+			}
+			if _, err := utils.ParseNetwork(network); err != nil {
+				problems = append(problems, errors.Errorf("%s (%s) must be ip addresses or networks: %s", option.key, option.variable.GetName(), network))
+			}
+		}
+	}
+
+	if utils.EnvMailInListen.GetEnvironmentValue() != "" &&
+		(utils.EnvMailInAddress.GetEnvironmentValue() == "" || utils.EnvMailInToken.GetEnvironmentValue() == "" ||
+			utils.EnvMailInAlbum.GetEnvironmentValue() == "") {
+		problems = append(problems, errors.New("mail_in.address, mail_in.token and mail_in.album are required to receive mail"))
+	}
+
+	return problems
+}
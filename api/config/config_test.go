@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	filePath := path.Join(t.TempDir(), "photoview.yaml")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestReadFile(t *testing.T) {
+	t.Run("Valid file", func(t *testing.T) {
+		filePath := writeConfigFile(t, `
+database:
+  driver: SQLite
+  sqlite_path: /data/photoview.db
+server:
+  listen_port: 8080
+  serve_ui: true
+mail_in:
+  senders: [alice@example.com, bob@example.com]
+`)
+
+		values, err := ReadFile(filePath)
+		assert.NoError(t, err)
+		assert.Equal(t, map[utils.EnvironmentVariable]string{
+			utils.EnvDatabaseDriver: "sqlite",
+			utils.EnvSqlitePath:     "/data/photoview.db",
+			utils.EnvListenPort:     "8080",
+			utils.EnvServeUI:        "1",
+			utils.EnvMailInSenders:  "alice@example.com,bob@example.com",
+		}, values)
+	})
+
+	t.Run("Invalid values and unknown options", func(t *testing.T) {
+		filePath := writeConfigFile(t, `
+database:
+  driver: oracle
+server:
+  listen_port: 70000
+  listen_address: 0.0.0.0
+`)
+
+		_, err := ReadFile(filePath)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "database.driver must be one of: mysql, postgres, sqlite")
+			assert.Contains(t, err.Error(), "server.listen_port must be a port number")
+			assert.Contains(t, err.Error(), "unknown option server.listen_address")
+		}
+	})
+
+	t.Run("Malformed file", func(t *testing.T) {
+		_, err := ReadFile(writeConfigFile(t, "database: [driver"))
+		assert.Error(t, err)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv(utils.EnvListenPort.GetName(), "5000")
+	t.Setenv(utils.EnvDLNAFriendlyName.GetName(), "")
+	os.Unsetenv(utils.EnvDLNAFriendlyName.GetName())
+	t.Setenv(utils.EnvSMTPPassword.GetName(), "")
+	os.Unsetenv(utils.EnvSMTPPassword.GetName())
+
+	filePath := writeConfigFile(t, `
+server:
+  listen_port: 8080
+features:
+  dlna_name: Living room
+smtp:
+  password: secret
+`)
+
+	assert.NoError(t, Load(filePath))
+	t.Cleanup(func() {
+		delete(fileVariables, utils.EnvDLNAFriendlyName)
+		delete(fileVariables, utils.EnvSMTPPassword)
+	})
+
+	assert.Equal(t, "5000", utils.EnvListenPort.GetValue(), "the environment takes precedence over the file")
+	assert.Equal(t, "Living room", utils.EnvDLNAFriendlyName.GetValue())
+
+	values := make(map[string]*Value)
+	for _, value := range Effective() {
+		values[value.Key] = value
+	}
+
+	assert.Equal(t, SourceEnvironment, values["server.listen_port"].Source)
+	assert.Equal(t, SourceFile, values["features.dlna_name"].Source)
+	assert.True(t, values["smtp.password"].Secret)
+	if _, isSet := os.LookupEnv(utils.EnvLogFormat.GetName()); !isSet {
+		assert.Equal(t, SourceDefault, values["log.format"].Source)
+		assert.Equal(t, "text", values["log.format"].Value)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/photoview/photoview/api/config"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+// runConfigCommand validates the configuration, given by the configuration file and the environment,
+// and prints the effective value of every option.
+//
+//	photoview config check -file /etc/photoview/photoview.yaml
+func runConfigCommand(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New("expected check")
+	}
+
+	flags := flag.NewFlagSet("config check", flag.ContinueOnError)
+	file := flags.String("file", config.FilePath(), "path of the configuration file")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *file != "" {
+		if err := config.Load(*file); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Configuration file: %s\n\n", *file)
+	} else {
+		fmt.Fprintf(out, "No configuration file, set %s or create %s\n\n", utils.EnvConfigFile.GetName(), config.DefaultFileName)
+	}
+
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "OPTION\tVARIABLE\tVALUE\tSOURCE")
+	for _, value := range config.Effective() {
+		shown := value.Value
+		if value.Secret && shown != "" {
+			shown = "********"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", value.Key, value.Variable.GetName(), shown, value.Source)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	problems := config.Validate()
+	if len(problems) == 0 {
+		fmt.Fprintln(out, "\nConfiguration is valid")
+		return nil
+	}
+
+	fmt.Fprintln(out, "\nProblems:")
+	for _, problem := range problems {
+		fmt.Fprintf(out, "- %s\n", problem)
+	}
+
+	return errors.Errorf("found %d problems in the configuration", len(problems))
+}
@@ -3,12 +3,13 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/url"
 	"time"
 
 	"github.com/photoview/photoview/api/database/drivers"
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/tracing"
 	"github.com/photoview/photoview/api/utils"
 	"github.com/pkg/errors"
 
@@ -65,15 +66,13 @@ func GetSqliteAddress(path string) (*url.URL, error) {
 	// queryValues.Add("_busy_timeout", "60000") // 1 minute
 	address.RawQuery = queryValues.Encode()
 
-	// log.Panicf("%s", address.String())
-
 	return address, nil
 }
 
 func ConfigureDatabase(config *gorm.Config) (*gorm.DB, error) {
 	var databaseDialect gorm.Dialector
 	driver := drivers.DatabaseDriverFromEnv()
-	log.Printf("Utilizing %s database driver based on environment variables", driver)
+	log.Info(context.Background(), "Utilizing database driver based on environment variables", "driver", driver)
 
 	switch driver {
 	case drivers.MYSQL:
@@ -102,6 +101,12 @@ func ConfigureDatabase(config *gorm.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	if tracing.Enabled() {
+		if err := db.Use(tracing.GormPlugin{}); err != nil {
+			return nil, err
+		}
+	}
+
 	// Manually enable foreign keys for sqlite, as this isn't done by default
 	if drivers.SQLITE.MatchDatabase(db) {
 		db.Exec("PRAGMA foreign_keys = ON")
@@ -110,6 +115,31 @@ func ConfigureDatabase(config *gorm.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
+// OpenExternalDatabase connects to the database of another application, such as one being migrated from
+func OpenExternalDatabase(driver drivers.DatabaseDriverType, address string) (*gorm.DB, error) {
+	var databaseDialect gorm.Dialector
+
+	switch driver {
+	case drivers.MYSQL:
+		config, err := mysql.ParseDSN(address)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not parse mysql url")
+		}
+		config.ParseTime = true
+		databaseDialect = gorm_mysql.Open(config.FormatDSN())
+	case drivers.SQLITE:
+		databaseDialect = sqlite.Open(address)
+	case drivers.POSTGRES:
+		databaseDialect = postgres.Open(address)
+	default:
+		return nil, errors.Errorf("unsupported database driver: %s", driver)
+	}
+
+	return gorm.Open(databaseDialect, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+}
+
 // SetupDatabase connects to the database using environment variables
 func SetupDatabase() (*gorm.DB, error) {
 
@@ -145,7 +175,7 @@ func SetupDatabase() (*gorm.DB, error) {
 			}
 		}
 
-		log.Printf("WARN: Could not ping database: %s. Will retry after 5 seconds\n", err)
+		log.Warn(context.Background(), "Could not ping database. Will retry after 5 seconds", "error", err)
 		time.Sleep(time.Duration(5) * time.Second)
 	}
 
@@ -158,13 +188,37 @@ var database_models []interface{} = []interface{}{
 	&models.SiteInfo{},
 	&models.Media{},
 	&models.MediaURL{},
+	&models.CacheEntry{},
 	&models.Album{},
 	&models.MediaEXIF{},
 	&models.VideoMetadata{},
+	&models.MediaHistogram{},
 	&models.ShareToken{},
+	&models.CastSession{},
+	&models.PhotoFrame{},
+	&models.Watermark{},
+	&models.Webhook{},
+	&models.Setting{},
+	&models.NotificationChannel{},
+	&models.UserNotification{},
+	&models.AlbumStorageUsage{},
+	&models.ScanReport{},
+	&models.ScanFailure{},
+	&models.ScanStatistics{},
+	&models.MediaRetry{},
+	&models.Lease{},
+	&models.CacheAccessStats{},
 	&models.UserMediaData{},
 	&models.UserAlbums{},
 	&models.UserPreferences{},
+	&models.MediaRetrieval{},
+	&models.StorageBackend{},
+	&models.StorageMapping{},
+	&models.Device{},
+	&models.DeviceAsset{},
+	&models.UploadSession{},
+	&models.ImportJob{},
+	&models.MediaPerson{},
 
 	// Face detection
 	&models.FaceGroup{},
@@ -174,11 +228,11 @@ var database_models []interface{} = []interface{}{
 func MigrateDatabase(db *gorm.DB) error {
 
 	if err := db.SetupJoinTable(&models.User{}, "Albums", &models.UserAlbums{}); err != nil {
-		log.Printf("Setup UserAlbums join table failed: %v\n", err)
+		log.Error(db.Statement.Context, "Setup UserAlbums join table failed", "error", err)
 	}
 
 	if err := db.AutoMigrate(database_models...); err != nil {
-		log.Printf("Auto migration failed: %v\n", err)
+		log.Error(db.Statement.Context, "Auto migration failed", "error", err)
 	}
 
 	// v2.1.0 - Replaced by Media.CreatedAt
@@ -189,7 +243,12 @@ func MigrateDatabase(db *gorm.DB) error {
 	// v2.3.0 - Changed type of MediaEXIF.Exposure and MediaEXIF.Flash
 	// from string values to decimal and int respectively
 	if err := migrate_exif_fields(db); err != nil {
-		log.Printf("Failed to run exif fields migration: %v\n", err)
+		log.Error(db.Statement.Context, "Failed to run exif fields migration", "error", err)
+	}
+
+	// Originals of photos are saved with the dimensions they are displayed in, rotated to their EXIF orientation
+	if err := migrate_original_orientation(db); err != nil {
+		log.Error(db.Statement.Context, "Failed to run original orientation migration", "error", err)
 	}
 
 	return nil
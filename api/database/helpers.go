@@ -2,7 +2,6 @@ package database
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/photoview/photoview/api/database/drivers"
 	"gorm.io/gorm"
@@ -39,7 +38,7 @@ func DateExtract(db *gorm.DB, component DateComponent, attribute string) string
 
 		result = fmt.Sprintf("CAST(strftime('%s', %s) AS INTEGER)", sqliteFormatted, attribute)
 	default:
-		log.Panicf("unsupported database backend: %s", drivers.GetDatabaseDriverType(db))
+		panic(fmt.Sprintf("unsupported database backend: %s", drivers.GetDatabaseDriverType(db)))
 	}
 
 	return result
@@ -2,11 +2,11 @@ package database
 
 import (
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
@@ -60,7 +60,7 @@ func migrate_exif_fields(db *gorm.DB) error {
 }
 
 func migrate_exif_fields_exposure(db *gorm.DB) error {
-	log.Println("Migrating `media_exif.exposure` from string to double")
+	log.Info(db.Statement.Context, "Migrating `media_exif.exposure` from string to double")
 
 	err := db.Transaction(func(tx *gorm.DB) error {
 
@@ -114,7 +114,7 @@ func migrate_exif_fields_exposure(db *gorm.DB) error {
 }
 
 func migrate_exif_fields_flash(db *gorm.DB) error {
-	log.Println("Migrating `media_exif.flash` from string to int")
+	log.Info(db.Statement.Context, "Migrating `media_exif.flash` from string to int")
 
 	err := db.Transaction(func(tx *gorm.DB) error {
 
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Swap the width and height of originals of photos that were saved without applying their EXIF orientation,
+// which are the originals that are portrait while their thumbnail is landscape, or the other way around,
+// as thumbnails have always been rotated to the orientation of the photo
+func migrate_original_orientation(db *gorm.DB) error {
+	thumbnails := db.Table("media_urls AS thumbnails").
+		Select("1").
+		Where("thumbnails.media_id = media_urls.media_id AND thumbnails.purpose = ?", models.PhotoThumbnail).
+		Where("(media_urls.width > media_urls.height AND thumbnails.width < thumbnails.height) OR " +
+			"(media_urls.width < media_urls.height AND thumbnails.width > thumbnails.height)")
+
+	var originals []*models.MediaURL
+	err := db.Where("media_urls.purpose = ? AND EXISTS (?)", models.MediaOriginal, thumbnails).
+		Find(&originals).Error
+	if err != nil {
+		return errors.Wrap(err, "get originals with the dimensions of their unrotated photo")
+	}
+
+	for _, original := range originals {
+		err := db.Model(original).
+			UpdateColumns(map[string]interface{}{"width": original.Height, "height": original.Width}).Error
+		if err != nil {
+			return errors.Wrapf(err, "swap dimensions of original (%d)", original.ID)
+		}
+	}
+
+	return nil
+}
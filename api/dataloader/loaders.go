@@ -14,6 +14,8 @@ type Loaders struct {
 	MediaThumbnail      *MediaURLLoader
 	MediaHighres        *MediaURLLoader
 	MediaVideoWeb       *MediaURLLoader
+	MediaMotionPhoto    *MediaURLLoader
+	MediaVideoSprite    *MediaURLLoader
 	UserFromAccessToken *UserLoader
 	UserMediaFavorite   *UserFavoritesLoader
 }
@@ -26,6 +28,8 @@ func Middleware(db *gorm.DB) mux.MiddlewareFunc {
 				MediaThumbnail:      NewThumbnailMediaURLLoader(db),
 				MediaHighres:        NewHighresMediaURLLoader(db),
 				MediaVideoWeb:       NewVideoWebMediaURLLoader(db),
+				MediaMotionPhoto:    NewMotionPhotoMediaURLLoader(db),
+				MediaVideoSprite:    NewVideoSpriteMediaURLLoader(db),
 				UserFromAccessToken: NewUserLoaderByToken(db),
 				UserMediaFavorite:   NewUserFavoriteLoader(db),
 			})
@@ -66,7 +66,30 @@ func NewVideoWebMediaURLLoader(db *gorm.DB) *MediaURLLoader {
 		maxBatch: 100,
 		wait:     5 * time.Millisecond,
 		fetch: makeMediaURLLoader(db, func(query *gorm.DB) *gorm.DB {
-			return query.Where("purpose = ? OR purpose = ?", models.VideoWeb, models.MediaOriginal)
+			// Videos with both have a web video because browsers can't play their original, it is ordered last so it is used
+			return query.Where("purpose = ? OR purpose = ?", models.VideoWeb, models.MediaOriginal).
+				Order("CASE WHEN purpose = '" + string(models.VideoWeb) + "' THEN 1 ELSE 0 END")
+		}),
+	}
+}
+
+func NewMotionPhotoMediaURLLoader(db *gorm.DB) *MediaURLLoader {
+	return &MediaURLLoader{
+		maxBatch: 100,
+		wait:     5 * time.Millisecond,
+		fetch: makeMediaURLLoader(db, func(query *gorm.DB) *gorm.DB {
+			return query.Where("purpose = ?", models.MotionVideo)
+		}),
+	}
+}
+
+// NewVideoSpriteMediaURLLoader loads the scrub sprites of videos, with the metadata of the videos, as their layout depends on their duration
+func NewVideoSpriteMediaURLLoader(db *gorm.DB) *MediaURLLoader {
+	return &MediaURLLoader{
+		maxBatch: 100,
+		wait:     5 * time.Millisecond,
+		fetch: makeMediaURLLoader(db, func(query *gorm.DB) *gorm.DB {
+			return query.Where("purpose = ?", models.VideoSprite).Preload("Media.VideoMetadata")
 		}),
 	}
 }
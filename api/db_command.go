@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/photoview/photoview/api/database/drivers"
+	"github.com/photoview/photoview/api/log"
+	"gorm.io/gorm"
+)
+
+// runDatabaseCommand migrates the database schema and exits, such as before rolling out a new version to several instances.
+// The database has already been migrated on startup by the time commands are run.
+//
+//	photoview db migrate
+func runDatabaseCommand(db *gorm.DB, args []string) error {
+	if _, err := expectSubcommand(args, "migrate"); err != nil {
+		return err
+	}
+
+	log.Info(db.Statement.Context, "Database migrated", "driver", drivers.GetDatabaseDriverType(db))
+	return nil
+}
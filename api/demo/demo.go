@@ -0,0 +1,288 @@
+// Package demo generates a synthetic sample library, with placeholder photos carrying made up exif and gps metadata,
+// and creates users owning it, for demos, frontend development and reproducible bug reports without personal photos.
+package demo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math/rand"
+	"os"
+	"path"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Password of the users created for the sample library
+const Password = "demo"
+
+// Name of the directory, shared by all users, of the sample library
+const sharedDirectory = "shared"
+
+// Options of the generated sample library
+type Options struct {
+	// Directory the sample library is written to
+	Path string
+	// Seed of the generator, the same seed always generates the same library
+	Seed int64
+	// Number of users, the first is named demo and is an admin
+	Users int
+	// Number of albums of every user, in addition to the album shared by all users
+	Albums int
+	// Number of photos in every album
+	Photos int
+	// Size of the generated photos
+	Width, Height int
+}
+
+// DefaultOptions returns the options of the sample library generated by the demo mode
+func DefaultOptions(libraryPath string) Options {
+	return Options{
+		Path:   libraryPath,
+		Seed:   1,
+		Users:  3,
+		Albums: 4,
+		Photos: 8,
+		Width:  1200,
+		Height: 800,
+	}
+}
+
+// Result summarizes a generated sample library
+type Result struct {
+	Users []*models.User
+	// Number of albums of the library, and of the photos written that didn't exist already
+	Albums int
+	Photos int
+}
+
+var usernames = []string{"demo", "alice", "bob", "carol", "dave", "erin", "frank", "grace"}
+
+type place struct {
+	Name                string
+	Latitude, Longitude float64
+}
+
+var places = []place{
+	{"Copenhagen", 55.6761, 12.5683},
+	{"Kyoto", 35.0116, 135.7681},
+	{"Lisbon", 38.7223, -9.1393},
+	{"Reykjavik", 64.1466, -21.9426},
+	{"Cape Town", -33.9249, 18.4241},
+	{"Vancouver", 49.2827, -123.1207},
+	{"Buenos Aires", -34.6037, -58.3816},
+	{"Sydney", -33.8688, 151.2093},
+	{"Marrakesh", 31.6295, -7.9811},
+	{"Hanoi", 21.0278, 105.8342},
+}
+
+var cameras = [][2]string{
+	{"Canon", "Canon EOS R6"},
+	{"NIKON CORPORATION", "NIKON Z 6_2"},
+	{"FUJIFILM", "X-T4"},
+	{"SONY", "ILCE-7M3"},
+	{"Apple", "iPhone 13"},
+}
+
+// Seed writes a sample library to the path of the options and creates its users,
+// each owning a directory of their own and the directory shared by all users.
+// Existing files and users are kept, so seeding again with the same options only fills in what is missing.
+// The library has to be scanned afterwards for its media to show up.
+func Seed(db *gorm.DB, options Options) (*Result, error) {
+	if options.Users < 1 || options.Users > len(usernames) {
+		return nil, errors.Errorf("number of demo users must be between 1 and %d", len(usernames))
+	}
+
+	random := rand.New(rand.NewSource(options.Seed))
+	result := &Result{}
+
+	sharedPath := path.Join(options.Path, sharedDirectory)
+	if err := generateAlbums(random, sharedPath, 1, options, result); err != nil {
+		return nil, err
+	}
+
+	userPaths := make([]string, options.Users)
+	for i := range userPaths {
+		userPaths[i] = path.Join(options.Path, usernames[i])
+		if err := generateAlbums(random, userPaths[i], options.Albums, options, result); err != nil {
+			return nil, err
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i, rootPath := range userPaths {
+			user, err := seedUser(tx, usernames[i], i == 0, []string{rootPath, sharedPath})
+			if err != nil {
+				return err
+			}
+			result.Users = append(result.Users, user)
+		}
+
+		// The demo admin takes the place of the one created by the setup wizard
+		if _, err := models.GetSiteInfo(tx); err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE site_info SET initial_setup = false").Error; err != nil {
+			return errors.Wrap(err, "finish initial setup")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// seedUser creates a user with the given root paths, unless the user already exists
+func seedUser(db *gorm.DB, username string, admin bool, rootPaths []string) (*models.User, error) {
+	var user models.User
+	err := db.Where("username = ?", username).First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrapf(err, "get demo user %s", username)
+	}
+
+	password := Password
+	newUser, err := models.RegisterUser(db, username, &password, admin)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create demo user %s", username)
+	}
+
+	for _, rootPath := range rootPaths {
+		if _, err := scanner.NewRootAlbum(db, rootPath, newUser); err != nil {
+			return nil, errors.Wrapf(err, "add root path %s to demo user %s", rootPath, username)
+		}
+	}
+
+	return newUser, nil
+}
+
+// generateAlbums writes albums of photos to a directory, each named after the place and year the photos were taken
+func generateAlbums(random *rand.Rand, dir string, count int, options Options, result *Result) error {
+	for i := 0; i < count; i++ {
+		albumPlace := places[random.Intn(len(places))]
+		start := time.Date(2015+random.Intn(9), time.Month(1+random.Intn(12)), 1+random.Intn(28), 8, 0, 0, 0, time.UTC)
+		camera := cameras[random.Intn(len(cameras))]
+
+		albumPath := path.Join(dir, fmt.Sprintf("%d %s", start.Year(), albumPlace.Name))
+		if err := os.MkdirAll(albumPath, 0755); err != nil {
+			return errors.Wrap(err, "create demo album directory")
+		}
+
+		shot := start
+		for j := 0; j < options.Photos; j++ {
+			shot = shot.Add(time.Duration(10+random.Intn(180)) * time.Minute)
+
+			metadata := &photoEXIF{
+				Make:        camera[0],
+				Model:       camera[1],
+				DateShot:    shot,
+				Exposure:    [2]uint32{1, uint32(30 + random.Intn(1000))},
+				Aperture:    [2]uint32{uint32(14 + random.Intn(100)), 10},
+				ISO:         uint16(100 * (1 + random.Intn(32))),
+				FocalLength: [2]uint32{uint32(18 + random.Intn(180)), 1},
+				Latitude:    albumPlace.Latitude + (random.Float64()-0.5)*0.1,
+				Longitude:   albumPlace.Longitude + (random.Float64()-0.5)*0.1,
+			}
+
+			// The generator is advanced the same way whether the photo exists or not,
+			// so existing libraries are completed with the same photos as a fresh one
+			img := generateImage(random, options.Width, options.Height)
+
+			photoPath := path.Join(albumPath, fmt.Sprintf("IMG_%04d.jpg", j+1))
+			if _, err := os.Stat(photoPath); err == nil {
+				continue
+			}
+
+			data := new(bytes.Buffer)
+			if err := jpeg.Encode(data, img, &jpeg.Options{Quality: 85}); err != nil {
+				return errors.Wrap(err, "encode demo photo")
+			}
+
+			if err := os.WriteFile(photoPath, withEXIF(data.Bytes(), metadata), 0644); err != nil {
+				return errors.Wrap(err, "write demo photo")
+			}
+			result.Photos++
+		}
+
+		result.Albums++
+	}
+
+	return nil
+}
+
+// generateImage draws a placeholder photo, a gradient between two random colors with a few random shapes on top
+func generateImage(random *rand.Rand, width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	from, to := randomColor(random), randomColor(random)
+	for y := 0; y < height; y++ {
+		t := float64(y) / float64(height)
+		line := color.RGBA{
+			R: mix(from.R, to.R, t),
+			G: mix(from.G, to.G, t),
+			B: mix(from.B, to.B, t),
+			A: 255,
+		}
+		draw.Draw(img, image.Rect(0, y, width, y+1), image.NewUniform(line), image.Point{}, draw.Src)
+	}
+
+	shapes := 3 + random.Intn(5)
+	for i := 0; i < shapes; i++ {
+		shape := randomColor(random)
+		shape.A = 160
+		fill := image.NewUniform(shape)
+
+		centerX, centerY := random.Intn(width), random.Intn(height)
+		radius := height/10 + random.Intn(height/4)
+
+		if random.Intn(2) == 0 {
+			rect := image.Rect(centerX-radius, centerY-radius, centerX+radius, centerY+radius)
+			draw.Draw(img, rect, fill, image.Point{}, draw.Over)
+		} else {
+			draw.DrawMask(img, img.Bounds(), fill, image.Point{}, &circle{centerX, centerY, radius}, image.Point{}, draw.Over)
+		}
+	}
+
+	return img
+}
+
+func randomColor(random *rand.Rand) color.RGBA {
+	return color.RGBA{R: uint8(random.Intn(256)), G: uint8(random.Intn(256)), B: uint8(random.Intn(256)), A: 255}
+}
+
+func mix(from, to uint8, t float64) uint8 {
+	return uint8(float64(from)*(1-t) + float64(to)*t)
+}
+
+// circle is a mask of a filled circle
+type circle struct {
+	x, y, r int
+}
+
+func (c *circle) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (c *circle) Bounds() image.Rectangle {
+	return image.Rect(c.x-c.r, c.y-c.r, c.x+c.r, c.y+c.r)
+}
+
+func (c *circle) At(x, y int) color.Color {
+	dx, dy := x-c.x, y-c.y
+	if dx*dx+dy*dy <= c.r*c.r {
+		return color.Alpha{A: 255}
+	}
+	return color.Alpha{A: 0}
+}
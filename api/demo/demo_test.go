@@ -0,0 +1,108 @@
+package demo_test
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/photoview/photoview/api/demo"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/exif"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestSeed(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	options := demo.Options{
+		Path:   t.TempDir(),
+		Seed:   42,
+		Users:  2,
+		Albums: 2,
+		Photos: 3,
+		Width:  120,
+		Height: 80,
+	}
+
+	result, err := demo.Seed(db, options)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Every user has two albums of their own and the shared album
+	assert.Equal(t, 5, result.Albums)
+	assert.Equal(t, 15, result.Photos)
+	if assert.Len(t, result.Users, 2) {
+		assert.Equal(t, "demo", result.Users[0].Username)
+		assert.True(t, result.Users[0].Admin)
+		assert.Equal(t, "alice", result.Users[1].Username)
+		assert.False(t, result.Users[1].Admin)
+	}
+
+	for _, user := range result.Users {
+		var rootAlbums int64
+		assert.NoError(t, db.Table("user_albums").Where("user_id = ?", user.ID).Count(&rootAlbums).Error)
+		assert.EqualValues(t, 2, rootAlbums)
+	}
+
+	siteInfo, err := models.GetSiteInfo(db)
+	assert.NoError(t, err)
+	assert.False(t, siteInfo.InitialSetup)
+
+	photos, err := filepath.Glob(path.Join(options.Path, "alice", "*", "IMG_*.jpg"))
+	assert.NoError(t, err)
+	if !assert.Len(t, photos, 6) {
+		return
+	}
+
+	parsed, err := exif.NewInternalExifParser().ParseExif(photos[0])
+	if !assert.NoError(t, err) || !assert.NotNil(t, parsed) {
+		return
+	}
+	assert.NotNil(t, parsed.Camera)
+	assert.NotNil(t, parsed.Maker)
+	assert.NotNil(t, parsed.DateShot)
+	assert.NotNil(t, parsed.Exposure)
+	assert.NotNil(t, parsed.Aperture)
+	assert.NotNil(t, parsed.Iso)
+	assert.NotNil(t, parsed.FocalLength)
+	assert.NotNil(t, parsed.GPSLatitude)
+	assert.NotNil(t, parsed.GPSLongitude)
+
+	// Seeding again with the same options keeps the existing users and files
+	again, err := demo.Seed(db, options)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, again.Photos)
+		assert.Equal(t, result.Users[0].ID, again.Users[0].ID)
+	}
+}
+
+func TestSeedIsReproducible(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	options := demo.Options{Seed: 7, Users: 1, Albums: 1, Photos: 2, Width: 60, Height: 40}
+
+	read := func() map[string][]byte {
+		options.Path = t.TempDir()
+		_, err := demo.Seed(db, options)
+		assert.NoError(t, err)
+
+		files := make(map[string][]byte)
+		photos, _ := filepath.Glob(path.Join(options.Path, "*", "*", "*.jpg"))
+		for _, photo := range photos {
+			relative, _ := filepath.Rel(options.Path, photo)
+			files[relative], _ = os.ReadFile(photo)
+		}
+		return files
+	}
+
+	first := read()
+	assert.Len(t, first, 4)
+	assert.Equal(t, first, read())
+}
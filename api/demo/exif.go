@@ -0,0 +1,204 @@
+package demo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sort"
+	"time"
+)
+
+// Types of the values of exif tags
+const (
+	exifASCII    uint16 = 2
+	exifShort    uint16 = 3
+	exifLong     uint16 = 4
+	exifRational uint16 = 5
+)
+
+// Tags written to the generated photos, in the order they have to appear in their directories
+const (
+	tagMake             uint16 = 0x010F
+	tagModel            uint16 = 0x0110
+	tagOrientation      uint16 = 0x0112
+	tagExifIFD          uint16 = 0x8769
+	tagGPSIFD           uint16 = 0x8825
+	tagExposureTime     uint16 = 0x829A
+	tagFNumber          uint16 = 0x829D
+	tagISOSpeedRatings  uint16 = 0x8827
+	tagDateTimeOriginal uint16 = 0x9003
+	tagFocalLength      uint16 = 0x920A
+	tagGPSLatitudeRef   uint16 = 0x0001
+	tagGPSLatitude      uint16 = 0x0002
+	tagGPSLongitudeRef  uint16 = 0x0003
+	tagGPSLongitude     uint16 = 0x0004
+)
+
+// photoEXIF is the metadata written to a generated photo
+type photoEXIF struct {
+	Make        string
+	Model       string
+	DateShot    time.Time
+	Exposure    [2]uint32
+	Aperture    [2]uint32
+	ISO         uint16
+	FocalLength [2]uint32
+	Latitude    float64
+	Longitude   float64
+}
+
+type exifEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+// withEXIF inserts an exif segment holding the metadata right after the start of a jpeg image
+func withEXIF(jpegData []byte, metadata *photoEXIF) []byte {
+	tiff := encodeTIFF(metadata)
+
+	segment := new(bytes.Buffer)
+	segment.Write([]byte{0xFF, 0xE1})
+	binary.Write(segment, binary.BigEndian, uint16(2+6+len(tiff)))
+	segment.WriteString("Exif\x00\x00")
+	segment.Write(tiff)
+
+	result := make([]byte, 0, len(jpegData)+segment.Len())
+	result = append(result, jpegData[:2]...)
+	result = append(result, segment.Bytes()...)
+	return append(result, jpegData[2:]...)
+}
+
+// encodeTIFF encodes the metadata as the big endian tiff structure of an exif segment,
+// with the main directory followed by the exif and gps directories it points to
+func encodeTIFF(metadata *photoEXIF) []byte {
+	ifd0 := []exifEntry{
+		asciiEntry(tagMake, metadata.Make),
+		asciiEntry(tagModel, metadata.Model),
+		shortEntry(tagOrientation, 1),
+		longEntry(tagExifIFD, 0),
+		longEntry(tagGPSIFD, 0),
+	}
+
+	exifIFD := []exifEntry{
+		rationalEntry(tagExposureTime, metadata.Exposure),
+		rationalEntry(tagFNumber, metadata.Aperture),
+		shortEntry(tagISOSpeedRatings, metadata.ISO),
+		asciiEntry(tagDateTimeOriginal, metadata.DateShot.Format("2006:01:02 15:04:05")),
+		rationalEntry(tagFocalLength, metadata.FocalLength),
+	}
+
+	latitudeRef, longitudeRef := "N", "E"
+	if metadata.Latitude < 0 {
+		latitudeRef = "S"
+	}
+	if metadata.Longitude < 0 {
+		longitudeRef = "W"
+	}
+
+	gpsIFD := []exifEntry{
+		asciiEntry(tagGPSLatitudeRef, latitudeRef),
+		rationalEntry(tagGPSLatitude, degreesToRationals(math.Abs(metadata.Latitude))...),
+		asciiEntry(tagGPSLongitudeRef, longitudeRef),
+		rationalEntry(tagGPSLongitude, degreesToRationals(math.Abs(metadata.Longitude))...),
+	}
+
+	const headerSize = 8
+	exifOffset := headerSize + ifdSize(ifd0)
+	gpsOffset := exifOffset + ifdSize(exifIFD)
+	ifd0[3] = longEntry(tagExifIFD, uint32(exifOffset))
+	ifd0[4] = longEntry(tagGPSIFD, uint32(gpsOffset))
+
+	tiff := new(bytes.Buffer)
+	tiff.WriteString("MM")
+	binary.Write(tiff, binary.BigEndian, uint16(42))
+	binary.Write(tiff, binary.BigEndian, uint32(headerSize))
+
+	writeIFD(tiff, ifd0, headerSize)
+	writeIFD(tiff, exifIFD, exifOffset)
+	writeIFD(tiff, gpsIFD, gpsOffset)
+
+	return tiff.Bytes()
+}
+
+// ifdSize returns the size of a directory, including the values too large to fit in its entries
+func ifdSize(entries []exifEntry) int {
+	size := 2 + 12*len(entries) + 4
+	for _, entry := range entries {
+		if len(entry.data) > 4 {
+			size += len(entry.data) + len(entry.data)%2
+		}
+	}
+	return size
+}
+
+func writeIFD(buf *bytes.Buffer, entries []exifEntry, offset int) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	dataOffset := offset + 2 + 12*len(entries) + 4
+	data := new(bytes.Buffer)
+
+	binary.Write(buf, binary.BigEndian, uint16(len(entries)))
+	for _, entry := range entries {
+		binary.Write(buf, binary.BigEndian, entry.tag)
+		binary.Write(buf, binary.BigEndian, entry.typ)
+		binary.Write(buf, binary.BigEndian, entry.count)
+
+		if len(entry.data) <= 4 {
+			value := make([]byte, 4)
+			copy(value, entry.data)
+			buf.Write(value)
+			continue
+		}
+
+		binary.Write(buf, binary.BigEndian, uint32(dataOffset+data.Len()))
+		data.Write(entry.data)
+		if len(entry.data)%2 == 1 {
+			data.WriteByte(0)
+		}
+	}
+
+	// No next directory
+	binary.Write(buf, binary.BigEndian, uint32(0))
+	buf.Write(data.Bytes())
+}
+
+func asciiEntry(tag uint16, value string) exifEntry {
+	data := append([]byte(value), 0)
+	return exifEntry{tag: tag, typ: exifASCII, count: uint32(len(data)), data: data}
+}
+
+func shortEntry(tag uint16, value uint16) exifEntry {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, value)
+	return exifEntry{tag: tag, typ: exifShort, count: 1, data: data}
+}
+
+func longEntry(tag uint16, value uint32) exifEntry {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, value)
+	return exifEntry{tag: tag, typ: exifLong, count: 1, data: data}
+}
+
+func rationalEntry(tag uint16, values ...[2]uint32) exifEntry {
+	data := make([]byte, 8*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint32(data[8*i:], value[0])
+		binary.BigEndian.PutUint32(data[8*i+4:], value[1])
+	}
+	return exifEntry{tag: tag, typ: exifRational, count: uint32(len(values)), data: data}
+}
+
+// degreesToRationals converts a coordinate into degrees, minutes and seconds, as stored by gps tags
+func degreesToRationals(value float64) [][2]uint32 {
+	degrees := math.Floor(value)
+	minutes := math.Floor((value - degrees) * 60)
+	seconds := ((value-degrees)*60 - minutes) * 60
+
+	return [][2]uint32{
+		{uint32(degrees), 1},
+		{uint32(minutes), 1},
+		{uint32(math.Round(seconds * 1000)), 1000},
+	}
+}
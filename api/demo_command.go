@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/photoview/photoview/api/demo"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/scanner_queue"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// runDemoCommand generates a sample library and its users, then scans it.
+// The same seed always generates the same library, so it can be used to reproduce bugs.
+//
+//	photoview demo seed -dir ./demo_library -seed 42 -users 2
+func runDemoCommand(db *gorm.DB, args []string) error {
+	if _, err := expectSubcommand(args, "seed"); err != nil {
+		return err
+	}
+
+	options := demo.DefaultOptions(utils.DemoPath())
+
+	flags := flag.NewFlagSet("demo seed", flag.ContinueOnError)
+	flags.StringVar(&options.Path, "dir", options.Path, "directory the sample library is written to")
+	flags.Int64Var(&options.Seed, "seed", options.Seed, "seed of the generator")
+	flags.IntVar(&options.Users, "users", options.Users, "number of users")
+	flags.IntVar(&options.Albums, "albums", options.Albums, "number of albums of every user")
+	flags.IntVar(&options.Photos, "photos", options.Photos, "number of photos in every album")
+	noScan := flags.Bool("no-scan", false, "don't scan the library once it has been generated")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if _, err := seedDemo(db, options); err != nil {
+		return err
+	}
+
+	if *noScan {
+		return nil
+	}
+
+	return runScanCommand(db, nil)
+}
+
+// seedDemoOnStartup generates the sample library of the demo mode, and queues it to be scanned,
+// when the demo mode is enabled and no users have been created yet
+func seedDemoOnStartup(ctx context.Context, db *gorm.DB) error {
+	if !utils.EnvDemoMode.GetBool() {
+		return nil
+	}
+
+	var userCount int64
+	if err := db.Model(&models.User{}).Count(&userCount).Error; err != nil {
+		return errors.Wrap(err, "count users")
+	}
+
+	if userCount > 0 {
+		log.Info(ctx, "Demo mode enabled, skipping sample library as users already exist")
+		return nil
+	}
+
+	if _, err := seedDemo(db, demo.DefaultOptions(utils.DemoPath())); err != nil {
+		return err
+	}
+
+	return scanner_queue.AddAllToQueue(ctx)
+}
+
+func seedDemo(db *gorm.DB, options demo.Options) (*demo.Result, error) {
+	result, err := demo.Seed(db, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "seed sample library")
+	}
+
+	usernames := make([]string, len(result.Users))
+	for i, user := range result.Users {
+		usernames[i] = user.Username
+	}
+
+	log.Info(db.Statement.Context, "Sample library generated", "path", options.Path, "albums", result.Albums,
+		"new_photos", result.Photos, "users", usernames, "password", demo.Password)
+	return result, nil
+}
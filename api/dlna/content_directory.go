@@ -0,0 +1,426 @@
+package dlna
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	contentDirectoryType  = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	connectionManagerType = "urn:schemas-upnp-org:service:ConnectionManager:1"
+)
+
+// DLNA flags of the served files, allowing seeking by byte range and both streaming and interactive transfers
+const dlnaContentFeatures = "DLNA.ORG_OP=01;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=01700000000000000000000000000000"
+
+// Content types that media players can be expected to play without a transcoded rendition
+var playableContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"video/mp4":  true,
+}
+
+const rootObjectID = "0"
+
+// didlObject is a container (album) or an item (media) of the content directory, or the root container if neither is set
+type didlObject struct {
+	album *models.Album
+	media *models.Media
+	// parentID is the object id of the parent container
+	parentID string
+}
+
+func (o *didlObject) id() string {
+	if o.media != nil {
+		return "media-" + strconv.Itoa(o.media.ID)
+	}
+	if o.album != nil {
+		return "album-" + strconv.Itoa(o.album.ID)
+	}
+	return rootObjectID
+}
+
+func (s *Server) handleContentDirectory(w http.ResponseWriter, r *http.Request) {
+	action, err := parseSOAPAction(r)
+	if err != nil {
+		writeSOAPError(w, upnpErrorInvalidArgs, "invalid soap request")
+		return
+	}
+
+	switch action.name {
+	case "Browse":
+		s.browse(w, r, action)
+	case "GetSystemUpdateID":
+		writeSOAPResponse(w, contentDirectoryType, action.name, []soapArgument{
+			{"Id", strconv.FormatUint(uint64(s.systemUpdateID), 10)},
+		})
+	case "GetSearchCapabilities":
+		writeSOAPResponse(w, contentDirectoryType, action.name, []soapArgument{{"SearchCaps", ""}})
+	case "GetSortCapabilities":
+		writeSOAPResponse(w, contentDirectoryType, action.name, []soapArgument{{"SortCaps", ""}})
+	default:
+		writeSOAPError(w, upnpErrorInvalidAction, "invalid action")
+	}
+}
+
+func (s *Server) handleConnectionManager(w http.ResponseWriter, r *http.Request) {
+	action, err := parseSOAPAction(r)
+	if err != nil {
+		writeSOAPError(w, upnpErrorInvalidArgs, "invalid soap request")
+		return
+	}
+
+	switch action.name {
+	case "GetProtocolInfo":
+		protocols := make([]string, 0)
+		for _, contentType := range []string{"image/jpeg", "image/png", "image/webp", "image/gif", "image/heif", "image/heic", "video/mp4", "video/quicktime", "video/webm"} {
+			protocols = append(protocols, protocolInfo(contentType))
+		}
+		writeSOAPResponse(w, connectionManagerType, action.name, []soapArgument{
+			{"Source", strings.Join(protocols, ",")},
+			{"Sink", ""},
+		})
+	case "GetCurrentConnectionIDs":
+		writeSOAPResponse(w, connectionManagerType, action.name, []soapArgument{{"ConnectionIDs", "0"}})
+	case "GetCurrentConnectionInfo":
+		writeSOAPResponse(w, connectionManagerType, action.name, []soapArgument{
+			{"RcsID", "-1"},
+			{"AVTransportID", "-1"},
+			{"ProtocolInfo", ""},
+			{"PeerConnectionManager", ""},
+			{"PeerConnectionID", "-1"},
+			{"Direction", "Output"},
+			{"Status", "OK"},
+		})
+	default:
+		writeSOAPError(w, upnpErrorInvalidAction, "invalid action")
+	}
+}
+
+func (s *Server) browse(w http.ResponseWriter, r *http.Request, action *soapAction) {
+	objectID := action.arguments["ObjectID"]
+	startingIndex, _ := strconv.Atoi(action.arguments["StartingIndex"])
+	requestedCount, _ := strconv.Atoi(action.arguments["RequestedCount"])
+
+	var objects []*didlObject
+	var totalMatches int
+	var err error
+
+	switch action.arguments["BrowseFlag"] {
+	case "BrowseMetadata":
+		var object *didlObject
+		if object, err = s.lookupObject(objectID); err == nil {
+			objects = []*didlObject{object}
+			totalMatches = 1
+		}
+	case "BrowseDirectChildren":
+		objects, err = s.children(objectID)
+		totalMatches = len(objects)
+
+		if startingIndex > len(objects) {
+			startingIndex = len(objects)
+		}
+		objects = objects[startingIndex:]
+		if requestedCount > 0 && requestedCount < len(objects) {
+			objects = objects[:requestedCount]
+		}
+	default:
+		writeSOAPError(w, upnpErrorInvalidArgs, "invalid browse flag")
+		return
+	}
+
+	if err == errNoSuchObject {
+		writeSOAPError(w, upnpErrorNoSuchObject, "no such object")
+		return
+	}
+	if err != nil {
+		log.Error(r.Context(), "Browsing dlna object", "object_id", objectID, "error", err)
+		writeSOAPError(w, upnpErrorActionFailed, "action failed")
+		return
+	}
+
+	result, err := s.didl(r, objects)
+	if err != nil {
+		log.Error(r.Context(), "Describing dlna objects", "error", err)
+		writeSOAPError(w, upnpErrorActionFailed, "action failed")
+		return
+	}
+
+	writeSOAPResponse(w, contentDirectoryType, action.name, []soapArgument{
+		{"Result", result},
+		{"NumberReturned", strconv.Itoa(len(objects))},
+		{"TotalMatches", strconv.Itoa(totalMatches)},
+		{"UpdateID", strconv.FormatUint(uint64(s.systemUpdateID), 10)},
+	})
+}
+
+var errNoSuchObject = errors.New("no such object")
+
+// lookupObject finds the object with the given id, if it is shared over DLNA
+func (s *Server) lookupObject(objectID string) (*didlObject, error) {
+	if objectID == rootObjectID {
+		return &didlObject{parentID: "-1"}, nil
+	}
+
+	kind, idString, found := strings.Cut(objectID, "-")
+	id, err := strconv.Atoi(idString)
+	if !found || err != nil {
+		return nil, errNoSuchObject
+	}
+
+	object := &didlObject{}
+	var album models.Album
+
+	switch kind {
+	case "album":
+		if err := s.db.First(&album, id).Error; err != nil {
+			return nil, errNoSuchObject
+		}
+	case "media":
+		var media models.Media
+		if err := s.db.First(&media, id).Error; err != nil {
+			return nil, errNoSuchObject
+		}
+		if err := s.db.First(&album, media.AlbumID).Error; err != nil {
+			return nil, errNoSuchObject
+		}
+		object.media = &media
+		object.parentID = "album-" + strconv.Itoa(album.ID)
+	default:
+		return nil, errNoSuchObject
+	}
+
+	shared, err := album.DLNAShared(s.db)
+	if err != nil {
+		return nil, err
+	}
+	if !shared {
+		return nil, errNoSuchObject
+	}
+
+	object.album = &album
+
+	if object.media == nil {
+		if object.parentID, err = s.albumParentID(&album); err != nil {
+			return nil, err
+		}
+	}
+
+	return object, nil
+}
+
+// children returns the sub albums and media of a container
+func (s *Server) children(objectID string) ([]*didlObject, error) {
+	if objectID == rootObjectID {
+		return s.rootAlbums()
+	}
+
+	container, err := s.lookupObject(objectID)
+	if err != nil {
+		return nil, err
+	}
+	if container.album == nil || container.media != nil {
+		return nil, errNoSuchObject
+	}
+
+	var subAlbums []*models.Album
+	if err := s.db.Where("parent_album_id = ?", container.album.ID).Order("title").Find(&subAlbums).Error; err != nil {
+		return nil, errors.Wrap(err, "get sub albums")
+	}
+
+	var media []*models.Media
+	if err := s.db.Where("album_id = ?", container.album.ID).Order("date_shot, title").Find(&media).Error; err != nil {
+		return nil, errors.Wrap(err, "get album media")
+	}
+
+	objects := make([]*didlObject, 0, len(subAlbums)+len(media))
+	for _, album := range subAlbums {
+		objects = append(objects, &didlObject{album: album, parentID: objectID})
+	}
+	for _, m := range media {
+		objects = append(objects, &didlObject{album: container.album, media: m, parentID: objectID})
+	}
+
+	return objects, nil
+}
+
+// rootAlbums returns the albums shared over DLNA, that are not inside another shared album
+func (s *Server) rootAlbums() ([]*didlObject, error) {
+	var sharedAlbums []*models.Album
+	if err := s.db.Where("dlna_enabled = ?", true).Order("title").Find(&sharedAlbums).Error; err != nil {
+		return nil, errors.Wrap(err, "get albums shared over dlna")
+	}
+
+	objects := make([]*didlObject, 0, len(sharedAlbums))
+	for _, album := range sharedAlbums {
+		parentID, err := s.albumParentID(album)
+		if err != nil {
+			return nil, err
+		}
+
+		if parentID == rootObjectID {
+			objects = append(objects, &didlObject{album: album, parentID: rootObjectID})
+		}
+	}
+
+	return objects, nil
+}
+
+// albumParentID returns the object id of the container of a shared album.
+// Albums are placed at the root, unless their parent album is shared as well.
+func (s *Server) albumParentID(album *models.Album) (string, error) {
+	if album.ParentAlbumID == nil {
+		return rootObjectID, nil
+	}
+
+	var parent models.Album
+	if err := s.db.First(&parent, *album.ParentAlbumID).Error; err != nil {
+		return rootObjectID, nil
+	}
+
+	parentShared, err := parent.DLNAShared(s.db)
+	if err != nil {
+		return "", err
+	}
+
+	if !parentShared {
+		return rootObjectID, nil
+	}
+
+	return "album-" + strconv.Itoa(parent.ID), nil
+}
+
+// didl describes the objects as a DIDL-Lite document
+func (s *Server) didl(r *http.Request, objects []*didlObject) (string, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" ` +
+		`xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/" xmlns:dlna="urn:schemas-dlna-org:metadata-1-0/">`)
+
+	for _, object := range objects {
+		if object.album == nil && object.media == nil {
+			rootAlbums, err := s.rootAlbums()
+			if err != nil {
+				return "", err
+			}
+
+			fmt.Fprintf(&buf, `<container id="%s" parentID="%s" restricted="1" childCount="%d"><dc:title>%s</dc:title><upnp:class>object.container.storageFolder</upnp:class></container>`,
+				object.id(), object.parentID, len(rootAlbums), xmlEscape(s.friendlyName))
+			continue
+		}
+
+		if object.media == nil {
+			var childCount int64
+			if err := s.db.Model(&models.Album{}).Where("parent_album_id = ?", object.album.ID).Count(&childCount).Error; err != nil {
+				return "", errors.Wrap(err, "count sub albums")
+			}
+
+			var mediaCount int64
+			if err := s.db.Model(&models.Media{}).Where("album_id = ?", object.album.ID).Count(&mediaCount).Error; err != nil {
+				return "", errors.Wrap(err, "count album media")
+			}
+
+			fmt.Fprintf(&buf, `<container id="%s" parentID="%s" restricted="1" childCount="%d"><dc:title>%s</dc:title><upnp:class>object.container.storageFolder</upnp:class></container>`,
+				object.id(), object.parentID, childCount+mediaCount, xmlEscape(object.album.Title))
+			continue
+		}
+
+		item, err := s.didlItem(r, object)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(item)
+	}
+
+	buf.WriteString(`</DIDL-Lite>`)
+	return buf.String(), nil
+}
+
+func (s *Server) didlItem(r *http.Request, object *didlObject) (string, error) {
+	media := object.media
+
+	var mediaURLs []*models.MediaURL
+	if err := s.db.Where("media_id = ?", media.ID).Find(&mediaURLs).Error; err != nil {
+		return "", errors.Wrap(err, "get media urls")
+	}
+
+	inColdStorage, err := object.album.InColdStorage(s.db)
+	if err != nil {
+		return "", err
+	}
+
+	class := "object.item.imageItem.photo"
+	if media.Type == models.MediaTypeVideo {
+		class = "object.item.videoItem"
+	}
+
+	// Renditions the media players are expected to play are listed first, as most players pick the first one
+	playable := make([]string, 0)
+	other := make([]string, 0)
+	albumArt := ""
+
+	for _, mediaURL := range mediaURLs {
+		fileURL := s.mediaURL(r, mediaURL)
+
+		switch mediaURL.Purpose {
+		case models.PhotoThumbnail, models.VideoThumbnail:
+			albumArt = fileURL
+			continue
+		case models.MediaOriginal:
+			if inColdStorage {
+				continue
+			}
+		}
+
+		res := fmt.Sprintf(`<res protocolInfo="%s" size="%d"`, protocolInfo(mediaURL.ContentType), mediaURL.FileSize)
+		if mediaURL.Width > 0 && mediaURL.Height > 0 {
+			res += fmt.Sprintf(` resolution="%dx%d"`, mediaURL.Width, mediaURL.Height)
+		}
+		res += ">" + xmlEscape(fileURL) + "</res>"
+
+		if playableContentTypes[mediaURL.ContentType] {
+			playable = append(playable, res)
+		} else {
+			other = append(other, res)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, `<item id="%s" parentID="%s" restricted="1"><dc:title>%s</dc:title><upnp:class>%s</upnp:class>`,
+		object.id(), object.parentID, xmlEscape(media.Title), class)
+
+	if !media.DateShot.IsZero() {
+		fmt.Fprintf(&buf, `<dc:date>%s</dc:date>`, media.DateShot.Format("2006-01-02T15:04:05"))
+	}
+
+	if albumArt != "" {
+		fmt.Fprintf(&buf, `<upnp:albumArtURI>%s</upnp:albumArtURI>`, xmlEscape(albumArt))
+	}
+
+	buf.WriteString(strings.Join(append(playable, other...), ""))
+	buf.WriteString(`</item>`)
+
+	return buf.String(), nil
+}
+
+// mediaURL is the absolute url of a file of a media, using the address the media player connected to
+func (s *Server) mediaURL(r *http.Request, mediaURL *models.MediaURL) string {
+	fileURL := url.URL{
+		Scheme: "http",
+		Host:   r.Host,
+		Path:   path.Join(s.basePath, "media", mediaURL.MediaName),
+	}
+	return fileURL.String()
+}
+
+func protocolInfo(contentType string) string {
+	return fmt.Sprintf("http-get:*:%s:%s", contentType, dlnaContentFeatures)
+}
@@ -0,0 +1,215 @@
+package dlna
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/utils"
+	"gorm.io/gorm"
+)
+
+// Server is a UPnP media server, that lets smart TVs and other media players on the local network
+// browse the albums that have been shared over DLNA
+type Server struct {
+	db           *gorm.DB
+	uuid         string
+	friendlyName string
+	// basePath is the path the http routes of the server are registered at
+	basePath string
+	// port is the port of the http server
+	port string
+	// systemUpdateID changes on every start, so media players refresh what they have cached
+	systemUpdateID uint32
+}
+
+// NewServer creates a media server, with its http routes served at basePath on the given port
+func NewServer(db *gorm.DB, basePath string, port string) *Server {
+	friendlyName := utils.EnvDLNAFriendlyName.GetValue()
+	if friendlyName == "" {
+		friendlyName = "Photoview"
+	}
+
+	return &Server{
+		db:             db,
+		uuid:           deviceUUID(),
+		friendlyName:   friendlyName,
+		basePath:       basePath,
+		port:           port,
+		systemUpdateID: uint32(time.Now().Unix()),
+	}
+}
+
+// InitializeDLNA registers the http routes of the media server and starts announcing it on the local network
+func InitializeDLNA(db *gorm.DB, router *mux.Router, basePath string, port string) error {
+	if strings.TrimSpace(utils.EnvDLNAAllowedNetworks.GetValue()) == "" {
+		log.Warn(context.Background(), "No networks are allowed to access the DLNA server, media players will be refused", "name", utils.EnvDLNAAllowedNetworks.GetName())
+	}
+
+	server := NewServer(db, basePath, port)
+	server.RegisterRoutes(router)
+	return server.StartDiscovery()
+}
+
+// RegisterRoutes registers the device description, the UPnP services and the media files of the server.
+// Media players can not log in, so the routes are only accessible from the networks allowed to access the server.
+func (s *Server) RegisterRoutes(router *mux.Router) {
+	router.Use(allowedNetworksOnly)
+
+	router.HandleFunc("/device.xml", s.handleDeviceDescription).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc("/ContentDirectory.xml", serveXML(contentDirectorySCPD)).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc("/ConnectionManager.xml", serveXML(connectionManagerSCPD)).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc("/control/ContentDirectory", s.handleContentDirectory).Methods(http.MethodPost)
+	router.HandleFunc("/control/ConnectionManager", s.handleConnectionManager).Methods(http.MethodPost)
+	router.HandleFunc("/events/{service}", handleEventSubscription).Methods("SUBSCRIBE", "UNSUBSCRIBE")
+	router.HandleFunc("/media/{name}", s.handleMedia).Methods(http.MethodGet, http.MethodHead)
+}
+
+// Headers set by reverse proxies, which forward requests from anywhere from a local address
+var forwardedHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"}
+
+// allowedNetworksOnly rejects requests from outside of the networks allowed to access the server.
+// Private addresses are not enough, as requests from anywhere can arrive from one, such as the bridge of a container.
+// Requests forwarded by a reverse proxy are rejected, as their address is the one of the proxy.
+func allowedNetworksOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, header := range forwardedHeaders {
+			if r.Header.Get(header) != "" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("forbidden"))
+				return
+			}
+		}
+
+		if !utils.IsDLNAClient(r.RemoteAddr) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("forbidden"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func serveXML(document string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+		w.Write([]byte(document))
+	}
+}
+
+func (s *Server) handleDeviceDescription(w http.ResponseWriter, r *http.Request) {
+	serveXML(fmt.Sprintf(deviceDescription, xmlEscape(s.friendlyName), s.uuid, s.basePath))(w, r)
+}
+
+// handleEventSubscription accepts subscriptions to service events, as some media players refuse to browse without.
+// The content of the server is not evented, so no events are ever sent.
+func handleEventSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "SUBSCRIBE" {
+		sid := r.Header.Get("SID")
+		if sid == "" {
+			sid = "uuid:" + formatUUID(md5.Sum([]byte(utils.GenerateToken())))
+		}
+		w.Header().Set("SID", sid)
+		w.Header().Set("TIMEOUT", "Second-1800")
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleMedia serves a file of a media in an album shared over DLNA
+func (s *Server) handleMedia(w http.ResponseWriter, r *http.Request) {
+	var mediaURL models.MediaURL
+	err := s.db.Joins("Media").Where("media_urls.media_name = ?", mux.Vars(r)["name"]).First(&mediaURL).Error
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404"))
+		return
+	}
+
+	media := mediaURL.Media
+
+	var album models.Album
+	if err := s.db.First(&album, media.AlbumID).Error; err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404"))
+		return
+	}
+
+	shared, err := album.DLNAShared(s.db)
+	if err != nil {
+		log.Error(r.Context(), "Checking if album is shared over dlna", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	if !shared {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404"))
+		return
+	}
+
+	if mediaURL.Purpose == models.MediaOriginal {
+		inColdStorage, err := album.InColdStorage(s.db)
+		if err != nil {
+			log.Error(r.Context(), "Serving dlna media", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		// Media players can not wait for originals to be retrieved, the previews are served instead
+		if inColdStorage {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("media is located in cold storage"))
+			return
+		}
+	}
+
+	cachedPath, err := mediaURL.CachedPath()
+	if err != nil {
+		log.Error(r.Context(), "Serving dlna media", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	if _, err := os.Stat(cachedPath); os.IsNotExist(err) && mediaURL.Purpose != models.MediaOriginal {
+		if err := scanner.ProcessSingleMedia(s.db, media); err != nil {
+			log.Error(r.Context(), "Processing media for dlna", "path", media.Path, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+	}
+
+	transferMode := "Interactive"
+	if media.Type == models.MediaTypeVideo {
+		transferMode = "Streaming"
+	}
+
+	w.Header().Set("Content-Type", mediaURL.ContentType)
+	w.Header().Set("transferMode.dlna.org", transferMode)
+	w.Header().Set("contentFeatures.dlna.org", dlnaContentFeatures)
+
+	http.ServeFile(w, r, cachedPath)
+}
+
+// deviceUUID derives the unique device name from the host name, so it stays the same across restarts
+func deviceUUID() string {
+	hostname, _ := os.Hostname()
+	return formatUUID(md5.Sum([]byte("photoview-dlna-" + hostname)))
+}
+
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
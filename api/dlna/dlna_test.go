@@ -0,0 +1,168 @@
+package dlna
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func browseRequest(objectID string, browseFlag string) *http.Request {
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>` +
+		`<u:Browse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1">` +
+		`<ObjectID>` + objectID + `</ObjectID><BrowseFlag>` + browseFlag + `</BrowseFlag>` +
+		`<Filter>*</Filter><StartingIndex>0</StartingIndex><RequestedCount>0</RequestedCount><SortCriteria></SortCriteria>` +
+		`</u:Browse></s:Body></s:Envelope>`
+
+	req := httptest.NewRequest(http.MethodPost, "/dlna/control/ContentDirectory", strings.NewReader(body))
+	req.Header.Set("SOAPACTION", `"urn:schemas-upnp-org:service:ContentDirectory:1#Browse"`)
+	req.RemoteAddr = "192.168.1.20:50000"
+	return req
+}
+
+func TestContentDirectory(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	sharedAlbum := models.Album{Title: "Holiday", Path: "/photos/holiday", DLNAEnabled: true}
+	privateAlbum := models.Album{Title: "Private", Path: "/photos/private"}
+	if !assert.NoError(t, db.Create(&[]*models.Album{&sharedAlbum, &privateAlbum}).Error) {
+		return
+	}
+
+	subAlbum := models.Album{Title: "Beach", Path: "/photos/holiday/beach", ParentAlbumID: &sharedAlbum.ID}
+	if !assert.NoError(t, db.Create(&subAlbum).Error) {
+		return
+	}
+
+	media := models.Media{Title: "sunset.jpg", Path: "/photos/holiday/beach/sunset.jpg", AlbumID: subAlbum.ID}
+	if !assert.NoError(t, db.Create(&media).Error) {
+		return
+	}
+
+	mediaURL := models.MediaURL{
+		MediaID:     media.ID,
+		MediaName:   "sunset_highres.jpg",
+		Purpose:     models.PhotoHighRes,
+		ContentType: "image/jpeg",
+		Width:       1024,
+		Height:      768,
+	}
+	if !assert.NoError(t, db.Create(&mediaURL).Error) {
+		return
+	}
+
+	t.Setenv(utils.EnvDLNAAllowedNetworks.GetName(), "192.168.1.0/24, 127.0.0.1")
+
+	router := mux.NewRouter()
+	NewServer(db, "/dlna", "4001").RegisterRoutes(router.PathPrefix("/dlna").Subrouter())
+
+	browse := func(objectID string, browseFlag string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, browseRequest(objectID, browseFlag))
+		return rr
+	}
+
+	t.Run("Browse root", func(t *testing.T) {
+		rr := browse(rootObjectID, "BrowseDirectChildren")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "Holiday")
+		assert.NotContains(t, rr.Body.String(), "Private")
+		assert.Contains(t, rr.Body.String(), "<TotalMatches>1</TotalMatches>")
+	})
+
+	t.Run("Browse sub album", func(t *testing.T) {
+		rr := browse("album-"+strconv.Itoa(subAlbum.ID), "BrowseDirectChildren")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "sunset.jpg")
+		assert.Contains(t, rr.Body.String(), "/dlna/media/sunset_highres.jpg")
+		assert.Contains(t, rr.Body.String(), `resolution=&#34;1024x768&#34;`)
+	})
+
+	t.Run("Browse metadata of sub album", func(t *testing.T) {
+		rr := browse("album-"+strconv.Itoa(subAlbum.ID), "BrowseMetadata")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "parentID=&#34;album-"+strconv.Itoa(sharedAlbum.ID)+"&#34;")
+	})
+
+	t.Run("Browse album not shared", func(t *testing.T) {
+		rr := browse("album-"+strconv.Itoa(privateAlbum.ID), "BrowseDirectChildren")
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Contains(t, rr.Body.String(), "<errorCode>701</errorCode>")
+	})
+
+	t.Run("Media of album not shared", func(t *testing.T) {
+		privateMedia := models.Media{Title: "private.jpg", Path: "/photos/private/private.jpg", AlbumID: privateAlbum.ID}
+		if !assert.NoError(t, db.Create(&privateMedia).Error) {
+			return
+		}
+
+		privateURL := models.MediaURL{MediaID: privateMedia.ID, MediaName: "private.jpg", Purpose: models.PhotoHighRes}
+		if !assert.NoError(t, db.Create(&privateURL).Error) {
+			return
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/dlna/media/private.jpg", nil)
+		req.RemoteAddr = "192.168.1.20:50000"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Request from outside the local network", func(t *testing.T) {
+		req := browseRequest(rootObjectID, "BrowseDirectChildren")
+		req.RemoteAddr = "8.8.8.8:50000"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("Request from a private network that is not allowed", func(t *testing.T) {
+		// Requests from anywhere arrive from the bridge when the server runs in a container
+		req := browseRequest(rootObjectID, "BrowseDirectChildren")
+		req.RemoteAddr = "172.17.0.1:50000"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("No networks allowed", func(t *testing.T) {
+		t.Setenv(utils.EnvDLNAAllowedNetworks.GetName(), "")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, browseRequest(rootObjectID, "BrowseDirectChildren"))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("Request forwarded by a reverse proxy", func(t *testing.T) {
+		for _, header := range []string{"X-Forwarded-For", "Forwarded", "X-Real-IP"} {
+			req := httptest.NewRequest(http.MethodGet, "/dlna/media/private.jpg", nil)
+			req.RemoteAddr = "127.0.0.1:50000"
+			req.Header.Set(header, "8.8.8.8")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusForbidden, rr.Code, header)
+		}
+	})
+
+	t.Run("Device description", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/dlna/device.xml", nil)
+		req.RemoteAddr = "127.0.0.1:50000"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "<controlURL>/dlna/control/ContentDirectory</controlURL>")
+	})
+}
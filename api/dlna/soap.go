@@ -0,0 +1,100 @@
+package dlna
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// soapAction is the action of a SOAP request to a UPnP service, along with its arguments
+type soapAction struct {
+	name      string
+	arguments map[string]string
+}
+
+// soapArgument is an output argument of an action, the order of the arguments is significant
+type soapArgument struct {
+	name  string
+	value string
+}
+
+// UPnP error codes
+const (
+	upnpErrorInvalidAction = 401
+	upnpErrorInvalidArgs   = 402
+	upnpErrorActionFailed  = 501
+	upnpErrorNoSuchObject  = 701
+)
+
+type soapEnvelope struct {
+	Body struct {
+		Action struct {
+			XMLName   xml.Name
+			Arguments []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:",any"`
+	} `xml:"Body"`
+}
+
+// parseSOAPAction reads the action and its arguments from the body of a control request
+func parseSOAPAction(r *http.Request) (*soapAction, error) {
+	var envelope soapEnvelope
+	if err := xml.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&envelope); err != nil {
+		return nil, err
+	}
+
+	action := &soapAction{
+		name:      envelope.Body.Action.XMLName.Local,
+		arguments: make(map[string]string),
+	}
+
+	// The action name is also found in the SOAPACTION header, "urn:schemas-upnp-org:service:ContentDirectory:1#Browse"
+	if action.name == "" {
+		header := strings.Trim(r.Header.Get("SOAPACTION"), `"`)
+		if index := strings.LastIndex(header, "#"); index >= 0 {
+			action.name = header[index+1:]
+		}
+	}
+
+	for _, argument := range envelope.Body.Action.Arguments {
+		action.arguments[argument.XMLName.Local] = argument.Value
+	}
+
+	return action, nil
+}
+
+func writeSOAPResponse(w http.ResponseWriter, serviceType string, action string, arguments []soapArgument) {
+	body := bytes.Buffer{}
+	for _, argument := range arguments {
+		fmt.Fprintf(&body, "<%s>%s</%s>", argument.name, xmlEscape(argument.value), argument.name)
+	}
+
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("EXT", "")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">`+
+		`<s:Body><u:%sResponse xmlns:u="%s">%s</u:%sResponse></s:Body></s:Envelope>`,
+		action, serviceType, body.String(), action)
+}
+
+func writeSOAPError(w http.ResponseWriter, code int, description string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">`+
+		`<s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`+
+		`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError>`+
+		`</detail></s:Fault></s:Body></s:Envelope>`,
+		code, xmlEscape(description))
+}
+
+func xmlEscape(value string) string {
+	buf := bytes.Buffer{}
+	xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
@@ -0,0 +1,193 @@
+package dlna
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/photoview/photoview/api/log"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ssdpAddress = "239.255.255.250:1900"
+	ssdpMaxAge  = 1800
+	// ssdpNotifyInterval is how often the server is announced, well within the max age of the announcements
+	ssdpNotifyInterval = 15 * time.Minute
+	mediaServerDevice  = "urn:schemas-upnp-org:device:MediaServer:1"
+	ssdpServerHeader   = "Linux UPnP/1.0 Photoview/1.0"
+)
+
+// StartDiscovery answers searches from media players on the local network, and periodically announces the server
+func (s *Server) StartDiscovery() error {
+	groupAddr, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return errors.Wrap(err, "resolve ssdp address")
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, groupAddr)
+	if err != nil {
+		return errors.Wrap(err, "listen for ssdp searches")
+	}
+
+	go s.answerSearches(conn)
+	go s.announce(groupAddr)
+
+	log.Info(context.Background(), "DLNA media server announced on the local network", "name", s.friendlyName)
+	return nil
+}
+
+// notificationTypes are the device and services the server announces itself as
+func (s *Server) notificationTypes() []string {
+	return []string{
+		"upnp:rootdevice",
+		"uuid:" + s.uuid,
+		mediaServerDevice,
+		contentDirectoryType,
+		connectionManagerType,
+	}
+}
+
+// uniqueServiceName identifies the server as the given notification type
+func (s *Server) uniqueServiceName(notificationType string) string {
+	if notificationType == "uuid:"+s.uuid {
+		return notificationType
+	}
+	return "uuid:" + s.uuid + "::" + notificationType
+}
+
+// location is the url of the device description, as reachable from the given local address
+func (s *Server) location(localIP net.IP) string {
+	return fmt.Sprintf("http://%s%s/device.xml", net.JoinHostPort(localIP.String(), s.port), s.basePath)
+}
+
+func (s *Server) answerSearches(conn *net.UDPConn) {
+	buf := make([]byte, 2048)
+	for {
+		n, remoteAddr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			log.Error(context.Background(), "Reading ssdp message", "error", err)
+			return
+		}
+
+		request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buf[:n])))
+		if err != nil || request.Method != "M-SEARCH" || request.Header.Get("MAN") != `"ssdp:discover"` {
+			continue
+		}
+
+		searchTarget := request.Header.Get("ST")
+		var targets []string
+		for _, notificationType := range s.notificationTypes() {
+			if searchTarget == "ssdp:all" || searchTarget == notificationType {
+				targets = append(targets, notificationType)
+			}
+		}
+
+		if len(targets) > 0 {
+			go s.respondSearch(remoteAddr, targets)
+		}
+	}
+}
+
+// respondSearch sends a unicast response for each of the matching targets of a search
+func (s *Server) respondSearch(remoteAddr *net.UDPAddr, targets []string) {
+	conn, err := net.DialUDP("udp4", nil, remoteAddr)
+	if err != nil {
+		log.Warn(context.Background(), "Responding to ssdp search", "remote_addr", remoteAddr.String(), "error", err)
+		return
+	}
+	defer conn.Close()
+
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	for _, target := range targets {
+		response := strings.Join([]string{
+			"HTTP/1.1 200 OK",
+			fmt.Sprintf("CACHE-CONTROL: max-age=%d", ssdpMaxAge),
+			"DATE: " + time.Now().UTC().Format(http.TimeFormat),
+			"EXT:",
+			"LOCATION: " + s.location(localIP),
+			"SERVER: " + ssdpServerHeader,
+			"ST: " + target,
+			"USN: " + s.uniqueServiceName(target),
+			"", "",
+		}, "\r\n")
+
+		if _, err := conn.Write([]byte(response)); err != nil {
+			log.Warn(context.Background(), "Responding to ssdp search", "remote_addr", remoteAddr.String(), "error", err)
+			return
+		}
+	}
+}
+
+// announce sends alive notifications on every network interface, until the process exits
+func (s *Server) announce(groupAddr *net.UDPAddr) {
+	for {
+		for _, localIP := range localIPv4Addresses() {
+			s.notifyAlive(groupAddr, localIP)
+		}
+		time.Sleep(ssdpNotifyInterval)
+	}
+}
+
+func (s *Server) notifyAlive(groupAddr *net.UDPAddr, localIP net.IP) {
+	conn, err := net.DialUDP("udp4", &net.UDPAddr{IP: localIP}, groupAddr)
+	if err != nil {
+		log.Warn(context.Background(), "Announcing dlna server", "ip", localIP.String(), "error", err)
+		return
+	}
+	defer conn.Close()
+
+	for _, notificationType := range s.notificationTypes() {
+		notification := strings.Join([]string{
+			"NOTIFY * HTTP/1.1",
+			"HOST: " + ssdpAddress,
+			fmt.Sprintf("CACHE-CONTROL: max-age=%d", ssdpMaxAge),
+			"LOCATION: " + s.location(localIP),
+			"NT: " + notificationType,
+			"NTS: ssdp:alive",
+			"SERVER: " + ssdpServerHeader,
+			"USN: " + s.uniqueServiceName(notificationType),
+			"", "",
+		}, "\r\n")
+
+		if _, err := conn.Write([]byte(notification)); err != nil {
+			log.Warn(context.Background(), "Announcing dlna server", "ip", localIP.String(), "error", err)
+			return
+		}
+	}
+}
+
+// localIPv4Addresses returns the addresses of the network interfaces that are up and support multicast
+func localIPv4Addresses() []net.IP {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		log.Warn(context.Background(), "Listing network interfaces", "error", err)
+		return nil
+	}
+
+	var addresses []net.IP
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				addresses = append(addresses, ipNet.IP.To4())
+			}
+		}
+	}
+
+	return addresses
+}
@@ -0,0 +1,136 @@
+// Package features gates heavy or experimental parts of the server behind feature flags.
+// Flags are backed by environment variables, so they can be changed at runtime as settings,
+// and are exposed through the api so the web interface can hide what is disabled.
+package features
+
+import (
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+// flag describes a feature and the environment variable that enables or disables it
+type flag struct {
+	feature     models.Feature
+	description string
+	variable    utils.EnvironmentVariable
+	// disables is whether the variable disables the feature when set, rather than enabling it
+	disables bool
+	// experimental features are disabled unless enabled explicitly
+	experimental bool
+	// restartRequired is whether changes only take effect after the server has been restarted
+	restartRequired bool
+}
+
+var flags = []*flag{
+	{
+		feature:     models.FeatureFaceRecognition,
+		description: "Detect and group faces in photos",
+		variable:    utils.EnvDisableFaceRecognition,
+		disables:    true,
+	},
+	{
+		feature:     models.FeatureVideoTranscoding,
+		description: "Encode videos with ffmpeg to formats supported by browsers, and generate video thumbnails",
+		variable:    utils.EnvDisableVideoEncoding,
+		disables:    true,
+	},
+	{
+		feature:     models.FeatureRawProcessing,
+		description: "Process raw photos with darktable",
+		variable:    utils.EnvDisableRawProcessing,
+		disables:    true,
+	},
+	{
+		feature:         models.FeatureDlna,
+		description:     "Serve media to DLNA players on the local network",
+		variable:        utils.EnvEnableDLNA,
+		experimental:    true,
+		restartRequired: true,
+	},
+	{
+		feature:      models.FeatureGpuThumbnails,
+		description:  "Scale photos down to thumbnails on the graphics card set by the video hardware acceleration",
+		variable:     utils.EnvEnableGPUThumbnails,
+		experimental: true,
+	},
+}
+
+func findFlag(feature models.Feature) *flag {
+	for _, f := range flags {
+		if f.feature == feature {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// Enabled returns whether a feature is enabled
+func Enabled(feature models.Feature) bool {
+	f := findFlag(feature)
+	if f == nil {
+		return false
+	}
+
+	return f.enabled()
+}
+
+func (f *flag) enabled() bool {
+	if f.variable.GetValue() == "" {
+		return !f.experimental
+	}
+
+	return f.variable.GetBool() != f.disables
+}
+
+// Setting returns the key and value of the setting that enables or disables a feature,
+// a nil value resets the feature to the value of the environment
+func Setting(feature models.Feature, enabled *bool) (string, *string, error) {
+	f := findFlag(feature)
+	if f == nil {
+		return "", nil, errors.Errorf("unknown feature: %s", feature)
+	}
+
+	if enabled == nil {
+		return f.variable.GetName(), nil, nil
+	}
+
+	value := "0"
+	if *enabled != f.disables {
+		value = "1"
+	}
+
+	return f.variable.GetName(), &value, nil
+}
+
+// GetFeatureFlag returns the state of the flag of a feature
+func GetFeatureFlag(feature models.Feature) *models.FeatureFlag {
+	f := findFlag(feature)
+	if f == nil {
+		return nil
+	}
+
+	return f.featureFlag()
+}
+
+// GetFeatureFlags returns the state of the flags of all features
+func GetFeatureFlags() []*models.FeatureFlag {
+	result := make([]*models.FeatureFlag, len(flags))
+	for i, f := range flags {
+		result[i] = f.featureFlag()
+	}
+
+	return result
+}
+
+func (f *flag) featureFlag() *models.FeatureFlag {
+	return &models.FeatureFlag{
+		Feature:         f.feature,
+		Description:     f.description,
+		Enabled:         f.enabled(),
+		Experimental:    f.experimental,
+		RestartRequired: f.restartRequired,
+		Overridden:      f.variable.GetOverride() != nil,
+	}
+}
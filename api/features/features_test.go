@@ -0,0 +1,66 @@
+package features_test
+
+import (
+	"testing"
+
+	"github.com/photoview/photoview/api/features"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnabled(t *testing.T) {
+	t.Setenv(utils.EnvDisableVideoEncoding.GetName(), "")
+	t.Setenv(utils.EnvEnableDLNA.GetName(), "")
+
+	assert.True(t, features.Enabled(models.FeatureVideoTranscoding), "features are enabled by default")
+	assert.False(t, features.Enabled(models.FeatureDlna), "experimental features are disabled by default")
+
+	t.Setenv(utils.EnvDisableVideoEncoding.GetName(), "1")
+	t.Setenv(utils.EnvEnableDLNA.GetName(), "true")
+
+	assert.False(t, features.Enabled(models.FeatureVideoTranscoding))
+	assert.True(t, features.Enabled(models.FeatureDlna))
+
+	// Settings override the environment
+	value := "0"
+	utils.EnvDisableVideoEncoding.SetOverride(&value)
+	defer utils.EnvDisableVideoEncoding.SetOverride(nil)
+
+	flag := features.GetFeatureFlag(models.FeatureVideoTranscoding)
+	if assert.NotNil(t, flag) {
+		assert.True(t, flag.Enabled)
+		assert.True(t, flag.Overridden)
+	}
+}
+
+func TestSetting(t *testing.T) {
+	enabled, disabled := true, false
+
+	key, value, err := features.Setting(models.FeatureFaceRecognition, &enabled)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.EnvDisableFaceRecognition.GetName(), key)
+	if assert.NotNil(t, value) {
+		assert.Equal(t, "0", *value)
+	}
+
+	key, value, err = features.Setting(models.FeatureDlna, &enabled)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.EnvEnableDLNA.GetName(), key)
+	if assert.NotNil(t, value) {
+		assert.Equal(t, "1", *value)
+	}
+
+	_, value, err = features.Setting(models.FeatureDlna, &disabled)
+	assert.NoError(t, err)
+	if assert.NotNil(t, value) {
+		assert.Equal(t, "0", *value)
+	}
+
+	_, value, err = features.Setting(models.FeatureDlna, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	_, _, err = features.Setting(models.Feature("FEDERATION"), &enabled)
+	assert.Error(t, err)
+}
@@ -0,0 +1,24 @@
+//go:build embed_ui
+
+package frontend
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+func assets() fs.FS {
+	sub, err := fs.Sub(dist, "dist")
+	if err != nil {
+		return nil
+	}
+
+	if _, err := fs.Stat(sub, "index.html"); err != nil {
+		return nil
+	}
+
+	return sub
+}
@@ -0,0 +1,9 @@
+//go:build !embed_ui
+
+package frontend
+
+import "io/fs"
+
+func assets() fs.FS {
+	return nil
+}
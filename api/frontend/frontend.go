@@ -0,0 +1,19 @@
+// Package frontend holds the built assets of the ui, embedded into the binary when it is built with the embed_ui tag,
+// so the server can be deployed as a single binary. The ui has to be built into frontend/dist first:
+//
+//	cd ui && npm run build -- --outDir ../api/frontend/dist --emptyOutDir
+//	cd api && go build -tags embed_ui
+package frontend
+
+import "io/fs"
+
+// Embedded returns whether the assets of the ui have been embedded into the binary
+func Embedded() bool {
+	return Assets() != nil
+}
+
+// Assets returns the embedded assets of the ui, rooted at the directory containing index.html,
+// or nil if the binary has been built without them
+func Assets() fs.FS {
+	return assets()
+}
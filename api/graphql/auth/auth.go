@@ -3,13 +3,13 @@ package auth
 import (
 	"context"
 	"errors"
-	"log"
 	"net/http"
 	"regexp"
 
 	"github.com/99designs/gqlgen/handler"
 	"github.com/photoview/photoview/api/dataloader"
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"gorm.io/gorm"
 )
 
@@ -32,7 +32,7 @@ func Middleware(db *gorm.DB) func(http.Handler) http.Handler {
 				user, err := dataloader.For(r.Context()).UserFromAccessToken.Load(tokenCookie.Value)
 				// user, err := models.VerifyTokenAndGetUser(db, tokenCookie.Value)
 				if err != nil {
-					log.Printf("Invalid token: %s\n", err)
+					log.Warn(r.Context(), "Invalid token", "error", err)
 					http.Error(w, "invalid authorization token", http.StatusForbidden)
 					return
 				}
@@ -43,7 +43,7 @@ func Middleware(db *gorm.DB) func(http.Handler) http.Handler {
 				// and call the next with our new context
 				r = r.WithContext(ctx)
 			} else {
-				log.Println("Did not find auth-token cookie")
+				log.Debug(r.Context(), "Did not find auth-token cookie")
 			}
 
 			next.ServeHTTP(w, r)
@@ -82,14 +82,14 @@ func AuthWebsocketInit(db *gorm.DB) func(context.Context, handler.InitPayload) (
 
 		token, err := TokenFromBearer(&bearer)
 		if err != nil {
-			log.Printf("Invalid bearer format (websocket): %s\n", bearer)
+			log.Warn(ctx, "Invalid bearer format (websocket)")
 			return nil, err
 		}
 
 		user, err := dataloader.For(ctx).UserFromAccessToken.Load(*token)
 		// user, err := models.VerifyTokenAndGetUser(db, *token)
 		if err != nil {
-			log.Printf("Invalid token in websocket: %s\n", err)
+			log.Warn(ctx, "Invalid token in websocket", "error", err)
 			return nil, errors.New("invalid authorization token")
 		}
 
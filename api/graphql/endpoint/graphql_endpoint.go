@@ -10,6 +10,7 @@ import (
 	photoview_graphql "github.com/photoview/photoview/api/graphql"
 	"github.com/photoview/photoview/api/graphql/resolvers"
 	"github.com/photoview/photoview/api/server"
+	"github.com/photoview/photoview/api/tracing"
 	"github.com/photoview/photoview/api/utils"
 	"gorm.io/gorm"
 )
@@ -45,5 +46,9 @@ func GraphqlEndpoint(db *gorm.DB) *graphql_handler.Server {
 		graphqlServer.Use(extension.Introspection{})
 	}
 
+	if tracing.Enabled() {
+		graphqlServer.Use(tracing.GraphQLTracer{})
+	}
+
 	return graphqlServer
 }
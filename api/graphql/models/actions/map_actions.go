@@ -0,0 +1,140 @@
+package actions
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	// mapMaxZoom is the deepest zoom level of maps, where a tile is about a meter wide
+	mapMaxZoom = 24
+	// mapTileSize is the size in pixels of the tiles maps are drawn in
+	mapTileSize = 256
+	// mapClusterCellSize is the size in pixels of the cells of the map, media in the same cell are grouped into a cluster
+	mapClusterCellSize = 64
+	// mercatorMaxLatitude is the furthest latitude from the equator shown on web maps
+	mercatorMaxLatitude = 85.05112878
+)
+
+type geoMediaPoint struct {
+	ID        int
+	Latitude  float64
+	Longitude float64
+	DateShot  time.Time
+}
+
+type mapClusterCell struct {
+	x, y int
+}
+
+// MyMediaClusters returns the media of a user taken inside the bounds of a map, grouped into clusters
+// of the media in the same cell of a grid over the map at the zoom level
+func MyMediaClusters(db *gorm.DB, user *models.User, bounds models.MapBounds, zoom int) ([]*models.MediaCluster, error) {
+	if zoom < 0 || zoom > mapMaxZoom {
+		return nil, errors.Errorf("zoom must be between 0 and %d", mapMaxZoom)
+	}
+	if bounds.South > bounds.North || bounds.South < -90 || bounds.North > 90 {
+		return nil, errors.New("invalid latitudes of bounds")
+	}
+	if bounds.West < -180 || bounds.West > 180 || bounds.East < -180 || bounds.East > 180 {
+		return nil, errors.New("invalid longitudes of bounds")
+	}
+
+	query := db.Table("media").
+		Select("media.id, media.date_shot, media_exif.gps_latitude AS latitude, media_exif.gps_longitude AS longitude").
+		Joins("INNER JOIN media_exif ON media.exif_id = media_exif.id").
+		Where("media.album_id IN (?)", db.Table("user_albums").Select("user_albums.album_id").Where("user_id = ?", user.ID)).
+		Where("media_exif.gps_latitude BETWEEN ? AND ?", bounds.South, bounds.North)
+
+	if bounds.West <= bounds.East {
+		query = query.Where("media_exif.gps_longitude BETWEEN ? AND ?", bounds.West, bounds.East)
+	} else {
+		query = query.Where("(media_exif.gps_longitude >= ? OR media_exif.gps_longitude <= ?)", bounds.West, bounds.East)
+	}
+
+	var points []*geoMediaPoint
+	if err := query.Scan(&points).Error; err != nil {
+		return nil, errors.Wrap(err, "get media with coordinates")
+	}
+
+	cells := make(map[mapClusterCell][]*geoMediaPoint)
+	for _, point := range points {
+		cell := mapClusterCellOf(point.Latitude, point.Longitude, zoom)
+		cells[cell] = append(cells[cell], point)
+	}
+
+	clusters := make([]*models.MediaCluster, 0, len(cells))
+	representatives := make(map[int]*models.MediaCluster, len(cells))
+	mediaIDs := make([]int, 0, len(cells))
+
+	for _, cellPoints := range cells {
+		cluster := &models.MediaCluster{Count: len(cellPoints)}
+		newest := cellPoints[0]
+
+		for _, point := range cellPoints {
+			cluster.Latitude += point.Latitude
+			cluster.Longitude += point.Longitude
+
+			if point.DateShot.After(newest.DateShot) || (point.DateShot.Equal(newest.DateShot) && point.ID > newest.ID) {
+				newest = point
+			}
+		}
+
+		cluster.Latitude /= float64(len(cellPoints))
+		cluster.Longitude /= float64(len(cellPoints))
+
+		clusters = append(clusters, cluster)
+		representatives[newest.ID] = cluster
+		mediaIDs = append(mediaIDs, newest.ID)
+	}
+
+	if len(mediaIDs) > 0 {
+		var media []*models.Media
+		if err := db.Where("id IN (?)", mediaIDs).Find(&media).Error; err != nil {
+			return nil, errors.Wrap(err, "get media of clusters")
+		}
+
+		for _, item := range media {
+			representatives[item.ID].Media = item
+		}
+	}
+
+	// Media deleted since the coordinates were read are left out
+	found := clusters[:0]
+	for _, cluster := range clusters {
+		if cluster.Media != nil {
+			found = append(found, cluster)
+		}
+	}
+	clusters = found
+
+	// The largest clusters come first, so they are drawn below the smaller ones on top of them
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Count != clusters[j].Count {
+			return clusters[i].Count > clusters[j].Count
+		}
+		return clusters[i].Media.ID < clusters[j].Media.ID
+	})
+
+	return clusters, nil
+}
+
+// mapClusterCellOf returns the cell of the grid over a web mercator map at the zoom level that a coordinate is in
+func mapClusterCellOf(latitude, longitude float64, zoom int) mapClusterCell {
+	latitude = math.Max(-mercatorMaxLatitude, math.Min(mercatorMaxLatitude, latitude))
+	worldSize := float64(mapTileSize) * math.Exp2(float64(zoom))
+
+	x := (longitude + 180) / 360 * worldSize
+	latitudeRadians := latitude * math.Pi / 180
+	y := (1 - math.Log(math.Tan(latitudeRadians)+1/math.Cos(latitudeRadians))/math.Pi) / 2 * worldSize
+
+	return mapClusterCell{
+		x: int(math.Floor(x / mapClusterCellSize)),
+		y: int(math.Floor(y / mapClusterCellSize)),
+	}
+}
@@ -0,0 +1,106 @@
+package actions_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/graphql/models/actions"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMyMediaClusters(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	user, err := models.RegisterUser(db, "user", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	otherUser, err := models.RegisterUser(db, "other", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{Title: "travels", Path: "/photos"}
+	otherAlbum := models.Album{Title: "other", Path: "/other"}
+	if !assert.NoError(t, db.Model(&user).Association("Albums").Append(&album)) ||
+		!assert.NoError(t, db.Model(&otherUser).Association("Albums").Append(&otherAlbum)) {
+		return
+	}
+
+	addMedia := func(album *models.Album, title string, latitude, longitude float64, dateShot time.Time) *models.Media {
+		media := models.Media{
+			Title:    title,
+			Path:     album.Path + "/" + title,
+			AlbumID:  album.ID,
+			DateShot: dateShot,
+			Type:     models.MediaTypePhoto,
+			Exif:     &models.MediaEXIF{GPSLatitude: &latitude, GPSLongitude: &longitude},
+		}
+		assert.NoError(t, db.Save(&media).Error)
+		return &media
+	}
+
+	day := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	addMedia(&album, "copenhagen1.jpg", 55.6761, 12.5683, day)
+	newest := addMedia(&album, "copenhagen2.jpg", 55.6770, 12.5690, day.Add(time.Hour))
+	aarhus := addMedia(&album, "aarhus.jpg", 56.1629, 10.2039, day)
+	fiji := addMedia(&album, "fiji.jpg", -17.7134, 178.0650, day)
+	samoa := addMedia(&album, "samoa.jpg", -13.7590, -172.1046, day)
+	addMedia(&otherAlbum, "other.jpg", 55.6761, 12.5683, day)
+	noGPS := models.Media{Title: "nogps.jpg", Path: "/photos/nogps.jpg", AlbumID: album.ID, Type: models.MediaTypePhoto}
+	assert.NoError(t, db.Save(&noGPS).Error)
+
+	denmark := models.MapBounds{North: 58, South: 54, East: 16, West: 7}
+
+	t.Run("Zoomed in", func(t *testing.T) {
+		clusters, err := actions.MyMediaClusters(db, user, denmark, 8)
+		if !assert.NoError(t, err) || !assert.Len(t, clusters, 2) {
+			return
+		}
+
+		assert.Equal(t, 2, clusters[0].Count)
+		assert.Equal(t, newest.ID, clusters[0].Media.ID)
+		assert.InDelta(t, 55.67655, clusters[0].Latitude, 0.00001)
+		assert.InDelta(t, 12.56865, clusters[0].Longitude, 0.00001)
+
+		assert.Equal(t, 1, clusters[1].Count)
+		assert.Equal(t, aarhus.ID, clusters[1].Media.ID)
+	})
+
+	t.Run("Zoomed out", func(t *testing.T) {
+		clusters, err := actions.MyMediaClusters(db, user, denmark, 0)
+		if assert.NoError(t, err) && assert.Len(t, clusters, 1) {
+			assert.Equal(t, 3, clusters[0].Count)
+		}
+	})
+
+	t.Run("Whole world", func(t *testing.T) {
+		clusters, err := actions.MyMediaClusters(db, user, models.MapBounds{North: 90, South: -90, East: 180, West: -180}, 0)
+		if assert.NoError(t, err) {
+			total := 0
+			for _, cluster := range clusters {
+				total += cluster.Count
+			}
+			assert.Equal(t, 5, total, "media of other users and without coordinates are left out")
+		}
+	})
+
+	t.Run("Across the antimeridian", func(t *testing.T) {
+		clusters, err := actions.MyMediaClusters(db, user, models.MapBounds{North: 0, South: -30, East: -160, West: 170}, 4)
+		if assert.NoError(t, err) && assert.Len(t, clusters, 2) {
+			ids := []int{clusters[0].Media.ID, clusters[1].Media.ID}
+			assert.ElementsMatch(t, []int{fiji.ID, samoa.ID}, ids)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := actions.MyMediaClusters(db, user, denmark, 30)
+		assert.Error(t, err)
+
+		_, err = actions.MyMediaClusters(db, user, models.MapBounds{North: 10, South: 20, East: 10, West: 0}, 4)
+		assert.Error(t, err)
+	})
+}
@@ -6,13 +6,14 @@ import (
 	"github.com/photoview/photoview/api/database/drivers"
 	"github.com/photoview/photoview/api/graphql/auth"
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/notifier"
 	"github.com/photoview/photoview/api/utils"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
-func AddMediaShare(db *gorm.DB, user *models.User, mediaID int, expire *time.Time, password *string) (*models.ShareToken, error) {
+func AddMediaShare(db *gorm.DB, user *models.User, mediaID int, expire *time.Time, password *string, stripMetadata *bool) (*models.ShareToken, error) {
 	var media models.Media
 
 	var query string
@@ -47,16 +48,20 @@ func AddMediaShare(db *gorm.DB, user *models.User, mediaID int, expire *time.Tim
 		Password: hashedPassword,
 		AlbumID:  nil,
 		MediaID:  &mediaID,
+
+		StripMetadata: stripMetadata,
 	}
 
 	if err := db.Create(&shareToken).Error; err != nil {
 		return nil, errors.Wrap(err, "failed to insert new share token into database")
 	}
 
+	notifier.ShareCreated(&shareToken)
+
 	return &shareToken, nil
 }
 
-func AddAlbumShare(db *gorm.DB, user *models.User, albumID int, expire *time.Time, password *string) (*models.ShareToken, error) {
+func AddAlbumShare(db *gorm.DB, user *models.User, albumID int, expire *time.Time, password *string, stripMetadata *bool) (*models.ShareToken, error) {
 	var count int64
 	err := db.
 		Model(&models.Album{}).
@@ -88,12 +93,16 @@ func AddAlbumShare(db *gorm.DB, user *models.User, albumID int, expire *time.Tim
 		Password: hashedPassword,
 		AlbumID:  &albumID,
 		MediaID:  nil,
+
+		StripMetadata: stripMetadata,
 	}
 
 	if err := db.Create(&shareToken).Error; err != nil {
 		return nil, errors.Wrap(err, "failed to insert new share token into database")
 	}
 
+	notifier.ShareCreated(&shareToken)
+
 	return &shareToken, nil
 }
 
@@ -130,6 +139,23 @@ func ProtectShareToken(db *gorm.DB, userID int, tokenValue string, password *str
 	return token, nil
 }
 
+// SetShareTokenStripMetadata sets whether metadata is removed from the media served through the share,
+// nil uses the default of the site
+func SetShareTokenStripMetadata(db *gorm.DB, userID int, tokenValue string, stripMetadata *bool) (*models.ShareToken, error) {
+	token, err := getUserToken(db, userID, tokenValue)
+	if err != nil {
+		return nil, err
+	}
+
+	token.StripMetadata = stripMetadata
+
+	if err := db.Save(&token).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to update strip metadata option of share token")
+	}
+
+	return token, nil
+}
+
 func hashSharePassword(password *string) (*string, error) {
 	var hashedPassword *string = nil
 	if password != nil {
@@ -7,6 +7,7 @@ import (
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/graphql/models/actions"
 	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -62,7 +63,7 @@ func TestShareToken(t *testing.T) {
 	var albumShare *models.ShareToken
 
 	t.Run("Add album share", func(t *testing.T) {
-		share, err := actions.AddAlbumShare(db, user, rootAlbum.ID, &expireTime, nil)
+		share, err := actions.AddAlbumShare(db, user, rootAlbum.ID, &expireTime, nil, nil)
 		albumShare = share
 
 		assert.NoError(t, err)
@@ -74,7 +75,7 @@ func TestShareToken(t *testing.T) {
 	})
 
 	t.Run("Add media share", func(t *testing.T) {
-		share, err := actions.AddMediaShare(db, user, media[0].ID, &expireTime, &sharePassword)
+		share, err := actions.AddMediaShare(db, user, media[0].ID, &expireTime, &sharePassword, nil)
 		mediaShare = share
 
 		assert.NoError(t, err)
@@ -104,4 +105,38 @@ func TestShareToken(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Empty(t, share.Password)
 	})
+
+	t.Run("Strip metadata of share", func(t *testing.T) {
+		t.Setenv(string(utils.EnvShareStripMetadata), "0")
+
+		strips, err := models.MediaSharesStripMetadata(db, &media[1])
+		assert.NoError(t, err)
+		assert.False(t, strips)
+
+		// Media of sub albums are stripped by a share of their parent album
+		stripMetadata := true
+		share, err := actions.SetShareTokenStripMetadata(db, user.ID, albumShare.Value, &stripMetadata)
+		if assert.NoError(t, err) {
+			assert.True(t, share.StripsMetadata())
+		}
+
+		strips, err = models.MediaSharesStripMetadata(db, &media[1])
+		assert.NoError(t, err)
+		assert.True(t, strips)
+
+		// Shares that don't set it use the default of the site
+		share, err = actions.SetShareTokenStripMetadata(db, user.ID, albumShare.Value, nil)
+		if assert.NoError(t, err) {
+			assert.Nil(t, share.StripMetadata)
+			assert.False(t, share.StripsMetadata())
+		}
+
+		t.Setenv(string(utils.EnvShareStripMetadata), "1")
+		strips, err = models.MediaSharesStripMetadata(db, &media[1])
+		assert.NoError(t, err)
+		assert.True(t, strips)
+
+		_, err = actions.SetShareTokenStripMetadata(db, user.ID+100, albumShare.Value, &stripMetadata)
+		assert.Error(t, err, "only the owner of a share can change it")
+	})
 }
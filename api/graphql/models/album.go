@@ -3,7 +3,10 @@ package models
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"os"
+	"path"
 
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
 
@@ -18,6 +21,10 @@ type Album struct {
 	Path     string `gorm:"not null"`
 	PathHash string `gorm:"unique"`
 	CoverID  *int
+	// ColdStorage marks albums on slow storage (tape, glacier mounts) where originals are retrieved asynchronously
+	ColdStorage bool `gorm:"not null;default:false"`
+	// DLNAEnabled shares the album and its sub albums with media players on the local network
+	DLNAEnabled bool `gorm:"column:dlna_enabled;not null;default:false"`
 }
 
 func (a *Album) FilePath() string {
@@ -80,6 +87,85 @@ func GetParentsFromAlbums(db *gorm.DB, filter func(*gorm.DB) *gorm.DB, albumID i
 	return parents, err
 }
 
+// InColdStorage returns true if this album or any of its parents has been marked as cold storage,
+// or if the album is located on a cold storage backend
+func (a *Album) InColdStorage(db *gorm.DB) (bool, error) {
+	if a.ColdStorage {
+		return true, nil
+	}
+
+	backend, err := StorageBackendForPath(db, a.Path)
+	if err != nil {
+		return false, err
+	}
+
+	if backend != nil && backend.Cold {
+		return true, nil
+	}
+
+	coldParents, err := a.GetParents(db, func(query *gorm.DB) *gorm.DB {
+		return query.Where("cold_storage = ?", true)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(coldParents) > 0, nil
+}
+
+// DLNAShared returns true if this album or any of its parents is shared with media players on the local network
+func (a *Album) DLNAShared(db *gorm.DB) (bool, error) {
+	if a.DLNAEnabled {
+		return true, nil
+	}
+
+	sharedParents, err := a.GetParents(db, func(query *gorm.DB) *gorm.DB {
+		return query.Where("dlna_enabled = ?", true)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(sharedParents) > 0, nil
+}
+
+// EnsureSubAlbum returns the sub album with the given directory name, creating the directory and album if they do not exist yet.
+// A new album is owned by the same users as this album.
+func (a *Album) EnsureSubAlbum(db *gorm.DB, name string) (*Album, error) {
+	subAlbumPath := path.Join(a.Path, name)
+
+	var albums []*Album
+	if err := db.Where("path_hash = ?", MD5Hash(subAlbumPath)).Limit(1).Find(&albums).Error; err != nil {
+		return nil, errors.Wrap(err, "get sub album from database")
+	}
+
+	if len(albums) > 0 {
+		return albums[0], nil
+	}
+
+	if err := os.MkdirAll(subAlbumPath, os.ModePerm); err != nil {
+		return nil, errors.Wrap(err, "create sub album directory")
+	}
+
+	var owners []User
+	if err := db.Model(a).Association("Owners").Find(&owners); err != nil {
+		return nil, errors.Wrap(err, "get owners of album")
+	}
+
+	subAlbum := &Album{
+		Title:         name,
+		ParentAlbumID: &a.ID,
+		Path:          subAlbumPath,
+		Owners:        owners,
+	}
+
+	if err := db.Create(subAlbum).Error; err != nil {
+		return nil, errors.Wrap(err, "insert sub album into database")
+	}
+
+	return subAlbum, nil
+}
+
 func (a *Album) Thumbnail(db *gorm.DB) (*Media, error) {
 	var media Media
 
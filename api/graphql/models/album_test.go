@@ -121,3 +121,44 @@ func TestAlbumGetChildrenAndParents(t *testing.T) {
 	})
 
 }
+
+func TestAlbumInColdStorage(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	rootAlbum := models.Album{
+		Title:       "archive",
+		Path:        "/archive",
+		ColdStorage: true,
+	}
+
+	if !assert.NoError(t, db.Save(&rootAlbum).Error) {
+		return
+	}
+
+	childAlbum := models.Album{
+		Title:         "2009",
+		Path:          "/archive/2009",
+		ParentAlbumID: &rootAlbum.ID,
+	}
+
+	otherAlbum := models.Album{
+		Title: "recent",
+		Path:  "/recent",
+	}
+
+	if !assert.NoError(t, db.Save(&childAlbum).Error) || !assert.NoError(t, db.Save(&otherAlbum).Error) {
+		return
+	}
+
+	cold, err := rootAlbum.InColdStorage(db)
+	assert.NoError(t, err)
+	assert.True(t, cold)
+
+	cold, err = childAlbum.InColdStorage(db)
+	assert.NoError(t, err)
+	assert.True(t, cold, "expected album to inherit cold storage from its parent")
+
+	cold, err = otherAlbum.InColdStorage(db)
+	assert.NoError(t, err)
+	assert.False(t, cold)
+}
@@ -0,0 +1,42 @@
+package models
+
+import "strings"
+
+// AVIFVariantPrefix is the prefix of the purposes of the AVIF encoded variants of the generated images of photos,
+// which are followed by the purpose of the image they are a variant of
+const AVIFVariantPrefix = "avif-"
+
+// HasAVIFVariant returns whether images of the purpose are given an AVIF variant, which are the thumbnails,
+// scaled copies and web copies generated of photos
+func (p MediaPurpose) HasAVIFVariant() bool {
+	_, scaled := p.ScaledSize()
+	return scaled || p == PhotoThumbnail || p == PhotoHighRes
+}
+
+// AVIFVariant returns the purpose of the AVIF variant of images of the purpose
+func (p MediaPurpose) AVIFVariant() MediaPurpose {
+	return MediaPurpose(AVIFVariantPrefix + string(p))
+}
+
+// AVIFVariantOf returns the purpose of the image the AVIF variant of the purpose is of,
+// and whether the purpose is of an AVIF variant
+func (p MediaPurpose) AVIFVariantOf() (MediaPurpose, bool) {
+	if !strings.HasPrefix(string(p), AVIFVariantPrefix) {
+		return "", false
+	}
+
+	base := MediaPurpose(strings.TrimPrefix(string(p), AVIFVariantPrefix))
+	return base, base.HasAVIFVariant()
+}
+
+// WithAVIFVariants returns the purposes, followed by the purposes of the AVIF variants of those that have one
+func WithAVIFVariants(purposes []MediaPurpose) []MediaPurpose {
+	result := append([]MediaPurpose{}, purposes...)
+	for _, purpose := range purposes {
+		if purpose.HasAVIFVariant() {
+			result = append(result, purpose.AVIFVariant())
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,26 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAVIFVariants(t *testing.T) {
+	assert.Equal(t, models.MediaPurpose("avif-thumbnail"), models.PhotoThumbnail.AVIFVariant())
+	assert.Equal(t, models.MediaPurpose("avif-scaled-256"), models.ScaledPhotoPurpose(256).AVIFVariant())
+	assert.False(t, models.MediaOriginal.HasAVIFVariant())
+	assert.False(t, models.VideoThumbnail.HasAVIFVariant())
+
+	base, isVariant := models.PhotoHighRes.AVIFVariant().AVIFVariantOf()
+	assert.True(t, isVariant)
+	assert.Equal(t, models.PhotoHighRes, base)
+
+	_, isVariant = models.MediaPurpose("avif-original").AVIFVariantOf()
+	assert.False(t, isVariant)
+
+	cacheType, _ := models.PhotoHighRes.AVIFVariant().CacheType()
+	assert.Equal(t, models.CacheTypeWebVersions, cacheType)
+	assert.Contains(t, models.CacheTypeThumbnails.Purposes(), models.PhotoThumbnail.AVIFVariant())
+}
@@ -0,0 +1,18 @@
+package models
+
+// CacheAccessStats counts the requests for cached files of a day, across all instances of the server.
+// A miss is a request for a file that was not in the cache, and had to be generated again.
+type CacheAccessStats struct {
+	// Date is the day in local time, formatted as 2006-01-02
+	Date   string `gorm:"primaryKey;size:10"`
+	Hits   int64  `gorm:"not null;default:0"`
+	Misses int64  `gorm:"not null;default:0"`
+}
+
+func (s *CacheAccessStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"path"
+	"time"
+)
+
+// CacheEntry is a file in the content addressed part of the media cache, such as a thumbnail, making up its manifest.
+// An entry is shared by all media with the same content, and is kept for a while after the last of them has been deleted,
+// so media that is moved or renamed doesn't have to be processed again.
+type CacheEntry struct {
+	// Key is the path of the file relative to the cache location of its purpose, see CacheType
+	Key         string       `gorm:"primaryKey;size:191"`
+	ContentHash string       `gorm:"not null;index;size:64"`
+	Purpose     MediaPurpose `gorm:"not null"`
+	Width       int          `gorm:"not null"`
+	Height      int          `gorm:"not null"`
+	ContentType string       `gorm:"not null"`
+	FileSize    int64        `gorm:"not null"`
+	// OrphanedAt is when the entry was found to no longer be used by any media, it is deleted once it has been orphaned for a while
+	OrphanedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// FilePath returns the path of the file of the cache entry
+func (e *CacheEntry) FilePath() string {
+	return path.Join(e.Purpose.CachePath(), e.Key)
+}
@@ -0,0 +1,65 @@
+package models
+
+import "github.com/photoview/photoview/api/utils"
+
+var cacheTypeLocations = map[CacheType]utils.EnvironmentVariable{
+	CacheTypeThumbnails:      utils.EnvMediaCacheThumbnails,
+	CacheTypeWebVersions:     utils.EnvMediaCacheWebVersions,
+	CacheTypeVideoTranscodes: utils.EnvMediaCacheVideoTranscodes,
+}
+
+var cacheTypePurposes = map[CacheType][]MediaPurpose{
+	CacheTypeThumbnails:      {PhotoThumbnail, VideoThumbnail, VideoSprite},
+	CacheTypeWebVersions:     {PhotoHighRes},
+	CacheTypeVideoTranscodes: {VideoWeb, MotionVideo},
+}
+
+// Path returns the directory the cached files of the type are stored in, which is the media cache unless configured otherwise.
+// Only files addressed by their content are stored there, files of media that has not been hashed stay in the media cache.
+func (t CacheType) Path() string {
+	if location := cacheTypeLocations[t].GetValue(); location != "" {
+		return location
+	}
+
+	return utils.MediaCachePath()
+}
+
+// Purposes returns the purposes of the media urls whose files are of the type.
+// The scaled copies of photos of the configured sizes are thumbnails, and AVIF variants are of the type of their image.
+func (t CacheType) Purposes() []MediaPurpose {
+	if t == CacheTypeThumbnails {
+		return WithAVIFVariants(append(append([]MediaPurpose{}, cacheTypePurposes[t]...), ScaledPhotoPurposes()...))
+	}
+
+	return WithAVIFVariants(cacheTypePurposes[t])
+}
+
+// CacheType returns the type of the cached files of the purpose, originals are not cached and have no type.
+// Images resized on demand are thumbnails, like the scaled copies.
+func (p MediaPurpose) CacheType() (CacheType, bool) {
+	if _, scaled := p.ScaledSize(); scaled || p.IsResized() {
+		return CacheTypeThumbnails, true
+	}
+	if base, variant := p.AVIFVariantOf(); variant {
+		return base.CacheType()
+	}
+
+	for cacheType, purposes := range cacheTypePurposes {
+		for _, purpose := range purposes {
+			if purpose == p {
+				return cacheType, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// CachePath returns the directory the cached files of the purpose, addressed by their content, are stored in
+func (p MediaPurpose) CachePath() string {
+	if cacheType, ok := p.CacheType(); ok {
+		return cacheType.Path()
+	}
+
+	return utils.MediaCachePath()
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"path"
+	"time"
+
+	"github.com/photoview/photoview/api/utils"
+)
+
+// CastSession gives a cast receiver, such as a Chromecast, temporary access to the media of an album.
+// Receivers can not log in, so the media is fetched with the token of the session instead.
+type CastSession struct {
+	Model
+	Token   string    `gorm:"not null;unique;size:32"`
+	UserID  int       `gorm:"not null;index"`
+	User    *User     `gorm:"constraint:OnDelete:CASCADE;"`
+	AlbumID int       `gorm:"not null;index"`
+	Album   *Album    `gorm:"constraint:OnDelete:CASCADE;"`
+	Expire  time.Time `gorm:"not null;index"`
+}
+
+func (s *CastSession) ManifestURL() string {
+	manifestURL := utils.ApiEndpointUrl()
+	manifestURL.Path = path.Join(manifestURL.Path, "cast", s.Token, "manifest.json")
+	return manifestURL.String()
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Device is a mobile device registered by a user, to back up its camera roll into a per-device album
+type Device struct {
+	Model
+	Name     string `gorm:"not null"`
+	Platform *string
+	UserID   int   `gorm:"not null;index"`
+	User     *User `gorm:"constraint:OnDelete:CASCADE;"`
+	// Path is the directory the media of the device is backed up to
+	Path         string `gorm:"not null"`
+	LastBackupAt *time.Time
+}
+
+// DeviceAsset records a file of a device that has been backed up, identified by the SHA-1 checksum of its content
+type DeviceAsset struct {
+	Model
+	DeviceID int     `gorm:"not null;index"`
+	Device   *Device `gorm:"constraint:OnDelete:CASCADE;"`
+	Checksum string  `gorm:"not null;index;size:40"`
+	MediaID  *int    `gorm:"index"`
+	Media    *Media  `gorm:"constraint:OnDelete:SET NULL;"`
+}
+
+// Album returns the album of the device, or nil if nothing has been backed up yet
+func (d *Device) Album(db *gorm.DB) (*Album, error) {
+	var albums []*Album
+	if err := db.Where("path_hash = ?", MD5Hash(d.Path)).Limit(1).Find(&albums).Error; err != nil {
+		return nil, errors.Wrap(err, "get device album from database")
+	}
+
+	if len(albums) == 0 {
+		return nil, nil
+	}
+
+	return albums[0], nil
+}
+
+// EnsureAlbum returns the album of the device, creating the directory and album if they do not exist yet.
+// The album is placed inside the album of the parent directory, and owned by the same users.
+func (d *Device) EnsureAlbum(db *gorm.DB) (*Album, error) {
+	var parent Album
+	if err := db.Where("path_hash = ?", MD5Hash(path.Dir(d.Path))).First(&parent).Error; err != nil {
+		return nil, errors.Wrap(err, "get parent album of device from database")
+	}
+
+	album, err := parent.EnsureSubAlbum(db, path.Base(d.Path))
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the name of the device rather than the directory name, which might have been sanitized
+	if album.Title != d.Name {
+		if err := db.Model(album).Update("title", d.Name).Error; err != nil {
+			return nil, errors.Wrap(err, "update title of device album")
+		}
+	}
+
+	return album, nil
+}
+
+// BackedUpChecksums returns the subset of the given checksums, that have already been backed up by any device of the user
+func BackedUpChecksums(db *gorm.DB, userID int, checksums []string) ([]string, error) {
+	backedUp := make([]string, 0)
+	if len(checksums) == 0 {
+		return backedUp, nil
+	}
+
+	err := db.Model(&DeviceAsset{}).
+		Joins("JOIN devices ON devices.id = device_assets.device_id").
+		Where("devices.user_id = ?", userID).
+		Where("device_assets.checksum IN (?)", checksums).
+		Distinct().Pluck("device_assets.checksum", &backedUp).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get backed up checksums from database")
+	}
+
+	return backedUp, nil
+}
@@ -17,6 +17,49 @@ type AuthorizeResult struct {
 	Token *string `json:"token,omitempty"`
 }
 
+// Disk usage of the cached files of a type
+type CacheTypeUsage struct {
+	Type CacheType `json:"type"`
+	// Directory the files are stored in, the media cache unless configured otherwise
+	Path string `json:"path"`
+	// Total size of the cached files of the type
+	UsedBytes int `json:"usedBytes"`
+	// Max size of the cached files of the type, 0 means no limit
+	BudgetBytes int `json:"budgetBytes"`
+	// Whether or not the usage is above the warning threshold of the budget
+	Warning bool `json:"warning"`
+	// Free space on the disk holding the files, if it could be determined
+	DiskFreeBytes *int `json:"diskFreeBytes,omitempty"`
+	// Total space on the disk holding the files, if it could be determined
+	DiskTotalBytes *int `json:"diskTotalBytes,omitempty"`
+}
+
+// Disk usage of the media cache
+type CacheUsage struct {
+	// Total size of the files in the media cache
+	UsedBytes int `json:"usedBytes"`
+	// Max size of the media cache, 0 means no limit
+	BudgetBytes int `json:"budgetBytes"`
+	// Fraction of the budget above which a warning is raised
+	WarningThreshold float64 `json:"warningThreshold"`
+	// Whether or not the usage is above the warning threshold of the budget
+	Warning bool `json:"warning"`
+	// Free space on the disk holding the media cache, if it could be determined
+	DiskFreeBytes *int `json:"diskFreeBytes,omitempty"`
+	// Total space on the disk holding the media cache, if it could be determined
+	DiskTotalBytes *int `json:"diskTotalBytes,omitempty"`
+	// When the usage was computed
+	ComputedAt time.Time `json:"computedAt"`
+	// Disk usage of every type of cached files
+	Types []*CacheTypeUsage `json:"types"`
+}
+
+type CameraUsage struct {
+	Camera     string  `json:"camera"`
+	Maker      *string `json:"maker,omitempty"`
+	MediaCount int     `json:"mediaCount"`
+}
+
 type Coordinates struct {
 	// GPS latitude in degrees
 	Latitude float64 `json:"latitude"`
@@ -24,12 +67,73 @@ type Coordinates struct {
 	Longitude float64 `json:"longitude"`
 }
 
+type FeatureFlag struct {
+	Feature     Feature `json:"feature"`
+	Description string  `json:"description"`
+	Enabled     bool    `json:"enabled"`
+	// Experimental features are disabled unless enabled explicitly
+	Experimental bool `json:"experimental"`
+	// Whether changes only take effect after the server has been restarted
+	RestartRequired bool `json:"restartRequired"`
+	// Whether the flag has been changed through the api, rather than coming from the environment
+	Overridden bool `json:"overridden"`
+}
+
+type MaintenanceStatus struct {
+	// Whether the server is in maintenance mode, scans and uploads are paused while it is
+	Enabled bool `json:"enabled"`
+	// The task currently running
+	CurrentTask *MaintenanceTask `json:"currentTask,omitempty"`
+	// Progress of the current task, from 0 to 1
+	Progress   float64    `json:"progress"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	// Results of the tasks finished during the latest maintenance
+	Results []*MaintenanceTaskResult `json:"results"`
+}
+
+type MaintenanceTaskResult struct {
+	Task    MaintenanceTask `json:"task"`
+	Success bool            `json:"success"`
+	// Summary of what the task did, or the error it failed with
+	Message string `json:"message"`
+}
+
+// A rectangle of a map in degrees, which crosses the antimeridian when west is greater than east
+type MapBounds struct {
+	North float64 `json:"north"`
+	South float64 `json:"south"`
+	East  float64 `json:"east"`
+	West  float64 `json:"west"`
+}
+
+// Media taken close to each other, shown as a single marker on a map
+type MediaCluster struct {
+	// Average GPS latitude of the media in degrees
+	Latitude float64 `json:"latitude"`
+	// Average GPS longitude of the media in degrees
+	Longitude float64 `json:"longitude"`
+	// Number of media in the cluster
+	Count int `json:"count"`
+	// The most recently taken media of the cluster, whose thumbnail represents it
+	Media *Media `json:"media"`
+}
+
 type MediaDownload struct {
 	// A description of the role of the media file
 	Title    string    `json:"title"`
 	MediaURL *MediaURL `json:"mediaUrl"`
 }
 
+type MediaGrowth struct {
+	// The month, formatted as 2006-01
+	Month string `json:"month"`
+	// Number of media added during the month
+	Added int `json:"added"`
+	// Number of media in the library at the end of the month
+	Total int `json:"total"`
+}
+
 type Mutation struct {
 }
 
@@ -69,6 +173,43 @@ type Pagination struct {
 type Query struct {
 }
 
+// Progress of a scan, from the first album being queued until the scanner is idle again.
+// Totals grow while the scan is running, as albums are queued and the media of albums are found.
+type ScanProgress struct {
+	// Whether the scanner is scanning
+	Running bool `json:"running"`
+	// Path of the album most recently started to be scanned, null once the scan has completed
+	CurrentAlbum *string `json:"currentAlbum,omitempty"`
+	// Number of albums scanned
+	AlbumsScanned int `json:"albumsScanned"`
+	// Number of albums queued to be scanned, including those already scanned
+	AlbumsTotal int `json:"albumsTotal"`
+	// Number of media processed, or skipped as they are unchanged
+	MediaProcessed int `json:"mediaProcessed"`
+	// Number of media found in the albums scanned so far
+	MediaTotal int `json:"mediaTotal"`
+	// Number of media found for new files
+	MediaAdded int `json:"mediaAdded"`
+	// Number of media removed as their files no longer exist
+	MediaRemoved int `json:"mediaRemoved"`
+	// Size in bytes of the files of the media processed, media skipped as they are unchanged are not counted
+	BytesProcessed int `json:"bytesProcessed"`
+	// Number of failures during the scan, see the scan report for them
+	FailureCount int        `json:"failureCount"`
+	StartedAt    *time.Time `json:"startedAt,omitempty"`
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+}
+
+// A job on the scanner queue, scanning a single album
+type ScannerQueueJob struct {
+	// The album scanned by the job
+	Album *Album `json:"album"`
+	// The user whose scan queued the job, null if the album was queued by itself, such as after an upload
+	User *User `json:"user,omitempty"`
+	// Whether the album is being scanned, otherwise it is waiting on the queue
+	Running bool `json:"running"`
+}
+
 type ScannerResult struct {
 	Finished bool     `json:"finished"`
 	Success  bool     `json:"success"`
@@ -91,6 +232,60 @@ type ShareTokenCredentials struct {
 	Password *string `json:"password,omitempty"`
 }
 
+// A setting that can be changed without restarting the server
+type SiteSetting struct {
+	// Name of the environment variable the setting overrides
+	Key         string `json:"key"`
+	Description string `json:"description"`
+	// The value currently in use
+	Value string `json:"value"`
+	// Whether the value has been changed through the api, rather than coming from the environment
+	Overridden bool `json:"overridden"`
+	// Value of the environment variable, used when the setting has not been changed
+	EnvironmentValue *string `json:"environmentValue,omitempty"`
+	// The allowed values, if the setting is limited to a set of options
+	Options []string `json:"options,omitempty"`
+}
+
+// Result of probing the performance of a storage backend
+type StorageDiagnostics struct {
+	Backend *StorageBackend `json:"backend"`
+	// Whether or not the root directory of the backend could be listed
+	Reachable bool `json:"reachable"`
+	// Number of directories that were listed
+	ListedDirectories int `json:"listedDirectories"`
+	// Total number of entries in the listed directories
+	ListedEntries int `json:"listedEntries"`
+	// Average time in milliseconds to list a directory
+	ListingLatencyMs *float64 `json:"listingLatencyMs,omitempty"`
+	// Number of directory entries listed per second
+	ListingEntriesPerSecond *float64 `json:"listingEntriesPerSecond,omitempty"`
+	// Average time in milliseconds to open a file and read its first 64 KB
+	ReadLatencyMs *float64 `json:"readLatencyMs,omitempty"`
+	// Slowest time in milliseconds to open a file and read its first 64 KB
+	MaxReadLatencyMs *float64 `json:"maxReadLatencyMs,omitempty"`
+	// Total number of listing and read operations performed
+	Probes int `json:"probes"`
+	// Number of failed operations
+	ErrorCount int `json:"errorCount"`
+	// Fraction of operations that failed
+	ErrorRate float64 `json:"errorRate"`
+	// The error of the last failed operation
+	LastError *string `json:"lastError,omitempty"`
+	// When the backend was probed
+	ProbedAt time.Time `json:"probedAt"`
+}
+
+// Disk space used by media, computed from the file sizes recorded when it was scanned
+type StorageUsage struct {
+	// Number of photos and videos
+	MediaCount int `json:"mediaCount"`
+	// Size in bytes of the original files
+	OriginalBytes int `json:"originalBytes"`
+	// Size in bytes of the thumbnails, high resolution photos and encoded videos generated by the scanner
+	CacheBytes int `json:"cacheBytes"`
+}
+
 type Subscription struct {
 }
 
@@ -106,6 +301,236 @@ type TimelineGroup struct {
 	Date time.Time `json:"date"`
 }
 
+type UsageStatistics struct {
+	// When the statistics were computed
+	ComputedAt time.Time `json:"computedAt"`
+	UserCount  int       `json:"userCount"`
+	AlbumCount int       `json:"albumCount"`
+	PhotoCount int       `json:"photoCount"`
+	VideoCount int       `json:"videoCount"`
+	// Media added to the library every month, over the last 12 months
+	Growth []*MediaGrowth `json:"growth"`
+	// Scans run every week, over the last 12 weeks
+	ScansPerWeek []*WeeklyScans `json:"scansPerWeek"`
+	// Users with the most activity over the last 30 days
+	MostActiveUsers []*UserActivity `json:"mostActiveUsers"`
+	// Cameras the most media has been taken with
+	TopCameras []*CameraUsage `json:"topCameras"`
+	// Requests for cached files every day, over the last 30 days
+	CacheAccess []*CacheAccessStats `json:"cacheAccess"`
+}
+
+type UserActivity struct {
+	User *User `json:"user"`
+	// Number of media added to the albums of the user
+	MediaAdded int `json:"mediaAdded"`
+	// Number of times the user has logged in
+	Logins int `json:"logins"`
+}
+
+type UserStorageUsage struct {
+	User  *User         `json:"user"`
+	Usage *StorageUsage `json:"usage"`
+}
+
+type WeeklyScans struct {
+	// Monday of the week
+	WeekStart time.Time `json:"weekStart"`
+	Scans     int       `json:"scans"`
+	// Number of failures during the scans of the week
+	Failures int `json:"failures"`
+}
+
+// Type of cached files, which can be stored at a location of its own, with a budget of its own
+type CacheType string
+
+const (
+	// Thumbnails of photos and videos, stored at PHOTOVIEW_MEDIA_CACHE_THUMBNAILS
+	CacheTypeThumbnails CacheType = "THUMBNAILS"
+	// High resolution versions of photos that browsers can't display, stored at PHOTOVIEW_MEDIA_CACHE_WEB_VERSIONS
+	CacheTypeWebVersions CacheType = "WEB_VERSIONS"
+	// Videos transcoded to a format browsers can play, stored at PHOTOVIEW_MEDIA_CACHE_VIDEO_TRANSCODES
+	CacheTypeVideoTranscodes CacheType = "VIDEO_TRANSCODES"
+)
+
+var AllCacheType = []CacheType{
+	CacheTypeThumbnails,
+	CacheTypeWebVersions,
+	CacheTypeVideoTranscodes,
+}
+
+func (e CacheType) IsValid() bool {
+	switch e {
+	case CacheTypeThumbnails, CacheTypeWebVersions, CacheTypeVideoTranscodes:
+		return true
+	}
+	return false
+}
+
+func (e CacheType) String() string {
+	return string(e)
+}
+
+func (e *CacheType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CacheType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CacheType", str)
+	}
+	return nil
+}
+
+func (e CacheType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Heavy or experimental parts of the server, that can be enabled or disabled by an admin
+type Feature string
+
+const (
+	// Detection and grouping of faces in photos
+	FeatureFaceRecognition Feature = "FACE_RECOGNITION"
+	// Encoding of videos with ffmpeg
+	FeatureVideoTranscoding Feature = "VIDEO_TRANSCODING"
+	// Processing of raw photos with darktable
+	FeatureRawProcessing Feature = "RAW_PROCESSING"
+	// DLNA media server
+	FeatureDlna Feature = "DLNA"
+	// Scaling of photos down to thumbnails on the graphics card
+	FeatureGpuThumbnails Feature = "GPU_THUMBNAILS"
+)
+
+var AllFeature = []Feature{
+	FeatureFaceRecognition,
+	FeatureVideoTranscoding,
+	FeatureRawProcessing,
+	FeatureDlna,
+	FeatureGpuThumbnails,
+}
+
+func (e Feature) IsValid() bool {
+	switch e {
+	case FeatureFaceRecognition, FeatureVideoTranscoding, FeatureRawProcessing, FeatureDlna, FeatureGpuThumbnails:
+		return true
+	}
+	return false
+}
+
+func (e Feature) String() string {
+	return string(e)
+}
+
+func (e *Feature) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Feature(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Feature", str)
+	}
+	return nil
+}
+
+func (e Feature) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// A photo service that a library can be imported from
+type ImportSource string
+
+const (
+	// A Google Photos export from Google Takeout, either as a zip archive or extracted
+	ImportSourceGoogleTakeout ImportSource = "GoogleTakeout"
+	// An export from Apple Photos or iCloud, made either by the Photos app with XMP sidecars or by osxphotos
+	// with XMP or json sidecars. Directories become albums, and a rating of 5 marks a favorite
+	ImportSourceApplePhotos ImportSource = "ApplePhotos"
+)
+
+var AllImportSource = []ImportSource{
+	ImportSourceGoogleTakeout,
+	ImportSourceApplePhotos,
+}
+
+func (e ImportSource) IsValid() bool {
+	switch e {
+	case ImportSourceGoogleTakeout, ImportSourceApplePhotos:
+		return true
+	}
+	return false
+}
+
+func (e ImportSource) String() string {
+	return string(e)
+}
+
+func (e *ImportSource) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImportSource(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImportSource", str)
+	}
+	return nil
+}
+
+func (e ImportSource) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ImportStatus string
+
+const (
+	ImportStatusPending   ImportStatus = "Pending"
+	ImportStatusRunning   ImportStatus = "Running"
+	ImportStatusCompleted ImportStatus = "Completed"
+	ImportStatusFailed    ImportStatus = "Failed"
+)
+
+var AllImportStatus = []ImportStatus{
+	ImportStatusPending,
+	ImportStatusRunning,
+	ImportStatusCompleted,
+	ImportStatusFailed,
+}
+
+func (e ImportStatus) IsValid() bool {
+	switch e {
+	case ImportStatusPending, ImportStatusRunning, ImportStatusCompleted, ImportStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e ImportStatus) String() string {
+	return string(e)
+}
+
+func (e *ImportStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImportStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImportStatus", str)
+	}
+	return nil
+}
+
+func (e ImportStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 // Supported language translations of the user interface
 type LanguageTranslation string
 
@@ -174,6 +599,205 @@ func (e LanguageTranslation) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
+// Severity of the messages logged by the server
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "DEBUG"
+	LogLevelInfo  LogLevel = "INFO"
+	LogLevelWarn  LogLevel = "WARN"
+	LogLevelError LogLevel = "ERROR"
+)
+
+var AllLogLevel = []LogLevel{
+	LogLevelDebug,
+	LogLevelInfo,
+	LogLevelWarn,
+	LogLevelError,
+}
+
+func (e LogLevel) IsValid() bool {
+	switch e {
+	case LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+		return true
+	}
+	return false
+}
+
+func (e LogLevel) String() string {
+	return string(e)
+}
+
+func (e *LogLevel) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = LogLevel(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid LogLevel", str)
+	}
+	return nil
+}
+
+func (e LogLevel) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Housekeeping tasks run while the server is in maintenance mode
+type MaintenanceTask string
+
+const (
+	// Delete cached files of albums and media that no longer exist, and enforce the cache budget
+	MaintenanceTaskCacheCleanup MaintenanceTask = "CACHE_CLEANUP"
+	// Check that the original and cached files of all media exist
+	MaintenanceTaskIntegrityCheck MaintenanceTask = "INTEGRITY_CHECK"
+	// Reclaim unused space in the database
+	MaintenanceTaskVacuum MaintenanceTask = "VACUUM"
+)
+
+var AllMaintenanceTask = []MaintenanceTask{
+	MaintenanceTaskCacheCleanup,
+	MaintenanceTaskIntegrityCheck,
+	MaintenanceTaskVacuum,
+}
+
+func (e MaintenanceTask) IsValid() bool {
+	switch e {
+	case MaintenanceTaskCacheCleanup, MaintenanceTaskIntegrityCheck, MaintenanceTaskVacuum:
+		return true
+	}
+	return false
+}
+
+func (e MaintenanceTask) String() string {
+	return string(e)
+}
+
+func (e *MaintenanceTask) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MaintenanceTask(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MaintenanceTask", str)
+	}
+	return nil
+}
+
+func (e MaintenanceTask) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Kinds of destinations notifications can be sent to
+type NotificationChannelKind string
+
+const (
+	// Email sent through the SMTP server configured with PHOTOVIEW_SMTP_HOST
+	NotificationChannelKindEmail NotificationChannelKind = "EMAIL"
+	// Push notification through a Gotify server
+	NotificationChannelKindGotify NotificationChannelKind = "GOTIFY"
+	// Push notification to a ntfy topic
+	NotificationChannelKindNtfy NotificationChannelKind = "NTFY"
+	// JSON message posted to an url
+	NotificationChannelKindWebhook NotificationChannelKind = "WEBHOOK"
+)
+
+var AllNotificationChannelKind = []NotificationChannelKind{
+	NotificationChannelKindEmail,
+	NotificationChannelKindGotify,
+	NotificationChannelKindNtfy,
+	NotificationChannelKindWebhook,
+}
+
+func (e NotificationChannelKind) IsValid() bool {
+	switch e {
+	case NotificationChannelKindEmail, NotificationChannelKindGotify, NotificationChannelKindNtfy, NotificationChannelKindWebhook:
+		return true
+	}
+	return false
+}
+
+func (e NotificationChannelKind) String() string {
+	return string(e)
+}
+
+func (e *NotificationChannelKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationChannelKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationChannelKind", str)
+	}
+	return nil
+}
+
+func (e NotificationChannelKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Events users can be notified of
+type NotificationEvent string
+
+const (
+	// Summary of the media added to the albums of the user, when the scanner has finished
+	NotificationEventScanCompleted NotificationEvent = "SCAN_COMPLETED"
+	// Summary of the failures in the report of a scan, only sent to admins
+	NotificationEventErrorDigest NotificationEvent = "ERROR_DIGEST"
+	// A share link of media or an album of the user has been created
+	NotificationEventNewShare NotificationEvent = "NEW_SHARE"
+	// Daily reminder of media taken on the same day in previous years
+	NotificationEventMemories NotificationEvent = "MEMORIES"
+	// A share link of the user has been opened
+	NotificationEventShareViewed NotificationEvent = "SHARE_VIEWED"
+	// An import started by the user has finished
+	NotificationEventImportCompleted NotificationEvent = "IMPORT_COMPLETED"
+)
+
+var AllNotificationEvent = []NotificationEvent{
+	NotificationEventScanCompleted,
+	NotificationEventErrorDigest,
+	NotificationEventNewShare,
+	NotificationEventMemories,
+	NotificationEventShareViewed,
+	NotificationEventImportCompleted,
+}
+
+func (e NotificationEvent) IsValid() bool {
+	switch e {
+	case NotificationEventScanCompleted, NotificationEventErrorDigest, NotificationEventNewShare, NotificationEventMemories, NotificationEventShareViewed, NotificationEventImportCompleted:
+		return true
+	}
+	return false
+}
+
+func (e NotificationEvent) String() string {
+	return string(e)
+}
+
+func (e *NotificationEvent) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationEvent", str)
+	}
+	return nil
+}
+
+func (e NotificationEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 // Specified the type a particular notification is of
 type NotificationType string
 
@@ -265,6 +889,56 @@ func (e OrderDirection) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
+// Status of retrieving an original media file from cold storage
+type RetrievalStatus string
+
+const (
+	// The retrieval is waiting in the queue
+	RetrievalStatusPending RetrievalStatus = "Pending"
+	// The original is currently being read from cold storage
+	RetrievalStatusInProgress RetrievalStatus = "InProgress"
+	// The original has been staged in the cache and can be downloaded
+	RetrievalStatusCompleted RetrievalStatus = "Completed"
+	// The retrieval failed, requesting it again will retry it
+	RetrievalStatusFailed RetrievalStatus = "Failed"
+)
+
+var AllRetrievalStatus = []RetrievalStatus{
+	RetrievalStatusPending,
+	RetrievalStatusInProgress,
+	RetrievalStatusCompleted,
+	RetrievalStatusFailed,
+}
+
+func (e RetrievalStatus) IsValid() bool {
+	switch e {
+	case RetrievalStatusPending, RetrievalStatusInProgress, RetrievalStatusCompleted, RetrievalStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e RetrievalStatus) String() string {
+	return string(e)
+}
+
+func (e *RetrievalStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RetrievalStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RetrievalStatus", str)
+	}
+	return nil
+}
+
+func (e RetrievalStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 // Supported downsampling filters for thumbnail generation
 type ThumbnailFilter string
 
@@ -314,3 +988,100 @@ func (e *ThumbnailFilter) UnmarshalGQL(v interface{}) error {
 func (e ThumbnailFilter) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
+
+type WatermarkPosition string
+
+const (
+	WatermarkPositionTopLeft     WatermarkPosition = "TOP_LEFT"
+	WatermarkPositionTopRight    WatermarkPosition = "TOP_RIGHT"
+	WatermarkPositionCenter      WatermarkPosition = "CENTER"
+	WatermarkPositionBottomLeft  WatermarkPosition = "BOTTOM_LEFT"
+	WatermarkPositionBottomRight WatermarkPosition = "BOTTOM_RIGHT"
+)
+
+var AllWatermarkPosition = []WatermarkPosition{
+	WatermarkPositionTopLeft,
+	WatermarkPositionTopRight,
+	WatermarkPositionCenter,
+	WatermarkPositionBottomLeft,
+	WatermarkPositionBottomRight,
+}
+
+func (e WatermarkPosition) IsValid() bool {
+	switch e {
+	case WatermarkPositionTopLeft, WatermarkPositionTopRight, WatermarkPositionCenter, WatermarkPositionBottomLeft, WatermarkPositionBottomRight:
+		return true
+	}
+	return false
+}
+
+func (e WatermarkPosition) String() string {
+	return string(e)
+}
+
+func (e *WatermarkPosition) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WatermarkPosition(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WatermarkPosition", str)
+	}
+	return nil
+}
+
+func (e WatermarkPosition) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Events in the library that webhooks can be notified of
+type WebhookEvent string
+
+const (
+	// The scanner has finished all queued jobs
+	WebhookEventScanCompleted WebhookEvent = "SCAN_COMPLETED"
+	// New media has been added to an album, by the scanner or an upload
+	WebhookEventMediaAdded WebhookEvent = "MEDIA_ADDED"
+	// A share has been opened
+	WebhookEventShareAccessed WebhookEvent = "SHARE_ACCESSED"
+	// A new user has been created
+	WebhookEventUserCreated WebhookEvent = "USER_CREATED"
+)
+
+var AllWebhookEvent = []WebhookEvent{
+	WebhookEventScanCompleted,
+	WebhookEventMediaAdded,
+	WebhookEventShareAccessed,
+	WebhookEventUserCreated,
+}
+
+func (e WebhookEvent) IsValid() bool {
+	switch e {
+	case WebhookEventScanCompleted, WebhookEventMediaAdded, WebhookEventShareAccessed, WebhookEventUserCreated:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEvent) String() string {
+	return string(e)
+}
+
+func (e *WebhookEvent) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEvent", str)
+	}
+	return nil
+}
+
+func (e WebhookEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
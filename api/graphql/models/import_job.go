@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// ImportJob tracks the import of a library exported from another photo service, such as Google Photos
+type ImportJob struct {
+	Model
+	Source     ImportSource `gorm:"not null"`
+	SourcePath string       `gorm:"not null"`
+	UserID     int          `gorm:"not null;index"`
+	User       *User        `gorm:"constraint:OnDelete:CASCADE;"`
+	// AlbumID is the album the imported media is placed inside
+	AlbumID int    `gorm:"not null;index"`
+	Album   *Album `gorm:"constraint:OnDelete:CASCADE;"`
+	// Layout is the folder structure imported media is placed in, relative to the album
+	Layout        string       `gorm:"not null"`
+	Status        ImportStatus `gorm:"not null;index"`
+	ImportedCount int          `gorm:"not null;default:0"`
+	SkippedCount  int          `gorm:"not null;default:0"`
+	Error         *string
+	FinishedAt    *time.Time
+}
+
+func (j *ImportJob) StartedAt() time.Time {
+	return j.CreatedAt
+}
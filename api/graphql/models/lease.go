@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Lease gives an instance of the server the exclusive right to run a job for a while,
+// when multiple instances share the same database
+type Lease struct {
+	Name string `gorm:"primaryKey"`
+	// Holder is the id of the instance holding the lease
+	Holder    string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UpdatedAt time.Time
+}
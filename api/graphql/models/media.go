@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"os"
 	"path"
 	"strconv"
 	"strings"
@@ -30,6 +31,15 @@ type Media struct {
 	SideCarHash     *string      `gorm:"unique"`
 	Faces           []*ImageFace `gorm:"constraint:OnDelete:CASCADE;"`
 	Blurhash        *string      `gorm:""`
+	// ContentHash is the SHA-256 hash of the original file and its sidecar, which addresses its files in the media cache
+	ContentHash *string `gorm:"size:64;index"`
+	// FileModTime and FileSize are the modification time and size of the original file when the media was last processed,
+	// rescans skip media whose file still has them
+	FileModTime *time.Time
+	FileSize    *int64 `gorm:"index"`
+	// MissingSince is set when the file of the media is no longer found where it was, it is kept until the scan has
+	// finished, so that it is found again by its content hash if it has been moved or renamed
+	MissingSince *time.Time `gorm:"index"`
 }
 
 func (Media) TableName() string {
@@ -77,10 +87,26 @@ func (m *Media) GetHighRes() (*MediaURL, error) {
 	return nil, nil
 }
 
+// CachePath returns the directory the cached files of the media are generated in, which is shared by all media with the same content.
+// Media that has not been hashed yet uses the directory of the media inside the directory of its album.
 func (m *Media) CachePath() (string, error) {
+	if m.ContentHash != nil {
+		return utils.CachePathForContent(utils.MediaCachePath(), *m.ContentHash)
+	}
+
 	return utils.CachePathForMedia(m.AlbumID, m.ID)
 }
 
+// CachePathForPurpose returns the directory the cached files of the given purpose of the media are generated in,
+// which is inside the cache location of the type of the files, once the content of the media has been hashed
+func (m *Media) CachePathForPurpose(purpose MediaPurpose) (string, error) {
+	if m.ContentHash != nil {
+		return utils.CachePathForContent(purpose.CachePath(), *m.ContentHash)
+	}
+
+	return m.CachePath()
+}
+
 type MediaType string
 
 const (
@@ -101,6 +127,10 @@ const (
 	MediaOriginal  MediaPurpose = "original"
 	VideoWeb       MediaPurpose = "video-web"
 	VideoThumbnail MediaPurpose = "video-thumbnail"
+	// MotionVideo is the video embedded in the file of a motion photo, extracted to the cache
+	MotionVideo MediaPurpose = "motion-video"
+	// VideoSprite is the image of the scrub sprite of a video, see VideoScrubSprite
+	VideoSprite MediaPurpose = "video-sprite"
 )
 
 type MediaURL struct {
@@ -113,12 +143,16 @@ type MediaURL struct {
 	Purpose     MediaPurpose `gorm:"not null;index"`
 	ContentType string       `gorm:"not null"`
 	FileSize    int64        `gorm:"not null"`
+	// LastAccessedAt is used to evict the least recently used files when the cache exceeds its budget
+	LastAccessedAt *time.Time
+	// CacheKey is the key of the cache entry of the file, if it is in the content addressed part of the cache
+	CacheKey *string `gorm:"size:191;index"`
 }
 
 func (p *MediaURL) URL() string {
 
 	imageURL := utils.ApiEndpointUrl()
-	if p.Purpose != VideoWeb {
+	if p.Purpose != VideoWeb && p.Purpose != MotionVideo {
 		imageURL.Path = path.Join(imageURL.Path, "photo", p.MediaName)
 	} else {
 		imageURL.Path = path.Join(imageURL.Path, "video", p.MediaName)
@@ -130,11 +164,18 @@ func (p *MediaURL) URL() string {
 func (p *MediaURL) CachedPath() (string, error) {
 	var cachedPath string
 
+	if p.CacheKey != nil {
+		return path.Join(p.Purpose.CachePath(), *p.CacheKey), nil
+	}
+
 	if p.Media == nil {
 		return "", errors.New("mediaURL.Media is nil")
 	}
 
-	if p.Purpose == PhotoThumbnail || p.Purpose == PhotoHighRes || p.Purpose == VideoThumbnail || p.Purpose == VideoWeb {
+	_, scaled := p.Purpose.ScaledSize()
+	_, avifVariant := p.Purpose.AVIFVariantOf()
+	if scaled || avifVariant || p.Purpose == PhotoThumbnail || p.Purpose == PhotoHighRes || p.Purpose == VideoThumbnail || p.Purpose == VideoWeb || p.Purpose == MotionVideo ||
+		p.Purpose == VideoSprite {
 		cachedPath = path.Join(utils.MediaCachePath(), strconv.Itoa(int(p.Media.AlbumID)), strconv.Itoa(int(p.MediaID)), p.MediaName)
 	} else if p.Purpose == MediaOriginal {
 		cachedPath = p.Media.Path
@@ -145,6 +186,27 @@ func (p *MediaURL) CachedPath() (string, error) {
 	return cachedPath, nil
 }
 
+// CachedFileComplete returns whether the cached file of the media url exists, with the size recorded when it was generated.
+// A file of another size has not been written completely, such as when the server crashed while generating it.
+func (p *MediaURL) CachedFileComplete() bool {
+	cachedPath, err := p.CachedPath()
+	if err != nil {
+		return false
+	}
+
+	fileInfo, err := os.Stat(cachedPath)
+	if err != nil {
+		return false
+	}
+
+	// The original can change on disk, and is rescanned when it does
+	if p.Purpose == MediaOriginal || p.FileSize == 0 {
+		return true
+	}
+
+	return fileInfo.Size() == p.FileSize
+}
+
 func SanitizeMediaName(mediaName string) string {
 	result := mediaName
 	result = strings.ReplaceAll(result, "/", "")
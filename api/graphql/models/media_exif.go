@@ -18,8 +18,9 @@ type MediaEXIF struct {
 	Flash           *int64
 	Orientation     *int64
 	ExposureProgram *int64
-	GPSLatitude     *float64
-	GPSLongitude    *float64
+	GPSLatitude     *float64 `gorm:"index"`
+	GPSLongitude    *float64 `gorm:"index"`
+	PageCount       *int64
 }
 
 func (MediaEXIF) TableName() string {
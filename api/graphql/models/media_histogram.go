@@ -0,0 +1,72 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+
+	"github.com/photoview/photoview/api/database/drivers"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// HistogramBinCount is the number of bins of each channel of a histogram, one for each 8-bit value
+const HistogramBinCount = 256
+
+// MediaHistogram is the distribution of the brightness and colors of a photo,
+// computed from its thumbnail when the photo is processed
+type MediaHistogram struct {
+	Model
+	MediaID   int           `gorm:"not null;uniqueIndex"`
+	Media     *Media        `gorm:"constraint:OnDelete:CASCADE;"`
+	Luminance HistogramBins `gorm:"not null"`
+	Red       HistogramBins `gorm:"not null"`
+	Green     HistogramBins `gorm:"not null"`
+	Blue      HistogramBins `gorm:"not null"`
+	// MeanLuminance is the average brightness of the photo, from 0 for black to 1 for white
+	MeanLuminance float64 `gorm:"not null;index"`
+}
+
+// HistogramBins is the number of pixels of each value of a channel, from dark to bright
+type HistogramBins [HistogramBinCount]int32
+
+// Ints returns the counts of the bins, as they are returned by the api
+func (bins HistogramBins) Ints() []int {
+	result := make([]int, len(bins))
+	for i, count := range bins {
+		result[i] = int(count)
+	}
+	return result
+}
+
+// GormDataType datatype used in database
+func (HistogramBins) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch drivers.GetDatabaseDriverType(db) {
+	case drivers.MYSQL, drivers.SQLITE:
+		return "BLOB"
+	case drivers.POSTGRES:
+		return "BYTEA"
+	}
+	return ""
+}
+
+// Scan tells GORM how to convert database data to Go format
+func (bins *HistogramBins) Scan(value interface{}) error {
+	byteValue, ok := value.([]byte)
+	if !ok {
+		return errors.Errorf("invalid histogram bins of type %T", value)
+	}
+
+	return binary.Read(bytes.NewReader(byteValue), binary.LittleEndian, bins)
+}
+
+// Value tells GORM how to save into the database
+func (bins HistogramBins) Value() (driver.Value, error) {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, bins); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,51 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMediaHistogramBins(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	if !assert.NoError(t, db.Save(&album).Error) {
+		return
+	}
+
+	media := models.Media{Title: "photo.jpg", Path: "/photos/photo.jpg", AlbumID: album.ID, Type: models.MediaTypePhoto}
+	if !assert.NoError(t, db.Save(&media).Error) {
+		return
+	}
+
+	histogram := models.MediaHistogram{MediaID: media.ID, MeanLuminance: 0.5}
+	histogram.Luminance[0] = 12
+	histogram.Red[255] = 1 << 20
+	histogram.Blue[128] = 7
+	if !assert.NoError(t, db.Save(&histogram).Error) {
+		return
+	}
+
+	var loaded models.MediaHistogram
+	if assert.NoError(t, db.First(&loaded, histogram.ID).Error) {
+		assert.Equal(t, histogram.Luminance, loaded.Luminance)
+		assert.Equal(t, histogram.Red, loaded.Red)
+		assert.Equal(t, histogram.Green, loaded.Green)
+		assert.Equal(t, histogram.Blue, loaded.Blue)
+		assert.Equal(t, 0.5, loaded.MeanLuminance)
+
+		ints := loaded.Red.Ints()
+		assert.Len(t, ints, models.HistogramBinCount)
+		assert.Equal(t, 1<<20, ints[255])
+	}
+
+	// The histogram is deleted along with the media
+	if assert.NoError(t, db.Delete(&media).Error) {
+		var count int64
+		assert.NoError(t, db.Model(&models.MediaHistogram{}).Count(&count).Error)
+		assert.Zero(t, count)
+	}
+}
@@ -0,0 +1,9 @@
+package models
+
+// MediaPerson is the name of a person appearing in a media, as tagged in the service the media was imported from
+type MediaPerson struct {
+	Model
+	MediaID int    `gorm:"not null;index"`
+	Media   *Media `gorm:"constraint:OnDelete:CASCADE;"`
+	Name    string `gorm:"not null"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// MediaRetrieval tracks the asynchronous retrieval of an original media file from cold storage
+type MediaRetrieval struct {
+	Model
+	MediaID     int             `gorm:"not null;unique"`
+	Media       *Media          `gorm:"constraint:OnDelete:CASCADE;"`
+	Status      RetrievalStatus `gorm:"not null;index"`
+	Error       *string
+	CompletedAt *time.Time
+}
+
+func (r *MediaRetrieval) RequestedAt() time.Time {
+	return r.CreatedAt
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// MediaRetry is a media that failed to be processed, such as a file with corrupt exif or a file that could not be read,
+// which is processed again with an exponential backoff until it succeeds or the attempts run out
+type MediaRetry struct {
+	Model
+	AlbumID       int    `gorm:"not null;index"`
+	Album         *Album `gorm:"constraint:OnDelete:CASCADE;"`
+	MediaPath     string `gorm:"not null"`
+	MediaPathHash string `gorm:"not null;unique"`
+	// Attempts is the number of times the media has been retried
+	Attempts  int    `gorm:"not null;default:0"`
+	LastError string `gorm:"type:text;not null"`
+	// NextAttemptAt is when the media is retried next, nil once the attempts have run out
+	NextAttemptAt *time.Time `gorm:"index"`
+}
+
+func (r *MediaRetry) FailedAt() time.Time {
+	return r.UpdatedAt
+}
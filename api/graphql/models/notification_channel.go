@@ -0,0 +1,53 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// NotificationChannel is a destination a user receives notifications at, such as an email address or a ntfy topic
+type NotificationChannel struct {
+	Model
+	UserID int                     `gorm:"not null;index"`
+	User   User                    `gorm:"constraint:OnDelete:CASCADE;"`
+	Kind   NotificationChannelKind `gorm:"not null"`
+	// Target is the email address, or the url of the Gotify server, ntfy topic or webhook
+	Target string `gorm:"not null"`
+	// Token authenticates to Gotify or ntfy, it is never exposed through the api
+	Token *string
+	// EventNames are the events the channel is notified of, separated by commas
+	EventNames     string `gorm:"column:events;not null"`
+	LastDeliveryAt *time.Time
+	LastError      *string
+}
+
+func (c *NotificationChannel) Events() []NotificationEvent {
+	events := make([]NotificationEvent, 0)
+	for _, name := range strings.Split(c.EventNames, ",") {
+		if event := NotificationEvent(name); event.IsValid() {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+func (c *NotificationChannel) SetEvents(events []NotificationEvent) {
+	names := make([]string, 0, len(events))
+	for _, event := range events {
+		names = append(names, event.String())
+	}
+
+	c.EventNames = strings.Join(names, ",")
+}
+
+// SubscribedTo returns whether the channel should be notified of the event
+func (c *NotificationChannel) SubscribedTo(event NotificationEvent) bool {
+	for _, subscribed := range c.Events() {
+		if subscribed == event {
+			return true
+		}
+	}
+
+	return false
+}
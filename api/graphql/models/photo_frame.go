@@ -0,0 +1,40 @@
+package models
+
+import (
+	"path"
+
+	"github.com/photoview/photoview/api/utils"
+)
+
+// PhotoFrame is a slideshow for a digital photo frame, such as a Raspberry Pi with a screen.
+// Frames can not log in, so the slideshow is fetched with the token of the frame instead.
+type PhotoFrame struct {
+	Model
+	Title  string `gorm:"not null"`
+	Token  string `gorm:"not null;unique;size:32"`
+	UserID int    `gorm:"not null;index"`
+	User   *User  `gorm:"constraint:OnDelete:CASCADE;"`
+	// AlbumID is the album to show, the favorites of the user are shown when it is nil
+	AlbumID          *int   `gorm:"index"`
+	Album            *Album `gorm:"constraint:OnDelete:CASCADE;"`
+	IncludeSubAlbums bool   `gorm:"not null;default:false"`
+	// MaxSize is the largest width or height of the images served to the frame, in pixels
+	MaxSize int `gorm:"not null"`
+	// Interval is the number of seconds each image is shown
+	Interval int  `gorm:"not null"`
+	Shuffle  bool `gorm:"not null;default:false"`
+}
+
+func (f *PhotoFrame) ImageURL() string {
+	return f.frameURL("image")
+}
+
+func (f *PhotoFrame) PlaylistURL() string {
+	return f.frameURL("playlist.json")
+}
+
+func (f *PhotoFrame) frameURL(name string) string {
+	frameURL := utils.ApiEndpointUrl()
+	frameURL.Path = path.Join(frameURL.Path, "frame", f.Token, name)
+	return frameURL.String()
+}
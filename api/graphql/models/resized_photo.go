@@ -0,0 +1,27 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResizedPhotoPrefix is the prefix of the purposes of the images of photos resized on demand to the size requested by a client.
+// They are only recorded as entries of the cache manifest, not as media urls, so they are removed once they have not been
+// requested for a while.
+const ResizedPhotoPrefix = "resized-"
+
+// ResizedPhotoPurpose returns the purpose of an image resized to the given size, where zero is an unbounded dimension.
+// Images that cover the size are cropped to it, others fit inside it.
+func ResizedPhotoPurpose(width, height int, cover bool) MediaPurpose {
+	purpose := fmt.Sprintf("%s%dx%d", ResizedPhotoPrefix, width, height)
+	if cover {
+		purpose += "-cover"
+	}
+
+	return MediaPurpose(purpose)
+}
+
+// IsResized returns whether the purpose is of an image resized on demand
+func (p MediaPurpose) IsResized() bool {
+	return strings.HasPrefix(string(p), ResizedPhotoPrefix)
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/photoview/photoview/api/utils"
+)
+
+// ScaledPhotoPrefix is the prefix of the purposes of the scaled copies of photos, which are followed by the size of their longest side
+const ScaledPhotoPrefix = "scaled-"
+
+// ScaledPhotoPurpose returns the purpose of the scaled copies of photos, whose longest side is the given number of pixels
+func ScaledPhotoPurpose(size int) MediaPurpose {
+	return MediaPurpose(ScaledPhotoPrefix + strconv.Itoa(size))
+}
+
+// ScaledSize returns the size of the longest side of the scaled copies of photos of the purpose,
+// and whether the purpose is of a scaled copy
+func (p MediaPurpose) ScaledSize() (int, bool) {
+	if !strings.HasPrefix(string(p), ScaledPhotoPrefix) {
+		return 0, false
+	}
+
+	size, err := strconv.Atoi(strings.TrimPrefix(string(p), ScaledPhotoPrefix))
+	if err != nil || size <= 0 {
+		return 0, false
+	}
+
+	return size, true
+}
+
+// ScaledPhotoSizes returns the sizes of the longest sides of the scaled copies generated of photos, besides their thumbnails,
+// as configured by a comma separated list of sizes in pixels. Invalid sizes are ignored.
+func ScaledPhotoSizes() []int {
+	sizes := make([]int, 0)
+	for _, value := range strings.Split(utils.EnvThumbnailSizes.GetValue(), ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || size <= 0 {
+			continue
+		}
+
+		duplicate := false
+		for _, other := range sizes {
+			duplicate = duplicate || other == size
+		}
+		if !duplicate {
+			sizes = append(sizes, size)
+		}
+	}
+
+	sort.Ints(sizes)
+	return sizes
+}
+
+// ScaledPhotoPurposes returns the purposes of the scaled copies of photos of the configured sizes
+func ScaledPhotoPurposes() []MediaPurpose {
+	sizes := ScaledPhotoSizes()
+	purposes := make([]MediaPurpose, len(sizes))
+	for i, size := range sizes {
+		purposes[i] = ScaledPhotoPurpose(size)
+	}
+
+	return purposes
+}
+
+// ImageForViewport returns the smallest image of the media urls of the media that fills a viewport of the given size in pixels,
+// when scaled to fit inside it, or the largest image when none of them do. Images are chosen from the thumbnails,
+// the scaled copies, the high resolution version and the original of photos that have no high resolution version,
+// as the originals of the others can't be displayed by browsers.
+func (m *Media) ImageForViewport(urls []*MediaURL, width, height int) *MediaURL {
+	hasHighRes := false
+	for _, url := range urls {
+		hasHighRes = hasHighRes || url.Purpose == PhotoHighRes
+	}
+
+	var smallestFilling, largest *MediaURL
+	for _, url := range urls {
+		_, scaled := url.Purpose.ScaledSize()
+		image := scaled || url.Purpose == PhotoThumbnail || url.Purpose == VideoThumbnail || url.Purpose == PhotoHighRes ||
+			(url.Purpose == MediaOriginal && !hasHighRes && m.Type == MediaTypePhoto)
+		if !image {
+			continue
+		}
+
+		pixels := url.Width * url.Height
+		if (url.Width >= width || url.Height >= height) && (smallestFilling == nil || pixels < smallestFilling.Width*smallestFilling.Height) {
+			smallestFilling = url
+		}
+		if largest == nil || pixels > largest.Width*largest.Height {
+			largest = url
+		}
+	}
+
+	if smallestFilling != nil {
+		return smallestFilling
+	}
+
+	return largest
+}
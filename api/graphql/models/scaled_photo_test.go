@@ -0,0 +1,53 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaledPhotoSizes(t *testing.T) {
+	t.Setenv(utils.EnvThumbnailSizes.GetName(), "2048, 256,invalid,-5,256")
+
+	assert.Equal(t, []int{256, 2048}, models.ScaledPhotoSizes())
+	assert.Equal(t, []models.MediaPurpose{"scaled-256", "scaled-2048"}, models.ScaledPhotoPurposes())
+
+	size, scaled := models.ScaledPhotoPurpose(256).ScaledSize()
+	assert.True(t, scaled)
+	assert.Equal(t, 256, size)
+
+	_, scaled = models.PhotoThumbnail.ScaledSize()
+	assert.False(t, scaled)
+
+	cacheType, _ := models.ScaledPhotoPurpose(256).CacheType()
+	assert.Equal(t, models.CacheTypeThumbnails, cacheType)
+	assert.Contains(t, models.CacheTypeThumbnails.Purposes(), models.ScaledPhotoPurpose(2048))
+}
+
+func TestImageForViewport(t *testing.T) {
+	original := &models.MediaURL{Purpose: models.MediaOriginal, Width: 6000, Height: 4000}
+	highRes := &models.MediaURL{Purpose: models.PhotoHighRes, Width: 6000, Height: 4000}
+	thumbnail := &models.MediaURL{Purpose: models.PhotoThumbnail, Width: 1024, Height: 682}
+	small := &models.MediaURL{Purpose: models.ScaledPhotoPurpose(256), Width: 256, Height: 170}
+	large := &models.MediaURL{Purpose: models.ScaledPhotoPurpose(2048), Width: 2048, Height: 1365}
+
+	photo := &models.Media{Type: models.MediaTypePhoto}
+	urls := []*models.MediaURL{original, highRes, thumbnail, small, large}
+
+	assert.Same(t, small, photo.ImageForViewport(urls, 200, 200))
+	assert.Same(t, thumbnail, photo.ImageForViewport(urls, 1000, 1000))
+	// A viewport taller than the photo is filled once the width of the photo fills it
+	assert.Same(t, large, photo.ImageForViewport(urls, 1920, 2000))
+	assert.Same(t, highRes, photo.ImageForViewport(urls, 3840, 2160))
+	assert.Same(t, highRes, photo.ImageForViewport(urls, 10000, 10000))
+
+	// Originals are only displayed when they can be displayed by browsers, which is when there's no high-res version
+	assert.Same(t, original, photo.ImageForViewport([]*models.MediaURL{original, thumbnail}, 3840, 2160))
+
+	video := &models.Media{Type: models.MediaTypeVideo}
+	videoThumbnail := &models.MediaURL{Purpose: models.VideoThumbnail, Width: 1024, Height: 576}
+	videoWeb := &models.MediaURL{Purpose: models.VideoWeb, Width: 1920, Height: 1080}
+	assert.Same(t, videoThumbnail, video.ImageForViewport([]*models.MediaURL{original, videoWeb, videoThumbnail}, 3840, 2160))
+}
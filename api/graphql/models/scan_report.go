@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ScanReport collects the failures of a scan, from the first album being scanned until the scanner is idle again
+type ScanReport struct {
+	Model
+	FinishedAt   *time.Time
+	FailureCount int `gorm:"not null;default:0"`
+}
+
+func (r *ScanReport) StartedAt() time.Time {
+	return r.CreatedAt
+}
+
+// ScanFailure is an error that occurred during a scan, such as a corrupt file or a failed video transcode
+type ScanFailure struct {
+	Model
+	ScanReportID int         `gorm:"not null;index"`
+	ScanReport   *ScanReport `gorm:"constraint:OnDelete:CASCADE;"`
+	// AlbumID is the album being scanned when the failure occurred, if any
+	AlbumID *int   `gorm:"index"`
+	Album   *Album `gorm:"constraint:OnDelete:SET NULL;"`
+	// MediaPath is the file that failed to be processed, if the failure is of a single file
+	MediaPath *string
+	// DirectoryPath is the directory that could not be read, if the failure is of a directory
+	DirectoryPath *string
+	Message       string `gorm:"type:text;not null"`
+}
+
+func (f *ScanFailure) OccurredAt() time.Time {
+	return f.CreatedAt
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// ScanStatistics are the totals of a finished scan, kept to show the growth of the library and the performance of scans over time
+type ScanStatistics struct {
+	Model
+	StartedAt     time.Time `gorm:"not null;index"`
+	FinishedAt    time.Time `gorm:"not null"`
+	AlbumsScanned int       `gorm:"not null;default:0"`
+	// FilesSeen is the number of media files found in the albums scanned, whether they were processed or unchanged
+	FilesSeen      int   `gorm:"not null;default:0"`
+	MediaAdded     int   `gorm:"not null;default:0"`
+	MediaRemoved   int   `gorm:"not null;default:0"`
+	BytesProcessed int64 `gorm:"not null;default:0"`
+	FailureCount   int   `gorm:"not null;default:0"`
+	// MediaCount is the number of media in the library once the scan had finished
+	MediaCount int64 `gorm:"not null;default:0"`
+}
+
+// Duration is the number of seconds the scan took
+func (s *ScanStatistics) Duration() float64 {
+	return s.FinishedAt.Sub(s.StartedAt).Seconds()
+}
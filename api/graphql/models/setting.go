@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Setting is a configuration value changed by an admin through the api.
+// It overrides the environment variable named by its key, without restarting the server.
+type Setting struct {
+	Key       string `gorm:"primaryKey;size:128"`
+	Value     string `gorm:"not null"`
+	UpdatedAt time.Time
+}
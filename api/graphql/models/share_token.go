@@ -2,6 +2,10 @@ package models
 
 import (
 	"time"
+
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
 )
 
 type ShareToken struct {
@@ -15,8 +19,47 @@ type ShareToken struct {
 	Album    *Album `gorm:"constraint:OnDelete:CASCADE;"`
 	MediaID  *int   `gorm:"index"`
 	Media    *Media `gorm:"constraint:OnDelete:CASCADE;"`
+	// StripMetadata removes GPS coordinates and other metadata from the media served through the share,
+	// nil uses the default of the site
+	StripMetadata *bool
 }
 
 func (share *ShareToken) Token() string {
 	return share.Value
 }
+
+// StripsMetadata returns whether metadata is removed from the media served through the share
+func (share *ShareToken) StripsMetadata() bool {
+	if share.StripMetadata != nil {
+		return *share.StripMetadata
+	}
+
+	return utils.EnvShareStripMetadata.GetBool()
+}
+
+// MediaSharesStripMetadata returns whether metadata of a media is hidden from the viewers of its shares,
+// which is the case if a share of the media, or of an album containing it, strips metadata
+func MediaSharesStripMetadata(db *gorm.DB, media *Media) (bool, error) {
+	albums, err := GetParentsFromAlbums(db, nil, media.AlbumID)
+	if err != nil {
+		return false, errors.Wrap(err, "get parent albums of media")
+	}
+
+	albumIDs := make([]int, len(albums))
+	for i, album := range albums {
+		albumIDs[i] = album.ID
+	}
+
+	var shares []*ShareToken
+	if err := db.Where("media_id = ? OR album_id IN (?)", media.ID, albumIDs).Find(&shares).Error; err != nil {
+		return false, errors.Wrap(err, "get shares of media")
+	}
+
+	for _, share := range shares {
+		if share.StripsMetadata() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
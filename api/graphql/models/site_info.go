@@ -7,10 +7,18 @@ import (
 )
 
 type SiteInfo struct {
-	InitialSetup         bool `gorm:"not null"`
-	PeriodicScanInterval int  `gorm:"not null"`
-	ConcurrentWorkers    int  `gorm:"not null"`
-	ThumbnailMethod   	 ThumbnailFilter  `gorm:"not null"`
+	InitialSetup         bool            `gorm:"not null"`
+	PeriodicScanInterval int             `gorm:"not null"`
+	ConcurrentWorkers    int             `gorm:"not null"`
+	ThumbnailMethod      ThumbnailFilter `gorm:"not null"`
+	// CacheBudget is the max size of the media cache in bytes, 0 means unlimited
+	CacheBudget int64 `gorm:"not null;default:0"`
+	// CacheWarningThreshold is the fraction of the cache budget, that when exceeded issues a warning
+	CacheWarningThreshold float64 `gorm:"not null;default:0.9"`
+	// Budgets of the types of cached files in bytes, which apply in addition to the budget of the whole cache, 0 means unlimited
+	ThumbnailsCacheBudget      int64 `gorm:"not null;default:0"`
+	WebVersionsCacheBudget     int64 `gorm:"not null;default:0"`
+	VideoTranscodesCacheBudget int64 `gorm:"not null;default:0"`
 }
 
 func (SiteInfo) TableName() string {
@@ -24,13 +32,29 @@ func DefaultSiteInfo(db *gorm.DB) SiteInfo {
 	}
 
 	return SiteInfo{
-		InitialSetup:         true,
-		PeriodicScanInterval: 0,
-		ConcurrentWorkers:    defaultConcurrentWorkers,
-		ThumbnailMethod:			ThumbnailFilterNearestNeighbor,
+		InitialSetup:          true,
+		PeriodicScanInterval:  0,
+		ConcurrentWorkers:     defaultConcurrentWorkers,
+		ThumbnailMethod:       ThumbnailFilterNearestNeighbor,
+		CacheBudget:           0,
+		CacheWarningThreshold: 0.9,
 	}
 }
 
+// CacheTypeBudget returns the budget of the cached files of the given type, and the column it is stored in
+func (info *SiteInfo) CacheTypeBudget(cacheType CacheType) (int64, string) {
+	switch cacheType {
+	case CacheTypeThumbnails:
+		return info.ThumbnailsCacheBudget, "thumbnails_cache_budget"
+	case CacheTypeWebVersions:
+		return info.WebVersionsCacheBudget, "web_versions_cache_budget"
+	case CacheTypeVideoTranscodes:
+		return info.VideoTranscodesCacheBudget, "video_transcodes_cache_budget"
+	}
+
+	return 0, ""
+}
+
 // GetSiteInfo gets the site info row from the database, and creates it if it does not exist
 func GetSiteInfo(db *gorm.DB) (*SiteInfo, error) {
 
@@ -23,6 +23,8 @@ func TestSiteInfo(t *testing.T) {
 	site_info.PeriodicScanInterval = 360
 	site_info.ConcurrentWorkers = 10
 	site_info.ThumbnailMethod = models.ThumbnailFilterLanczos
+	site_info.CacheBudget = 1024 * 1024
+	site_info.CacheWarningThreshold = 0.8
 
 	if !assert.NoError(t, db.Session(&gorm.Session{AllowGlobalUpdate: true}).Save(&site_info).Error) {
 		return
@@ -34,10 +36,12 @@ func TestSiteInfo(t *testing.T) {
 	}
 
 	assert.Equal(t, models.SiteInfo{
-		InitialSetup:         false,
-		PeriodicScanInterval: 360,
-		ConcurrentWorkers:    10,
-		ThumbnailMethod:    	models.ThumbnailFilterLanczos,
+		InitialSetup:          false,
+		PeriodicScanInterval:  360,
+		ConcurrentWorkers:     10,
+		ThumbnailMethod:       models.ThumbnailFilterLanczos,
+		CacheBudget:           1024 * 1024,
+		CacheWarningThreshold: 0.8,
 	}, *site_info)
 
 }
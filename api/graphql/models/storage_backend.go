@@ -0,0 +1,181 @@
+package models
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// StorageBackend is a named storage location, such as a local SSD or a NAS mount, that album subtrees can be mapped to
+type StorageBackend struct {
+	Model
+	Name string `gorm:"not null;unique"`
+	Path string `gorm:"not null"`
+	// Cold marks the backend as slow storage, where originals are retrieved asynchronously
+	Cold     bool             `gorm:"not null;default:false"`
+	Mappings []StorageMapping `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// StorageMapping maps the album subtree at AlbumPath to the directory SubPath of a storage backend
+type StorageMapping struct {
+	Model
+	AlbumPath        string          `gorm:"not null"`
+	SubPath          string          `gorm:"not null;default:''"`
+	StorageBackendID int             `gorm:"not null;index"`
+	StorageBackend   *StorageBackend `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// BackendPath returns the directory on the storage backend that the mapped album subtree is located in
+func (m *StorageMapping) BackendPath() string {
+	return path.Join(m.StorageBackend.Path, m.SubPath)
+}
+
+// storagePathResolver resolves album paths to the storage backends they are mapped to
+type storagePathResolver struct {
+	mappings []*StorageMapping
+}
+
+func newStoragePathResolver(db *gorm.DB) (*storagePathResolver, error) {
+	var mappings []*StorageMapping
+	if err := db.Joins("StorageBackend").Find(&mappings).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage mappings from database")
+	}
+
+	return &storagePathResolver{mappings: mappings}, nil
+}
+
+// resolve translates the album path to the location on the storage backend of the closest mapped subtree
+func (r *storagePathResolver) resolve(albumPath string) string {
+	var closest *StorageMapping
+	for _, mapping := range r.mappings {
+		if !pathContains(mapping.AlbumPath, albumPath) {
+			continue
+		}
+
+		if closest == nil || len(mapping.AlbumPath) > len(closest.AlbumPath) {
+			closest = mapping
+		}
+	}
+
+	if closest == nil {
+		return albumPath
+	}
+
+	relativePath := strings.TrimPrefix(albumPath, closest.AlbumPath)
+	return path.Join(closest.BackendPath(), relativePath)
+}
+
+// ResolveStoragePath translates an album path to the location of the storage backend it is mapped to.
+// If the path is not within a mapped subtree, it is returned as is.
+func ResolveStoragePath(db *gorm.DB, albumPath string) (string, error) {
+	resolver, err := newStoragePathResolver(db)
+	if err != nil {
+		return "", err
+	}
+
+	return resolver.resolve(path.Clean(albumPath)), nil
+}
+
+// MappedStorageDirectories returns the subtrees mapped to a storage backend, that are direct children of the given directory.
+// The result maps the directory names to their locations on the storage backends.
+func MappedStorageDirectories(db *gorm.DB, dirPath string) (map[string]string, error) {
+	resolver, err := newStoragePathResolver(db)
+	if err != nil {
+		return nil, err
+	}
+
+	mapped := make(map[string]string)
+	for _, mapping := range resolver.mappings {
+		if resolver.resolve(path.Dir(mapping.AlbumPath)) == dirPath {
+			mapped[path.Base(mapping.AlbumPath)] = mapping.BackendPath()
+		}
+	}
+
+	return mapped, nil
+}
+
+// MoveStoragePaths updates the paths of the albums and media located within oldPath, to be located within newPath instead.
+// It is used when the storage backend a subtree is mapped to changes, so scans find the existing albums at their new location,
+// instead of replacing them with new albums.
+func MoveStoragePaths(db *gorm.DB, oldPath string, newPath string) error {
+	if oldPath == newPath {
+		return nil
+	}
+
+	movedPath := func(p string) string {
+		return path.Join(newPath, strings.TrimPrefix(p, oldPath))
+	}
+
+	// The LIKE pattern is only used to narrow down the rows, the paths are compared exactly below
+	pattern := strings.TrimSuffix(oldPath, "/") + "/%"
+
+	var albums []*Album
+	if err := db.Where("path = ? OR path LIKE ?", oldPath, pattern).Find(&albums).Error; err != nil {
+		return errors.Wrap(err, "get albums of moved storage path")
+	}
+
+	for _, album := range albums {
+		if !pathContains(oldPath, album.Path) {
+			continue
+		}
+
+		album.Path = movedPath(album.Path)
+		album.PathHash = MD5Hash(album.Path)
+		if err := db.Model(album).Updates(map[string]interface{}{"path": album.Path, "path_hash": album.PathHash}).Error; err != nil {
+			return errors.Wrap(err, "move album to new storage path")
+		}
+	}
+
+	var media []*Media
+	if err := db.Where("path LIKE ?", pattern).Find(&media).Error; err != nil {
+		return errors.Wrap(err, "get media of moved storage path")
+	}
+
+	for _, m := range media {
+		if !pathContains(oldPath, m.Path) {
+			continue
+		}
+
+		m.Path = movedPath(m.Path)
+		m.PathHash = MD5Hash(m.Path)
+		if m.SideCarPath != nil && pathContains(oldPath, *m.SideCarPath) {
+			sideCarPath := movedPath(*m.SideCarPath)
+			m.SideCarPath = &sideCarPath
+		}
+
+		updates := map[string]interface{}{"path": m.Path, "path_hash": m.PathHash, "side_car_path": m.SideCarPath}
+		if err := db.Model(m).Updates(updates).Error; err != nil {
+			return errors.Wrap(err, "move media to new storage path")
+		}
+	}
+
+	return nil
+}
+
+// StorageBackendForPath returns the storage backend that the given path is located on, or nil if it is not on a configured backend
+func StorageBackendForPath(db *gorm.DB, filePath string) (*StorageBackend, error) {
+	var backends []*StorageBackend
+	if err := db.Find(&backends).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage backends from database")
+	}
+
+	var closest *StorageBackend
+	for _, backend := range backends {
+		if !pathContains(backend.Path, filePath) {
+			continue
+		}
+
+		if closest == nil || len(backend.Path) > len(closest.Path) {
+			closest = backend
+		}
+	}
+
+	return closest, nil
+}
+
+// pathContains returns true if path is equal to or located within the directory dir
+func pathContains(dir string, path string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
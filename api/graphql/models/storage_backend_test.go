@@ -0,0 +1,114 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageBackendMapping(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	ssd := models.StorageBackend{Name: "ssd", Path: "/mnt/ssd"}
+	nas := models.StorageBackend{Name: "nas", Path: "/mnt/nas", Cold: true}
+	if !assert.NoError(t, db.Create(&[]*models.StorageBackend{&ssd, &nas}).Error) {
+		return
+	}
+
+	mappings := []models.StorageMapping{
+		{AlbumPath: "/photos/recent", StorageBackendID: ssd.ID},
+		{AlbumPath: "/photos/archive", StorageBackendID: nas.ID, SubPath: "photo_archive"},
+		{AlbumPath: "/photos/archive/1990", StorageBackendID: ssd.ID, SubPath: "1990"},
+	}
+	if !assert.NoError(t, db.Create(&mappings).Error) {
+		return
+	}
+
+	t.Run("Resolve storage path", func(t *testing.T) {
+		cases := map[string]string{
+			"/photos":                  "/photos",
+			"/photos/recent":           "/mnt/ssd",
+			"/photos/recent/2021":      "/mnt/ssd/2021",
+			"/photos/recently":         "/photos/recently",
+			"/photos/archive/2001/img": "/mnt/nas/photo_archive/2001/img",
+			"/photos/archive/1990/img": "/mnt/ssd/1990/img",
+		}
+
+		for albumPath, expected := range cases {
+			resolved, err := models.ResolveStoragePath(db, albumPath)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, resolved, albumPath)
+		}
+	})
+
+	t.Run("Mapped storage directories", func(t *testing.T) {
+		mapped, err := models.MappedStorageDirectories(db, "/photos")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"recent":  "/mnt/ssd",
+			"archive": "/mnt/nas/photo_archive",
+		}, mapped)
+
+		mapped, err = models.MappedStorageDirectories(db, "/mnt/nas/photo_archive")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"1990": "/mnt/ssd/1990",
+		}, mapped)
+	})
+
+	t.Run("Cold storage backend", func(t *testing.T) {
+		archiveAlbum := models.Album{Title: "2001", Path: "/mnt/nas/photo_archive/2001"}
+		recentAlbum := models.Album{Title: "2021", Path: "/mnt/ssd/2021"}
+		assert.NoError(t, db.Create(&[]*models.Album{&archiveAlbum, &recentAlbum}).Error)
+
+		cold, err := archiveAlbum.InColdStorage(db)
+		assert.NoError(t, err)
+		assert.True(t, cold)
+
+		cold, err = recentAlbum.InColdStorage(db)
+		assert.NoError(t, err)
+		assert.False(t, cold)
+	})
+
+	t.Run("Move storage paths", func(t *testing.T) {
+		holiday := models.Album{Title: "holiday", Path: "/photos/holiday"}
+		beach := models.Album{Title: "beach", Path: "/photos/holiday/beach"}
+		other := models.Album{Title: "holidays", Path: "/photos/holidays"}
+		if !assert.NoError(t, db.Create(&[]*models.Album{&holiday, &beach, &other}).Error) {
+			return
+		}
+
+		sideCarPath := "/photos/holiday/beach/sunset.jpg.xmp"
+		media := []*models.Media{
+			{Title: "sunset.jpg", Path: "/photos/holiday/beach/sunset.jpg", SideCarPath: &sideCarPath, AlbumID: beach.ID},
+			{Title: "party.jpg", Path: "/photos/holidays/party.jpg", AlbumID: other.ID},
+		}
+		if !assert.NoError(t, db.Create(&media).Error) {
+			return
+		}
+
+		if !assert.NoError(t, models.MoveStoragePaths(db, "/photos/holiday", "/mnt/ssd/holiday")) {
+			return
+		}
+
+		for _, album := range []*models.Album{&holiday, &beach, &other} {
+			assert.NoError(t, db.First(album, album.ID).Error)
+		}
+		assert.Equal(t, "/mnt/ssd/holiday", holiday.Path)
+		assert.Equal(t, models.MD5Hash("/mnt/ssd/holiday"), holiday.PathHash)
+		assert.Equal(t, "/mnt/ssd/holiday/beach", beach.Path)
+		assert.Equal(t, "/photos/holidays", other.Path)
+
+		for _, m := range media {
+			assert.NoError(t, db.First(m, m.ID).Error)
+		}
+		assert.Equal(t, "/mnt/ssd/holiday/beach/sunset.jpg", media[0].Path)
+		assert.Equal(t, models.MD5Hash("/mnt/ssd/holiday/beach/sunset.jpg"), media[0].PathHash)
+		if assert.NotNil(t, media[0].SideCarPath) {
+			assert.Equal(t, "/mnt/ssd/holiday/beach/sunset.jpg.xmp", *media[0].SideCarPath)
+		}
+		assert.Equal(t, "/photos/holidays/party.jpg", media[1].Path)
+	})
+}
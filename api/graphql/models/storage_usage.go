@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// AlbumStorageUsage is the disk space used by the media of an album, computed from the file sizes recorded by the scanner.
+// It is updated every time the album is scanned.
+type AlbumStorageUsage struct {
+	AlbumID       int    `gorm:"primaryKey;autoIncrement:false"`
+	Album         *Album `gorm:"constraint:OnDelete:CASCADE;"`
+	MediaCount    int    `gorm:"not null;default:0"`
+	OriginalBytes int64  `gorm:"not null;default:0"`
+	CacheBytes    int64  `gorm:"not null;default:0"`
+	UpdatedAt     time.Time
+}
+
+func (u *AlbumStorageUsage) Usage() *StorageUsage {
+	return &StorageUsage{
+		MediaCount:    u.MediaCount,
+		OriginalBytes: int(u.OriginalBytes),
+		CacheBytes:    int(u.CacheBytes),
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"path"
+
+	"github.com/photoview/photoview/api/utils"
+)
+
+// UploadSession tracks a resumable upload of a single file into an album
+type UploadSession struct {
+	Model
+	Token    string `gorm:"not null;unique;size:32"`
+	UserID   int    `gorm:"not null;index"`
+	User     *User  `gorm:"constraint:OnDelete:CASCADE;"`
+	AlbumID  int    `gorm:"not null;index"`
+	Album    *Album `gorm:"constraint:OnDelete:CASCADE;"`
+	Filename string `gorm:"not null"`
+	// Size is the total size of the file in bytes
+	Size int64 `gorm:"not null"`
+	// Offset is the number of bytes received so far
+	Offset int64 `gorm:"not null;default:0"`
+	// DeviceID is set for uploads made by a device backing up its camera roll
+	DeviceID *int    `gorm:"index"`
+	Device   *Device `gorm:"constraint:OnDelete:CASCADE;"`
+	// Checksum is the expected SHA-1 checksum of the uploaded file, if provided by the client
+	Checksum *string `gorm:"size:40"`
+	// MediaID is set once the upload has completed and the file has been imported
+	MediaID *int
+	Media   *Media `gorm:"constraint:OnDelete:SET NULL;"`
+}
+
+// PartialPath returns the path in the media cache, where the received part of the upload is stored
+func (s *UploadSession) PartialPath() string {
+	return path.Join(utils.MediaCachePath(), "uploads", s.Token)
+}
+
+func (s *UploadSession) Completed() bool {
+	return s.Offset >= s.Size
+}
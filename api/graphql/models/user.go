@@ -3,6 +3,7 @@ package models
 import (
 	"crypto/rand"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -45,6 +46,34 @@ type UserPreferences struct {
 	UserID   int  `gorm:"not null;index"`
 	User     User `gorm:"constraint:OnDelete:CASCADE;"`
 	Language *LanguageTranslation
+	// ExcludePatternLines are the patterns of files and directories excluded from scans of the albums of the user, one per line
+	ExcludePatternLines *string `gorm:"column:exclude_patterns;type:text"`
+}
+
+// ExcludePatterns returns the patterns of files and directories excluded from scans of the albums of the user
+func (u *UserPreferences) ExcludePatterns() []string {
+	patterns := make([]string, 0)
+	if u.ExcludePatternLines == nil {
+		return patterns
+	}
+
+	for _, pattern := range strings.Split(*u.ExcludePatternLines, "\n") {
+		if pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+func (u *UserPreferences) SetExcludePatterns(patterns []string) {
+	if len(patterns) == 0 {
+		u.ExcludePatternLines = nil
+		return
+	}
+
+	lines := strings.Join(patterns, "\n")
+	u.ExcludePatternLines = &lines
 }
 
 func (u *UserPreferences) BeforeSave(tx *gorm.DB) error {
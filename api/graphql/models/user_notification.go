@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// UserNotification is a notification kept in the notification center of a user
+type UserNotification struct {
+	Model
+	UserID int               `gorm:"not null;index"`
+	User   User              `gorm:"constraint:OnDelete:CASCADE;"`
+	Event  NotificationEvent `gorm:"not null"`
+	Title  string            `gorm:"not null"`
+	Body   string            `gorm:"type:text;not null"`
+	ReadAt *time.Time        `gorm:"index"`
+}
+
+func (n *UserNotification) Read() bool {
+	return n.ReadAt != nil
+}
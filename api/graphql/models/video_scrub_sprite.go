@@ -0,0 +1,34 @@
+package models
+
+// Layout of the scrub sprites of videos, the same for all videos so it is not stored
+const (
+	ScrubSpriteFrames     = 100
+	ScrubSpriteColumns    = 10
+	ScrubSpriteFrameWidth = 160
+)
+
+// VideoScrubSprite is an image of frames of a video at even intervals, laid out in a grid,
+// shown as previews while hovering over or scrubbing through the video
+type VideoScrubSprite struct {
+	Image       *MediaURL
+	FrameCount  int
+	Columns     int
+	FrameWidth  int
+	FrameHeight int
+	// Interval is the number of seconds between frames, frame i is of the video at i * Interval
+	Interval float64
+}
+
+// NewVideoScrubSprite returns the layout of the scrub sprite image of a video of the given duration
+func NewVideoScrubSprite(image *MediaURL, durationSeconds float64) *VideoScrubSprite {
+	rows := (ScrubSpriteFrames + ScrubSpriteColumns - 1) / ScrubSpriteColumns
+
+	return &VideoScrubSprite{
+		Image:       image,
+		FrameCount:  ScrubSpriteFrames,
+		Columns:     ScrubSpriteColumns,
+		FrameWidth:  image.Width / ScrubSpriteColumns,
+		FrameHeight: image.Height / rows,
+		Interval:    durationSeconds / ScrubSpriteFrames,
+	}
+}
@@ -0,0 +1,23 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVideoScrubSprite(t *testing.T) {
+	image := &models.MediaURL{Purpose: models.VideoSprite, Width: 1600, Height: 900}
+	sprite := models.NewVideoScrubSprite(image, 250)
+
+	assert.Equal(t, image, sprite.Image)
+	assert.Equal(t, 100, sprite.FrameCount)
+	assert.Equal(t, 10, sprite.Columns)
+	assert.Equal(t, 160, sprite.FrameWidth)
+	assert.Equal(t, 90, sprite.FrameHeight)
+	assert.Equal(t, 2.5, sprite.Interval)
+
+	cacheType, _ := models.VideoSprite.CacheType()
+	assert.Equal(t, models.CacheTypeThumbnails, cacheType)
+}
@@ -0,0 +1,17 @@
+package models
+
+// Watermark is drawn over the photos of a user served through share links, so only the user sees them without it
+type Watermark struct {
+	Model
+	UserID  int   `gorm:"not null;uniqueIndex"`
+	User    *User `gorm:"constraint:OnDelete:CASCADE;"`
+	Enabled bool  `gorm:"not null;default:false"`
+	// Text is drawn as the watermark, if it has no image
+	Text *string
+	// ImageMediaID is a PNG photo of the user drawn as the watermark
+	ImageMediaID *int
+	ImageMedia   *Media            `gorm:"constraint:OnDelete:SET NULL;"`
+	Position     WatermarkPosition `gorm:"not null;default:BOTTOM_RIGHT"`
+	// Opacity is from 0, invisible, to 1, opaque
+	Opacity float64 `gorm:"not null;default:0.5"`
+}
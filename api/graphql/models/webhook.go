@@ -0,0 +1,49 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook is an url that is notified of events in the library, such as new media being added
+type Webhook struct {
+	Model
+	URL string `gorm:"not null"`
+	// Secret signs the payloads, so the receiver can verify they were sent by Photoview
+	Secret string `gorm:"not null"`
+	// EventNames are the events the webhook is notified of, separated by commas
+	EventNames     string `gorm:"column:events;not null"`
+	LastDeliveryAt *time.Time
+	LastError      *string
+}
+
+func (w *Webhook) Events() []WebhookEvent {
+	events := make([]WebhookEvent, 0)
+	for _, name := range strings.Split(w.EventNames, ",") {
+		if event := WebhookEvent(name); event.IsValid() {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+func (w *Webhook) SetEvents(events []WebhookEvent) {
+	names := make([]string, 0, len(events))
+	for _, event := range events {
+		names = append(names, event.String())
+	}
+
+	w.EventNames = strings.Join(names, ",")
+}
+
+// SubscribedTo returns whether the webhook should be notified of the event
+func (w *Webhook) SubscribedTo(event WebhookEvent) bool {
+	for _, subscribed := range w.Events() {
+		if subscribed == event {
+			return true
+		}
+	}
+
+	return false
+}
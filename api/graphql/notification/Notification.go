@@ -1,11 +1,12 @@
 package notification
 
 import (
+	"context"
 	"errors"
-	"log"
 	"sync"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 )
 
 type NotificationChannel = chan<- *models.Notification
@@ -30,7 +31,7 @@ var nextNotificationId = 0
 var notificationLock = &sync.Mutex{}
 
 func RegisterListener(user *models.User, channel NotificationChannel) int {
-	log.Println("Registering notification listener")
+	log.Debug(context.Background(), "Registering notification listener", "user_id", user.ID)
 
 	notificationLock.Lock()
 	defer notificationLock.Unlock()
@@ -46,7 +47,7 @@ func DeregisterListener(listenerID int) error {
 
 	for i, listener := range notificationListeners {
 
-		log.Println("Deregistering notification listener")
+		log.Debug(context.Background(), "Deregistering notification listener")
 
 		if listener.listenerID == listenerID {
 
@@ -0,0 +1,57 @@
+package notification
+
+import (
+	"sync"
+
+	"github.com/photoview/photoview/api/graphql/models"
+)
+
+type UserNotificationChannel = chan<- *models.UserNotification
+
+type userNotificationListener struct {
+	userID  int
+	channel UserNotificationChannel
+}
+
+var userNotificationListeners = make(map[int]*userNotificationListener)
+var nextUserNotificationListenerID = 0
+var userNotificationLock = &sync.Mutex{}
+
+// RegisterUserNotificationListener registers a channel receiving the notifications added to the notification center of the user
+func RegisterUserNotificationListener(user *models.User, channel UserNotificationChannel) int {
+	userNotificationLock.Lock()
+	defer userNotificationLock.Unlock()
+
+	nextUserNotificationListenerID++
+	userNotificationListeners[nextUserNotificationListenerID] = &userNotificationListener{
+		userID:  user.ID,
+		channel: channel,
+	}
+
+	return nextUserNotificationListenerID
+}
+
+func DeregisterUserNotificationListener(listenerID int) {
+	userNotificationLock.Lock()
+	defer userNotificationLock.Unlock()
+
+	delete(userNotificationListeners, listenerID)
+}
+
+// PublishUserNotification sends a notification to the listeners of its user.
+// Listeners that are not ready to receive it are skipped, as the notification is stored and can be queried later.
+func PublishUserNotification(notification *models.UserNotification) {
+	userNotificationLock.Lock()
+	defer userNotificationLock.Unlock()
+
+	for _, listener := range userNotificationListeners {
+		if listener.userID != notification.UserID {
+			continue
+		}
+
+		select {
+		case listener.channel <- notification:
+		default:
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func (r *queryResolver) CacheUsage(ctx context.Context) (*models.CacheUsage, error) {
+	return storage.GetCacheUsage(r.DB(ctx))
+}
+
+func (r *mutationResolver) SetCacheBudget(ctx context.Context, budgetBytes int, warningThreshold *float64, cacheType *models.CacheType) (*models.CacheUsage, error) {
+	db := r.DB(ctx)
+
+	if budgetBytes < 0 {
+		return nil, errors.New("cache budget must not be negative")
+	}
+
+	budgetColumn := "cache_budget"
+	if cacheType != nil {
+		if _, budgetColumn = (&models.SiteInfo{}).CacheTypeBudget(*cacheType); budgetColumn == "" {
+			return nil, errors.Errorf("unsupported cache type: %s", *cacheType)
+		}
+	}
+
+	updates := map[string]interface{}{
+		budgetColumn: int64(budgetBytes),
+	}
+
+	if warningThreshold != nil {
+		if *warningThreshold <= 0 || *warningThreshold > 1 {
+			return nil, errors.New("warning threshold must be between 0 and 1")
+		}
+		updates["cache_warning_threshold"] = *warningThreshold
+	}
+
+	if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&models.SiteInfo{}).Updates(updates).Error; err != nil {
+		return nil, errors.Wrap(err, "update cache budget")
+	}
+
+	return storage.CheckCacheBudget(db)
+}
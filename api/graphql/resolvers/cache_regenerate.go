@@ -0,0 +1,86 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/photoview/photoview/api/cluster"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// cacheRegenerateLease is held while cached files are regenerated, so they are only regenerated once at a time across all instances
+var cacheRegenerateLease = cluster.NewLease("cache-regenerate", time.Minute)
+
+func (r *mutationResolver) RegenerateCache(ctx context.Context, mediaID *int, albumID *int) (*models.ScannerResult, error) {
+	db := r.DB(ctx)
+
+	query := db.Model(&models.Media{}).Preload("MediaURL").Order("media.id")
+	if mediaID != nil {
+		query = query.Where("media.id = ?", *mediaID)
+	}
+	if albumID != nil {
+		albums, err := models.GetChildrenFromAlbums(db, nil, []int{*albumID})
+		if err != nil {
+			return nil, errors.Wrap(err, "get sub albums of album")
+		}
+
+		albumIDs := make([]int, len(albums))
+		for i, album := range albums {
+			albumIDs[i] = album.ID
+		}
+		query = query.Where("media.album_id IN (?)", albumIDs)
+	}
+
+	var mediaCount int64
+	if err := query.Session(&gorm.Session{}).Count(&mediaCount).Error; err != nil {
+		return nil, errors.Wrap(err, "count media to regenerate")
+	}
+
+	if mediaCount == 0 && (mediaID != nil || albumID != nil) {
+		return nil, errors.New("no media found to regenerate")
+	}
+
+	if cacheRegenerateLease.Held() {
+		return nil, errors.New("cached files are already being regenerated")
+	}
+
+	acquired, err := cacheRegenerateLease.Acquire(db)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, errors.New("cached files are already being regenerated by another instance")
+	}
+
+	// The cached files are regenerated after the request has finished
+	backgroundDB := r.DB(context.Background())
+	backgroundQuery := query.WithContext(context.Background())
+	go func() {
+		defer func() {
+			if err := cacheRegenerateLease.Release(backgroundDB); err != nil {
+				log.Warn(context.Background(), "Releasing cache regenerate lease", "error", err)
+			}
+		}()
+
+		start := time.Now()
+		processed, failed, err := scanner.RegenerateCachedFiles(context.Background(), backgroundDB, backgroundQuery, true)
+		if err != nil {
+			log.Error(context.Background(), "Regenerating cached files", "error", err)
+			return
+		}
+
+		log.Info(context.Background(), "Cached files regenerated", "processed", processed, "failed", failed, "duration", time.Since(start))
+	}()
+
+	message := fmt.Sprintf("Regenerating the cached files of %d media", mediaCount)
+	return &models.ScannerResult{
+		Finished: false,
+		Success:  true,
+		Message:  &message,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+// How long a cast receiver has access to the media of an album, long enough for a slideshow running through an evening
+const castSessionDuration = 12 * time.Hour
+
+func (r *mutationResolver) CastAlbum(ctx context.Context, albumID int) (*models.CastSession, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	db := r.DB(ctx)
+
+	var album models.Album
+	if err := db.First(&album, albumID).Error; err != nil {
+		return nil, errors.Wrap(err, "get album from database")
+	}
+
+	ownsAlbum, err := user.OwnsAlbum(db, &album)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ownsAlbum {
+		return nil, auth.ErrUnauthorized
+	}
+
+	if err := db.Where("expire < ?", time.Now()).Delete(&models.CastSession{}).Error; err != nil {
+		return nil, errors.Wrap(err, "delete expired cast sessions")
+	}
+
+	session := models.CastSession{
+		Token:   utils.GenerateToken() + utils.GenerateToken(),
+		UserID:  user.ID,
+		AlbumID: album.ID,
+		Expire:  time.Now().Add(castSessionDuration),
+	}
+
+	if err := db.Create(&session).Error; err != nil {
+		return nil, errors.Wrap(err, "create cast session")
+	}
+
+	return &session, nil
+}
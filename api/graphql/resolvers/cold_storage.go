@@ -0,0 +1,75 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/pkg/errors"
+)
+
+func (r *albumResolver) ColdStorage(ctx context.Context, album *models.Album) (bool, error) {
+	return album.InColdStorage(r.DB(ctx))
+}
+
+func (r *mediaResolver) Retrieval(ctx context.Context, media *models.Media) (*models.MediaRetrieval, error) {
+	var retrieval []*models.MediaRetrieval
+	if err := r.DB(ctx).Where("media_id = ?", media.ID).Limit(1).Find(&retrieval).Error; err != nil {
+		return nil, errors.Wrapf(err, "get retrieval for media (%s)", media.Path)
+	}
+
+	if len(retrieval) == 0 {
+		return nil, nil
+	}
+
+	return retrieval[0], nil
+}
+
+func (r *mutationResolver) SetAlbumColdStorage(ctx context.Context, albumID int, coldStorage bool) (*models.Album, error) {
+	db := r.DB(ctx)
+
+	var album models.Album
+	if err := db.First(&album, albumID).Error; err != nil {
+		return nil, errors.Wrap(err, "get album from database")
+	}
+
+	if err := db.Model(&album).Update("cold_storage", coldStorage).Error; err != nil {
+		return nil, errors.Wrap(err, "update album cold storage")
+	}
+
+	return &album, nil
+}
+
+func (r *mutationResolver) RequestMediaRetrieval(ctx context.Context, mediaID int) (*models.MediaRetrieval, error) {
+	db := r.DB(ctx)
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	var media models.Media
+	if err := db.Joins("Album").First(&media, mediaID).Error; err != nil {
+		return nil, errors.Wrap(err, "get media from database")
+	}
+
+	ownsAlbum, err := user.OwnsAlbum(db, &media.Album)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ownsAlbum {
+		return nil, errors.New("forbidden")
+	}
+
+	inColdStorage, err := media.Album.InColdStorage(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if !inColdStorage {
+		return nil, errors.New("media is not located in cold storage")
+	}
+
+	return storage.RequestRetrieval(db, &media)
+}
@@ -0,0 +1,186 @@
+package resolvers
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	api "github.com/photoview/photoview/api/graphql"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+)
+
+type deviceResolver struct {
+	*Resolver
+}
+
+type uploadSessionResolver struct {
+	*Resolver
+}
+
+func (r *Resolver) Device() api.DeviceResolver {
+	return deviceResolver{r}
+}
+
+func (r *Resolver) UploadSession() api.UploadSessionResolver {
+	return uploadSessionResolver{r}
+}
+
+func (r deviceResolver) Album(ctx context.Context, obj *models.Device) (*models.Album, error) {
+	return obj.Album(r.DB(ctx))
+}
+
+func (r deviceResolver) BackedUpCount(ctx context.Context, obj *models.Device) (int, error) {
+	var count int64
+	if err := r.DB(ctx).Model(&models.DeviceAsset{}).Where("device_id = ?", obj.ID).Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "count device assets")
+	}
+
+	return int(count), nil
+}
+
+func (r deviceResolver) Uploads(ctx context.Context, obj *models.Device) ([]*models.UploadSession, error) {
+	var sessions []*models.UploadSession
+	err := r.DB(ctx).Where("device_id = ?", obj.ID).Where("media_id IS NULL").Order("created_at").Find(&sessions).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get upload sessions of device")
+	}
+
+	return sessions, nil
+}
+
+func (r uploadSessionResolver) Media(ctx context.Context, obj *models.UploadSession) (*models.Media, error) {
+	if obj.MediaID == nil {
+		return nil, nil
+	}
+
+	var media models.Media
+	if err := r.DB(ctx).First(&media, *obj.MediaID).Error; err != nil {
+		return nil, errors.Wrap(err, "get uploaded media")
+	}
+
+	return &media, nil
+}
+
+func (r *queryResolver) MyDevices(ctx context.Context) ([]*models.Device, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	var devices []*models.Device
+	if err := r.DB(ctx).Where("user_id = ?", user.ID).Order("name").Find(&devices).Error; err != nil {
+		return nil, errors.Wrap(err, "get devices of user")
+	}
+
+	return devices, nil
+}
+
+func (r *queryResolver) DeviceBackupCheck(ctx context.Context, deviceID int, checksums []string) ([]string, error) {
+	db := r.DB(ctx)
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	var device models.Device
+	if err := db.Where("id = ? AND user_id = ?", deviceID, user.ID).First(&device).Error; err != nil {
+		return nil, errors.Wrap(err, "get device")
+	}
+
+	normalized := make([]string, len(checksums))
+	for i, checksum := range checksums {
+		normalized[i] = strings.ToLower(checksum)
+	}
+
+	backedUp, err := models.BackedUpChecksums(db, user.ID, normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	backedUpSet := make(map[string]bool, len(backedUp))
+	for _, checksum := range backedUp {
+		backedUpSet[checksum] = true
+	}
+
+	missing := make([]string, 0)
+	for _, checksum := range normalized {
+		if !backedUpSet[checksum] {
+			missing = append(missing, checksum)
+		}
+	}
+
+	return missing, nil
+}
+
+func (r *mutationResolver) RegisterDevice(ctx context.Context, name string, platform *string, parentAlbumID int) (*models.Device, error) {
+	db := r.DB(ctx)
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	name = strings.TrimSpace(name)
+	dirName := strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if dirName == "" || strings.HasPrefix(dirName, ".") {
+		return nil, errors.New("invalid device name")
+	}
+
+	var parentAlbum models.Album
+	if err := db.First(&parentAlbum, parentAlbumID).Error; err != nil {
+		return nil, errors.Wrap(err, "get parent album")
+	}
+
+	ownsAlbum, err := user.OwnsAlbum(db, &parentAlbum)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ownsAlbum {
+		return nil, errors.New("forbidden")
+	}
+
+	devicePath := path.Join(parentAlbum.Path, dirName)
+
+	var existingCount int64
+	if err := db.Model(&models.Device{}).Where("path = ?", devicePath).Count(&existingCount).Error; err != nil {
+		return nil, errors.Wrap(err, "check for existing device")
+	}
+
+	if existingCount > 0 {
+		return nil, errors.New("a device with the same name is already backed up to this album")
+	}
+
+	device := models.Device{
+		Name:     name,
+		Platform: platform,
+		UserID:   user.ID,
+		Path:     devicePath,
+	}
+
+	if err := db.Create(&device).Error; err != nil {
+		return nil, errors.Wrap(err, "insert device into database")
+	}
+
+	return &device, nil
+}
+
+func (r *mutationResolver) RemoveDevice(ctx context.Context, id int) (*models.Device, error) {
+	db := r.DB(ctx)
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	var device models.Device
+	if err := db.Where("id = ? AND user_id = ?", id, user.ID).First(&device).Error; err != nil {
+		return nil, errors.Wrap(err, "get device")
+	}
+
+	if err := db.Delete(&device).Error; err != nil {
+		return nil, errors.Wrap(err, "delete device")
+	}
+
+	return &device, nil
+}
@@ -0,0 +1,27 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+)
+
+func (r *albumResolver) DlnaShared(ctx context.Context, album *models.Album) (bool, error) {
+	return album.DLNAShared(r.DB(ctx))
+}
+
+func (r *mutationResolver) SetAlbumDlna(ctx context.Context, albumID int, enabled bool) (*models.Album, error) {
+	db := r.DB(ctx)
+
+	var album models.Album
+	if err := db.First(&album, albumID).Error; err != nil {
+		return nil, errors.Wrap(err, "get album from database")
+	}
+
+	if err := db.Model(&album).Update("dlna_enabled", enabled).Error; err != nil {
+		return nil, errors.Wrap(err, "update album dlna sharing")
+	}
+
+	return &album, nil
+}
@@ -0,0 +1,26 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/features"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/settings"
+)
+
+func (r *queryResolver) FeatureFlags(ctx context.Context) ([]*models.FeatureFlag, error) {
+	return features.GetFeatureFlags(), nil
+}
+
+func (r *mutationResolver) SetFeatureFlag(ctx context.Context, feature models.Feature, enabled *bool) (*models.FeatureFlag, error) {
+	key, value, err := features.Setting(feature, enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := settings.UpdateSetting(r.DB(ctx), key, value); err != nil {
+		return nil, err
+	}
+
+	return features.GetFeatureFlag(feature), nil
+}
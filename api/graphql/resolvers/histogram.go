@@ -0,0 +1,49 @@
+package resolvers
+
+import (
+	"context"
+
+	api "github.com/photoview/photoview/api/graphql"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+)
+
+type mediaHistogramResolver struct {
+	*Resolver
+}
+
+func (r *Resolver) MediaHistogram() api.MediaHistogramResolver {
+	return mediaHistogramResolver{r}
+}
+
+func (r mediaHistogramResolver) Luminance(ctx context.Context, obj *models.MediaHistogram) ([]int, error) {
+	return obj.Luminance.Ints(), nil
+}
+
+func (r mediaHistogramResolver) Red(ctx context.Context, obj *models.MediaHistogram) ([]int, error) {
+	return obj.Red.Ints(), nil
+}
+
+func (r mediaHistogramResolver) Green(ctx context.Context, obj *models.MediaHistogram) ([]int, error) {
+	return obj.Green.Ints(), nil
+}
+
+func (r mediaHistogramResolver) Blue(ctx context.Context, obj *models.MediaHistogram) ([]int, error) {
+	return obj.Blue.Ints(), nil
+}
+
+func (r *mediaResolver) Histogram(ctx context.Context, media *models.Media) (*models.MediaHistogram, error) {
+	if media.Type != models.MediaTypePhoto {
+		return nil, nil
+	}
+
+	var histograms []*models.MediaHistogram
+	if err := r.DB(ctx).Where("media_id = ?", media.ID).Limit(1).Find(&histograms).Error; err != nil {
+		return nil, errors.Wrap(err, "get histogram of media")
+	}
+	if len(histograms) == 0 {
+		return nil, nil
+	}
+
+	return histograms[0], nil
+}
@@ -0,0 +1,81 @@
+package resolvers
+
+import (
+	"context"
+	"path"
+
+	api "github.com/photoview/photoview/api/graphql"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/importer"
+	"github.com/pkg/errors"
+)
+
+type importJobResolver struct {
+	*Resolver
+}
+
+func (r *Resolver) ImportJob() api.ImportJobResolver {
+	return importJobResolver{r}
+}
+
+func (r importJobResolver) Album(ctx context.Context, obj *models.ImportJob) (*models.Album, error) {
+	var album models.Album
+	if err := r.DB(ctx).First(&album, obj.AlbumID).Error; err != nil {
+		return nil, errors.Wrap(err, "get import album")
+	}
+
+	return &album, nil
+}
+
+func (r *mediaResolver) People(ctx context.Context, media *models.Media) ([]string, error) {
+	people := make([]string, 0)
+	if err := r.DB(ctx).Model(&models.MediaPerson{}).Where("media_id = ?", media.ID).Order("name").Pluck("name", &people).Error; err != nil {
+		return nil, errors.Wrapf(err, "get people of media (%s)", media.Path)
+	}
+
+	return people, nil
+}
+
+func (r *queryResolver) ImportJobs(ctx context.Context) ([]*models.ImportJob, error) {
+	var jobs []*models.ImportJob
+	if err := r.DB(ctx).Order("created_at DESC").Find(&jobs).Error; err != nil {
+		return nil, errors.Wrap(err, "get import jobs from database")
+	}
+
+	return jobs, nil
+}
+
+func (r *mutationResolver) StartImport(ctx context.Context, source models.ImportSource, sourcePath string, albumID int, layout *string) (*models.ImportJob, error) {
+	db := r.DB(ctx)
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	if !path.IsAbs(sourcePath) {
+		return nil, errors.New("import source path must be absolute")
+	}
+
+	var album models.Album
+	if err := db.First(&album, albumID).Error; err != nil {
+		return nil, errors.Wrap(err, "get import album")
+	}
+
+	job := models.ImportJob{
+		Source:     source,
+		SourcePath: path.Clean(sourcePath),
+		UserID:     user.ID,
+		AlbumID:    album.ID,
+	}
+
+	if layout != nil {
+		job.Layout = *layout
+	}
+
+	if err := importer.StartImport(db, &job); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,25 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/cluster"
+	api "github.com/photoview/photoview/api/graphql"
+	"github.com/photoview/photoview/api/graphql/models"
+)
+
+type leaseResolver struct {
+	*Resolver
+}
+
+func (r *Resolver) Lease() api.LeaseResolver {
+	return leaseResolver{r}
+}
+
+func (r leaseResolver) HeldByThisInstance(ctx context.Context, obj *models.Lease) (bool, error) {
+	return obj.Holder == cluster.InstanceID(), nil
+}
+
+func (r *queryResolver) Leases(ctx context.Context) ([]*models.Lease, error) {
+	return cluster.GetLeases(r.DB(ctx))
+}
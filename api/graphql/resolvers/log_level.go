@@ -0,0 +1,26 @@
+package resolvers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/pkg/errors"
+)
+
+func (r *queryResolver) LogLevel(ctx context.Context) (models.LogLevel, error) {
+	return models.LogLevel(strings.ToUpper(log.GetLevel().String())), nil
+}
+
+func (r *mutationResolver) SetLogLevel(ctx context.Context, level models.LogLevel) (models.LogLevel, error) {
+	parsed, err := log.ParseLevel(level.String())
+	if err != nil {
+		return "", errors.Wrap(err, "set log level")
+	}
+
+	log.SetLevel(parsed)
+	log.Info(ctx, "Log level changed", "level", parsed)
+
+	return level, nil
+}
@@ -0,0 +1,16 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/maintenance"
+)
+
+func (r *queryResolver) MaintenanceStatus(ctx context.Context) (*models.MaintenanceStatus, error) {
+	return maintenance.GetStatus(), nil
+}
+
+func (r *mutationResolver) StartMaintenance(ctx context.Context, tasks []models.MaintenanceTask) (*models.MaintenanceStatus, error) {
+	return maintenance.Start(r.DB(ctx), tasks)
+}
@@ -2,6 +2,7 @@ package resolvers
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/photoview/photoview/api/dataloader"
@@ -123,8 +124,13 @@ func (r *mediaResolver) Downloads(ctx context.Context, media *models.Media) ([]*
 	downloads := make([]*models.MediaDownload, 0)
 
 	for _, url := range mediaUrls {
+		// AVIF variants are served in place of the images they are a variant of, to clients that accept them
+		if _, isVariant := url.Purpose.AVIFVariantOf(); isVariant {
+			continue
+		}
 
 		var title string
+		scaledSize, scaled := url.Purpose.ScaledSize()
 		switch {
 		case url.Purpose == models.MediaOriginal:
 			title = "Original"
@@ -132,10 +138,16 @@ func (r *mediaResolver) Downloads(ctx context.Context, media *models.Media) ([]*
 			title = "Small"
 		case url.Purpose == models.PhotoHighRes:
 			title = "Large"
+		case scaled:
+			title = fmt.Sprintf("Scaled to %d pixels", scaledSize)
 		case url.Purpose == models.VideoThumbnail:
 			title = "Video thumbnail"
 		case url.Purpose == models.VideoWeb:
 			title = "Web optimized video"
+		case url.Purpose == models.MotionVideo:
+			title = "Motion photo video"
+		case url.Purpose == models.VideoSprite:
+			title = "Video preview frames"
 		}
 
 		downloads = append(downloads, &models.MediaDownload{
@@ -155,6 +167,15 @@ func (r *mediaResolver) HighRes(ctx context.Context, media *models.Media) (*mode
 	return dataloader.For(ctx).MediaHighres.Load(media.ID)
 }
 
+func (r *mediaResolver) ImageForViewport(ctx context.Context, media *models.Media, width int, height int) (*models.MediaURL, error) {
+	var mediaURLs []*models.MediaURL
+	if err := r.DB(ctx).Where("media_id = ?", media.ID).Find(&mediaURLs).Error; err != nil {
+		return nil, errors.Wrapf(err, "get images of media (%s)", media.Path)
+	}
+
+	return media.ImageForViewport(mediaURLs, width, height), nil
+}
+
 func (r *mediaResolver) Thumbnail(ctx context.Context, media *models.Media) (*models.MediaURL, error) {
 	return dataloader.For(ctx).MediaThumbnail.Load(media.ID)
 }
@@ -167,17 +188,56 @@ func (r *mediaResolver) VideoWeb(ctx context.Context, media *models.Media) (*mod
 	return dataloader.For(ctx).MediaVideoWeb.Load(media.ID)
 }
 
-func (r *mediaResolver) Exif(ctx context.Context, media *models.Media) (*models.MediaEXIF, error) {
-	if media.Exif != nil {
-		return media.Exif, nil
+func (r *mediaResolver) ScrubSprite(ctx context.Context, media *models.Media) (*models.VideoScrubSprite, error) {
+	if media.Type != models.MediaTypeVideo {
+		return nil, nil
 	}
 
-	var exif models.MediaEXIF
-	if err := r.DB(ctx).Model(&media).Association("Exif").Find(&exif); err != nil {
+	sprite, err := dataloader.For(ctx).MediaVideoSprite.Load(media.ID)
+	if err != nil || sprite == nil {
 		return nil, err
 	}
 
-	return &exif, nil
+	if sprite.Media == nil || sprite.Media.VideoMetadata == nil {
+		return nil, nil
+	}
+
+	return models.NewVideoScrubSprite(sprite, sprite.Media.VideoMetadata.Duration), nil
+}
+
+func (r *mediaResolver) MotionPhoto(ctx context.Context, media *models.Media) (*models.MediaURL, error) {
+	if media.Type != models.MediaTypePhoto {
+		return nil, nil
+	}
+
+	return dataloader.For(ctx).MediaMotionPhoto.Load(media.ID)
+}
+
+func (r *mediaResolver) Exif(ctx context.Context, media *models.Media) (*models.MediaEXIF, error) {
+	exif := media.Exif
+	if exif == nil {
+		exif = &models.MediaEXIF{}
+		if err := r.DB(ctx).Model(&media).Association("Exif").Find(exif); err != nil {
+			return nil, err
+		}
+	}
+
+	// Media is only accessed without a user through shares, whose viewers may not see where it was taken
+	if auth.UserFromContext(ctx) == nil {
+		strip, err := models.MediaSharesStripMetadata(r.DB(ctx), media)
+		if err != nil {
+			return nil, err
+		}
+
+		if strip {
+			stripped := *exif
+			stripped.GPSLatitude = nil
+			stripped.GPSLongitude = nil
+			return &stripped, nil
+		}
+	}
+
+	return exif, nil
 }
 
 func (r *mediaResolver) Favorite(ctx context.Context, media *models.Media) (bool, error) {
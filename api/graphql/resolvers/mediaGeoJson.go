@@ -6,6 +6,8 @@ import (
 	"path"
 
 	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/graphql/models/actions"
 	"github.com/photoview/photoview/api/utils"
 )
 
@@ -124,6 +126,15 @@ func (r *queryResolver) MyMediaGeoJSON(ctx context.Context) (interface{}, error)
 	return featureCollection, nil
 }
 
+func (r *queryResolver) MyMediaClusters(ctx context.Context, bounds models.MapBounds, zoom int) ([]*models.MediaCluster, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	return actions.MyMediaClusters(r.DB(ctx), user, bounds, zoom)
+}
+
 func (r *queryResolver) MapboxToken(ctx context.Context) (*string, error) {
 	mapboxTokenEnv := os.Getenv("MAPBOX_TOKEN")
 	if mapboxTokenEnv == "" {
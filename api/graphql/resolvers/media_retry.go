@@ -0,0 +1,35 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/scanner_queue"
+	"github.com/pkg/errors"
+)
+
+func (r *queryResolver) FailedMedia(ctx context.Context, paginate *models.Pagination) ([]*models.MediaRetry, error) {
+	query := r.DB(ctx).Preload("Album").Order("next_attempt_at IS NULL, next_attempt_at, id")
+
+	var retries []*models.MediaRetry
+	if err := models.FormatSQL(query, nil, paginate).Find(&retries).Error; err != nil {
+		return nil, errors.Wrap(err, "get failed media from database")
+	}
+
+	return retries, nil
+}
+
+func (r *mutationResolver) RetryFailedMedia(ctx context.Context) (*models.ScannerResult, error) {
+	albums, err := scanner_queue.RetryFailedMedia(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Retrying the failed media of %d albums", albums)
+	return &models.ScannerResult{
+		Finished: false,
+		Success:  true,
+		Message:  &message,
+	}, nil
+}
@@ -0,0 +1,163 @@
+package resolvers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/notifier"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func (r *queryResolver) MyNotificationChannels(ctx context.Context) ([]*models.NotificationChannel, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	var channels []*models.NotificationChannel
+	if err := r.DB(ctx).Where("user_id = ?", user.ID).Order("id").Find(&channels).Error; err != nil {
+		return nil, errors.Wrap(err, "get notification channels from database")
+	}
+
+	return channels, nil
+}
+
+func (r *mutationResolver) CreateNotificationChannel(ctx context.Context, kind models.NotificationChannelKind, target string, token *string, events []models.NotificationEvent) (*models.NotificationChannel, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	target, err := notifier.ValidateChannel(user, kind, strings.TrimSpace(target))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateNotificationEvents(user, events); err != nil {
+		return nil, err
+	}
+
+	channel := models.NotificationChannel{
+		UserID: user.ID,
+		Kind:   kind,
+		Target: target,
+		Token:  emptyToNil(token),
+	}
+	channel.SetEvents(events)
+
+	if err := r.DB(ctx).Create(&channel).Error; err != nil {
+		return nil, errors.Wrap(err, "create notification channel")
+	}
+
+	return &channel, nil
+}
+
+func (r *mutationResolver) UpdateNotificationChannel(ctx context.Context, id int, target *string, token *string, events []models.NotificationEvent) (*models.NotificationChannel, error) {
+	db := r.DB(ctx)
+
+	channel, err := ownedNotificationChannel(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if target != nil {
+		validated, err := notifier.ValidateChannel(auth.UserFromContext(ctx), channel.Kind, strings.TrimSpace(*target))
+		if err != nil {
+			return nil, err
+		}
+		channel.Target = validated
+	}
+
+	if token != nil {
+		channel.Token = emptyToNil(token)
+	}
+
+	if events != nil {
+		if err := validateNotificationEvents(auth.UserFromContext(ctx), events); err != nil {
+			return nil, err
+		}
+		channel.SetEvents(events)
+	}
+
+	if err := db.Omit("User").Save(channel).Error; err != nil {
+		return nil, errors.Wrap(err, "update notification channel")
+	}
+
+	return channel, nil
+}
+
+func (r *mutationResolver) DeleteNotificationChannel(ctx context.Context, id int) (*models.NotificationChannel, error) {
+	db := r.DB(ctx)
+
+	channel, err := ownedNotificationChannel(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Delete(channel).Error; err != nil {
+		return nil, errors.Wrap(err, "delete notification channel")
+	}
+
+	return channel, nil
+}
+
+func (r *mutationResolver) TestNotificationChannel(ctx context.Context, id int) (*models.NotificationChannel, error) {
+	db := r.DB(ctx)
+
+	channel, err := ownedNotificationChannel(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = notifier.Send(db, channel, &notifier.Message{
+		Title: "Photoview test notification",
+		Body:  "Notifications from Photoview will be delivered here.",
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "send test notification")
+	}
+
+	if err := db.First(channel, channel.ID).Error; err != nil {
+		return nil, errors.Wrap(err, "get notification channel from database")
+	}
+
+	return channel, nil
+}
+
+func ownedNotificationChannel(ctx context.Context, db *gorm.DB, id int) (*models.NotificationChannel, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	var channel models.NotificationChannel
+	if err := db.Where("id = ? AND user_id = ?", id, user.ID).First(&channel).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("notification channel not found")
+		}
+		return nil, errors.Wrap(err, "get notification channel from database")
+	}
+
+	return &channel, nil
+}
+
+func validateNotificationEvents(user *models.User, events []models.NotificationEvent) error {
+	for _, event := range events {
+		if event == models.NotificationEventErrorDigest && !user.Admin {
+			return errors.New("only admins can subscribe to error digests")
+		}
+	}
+
+	return nil
+}
+
+func emptyToNil(value *string) *string {
+	if value == nil || *value == "" {
+		return nil
+	}
+
+	return value
+}
@@ -0,0 +1,139 @@
+package resolvers
+
+import (
+	"context"
+	"strings"
+
+	api "github.com/photoview/photoview/api/graphql"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPhotoFrameMaxSize  = 1920
+	defaultPhotoFrameInterval = 60
+)
+
+type photoFrameResolver struct {
+	*Resolver
+}
+
+func (r *Resolver) PhotoFrame() api.PhotoFrameResolver {
+	return photoFrameResolver{r}
+}
+
+func (r photoFrameResolver) Album(ctx context.Context, obj *models.PhotoFrame) (*models.Album, error) {
+	if obj.AlbumID == nil {
+		return nil, nil
+	}
+
+	var album models.Album
+	if err := r.DB(ctx).First(&album, *obj.AlbumID).Error; err != nil {
+		return nil, errors.Wrap(err, "get album of photo frame")
+	}
+
+	return &album, nil
+}
+
+func (r *queryResolver) MyPhotoFrames(ctx context.Context) ([]*models.PhotoFrame, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	var frames []*models.PhotoFrame
+	if err := r.DB(ctx).Where("user_id = ?", user.ID).Order("title").Find(&frames).Error; err != nil {
+		return nil, errors.Wrap(err, "get photo frames of user")
+	}
+
+	return frames, nil
+}
+
+func (r *mutationResolver) CreatePhotoFrame(ctx context.Context, title string, albumID *int, includeSubAlbums *bool,
+	maxSize *int, interval *int, shuffle *bool) (*models.PhotoFrame, error) {
+
+	db := r.DB(ctx)
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, errors.New("photo frame title must not be empty")
+	}
+
+	frame := models.PhotoFrame{
+		Title:    title,
+		Token:    utils.GenerateToken() + utils.GenerateToken(),
+		UserID:   user.ID,
+		AlbumID:  albumID,
+		MaxSize:  defaultPhotoFrameMaxSize,
+		Interval: defaultPhotoFrameInterval,
+	}
+
+	if albumID != nil {
+		var album models.Album
+		if err := db.First(&album, *albumID).Error; err != nil {
+			return nil, errors.Wrap(err, "get album from database")
+		}
+
+		ownsAlbum, err := user.OwnsAlbum(db, &album)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ownsAlbum {
+			return nil, auth.ErrUnauthorized
+		}
+	}
+
+	if includeSubAlbums != nil {
+		frame.IncludeSubAlbums = *includeSubAlbums
+	}
+
+	if shuffle != nil {
+		frame.Shuffle = *shuffle
+	}
+
+	if maxSize != nil {
+		if *maxSize < 64 || *maxSize > 8192 {
+			return nil, errors.New("max size must be between 64 and 8192 pixels")
+		}
+		frame.MaxSize = *maxSize
+	}
+
+	if interval != nil {
+		if *interval < 1 {
+			return nil, errors.New("interval must be at least one second")
+		}
+		frame.Interval = *interval
+	}
+
+	if err := db.Create(&frame).Error; err != nil {
+		return nil, errors.Wrap(err, "create photo frame")
+	}
+
+	return &frame, nil
+}
+
+func (r *mutationResolver) DeletePhotoFrame(ctx context.Context, id int) (*models.PhotoFrame, error) {
+	db := r.DB(ctx)
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	var frame models.PhotoFrame
+	if err := db.Where("id = ? AND user_id = ?", id, user.ID).First(&frame).Error; err != nil {
+		return nil, errors.Wrap(err, "get photo frame")
+	}
+
+	if err := db.Delete(&frame).Error; err != nil {
+		return nil, errors.Wrap(err, "delete photo frame")
+	}
+
+	return &frame, nil
+}
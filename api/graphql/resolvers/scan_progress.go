@@ -0,0 +1,27 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/scan_progress"
+)
+
+func (r *queryResolver) ScanProgress(ctx context.Context) (*models.ScanProgress, error) {
+	return scan_progress.Get(), nil
+}
+
+func (r *subscriptionResolver) ScanProgress(ctx context.Context) (<-chan *models.ScanProgress, error) {
+	progressChannel := make(chan *models.ScanProgress, 1)
+
+	// Subscribers start with the current progress, so they don't have to query it as well
+	progressChannel <- scan_progress.Get()
+	listenerID := scan_progress.Subscribe(progressChannel)
+
+	go func() {
+		<-ctx.Done()
+		scan_progress.Unsubscribe(listenerID)
+	}()
+
+	return progressChannel, nil
+}
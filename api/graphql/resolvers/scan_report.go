@@ -0,0 +1,59 @@
+package resolvers
+
+import (
+	"context"
+
+	api "github.com/photoview/photoview/api/graphql"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+)
+
+type scanReportResolver struct {
+	*Resolver
+}
+
+func (r *Resolver) ScanReport() api.ScanReportResolver {
+	return scanReportResolver{r}
+}
+
+func (r scanReportResolver) Failures(ctx context.Context, obj *models.ScanReport, paginate *models.Pagination) ([]*models.ScanFailure, error) {
+	query := r.DB(ctx).Preload("Album").Where("scan_report_id = ?", obj.ID).Order("id")
+
+	var failures []*models.ScanFailure
+	if err := models.FormatSQL(query, nil, paginate).Find(&failures).Error; err != nil {
+		return nil, errors.Wrap(err, "get failures of scan report from database")
+	}
+
+	return failures, nil
+}
+
+func (r *queryResolver) ScanReports(ctx context.Context, paginate *models.Pagination) ([]*models.ScanReport, error) {
+	query := models.FormatSQL(r.DB(ctx).Order("id DESC"), nil, paginate)
+
+	var reports []*models.ScanReport
+	if err := query.Find(&reports).Error; err != nil {
+		return nil, errors.Wrap(err, "get scan reports from database")
+	}
+
+	return reports, nil
+}
+
+func (r *queryResolver) ScanReport(ctx context.Context, id int) (*models.ScanReport, error) {
+	var report models.ScanReport
+	if err := r.DB(ctx).First(&report, id).Error; err != nil {
+		return nil, errors.Wrap(err, "get scan report from database")
+	}
+
+	return &report, nil
+}
+
+func (r *queryResolver) ScanHistory(ctx context.Context, paginate *models.Pagination) ([]*models.ScanStatistics, error) {
+	query := models.FormatSQL(r.DB(ctx).Order("id DESC"), nil, paginate)
+
+	var history []*models.ScanStatistics
+	if err := query.Find(&history).Error; err != nil {
+		return nil, errors.Wrap(err, "get scan history from database")
+	}
+
+	return history, nil
+}
@@ -2,9 +2,11 @@ package resolvers
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/photoview/photoview/api/database/drivers"
+	"github.com/photoview/photoview/api/graphql/auth"
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/scanner/periodic_scanner"
 	"github.com/photoview/photoview/api/scanner/scanner_queue"
@@ -13,7 +15,7 @@ import (
 )
 
 func (r *mutationResolver) ScanAll(ctx context.Context) (*models.ScannerResult, error) {
-	err := scanner_queue.AddAllToQueue()
+	err := scanner_queue.AddAllToQueue(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +35,7 @@ func (r *mutationResolver) ScanUser(ctx context.Context, userID int) (*models.Sc
 		return nil, errors.Wrap(err, "get user from database")
 	}
 
-	scanner_queue.AddUserToQueue(&user)
+	scanner_queue.AddUserToQueue(ctx, &user)
 
 	startMessage := "Scanner started"
 	return &models.ScannerResult{
@@ -43,6 +45,64 @@ func (r *mutationResolver) ScanUser(ctx context.Context, userID int) (*models.Sc
 	}, nil
 }
 
+func (r *mutationResolver) ScanAlbum(ctx context.Context, albumID int, recursive *bool) (*models.ScannerResult, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	db := r.DB(ctx)
+
+	var album models.Album
+	if err := db.First(&album, albumID).Error; err != nil {
+		return nil, errors.Wrap(err, "get album from database")
+	}
+
+	ownsAlbum, err := user.OwnsAlbum(db, &album)
+	if err != nil {
+		return nil, err
+	}
+	if !ownsAlbum {
+		return nil, auth.ErrUnauthorized
+	}
+
+	if recursive != nil && *recursive {
+		err = scanner_queue.AddAlbumTreeToQueue(ctx, user, &album)
+	} else {
+		err = scanner_queue.AddAlbumToQueue(ctx, &album)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	startMessage := "Scanner started"
+	return &models.ScannerResult{
+		Finished: false,
+		Success:  true,
+		Message:  &startMessage,
+	}, nil
+}
+
+func (r *mutationResolver) CancelScan(ctx context.Context, userID *int) (*models.ScannerResult, error) {
+	var removed, cancelled int
+	if userID != nil {
+		removed, cancelled = scanner_queue.CancelUserScan(ctx, *userID)
+	} else {
+		removed, cancelled = scanner_queue.CancelScan(ctx)
+	}
+
+	message := fmt.Sprintf("Scan cancelled, %d albums removed from the queue and %d albums stopped", removed, cancelled)
+	return &models.ScannerResult{
+		Finished: true,
+		Success:  true,
+		Message:  &message,
+	}, nil
+}
+
+func (r *queryResolver) ScannerQueue(ctx context.Context) ([]*models.ScannerQueueJob, error) {
+	return scanner_queue.QueuedJobs(), nil
+}
+
 func (r *mutationResolver) SetPeriodicScanInterval(ctx context.Context, interval int) (int, error) {
 	db := r.DB(ctx)
 	if interval < 0 {
@@ -0,0 +1,16 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/settings"
+)
+
+func (r *queryResolver) SiteSettings(ctx context.Context) ([]*models.SiteSetting, error) {
+	return settings.GetSettings(), nil
+}
+
+func (r *mutationResolver) SetSiteSetting(ctx context.Context, key string, value *string) (*models.SiteSetting, error) {
+	return settings.UpdateSetting(r.DB(ctx), key, value)
+}
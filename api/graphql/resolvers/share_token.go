@@ -12,6 +12,8 @@ import (
 	"github.com/photoview/photoview/api/graphql/auth"
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/graphql/models/actions"
+	"github.com/photoview/photoview/api/notifier"
+	"github.com/photoview/photoview/api/webhooks"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -61,6 +63,9 @@ func (r *queryResolver) ShareToken(ctx context.Context, credentials models.Share
 		}
 	}
 
+	webhooks.ShareAccessed(&token)
+	notifier.ShareViewed(&token)
+
 	return &token, nil
 }
 
@@ -93,22 +98,22 @@ func (r *queryResolver) ShareTokenValidatePassword(ctx context.Context, credenti
 	return true, nil
 }
 
-func (r *mutationResolver) ShareAlbum(ctx context.Context, albumID int, expire *time.Time, password *string) (*models.ShareToken, error) {
+func (r *mutationResolver) ShareAlbum(ctx context.Context, albumID int, expire *time.Time, password *string, stripMetadata *bool) (*models.ShareToken, error) {
 	user := auth.UserFromContext(ctx)
 	if user == nil {
 		return nil, auth.ErrUnauthorized
 	}
 
-	return actions.AddAlbumShare(r.DB(ctx), user, albumID, expire, password)
+	return actions.AddAlbumShare(r.DB(ctx), user, albumID, expire, password, stripMetadata)
 }
 
-func (r *mutationResolver) ShareMedia(ctx context.Context, mediaID int, expire *time.Time, password *string) (*models.ShareToken, error) {
+func (r *mutationResolver) ShareMedia(ctx context.Context, mediaID int, expire *time.Time, password *string, stripMetadata *bool) (*models.ShareToken, error) {
 	user := auth.UserFromContext(ctx)
 	if user == nil {
 		return nil, auth.ErrUnauthorized
 	}
 
-	return actions.AddMediaShare(r.DB(ctx), user, mediaID, expire, password)
+	return actions.AddMediaShare(r.DB(ctx), user, mediaID, expire, password, stripMetadata)
 }
 
 func (r *mutationResolver) DeleteShareToken(ctx context.Context, tokenValue string) (*models.ShareToken, error) {
@@ -128,3 +133,12 @@ func (r *mutationResolver) ProtectShareToken(ctx context.Context, tokenValue str
 
 	return actions.ProtectShareToken(r.DB(ctx), user.ID, tokenValue, password)
 }
+
+func (r *mutationResolver) SetShareTokenStripMetadata(ctx context.Context, tokenValue string, stripMetadata *bool) (*models.ShareToken, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	return actions.SetShareTokenStripMetadata(r.DB(ctx), user.ID, tokenValue, stripMetadata)
+}
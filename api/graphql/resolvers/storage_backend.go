@@ -0,0 +1,181 @@
+package resolvers
+
+import (
+	"context"
+	"path"
+
+	api "github.com/photoview/photoview/api/graphql"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type storageBackendResolver struct {
+	*Resolver
+}
+
+type storageMappingResolver struct {
+	*Resolver
+}
+
+func (r *Resolver) StorageBackend() api.StorageBackendResolver {
+	return storageBackendResolver{r}
+}
+
+func (r *Resolver) StorageMapping() api.StorageMappingResolver {
+	return storageMappingResolver{r}
+}
+
+func (r storageBackendResolver) Mappings(ctx context.Context, obj *models.StorageBackend) ([]*models.StorageMapping, error) {
+	var mappings []*models.StorageMapping
+	if err := r.DB(ctx).Where("storage_backend_id = ?", obj.ID).Order("album_path").Find(&mappings).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage mappings from database")
+	}
+
+	return mappings, nil
+}
+
+func (r storageMappingResolver) Backend(ctx context.Context, obj *models.StorageMapping) (*models.StorageBackend, error) {
+	if obj.StorageBackend != nil {
+		return obj.StorageBackend, nil
+	}
+
+	var backend models.StorageBackend
+	if err := r.DB(ctx).First(&backend, obj.StorageBackendID).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage backend from database")
+	}
+
+	return &backend, nil
+}
+
+func (r *queryResolver) StorageBackends(ctx context.Context) ([]*models.StorageBackend, error) {
+	var backends []*models.StorageBackend
+	if err := r.DB(ctx).Order("name").Find(&backends).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage backends from database")
+	}
+
+	return backends, nil
+}
+
+func (r *queryResolver) StorageDiagnostics(ctx context.Context, sampleSize *int) ([]*models.StorageDiagnostics, error) {
+	size := 0
+	if sampleSize != nil {
+		size = *sampleSize
+	}
+
+	return storage.DiagnoseStorageBackends(r.DB(ctx), size)
+}
+
+func (r *mutationResolver) CreateStorageBackend(ctx context.Context, name string, backendPath string, cold *bool) (*models.StorageBackend, error) {
+	if !path.IsAbs(backendPath) {
+		return nil, errors.New("storage backend path must be absolute")
+	}
+
+	backend := models.StorageBackend{
+		Name: name,
+		Path: path.Clean(backendPath),
+		Cold: cold != nil && *cold,
+	}
+
+	if err := r.DB(ctx).Create(&backend).Error; err != nil {
+		return nil, errors.Wrap(err, "insert storage backend into database")
+	}
+
+	return &backend, nil
+}
+
+func (r *mutationResolver) DeleteStorageBackend(ctx context.Context, id int) (*models.StorageBackend, error) {
+	db := r.DB(ctx)
+
+	var backend models.StorageBackend
+	if err := db.First(&backend, id).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage backend from database")
+	}
+
+	if err := db.Select("Mappings").Delete(&backend).Error; err != nil {
+		return nil, errors.Wrap(err, "delete storage backend")
+	}
+
+	return &backend, nil
+}
+
+func (r *mutationResolver) MapStoragePath(ctx context.Context, albumPath string, backendID int, subPath *string) (*models.StorageMapping, error) {
+	db := r.DB(ctx)
+
+	if !path.IsAbs(albumPath) {
+		return nil, errors.New("album path must be absolute")
+	}
+	albumPath = path.Clean(albumPath)
+
+	var backend models.StorageBackend
+	if err := db.First(&backend, backendID).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage backend from database")
+	}
+
+	var existingCount int64
+	if err := db.Model(&models.StorageMapping{}).Where("album_path = ?", albumPath).Count(&existingCount).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage mappings from database")
+	}
+
+	if existingCount > 0 {
+		return nil, errors.Errorf("album path is already mapped to a storage backend: %s", albumPath)
+	}
+
+	mapping := models.StorageMapping{
+		AlbumPath:        albumPath,
+		StorageBackendID: backend.ID,
+		StorageBackend:   &backend,
+	}
+
+	if subPath != nil {
+		mapping.SubPath = path.Clean("/" + *subPath)[1:]
+	}
+
+	// Albums already scanned within the subtree are moved to the backend, so the next scan does not replace them
+	err := db.Transaction(func(tx *gorm.DB) error {
+		oldPath, err := models.ResolveStoragePath(tx, albumPath)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Omit("StorageBackend").Create(&mapping).Error; err != nil {
+			return errors.Wrap(err, "insert storage mapping into database")
+		}
+
+		return models.MoveStoragePaths(tx, oldPath, mapping.BackendPath())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+func (r *mutationResolver) UnmapStoragePath(ctx context.Context, id int) (*models.StorageMapping, error) {
+	db := r.DB(ctx)
+
+	var mapping models.StorageMapping
+	if err := db.Joins("StorageBackend").First(&mapping, "storage_mappings.id = ?", id).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage mapping from database")
+	}
+
+	// Albums within the subtree are moved back to where it is located without the mapping
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&mapping).Error; err != nil {
+			return errors.Wrap(err, "delete storage mapping")
+		}
+
+		newPath, err := models.ResolveStoragePath(tx, mapping.AlbumPath)
+		if err != nil {
+			return err
+		}
+
+		return models.MoveStoragePaths(tx, mapping.BackendPath(), newPath)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &mapping, nil
+}
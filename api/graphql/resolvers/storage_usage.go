@@ -0,0 +1,39 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/pkg/errors"
+)
+
+func (r *queryResolver) StorageUsageByUser(ctx context.Context) ([]*models.UserStorageUsage, error) {
+	return storage.GetUserStorageUsage(r.DB(ctx))
+}
+
+func (r *queryResolver) StorageUsageByAlbum(ctx context.Context, userID *int, paginate *models.Pagination) ([]*models.AlbumStorageUsage, error) {
+	db := r.DB(ctx)
+
+	query := db.Preload("Album").Order("original_bytes + cache_bytes DESC, album_id")
+	if userID != nil {
+		query = query.Where("album_id IN (?)", db.Table("user_albums").Select("album_id").Where("user_id = ?", *userID))
+	}
+
+	var usages []*models.AlbumStorageUsage
+	if err := models.FormatSQL(query, nil, paginate).Find(&usages).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage usage of albums from database")
+	}
+
+	return usages, nil
+}
+
+func (r *mutationResolver) RefreshStorageUsage(ctx context.Context) ([]*models.UserStorageUsage, error) {
+	db := r.DB(ctx)
+
+	if err := storage.RefreshStorageUsage(db); err != nil {
+		return nil, err
+	}
+
+	return storage.GetUserStorageUsage(db)
+}
@@ -0,0 +1,12 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/stats"
+)
+
+func (r *queryResolver) UsageStatistics(ctx context.Context, refresh *bool) (*models.UsageStatistics, error) {
+	return stats.GetUsageStatistics(r.DB(ctx), refresh != nil && *refresh)
+}
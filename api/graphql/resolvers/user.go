@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"strings"
 
 	api "github.com/photoview/photoview/api/graphql"
 	"github.com/photoview/photoview/api/graphql/auth"
@@ -12,7 +13,9 @@ import (
 	"github.com/photoview/photoview/api/graphql/models/actions"
 	"github.com/photoview/photoview/api/scanner"
 	"github.com/photoview/photoview/api/scanner/face_detection"
+	"github.com/photoview/photoview/api/scanner/path_exclusion"
 	"github.com/photoview/photoview/api/utils"
+	"github.com/photoview/photoview/api/webhooks"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -200,6 +203,45 @@ func (r *mutationResolver) ChangeUserPreferences(ctx context.Context, language *
 	return &userPref, nil
 }
 
+func (r *mutationResolver) SetExcludePatterns(ctx context.Context, patterns []string) (*models.UserPreferences, error) {
+	db := r.DB(ctx)
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	cleanPatterns := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		// Patterns are stored one per line
+		if strings.Contains(pattern, "\n") {
+			return nil, errors.Errorf("exclusion pattern spans multiple lines (%s)", pattern)
+		}
+		if err := path_exclusion.Validate(pattern); err != nil {
+			return nil, err
+		}
+		cleanPatterns = append(cleanPatterns, pattern)
+	}
+
+	userPref := models.UserPreferences{
+		UserID: user.ID,
+	}
+	if err := db.Where("user_id = ?", user.ID).FirstOrCreate(&userPref).Error; err != nil {
+		return nil, err
+	}
+
+	userPref.SetExcludePatterns(cleanPatterns)
+	if err := db.Model(&userPref).Select("exclude_patterns").Updates(&userPref).Error; err != nil {
+		return nil, errors.Wrap(err, "save exclusion patterns")
+	}
+
+	return &userPref, nil
+}
+
 // Admin queries
 func (r *mutationResolver) UpdateUser(ctx context.Context, id int, username *string, password *string, admin *bool) (*models.User, error) {
 	db := r.DB(ctx)
@@ -256,6 +298,8 @@ func (r *mutationResolver) CreateUser(ctx context.Context, username string, pass
 		return nil, transactionError
 	}
 
+	webhooks.UserCreated(user)
+
 	return user, nil
 }
 
@@ -0,0 +1,88 @@
+package resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/graphql/notification"
+	"github.com/pkg/errors"
+)
+
+func (r *queryResolver) MyNotifications(ctx context.Context, unreadOnly *bool, paginate *models.Pagination) ([]*models.UserNotification, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	query := r.DB(ctx).Where("user_id = ?", user.ID)
+	if unreadOnly != nil && *unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	query = models.FormatSQL(query.Order("created_at DESC, id DESC"), nil, paginate)
+
+	var notifications []*models.UserNotification
+	if err := query.Find(&notifications).Error; err != nil {
+		return nil, errors.Wrap(err, "get notifications from database")
+	}
+
+	return notifications, nil
+}
+
+func (r *queryResolver) UnreadNotificationCount(ctx context.Context) (int, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return 0, auth.ErrUnauthorized
+	}
+
+	var count int64
+	err := r.DB(ctx).Model(&models.UserNotification{}).
+		Where("user_id = ? AND read_at IS NULL", user.ID).
+		Count(&count).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "count unread notifications")
+	}
+
+	return int(count), nil
+}
+
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, ids []int) (int, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return 0, auth.ErrUnauthorized
+	}
+
+	query := r.DB(ctx).Model(&models.UserNotification{}).Where("user_id = ? AND read_at IS NULL", user.ID)
+
+	// Without ids, all notifications of the user are marked as read
+	if ids != nil {
+		query = query.Where("id IN (?)", ids)
+	}
+
+	result := query.Update("read_at", time.Now())
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "mark notifications as read")
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+func (r *subscriptionResolver) UserNotification(ctx context.Context) (<-chan *models.UserNotification, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	notificationChannel := make(chan *models.UserNotification, 1)
+
+	listenerID := notification.RegisterUserNotificationListener(user, notificationChannel)
+
+	go func() {
+		<-ctx.Done()
+		notification.DeregisterUserNotificationListener(listenerID)
+	}()
+
+	return notificationChannel, nil
+}
@@ -0,0 +1,136 @@
+package resolvers
+
+import (
+	"context"
+	"strings"
+
+	api "github.com/photoview/photoview/api/graphql"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type watermarkResolver struct {
+	*Resolver
+}
+
+func (r *Resolver) Watermark() api.WatermarkResolver {
+	return watermarkResolver{r}
+}
+
+func (r watermarkResolver) Image(ctx context.Context, obj *models.Watermark) (*models.Media, error) {
+	if obj.ImageMediaID == nil {
+		return nil, nil
+	}
+
+	var media models.Media
+	if err := r.DB(ctx).First(&media, *obj.ImageMediaID).Error; err != nil {
+		return nil, errors.Wrap(err, "get image of watermark")
+	}
+
+	return &media, nil
+}
+
+func (r *queryResolver) MyWatermark(ctx context.Context) (*models.Watermark, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	var watermark models.Watermark
+	if err := r.DB(ctx).Where("user_id = ?", user.ID).First(&watermark).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "get watermark of user")
+	}
+
+	return &watermark, nil
+}
+
+func (r *mutationResolver) SetWatermark(ctx context.Context, enabled bool, text *string, imageMediaID *int,
+	position *models.WatermarkPosition, opacity *float64) (*models.Watermark, error) {
+
+	db := r.DB(ctx)
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	if text != nil && imageMediaID != nil {
+		return nil, errors.New("a watermark can not have both a text and an image")
+	}
+
+	watermark := models.Watermark{
+		UserID:   user.ID,
+		Position: models.WatermarkPositionBottomRight,
+		Opacity:  0.5,
+	}
+	if err := db.Where("user_id = ?", user.ID).First(&watermark).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(err, "get watermark of user")
+	}
+
+	watermark.Enabled = enabled
+
+	if text != nil {
+		trimmed := strings.TrimSpace(*text)
+		if trimmed == "" {
+			return nil, errors.New("watermark text must not be empty")
+		}
+		watermark.Text = &trimmed
+		watermark.ImageMediaID = nil
+	}
+
+	if imageMediaID != nil {
+		var media models.Media
+		if err := db.Joins("Album").First(&media, *imageMediaID).Error; err != nil {
+			return nil, errors.Wrap(err, "get media from database")
+		}
+
+		ownsAlbum, err := user.OwnsAlbum(db, &media.Album)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ownsAlbum {
+			return nil, auth.ErrUnauthorized
+		}
+
+		var pngCount int64
+		err = db.Model(&models.MediaURL{}).
+			Where("media_id = ? AND purpose = ? AND content_type = ?", media.ID, models.MediaOriginal, "image/png").
+			Count(&pngCount).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "get original of watermark image")
+		}
+
+		if pngCount == 0 {
+			return nil, errors.New("watermark image must be a PNG photo")
+		}
+
+		watermark.ImageMediaID = &media.ID
+		watermark.Text = nil
+	}
+
+	if position != nil {
+		watermark.Position = *position
+	}
+
+	if opacity != nil {
+		if *opacity < 0 || *opacity > 1 {
+			return nil, errors.New("watermark opacity must be between 0 and 1")
+		}
+		watermark.Opacity = *opacity
+	}
+
+	if watermark.Enabled && watermark.Text == nil && watermark.ImageMediaID == nil {
+		return nil, errors.New("an enabled watermark must have a text or an image")
+	}
+
+	if err := db.Save(&watermark).Error; err != nil {
+		return nil, errors.Wrap(err, "save watermark")
+	}
+
+	return &watermark, nil
+}
@@ -0,0 +1,87 @@
+package resolvers
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	if err := r.DB(ctx).Order("id").Find(&webhooks).Error; err != nil {
+		return nil, errors.Wrap(err, "get webhooks from database")
+	}
+
+	return webhooks, nil
+}
+
+func (r *mutationResolver) CreateWebhook(ctx context.Context, webhookURL string, events []models.WebhookEvent) (*models.Webhook, error) {
+	if err := validateWebhookURL(webhookURL); err != nil {
+		return nil, err
+	}
+
+	webhook := models.Webhook{
+		URL:    webhookURL,
+		Secret: utils.GenerateToken() + utils.GenerateToken() + utils.GenerateToken() + utils.GenerateToken(),
+	}
+	webhook.SetEvents(events)
+
+	if err := r.DB(ctx).Create(&webhook).Error; err != nil {
+		return nil, errors.Wrap(err, "create webhook")
+	}
+
+	return &webhook, nil
+}
+
+func (r *mutationResolver) UpdateWebhook(ctx context.Context, id int, webhookURL *string, events []models.WebhookEvent) (*models.Webhook, error) {
+	db := r.DB(ctx)
+
+	var webhook models.Webhook
+	if err := db.First(&webhook, id).Error; err != nil {
+		return nil, errors.Wrap(err, "get webhook from database")
+	}
+
+	if webhookURL != nil {
+		if err := validateWebhookURL(*webhookURL); err != nil {
+			return nil, err
+		}
+		webhook.URL = *webhookURL
+	}
+
+	if events != nil {
+		webhook.SetEvents(events)
+	}
+
+	if err := db.Save(&webhook).Error; err != nil {
+		return nil, errors.Wrap(err, "update webhook")
+	}
+
+	return &webhook, nil
+}
+
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id int) (*models.Webhook, error) {
+	db := r.DB(ctx)
+
+	var webhook models.Webhook
+	if err := db.First(&webhook, id).Error; err != nil {
+		return nil, errors.Wrap(err, "get webhook from database")
+	}
+
+	if err := db.Delete(&webhook).Error; err != nil {
+		return nil, errors.Wrap(err, "delete webhook")
+	}
+
+	return &webhook, nil
+}
+
+func validateWebhookURL(webhookURL string) error {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("webhook url must be an absolute http or https url")
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/pkg/errors"
+)
+
+// parseApplePhotosExport finds the media of an export from Apple Photos, either made by the Photos app or by osxphotos.
+// Each directory is considered an album, and metadata is read from XMP sidecars ("IMG_0001.xmp" or "IMG_0001.jpg.xmp"),
+// or from the exiftool style json sidecars written by osxphotos ("IMG_0001.jpg.json").
+func parseApplePhotosExport(export fs.FS) ([]*importItem, error) {
+	items := make([]*importItem, 0)
+
+	err := fs.WalkDir(export, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			// Skip hidden directories, such as the export database of osxphotos
+			if filePath != "." && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		mediaType, found := media_type.GetExtensionMediaType(path.Ext(d.Name()))
+		if !found || !mediaType.IsSupported() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		item := &importItem{
+			path: filePath,
+		}
+
+		if dir := path.Dir(filePath); dir != "." {
+			item.album = path.Base(dir)
+		}
+
+		metadata, err := readAppleSidecar(export, filePath)
+		if err != nil {
+			return err
+		}
+		if metadata != nil {
+			item.metadata = *metadata
+		}
+
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "walk export")
+	}
+
+	return items, nil
+}
+
+// readAppleSidecar reads the metadata of the sidecar of the media file, returns nil if it has no sidecar
+func readAppleSidecar(export fs.FS, mediaPath string) (*ImportMetadata, error) {
+	ext := path.Ext(mediaPath)
+	withoutExt := strings.TrimSuffix(mediaPath, ext)
+
+	for _, sidecarPath := range []string{mediaPath + ".xmp", withoutExt + ".xmp", withoutExt + ".XMP"} {
+		data, err := fs.ReadFile(export, sidecarPath)
+		if err != nil {
+			continue
+		}
+
+		properties, err := parseXMP(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse xmp sidecar (%s)", sidecarPath)
+		}
+
+		metadata := properties.metadata()
+		return &metadata, nil
+	}
+
+	data, err := fs.ReadFile(export, mediaPath+".json")
+	if err != nil {
+		return nil, nil
+	}
+
+	metadata, err := parseExiftoolJSON(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse json sidecar (%s)", mediaPath+".json")
+	}
+
+	return metadata, nil
+}
+
+// parseExiftoolJSON reads the metadata of a json sidecar in the format of `exiftool -json -G`,
+// an array holding a single object with keys prefixed by their group, eg. "XMP:Description"
+func parseExiftoolJSON(data []byte) (*ImportMetadata, error) {
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	properties := make(xmpProperties)
+	if len(entries) > 0 {
+		for key, value := range entries[0] {
+			name := key
+			if index := strings.LastIndex(key, ":"); index >= 0 {
+				name = key[index+1:]
+			}
+
+			switch v := value.(type) {
+			case []interface{}:
+				for _, element := range v {
+					properties.add(name, fmt.Sprint(element))
+				}
+			case float64:
+				properties.add(name, strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				properties.add(name, fmt.Sprint(v))
+			}
+		}
+	}
+
+	metadata := properties.metadata()
+
+	// Exiftool writes coordinates as signed decimal numbers, with the direction in a separate reference tag
+	latitude, latErr := strconv.ParseFloat(properties.first("GPSLatitude"), 64)
+	longitude, lonErr := strconv.ParseFloat(properties.first("GPSLongitude"), 64)
+	if latErr == nil && lonErr == nil {
+		if strings.HasPrefix(properties.first("GPSLatitudeRef"), "S") && latitude > 0 {
+			latitude = -latitude
+		}
+		if strings.HasPrefix(properties.first("GPSLongitudeRef"), "W") && longitude > 0 {
+			longitude = -longitude
+		}
+		metadata.Latitude = &latitude
+		metadata.Longitude = &longitude
+	}
+
+	if offset := properties.first("OffsetTimeOriginal"); offset != "" {
+		if takenAt := parseMetadataDate(properties.first("DateTimeOriginal") + offset); takenAt != nil {
+			metadata.TakenAt = takenAt
+		}
+	}
+
+	return &metadata, nil
+}
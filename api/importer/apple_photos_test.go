@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testXMPSidecar = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+  <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+    <rdf:Description rdf:about=""
+        xmlns:dc="http://purl.org/dc/elements/1.1/"
+        xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+        xmlns:exif="http://ns.adobe.com/exif/1.0/"
+        xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+        xmlns:Iptc4xmpExt="http://iptc.org/std/Iptc4xmpExt/2008-02-29/"
+        photoshop:DateCreated="2019-07-04T12:00:00+02:00"
+        xmp:Rating="5">
+      <dc:description>
+        <rdf:Alt>
+          <rdf:li xml:lang="x-default">Sunset at the beach</rdf:li>
+        </rdf:Alt>
+      </dc:description>
+      <Iptc4xmpExt:PersonInImage>
+        <rdf:Bag>
+          <rdf:li>Alice</rdf:li>
+          <rdf:li>Bob</rdf:li>
+        </rdf:Bag>
+      </Iptc4xmpExt:PersonInImage>
+      <exif:GPSLatitude>55,30.0N</exif:GPSLatitude>
+      <exif:GPSLongitude>12,15,36W</exif:GPSLongitude>
+    </rdf:Description>
+  </rdf:RDF>
+</x:xmpmeta>`
+
+const testExiftoolSidecar = `[{
+  "SourceFile": "IMG_0002.heic",
+  "XMP:Description": "Birthday",
+  "XMP:PersonInImage": ["Carol"],
+  "EXIF:DateTimeOriginal": "2020:01:02 10:00:00",
+  "EXIF:OffsetTimeOriginal": "+01:00",
+  "EXIF:GPSLatitude": 33.5,
+  "EXIF:GPSLatitudeRef": "S",
+  "EXIF:GPSLongitude": 151.2,
+  "EXIF:GPSLongitudeRef": "E",
+  "XMP:Rating": 0
+}]`
+
+func TestParseApplePhotosExport(t *testing.T) {
+	export := fstest.MapFS{
+		"Vacation/IMG_0001.jpg":           {Data: []byte("IMAGE")},
+		"Vacation/IMG_0001.xmp":           {Data: []byte(testXMPSidecar)},
+		"Family/IMG_0002.heic":            {Data: []byte("IMAGE")},
+		"Family/IMG_0002.heic.json":       {Data: []byte(testExiftoolSidecar)},
+		"IMG_0003.png":                    {Data: []byte("IMAGE")},
+		".osxphotos_export/IMG_0004.jpg":  {Data: []byte("IMAGE")},
+		"Family/.osxphotos_export.db":     {Data: []byte("DATABASE")},
+		"Family/IMG_0002_edited.heic.xmp": {Data: []byte("<invalid")},
+	}
+
+	items, err := parseApplePhotosExport(export)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	byPath := make(map[string]*importItem)
+	for _, item := range items {
+		byPath[item.path] = item
+	}
+
+	assert.Len(t, byPath, 3)
+
+	vacation := byPath["Vacation/IMG_0001.jpg"]
+	if assert.NotNil(t, vacation) {
+		assert.Equal(t, "Vacation", vacation.album)
+		assert.Equal(t, "Sunset at the beach", *vacation.metadata.Description)
+		assert.Equal(t, []string{"Alice", "Bob"}, vacation.metadata.People)
+		assert.True(t, vacation.metadata.Favorite)
+		assert.Equal(t, time.Date(2019, 7, 4, 10, 0, 0, 0, time.UTC), vacation.metadata.TakenAt.UTC())
+		assert.Equal(t, 55.5, *vacation.metadata.Latitude)
+		assert.Equal(t, -12.26, *vacation.metadata.Longitude)
+	}
+
+	family := byPath["Family/IMG_0002.heic"]
+	if assert.NotNil(t, family) {
+		assert.Equal(t, "Family", family.album)
+		assert.Equal(t, "Birthday", *family.metadata.Description)
+		assert.Equal(t, []string{"Carol"}, family.metadata.People)
+		assert.False(t, family.metadata.Favorite)
+		assert.Equal(t, time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC), family.metadata.TakenAt.UTC())
+		assert.Equal(t, -33.5, *family.metadata.Latitude)
+		assert.Equal(t, 151.2, *family.metadata.Longitude)
+	}
+
+	root := byPath["IMG_0003.png"]
+	if assert.NotNil(t, root) {
+		assert.Equal(t, "", root.album)
+		assert.Nil(t, root.metadata.Description)
+	}
+}
@@ -0,0 +1,233 @@
+package importer
+
+import (
+	"encoding/json"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/pkg/errors"
+)
+
+// Folders holding all photos of a year, rather than an album created by the user
+var takeoutYearFolder = regexp.MustCompile(`^Photos from \d{4}$`)
+
+// Duplicate file names are numbered before the extension, eg. "IMG(1).jpg", while the sidecar is numbered after it, eg. "IMG.jpg(1).json"
+var takeoutDuplicateNumber = regexp.MustCompile(`^(.*)(\(\d+\))(\.[^.]*)$`)
+
+// Takeout truncates the name of sidecar files to this length, excluding the ".json" extension
+const takeoutMaxSidecarName = 46
+
+type takeoutGeoData struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type takeoutMetadata struct {
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	PhotoTakenTime struct {
+		Timestamp string `json:"timestamp"`
+	} `json:"photoTakenTime"`
+	GeoData     takeoutGeoData `json:"geoData"`
+	GeoDataExif takeoutGeoData `json:"geoDataExif"`
+	People      []struct {
+		Name string `json:"name"`
+	} `json:"people"`
+	Favorited bool `json:"favorited"`
+}
+
+type takeoutAlbumMetadata struct {
+	Title string `json:"title"`
+}
+
+// parseGoogleTakeout finds the media of a Google Photos export from Google Takeout.
+// Every media file is accompanied by a json sidecar file holding its metadata.
+// Media appearing both in an album and in the folder of its year is only imported once, as part of the album.
+func parseGoogleTakeout(export fs.FS) ([]*importItem, error) {
+	items := make([]*importItem, 0)
+	seen := make(map[string]*importItem)
+
+	dirs := make([]string, 0)
+	err := fs.WalkDir(export, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			dirs = append(dirs, filePath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "walk export")
+	}
+
+	for _, dir := range dirs {
+		entries, err := fs.ReadDir(export, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read export directory (%s)", dir)
+		}
+
+		album := takeoutAlbumTitle(export, dir)
+
+		sidecars := make(map[string]bool)
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+				sidecars[entry.Name()] = true
+			}
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			mediaType, found := media_type.GetExtensionMediaType(path.Ext(entry.Name()))
+			if !found || !mediaType.IsSupported() {
+				continue
+			}
+
+			item := &importItem{
+				path:  path.Join(dir, entry.Name()),
+				album: album,
+			}
+
+			if sidecar := takeoutSidecarName(entry.Name(), sidecars); sidecar != "" {
+				metadata, err := readTakeoutSidecar(export, path.Join(dir, sidecar))
+				if err != nil {
+					return nil, err
+				}
+				item.metadata = *metadata
+			}
+
+			key := entry.Name()
+			if item.metadata.TakenAt != nil {
+				key += "@" + strconv.FormatInt(item.metadata.TakenAt.Unix(), 10)
+			}
+
+			if existing, found := seen[key]; found {
+				// Prefer the copy inside an album, over the one in the folder of the year
+				if existing.album == "" && album != "" {
+					existing.path = item.path
+					existing.album = album
+				}
+				continue
+			}
+
+			seen[key] = item
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// takeoutAlbumTitle returns the title of the album of the directory, or an empty string if it is not an album
+func takeoutAlbumTitle(export fs.FS, dir string) string {
+	name := path.Base(dir)
+	if dir == "." || takeoutYearFolder.MatchString(name) || name == "Google Photos" || name == "Takeout" {
+		return ""
+	}
+
+	data, err := fs.ReadFile(export, path.Join(dir, "metadata.json"))
+	if err == nil {
+		var albumMetadata takeoutAlbumMetadata
+		if json.Unmarshal(data, &albumMetadata) == nil && albumMetadata.Title != "" {
+			return albumMetadata.Title
+		}
+	}
+
+	return name
+}
+
+// takeoutSidecarName finds the name of the json sidecar file of the media file, among the json files of the directory
+func takeoutSidecarName(filename string, sidecars map[string]bool) string {
+	candidates := make([]string, 0, 4)
+
+	base := filename
+	number := ""
+	if match := takeoutDuplicateNumber.FindStringSubmatch(filename); match != nil {
+		base = match[1] + match[3]
+		number = match[2]
+	}
+
+	// Edited copies share the sidecar of the original
+	ext := path.Ext(base)
+	original := strings.TrimSuffix(base, ext)
+	for _, suffix := range []string{"-edited", "-bearbeitet", "-modifié"} {
+		original = strings.TrimSuffix(original, suffix)
+	}
+	original += ext
+
+	for _, name := range []string{base, original} {
+		for _, kind := range []string{"", ".supplemental-metadata"} {
+			sidecar := name + kind
+			if len(sidecar) > takeoutMaxSidecarName {
+				sidecar = sidecar[:takeoutMaxSidecarName]
+			}
+			candidates = append(candidates, sidecar+number+".json")
+		}
+	}
+
+	for _, candidate := range candidates {
+		if sidecars[candidate] {
+			return candidate
+		}
+	}
+
+	// Newer exports truncate the supplemental metadata suffix at varying lengths
+	for sidecar := range sidecars {
+		if strings.HasPrefix(sidecar, base+".supp") || strings.HasPrefix(sidecar, original+".supp") {
+			return sidecar
+		}
+	}
+
+	return ""
+}
+
+func readTakeoutSidecar(export fs.FS, sidecarPath string) (*ImportMetadata, error) {
+	data, err := fs.ReadFile(export, sidecarPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read sidecar (%s)", sidecarPath)
+	}
+
+	var sidecar takeoutMetadata
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return nil, errors.Wrapf(err, "parse sidecar (%s)", sidecarPath)
+	}
+
+	metadata := ImportMetadata{
+		Favorite: sidecar.Favorited,
+	}
+
+	if sidecar.Description != "" {
+		metadata.Description = &sidecar.Description
+	}
+
+	if timestamp, err := strconv.ParseInt(sidecar.PhotoTakenTime.Timestamp, 10, 64); err == nil && timestamp > 0 {
+		takenAt := time.Unix(timestamp, 0).UTC()
+		metadata.TakenAt = &takenAt
+	}
+
+	// Coordinates of exactly zero means that the location is unknown
+	for _, geoData := range []takeoutGeoData{sidecar.GeoData, sidecar.GeoDataExif} {
+		if geoData.Latitude != 0 || geoData.Longitude != 0 {
+			latitude, longitude := geoData.Latitude, geoData.Longitude
+			metadata.Latitude = &latitude
+			metadata.Longitude = &longitude
+			break
+		}
+	}
+
+	for _, person := range sidecar.People {
+		metadata.People = append(metadata.People, person.Name)
+	}
+
+	return &metadata, nil
+}
@@ -0,0 +1,66 @@
+package importer
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTakeoutSidecarName(t *testing.T) {
+	sidecars := map[string]bool{
+		"IMG_0001.jpg.json":                                   true,
+		"IMG_0002.jpg(1).json":                                true,
+		"IMG_0003.jpg.supplemental-metadata.json":             true,
+		"IMG_0004.jpg.supplemental-me.json":                   true,
+		"Screenshot_20210101-123456_Some Long Applicati.json": true,
+	}
+
+	cases := map[string]string{
+		"IMG_0001.jpg":        "IMG_0001.jpg.json",
+		"IMG_0001-edited.jpg": "IMG_0001.jpg.json",
+		"IMG_0002(1).jpg":     "IMG_0002.jpg(1).json",
+		"IMG_0003.jpg":        "IMG_0003.jpg.supplemental-metadata.json",
+		"IMG_0004.jpg":        "IMG_0004.jpg.supplemental-me.json",
+		"Screenshot_20210101-123456_Some Long Application.jpg": "Screenshot_20210101-123456_Some Long Applicati.json",
+		"IMG_0005.jpg": "",
+	}
+
+	for filename, expected := range cases {
+		assert.Equal(t, expected, takeoutSidecarName(filename, sidecars), filename)
+	}
+}
+
+func TestParseGoogleTakeout(t *testing.T) {
+	export := fstest.MapFS{
+		"Takeout/Google Photos/Photos from 2019/IMG_0001.jpg":      {Data: []byte("IMAGE")},
+		"Takeout/Google Photos/Photos from 2019/IMG_0001.jpg.json": {Data: []byte(`{"photoTakenTime": {"timestamp": "1562241600"}}`)},
+		"Takeout/Google Photos/Photos from 2019/IMG_0002.jpg":      {Data: []byte("IMAGE")},
+		"Takeout/Google Photos/Trip/IMG_0001.jpg":                  {Data: []byte("IMAGE")},
+		"Takeout/Google Photos/Trip/IMG_0001.jpg.json":             {Data: []byte(`{"photoTakenTime": {"timestamp": "1562241600"}, "geoData": {"latitude": 0, "longitude": 0}}`)},
+		"Takeout/Google Photos/Trip/metadata.json":                 {Data: []byte(`{"title": "Summer trip"}`)},
+		"Takeout/Google Photos/Trip/notes.txt":                     {Data: []byte("TEXT")},
+	}
+
+	items, err := parseGoogleTakeout(export)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	albums := make(map[string]string)
+	for _, item := range items {
+		albums[item.path] = item.album
+	}
+
+	assert.Equal(t, map[string]string{
+		"Takeout/Google Photos/Trip/IMG_0001.jpg":             "Summer trip",
+		"Takeout/Google Photos/Photos from 2019/IMG_0002.jpg": "",
+	}, albums)
+
+	for _, item := range items {
+		if item.album != "" {
+			assert.NotNil(t, item.metadata.TakenAt)
+			assert.Nil(t, item.metadata.Latitude)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type immichAsset struct {
+	ID               string     `gorm:"column:id"`
+	OriginalPath     string     `gorm:"column:originalPath"`
+	IsFavorite       bool       `gorm:"column:isFavorite"`
+	Description      *string    `gorm:"column:description"`
+	Latitude         *float64   `gorm:"column:latitude"`
+	Longitude        *float64   `gorm:"column:longitude"`
+	DateTimeOriginal *time.Time `gorm:"column:dateTimeOriginal"`
+}
+
+// readImmich reads the media of an Immich database, optionally only those of the user with the given email.
+// Immich does not record whether metadata has been edited, so its values are only applied where the media files have none.
+func readImmich(source *gorm.DB, sourceUser string) ([]*migrationItem, error) {
+	query := source.Table("assets").
+		Select(`assets.id, assets."originalPath", assets."isFavorite", exif.description, exif.latitude, exif.longitude, exif."dateTimeOriginal"`).
+		Joins(`LEFT JOIN exif ON exif."assetId" = assets.id`).
+		Where(`assets."deletedAt" IS NULL`)
+
+	if sourceUser != "" {
+		query = query.Joins(`JOIN users ON users.id = assets."ownerId"`).Where("users.email = ?", sourceUser)
+	}
+
+	var assets []*immichAsset
+	if err := query.Scan(&assets).Error; err != nil {
+		return nil, errors.Wrap(err, "get assets")
+	}
+
+	items := make([]*migrationItem, 0, len(assets))
+	assetItems := make(map[string]*migrationItem, len(assets))
+
+	for _, asset := range assets {
+		item := &migrationItem{
+			path: asset.OriginalPath,
+			metadata: ImportMetadata{
+				Favorite: asset.IsFavorite,
+				TakenAt:  asset.DateTimeOriginal,
+			},
+		}
+
+		if asset.Description != nil && *asset.Description != "" {
+			item.metadata.Description = asset.Description
+		}
+
+		if asset.Latitude != nil && asset.Longitude != nil {
+			item.metadata.Latitude, item.metadata.Longitude = asset.Latitude, asset.Longitude
+		}
+
+		items = append(items, item)
+		assetItems[asset.ID] = item
+	}
+
+	var people []struct {
+		AssetID string `gorm:"column:assetId"`
+		Name    string `gorm:"column:name"`
+	}
+	err := source.Table("asset_faces").
+		Select(`DISTINCT asset_faces."assetId", person.name`).
+		Joins(`JOIN person ON person.id = asset_faces."personId"`).
+		Where("person.name <> ''").
+		Scan(&people).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get people")
+	}
+
+	for _, person := range people {
+		if item, found := assetItems[person.AssetID]; found {
+			item.metadata.People = append(item.metadata.People, person.Name)
+		}
+	}
+
+	var albums []struct {
+		AssetID   string `gorm:"column:assetsId"`
+		AlbumName string `gorm:"column:albumName"`
+	}
+	err = source.Table("albums_assets_assets").
+		Select(`albums_assets_assets."assetsId", albums."albumName"`).
+		Joins(`JOIN albums ON albums.id = albums_assets_assets."albumsId"`).
+		Where(`albums."deletedAt" IS NULL`).
+		Scan(&albums).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get albums")
+	}
+
+	for _, album := range albums {
+		if item, found := assetItems[album.AssetID]; found {
+			item.albums = append(item.albums, album.AlbumName)
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,297 @@
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/maintenance"
+	"github.com/photoview/photoview/api/notifier"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/scanner/scanner_queue"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// DefaultLayout places imported media in a sub album for each album of the export
+const DefaultLayout = "{album}"
+
+// ImportMetadata is the metadata of a media file, as exported by the service it is imported from
+type ImportMetadata struct {
+	Description *string
+	TakenAt     *time.Time
+	Latitude    *float64
+	Longitude   *float64
+	People      []string
+	Favorite    bool
+	// Override is set if the values were edited by the user, and take precedence over values read from the media file
+	Override bool
+}
+
+// importItem is a media file found in an export
+type importItem struct {
+	// path of the file within the export
+	path     string
+	album    string
+	metadata ImportMetadata
+}
+
+// exportParser finds the media files of an export along with their metadata
+type exportParser func(export fs.FS) ([]*importItem, error)
+
+var exportParsers = map[models.ImportSource]exportParser{
+	models.ImportSourceGoogleTakeout: parseGoogleTakeout,
+	models.ImportSourceApplePhotos:   parseApplePhotosExport,
+}
+
+// InitializeImporter marks imports that were interrupted by a restart as failed
+func InitializeImporter(db *gorm.DB) error {
+	interrupted := "interrupted by server restart"
+	err := db.Model(&models.ImportJob{}).
+		Where("status IN (?)", []models.ImportStatus{models.ImportStatusPending, models.ImportStatusRunning}).
+		Updates(map[string]interface{}{"status": models.ImportStatusFailed, "error": interrupted}).Error
+	if err != nil {
+		return errors.Wrap(err, "mark interrupted imports as failed")
+	}
+
+	return nil
+}
+
+// StartImport validates and saves the import job, and runs it in the background
+func StartImport(db *gorm.DB, job *models.ImportJob) error {
+	if maintenance.Enabled() {
+		return maintenance.ErrMaintenance
+	}
+
+	if _, found := exportParsers[job.Source]; !found {
+		return errors.Errorf("unsupported import source: %s", job.Source)
+	}
+
+	if _, err := os.Stat(job.SourcePath); err != nil {
+		return errors.Wrap(err, "read import source")
+	}
+
+	if job.Layout == "" {
+		job.Layout = DefaultLayout
+	}
+
+	job.Status = models.ImportStatusPending
+	if err := db.Create(job).Error; err != nil {
+		return errors.Wrap(err, "insert import job into database")
+	}
+
+	ctx := log.WithAttrs(log.Detach(db.Statement.Context), "import_id", job.ID)
+
+	// The import runs on a copy of the job, as the job returned to the caller is read while the import updates its progress
+	running := *job
+	job = &running
+	go func() {
+		err := runImport(ctx, db, job)
+
+		finishedAt := time.Now()
+		job.FinishedAt = &finishedAt
+		if err != nil {
+			log.Error(ctx, "Import failed", "source", job.SourcePath, "error", err)
+			errorMessage := err.Error()
+			job.Status = models.ImportStatusFailed
+			job.Error = &errorMessage
+		} else {
+			log.Info(ctx, "Import completed", "source", job.SourcePath, "imported", job.ImportedCount)
+			job.Status = models.ImportStatusCompleted
+		}
+
+		if err := db.Omit("User", "Album").Save(job).Error; err != nil {
+			log.Error(ctx, "Updating import job", "error", err)
+		}
+
+		notifier.ImportCompleted(job)
+	}()
+
+	return nil
+}
+
+func runImport(ctx context.Context, db *gorm.DB, job *models.ImportJob) error {
+	job.Status = models.ImportStatusRunning
+	if err := db.Model(job).Update("status", job.Status).Error; err != nil {
+		return err
+	}
+
+	var album models.Album
+	if err := db.First(&album, job.AlbumID).Error; err != nil {
+		return errors.Wrap(err, "get import album")
+	}
+
+	export, closeExport, err := openExport(job.SourcePath)
+	if err != nil {
+		return err
+	}
+	defer closeExport()
+
+	items, err := exportParsers[job.Source](export)
+	if err != nil {
+		return errors.Wrap(err, "parse export")
+	}
+
+	log.Info(ctx, "Importing media", "count", len(items), "source", job.SourcePath)
+
+	changedAlbums := make(map[int]*models.Album)
+
+	for _, item := range items {
+		targetAlbum, err := ensureLayoutAlbum(db, &album, expandLayout(job.Layout, item))
+		if err != nil {
+			return err
+		}
+
+		media, err := importItemFile(db, export, item, targetAlbum)
+		if err != nil {
+			log.Warn(ctx, "Skipping import of media", "path", item.path, "error", err)
+			job.SkippedCount++
+		} else if media == nil {
+			job.SkippedCount++
+		} else {
+			if err := applyMetadata(db, job.UserID, media, &item.metadata); err != nil {
+				log.Warn(ctx, "Could not apply imported metadata", "path", media.Path, "error", err)
+			}
+
+			job.ImportedCount++
+			changedAlbums[targetAlbum.ID] = targetAlbum
+		}
+
+		err = db.Model(job).Updates(map[string]interface{}{
+			"imported_count": job.ImportedCount,
+			"skipped_count":  job.SkippedCount,
+		}).Error
+		if err != nil {
+			return errors.Wrap(err, "update import job progress")
+		}
+	}
+
+	// Generate thumbnails for the imported media
+	for _, changedAlbum := range changedAlbums {
+		if err := scanner_queue.AddAlbumToQueue(ctx, changedAlbum); err != nil {
+			log.Warn(ctx, "Could not queue album for scanning after import", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// openExport opens an export either as a zip archive or as an extracted directory
+func openExport(sourcePath string) (fs.FS, func() error, error) {
+	stat, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "read import source")
+	}
+
+	if stat.IsDir() {
+		return os.DirFS(sourcePath), func() error { return nil }, nil
+	}
+
+	if strings.ToLower(path.Ext(sourcePath)) != ".zip" {
+		return nil, nil, errors.New("import source must be a directory or a zip archive")
+	}
+
+	archive, err := zip.OpenReader(sourcePath)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "open zip archive")
+	}
+
+	return archive, archive.Close, nil
+}
+
+// expandLayout replaces the placeholders in the layout with values of the item, returning the directories to place it in
+func expandLayout(layout string, item *importItem) []string {
+	values := map[string]string{
+		"{album}": item.album,
+		"{year}":  "Unknown date",
+		"{month}": "",
+		"{day}":   "",
+	}
+
+	if takenAt := item.metadata.TakenAt; takenAt != nil {
+		values["{year}"] = fmt.Sprintf("%04d", takenAt.Year())
+		values["{month}"] = fmt.Sprintf("%02d", takenAt.Month())
+		values["{day}"] = fmt.Sprintf("%02d", takenAt.Day())
+	}
+
+	dirs := make([]string, 0)
+	for _, segment := range strings.Split(layout, "/") {
+		for placeholder, value := range values {
+			segment = strings.ReplaceAll(segment, placeholder, value)
+		}
+
+		segment = strings.TrimSpace(strings.NewReplacer("/", "_", "\\", "_").Replace(segment))
+		if segment == "" || strings.HasPrefix(segment, ".") {
+			continue
+		}
+
+		dirs = append(dirs, segment)
+	}
+
+	return dirs
+}
+
+func ensureLayoutAlbum(db *gorm.DB, album *models.Album, dirs []string) (*models.Album, error) {
+	for _, dir := range dirs {
+		subAlbum, err := album.EnsureSubAlbum(db, dir)
+		if err != nil {
+			return nil, err
+		}
+		album = subAlbum
+	}
+
+	return album, nil
+}
+
+// importItemFile copies the file of the item into the album.
+// Returns nil if an identical file has already been imported into the album.
+func importItemFile(db *gorm.DB, export fs.FS, item *importItem, album *models.Album) (*models.Media, error) {
+	filename := path.Base(item.path)
+
+	src, err := export.Open(item.path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	srcStat, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip files imported by an earlier run of the import
+	if existing, err := os.Stat(path.Join(album.Path, filename)); err == nil && existing.Size() == srcStat.Size() {
+		return nil, nil
+	}
+
+	importDir := path.Join(utils.MediaCachePath(), "imports")
+	if err := os.MkdirAll(importDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	tmpFile, err := os.CreateTemp(importDir, "import_*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := io.Copy(tmpFile, src); err != nil {
+		tmpFile.Close()
+		return nil, errors.Wrap(err, "copy file from export")
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return nil, err
+	}
+
+	return scanner.ImportMediaFile(db, album, tmpFile.Name(), filename)
+}
@@ -0,0 +1,105 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestExpandLayout(t *testing.T) {
+	takenAt := time.Date(2019, 7, 4, 12, 0, 0, 0, time.UTC)
+	item := &importItem{
+		album:    "Summer/Trip",
+		metadata: ImportMetadata{TakenAt: &takenAt},
+	}
+
+	assert.Equal(t, []string{"Summer_Trip"}, expandLayout("{album}", item))
+	assert.Equal(t, []string{"2019", "07", "Summer_Trip"}, expandLayout("{year}/{month}/{album}", item))
+	assert.Equal(t, []string{"2019-07-04"}, expandLayout("{year}-{month}-{day}", item))
+	assert.Equal(t, []string{"imported"}, expandLayout("../imported", item))
+
+	item.album = ""
+	item.metadata.TakenAt = nil
+	assert.Equal(t, []string{}, expandLayout("{album}", item))
+	assert.Equal(t, []string{"Unknown date"}, expandLayout("{year}/{month}", item))
+}
+
+func TestRunImport(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	user, err := models.RegisterUser(db, "username", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{Title: "photos", Path: t.TempDir()}
+	if !assert.NoError(t, db.Model(&user).Association("Albums").Append(&album)) {
+		return
+	}
+
+	export := t.TempDir()
+	albumDir := path.Join(export, "Takeout", "Google Photos", "Holiday")
+	assert.NoError(t, os.MkdirAll(albumDir, 0755))
+	assert.NoError(t, os.WriteFile(path.Join(albumDir, "IMG_0001.jpg"), []byte("IMAGE DATA"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(albumDir, "IMG_0001.jpg.json"), []byte(`{
+		"description": "At the beach",
+		"photoTakenTime": {"timestamp": "1562241600"},
+		"geoData": {"latitude": 55.5, "longitude": 12.5},
+		"people": [{"name": "Alice"}, {"name": "Bob"}],
+		"favorited": true
+	}`), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(albumDir, "notes.txt"), []byte("TEXT"), 0644))
+
+	job := models.ImportJob{
+		Source:     models.ImportSourceGoogleTakeout,
+		SourcePath: export,
+		UserID:     user.ID,
+		AlbumID:    album.ID,
+		Layout:     "{year}/{album}",
+		Status:     models.ImportStatusPending,
+	}
+	if !assert.NoError(t, db.Create(&job).Error) {
+		return
+	}
+
+	if !assert.NoError(t, runImport(context.Background(), db, &job)) {
+		return
+	}
+	assert.Equal(t, 1, job.ImportedCount)
+
+	var media models.Media
+	if !assert.NoError(t, db.Preload("Exif").Where("path = ?", path.Join(album.Path, "2019", "Holiday", "IMG_0001.jpg")).First(&media).Error) {
+		return
+	}
+
+	assert.Equal(t, int64(1562241600), media.DateShot.Unix())
+	if assert.NotNil(t, media.Exif) {
+		assert.Equal(t, "At the beach", *media.Exif.Description)
+		assert.Equal(t, 55.5, *media.Exif.GPSLatitude)
+	}
+
+	var people []string
+	assert.NoError(t, db.Model(&models.MediaPerson{}).Where("media_id = ?", media.ID).Order("name").Pluck("name", &people).Error)
+	assert.Equal(t, []string{"Alice", "Bob"}, people)
+
+	var favorite models.UserMediaData
+	assert.NoError(t, db.Where("user_id = ? AND media_id = ?", user.ID, media.ID).First(&favorite).Error)
+	assert.True(t, favorite.Favorite)
+
+	// Running the import again skips the files already imported
+	job.ImportedCount = 0
+	if assert.NoError(t, runImport(context.Background(), db, &job)) {
+		assert.Equal(t, 0, job.ImportedCount)
+	}
+}
@@ -0,0 +1,112 @@
+package importer
+
+import (
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// applyMetadata merges the metadata of the export into the media.
+// Values read from the media file itself take precedence over the exported values, unless the metadata overrides them.
+func applyMetadata(db *gorm.DB, userID int, media *models.Media, metadata *ImportMetadata) error {
+	if err := mergeExif(db, media, metadata); err != nil {
+		return err
+	}
+
+	if metadata.Favorite {
+		user := models.User{}
+		user.ID = userID
+		if _, err := user.FavoriteMedia(db, media.ID, true); err != nil {
+			return err
+		}
+	}
+
+	// Skip people already recorded by an earlier import of the same media
+	var existingPeople []string
+	if err := db.Model(&models.MediaPerson{}).Where("media_id = ?", media.ID).Pluck("name", &existingPeople).Error; err != nil {
+		return errors.Wrap(err, "get people of media")
+	}
+
+	existing := make(map[string]bool)
+	for _, name := range existingPeople {
+		existing[name] = true
+	}
+
+	for _, name := range uniqueNames(metadata.People) {
+		if existing[name] {
+			continue
+		}
+
+		person := models.MediaPerson{
+			MediaID: media.ID,
+			Name:    name,
+		}
+
+		if err := db.Create(&person).Error; err != nil {
+			return errors.Wrap(err, "insert media person into database")
+		}
+	}
+
+	return nil
+}
+
+func mergeExif(db *gorm.DB, media *models.Media, metadata *ImportMetadata) error {
+	exif := &models.MediaEXIF{}
+	if media.ExifID != nil {
+		if err := db.First(exif, *media.ExifID).Error; err != nil {
+			return errors.Wrap(err, "get media exif from database")
+		}
+	}
+
+	changed := false
+
+	if metadata.Description != nil && *metadata.Description != "" && (metadata.Override || exif.Description == nil || *exif.Description == "") {
+		exif.Description = metadata.Description
+		changed = true
+	}
+
+	if metadata.Latitude != nil && metadata.Longitude != nil && (metadata.Override || exif.GPSLatitude == nil || exif.GPSLongitude == nil) {
+		exif.GPSLatitude = metadata.Latitude
+		exif.GPSLongitude = metadata.Longitude
+		changed = true
+	}
+
+	if metadata.TakenAt != nil && (metadata.Override || exif.DateShot == nil) {
+		exif.DateShot = metadata.TakenAt
+		changed = true
+
+		media.DateShot = *metadata.TakenAt
+		if err := db.Model(media).Update("date_shot", media.DateShot).Error; err != nil {
+			return errors.Wrap(err, "update media date_shot")
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if media.ExifID != nil {
+		return errors.Wrap(db.Save(exif).Error, "update media exif")
+	}
+
+	if err := db.Model(media).Association("Exif").Replace(exif); err != nil {
+		return errors.Wrap(err, "save media exif to database")
+	}
+
+	return nil
+}
+
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(names))
+
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		unique = append(unique, name)
+	}
+
+	return unique
+}
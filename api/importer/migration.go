@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/scanner_queue"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// MigrationSource is a photo management application whose database can be migrated to Photoview
+type MigrationSource string
+
+const (
+	MigrationSourcePhotoPrism MigrationSource = "photoprism"
+	MigrationSourceImmich     MigrationSource = "immich"
+)
+
+// MigrationOptions configures a migration from the database of another application
+type MigrationOptions struct {
+	Source MigrationSource
+	// UserID is the Photoview user that favorites are migrated for
+	UserID int
+	// SourceUser limits the migration to the media of a single user of the other application, if it supports multiple users
+	SourceUser string
+	// PathMappings translate path prefixes of the other application into paths of the Photoview library,
+	// for when the same files are mounted at different locations
+	PathMappings map[string]string
+	// AlbumsParent is the album that albums of the other application are copied into as sub albums.
+	// As Photoview albums are directories, albums are only migrated if it is set.
+	AlbumsParent *models.Album
+}
+
+// MigrationResult summarizes a completed migration
+type MigrationResult struct {
+	// MigratedMedia is the number of media found in the Photoview library that metadata was migrated for
+	MigratedMedia int
+	// UnmatchedMedia is the number of media that could not be found in the Photoview library
+	UnmatchedMedia int
+	// MigratedAlbums is the number of albums copied into AlbumsParent
+	MigratedAlbums int
+}
+
+// migrationItem is a media file in the database of the other application
+type migrationItem struct {
+	path string
+	// metadata is only applied where the media file itself has none
+	metadata ImportMetadata
+	// edits were made by the user in the other application, and take precedence over the media file
+	edits  ImportMetadata
+	albums []string
+}
+
+// migrationReader reads the media of the other application from its database
+type migrationReader func(source *gorm.DB, sourceUser string) ([]*migrationItem, error)
+
+var migrationReaders = map[MigrationSource]migrationReader{
+	MigrationSourcePhotoPrism: readPhotoPrism,
+	MigrationSourceImmich:     readImmich,
+}
+
+// Migrate reads the database of another photo management application, and applies favorites, people names
+// and edited metadata to the matching media of the Photoview library. The library must have been scanned beforehand.
+func Migrate(db *gorm.DB, source *gorm.DB, options MigrationOptions) (*MigrationResult, error) {
+	reader, found := migrationReaders[options.Source]
+	if !found {
+		return nil, errors.Errorf("unsupported migration source: %s", options.Source)
+	}
+
+	items, err := reader(source, options.SourceUser)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s database", options.Source)
+	}
+
+	ctx := db.Statement.Context
+	log.Info(ctx, "Migrating media", "count", len(items), "source", options.Source)
+
+	result := MigrationResult{}
+	migratedAlbums := make(map[string]*models.Album)
+
+	for _, item := range items {
+		mediaPath := mapMigrationPath(item.path, options.PathMappings)
+
+		var media []*models.Media
+		if err := db.Where("path_hash = ?", models.MD5Hash(mediaPath)).Limit(1).Find(&media).Error; err != nil {
+			return nil, errors.Wrap(err, "get media from database")
+		}
+
+		if len(media) == 0 {
+			result.UnmatchedMedia++
+			continue
+		}
+
+		if err := applyMigrationItem(db, options.UserID, media[0], item); err != nil {
+			log.Warn(ctx, "Could not migrate metadata", "path", mediaPath, "error", err)
+			continue
+		}
+		result.MigratedMedia++
+
+		if options.AlbumsParent == nil {
+			continue
+		}
+
+		for _, albumTitle := range item.albums {
+			album, err := ensureLayoutAlbum(db, options.AlbumsParent, expandLayout(DefaultLayout, &importItem{album: albumTitle}))
+			if err != nil {
+				return nil, err
+			}
+
+			copied, err := importItemFile(db, os.DirFS("/"), &importItem{path: strings.TrimPrefix(mediaPath, "/")}, album)
+			if err != nil {
+				log.Warn(ctx, "Could not copy media into album", "path", mediaPath, "album", albumTitle, "error", err)
+				continue
+			}
+
+			migratedAlbums[albumTitle] = album
+
+			if copied != nil {
+				if err := applyMigrationItem(db, options.UserID, copied, item); err != nil {
+					log.Warn(ctx, "Could not migrate metadata", "path", copied.Path, "error", err)
+				}
+			}
+		}
+	}
+
+	result.MigratedAlbums = len(migratedAlbums)
+
+	// Generate thumbnails for the media copied into albums
+	for _, album := range migratedAlbums {
+		if err := scanner_queue.AddAlbumToQueue(ctx, album); err != nil {
+			log.Warn(ctx, "Could not queue album for scanning after migration", "error", err)
+		}
+	}
+
+	return &result, nil
+}
+
+func applyMigrationItem(db *gorm.DB, userID int, media *models.Media, item *migrationItem) error {
+	if err := applyMetadata(db, userID, media, &item.metadata); err != nil {
+		return err
+	}
+
+	item.edits.Override = true
+	return applyMetadata(db, userID, media, &item.edits)
+}
+
+// mapMigrationPath translates a path of the other application using the longest matching path prefix
+func mapMigrationPath(p string, mappings map[string]string) string {
+	prefixes := make([]string, 0, len(mappings))
+	for prefix := range mappings {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	for _, prefix := range prefixes {
+		trimmed := strings.TrimSuffix(prefix, "/")
+		if p == trimmed || strings.HasPrefix(p, trimmed+"/") {
+			return path.Join(mappings[prefix], strings.TrimPrefix(p, trimmed))
+		}
+	}
+
+	return p
+}
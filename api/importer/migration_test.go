@@ -0,0 +1,201 @@
+package importer
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMapMigrationPath(t *testing.T) {
+	mappings := map[string]string{
+		"/":                       "/photos",
+		"/usr/src/app/upload/":    "/immich",
+		"/usr/src/app/upload/lib": "/library",
+	}
+
+	assert.Equal(t, "/photos/2020/img.jpg", mapMigrationPath("/2020/img.jpg", mappings))
+	assert.Equal(t, "/immich/admin/img.jpg", mapMigrationPath("/usr/src/app/upload/admin/img.jpg", mappings))
+	assert.Equal(t, "/library/img.jpg", mapMigrationPath("/usr/src/app/upload/lib/img.jpg", mappings))
+	assert.Equal(t, "/immich/library2/img.jpg", mapMigrationPath("/usr/src/app/upload/library2/img.jpg", mappings))
+	assert.Equal(t, "/2020/img.jpg", mapMigrationPath("/2020/img.jpg", nil))
+}
+
+func openSourceDatabase(t *testing.T, schema ...string) *gorm.DB {
+	source, err := gorm.Open(sqlite.Open(path.Join(t.TempDir(), "source.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, statement := range schema {
+		if err := source.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return source
+}
+
+func migrationTestLibrary(t *testing.T, db *gorm.DB) (*models.User, *models.Album, *models.Media) {
+	user, err := models.RegisterUser(db, "username", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	album := models.Album{Title: "photos", Path: t.TempDir()}
+	if err := db.Model(&user).Association("Albums").Append(&album); err != nil {
+		t.Fatal(err)
+	}
+
+	mediaPath := path.Join(album.Path, "img.jpg")
+	if err := os.WriteFile(mediaPath, []byte("IMAGE DATA"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	description := "From the camera"
+	media := models.Media{
+		Title:   "img.jpg",
+		Path:    mediaPath,
+		AlbumID: album.ID,
+		Exif:    &models.MediaEXIF{Description: &description},
+	}
+	if err := db.Create(&media).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return user, &album, &media
+}
+
+func TestMigratePhotoPrism(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	user, album, media := migrationTestLibrary(t, db)
+
+	source := openSourceDatabase(t,
+		`CREATE TABLE photos (id INTEGER PRIMARY KEY, photo_uid TEXT, photo_favorite BOOLEAN, photo_description TEXT, description_src TEXT,
+			taken_at DATETIME, taken_src TEXT, photo_lat REAL, photo_lng REAL, place_src TEXT, deleted_at DATETIME)`,
+		`CREATE TABLE files (id INTEGER PRIMARY KEY, photo_id INTEGER, file_uid TEXT, file_name TEXT, file_sidecar BOOLEAN, file_missing BOOLEAN)`,
+		`CREATE TABLE subjects (subj_uid TEXT, subj_name TEXT, subj_type TEXT, deleted_at DATETIME)`,
+		`CREATE TABLE markers (file_uid TEXT, subj_uid TEXT, marker_invalid BOOLEAN)`,
+		`CREATE TABLE albums (album_uid TEXT, album_title TEXT, album_type TEXT, deleted_at DATETIME)`,
+		`CREATE TABLE photos_albums (photo_uid TEXT, album_uid TEXT, hidden BOOLEAN)`,
+		`INSERT INTO photos VALUES (1, 'p1', 1, 'Edited description', 'manual', '2015-06-01 10:00:00', 'meta', 55.5, 12.5, 'estimate', NULL)`,
+		`INSERT INTO photos VALUES (2, 'p2', 1, '', '', NULL, '', 0, 0, '', NULL)`,
+		`INSERT INTO files VALUES (1, 1, 'f1', '2015/img.jpg', 0, 0)`,
+		`INSERT INTO files VALUES (2, 1, 'f2', '2015/img.jpg.xmp', 1, 0)`,
+		`INSERT INTO files VALUES (3, 2, 'f3', '2015/missing.jpg', 0, 0)`,
+		`INSERT INTO subjects VALUES ('s1', 'Alice', 'person', NULL)`,
+		`INSERT INTO markers VALUES ('f1', 's1', 0)`,
+		`INSERT INTO albums VALUES ('a1', 'Holiday', 'album', NULL)`,
+		`INSERT INTO albums VALUES ('a2', '2015', 'folder', NULL)`,
+		`INSERT INTO photos_albums VALUES ('p1', 'a1', 0)`,
+		`INSERT INTO photos_albums VALUES ('p1', 'a2', 0)`,
+	)
+
+	result, err := Migrate(db, source, MigrationOptions{
+		Source:       MigrationSourcePhotoPrism,
+		UserID:       user.ID,
+		PathMappings: map[string]string{"/2015": album.Path},
+		AlbumsParent: album,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 1, result.MigratedMedia)
+	assert.Equal(t, 1, result.UnmatchedMedia)
+	assert.Equal(t, 1, result.MigratedAlbums)
+
+	var migrated models.Media
+	if !assert.NoError(t, db.Preload("Exif").First(&migrated, media.ID).Error) {
+		return
+	}
+
+	if assert.NotNil(t, migrated.Exif) {
+		assert.Equal(t, "Edited description", *migrated.Exif.Description, "expected manual edit to take precedence over the media file")
+		assert.NotNil(t, migrated.Exif.GPSLatitude)
+		assert.NotNil(t, migrated.Exif.DateShot)
+	}
+
+	var favorite models.UserMediaData
+	if assert.NoError(t, db.Where("user_id = ? AND media_id = ?", user.ID, media.ID).First(&favorite).Error) {
+		assert.True(t, favorite.Favorite)
+	}
+
+	var people []string
+	assert.NoError(t, db.Model(&models.MediaPerson{}).Where("media_id = ?", media.ID).Pluck("name", &people).Error)
+	assert.Equal(t, []string{"Alice"}, people)
+
+	assert.FileExists(t, path.Join(album.Path, "Holiday", "img.jpg"))
+	assert.NoDirExists(t, path.Join(album.Path, "2015"), "expected folder albums of PhotoPrism to be skipped")
+
+	// Running the migration again should not duplicate anything
+	_, err = Migrate(db, source, MigrationOptions{
+		Source:       MigrationSourcePhotoPrism,
+		UserID:       user.ID,
+		PathMappings: map[string]string{"/2015": album.Path},
+		AlbumsParent: album,
+	})
+	assert.NoError(t, err)
+
+	people = nil
+	assert.NoError(t, db.Model(&models.MediaPerson{}).Where("media_id = ?", media.ID).Pluck("name", &people).Error)
+	assert.Equal(t, []string{"Alice"}, people)
+	assert.NoFileExists(t, path.Join(album.Path, "Holiday", "img (1).jpg"))
+}
+
+func TestMigrateImmich(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	user, album, media := migrationTestLibrary(t, db)
+
+	source := openSourceDatabase(t,
+		`CREATE TABLE users (id TEXT, email TEXT)`,
+		`CREATE TABLE assets (id TEXT, "originalPath" TEXT, "ownerId" TEXT, "isFavorite" BOOLEAN, "deletedAt" DATETIME)`,
+		`CREATE TABLE exif ("assetId" TEXT, description TEXT, latitude REAL, longitude REAL, "dateTimeOriginal" DATETIME)`,
+		`CREATE TABLE person (id TEXT, name TEXT)`,
+		`CREATE TABLE asset_faces ("assetId" TEXT, "personId" TEXT)`,
+		`CREATE TABLE albums (id TEXT, "albumName" TEXT, "deletedAt" DATETIME)`,
+		`CREATE TABLE albums_assets_assets ("albumsId" TEXT, "assetsId" TEXT)`,
+		`INSERT INTO users VALUES ('u1', 'me@example.com'), ('u2', 'other@example.com')`,
+		`INSERT INTO assets VALUES ('a1', '/usr/src/app/upload/library/admin/img.jpg', 'u1', 1, NULL)`,
+		`INSERT INTO assets VALUES ('a2', '/usr/src/app/upload/library/other/img.jpg', 'u2', 1, NULL)`,
+		`INSERT INTO exif VALUES ('a1', 'Immich description', 55.5, 12.5, NULL)`,
+		`INSERT INTO person VALUES ('p1', 'Bob'), ('p2', '')`,
+		`INSERT INTO asset_faces VALUES ('a1', 'p1'), ('a1', 'p2')`,
+	)
+
+	result, err := Migrate(db, source, MigrationOptions{
+		Source:       MigrationSourceImmich,
+		UserID:       user.ID,
+		SourceUser:   "me@example.com",
+		PathMappings: map[string]string{"/usr/src/app/upload/library/admin": album.Path},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 1, result.MigratedMedia)
+	assert.Equal(t, 0, result.UnmatchedMedia)
+
+	var migrated models.Media
+	if !assert.NoError(t, db.Preload("Exif").First(&migrated, media.ID).Error) {
+		return
+	}
+
+	if assert.NotNil(t, migrated.Exif) {
+		assert.Equal(t, "From the camera", *migrated.Exif.Description, "expected media file to take precedence")
+		assert.NotNil(t, migrated.Exif.GPSLatitude)
+	}
+
+	var people []string
+	assert.NoError(t, db.Model(&models.MediaPerson{}).Where("media_id = ?", media.ID).Pluck("name", &people).Error)
+	assert.Equal(t, []string{"Bob"}, people)
+}
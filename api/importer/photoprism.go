@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type photoPrismFile struct {
+	FileName         string
+	PhotoUID         string
+	PhotoFavorite    bool
+	PhotoDescription string
+	DescriptionSrc   string
+	TakenAt          *time.Time
+	TakenSrc         string
+	PhotoLat         float64
+	PhotoLng         float64
+	PlaceSrc         string
+}
+
+// readPhotoPrism reads the media of a PhotoPrism database.
+// The file paths are relative to the originals directory of PhotoPrism, and are returned with a leading slash,
+// values edited by the user in PhotoPrism take precedence over the media files.
+// PhotoPrism has a single library shared by its users, so the source user is ignored.
+func readPhotoPrism(source *gorm.DB, sourceUser string) ([]*migrationItem, error) {
+	var files []*photoPrismFile
+	err := source.Raw(`
+		SELECT files.file_name, photos.photo_uid, photos.photo_favorite, photos.photo_description, photos.description_src,
+			photos.taken_at, photos.taken_src, photos.photo_lat, photos.photo_lng, photos.place_src
+		FROM files JOIN photos ON photos.id = files.photo_id
+		WHERE files.file_sidecar = ? AND files.file_missing = ? AND photos.deleted_at IS NULL
+	`, false, false).Scan(&files).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get photos")
+	}
+
+	items := make([]*migrationItem, 0, len(files))
+	photoItems := make(map[string][]*migrationItem)
+
+	for _, file := range files {
+		item := &migrationItem{
+			path: "/" + file.FileName,
+		}
+		item.metadata.Favorite = file.PhotoFavorite
+
+		metadata := &item.metadata
+		if file.DescriptionSrc == "manual" {
+			metadata = &item.edits
+		}
+		if file.PhotoDescription != "" {
+			description := file.PhotoDescription
+			metadata.Description = &description
+		}
+
+		metadata = &item.metadata
+		if file.TakenSrc == "manual" {
+			metadata = &item.edits
+		}
+		metadata.TakenAt = file.TakenAt
+
+		metadata = &item.metadata
+		if file.PlaceSrc == "manual" {
+			metadata = &item.edits
+		}
+		if file.PhotoLat != 0 || file.PhotoLng != 0 {
+			lat, lng := file.PhotoLat, file.PhotoLng
+			metadata.Latitude, metadata.Longitude = &lat, &lng
+		}
+
+		items = append(items, item)
+		photoItems[file.PhotoUID] = append(photoItems[file.PhotoUID], item)
+	}
+
+	// People are marked on a single file, but apply to all files of the photo
+	var people []struct {
+		PhotoUID string
+		SubjName string
+	}
+	err = source.Raw(`
+		SELECT DISTINCT photos.photo_uid, subjects.subj_name
+		FROM markers
+		JOIN files ON files.file_uid = markers.file_uid
+		JOIN photos ON photos.id = files.photo_id
+		JOIN subjects ON subjects.subj_uid = markers.subj_uid
+		WHERE markers.marker_invalid = ? AND subjects.subj_type = 'person' AND subjects.deleted_at IS NULL
+	`, false).Scan(&people).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get people")
+	}
+
+	for _, person := range people {
+		for _, item := range photoItems[person.PhotoUID] {
+			item.metadata.People = append(item.metadata.People, person.SubjName)
+		}
+	}
+
+	// Only albums created by the user, not the folders, moments and calendar albums generated by PhotoPrism
+	var albums []struct {
+		PhotoUID   string
+		AlbumTitle string
+	}
+	err = source.Raw(`
+		SELECT photos_albums.photo_uid, albums.album_title
+		FROM photos_albums JOIN albums ON albums.album_uid = photos_albums.album_uid
+		WHERE albums.album_type = 'album' AND albums.deleted_at IS NULL AND photos_albums.hidden = ?
+	`, false).Scan(&albums).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get albums")
+	}
+
+	for _, album := range albums {
+		for _, item := range photoItems[album.PhotoUID] {
+			item.albums = append(item.albums, album.AlbumTitle)
+		}
+	}
+
+	return items, nil
+}
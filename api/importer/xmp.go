@@ -0,0 +1,155 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xmpProperties holds the values of an XMP packet, by the local name of the properties.
+// Properties can be written either as attributes or as elements, and values of lists such as
+// rdf:Bag, rdf:Seq and rdf:Alt are collected in order.
+type xmpProperties map[string][]string
+
+const rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// parseXMP reads the properties of an XMP sidecar file
+func parseXMP(data []byte) (xmpProperties, error) {
+	properties := make(xmpProperties)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	// The innermost property element, that text belongs to
+	propertyStack := make([]string, 0)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				if attr.Name.Space != rdfNamespace && attr.Name.Space != "xmlns" && attr.Name.Space != "" {
+					properties.add(attr.Name.Local, attr.Value)
+				}
+			}
+
+			if t.Name.Space == rdfNamespace {
+				propertyStack = append(propertyStack, "")
+			} else {
+				propertyStack = append(propertyStack, t.Name.Local)
+			}
+		case xml.EndElement:
+			propertyStack = propertyStack[:len(propertyStack)-1]
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" {
+				continue
+			}
+
+			for i := len(propertyStack) - 1; i >= 0; i-- {
+				if propertyStack[i] != "" {
+					properties.add(propertyStack[i], text)
+					break
+				}
+			}
+		}
+	}
+
+	return properties, nil
+}
+
+func (p xmpProperties) add(name string, value string) {
+	p[name] = append(p[name], value)
+}
+
+// first returns the first value of the first of the given properties that is present
+func (p xmpProperties) first(names ...string) string {
+	for _, name := range names {
+		if values := p[name]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// metadata converts the properties to import metadata, a rating of 5 is considered a favorite
+func (p xmpProperties) metadata() ImportMetadata {
+	metadata := ImportMetadata{}
+
+	if description := p.first("description", "Description", "ImageDescription", "Caption-Abstract"); description != "" {
+		metadata.Description = &description
+	}
+
+	if takenAt := parseMetadataDate(p.first("DateTimeOriginal", "DateCreated", "CreateDate")); takenAt != nil {
+		metadata.TakenAt = takenAt
+	}
+
+	latitude := parseXMPCoordinate(p.first("GPSLatitude"))
+	longitude := parseXMPCoordinate(p.first("GPSLongitude"))
+	if latitude != nil && longitude != nil {
+		metadata.Latitude = latitude
+		metadata.Longitude = longitude
+	}
+
+	metadata.People = append(metadata.People, p["PersonInImage"]...)
+
+	if rating, err := strconv.Atoi(p.first("Rating")); err == nil && rating >= 5 {
+		metadata.Favorite = true
+	}
+
+	return metadata
+}
+
+var metadataDateFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006:01:02 15:04:05-07:00",
+	"2006:01:02 15:04:05",
+}
+
+// parseMetadataDate parses the date formats used by XMP and exiftool
+func parseMetadataDate(value string) *time.Time {
+	for _, format := range metadataDateFormats {
+		if date, err := time.Parse(format, value); err == nil {
+			return &date
+		}
+	}
+	return nil
+}
+
+var xmpCoordinateFormat = regexp.MustCompile(`^(\d+),(\d+(?:\.\d+)?)(?:,(\d+(?:\.\d+)?))?([NSEW])$`)
+
+// parseXMPCoordinate parses a GPS coordinate in the XMP format "DDD,MM.mmk" or "DDD,MM,SSk"
+func parseXMPCoordinate(value string) *float64 {
+	match := xmpCoordinateFormat.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return nil
+	}
+
+	degrees, _ := strconv.ParseFloat(match[1], 64)
+	minutes, _ := strconv.ParseFloat(match[2], 64)
+	seconds := 0.0
+	if match[3] != "" {
+		seconds, _ = strconv.ParseFloat(match[3], 64)
+	}
+
+	coordinate := degrees + minutes/60 + seconds/3600
+	if match[4] == "S" || match[4] == "W" {
+		coordinate = -coordinate
+	}
+
+	coordinate = math.Round(coordinate*1e7) / 1e7
+	return &coordinate
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type contextKey struct{}
+
+// RequestIDKey is the field the id of a request is logged as
+const RequestIDKey = "request_id"
+
+// WithAttrs returns a copy of the context, with fields that are added to every message logged with it
+func WithAttrs(ctx context.Context, args ...interface{}) context.Context {
+	fields := append(contextFields(ctx), argsToFields(args)...)
+	return context.WithValue(ctx, contextKey{}, fields)
+}
+
+// WithRequestID returns a copy of the context, logging messages with the id of the request being handled
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return WithAttrs(ctx, RequestIDKey, requestID)
+}
+
+// RequestID returns the id of the request of the context, or an empty string if there is none
+func RequestID(ctx context.Context) string {
+	fields := contextFields(ctx)
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].key == RequestIDKey {
+			if requestID, ok := fields[i].value.(string); ok {
+				return requestID
+			}
+		}
+	}
+
+	return ""
+}
+
+// Detach returns a new background context with the logging fields of the given context.
+// It is used for work outliving a request, such as scans, which should be logged with the id of the request that started them
+// without being cancelled when the request ends.
+func Detach(ctx context.Context) context.Context {
+	fields := contextFields(ctx)
+	if len(fields) == 0 {
+		return context.Background()
+	}
+
+	return context.WithValue(context.Background(), contextKey{}, fields)
+}
+
+// NewRequestID generates a random id for a request
+func NewRequestID() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(bytes)
+}
+
+// contextFields returns a copy of the fields of the context, which the caller is free to append to
+func contextFields(ctx context.Context) []field {
+	if ctx == nil {
+		return nil
+	}
+
+	fields, _ := ctx.Value(contextKey{}).([]field)
+	return append([]field(nil), fields...)
+}
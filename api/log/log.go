@@ -0,0 +1,288 @@
+// Package log implements leveled, structured logging for Photoview.
+//
+// Every message takes a context, fields attached to the context with WithAttrs,
+// such as the id of the request being handled, are included in the messages logged with it.
+// Additional fields are given as alternating keys and values:
+//
+//	log.Error(ctx, "failed to process media", "path", media.Path, "error", err)
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Level is the severity of a log message
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// ParseLevel parses the name of a level, such as "debug" or "WARN"
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level: %s", name)
+	}
+}
+
+// Format is how log messages are written
+type Format int32
+
+const (
+	// FormatText writes messages as human readable lines of `key=value` pairs
+	FormatText Format = iota
+	// FormatJSON writes each message as a JSON object on a line of its own
+	FormatJSON
+)
+
+// ParseFormat parses the name of a format, either "text" or "json"
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return FormatText, fmt.Errorf("unknown log format: %s", name)
+	}
+}
+
+var (
+	currentLevel  int32 = int32(LevelInfo)
+	currentFormat int32 = int32(FormatText)
+
+	outputMutex sync.Mutex
+	output      io.Writer = os.Stderr
+)
+
+// InitializeLogging sets the level and format of the logger from their names, empty names keep the defaults
+func InitializeLogging(level string, format string) error {
+	if level != "" {
+		parsed, err := ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		SetLevel(parsed)
+	}
+
+	if format != "" {
+		parsed, err := ParseFormat(format)
+		if err != nil {
+			return err
+		}
+		SetFormat(parsed)
+	}
+
+	return nil
+}
+
+// SetLevel changes the minimum level of messages being logged, it can be called at any time
+func SetLevel(level Level) {
+	atomic.StoreInt32(&currentLevel, int32(level))
+}
+
+// GetLevel returns the minimum level of messages being logged
+func GetLevel() Level {
+	return Level(atomic.LoadInt32(&currentLevel))
+}
+
+// Enabled reports whether messages of the given level are logged
+func Enabled(level Level) bool {
+	return level >= GetLevel()
+}
+
+// SetFormat changes how messages are written
+func SetFormat(format Format) {
+	atomic.StoreInt32(&currentFormat, int32(format))
+}
+
+// SetOutput changes where messages are written to, os.Stderr by default
+func SetOutput(w io.Writer) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	output = w
+}
+
+func Debug(ctx context.Context, msg string, args ...interface{}) {
+	write(ctx, LevelDebug, msg, args)
+}
+
+func Info(ctx context.Context, msg string, args ...interface{}) {
+	write(ctx, LevelInfo, msg, args)
+}
+
+func Warn(ctx context.Context, msg string, args ...interface{}) {
+	write(ctx, LevelWarn, msg, args)
+}
+
+func Error(ctx context.Context, msg string, args ...interface{}) {
+	write(ctx, LevelError, msg, args)
+}
+
+// Fatal logs an error and exits the program
+func Fatal(ctx context.Context, msg string, args ...interface{}) {
+	write(ctx, LevelError, msg, args)
+	os.Exit(1)
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+func write(ctx context.Context, level Level, msg string, args []interface{}) {
+	if !Enabled(level) {
+		return
+	}
+
+	fields := append(contextFields(ctx), argsToFields(args)...)
+
+	var line []byte
+	if Format(atomic.LoadInt32(&currentFormat)) == FormatJSON {
+		line = formatJSON(time.Now(), level, msg, fields)
+	} else {
+		line = formatText(time.Now(), level, msg, fields)
+	}
+
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	output.Write(line)
+}
+
+func argsToFields(args []interface{}) []field {
+	fields := make([]field, 0, (len(args)+1)/2)
+
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok || i+1 == len(args) {
+			// A value without a key, which is a mistake at the call site, but the value should not be lost
+			fields = append(fields, field{key: "!BADKEY", value: args[i]})
+			i--
+			continue
+		}
+
+		fields = append(fields, field{key: key, value: args[i+1]})
+	}
+
+	return fields
+}
+
+func formatText(t time.Time, level Level, msg string, fields []field) []byte {
+	var sb strings.Builder
+
+	sb.WriteString(t.Format("2006/01/02 15:04:05"))
+	sb.WriteByte(' ')
+	sb.WriteString(strings.ToUpper(level.String()))
+	sb.WriteByte(' ')
+	sb.WriteString(msg)
+
+	for _, f := range fields {
+		sb.WriteByte(' ')
+		sb.WriteString(f.key)
+		sb.WriteByte('=')
+		sb.WriteString(quoteIfNeeded(textValue(f.value)))
+	}
+
+	sb.WriteByte('\n')
+	return []byte(sb.String())
+}
+
+func textValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprintf("%+v", v)
+	}
+}
+
+func quoteIfNeeded(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\r\"=") {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+func formatJSON(t time.Time, level Level, msg string, fields []field) []byte {
+	entry := make(map[string]interface{}, len(fields)+3)
+	for _, f := range fields {
+		entry[f.key] = jsonValue(f.value)
+	}
+
+	entry["time"] = t.Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		// Fall back to the string representation of all values
+		for key, value := range entry {
+			entry[key] = textValue(value)
+		}
+		line, _ = json.Marshal(entry)
+	}
+
+	return append(line, '\n')
+}
+
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case json.Marshaler:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
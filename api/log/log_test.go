@@ -0,0 +1,114 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/photoview/photoview/api/log"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func captureOutput(t *testing.T, level log.Level, format log.Format) *bytes.Buffer {
+	output := bytes.Buffer{}
+	log.SetOutput(&output)
+	log.SetLevel(level)
+	log.SetFormat(format)
+
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(log.LevelInfo)
+		log.SetFormat(log.FormatText)
+	})
+
+	return &output
+}
+
+func TestTextFormat(t *testing.T) {
+	output := captureOutput(t, log.LevelInfo, log.FormatText)
+
+	log.Info(context.Background(), "media processed", "path", "/photos/a b.jpg", "count", 3, "error", errors.New("bad"))
+
+	line := output.String()
+	assert.True(t, strings.HasSuffix(line, "\n"))
+	assert.Contains(t, line, ` INFO media processed path="/photos/a b.jpg" count=3 error=bad`)
+}
+
+func TestJSONFormat(t *testing.T) {
+	output := captureOutput(t, log.LevelInfo, log.FormatJSON)
+
+	ctx := log.WithRequestID(context.Background(), "abc123")
+	log.Warn(ctx, "slow request", "status", 200)
+
+	var entry map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal(output.Bytes(), &entry)) {
+		return
+	}
+
+	assert.Equal(t, "warn", entry["level"])
+	assert.Equal(t, "slow request", entry["msg"])
+	assert.Equal(t, "abc123", entry["request_id"])
+	assert.EqualValues(t, 200, entry["status"])
+	assert.NotEmpty(t, entry["time"])
+}
+
+func TestLevelFiltering(t *testing.T) {
+	output := captureOutput(t, log.LevelWarn, log.FormatText)
+
+	log.Debug(context.Background(), "debug message")
+	log.Info(context.Background(), "info message")
+	assert.Empty(t, output.String())
+
+	log.Error(context.Background(), "error message")
+	assert.Contains(t, output.String(), "ERROR error message")
+
+	log.SetLevel(log.LevelDebug)
+	log.Debug(context.Background(), "debug message")
+	assert.Contains(t, output.String(), "DEBUG debug message")
+}
+
+func TestBadKey(t *testing.T) {
+	output := captureOutput(t, log.LevelInfo, log.FormatText)
+
+	log.Info(context.Background(), "message", 42, "key", "value", "dangling")
+
+	assert.Contains(t, output.String(), "!BADKEY=42 key=value !BADKEY=dangling")
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := log.ParseLevel(" WARNING ")
+	assert.NoError(t, err)
+	assert.Equal(t, log.LevelWarn, level)
+
+	_, err = log.ParseLevel("verbose")
+	assert.Error(t, err)
+
+	assert.Error(t, log.InitializeLogging("info", "xml"))
+}
+
+func TestContextFields(t *testing.T) {
+	output := captureOutput(t, log.LevelInfo, log.FormatText)
+
+	ctx, cancel := context.WithCancel(log.WithRequestID(context.Background(), "req1"))
+	jobCtx := log.WithAttrs(log.Detach(ctx), "album_id", 5)
+	cancel()
+
+	assert.NoError(t, jobCtx.Err(), "detached context should not be cancelled with its parent")
+	assert.Equal(t, "req1", log.RequestID(jobCtx))
+	assert.Equal(t, "", log.RequestID(context.Background()))
+
+	log.Info(jobCtx, "scanning")
+	assert.Contains(t, output.String(), "INFO scanning request_id=req1 album_id=5")
+
+	// Fields added to a derived context should not leak into its parent
+	log.WithAttrs(ctx, "extra", true)
+	output.Reset()
+	log.Info(ctx, "parent")
+	assert.NotContains(t, output.String(), "extra")
+
+	assert.Len(t, log.NewRequestID(), 16)
+}
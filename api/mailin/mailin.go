@@ -0,0 +1,127 @@
+// Package mailin implements an email-in upload gateway, where photos and videos mailed to a configured address
+// are imported into an album. Mail is received over SMTP, either directly or forwarded by a mail server.
+//
+// The From header of mail can be forged by anyone, so mail is only accepted for the address with a secret token
+// added to its local part, eg. upload+<token>@photos.example.com, which is only known to the allowed senders.
+package mailin
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Gateway receives mail over SMTP and imports the attached media into an album
+type Gateway struct {
+	db       *gorm.DB
+	hostname string
+	// address is the address mail is received at, mail is only accepted for it with the token added to its local part
+	address string
+	// token is the secret that must be added to the address, known only to the allowed senders
+	token string
+	// senders are the addresses allowed to send media, all in lower case
+	senders map[string]bool
+	// albumPath is the path of the album the media is imported into
+	albumPath string
+}
+
+// NewGateway creates a gateway, accepting mail to address with the token from the given senders and importing it into the album at albumPath
+func NewGateway(db *gorm.DB, address string, token string, senders []string, albumPath string) *Gateway {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "photoview"
+	}
+
+	allowedSenders := make(map[string]bool, len(senders))
+	for _, sender := range senders {
+		if sender = strings.ToLower(strings.TrimSpace(sender)); sender != "" {
+			allowedSenders[sender] = true
+		}
+	}
+
+	return &Gateway{
+		db:        db,
+		hostname:  hostname,
+		address:   strings.ToLower(strings.TrimSpace(address)),
+		token:     strings.TrimSpace(token),
+		senders:   allowedSenders,
+		albumPath: albumPath,
+	}
+}
+
+// InitializeMailIn starts the gateway, if an address to listen for mail on has been configured
+func InitializeMailIn(db *gorm.DB) error {
+	listenAddr := utils.EnvMailInListen.GetValue()
+	if listenAddr == "" {
+		return nil
+	}
+
+	address := utils.EnvMailInAddress.GetValue()
+	token := utils.EnvMailInToken.GetValue()
+	senders := strings.Split(utils.EnvMailInSenders.GetValue(), ",")
+	albumPath := utils.EnvMailInAlbum.GetValue()
+
+	if address == "" || albumPath == "" || token == "" {
+		return errors.Errorf("%s, %s and %s must be set to receive mail",
+			utils.EnvMailInAddress.GetName(), utils.EnvMailInToken.GetName(), utils.EnvMailInAlbum.GetName())
+	}
+
+	gateway := NewGateway(db, address, token, senders, albumPath)
+	if len(gateway.senders) == 0 {
+		return errors.Errorf("%s must list the addresses allowed to send media", utils.EnvMailInSenders.GetName())
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return errors.Wrap(err, "listen for mail")
+	}
+
+	go func() {
+		if err := gateway.Serve(listener); err != nil {
+			log.Error(context.Background(), "Email-in gateway stopped", "error", err)
+		}
+	}()
+
+	log.Info(context.Background(), "Receiving mail", "address", gateway.address, "listen", listener.Addr().String())
+	return nil
+}
+
+// Serve accepts SMTP connections on the listener, until it is closed
+func (g *Gateway) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go g.handleConnection(conn)
+	}
+}
+
+func (g *Gateway) senderAllowed(sender string) bool {
+	return g.senders[strings.ToLower(sender)]
+}
+
+// recipientAllowed returns whether mail to the recipient is accepted, which is the address of the gateway
+// with its token added to the local part, eg. upload+<token>@photos.example.com
+func (g *Gateway) recipientAllowed(recipient string) bool {
+	local, domain, ok := strings.Cut(g.address, "@")
+	recipientLocal, recipientDomain, recipientOK := strings.Cut(recipient, "@")
+	if !ok || !recipientOK || g.token == "" || !strings.EqualFold(domain, recipientDomain) {
+		return false
+	}
+
+	recipientLocal, token, found := strings.Cut(recipientLocal, "+")
+	if !found || !strings.EqualFold(local, recipientLocal) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(g.token)) == 1
+}
@@ -0,0 +1,120 @@
+package mailin
+
+import (
+	"net"
+	"net/smtp"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestParsePath(t *testing.T) {
+	address, ok := parsePath("FROM:<grandma@example.com> SIZE=1024", "FROM:")
+	assert.True(t, ok)
+	assert.Equal(t, "grandma@example.com", address)
+
+	address, ok = parsePath("to: <photos@example.com>", "TO:")
+	assert.True(t, ok)
+	assert.Equal(t, "photos@example.com", address)
+
+	_, ok = parsePath("FROM:grandma@example.com", "FROM:")
+	assert.False(t, ok)
+}
+
+const testMessage = "From: Grandma <%FROM%>\r\n" +
+	"To: photos@example.com\r\n" +
+	"Subject: Birthday\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Pictures from the party\r\n" +
+	"--outer\r\n" +
+	"Content-Type: image/jpeg; name=\"party.jpg\"\r\n" +
+	"Content-Disposition: attachment; filename=\"party.jpg\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"SU1BR0UgREFUQQ==\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf; name=\"invitation.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"UERG\r\n" +
+	"--outer--\r\n"
+
+func TestGateway(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	album := models.Album{Title: "From grandma", Path: t.TempDir()}
+	if !assert.NoError(t, db.Create(&album).Error) {
+		return
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	gateway := NewGateway(db, "Photos@example.com", "s3cret", []string{"grandma@example.com", " "}, album.Path)
+	go gateway.Serve(listener)
+
+	send := func(from string) error {
+		message := strings.ReplaceAll(testMessage, "%FROM%", from)
+		return smtp.SendMail(listener.Addr().String(), nil, from, []string{"photos+s3cret@example.com"}, []byte(message))
+	}
+
+	t.Run("Allowed sender", func(t *testing.T) {
+		assert.NoError(t, send("Grandma@example.com"))
+
+		imported, err := os.ReadFile(path.Join(album.Path, "party.jpg"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "IMAGE DATA", string(imported))
+		}
+		assert.NoFileExists(t, path.Join(album.Path, "invitation.pdf"))
+
+		var count int64
+		assert.NoError(t, db.Model(&models.Media{}).Where("album_id = ?", album.ID).Count(&count).Error)
+		assert.EqualValues(t, 1, count)
+	})
+
+	t.Run("Sender not allowed", func(t *testing.T) {
+		err := send("stranger@example.com")
+		assert.ErrorContains(t, err, "550")
+		assert.NoFileExists(t, path.Join(album.Path, "party (1).jpg"))
+	})
+
+	t.Run("Unknown recipient", func(t *testing.T) {
+		for _, recipient := range []string{"other@example.com", "photos@example.com", "photos+wrong@example.com", "photos+s3cret@other.com"} {
+			message := strings.ReplaceAll(testMessage, "%FROM%", "grandma@example.com")
+			err := smtp.SendMail(listener.Addr().String(), nil, "grandma@example.com", []string{recipient}, []byte(message))
+			assert.ErrorContains(t, err, "550", recipient)
+		}
+		assert.NoFileExists(t, path.Join(album.Path, "party (1).jpg"))
+	})
+}
+
+func TestRecipientAllowed(t *testing.T) {
+	gateway := NewGateway(nil, "upload@photos.example.com", "token", nil, "/photos")
+
+	assert.True(t, gateway.recipientAllowed("upload+token@photos.example.com"))
+	assert.True(t, gateway.recipientAllowed("Upload+token@Photos.example.com"))
+	assert.False(t, gateway.recipientAllowed("upload+Token@photos.example.com"), "the token is case sensitive")
+	assert.False(t, gateway.recipientAllowed("upload@photos.example.com"))
+	assert.False(t, gateway.recipientAllowed("upload+@photos.example.com"))
+	assert.False(t, gateway.recipientAllowed("other+token@photos.example.com"))
+
+	assert.False(t, NewGateway(nil, "upload@photos.example.com", "", nil, "/photos").recipientAllowed("upload+@photos.example.com"))
+}
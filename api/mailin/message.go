@@ -0,0 +1,171 @@
+package mailin
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/scanner/scanner_queue"
+	"github.com/pkg/errors"
+)
+
+var errSenderNotAllowed = errors.New("sender is not allowed to send media")
+
+// mimeHeader is the part of the headers of a message or a MIME part, needed to read its content
+type mimeHeader interface {
+	Get(key string) string
+}
+
+// deliver imports the photos and videos attached to a message into the album of the gateway.
+// Returns the number of imported files.
+func (g *Gateway) deliver(ctx context.Context, r io.Reader) (int, error) {
+	message, err := mail.ReadMessage(r)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse message")
+	}
+
+	// The From header is checked rather than the envelope sender, as it is kept when mail is forwarded
+	from, err := mail.ParseAddress(message.Header.Get("From"))
+	if err != nil || !g.senderAllowed(from.Address) {
+		return 0, errSenderNotAllowed
+	}
+
+	var album models.Album
+	if err := g.db.Where("path_hash = ?", models.MD5Hash(g.albumPath)).First(&album).Error; err != nil {
+		return 0, errors.Wrapf(err, "find album of email-in gateway (%s)", g.albumPath)
+	}
+
+	imported := 0
+	err = walkParts(message.Header, message.Body, func(filename string, content io.Reader) error {
+		if _, err := scanner.ValidUploadFilename(filename); err != nil {
+			return nil
+		}
+
+		if err := g.importAttachment(&album, filename, content); err != nil {
+			return err
+		}
+
+		imported++
+		return nil
+	})
+	if err != nil {
+		return imported, err
+	}
+
+	if imported > 0 {
+		log.Info(ctx, "Imported mailed media", "count", imported, "sender", from.Address, "album", album.Path)
+
+		if err := scanner_queue.AddAlbumToQueue(ctx, &album); err != nil {
+			log.Warn(ctx, "Could not queue album for scanning after email-in upload", "error", err)
+		}
+	}
+
+	return imported, nil
+}
+
+func (g *Gateway) importAttachment(album *models.Album, filename string, content io.Reader) error {
+	tmpFile, err := os.CreateTemp("", "photoview-mail-attachment-*")
+	if err != nil {
+		return errors.Wrap(err, "create temporary file for attachment")
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = io.Copy(tmpFile, content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "read attachment (%s)", filename)
+	}
+
+	if _, err := scanner.ImportMediaFile(g.db, album, tmpFile.Name(), filename); err != nil {
+		return errors.Wrapf(err, "import attachment (%s)", filename)
+	}
+
+	return nil
+}
+
+// walkParts calls fn with the decoded content of every attachment and inline image of a message, including nested parts
+func walkParts(header mimeHeader, body io.Reader, fn func(filename string, content io.Reader) error) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := parts.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrap(err, "read part of message")
+			}
+
+			if err := walkParts(part.Header, part, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	filename := partFilename(header, mediaType, params)
+	if filename == "" {
+		return nil
+	}
+
+	var content io.Reader = body
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		content = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		content = quotedprintable.NewReader(body)
+	}
+
+	return fn(filename, content)
+}
+
+// partFilename returns the file name of an attachment, or a generated one for unnamed images and videos.
+// Returns an empty string for parts that are not files, such as the text of the message.
+func partFilename(header mimeHeader, mediaType string, params map[string]string) string {
+	filename := params["name"]
+	if _, dispositionParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && dispositionParams["filename"] != "" {
+		filename = dispositionParams["filename"]
+	}
+
+	if filename != "" {
+		// Some mail clients encode non-ascii file names as encoded-words instead of RFC 2231
+		if decoded, err := new(mime.WordDecoder).DecodeHeader(filename); err == nil {
+			filename = decoded
+		}
+		return filename
+	}
+
+	if !strings.HasPrefix(mediaType, "image/") && !strings.HasPrefix(mediaType, "video/") {
+		return ""
+	}
+
+	extensions, err := mime.ExtensionsByType(mediaType)
+	if err != nil {
+		return ""
+	}
+
+	for _, ext := range extensions {
+		filename := "mail-" + time.Now().Format("20060102-150405") + ext
+		if _, err := scanner.ValidUploadFilename(filename); err == nil {
+			return filename
+		}
+	}
+
+	return ""
+}
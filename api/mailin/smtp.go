@@ -0,0 +1,217 @@
+package mailin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/maintenance"
+
+	"github.com/pkg/errors"
+)
+
+// Largest message accepted, media is mailed as base64 which makes it a third larger
+const maxMessageSize = 64 << 20
+
+// How long a client may be idle before the connection is closed
+const commandTimeout = 5 * time.Minute
+
+// smtpSession is the state of a single SMTP connection.
+// Only the commands needed to receive mail are supported. Senders are authenticated by the token in the recipient address,
+// and TLS is left to a mail server in front.
+type smtpSession struct {
+	ctx        context.Context
+	gateway    *Gateway
+	conn       net.Conn
+	text       *textproto.Conn
+	sender     string
+	recipients []string
+}
+
+func (g *Gateway) handleConnection(conn net.Conn) {
+	session := &smtpSession{
+		ctx:     log.WithAttrs(context.Background(), "remote_addr", conn.RemoteAddr().String()),
+		gateway: g,
+		conn:    conn,
+		text:    textproto.NewConn(conn),
+	}
+	defer session.text.Close()
+
+	session.reply(220, fmt.Sprintf("%s ESMTP Photoview", g.hostname))
+
+	for {
+		conn.SetDeadline(time.Now().Add(commandTimeout))
+
+		line, err := session.text.ReadLine()
+		if err != nil {
+			if err != io.EOF {
+				log.Warn(session.ctx, "Reading smtp command", "error", err)
+			}
+			return
+		}
+
+		command, argument, _ := strings.Cut(line, " ")
+		if !session.handleCommand(strings.ToUpper(command), strings.TrimSpace(argument)) {
+			return
+		}
+	}
+}
+
+// handleCommand handles a single command, returning false when the connection should be closed
+func (s *smtpSession) handleCommand(command string, argument string) bool {
+	switch command {
+	case "HELO":
+		s.reset()
+		s.reply(250, s.gateway.hostname)
+	case "EHLO":
+		s.reset()
+		s.reply(250, s.gateway.hostname, fmt.Sprintf("SIZE %d", maxMessageSize), "8BITMIME")
+	case "MAIL":
+		address, ok := parsePath(argument, "FROM:")
+		if !ok {
+			s.reply(501, "Syntax: MAIL FROM:<address>")
+			return true
+		}
+		s.reset()
+		s.sender = address
+		s.reply(250, "OK")
+	case "RCPT":
+		if s.sender == "" {
+			s.reply(503, "MAIL command required first")
+			return true
+		}
+
+		address, ok := parsePath(argument, "TO:")
+		if !ok {
+			s.reply(501, "Syntax: RCPT TO:<address>")
+			return true
+		}
+
+		if !s.gateway.recipientAllowed(address) {
+			s.reply(550, "No such mailbox")
+			return true
+		}
+
+		s.recipients = append(s.recipients, address)
+		s.reply(250, "OK")
+	case "DATA":
+		if len(s.recipients) == 0 {
+			s.reply(503, "RCPT command required first")
+			return true
+		}
+
+		// Senders retry temporary failures, so the mail is delivered once maintenance has finished
+		if maintenance.Enabled() {
+			s.reply(451, "Server is in maintenance mode, try again later")
+			s.reset()
+			return true
+		}
+
+		s.receiveData()
+		s.reset()
+	case "RSET":
+		s.reset()
+		s.reply(250, "OK")
+	case "NOOP":
+		s.reply(250, "OK")
+	case "QUIT":
+		s.reply(221, "Bye")
+		return false
+	default:
+		s.reply(502, "Command not implemented")
+	}
+
+	return true
+}
+
+func (s *smtpSession) receiveData() {
+	s.reply(354, "End data with <CR><LF>.<CR><LF>")
+
+	// The message is spooled to disk first, so nothing is imported from messages that turn out to be too large
+	spool, err := os.CreateTemp("", "photoview-mail-*")
+	if err != nil {
+		log.Error(s.ctx, "Creating spool file for mail", "error", err)
+		s.reply(451, "Could not receive the message, try again later")
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	data := s.text.DotReader()
+	size, err := io.Copy(spool, io.LimitReader(data, maxMessageSize+1))
+	if err == nil {
+		// Read the rest of the message, so the connection is ready for the next command
+		_, err = io.Copy(io.Discard, data)
+	}
+	if err != nil {
+		log.Warn(s.ctx, "Reading mail", "error", err)
+		s.reply(451, "Could not receive the message, try again later")
+		return
+	}
+
+	if size > maxMessageSize {
+		s.reply(552, "Message exceeds the maximum size")
+		return
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		log.Error(s.ctx, "Reading spooled mail", "error", err)
+		s.reply(451, "Could not receive the message, try again later")
+		return
+	}
+
+	imported, err := s.gateway.deliver(s.ctx, spool)
+
+	switch {
+	case errors.Is(err, errSenderNotAllowed):
+		s.reply(550, "Sender is not allowed to send media")
+	case err != nil:
+		log.Error(s.ctx, "Importing mail", "sender", s.sender, "error", err)
+		s.reply(451, "Could not import the media, try again later")
+	case imported == 0:
+		s.reply(554, "No supported photos or videos found in the message")
+	default:
+		s.reply(250, fmt.Sprintf("OK, imported %d files", imported))
+	}
+}
+
+func (s *smtpSession) reset() {
+	s.sender = ""
+	s.recipients = nil
+}
+
+// reply writes a reply, with a line for each of the messages
+func (s *smtpSession) reply(code int, messages ...string) {
+	for i, message := range messages {
+		separator := "-"
+		if i == len(messages)-1 {
+			separator = " "
+		}
+		s.text.PrintfLine("%d%s%s", code, separator, message)
+	}
+}
+
+// parsePath parses the address of a MAIL or RCPT command, eg. "FROM:<user@example.com> SIZE=1024"
+func parsePath(argument string, prefix string) (string, bool) {
+	if len(argument) < len(prefix) || !strings.EqualFold(argument[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	path := strings.TrimSpace(argument[len(prefix):])
+	if !strings.HasPrefix(path, "<") {
+		return "", false
+	}
+
+	end := strings.Index(path, ">")
+	if end < 0 {
+		return "", false
+	}
+
+	return path[1:end], true
+}
@@ -0,0 +1,188 @@
+// Package maintenance puts the server in maintenance mode, pausing scans and uploads,
+// while housekeeping tasks such as cleaning the cache run, and resumes normal operation afterwards.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/graphql/notification"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/scanner_queue"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ErrMaintenance is returned by operations that are paused during maintenance
+var ErrMaintenance = errors.New("server is in maintenance mode, try again later")
+
+// progressFunc reports the progress of a task, from 0 to 1
+type progressFunc func(progress float64)
+
+// taskFunc runs a task and returns a summary of what it did
+type taskFunc func(ctx context.Context, db *gorm.DB, progress progressFunc) (string, error)
+
+var taskFuncs = map[models.MaintenanceTask]taskFunc{
+	models.MaintenanceTaskCacheCleanup:   cleanupCache,
+	models.MaintenanceTaskIntegrityCheck: checkIntegrity,
+	models.MaintenanceTaskVacuum:         vacuumDatabase,
+}
+
+var taskNames = map[models.MaintenanceTask]string{
+	models.MaintenanceTaskCacheCleanup:   "Cleaning up cache",
+	models.MaintenanceTaskIntegrityCheck: "Checking integrity",
+	models.MaintenanceTaskVacuum:         "Vacuuming database",
+}
+
+var (
+	statusLock = &sync.Mutex{}
+	status     = models.MaintenanceStatus{Results: make([]*models.MaintenanceTaskResult, 0)}
+)
+
+// Enabled returns whether the server is in maintenance mode
+func Enabled() bool {
+	statusLock.Lock()
+	defer statusLock.Unlock()
+
+	return status.Enabled
+}
+
+// GetStatus returns the state of the maintenance mode, and the results of the latest maintenance
+func GetStatus() *models.MaintenanceStatus {
+	statusLock.Lock()
+	defer statusLock.Unlock()
+
+	return copyStatus()
+}
+
+// Status lock should be held prior to calling this function
+func copyStatus() *models.MaintenanceStatus {
+	result := status
+	result.Results = append([]*models.MaintenanceTaskResult{}, status.Results...)
+	return &result
+}
+
+// Start puts the server in maintenance mode and runs the given tasks in the background, or all tasks if none are given.
+// Once jobs already in progress have been scanned, the tasks are run one after another,
+// and when they have finished the server returns to normal operation.
+func Start(db *gorm.DB, tasks []models.MaintenanceTask) (*models.MaintenanceStatus, error) {
+	if len(tasks) == 0 {
+		tasks = models.AllMaintenanceTask
+	}
+
+	for _, task := range tasks {
+		if _, found := taskFuncs[task]; !found {
+			return nil, errors.Errorf("unsupported maintenance task: %s", task)
+		}
+	}
+
+	statusLock.Lock()
+	defer statusLock.Unlock()
+
+	if status.Enabled {
+		return nil, errors.New("maintenance is already running")
+	}
+
+	startedAt := time.Now()
+	status = models.MaintenanceStatus{
+		Enabled:   true,
+		StartedAt: &startedAt,
+		Results:   make([]*models.MaintenanceTaskResult, 0),
+	}
+
+	scanner_queue.PauseScannerQueue()
+
+	ctx := log.Detach(db.Statement.Context)
+	log.Info(ctx, "Maintenance started", "tasks", fmt.Sprint(tasks))
+
+	go run(ctx, db.WithContext(ctx), tasks)
+
+	return copyStatus(), nil
+}
+
+func run(ctx context.Context, db *gorm.DB, tasks []models.MaintenanceTask) {
+	broadcastProgress("Waiting for scanner", "Waiting for the albums being scanned to finish", 0)
+	scanner_queue.WaitForJobsInProgress()
+
+	failed := 0
+	for _, task := range tasks {
+		currentTask := task
+
+		statusLock.Lock()
+		status.CurrentTask = &currentTask
+		status.Progress = 0
+		statusLock.Unlock()
+
+		broadcastProgress(taskNames[task], "", 0)
+
+		notifyThrottle := utils.NewThrottle(500 * time.Millisecond)
+		taskStart := time.Now()
+		message, err := taskFuncs[task](ctx, db, func(progress float64) {
+			statusLock.Lock()
+			status.Progress = progress
+			statusLock.Unlock()
+
+			notifyThrottle.Trigger(func() {
+				broadcastProgress(taskNames[currentTask], "", progress)
+			})
+		})
+
+		result := &models.MaintenanceTaskResult{Task: task, Success: err == nil, Message: message}
+		if err != nil {
+			failed++
+			result.Message = err.Error()
+			log.Error(ctx, "Maintenance task failed", "task", task, "error", err)
+		} else {
+			log.Info(ctx, "Maintenance task finished", "task", task, "result", message, "duration", time.Since(taskStart))
+		}
+
+		statusLock.Lock()
+		status.Results = append(status.Results, result)
+		statusLock.Unlock()
+	}
+
+	statusLock.Lock()
+	finishedAt := time.Now()
+	status.Enabled = false
+	status.CurrentTask = nil
+	status.Progress = 0
+	status.FinishedAt = &finishedAt
+	statusLock.Unlock()
+
+	scanner_queue.ResumeScannerQueue()
+	log.Info(ctx, "Maintenance finished", "failed_tasks", failed)
+
+	timeoutDelay := 5000
+	done := &models.Notification{
+		Key:      "maintenance-progress",
+		Type:     models.NotificationTypeMessage,
+		Header:   "Maintenance finished",
+		Content:  "Scans and uploads have been resumed",
+		Positive: failed == 0,
+		Negative: failed > 0,
+		Timeout:  &timeoutDelay,
+	}
+	if failed > 0 {
+		done.Content = fmt.Sprintf("%d of %d tasks failed, scans and uploads have been resumed", failed, len(tasks))
+	}
+	notification.BroadcastNotification(done)
+}
+
+func broadcastProgress(header string, content string, progress float64) {
+	if content == "" {
+		content = "Scans and uploads are paused until maintenance has finished"
+	}
+
+	percent := progress * 100.0
+	notification.BroadcastNotification(&models.Notification{
+		Key:      "maintenance-progress",
+		Type:     models.NotificationTypeProgress,
+		Header:   "Maintenance: " + header,
+		Content:  content,
+		Progress: &percent,
+	})
+}
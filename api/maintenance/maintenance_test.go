@@ -0,0 +1,115 @@
+package maintenance_test
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/maintenance"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func waitForMaintenance(t *testing.T) *models.MaintenanceStatus {
+	deadline := time.Now().Add(10 * time.Second)
+	for maintenance.Enabled() {
+		if time.Now().After(deadline) {
+			t.Fatal("maintenance did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return maintenance.GetStatus()
+}
+
+func TestCacheCleanup(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	assert.NoError(t, db.Save(&album).Error)
+
+	media := models.Media{Title: "a.jpg", Path: "/photos/a.jpg", AlbumID: album.ID}
+	assert.NoError(t, db.Save(&media).Error)
+
+	cachedMedia, err := media.CachePath()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cachePath := utils.MediaCachePath()
+	albumCache := path.Join(cachePath, strconv.Itoa(album.ID))
+	deletedMedia := path.Join(albumCache, strconv.Itoa(media.ID+1))
+	deletedAlbum := path.Join(cachePath, strconv.Itoa(album.ID+1))
+	unrelated := path.Join(cachePath, "unrelated")
+
+	for _, dir := range []string{deletedMedia, deletedAlbum, unrelated} {
+		assert.NoError(t, os.MkdirAll(dir, 0755))
+	}
+
+	status, err := maintenance.Start(db, []models.MaintenanceTask{models.MaintenanceTaskCacheCleanup, models.MaintenanceTaskVacuum})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, status.Enabled)
+
+	status = waitForMaintenance(t)
+	assert.NotNil(t, status.FinishedAt)
+	if assert.Len(t, status.Results, 2) {
+		assert.Equal(t, models.MaintenanceTaskCacheCleanup, status.Results[0].Task)
+		assert.True(t, status.Results[0].Success, status.Results[0].Message)
+		assert.Contains(t, status.Results[0].Message, "1 deleted albums and 1 deleted media")
+
+		assert.Equal(t, models.MaintenanceTaskVacuum, status.Results[1].Task)
+		assert.True(t, status.Results[1].Success, status.Results[1].Message)
+	}
+
+	assert.DirExists(t, cachedMedia)
+	assert.DirExists(t, unrelated)
+	assert.NoDirExists(t, deletedMedia)
+	assert.NoDirExists(t, deletedAlbum)
+}
+
+func TestIntegrityCheck(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	existing, err := os.CreateTemp(t.TempDir(), "*.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+	existing.Close()
+
+	album := models.Album{Title: "album", Path: path.Dir(existing.Name())}
+	assert.NoError(t, db.Save(&album).Error)
+
+	media := []models.Media{
+		{Title: "existing.jpg", Path: existing.Name(), AlbumID: album.ID},
+		{Title: "missing.jpg", Path: path.Join(album.Path, "missing.jpg"), AlbumID: album.ID},
+	}
+	assert.NoError(t, db.Save(&media).Error)
+
+	_, err = maintenance.Start(db, []models.MaintenanceTask{models.MaintenanceTaskIntegrityCheck})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	status := waitForMaintenance(t)
+	if assert.Len(t, status.Results, 1) {
+		assert.True(t, status.Results[0].Success, status.Results[0].Message)
+		assert.Contains(t, status.Results[0].Message, "Checked 2 media: 1 originals")
+	}
+}
+
+func TestStartUnsupportedTask(t *testing.T) {
+	_, err := maintenance.Start(nil, []models.MaintenanceTask{"UNKNOWN"})
+	assert.Error(t, err)
+	assert.False(t, maintenance.Enabled())
+}
@@ -0,0 +1,137 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/photoview/photoview/api/database/drivers"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// cleanupCache deletes the cache directories of albums and media that no longer exist,
+// which are left behind when media is removed while the server is stopped,
+// then removes the files of the content addressed cache no longer used by any media and the files left incomplete by a crash,
+// and enforces the cache budget
+func cleanupCache(ctx context.Context, db *gorm.DB, progress progressFunc) (string, error) {
+	orphaned, err := storage.RemoveOrphanedCacheDirectories(db, progress)
+	if err != nil {
+		return "", errors.Wrap(err, "remove orphaned cache directories")
+	}
+
+	removedIncomplete, err := storage.RemoveIncompleteCacheFiles(db)
+	if err != nil {
+		return "", errors.Wrap(err, "remove incomplete cache files")
+	}
+
+	removedEntries, err := storage.CollectCacheGarbage(db, storage.CacheGarbageGracePeriod)
+	if err != nil {
+		return "", errors.Wrap(err, "collect cache garbage")
+	}
+
+	usage, err := storage.CheckCacheBudget(db)
+	if err != nil {
+		return "", errors.Wrap(err, "check cache budget")
+	}
+
+	progress(1)
+
+	return fmt.Sprintf("Removed the cache of %d deleted albums and %d deleted media reclaiming %d bytes, %d unused and %d incomplete cached files, the cache now uses %d bytes",
+		orphaned.Albums, orphaned.Media, orphaned.Bytes, removedEntries, removedIncomplete, usage.UsedBytes), nil
+}
+
+// checkIntegrity checks that the original files of all media still exist, and counts the cached files that are missing.
+// Problems are logged and summarized, nothing is changed, as missing originals are removed by the next scan,
+// and missing cached files are regenerated when they are requested.
+func checkIntegrity(ctx context.Context, db *gorm.DB, progress progressFunc) (string, error) {
+	var total int64
+	if err := db.Model(&models.Media{}).Count(&total).Error; err != nil {
+		return "", errors.Wrap(err, "count media")
+	}
+
+	checked, missingOriginals, missingSideCars, missingCached := 0, 0, 0, 0
+
+	var results []*models.Media
+	err := db.Preload("MediaURL").FindInBatches(&results, 100, func(tx *gorm.DB, batch int) error {
+		for _, media := range results {
+			if _, err := os.Stat(media.Path); err != nil {
+				log.Warn(ctx, "Original of media is missing", "media_id", media.ID, "path", media.Path, "error", err)
+				missingOriginals++
+			}
+
+			if media.SideCarPath != nil {
+				if _, err := os.Stat(*media.SideCarPath); err != nil {
+					log.Warn(ctx, "Sidecar of media is missing", "media_id", media.ID, "path", *media.SideCarPath, "error", err)
+					missingSideCars++
+				}
+			}
+
+			for i := range media.MediaURL {
+				mediaURL := &media.MediaURL[i]
+				if mediaURL.Purpose == models.MediaOriginal {
+					continue
+				}
+
+				mediaURL.Media = media
+				cachedPath, err := mediaURL.CachedPath()
+				if err != nil {
+					continue
+				}
+
+				if _, err := os.Stat(cachedPath); err != nil {
+					missingCached++
+				}
+			}
+		}
+
+		checked += len(results)
+		progress(float64(checked) / float64(total))
+		return nil
+	}).Error
+	if err != nil {
+		return "", errors.Wrap(err, "check media")
+	}
+
+	return fmt.Sprintf("Checked %d media: %d originals and %d sidecars missing, %d cached files missing that are regenerated when requested",
+		checked, missingOriginals, missingSideCars, missingCached), nil
+}
+
+// vacuumDatabase reclaims the space of deleted rows and updates the statistics of the query planner
+func vacuumDatabase(ctx context.Context, db *gorm.DB, progress progressFunc) (string, error) {
+	switch drivers.GetDatabaseDriverType(db) {
+	case drivers.SQLITE:
+		if err := db.Exec("VACUUM").Error; err != nil {
+			return "", errors.Wrap(err, "vacuum sqlite database")
+		}
+		if err := db.Exec("ANALYZE").Error; err != nil {
+			return "", errors.Wrap(err, "analyze sqlite database")
+		}
+	case drivers.POSTGRES:
+		if err := db.Exec("VACUUM ANALYZE").Error; err != nil {
+			return "", errors.Wrap(err, "vacuum postgres database")
+		}
+	case drivers.MYSQL:
+		tables, err := db.Migrator().GetTables()
+		if err != nil {
+			return "", errors.Wrap(err, "get database tables")
+		}
+
+		for i, table := range tables {
+			progress(float64(i) / float64(len(tables)))
+
+			// OPTIMIZE TABLE returns a result set, which has to be read for the statement to finish
+			var results []map[string]interface{}
+			if err := db.Raw("OPTIMIZE TABLE " + db.Statement.Quote(table)).Scan(&results).Error; err != nil {
+				return "", errors.Wrapf(err, "optimize table %s", table)
+			}
+		}
+	}
+
+	progress(1)
+
+	return fmt.Sprintf("Vacuumed %s database", drivers.GetDatabaseDriverType(db)), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/photoview/photoview/api/database"
+	"github.com/photoview/photoview/api/database/drivers"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/importer"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/exif"
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// pathMappings is a repeatable command line flag of the form source=target
+type pathMappings map[string]string
+
+func (m pathMappings) String() string {
+	mappings := make([]string, 0, len(m))
+	for source, target := range m {
+		mappings = append(mappings, source+"="+target)
+	}
+	return strings.Join(mappings, ",")
+}
+
+func (m pathMappings) Set(value string) error {
+	source, target, found := strings.Cut(value, "=")
+	if !found || source == "" || target == "" {
+		return errors.New("expected a mapping of the form source=target")
+	}
+
+	m[source] = target
+	return nil
+}
+
+// runMigrateCommand migrates favorites, people, albums and edited metadata from the database of PhotoPrism or Immich.
+//
+//	photoview migrate -from photoprism -dsn 'user:pass@tcp(mariadb)/photoprism' -user admin -map /=/photos
+func runMigrateCommand(db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := flags.String("from", "", "application to migrate from, either photoprism or immich")
+	driver := flags.String("driver", "", "database driver of the application: mysql, postgres or sqlite (defaults to mysql for photoprism and postgres for immich)")
+	dsn := flags.String("dsn", "", "address of the database of the application")
+	username := flags.String("user", "", "Photoview user to migrate favorites for")
+	sourceUser := flags.String("source-user", "", "only migrate media of the user with this email (immich only)")
+	albumsInto := flags.Int("albums-into", 0, "id of the album to copy albums into as sub albums, albums are skipped if not set")
+	mappings := pathMappings{}
+	flags.Var(mappings, "map", "translate a path prefix of the application to a path of the Photoview library, eg. /usr/src/app/upload=/photos (repeatable)")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	source := importer.MigrationSource(strings.ToLower(*from))
+	if *driver == "" {
+		switch source {
+		case importer.MigrationSourcePhotoPrism:
+			*driver = string(drivers.MYSQL)
+		case importer.MigrationSourceImmich:
+			*driver = string(drivers.POSTGRES)
+		}
+	}
+
+	if *dsn == "" || *username == "" {
+		flags.Usage()
+		return errors.New("-dsn and -user are required")
+	}
+
+	var user models.User
+	if err := db.Where("username = ?", *username).First(&user).Error; err != nil {
+		return errors.Wrapf(err, "find user %s", *username)
+	}
+
+	options := importer.MigrationOptions{
+		Source:       source,
+		UserID:       user.ID,
+		SourceUser:   *sourceUser,
+		PathMappings: mappings,
+	}
+
+	if *albumsInto != 0 {
+		var album models.Album
+		if err := db.First(&album, *albumsInto).Error; err != nil {
+			return errors.Wrapf(err, "find album %d", *albumsInto)
+		}
+		options.AlbumsParent = &album
+	}
+
+	sourceDB, err := database.OpenExternalDatabase(drivers.DatabaseDriverType(strings.ToLower(*driver)), *dsn)
+	if err != nil {
+		return errors.Wrapf(err, "connect to %s database", source)
+	}
+
+	// Needed to read the media copied into albums
+	executable_worker.InitializeExecutableWorkers()
+	exif.InitializeEXIFParser()
+
+	result, err := importer.Migrate(db, sourceDB, options)
+	if err != nil {
+		return err
+	}
+
+	ctx := db.Statement.Context
+	log.Info(ctx, "Migration completed",
+		"source", source,
+		"migrated_media", result.MigratedMedia,
+		"migrated_albums", result.MigratedAlbums,
+		"unmatched_media", result.UnmatchedMedia)
+
+	if result.UnmatchedMedia > 0 {
+		log.Info(ctx, "Make sure the library has been scanned, and use -map if the media is mounted at different paths")
+	}
+
+	return nil
+}
@@ -0,0 +1,340 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/cluster"
+	"github.com/photoview/photoview/api/database"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Number of error messages included in a digest, the rest are only counted
+const maxDigestErrors = 20
+
+// Hour of the day, in local time, memories reminders are sent at
+const memoriesHour = 9
+
+var (
+	scanStateLock   = &sync.Mutex{}
+	lastScanSummary time.Time
+)
+
+// ScanCompleted notifies users of the media added to their albums since the previous scan,
+// and admins of the failures in the report of the scan, if it has any
+func ScanCompleted(report *models.ScanReport) {
+	db := getDB()
+	if db == nil {
+		return
+	}
+
+	scanStateLock.Lock()
+	since := lastScanSummary
+	lastScanSummary = time.Now()
+	scanStateLock.Unlock()
+
+	go func() {
+		if err := sendScanSummaries(db, since); err != nil {
+			log.Warn(context.Background(), "Sending scan summaries", "error", err)
+		}
+
+		if report != nil && report.FailureCount > 0 {
+			if err := sendErrorDigest(db, report); err != nil {
+				log.Warn(context.Background(), "Sending error digest", "error", err)
+			}
+		}
+	}()
+}
+
+func sendScanSummaries(db *gorm.DB, since time.Time) error {
+	var counts []struct {
+		UserID int
+		Type   models.MediaType
+		Count  int
+	}
+
+	err := db.Model(&models.Media{}).
+		Select("user_albums.user_id AS user_id, media.type AS type, COUNT(*) AS count").
+		Joins("JOIN user_albums ON user_albums.album_id = media.album_id").
+		Where("media.created_at > ?", since).
+		Group("user_albums.user_id, media.type").
+		Order("user_albums.user_id, media.type").
+		Scan(&counts).Error
+	if err != nil {
+		return errors.Wrap(err, "count new media of users")
+	}
+
+	// Users without new media are not notified, so periodic scans finding nothing new don't notify anyone
+	userIDs := make([]int, 0)
+	parts := make(map[int][]string)
+	for _, count := range counts {
+		if _, found := parts[count.UserID]; !found {
+			userIDs = append(userIDs, count.UserID)
+		}
+		parts[count.UserID] = append(parts[count.UserID], pluralize(count.Count, "new "+string(count.Type)))
+	}
+
+	for _, userID := range userIDs {
+		notifyUser(db, userID, &Message{
+			Event: models.NotificationEventScanCompleted,
+			Title: "Scan complete",
+			Body:  strings.Join(parts[userID], " and ") + " added to your library.",
+		})
+	}
+
+	return nil
+}
+
+func sendErrorDigest(db *gorm.DB, report *models.ScanReport) error {
+	var failures []*models.ScanFailure
+	err := db.Where("scan_report_id = ?", report.ID).
+		Order("id").
+		Limit(maxDigestErrors).
+		Find(&failures).Error
+	if err != nil {
+		return errors.Wrap(err, "get failures of scan report")
+	}
+
+	var admins []*models.User
+	if err := db.Where("admin = ?", true).Find(&admins).Error; err != nil {
+		return errors.Wrap(err, "get admins from database")
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s occurred during the last scan:\n", pluralize(report.FailureCount, "error"))
+	for _, failure := range failures {
+		body.WriteString("\n- " + failure.Message)
+	}
+	if report.FailureCount > len(failures) {
+		fmt.Fprintf(&body, "\n\nand %d more, see scan report %d for all errors.", report.FailureCount-len(failures), report.ID)
+	}
+
+	for _, admin := range admins {
+		notifyUser(db, admin.ID, &Message{
+			Event: models.NotificationEventErrorDigest,
+			Title: "Errors during scan",
+			Body:  body.String(),
+		})
+	}
+
+	return nil
+}
+
+// How long after a share has been viewed, views of it are not notified again
+const shareViewedInterval = time.Hour
+
+var (
+	shareViewsLock  = &sync.Mutex{}
+	shareLastViewed = make(map[int]time.Time)
+)
+
+// ShareViewed notifies the owner of a share that it has been opened,
+// at most once an hour for every share, as a share is queried on every page of it
+func ShareViewed(token *models.ShareToken) {
+	db := getDB()
+	if db == nil {
+		return
+	}
+
+	shareViewsLock.Lock()
+	lastViewed, viewed := shareLastViewed[token.ID]
+	if viewed && time.Since(lastViewed) < shareViewedInterval {
+		shareViewsLock.Unlock()
+		return
+	}
+	shareLastViewed[token.ID] = time.Now()
+	shareViewsLock.Unlock()
+
+	go func() {
+		subject, err := shareSubject(db, token)
+		if err != nil {
+			log.Warn(context.Background(), "Getting subject of viewed share", "error", err)
+			return
+		}
+
+		notifyUser(db, token.OwnerID, &Message{
+			Event: models.NotificationEventShareViewed,
+			Title: "Share viewed",
+			Body:  fmt.Sprintf("The share link of %s has been opened.", subject),
+		})
+	}()
+}
+
+// ImportCompleted notifies the user who started an import that it has finished
+func ImportCompleted(job *models.ImportJob) {
+	db := getDB()
+	if db == nil {
+		return
+	}
+
+	message := &Message{Event: models.NotificationEventImportCompleted}
+	if job.Status == models.ImportStatusFailed {
+		message.Title = "Import failed"
+		message.Body = fmt.Sprintf("The import from %s failed", job.SourcePath)
+		if job.Error != nil {
+			message.Body += ": " + *job.Error
+		}
+	} else {
+		message.Title = "Import completed"
+		message.Body = fmt.Sprintf("%s imported from %s", pluralize(job.ImportedCount, "file"), job.SourcePath)
+		if job.SkippedCount > 0 {
+			message.Body += fmt.Sprintf(", %d skipped", job.SkippedCount)
+		}
+		message.Body += "."
+	}
+
+	notifyUser(db, job.UserID, message)
+}
+
+// ShareCreated notifies the owner of a share that it has been created
+func ShareCreated(token *models.ShareToken) {
+	db := getDB()
+	if db == nil {
+		return
+	}
+
+	go func() {
+		subject, err := shareSubject(db, token)
+		if err != nil {
+			log.Warn(context.Background(), "Getting subject of new share", "error", err)
+			return
+		}
+
+		body := fmt.Sprintf("A share link of %s has been created.", subject)
+		if token.Expire != nil {
+			body += fmt.Sprintf(" It expires on %s.", token.Expire.Format("January 2, 2006"))
+		}
+
+		notifyUser(db, token.OwnerID, &Message{
+			Event: models.NotificationEventNewShare,
+			Title: "New share link",
+			Body:  body,
+		})
+	}()
+}
+
+// shareSubject describes what has been shared, for use in a message
+func shareSubject(db *gorm.DB, token *models.ShareToken) (string, error) {
+	if token.AlbumID != nil {
+		var album models.Album
+		if err := db.Select("title").First(&album, *token.AlbumID).Error; err != nil {
+			return "", errors.Wrap(err, "get shared album")
+		}
+		return fmt.Sprintf("the album %q", album.Title), nil
+	}
+
+	if token.MediaID != nil {
+		var media models.Media
+		if err := db.Select("title").First(&media, *token.MediaID).Error; err != nil {
+			return "", errors.Wrap(err, "get shared media")
+		}
+		return fmt.Sprintf("%q", media.Title), nil
+	}
+
+	return "", errors.New("share is neither of an album nor of media")
+}
+
+func sendMemoriesDaily(db *gorm.DB) {
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), memoriesHour, 0, 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+
+		time.Sleep(time.Until(next))
+
+		// Only one of the instances sharing the database sends the reminders of the day
+		claimed, err := cluster.TryAcquire(db, "memories", 12*time.Hour)
+		if err != nil {
+			log.Warn(context.Background(), "Claiming memories reminders", "error", err)
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := SendMemories(db, time.Now()); err != nil {
+			log.Warn(context.Background(), "Sending memories reminders", "error", err)
+		}
+	}
+}
+
+// SendMemories reminds users of the media they took on the same day as the given date in previous years
+func SendMemories(db *gorm.DB, date time.Time) error {
+	userIDs, err := subscribedUsers(db, models.NotificationEventMemories)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		var years []struct {
+			Year  int
+			Count int
+		}
+
+		yearColumn := database.DateExtract(db, database.DateCompYear, "media.date_shot")
+		err := db.Model(&models.Media{}).
+			Select(yearColumn+" AS year, COUNT(*) AS count").
+			Joins("JOIN user_albums ON user_albums.album_id = media.album_id").
+			Where("user_albums.user_id = ?", userID).
+			Where(database.DateExtract(db, database.DateCompMonth, "media.date_shot")+" = ?", int(date.Month())).
+			Where(database.DateExtract(db, database.DateCompDay, "media.date_shot")+" = ?", date.Day()).
+			Where(yearColumn+" < ?", date.Year()).
+			Group(yearColumn).
+			Scan(&years).Error
+		if err != nil {
+			return errors.Wrap(err, "get memories of user")
+		}
+
+		if len(years) == 0 {
+			continue
+		}
+
+		sort.Slice(years, func(i, j int) bool { return years[i].Year < years[j].Year })
+
+		total := 0
+		yearNames := make([]string, len(years))
+		for i, year := range years {
+			total += year.Count
+			yearNames[i] = strconv.Itoa(year.Year)
+		}
+
+		media := strconv.Itoa(total) + " photos and videos"
+		if total == 1 {
+			media = "1 photo or video"
+		}
+
+		notifyUser(db, userID, &Message{
+			Event: models.NotificationEventMemories,
+			Title: "On this day",
+			Body:  fmt.Sprintf("You have %s from this day in %s.", media, joinList(yearNames)),
+		})
+	}
+
+	return nil
+}
+
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return "1 " + noun
+	}
+
+	return strconv.Itoa(count) + " " + noun + "s"
+}
+
+// joinList joins words into a readable list, such as "2019, 2020 and 2021"
+func joinList(words []string) string {
+	if len(words) <= 1 {
+		return strings.Join(words, "")
+	}
+
+	return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+}
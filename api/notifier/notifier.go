@@ -0,0 +1,162 @@
+// Package notifier sends notifications to users through the channels they have configured,
+// such as email, Gotify, ntfy or a webhook, and keeps them in the notification center of the user.
+package notifier
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/graphql/notification"
+	"github.com/photoview/photoview/api/log"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Message is a notification, sent as is to every channel
+type Message struct {
+	Event models.NotificationEvent
+	Title string
+	Body  string
+}
+
+// sender delivers a message through a kind of channel
+type sender func(channel *models.NotificationChannel, message *Message) error
+
+var senders = map[models.NotificationChannelKind]sender{
+	models.NotificationChannelKindEmail:   sendEmail,
+	models.NotificationChannelKindGotify:  sendGotify,
+	models.NotificationChannelKindNtfy:    sendNtfy,
+	models.NotificationChannelKindWebhook: sendWebhook,
+}
+
+var notifierDB *gorm.DB
+var notifierLock = &sync.Mutex{}
+
+// InitializeNotifier enables sending notifications and starts the daily memories reminders,
+// until then events are discarded
+func InitializeNotifier(db *gorm.DB) {
+	notifierLock.Lock()
+	notifierDB = db
+	lastScanSummary = time.Now()
+	notifierLock.Unlock()
+
+	go sendMemoriesDaily(db)
+}
+
+func getDB() *gorm.DB {
+	notifierLock.Lock()
+	defer notifierLock.Unlock()
+
+	return notifierDB
+}
+
+// Send delivers a message through a single channel right away, and records the outcome
+func Send(db *gorm.DB, channel *models.NotificationChannel, message *Message) error {
+	send, found := senders[channel.Kind]
+	if !found {
+		return errors.Errorf("unsupported notification channel: %s", channel.Kind)
+	}
+
+	err := checkChannelOwner(db, channel)
+	if err == nil {
+		err = send(channel, message)
+	}
+
+	updates := map[string]interface{}{}
+	if err != nil {
+		updates["last_error"] = err.Error()
+	} else {
+		updates["last_delivery_at"] = time.Now()
+		updates["last_error"] = nil
+	}
+
+	if dbErr := db.Model(&models.NotificationChannel{}).Where("id = ?", channel.ID).Updates(updates).Error; dbErr != nil {
+		log.Warn(db.Statement.Context, "Recording notification delivery", "error", dbErr)
+	}
+
+	return err
+}
+
+// Events kept in the notification center of the user, others are only sent through the channels the user has configured
+var notificationCenterEvents = map[models.NotificationEvent]bool{
+	models.NotificationEventScanCompleted:   true,
+	models.NotificationEventErrorDigest:     true,
+	models.NotificationEventShareViewed:     true,
+	models.NotificationEventImportCompleted: true,
+}
+
+// notifyUser adds a message to the notification center of a user,
+// and sends it to the channels of the user subscribed to its event in the background
+func notifyUser(db *gorm.DB, userID int, message *Message) {
+	if notificationCenterEvents[message.Event] {
+		userNotification := models.UserNotification{
+			UserID: userID,
+			Event:  message.Event,
+			Title:  message.Title,
+			Body:   message.Body,
+		}
+
+		if err := db.Create(&userNotification).Error; err != nil {
+			log.Warn(db.Statement.Context, "Adding notification to notification center", "error", err)
+		} else {
+			notification.PublishUserNotification(&userNotification)
+		}
+	}
+
+	var channels []*models.NotificationChannel
+	if err := db.Where("user_id = ?", userID).Find(&channels).Error; err != nil {
+		log.Warn(db.Statement.Context, "Getting notification channels from database", "error", err)
+		return
+	}
+
+	for _, channel := range channels {
+		if !channel.SubscribedTo(message.Event) {
+			continue
+		}
+
+		go func(channel *models.NotificationChannel) {
+			if err := Send(db, channel, message); err != nil {
+				log.Warn(context.Background(), "Sending notification", "event", message.Event, "channel_id", channel.ID, "error", err)
+			}
+		}(channel)
+	}
+}
+
+// subscribedUsers returns the ids of the users with a channel subscribed to the event
+func subscribedUsers(db *gorm.DB, event models.NotificationEvent) ([]int, error) {
+	var channels []*models.NotificationChannel
+	if err := db.Where("events LIKE ?", "%"+event.String()+"%").Find(&channels).Error; err != nil {
+		return nil, errors.Wrap(err, "get notification channels from database")
+	}
+
+	userIDs := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, channel := range channels {
+		if channel.SubscribedTo(event) && !seen[channel.UserID] {
+			seen[channel.UserID] = true
+			userIDs = append(userIDs, channel.UserID)
+		}
+	}
+
+	return userIDs, nil
+}
+
+// checkChannelOwner fails for channels only admins can use, whose owner is no longer an admin
+func checkChannelOwner(db *gorm.DB, channel *models.NotificationChannel) error {
+	if !RequiresAdmin(channel.Kind) {
+		return nil
+	}
+
+	var owner models.User
+	if err := db.Select("id", "admin").First(&owner, channel.UserID).Error; err != nil {
+		return errors.Wrap(err, "get owner of notification channel")
+	}
+
+	if !owner.Admin {
+		return errors.Errorf("only admins can use %s notification channels", channel.Kind)
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package notifier_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/graphql/notification"
+	"github.com/photoview/photoview/api/notifier"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+type receivedRequest struct {
+	path    string
+	headers http.Header
+	body    string
+}
+
+func notificationServer(t *testing.T) (*httptest.Server, chan receivedRequest) {
+	requests := make(chan receivedRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- receivedRequest{path: r.URL.Path, headers: r.Header, body: string(body)}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+func TestSend(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	server, requests := notificationServer(t)
+
+	user, err := models.RegisterUser(db, "user", nil, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	token := "secret-token"
+	message := &notifier.Message{Event: models.NotificationEventNewShare, Title: "Título", Body: "body"}
+
+	t.Run("Gotify", func(t *testing.T) {
+		channel := models.NotificationChannel{UserID: user.ID, Kind: models.NotificationChannelKindGotify, Target: server.URL + "/", Token: &token}
+		assert.NoError(t, db.Create(&channel).Error)
+
+		if !assert.NoError(t, notifier.Send(db, &channel, message)) {
+			return
+		}
+
+		request := <-requests
+		assert.Equal(t, "/message", request.path)
+		assert.Equal(t, token, request.headers.Get("X-Gotify-Key"))
+
+		var payload map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(request.body), &payload))
+		assert.Equal(t, "Título", payload["title"])
+		assert.Equal(t, "body", payload["message"])
+
+		assert.NoError(t, db.First(&channel, channel.ID).Error)
+		assert.NotNil(t, channel.LastDeliveryAt)
+		assert.Nil(t, channel.LastError)
+	})
+
+	t.Run("Ntfy", func(t *testing.T) {
+		channel := models.NotificationChannel{UserID: user.ID, Kind: models.NotificationChannelKindNtfy, Target: server.URL + "/photos"}
+		assert.NoError(t, db.Create(&channel).Error)
+
+		if !assert.NoError(t, notifier.Send(db, &channel, message)) {
+			return
+		}
+
+		request := <-requests
+		assert.Equal(t, "/photos", request.path)
+		assert.Equal(t, "=?utf-8?q?T=C3=ADtulo?=", request.headers.Get("Title"))
+		assert.Equal(t, "body", request.body)
+	})
+
+	t.Run("Webhook", func(t *testing.T) {
+		channel := models.NotificationChannel{UserID: user.ID, Kind: models.NotificationChannelKindWebhook, Target: server.URL + "/hook"}
+		assert.NoError(t, db.Create(&channel).Error)
+
+		if !assert.NoError(t, notifier.Send(db, &channel, message)) {
+			return
+		}
+
+		request := <-requests
+		var payload map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(request.body), &payload))
+		assert.Equal(t, "NEW_SHARE", payload["event"])
+		assert.Equal(t, "Título", payload["title"])
+	})
+
+	t.Run("Failed delivery is recorded", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer failing.Close()
+
+		channel := models.NotificationChannel{UserID: user.ID, Kind: models.NotificationChannelKindWebhook, Target: failing.URL}
+		assert.NoError(t, db.Create(&channel).Error)
+
+		assert.Error(t, notifier.Send(db, &channel, message))
+
+		assert.NoError(t, db.First(&channel, channel.ID).Error)
+		assert.Nil(t, channel.LastDeliveryAt)
+		assert.NotNil(t, channel.LastError)
+	})
+
+	t.Run("Url channel of a user who is not an admin", func(t *testing.T) {
+		other, err := models.RegisterUser(db, "other", nil, false)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		channel := models.NotificationChannel{UserID: other.ID, Kind: models.NotificationChannelKindWebhook, Target: server.URL + "/internal"}
+		assert.NoError(t, db.Create(&channel).Error)
+
+		assert.Error(t, notifier.Send(db, &channel, message))
+
+		select {
+		case request := <-requests:
+			t.Errorf("unexpected request sent to %s", request.path)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
+
+func TestSendMemories(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	server, requests := notificationServer(t)
+
+	user, err := models.RegisterUser(db, "user", nil, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	assert.NoError(t, db.Save(&album).Error)
+	assert.NoError(t, db.Model(&user).Association("Albums").Append(&album))
+
+	today := time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC)
+	media := []models.Media{
+		{Title: "a.jpg", Path: "/photos/a.jpg", AlbumID: album.ID, DateShot: time.Date(2019, 6, 15, 12, 0, 0, 0, time.UTC)},
+		{Title: "b.jpg", Path: "/photos/b.jpg", AlbumID: album.ID, DateShot: time.Date(2021, 6, 15, 18, 0, 0, 0, time.UTC)},
+		{Title: "c.jpg", Path: "/photos/c.jpg", AlbumID: album.ID, DateShot: time.Date(2021, 6, 15, 19, 0, 0, 0, time.UTC)},
+		{Title: "d.jpg", Path: "/photos/d.jpg", AlbumID: album.ID, DateShot: time.Date(2021, 6, 16, 12, 0, 0, 0, time.UTC)},
+		{Title: "e.jpg", Path: "/photos/e.jpg", AlbumID: album.ID, DateShot: time.Date(2024, 6, 15, 8, 0, 0, 0, time.UTC)},
+	}
+	assert.NoError(t, db.Save(&media).Error)
+
+	channel := models.NotificationChannel{UserID: user.ID, Kind: models.NotificationChannelKindWebhook, Target: server.URL}
+	channel.SetEvents([]models.NotificationEvent{models.NotificationEventMemories})
+	assert.NoError(t, db.Create(&channel).Error)
+
+	// Not subscribed to memories, should not be notified
+	other := models.NotificationChannel{UserID: user.ID, Kind: models.NotificationChannelKindWebhook, Target: server.URL + "/other"}
+	other.SetEvents([]models.NotificationEvent{models.NotificationEventScanCompleted})
+	assert.NoError(t, db.Create(&other).Error)
+
+	if !assert.NoError(t, notifier.SendMemories(db, today)) {
+		return
+	}
+
+	select {
+	case request := <-requests:
+		assert.Equal(t, "/", request.path)
+
+		var payload map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(request.body), &payload))
+		assert.Equal(t, "MEMORIES", payload["event"])
+		assert.Equal(t, "You have 3 photos and videos from this day in 2019 and 2021.", payload["message"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("memories were not sent")
+	}
+
+	select {
+	case request := <-requests:
+		t.Errorf("unexpected notification sent to %s", request.path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestValidateChannel(t *testing.T) {
+	admin := &models.User{Admin: true}
+	user := &models.User{}
+
+	target, err := notifier.ValidateChannel(admin, models.NotificationChannelKindNtfy, "https://ntfy.sh/photos")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ntfy.sh/photos", target)
+	_, err = notifier.ValidateChannel(admin, models.NotificationChannelKindGotify, "gotify.local")
+	assert.Error(t, err)
+
+	// Channels sending requests to urls can only be added by admins
+	for _, kind := range []models.NotificationChannelKind{models.NotificationChannelKindGotify, models.NotificationChannelKindNtfy, models.NotificationChannelKindWebhook} {
+		_, err = notifier.ValidateChannel(user, kind, "http://192.168.1.1/")
+		assert.Error(t, err, kind)
+	}
+
+	t.Setenv("PHOTOVIEW_SMTP_HOST", "")
+	_, err = notifier.ValidateChannel(user, models.NotificationChannelKindEmail, "user@example.com")
+	assert.Error(t, err)
+
+	t.Setenv("PHOTOVIEW_SMTP_HOST", "smtp.example.com")
+	target, err = notifier.ValidateChannel(user, models.NotificationChannelKindEmail, "user@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "user@example.com", target)
+	_, err = notifier.ValidateChannel(user, models.NotificationChannelKindEmail, "not an address")
+	assert.Error(t, err)
+
+	// Display names are removed, as they are not part of the address mail is sent to
+	target, err = notifier.ValidateChannel(user, models.NotificationChannelKindEmail, `"Me" <me@example.com>`)
+	assert.NoError(t, err)
+	assert.Equal(t, "me@example.com", target)
+}
+
+func TestNotificationCenter(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	notifier.InitializeNotifier(db)
+
+	user, err := models.RegisterUser(db, "user", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	received := make(chan *models.UserNotification, 1)
+	listenerID := notification.RegisterUserNotificationListener(user, received)
+	defer notification.DeregisterUserNotificationListener(listenerID)
+
+	job := models.ImportJob{
+		UserID:        user.ID,
+		SourcePath:    "/imports/export.zip",
+		Status:        models.ImportStatusCompleted,
+		ImportedCount: 12,
+		SkippedCount:  2,
+	}
+	notifier.ImportCompleted(&job)
+
+	var stored []*models.UserNotification
+	assert.NoError(t, db.Where("user_id = ?", user.ID).Find(&stored).Error)
+	if !assert.Len(t, stored, 1) {
+		return
+	}
+	assert.Equal(t, models.NotificationEventImportCompleted, stored[0].Event)
+	assert.Equal(t, "12 files imported from /imports/export.zip, 2 skipped.", stored[0].Body)
+	assert.False(t, stored[0].Read())
+
+	select {
+	case published := <-received:
+		assert.Equal(t, stored[0].ID, published.ID)
+	case <-time.After(time.Second):
+		t.Error("notification was not published to listener")
+	}
+}
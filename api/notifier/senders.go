@@ -0,0 +1,196 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// RequiresAdmin returns whether only admins can use a kind of channel. Gotify, ntfy and webhook channels make the server
+// send requests to any url, including ones on its internal network, like webhooks which only admins can add.
+func RequiresAdmin(kind models.NotificationChannelKind) bool {
+	switch kind {
+	case models.NotificationChannelKindGotify, models.NotificationChannelKindNtfy, models.NotificationChannelKindWebhook:
+		return true
+	}
+
+	return false
+}
+
+// ValidateChannel checks that the user can use the kind of channel and that the target is valid for it.
+// Returns the target to store, which is the bare address of email targets with a display name.
+func ValidateChannel(user *models.User, kind models.NotificationChannelKind, target string) (string, error) {
+	if RequiresAdmin(kind) && !user.Admin {
+		return "", errors.Errorf("only admins can add %s notification channels", kind)
+	}
+
+	switch kind {
+	case models.NotificationChannelKindEmail:
+		if utils.EnvSMTPHost.GetValue() == "" {
+			return "", errors.Errorf("email notifications require %s to be set", utils.EnvSMTPHost.GetName())
+		}
+		parsed, err := mail.ParseAddress(target)
+		if err != nil {
+			return "", errors.New("target must be an email address")
+		}
+		return parsed.Address, nil
+	case models.NotificationChannelKindGotify, models.NotificationChannelKindNtfy, models.NotificationChannelKindWebhook:
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return "", errors.New("target must be an absolute http or https url")
+		}
+		return target, nil
+	}
+
+	return "", errors.Errorf("unsupported notification channel: %s", kind)
+}
+
+func sendEmail(channel *models.NotificationChannel, message *Message) error {
+	host := utils.EnvSMTPHost.GetValue()
+	if host == "" {
+		return errors.Errorf("%s is not set", utils.EnvSMTPHost.GetName())
+	}
+
+	port := utils.EnvSMTPPort.GetValue()
+	if port == "" {
+		port = "587"
+	}
+
+	from := utils.EnvSMTPFrom.GetValue()
+	if from == "" {
+		from = "photoview@" + host
+	}
+
+	var auth smtp.Auth
+	if username := utils.EnvSMTPUsername.GetValue(); username != "" {
+		auth = smtp.PlainAuth("", username, utils.EnvSMTPPassword.GetValue(), host)
+	}
+
+	// Channels added before targets were stored as bare addresses may have a display name
+	to, err := mail.ParseAddress(channel.Target)
+	if err != nil {
+		return errors.Wrap(err, "parse email address of channel")
+	}
+
+	body := strings.Join([]string{
+		"From: " + from,
+		"To: " + to.Address,
+		"Subject: " + mimeHeader(message.Title),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		strings.ReplaceAll(message.Body, "\n", "\r\n"),
+	}, "\r\n")
+
+	if err := smtp.SendMail(net.JoinHostPort(host, port), auth, from, []string{to.Address}, []byte(body)); err != nil {
+		return errors.Wrap(err, "send email")
+	}
+
+	return nil
+}
+
+// mimeHeader encodes a header value, so titles can contain any characters
+func mimeHeader(value string) string {
+	return mime.QEncoding.Encode("utf-8", value)
+}
+
+func sendGotify(channel *models.NotificationChannel, message *Message) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    message.Title,
+		"message":  message.Body,
+		"priority": 5,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(channel.Target, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if channel.Token != nil {
+		req.Header.Set("X-Gotify-Key", *channel.Token)
+	}
+
+	return doRequest(req)
+}
+
+func sendNtfy(channel *models.NotificationChannel, message *Message) error {
+	req, err := http.NewRequest(http.MethodPost, channel.Target, strings.NewReader(message.Body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Title", mimeHeader(message.Title))
+	req.Header.Set("Tags", "camera")
+	if channel.Token != nil {
+		req.Header.Set("Authorization", "Bearer "+*channel.Token)
+	}
+
+	return doRequest(req)
+}
+
+// webhookPayload is the JSON body posted to webhook channels
+type webhookPayload struct {
+	Event     models.NotificationEvent `json:"event"`
+	Timestamp time.Time                `json:"timestamp"`
+	Title     string                   `json:"title"`
+	Message   string                   `json:"message"`
+}
+
+func sendWebhook(channel *models.NotificationChannel, message *Message) error {
+	body, err := json.Marshal(webhookPayload{
+		Event:     message.Event,
+		Timestamp: time.Now().UTC(),
+		Title:     message.Title,
+		Message:   message.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, channel.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if channel.Token != nil {
+		req.Header.Set("Authorization", "Bearer "+*channel.Token)
+	}
+
+	return doRequest(req)
+}
+
+func doRequest(req *http.Request) error {
+	req.Header.Set("User-Agent", "Photoview-Notifier")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("notification service responded with status %s", res.Status)
+	}
+
+	return nil
+}
@@ -1,8 +1,11 @@
 package routes
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/photoview/photoview/api/graphql/auth"
 	"github.com/photoview/photoview/api/graphql/models"
@@ -12,7 +15,7 @@ import (
 )
 
 func authenticateMedia(media *models.Media, db *gorm.DB, r *http.Request) (success bool, responseMessage string, responseStatus int, errorMessage error) {
-	user := auth.UserFromContext(r.Context())
+	user := mediaRequestUser(db, r)
 
 	if user != nil {
 		var album models.Album
@@ -38,6 +41,17 @@ func authenticateMedia(media *models.Media, db *gorm.DB, r *http.Request) (succe
 	return true, "success", http.StatusAccepted, nil
 }
 
+// mediaRequestUser returns the user requesting media, logged in through the auth cookie,
+// authenticated with the bearer token of the REST API, whose responses link to the media,
+// or with HTTP basic auth, which feed readers also use for the images and enclosures of feeds.
+func mediaRequestUser(db *gorm.DB, r *http.Request) *models.User {
+	if user, err := restUser(r); err == nil {
+		return user
+	}
+
+	return requestUser(db, r)
+}
+
 func authenticateAlbum(album *models.Album, db *gorm.DB, r *http.Request) (success bool, responseMessage string, responseStatus int, errorMessage error) {
 	user := auth.UserFromContext(r.Context())
 
@@ -127,3 +141,88 @@ func shareTokenFromRequest(db *gorm.DB, r *http.Request, mediaID *int, albumID *
 
 	return true, "", 0, nil
 }
+
+// requestShareToken returns the share token media is requested through, nil if it is requested by a logged in user.
+// The share token must already have been authenticated.
+func requestShareToken(db *gorm.DB, r *http.Request) (*models.ShareToken, error) {
+	if auth.UserFromContext(r.Context()) != nil {
+		return nil, nil
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return nil, nil
+	}
+
+	var shareToken models.ShareToken
+	if err := db.Where("value = ?", token).First(&shareToken).Error; err != nil {
+		return nil, errors.Wrap(err, "get share token of request")
+	}
+
+	return &shareToken, nil
+}
+
+// shareStripsMetadata returns whether metadata is removed from the media of a request made through a share link
+func shareStripsMetadata(db *gorm.DB, r *http.Request) (bool, error) {
+	shareToken, err := requestShareToken(db, r)
+	if shareToken == nil || err != nil {
+		return false, err
+	}
+
+	return shareToken.StripsMetadata(), nil
+}
+
+// How long verified basic auth credentials are remembered
+const basicAuthCacheTTL = 5 * time.Minute
+
+type basicAuthCredential struct {
+	userID    int
+	expiresAt time.Time
+}
+
+var basicAuthCache = make(map[[sha256.Size]byte]basicAuthCredential)
+var basicAuthCacheLock = &sync.Mutex{}
+
+// requestUser returns the user logged in through the auth cookie, or authenticated with HTTP basic auth,
+// for clients such as feed readers and WebDAV clients that do not support cookies.
+func requestUser(db *gorm.DB, r *http.Request) *models.User {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return user
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil
+	}
+
+	// Verifying the password is slow by design, so verified credentials are remembered for a while,
+	// as these clients send them with every request
+	key := sha256.Sum256([]byte(username + ":" + password))
+
+	basicAuthCacheLock.Lock()
+	cached, found := basicAuthCache[key]
+	basicAuthCacheLock.Unlock()
+
+	if found && time.Now().Before(cached.expiresAt) {
+		var user models.User
+		if err := db.First(&user, cached.userID).Error; err == nil {
+			return &user
+		}
+	}
+
+	user, err := models.AuthorizeUser(db, username, password)
+	if err != nil {
+		return nil
+	}
+
+	basicAuthCacheLock.Lock()
+	for k, c := range basicAuthCache {
+		if time.Now().After(c.expiresAt) {
+			delete(basicAuthCache, k)
+		}
+	}
+	basicAuthCache[key] = basicAuthCredential{userID: user.ID, expiresAt: time.Now().Add(basicAuthCacheTTL)}
+	basicAuthCacheLock.Unlock()
+
+	return user
+}
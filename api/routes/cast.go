@@ -0,0 +1,229 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/photoview/photoview/api/utils"
+	"gorm.io/gorm"
+)
+
+// Content types that cast receivers can display natively
+var castableContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+	"video/mp4":  true,
+	"video/webm": true,
+}
+
+type castManifest struct {
+	Title  string     `json:"title"`
+	Expire time.Time  `json:"expire"`
+	Items  []castItem `json:"items"`
+}
+
+type castItem struct {
+	ID           int              `json:"id"`
+	Title        string           `json:"title"`
+	Type         models.MediaType `json:"type"`
+	URL          string           `json:"url"`
+	ContentType  string           `json:"contentType"`
+	Size         int64            `json:"size"`
+	Width        int              `json:"width"`
+	Height       int              `json:"height"`
+	ThumbnailURL string           `json:"thumbnailUrl,omitempty"`
+}
+
+// RegisterCastRoutes serves the media of cast sessions to cast receivers, such as Chromecasts and AirPlay devices.
+// The receivers are web pages hosted elsewhere that load the media without credentials.
+func RegisterCastRoutes(db *gorm.DB, router *mux.Router) {
+	router.Use(castCORSMiddleware)
+
+	router.HandleFunc("/{token}/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		session, ok := castSessionFromRequest(db, w, r)
+		if !ok {
+			return
+		}
+
+		manifest, err := buildCastManifest(db, r, session)
+		if err != nil {
+			log.Error(r.Context(), "Building cast manifest", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(manifest)
+	}).Methods(http.MethodGet, http.MethodHead)
+
+	router.HandleFunc("/{token}/media/{name}", func(w http.ResponseWriter, r *http.Request) {
+		session, ok := castSessionFromRequest(db, w, r)
+		if !ok {
+			return
+		}
+
+		var mediaURL models.MediaURL
+		result := db.Model(&models.MediaURL{}).Joins("Media").Select("media_urls.*").Where("media_urls.media_name = ?", mux.Vars(r)["name"]).Scan(&mediaURL)
+		if result.Error != nil || mediaURL.Media == nil || mediaURL.Media.AlbumID != session.AlbumID {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("404"))
+			return
+		}
+
+		media := mediaURL.Media
+
+		cachedPath, err := mediaURL.CachedPath()
+		if err != nil {
+			log.Error(r.Context(), "Serving cast media", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		inColdStorage, err := mediaInColdStorage(db, media)
+		if err != nil {
+			log.Error(r.Context(), "Serving cast media", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		if inColdStorage {
+			if cachedPath, ok = resolveColdStoragePath(db, w, r, &mediaURL, cachedPath); !ok {
+				return
+			}
+		}
+
+		if _, err := os.Stat(cachedPath); os.IsNotExist(err) {
+			if err := scanner.ProcessSingleMedia(db, media); err != nil {
+				log.Error(r.Context(), "Processing media for cast session", "path", media.Path, "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("internal server error"))
+				return
+			}
+		}
+
+		if err := storage.TouchMediaURL(db, &mediaURL); err != nil {
+			log.Warn(r.Context(), "Updating access time of media url", "error", err)
+		}
+
+		if mediaURL.ContentType != "" {
+			w.Header().Set("Content-Type", mediaURL.ContentType)
+		}
+		w.Header().Set("Cache-Control", "private, max-age=86400, immutable")
+
+		serveMediaFile(w, r, mediaURL.ContentType, cachedPath)
+	}).Methods(http.MethodGet, http.MethodHead)
+}
+
+// castCORSMiddleware allows receivers on any origin to load the media, as no credentials are involved
+func castCORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD")
+		w.Header().Set("Access-Control-Allow-Headers", "Range")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges")
+		w.Header().Del("Access-Control-Allow-Credentials")
+		w.Header().Del("Vary")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// castSessionFromRequest returns the unexpired cast session of the token in the url.
+// If the returned bool is false, a response has already been written.
+func castSessionFromRequest(db *gorm.DB, w http.ResponseWriter, r *http.Request) (*models.CastSession, bool) {
+	var session models.CastSession
+	err := db.Where("token = ?", mux.Vars(r)["token"]).Where("expire > ?", time.Now()).First(&session).Error
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("cast session not found or expired"))
+		return nil, false
+	}
+
+	return &session, true
+}
+
+// buildCastManifest lists the media of the album of a session, each with the largest rendition receivers can display
+func buildCastManifest(db *gorm.DB, r *http.Request, session *models.CastSession) (*castManifest, error) {
+	var album models.Album
+	if err := db.First(&album, session.AlbumID).Error; err != nil {
+		return nil, err
+	}
+
+	inColdStorage, err := album.InColdStorage(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var media []*models.Media
+	if err := db.Where("album_id = ?", album.ID).Order("date_shot, title").Preload("MediaURL").Find(&media).Error; err != nil {
+		return nil, err
+	}
+
+	manifest := castManifest{
+		Title:  album.Title,
+		Expire: session.Expire,
+		Items:  make([]castItem, 0, len(media)),
+	}
+
+	castURL := func(mediaURL *models.MediaURL) string {
+		fileURL := utils.ApiEndpointUrl()
+		fileURL.Path = path.Join(fileURL.Path, "cast", session.Token, "media", mediaURL.MediaName)
+		return absoluteURL(r, fileURL.String())
+	}
+
+	for _, m := range media {
+		var rendition, thumbnail *models.MediaURL
+		for i := range m.MediaURL {
+			mediaURL := &m.MediaURL[i]
+
+			switch mediaURL.Purpose {
+			case models.PhotoThumbnail, models.VideoThumbnail:
+				thumbnail = mediaURL
+			case models.PhotoHighRes, models.VideoWeb:
+				rendition = mediaURL
+			case models.MediaOriginal:
+				// Originals are only used when no rendition has been made, as the file was already suitable
+				if rendition == nil && !inColdStorage && castableContentTypes[mediaURL.ContentType] {
+					rendition = mediaURL
+				}
+			}
+		}
+
+		if rendition == nil {
+			continue
+		}
+
+		item := castItem{
+			ID:          m.ID,
+			Title:       m.Title,
+			Type:        m.Type,
+			URL:         castURL(rendition),
+			ContentType: rendition.ContentType,
+			Size:        rendition.FileSize,
+			Width:       rendition.Width,
+			Height:      rendition.Height,
+		}
+
+		if thumbnail != nil {
+			item.ThumbnailURL = castURL(thumbnail)
+		}
+
+		manifest.Items = append(manifest.Items, item)
+	}
+
+	return &manifest, nil
+}
@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCastRoutes(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	user, err := models.RegisterUser(db, "username", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{Title: "Holiday", Path: t.TempDir()}
+	otherAlbum := models.Album{Title: "Other", Path: t.TempDir()}
+	if !assert.NoError(t, db.Create(&[]*models.Album{&album, &otherAlbum}).Error) {
+		return
+	}
+
+	beachPath := path.Join(album.Path, "beach.jpg")
+	if !assert.NoError(t, os.WriteFile(beachPath, []byte("IMAGE DATA"), 0644)) {
+		return
+	}
+
+	media := []models.Media{
+		{Title: "beach.jpg", Path: beachPath, AlbumID: album.ID, Type: models.MediaTypePhoto},
+		{Title: "raw.cr2", Path: path.Join(album.Path, "raw.cr2"), AlbumID: album.ID, Type: models.MediaTypePhoto},
+		{Title: "secret.jpg", Path: path.Join(otherAlbum.Path, "secret.jpg"), AlbumID: otherAlbum.ID, Type: models.MediaTypePhoto},
+	}
+	if !assert.NoError(t, db.Create(&media).Error) {
+		return
+	}
+
+	mediaURLs := []models.MediaURL{
+		{MediaID: media[0].ID, MediaName: "beach.jpg", Purpose: models.MediaOriginal, ContentType: "image/jpeg", FileSize: 10, Width: 800, Height: 600},
+		{MediaID: media[1].ID, MediaName: "raw.cr2", Purpose: models.MediaOriginal, ContentType: "image/x-canon-cr2"},
+		{MediaID: media[2].ID, MediaName: "secret.jpg", Purpose: models.MediaOriginal, ContentType: "image/jpeg"},
+	}
+	if !assert.NoError(t, db.Create(&mediaURLs).Error) {
+		return
+	}
+
+	sessions := []models.CastSession{
+		{Token: "validtoken", UserID: user.ID, AlbumID: album.ID, Expire: time.Now().Add(time.Hour)},
+		{Token: "expiredtoken", UserID: user.ID, AlbumID: album.ID, Expire: time.Now().Add(-time.Hour)},
+	}
+	if !assert.NoError(t, db.Create(&sessions).Error) {
+		return
+	}
+
+	router := mux.NewRouter()
+	RegisterCastRoutes(db, router.PathPrefix("/cast").Subrouter())
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		return rr
+	}
+
+	t.Run("Manifest", func(t *testing.T) {
+		rr := get("/cast/validtoken/manifest.json")
+		if !assert.Equal(t, http.StatusOK, rr.Code) {
+			return
+		}
+		assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+
+		var manifest castManifest
+		if !assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &manifest)) {
+			return
+		}
+
+		assert.Equal(t, "Holiday", manifest.Title)
+		if assert.Len(t, manifest.Items, 1, "expected media without a castable rendition to be left out") {
+			assert.Equal(t, "http://example.com/cast/validtoken/media/beach.jpg", manifest.Items[0].URL)
+			assert.Equal(t, "image/jpeg", manifest.Items[0].ContentType)
+			assert.Equal(t, 800, manifest.Items[0].Width)
+		}
+	})
+
+	t.Run("Media", func(t *testing.T) {
+		rr := get("/cast/validtoken/media/beach.jpg")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "IMAGE DATA", rr.Body.String())
+		assert.Equal(t, "image/jpeg", rr.Header().Get("Content-Type"))
+	})
+
+	t.Run("Media of other album", func(t *testing.T) {
+		rr := get("/cast/validtoken/media/secret.jpg")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Expired session", func(t *testing.T) {
+		rr := get("/cast/expiredtoken/manifest.json")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
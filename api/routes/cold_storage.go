@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/storage"
+	"gorm.io/gorm"
+)
+
+// Media currently being processed in the background, because its cached previews were missing
+var coldProcessing = make(map[int]bool)
+var coldProcessingLock = &sync.Mutex{}
+
+func mediaInColdStorage(db *gorm.DB, media *models.Media) (bool, error) {
+	var album models.Album
+	if err := db.First(&album, media.AlbumID).Error; err != nil {
+		return false, err
+	}
+
+	return album.InColdStorage(db)
+}
+
+// resolveColdStoragePath finds the file to serve for a media url located in cold storage, without blocking on the storage.
+// Originals are served once they have been retrieved to the cache, otherwise a retrieval is queued.
+// Missing previews are regenerated in the background.
+// If the returned bool is false, a response has already been written.
+func resolveColdStoragePath(db *gorm.DB, w http.ResponseWriter, r *http.Request, mediaURL *models.MediaURL, cachedPath string) (string, bool) {
+	media := mediaURL.Media
+
+	if mediaURL.Purpose == models.MediaOriginal {
+		retrieval, err := storage.RequestRetrieval(db, media)
+		if err != nil {
+			log.Error(r.Context(), "Requesting retrieval from cold storage", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return "", false
+		}
+
+		if retrieval.Status != models.RetrievalStatusCompleted {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("retrieving media from cold storage, status: " + retrieval.Status.String()))
+			return "", false
+		}
+
+		retrievedPath, err := storage.RetrievedPath(media)
+		if err != nil {
+			log.Error(r.Context(), "Serving media from cold storage", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return "", false
+		}
+
+		return retrievedPath, true
+	}
+
+	if _, err := os.Stat(cachedPath); os.IsNotExist(err) {
+		coldProcessingLock.Lock()
+		if !coldProcessing[media.ID] {
+			coldProcessing[media.ID] = true
+
+			ctx := log.Detach(r.Context())
+			go func() {
+				if err := scanner.ProcessSingleMedia(db, media); err != nil {
+					log.Error(ctx, "Processing media from cold storage", "path", media.Path, "error", err)
+				}
+
+				coldProcessingLock.Lock()
+				delete(coldProcessing, media.ID)
+				coldProcessingLock.Unlock()
+			}()
+		}
+		coldProcessingLock.Unlock()
+
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("media is being processed from cold storage"))
+		return "", false
+	}
+
+	return cachedPath, true
+}
+
+// coldStorageDownloadReady requests retrieval of all originals in the list of media urls,
+// and returns true if all of them have been retrieved to the cache.
+func coldStorageDownloadReady(db *gorm.DB, mediaURLs []*models.MediaURL) (bool, error) {
+	ready := true
+
+	for _, mediaURL := range mediaURLs {
+		if mediaURL.Purpose != models.MediaOriginal {
+			continue
+		}
+
+		retrieval, err := storage.RequestRetrieval(db, mediaURL.Media)
+		if err != nil {
+			return false, err
+		}
+
+		if retrieval.Status != models.RetrievalStatusCompleted {
+			ready = false
+		}
+	}
+
+	return ready, nil
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Status code used by the tus checksum extension, when the uploaded content does not match the checksum
+const statusChecksumMismatch = 460
+
+// authenticateDeviceUpload makes sure the device belongs to the logged in user, and returns the album its media is backed up to
+func authenticateDeviceUpload(db *gorm.DB, r *http.Request, deviceID string) (*models.Device, *models.Album, int, error) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return nil, nil, http.StatusUnauthorized, auth.ErrUnauthorized
+	}
+
+	var device models.Device
+	if err := db.Where("id = ? AND user_id = ?", deviceID, user.ID).First(&device).Error; err != nil {
+		return nil, nil, http.StatusNotFound, errors.New("device not found")
+	}
+
+	album, err := device.EnsureAlbum(db)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+
+	return &device, album, http.StatusOK, nil
+}
+
+// deviceAssetMedia returns the id of the media, if a file with the checksum has already been backed up by a device of the same user
+func deviceAssetMedia(db *gorm.DB, device *models.Device, checksum string) (*int, error) {
+	var assets []*models.DeviceAsset
+	err := db.Joins("JOIN devices ON devices.id = device_assets.device_id").
+		Where("devices.user_id = ?", device.UserID).
+		Where("device_assets.checksum = ?", checksum).
+		Limit(1).Find(&assets).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(assets) == 0 {
+		return nil, nil
+	}
+
+	mediaID := 0
+	if assets[0].MediaID != nil {
+		mediaID = *assets[0].MediaID
+	}
+
+	return &mediaID, nil
+}
+
+// verifyUploadChecksum compares the SHA-1 checksum of a completed upload with the one provided by the client
+func verifyUploadChecksum(session *models.UploadSession) error {
+	if session.Checksum == nil {
+		return nil
+	}
+
+	file, err := os.Open(session.PartialPath())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+
+	if checksum := hex.EncodeToString(hash.Sum(nil)); checksum != *session.Checksum {
+		return errors.Errorf("expected checksum %s, got %s", *session.Checksum, checksum)
+	}
+
+	return nil
+}
+
+func recordDeviceAsset(db *gorm.DB, session *models.UploadSession, media *models.Media) error {
+	if err := db.Model(&models.Device{}).Where("id = ?", *session.DeviceID).Update("last_backup_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	if session.Checksum == nil {
+		return nil
+	}
+
+	asset := models.DeviceAsset{
+		DeviceID: *session.DeviceID,
+		Checksum: *session.Checksum,
+		MediaID:  &media.ID,
+	}
+
+	return db.Create(&asset).Error
+}
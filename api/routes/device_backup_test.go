@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceBackupUpload(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	user, err := models.RegisterUser(db, "username", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootAlbum := models.Album{
+		Title: "root",
+		Path:  t.TempDir(),
+	}
+
+	if !assert.NoError(t, db.Model(&user).Association("Albums").Append(&rootAlbum)) {
+		return
+	}
+
+	device := models.Device{
+		Name:   "phone",
+		UserID: user.ID,
+		Path:   path.Join(rootAlbum.Path, "phone"),
+	}
+
+	if !assert.NoError(t, db.Create(&device).Error) {
+		return
+	}
+
+	router := mux.NewRouter()
+	RegisterUploadRoutes(db, router.PathPrefix("/upload").Subrouter())
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		req = req.WithContext(auth.AddUserToContext(req.Context(), user))
+		req.Header.Set("Tus-Resumable", tusVersion)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	upload := func(content []byte, checksum string) *httptest.ResponseRecorder {
+		metadata := fmt.Sprintf("filename %s,deviceId %s,checksum %s",
+			base64.StdEncoding.EncodeToString([]byte("IMG_0001.jpg")),
+			base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(device.ID))),
+			base64.StdEncoding.EncodeToString([]byte(checksum)))
+
+		req := httptest.NewRequest("POST", "/upload/files", nil)
+		req.Header.Set("Upload-Length", fmt.Sprint(len(content)))
+		req.Header.Set("Upload-Metadata", metadata)
+		rec := serve(req)
+		if rec.Code != http.StatusCreated {
+			return rec
+		}
+
+		req = httptest.NewRequest("PATCH", rec.Header().Get("Location"), bytes.NewReader(content))
+		req.Header.Set("Content-Type", "application/offset+octet-stream")
+		req.Header.Set("Upload-Offset", "0")
+		return serve(req)
+	}
+
+	content := []byte("IMAGE DATA")
+	hash := sha1.Sum(content)
+	checksum := hex.EncodeToString(hash[:])
+
+	t.Run("Checksum mismatch", func(t *testing.T) {
+		rec := upload([]byte("CORRUPT DATA"), checksum)
+		assert.Equal(t, statusChecksumMismatch, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("Upload-Offset"))
+	})
+
+	t.Run("Backup new file", func(t *testing.T) {
+		rec := upload(content, checksum)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("Photoview-Media-Id"))
+		assert.FileExists(t, path.Join(device.Path, "IMG_0001.jpg"))
+
+		album, err := device.Album(db)
+		assert.NoError(t, err)
+		if assert.NotNil(t, album) {
+			assert.Equal(t, rootAlbum.ID, *album.ParentAlbumID)
+		}
+
+		backedUp, err := models.BackedUpChecksums(db, user.ID, []string{checksum, "unknown"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{checksum}, backedUp)
+	})
+
+	t.Run("Skip file already backed up", func(t *testing.T) {
+		rec := upload(content, checksum)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("Photoview-Media-Id"))
+	})
+}
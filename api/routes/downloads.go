@@ -4,14 +4,17 @@ import (
 	"archive/zip"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/photoview/photoview/api/database/drivers"
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/storage"
 	"gorm.io/gorm"
 )
 
@@ -30,7 +33,7 @@ func RegisterDownloadRoutes(db *gorm.DB, router *mux.Router) {
 
 		if success, response, status, err := authenticateAlbum(&album, db, r); !success {
 			if err != nil {
-				log.Printf("WARN: error authenticating album for download: %v\n", err)
+				log.Warn(r.Context(), "Error authenticating album for download", "error", err)
 			}
 			w.WriteHeader(status)
 			w.Write([]byte(response))
@@ -57,31 +60,134 @@ func RegisterDownloadRoutes(db *gorm.DB, router *mux.Router) {
 			return
 		}
 
+		inColdStorage, err := album.InColdStorage(db)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		if inColdStorage {
+			if ready, err := coldStorageDownloadReady(db, mediaURLs); err != nil {
+				log.Error(r.Context(), "Failed to request retrieval from cold storage, when downloading album", "album_id", album.ID, "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("internal server error"))
+				return
+			} else if !ready {
+				w.Header().Set("Retry-After", "60")
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte("retrieving media from cold storage"))
+				return
+			}
+		}
+
+		// Photos downloaded through share links are watermarked, if the owner of the album has enabled a watermark
+		watermark, err := shareWatermark(db, r)
+		if err != nil {
+			log.Error(r.Context(), "Getting watermark of shared album, when downloading album", "album_id", album.ID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		stripping, err := shareStripsMetadata(db, r)
+		if err != nil {
+			log.Error(r.Context(), "Getting metadata option of shared album, when downloading album", "album_id", album.ID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/zip")
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", album.Title))
 
 		zipWriter := zip.NewWriter(w)
 
 		for _, media := range mediaURLs {
-			zipFile, err := zipWriter.Create(fmt.Sprintf("%s/%s", album.Title, media.MediaName))
+			watermarked := watermark.appliesTo(media.Media, media.ContentType)
+
+			// Originals that can't be decoded to draw the watermark over are replaced by their web versions, unless they are downloaded as well
+			if watermarked {
+				replacement, err := watermarkableMediaURL(db, media)
+				if err != nil {
+					log.Error(r.Context(), "Failed to get web version of photo, when downloading album", "album_id", album.ID, "error", err)
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte("internal server error"))
+					return
+				}
+				if replacement == nil || (replacement != media && purposeListed(mediaPurposeList, replacement.Purpose)) {
+					continue
+				}
+				media = replacement
+			}
+
+			// Originals metadata can't be removed from are replaced by their web versions, unless they are downloaded as well
+			stripped := stripping && !watermarked && media.Media.Type == models.MediaTypePhoto && media.ContentType != string(media_type.TypeSvg)
+			if stripped {
+				replacement, err := strippableMediaURL(db, media)
+				if err != nil {
+					log.Error(r.Context(), "Failed to get web version of photo, when downloading album", "album_id", album.ID, "error", err)
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte("internal server error"))
+					return
+				}
+				if replacement == nil || (replacement != media && purposeListed(mediaPurposeList, replacement.Purpose)) {
+					continue
+				}
+				media = replacement
+			}
+
+			fileName := media.MediaName
+			if watermarked {
+				fileName = strings.TrimSuffix(fileName, path.Ext(fileName)) + ".jpg"
+			}
+
+			zipFile, err := zipWriter.Create(fmt.Sprintf("%s/%s", album.Title, fileName))
 			if err != nil {
-				log.Printf("ERROR: Failed to create a file in zip, when downloading album (%d): %v\n", album.ID, err)
+				log.Error(r.Context(), "Failed to create a file in zip, when downloading album", "album_id", album.ID, "error", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte("internal server error"))
 				return
 			}
 
 			filePath, err := media.CachedPath()
+			if err == nil && inColdStorage && media.Purpose == models.MediaOriginal {
+				filePath, err = storage.RetrievedPath(media.Media)
+			}
 			if err != nil {
-				log.Printf("ERROR: Failed to get mediaURL cache path, when downloading album (%d): %v\n", album.ID, err)
+				log.Error(r.Context(), "Failed to get mediaURL cache path, when downloading album", "album_id", album.ID, "error", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte("internal server error"))
 				return
 			}
 
+			if watermarked {
+				if err := writeWatermarkedPhoto(zipFile, watermark, filePath, nil); err != nil {
+					log.Error(r.Context(), "Failed to watermark photo, when downloading album", "album_id", album.ID, "path", filePath, "error", err)
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte("internal server error"))
+					return
+				}
+				continue
+			}
+
+			if stripped && canStripMetadata(media.ContentType) {
+				data, err := os.ReadFile(filePath)
+				if err == nil {
+					err = stripMetadata(zipFile, data, media.ContentType)
+				}
+				if err != nil {
+					log.Error(r.Context(), "Failed to strip metadata of photo, when downloading album", "album_id", album.ID, "path", filePath, "error", err)
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte("internal server error"))
+					return
+				}
+				continue
+			}
+
 			fileData, err := os.Open(filePath)
 			if err != nil {
-				log.Printf("ERROR: Failed to open file to include in zip, when downloading album (%d): %v\n", album.ID, err)
+				log.Error(r.Context(), "Failed to open file to include in zip, when downloading album", "album_id", album.ID, "error", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte("internal server error"))
 				return
@@ -89,14 +195,14 @@ func RegisterDownloadRoutes(db *gorm.DB, router *mux.Router) {
 
 			_, err = io.Copy(zipFile, fileData)
 			if err != nil {
-				log.Printf("ERROR: Failed to copy file data, when downloading album (%d): %v\n", album.ID, err)
+				log.Error(r.Context(), "Failed to copy file data, when downloading album", "album_id", album.ID, "error", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte("internal server error"))
 				return
 			}
 
 			if err := fileData.Close(); err != nil {
-				log.Printf("ERROR: Failed to close file, when downloading album (%d): %v\n", album.ID, err)
+				log.Error(r.Context(), "Failed to close file, when downloading album", "album_id", album.ID, "error", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte("internal server error"))
 				return
@@ -107,3 +213,13 @@ func RegisterDownloadRoutes(db *gorm.DB, router *mux.Router) {
 		zipWriter.Close()
 	})
 }
+
+func purposeListed(purposes []string, purpose models.MediaPurpose) bool {
+	for _, listed := range purposes {
+		if listed == string(purpose) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,442 @@
+package routes
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Maximum number of media included in a feed
+const feedItemLimit = 50
+
+type feed struct {
+	title   string
+	link    string
+	feedURL string
+	updated time.Time
+	items   []feedItem
+}
+
+type feedItem struct {
+	id          string
+	title       string
+	link        string
+	imageURL    string
+	contentType string
+	size        int64
+	published   time.Time
+}
+
+// RegisterFeedRoutes registers RSS, Atom and JSON feeds of the most recently added media,
+// either of an album and its sub albums, or of the entire library of a user.
+// Album feeds can be accessed using a share token, feed readers can otherwise authenticate with HTTP basic auth.
+func RegisterFeedRoutes(db *gorm.DB, router *mux.Router) {
+	router.HandleFunc("/album/{album_id}/{format:rss|atom|json}", func(w http.ResponseWriter, r *http.Request) {
+		var album models.Album
+		if err := db.First(&album, "id = ?", mux.Vars(r)["album_id"]).Error; err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("404"))
+			return
+		}
+
+		shareToken := r.URL.Query().Get("token")
+		if shareToken == "" {
+			user := requestUser(db, r)
+			if user == nil {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Photoview"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("unauthorized"))
+				return
+			}
+			r = r.WithContext(auth.AddUserToContext(r.Context(), user))
+		}
+
+		if success, response, status, err := authenticateAlbum(&album, db, r); !success {
+			if err != nil {
+				log.Warn(r.Context(), "Error authenticating album feed", "error", err)
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(response))
+			return
+		}
+
+		albums, err := album.GetChildren(db, nil)
+		if err != nil {
+			log.Error(r.Context(), "Getting sub albums for feed", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		link := path.Join("album", strconv.Itoa(album.ID))
+		if shareToken != "" {
+			link = path.Join("share", shareToken)
+		}
+
+		f := feed{
+			title: album.Title,
+			link:  uiURL(r, link),
+		}
+
+		if err := fillFeed(db, r, &f, albums, shareToken); err != nil {
+			log.Error(r.Context(), "Generating album feed", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		writeFeed(w, r, &f, mux.Vars(r)["format"])
+	}).Methods(http.MethodGet)
+
+	router.HandleFunc("/library/{format:rss|atom|json}", func(w http.ResponseWriter, r *http.Request) {
+		user := requestUser(db, r)
+		if user == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Photoview"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("unauthorized"))
+			return
+		}
+
+		if err := user.FillAlbums(db); err != nil {
+			log.Error(r.Context(), "Getting library feed", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		albums := make([]*models.Album, len(user.Albums))
+		for i := range user.Albums {
+			albums[i] = &user.Albums[i]
+		}
+
+		f := feed{
+			title: fmt.Sprintf("Photoview - %s", user.Username),
+			link:  uiURL(r, "timeline"),
+		}
+
+		if err := fillFeed(db, r, &f, albums, ""); err != nil {
+			log.Error(r.Context(), "Generating library feed", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		writeFeed(w, r, &f, mux.Vars(r)["format"])
+	}).Methods(http.MethodGet)
+}
+
+// fillFeed adds the most recently added media of the given albums to the feed
+func fillFeed(db *gorm.DB, r *http.Request, f *feed, albums []*models.Album, shareToken string) error {
+	albumIDs := make([]int, len(albums))
+	for i, album := range albums {
+		albumIDs[i] = album.ID
+	}
+
+	var media []*models.Media
+	err := db.Where("album_id IN (?)", albumIDs).
+		Order("created_at DESC, id DESC").
+		Limit(feedItemLimit).
+		Find(&media).Error
+	if err != nil {
+		return errors.Wrap(err, "get recently added media")
+	}
+
+	mediaIDs := make([]int, len(media))
+	for i, m := range media {
+		mediaIDs[i] = m.ID
+	}
+
+	var mediaURLs []*models.MediaURL
+	err = db.Where("media_id IN (?)", mediaIDs).
+		Where("purpose IN (?)", []models.MediaPurpose{models.PhotoHighRes, models.PhotoThumbnail, models.VideoThumbnail}).
+		Find(&mediaURLs).Error
+	if err != nil {
+		return errors.Wrap(err, "get media urls")
+	}
+
+	// Prefer the high resolution image over the thumbnail
+	images := make(map[int]*models.MediaURL)
+	for _, mediaURL := range mediaURLs {
+		if existing, found := images[mediaURL.MediaID]; !found || existing.Purpose != models.PhotoHighRes {
+			images[mediaURL.MediaID] = mediaURL
+		}
+	}
+
+	f.feedURL = absoluteURL(r, r.URL.RequestURI())
+	f.items = make([]feedItem, 0, len(media))
+
+	for _, m := range media {
+		if m.CreatedAt.After(f.updated) {
+			f.updated = m.CreatedAt
+		}
+
+		item := feedItem{
+			id:        fmt.Sprintf("photoview-media-%d", m.ID),
+			title:     m.Title,
+			link:      f.link,
+			published: m.CreatedAt,
+		}
+
+		if image, found := images[m.ID]; found {
+			imageURL, err := url.Parse(absoluteURL(r, image.URL()))
+			if err != nil {
+				return errors.Wrap(err, "parse media url")
+			}
+
+			if shareToken != "" {
+				query := imageURL.Query()
+				query.Set("token", shareToken)
+				imageURL.RawQuery = query.Encode()
+			}
+
+			item.imageURL = imageURL.String()
+			item.contentType = image.ContentType
+			item.size = image.FileSize
+		}
+
+		f.items = append(f.items, item)
+	}
+
+	return nil
+}
+
+func writeFeed(w http.ResponseWriter, r *http.Request, f *feed, format string) {
+	var contentType string
+	var body []byte
+	var err error
+
+	switch format {
+	case "rss":
+		contentType = "application/rss+xml; charset=utf-8"
+		body, err = encodeRSS(f)
+	case "atom":
+		contentType = "application/atom+xml; charset=utf-8"
+		body, err = encodeAtom(f)
+	default:
+		contentType = "application/feed+json; charset=utf-8"
+		body, err = encodeJSONFeed(f)
+	}
+
+	if err != nil {
+		log.Error(r.Context(), "Encoding feed", "format", format, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Write(body)
+}
+
+func (item *feedItem) contentHTML() string {
+	if item.imageURL == "" {
+		return html.EscapeString(item.title)
+	}
+
+	return fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(item.imageURL), html.EscapeString(item.title))
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+func encodeRSS(f *feed) ([]byte, error) {
+	channel := rssChannel{
+		Title:       f.title,
+		Link:        f.link,
+		Description: fmt.Sprintf("Recently added media in %s", f.title),
+		Items:       make([]rssItem, len(f.items)),
+	}
+
+	if !f.updated.IsZero() {
+		channel.LastBuildDate = f.updated.Format(time.RFC1123Z)
+	}
+
+	for i, item := range f.items {
+		channel.Items[i] = rssItem{
+			Title:       item.title,
+			Link:        item.link,
+			Description: item.contentHTML(),
+			GUID:        rssGUID{Value: item.id},
+			PubDate:     item.published.Format(time.RFC1123Z),
+		}
+
+		if item.imageURL != "" {
+			channel.Items[i].Enclosure = &rssEnclosure{URL: item.imageURL, Length: item.size, Type: item.contentType}
+		}
+	}
+
+	body, err := xml.MarshalIndent(rssFeed{Version: "2.0", Channel: channel}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Links     []atomLink  `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func encodeAtom(f *feed) ([]byte, error) {
+	updated := f.updated
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	atom := atomFeed{
+		ID:      f.feedURL,
+		Title:   f.title,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.link, Rel: "alternate"},
+			{Href: f.feedURL, Rel: "self"},
+		},
+		Entries: make([]atomEntry, len(f.items)),
+	}
+
+	for i, item := range f.items {
+		entry := atomEntry{
+			ID:        "urn:" + item.id,
+			Title:     item.title,
+			Updated:   item.published.Format(time.RFC3339),
+			Published: item.published.Format(time.RFC3339),
+			Links:     []atomLink{{Href: item.link, Rel: "alternate"}},
+			Content:   atomContent{Type: "html", Value: item.contentHTML()},
+		}
+
+		if item.imageURL != "" {
+			entry.Links = append(entry.Links, atomLink{Href: item.imageURL, Rel: "enclosure", Type: item.contentType, Length: item.size})
+		}
+
+		atom.Entries[i] = entry
+	}
+
+	body, err := xml.MarshalIndent(atom, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes,omitempty"`
+}
+
+func encodeJSONFeed(f *feed) ([]byte, error) {
+	output := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.title,
+		HomePageURL: f.link,
+		FeedURL:     f.feedURL,
+		Items:       make([]jsonFeedItem, len(f.items)),
+	}
+
+	for i, item := range f.items {
+		output.Items[i] = jsonFeedItem{
+			ID:            item.id,
+			URL:           item.link,
+			Title:         item.title,
+			ContentHTML:   item.contentHTML(),
+			Image:         item.imageURL,
+			DatePublished: item.published.Format(time.RFC3339),
+		}
+
+		if item.imageURL != "" {
+			output.Items[i].Attachments = []jsonFeedAttachment{{URL: item.imageURL, MimeType: item.contentType, SizeInBytes: item.size}}
+		}
+	}
+
+	return json.MarshalIndent(output, "", "  ")
+}
@@ -0,0 +1,206 @@
+package routes
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeds(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	password := "1234"
+	user, err := models.RegisterUser(db, "username", &password, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{
+		Title: "Holiday",
+		Path:  "/photos/holiday",
+	}
+
+	if !assert.NoError(t, db.Model(&user).Association("Albums").Append(&album)) {
+		return
+	}
+
+	otherAlbum := models.Album{
+		Title: "Other",
+		Path:  "/photos/other",
+	}
+
+	if !assert.NoError(t, db.Save(&otherAlbum).Error) {
+		return
+	}
+
+	media := []models.Media{
+		{Title: "beach.jpg", Path: "/photos/holiday/beach.jpg", AlbumID: album.ID},
+		{Title: "sunset.jpg", Path: "/photos/holiday/sunset.jpg", AlbumID: album.ID},
+		{Title: "secret.jpg", Path: "/photos/other/secret.jpg", AlbumID: otherAlbum.ID},
+	}
+
+	if !assert.NoError(t, db.Create(&media).Error) {
+		return
+	}
+
+	mediaURL := models.MediaURL{
+		MediaID:     media[0].ID,
+		MediaName:   "beach_highres.jpg",
+		Purpose:     models.PhotoHighRes,
+		ContentType: "image/jpeg",
+		FileSize:    1234,
+	}
+
+	if !assert.NoError(t, db.Create(&mediaURL).Error) {
+		return
+	}
+
+	shareToken := models.ShareToken{
+		Value:   "sharetoken",
+		OwnerID: user.ID,
+		AlbumID: &album.ID,
+	}
+
+	if !assert.NoError(t, db.Create(&shareToken).Error) {
+		return
+	}
+
+	router := mux.NewRouter()
+	RegisterFeedRoutes(db, router.PathPrefix("/feed").Subrouter())
+	RegisterPhotoRoutes(db, router.PathPrefix("/photo").Subrouter())
+
+	serve := func(target string, authenticate bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if authenticate {
+			req = req.WithContext(auth.AddUserToContext(req.Context(), user))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		rec := serve(fmt.Sprintf("/feed/album/%d/rss", album.ID), false)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = serve(fmt.Sprintf("/feed/album/%d/rss?token=invalid", album.ID), false)
+		assert.NotEqual(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("RSS feed with share token", func(t *testing.T) {
+		rec := serve(fmt.Sprintf("/feed/album/%d/rss?token=%s", album.ID, shareToken.Value), false)
+		if !assert.Equal(t, http.StatusOK, rec.Code) {
+			return
+		}
+
+		var rss rssFeed
+		if !assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &rss)) {
+			return
+		}
+
+		assert.Equal(t, "Holiday", rss.Channel.Title)
+		if !assert.Len(t, rss.Channel.Items, 2) {
+			return
+		}
+
+		beach := rss.Channel.Items[1]
+		assert.Equal(t, "beach.jpg", beach.Title)
+		if assert.NotNil(t, beach.Enclosure) {
+			assert.Contains(t, beach.Enclosure.URL, "beach_highres.jpg")
+			assert.Contains(t, beach.Enclosure.URL, "token=sharetoken")
+			assert.Equal(t, int64(1234), beach.Enclosure.Length)
+		}
+	})
+
+	t.Run("Enclosure with basic auth", func(t *testing.T) {
+		test_utils.FilesystemTest(t)
+
+		mediaURL.Media = &media[0]
+		cachedPath, err := mediaURL.CachedPath()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		if !assert.NoError(t, os.MkdirAll(path.Dir(cachedPath), 0755)) ||
+			!assert.NoError(t, os.WriteFile(cachedPath, make([]byte, mediaURL.FileSize), 0644)) {
+			return
+		}
+
+		basicAuth := func(target string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", target, nil)
+			req.SetBasicAuth("username", password)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := basicAuth(fmt.Sprintf("/feed/album/%d/rss", album.ID))
+		if !assert.Equal(t, http.StatusOK, rec.Code) {
+			return
+		}
+
+		var rss rssFeed
+		if !assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &rss)) || !assert.Len(t, rss.Channel.Items, 2) {
+			return
+		}
+
+		enclosure := rss.Channel.Items[1].Enclosure
+		if !assert.NotNil(t, enclosure) {
+			return
+		}
+
+		enclosureURL, err := url.Parse(enclosure.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		rec = basicAuth(enclosureURL.RequestURI())
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, int(mediaURL.FileSize), rec.Body.Len())
+	})
+
+	t.Run("Atom feed", func(t *testing.T) {
+		rec := serve(fmt.Sprintf("/feed/album/%d/atom", album.ID), true)
+		if !assert.Equal(t, http.StatusOK, rec.Code) {
+			return
+		}
+
+		var atom atomFeed
+		if !assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &atom)) {
+			return
+		}
+
+		assert.Len(t, atom.Entries, 2)
+	})
+
+	t.Run("Library JSON feed", func(t *testing.T) {
+		rec := serve("/feed/library/json", true)
+		if !assert.Equal(t, http.StatusOK, rec.Code) {
+			return
+		}
+
+		var feed jsonFeed
+		if !assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &feed)) {
+			return
+		}
+
+		titles := make([]string, 0)
+		for _, item := range feed.Items {
+			titles = append(titles, item.Title)
+		}
+
+		assert.ElementsMatch(t, []string{"beach.jpg", "sunset.jpg"}, titles)
+	})
+}
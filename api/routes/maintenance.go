@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/photoview/photoview/api/maintenance"
+)
+
+// How long clients are told to wait before retrying requests rejected during maintenance
+const maintenanceRetryAfter = "300"
+
+// maintenanceMiddleware rejects requests adding media to the library while the server is in maintenance mode.
+// Requests only reading the library are still served.
+func maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maintenance.Enabled() && !readOnlyMethod(r.Method) {
+			w.Header().Set("Retry-After", maintenanceRetryAfter)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(maintenance.ErrMaintenance.Error()))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func readOnlyMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,304 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/jpeg"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/utils"
+	"gorm.io/gorm"
+)
+
+// Content types of originals that can be resized for a frame, when no high resolution version has been generated
+var frameDecodableContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type framePlaylist struct {
+	Title    string      `json:"title"`
+	Interval int         `json:"interval"`
+	Items    []frameItem `json:"items"`
+}
+
+type frameItem struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// framePhoto is a photo of a frame along with the file it is shown from
+type framePhoto struct {
+	media     *models.Media
+	rendition *models.MediaURL
+}
+
+// RegisterPhotoFrameRoutes serves the slideshows of photo frames.
+// Simple frames can keep loading /image, which always responds with the photo that should currently be shown,
+// while frames running their own slideshow can fetch /playlist.json.
+func RegisterPhotoFrameRoutes(db *gorm.DB, router *mux.Router) {
+	router.HandleFunc("/{token}/image", func(w http.ResponseWriter, r *http.Request) {
+		frame, ok := photoFrameFromRequest(db, w, r)
+		if !ok {
+			return
+		}
+
+		photos, err := photoFramePhotos(db, frame, time.Now())
+		if err != nil {
+			log.Error(r.Context(), "Getting photos of photo frame", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		if len(photos) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("no photos to show"))
+			return
+		}
+
+		cycle := time.Now().Unix() / int64(frame.Interval)
+		remaining := int64(frame.Interval) - time.Now().Unix()%int64(frame.Interval)
+
+		// Lets frames that simply display the url in a browser move on to the next photo
+		w.Header().Set("Refresh", strconv.FormatInt(remaining, 10))
+		w.Header().Set("Cache-Control", "no-store")
+
+		servePhotoFrameImage(db, w, r, frame, photos[cycle%int64(len(photos))])
+	}).Methods(http.MethodGet, http.MethodHead)
+
+	router.HandleFunc("/{token}/playlist.json", func(w http.ResponseWriter, r *http.Request) {
+		frame, ok := photoFrameFromRequest(db, w, r)
+		if !ok {
+			return
+		}
+
+		photos, err := photoFramePhotos(db, frame, time.Now())
+		if err != nil {
+			log.Error(r.Context(), "Getting photos of photo frame", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		playlist := framePlaylist{
+			Title:    frame.Title,
+			Interval: frame.Interval,
+			Items:    make([]frameItem, 0, len(photos)),
+		}
+
+		for _, photo := range photos {
+			imageURL := utils.ApiEndpointUrl()
+			imageURL.Path = path.Join(imageURL.Path, "frame", frame.Token, "media", strconv.Itoa(photo.media.ID))
+
+			width, height := fitDimensions(photo.rendition.Width, photo.rendition.Height, frame.MaxSize)
+
+			playlist.Items = append(playlist.Items, frameItem{
+				ID:     photo.media.ID,
+				Title:  photo.media.Title,
+				URL:    absoluteURL(r, imageURL.String()),
+				Width:  width,
+				Height: height,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(playlist)
+	}).Methods(http.MethodGet, http.MethodHead)
+
+	router.HandleFunc("/{token}/media/{id}", func(w http.ResponseWriter, r *http.Request) {
+		frame, ok := photoFrameFromRequest(db, w, r)
+		if !ok {
+			return
+		}
+
+		photos, err := photoFramePhotos(db, frame, time.Now())
+		if err != nil {
+			log.Error(r.Context(), "Getting photos of photo frame", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		for _, photo := range photos {
+			if strconv.Itoa(photo.media.ID) == mux.Vars(r)["id"] {
+				w.Header().Set("Cache-Control", "private, max-age=86400")
+				servePhotoFrameImage(db, w, r, frame, photo)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404"))
+	}).Methods(http.MethodGet, http.MethodHead)
+}
+
+// photoFrameFromRequest returns the photo frame of the token in the url.
+// If the returned bool is false, a response has already been written.
+func photoFrameFromRequest(db *gorm.DB, w http.ResponseWriter, r *http.Request) (*models.PhotoFrame, bool) {
+	var frame models.PhotoFrame
+	if err := db.Where("token = ?", mux.Vars(r)["token"]).First(&frame).Error; err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("photo frame not found"))
+		return nil, false
+	}
+
+	return &frame, true
+}
+
+// photoFramePhotos returns the photos shown by a frame, in the order they are shown.
+// Shuffled frames are reordered every time all photos have been shown.
+func photoFramePhotos(db *gorm.DB, frame *models.PhotoFrame, now time.Time) ([]framePhoto, error) {
+	query := db.Where("media.type = ?", models.MediaTypePhoto).Preload("MediaURL").Order("media.date_shot, media.title")
+
+	if frame.AlbumID == nil {
+		query = query.Joins("JOIN user_media_data ON user_media_data.media_id = media.id").
+			Where("user_media_data.user_id = ? AND user_media_data.favorite = ?", frame.UserID, true)
+	} else if frame.IncludeSubAlbums {
+		albums, err := models.GetChildrenFromAlbums(db, nil, []int{*frame.AlbumID})
+		if err != nil {
+			return nil, err
+		}
+
+		albumIDs := make([]int, 0, len(albums))
+		for _, album := range albums {
+			albumIDs = append(albumIDs, album.ID)
+		}
+
+		query = query.Where("media.album_id IN (?)", albumIDs)
+	} else {
+		query = query.Where("media.album_id = ?", *frame.AlbumID)
+	}
+
+	var media []*models.Media
+	if err := query.Find(&media).Error; err != nil {
+		return nil, err
+	}
+
+	coldStorageAlbums := make(map[int]bool)
+	photos := make([]framePhoto, 0, len(media))
+
+	for _, m := range media {
+		var rendition *models.MediaURL
+		for i := range m.MediaURL {
+			mediaURL := &m.MediaURL[i]
+
+			if mediaURL.Purpose == models.PhotoHighRes {
+				rendition = mediaURL
+				break
+			}
+
+			if mediaURL.Purpose == models.MediaOriginal && frameDecodableContentTypes[mediaURL.ContentType] {
+				rendition = mediaURL
+			}
+		}
+
+		if rendition == nil {
+			continue
+		}
+
+		// Originals in cold storage can not be served right away
+		if rendition.Purpose == models.MediaOriginal {
+			inColdStorage, checked := coldStorageAlbums[m.AlbumID]
+			if !checked {
+				var err error
+				if inColdStorage, err = mediaInColdStorage(db, m); err != nil {
+					return nil, err
+				}
+				coldStorageAlbums[m.AlbumID] = inColdStorage
+			}
+
+			if inColdStorage {
+				continue
+			}
+		}
+
+		rendition.Media = m
+		photos = append(photos, framePhoto{media: m, rendition: rendition})
+	}
+
+	if frame.Shuffle && len(photos) > 0 {
+		round := now.Unix() / int64(frame.Interval) / int64(len(photos))
+		random := rand.New(rand.NewSource(int64(frame.ID)<<32 ^ round))
+		random.Shuffle(len(photos), func(i, j int) {
+			photos[i], photos[j] = photos[j], photos[i]
+		})
+	}
+
+	return photos, nil
+}
+
+// servePhotoFrameImage writes the image of a photo, scaled down to fit the max size of the frame
+func servePhotoFrameImage(db *gorm.DB, w http.ResponseWriter, r *http.Request, frame *models.PhotoFrame, photo framePhoto) {
+	cachedPath, err := photo.rendition.CachedPath()
+	if err != nil {
+		log.Error(r.Context(), "Serving photo frame image", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	if _, err := os.Stat(cachedPath); os.IsNotExist(err) {
+		if err := scanner.ProcessSingleMedia(db, photo.media); err != nil {
+			log.Error(r.Context(), "Processing media for photo frame", "path", photo.media.Path, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+	}
+
+	if photo.rendition.Width <= frame.MaxSize && photo.rendition.Height <= frame.MaxSize && photo.rendition.Width > 0 {
+		w.Header().Set("Content-Type", photo.rendition.ContentType)
+		serveMediaFile(w, r, photo.rendition.ContentType, cachedPath)
+		return
+	}
+
+	img, err := imaging.Open(cachedPath, imaging.AutoOrientation(true))
+	if err != nil {
+		log.Error(r.Context(), "Opening image for photo frame", "path", cachedPath, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	var buf bytes.Buffer
+	resized := imaging.Fit(img, frame.MaxSize, frame.MaxSize, imaging.Lanczos)
+	if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85}); err != nil {
+		log.Error(r.Context(), "Encoding image for photo frame", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// fitDimensions scales dimensions down to fit within a square of the given size, keeping the aspect ratio
+func fitDimensions(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+
+	if width > height {
+		return size, height * size / width
+	}
+
+	return width * size / height, size
+}
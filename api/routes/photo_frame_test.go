@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhotoFrameRoutes(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	user, err := models.RegisterUser(db, "username", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{Title: "Holiday", Path: t.TempDir()}
+	if !assert.NoError(t, db.Create(&album).Error) {
+		return
+	}
+
+	beachPath := path.Join(album.Path, "beach.jpg")
+	if !assert.NoError(t, imaging.Save(imaging.New(200, 100, color.White), beachPath)) {
+		return
+	}
+
+	media := []models.Media{
+		{Title: "beach.jpg", Path: beachPath, AlbumID: album.ID, Type: models.MediaTypePhoto},
+		{Title: "raw.cr2", Path: path.Join(album.Path, "raw.cr2"), AlbumID: album.ID, Type: models.MediaTypePhoto},
+		{Title: "clip.mp4", Path: path.Join(album.Path, "clip.mp4"), AlbumID: album.ID, Type: models.MediaTypeVideo},
+	}
+	if !assert.NoError(t, db.Create(&media).Error) {
+		return
+	}
+
+	mediaURLs := []models.MediaURL{
+		{MediaID: media[0].ID, MediaName: "beach.jpg", Purpose: models.MediaOriginal, ContentType: "image/jpeg", Width: 200, Height: 100},
+		{MediaID: media[1].ID, MediaName: "raw.cr2", Purpose: models.MediaOriginal, ContentType: "image/x-canon-cr2"},
+		{MediaID: media[2].ID, MediaName: "clip.mp4", Purpose: models.MediaOriginal, ContentType: "video/mp4"},
+	}
+	if !assert.NoError(t, db.Create(&mediaURLs).Error) {
+		return
+	}
+
+	frames := []models.PhotoFrame{
+		{Title: "Kitchen", Token: "albumframe", UserID: user.ID, AlbumID: &album.ID, MaxSize: 50, Interval: 60},
+		{Title: "Bedroom", Token: "favoritesframe", UserID: user.ID, MaxSize: 1920, Interval: 30},
+	}
+	if !assert.NoError(t, db.Create(&frames).Error) {
+		return
+	}
+
+	router := mux.NewRouter()
+	RegisterPhotoFrameRoutes(db, router.PathPrefix("/frame").Subrouter())
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		return rr
+	}
+
+	t.Run("Playlist", func(t *testing.T) {
+		rr := get("/frame/albumframe/playlist.json")
+		if !assert.Equal(t, http.StatusOK, rr.Code) {
+			return
+		}
+
+		var playlist framePlaylist
+		if !assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &playlist)) {
+			return
+		}
+
+		assert.Equal(t, "Kitchen", playlist.Title)
+		assert.Equal(t, 60, playlist.Interval)
+		if assert.Len(t, playlist.Items, 1, "only photos that can be shown should be listed") {
+			assert.Equal(t, media[0].ID, playlist.Items[0].ID)
+			assert.Equal(t, 50, playlist.Items[0].Width)
+			assert.Equal(t, 25, playlist.Items[0].Height)
+			assert.Contains(t, playlist.Items[0].URL, "/frame/albumframe/media/")
+		}
+	})
+
+	t.Run("Current image is resized", func(t *testing.T) {
+		rr := get("/frame/albumframe/image")
+		if !assert.Equal(t, http.StatusOK, rr.Code) {
+			return
+		}
+
+		assert.Equal(t, "image/jpeg", rr.Header().Get("Content-Type"))
+		assert.NotEmpty(t, rr.Header().Get("Refresh"))
+
+		img, _, err := image.Decode(rr.Body)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 50, img.Bounds().Dx())
+			assert.Equal(t, 25, img.Bounds().Dy())
+		}
+	})
+
+	t.Run("Media not in frame", func(t *testing.T) {
+		rr := get("/frame/albumframe/media/999")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Favorites", func(t *testing.T) {
+		rr := get("/frame/favoritesframe/image")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+
+		if _, err := user.FavoriteMedia(db, media[0].ID, true); !assert.NoError(t, err) {
+			return
+		}
+
+		rr = get("/frame/favoritesframe/image")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		rr := get("/frame/invalid/image")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,274 @@
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/jpeg"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/photoview/photoview/api/utils"
+)
+
+// maxResizeDimension limits the size images can be resized to on demand
+const maxResizeDimension = 4096
+
+// sharedResizeDimensions are the widths and heights images requested through share links are kept in the cache in.
+// Images of other sizes are resized again for every such request, so anonymous viewers can't fill the cache with images of every size.
+var sharedResizeDimensions = []int{100, 200, 400, 800, 1600}
+
+// resizeOptions is the size a client requested an image of a photo in, with the w, h and fit query parameters
+type resizeOptions struct {
+	// width and height are zero when they are not bounded
+	width  int
+	height int
+	// cover crops the image to cover the size, instead of fitting it inside it
+	cover bool
+	// faces are the faces detected in the image, which covering crops are placed over
+	faces []models.FaceRectangle
+}
+
+// parseResizeOptions reads the size an image is requested in, it returns false if the image is not to be resized
+func parseResizeOptions(query url.Values) (resizeOptions, bool, error) {
+	var options resizeOptions
+	if query.Get("w") == "" && query.Get("h") == "" {
+		return options, false, nil
+	}
+
+	for _, dimension := range []struct {
+		name  string
+		value *int
+	}{{"w", &options.width}, {"h", &options.height}} {
+		value := query.Get(dimension.name)
+		if value == "" {
+			continue
+		}
+
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 || size > maxResizeDimension {
+			return options, false, errors.Errorf("invalid %s, must be between 1 and %d", dimension.name, maxResizeDimension)
+		}
+		*dimension.value = size
+	}
+
+	switch query.Get("fit") {
+	case "", "contain":
+	case "cover":
+		if options.width == 0 || options.height == 0 {
+			return options, false, errors.New("fit cover requires both w and h")
+		}
+		options.cover = true
+	default:
+		return options, false, errors.New("invalid fit, must be contain or cover")
+	}
+
+	return options, true, nil
+}
+
+// purpose is the purpose of the images of the requested size in the media cache. Images cropped over the faces of a photo
+// are kept apart for every set of faces, so they are cropped again once faces are detected in the photo or removed from it.
+func (options resizeOptions) purpose() models.MediaPurpose {
+	purpose := models.ResizedPhotoPurpose(options.width, options.height, options.cover)
+	if !options.cover || len(options.faces) == 0 {
+		return purpose
+	}
+
+	hash := fnv.New32a()
+	for _, face := range options.faces {
+		fmt.Fprintf(hash, "%g,%g,%g,%g;", face.MinX, face.MinY, face.MaxX, face.MaxY)
+	}
+
+	return purpose + models.MediaPurpose(fmt.Sprintf("-faces%08x", hash.Sum32()))
+}
+
+// cachedForShares returns whether images of the size are kept in the cache when they are requested through a share link
+func (options resizeOptions) cachedForShares() bool {
+	for _, dimension := range []int{options.width, options.height} {
+		if dimension == 0 {
+			continue
+		}
+
+		standard := false
+		for _, size := range sharedResizeDimensions {
+			standard = standard || dimension == size
+		}
+		if !standard {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resizeSource returns the smallest image of the media that is at least the requested size, to resize it from.
+// Images are resized from the copies of the media in the cache, its thumbnail, scaled images and web version,
+// and only from the original of photos that have no web version, as they are in a format browsers display already.
+func resizeSource(db *gorm.DB, media *models.Media, options resizeOptions) (*models.MediaURL, error) {
+	var urls []*models.MediaURL
+	if err := db.Where("media_id = ?", media.ID).Find(&urls).Error; err != nil {
+		return nil, errors.Wrap(err, "get images of media to resize")
+	}
+
+	var largest *models.MediaURL
+	for _, url := range urls {
+		url.Media = media
+		if largest == nil || url.Width*url.Height > largest.Width*largest.Height {
+			largest = url
+		}
+	}
+
+	// Images fit inside a viewport of the size, and cover it when they fill the dimension they are cropped in
+	width, height := options.width, options.height
+	if width == 0 {
+		width = math.MaxInt32
+	}
+	if height == 0 {
+		height = math.MaxInt32
+	}
+	if options.cover && largest != nil {
+		if largest.Width*options.height > largest.Height*options.width {
+			width = math.MaxInt32
+		} else {
+			height = math.MaxInt32
+		}
+	}
+
+	return media.ImageForViewport(urls, width, height), nil
+}
+
+// resizeImage scales an image down to the requested size, images are never scaled up.
+// Images that cover the size are cropped to a smaller size of the same aspect ratio, if they are too small to cover it,
+// keeping the faces or the most detailed part of the image rather than its center.
+func resizeImage(img image.Image, options resizeOptions) image.Image {
+	bounds := img.Bounds()
+
+	if options.cover {
+		scale := math.Min(1, math.Min(float64(bounds.Dx())/float64(options.width), float64(bounds.Dy())/float64(options.height)))
+		width := int(math.Max(1, math.Round(float64(options.width)*scale)))
+		height := int(math.Max(1, math.Round(float64(options.height)*scale)))
+		cropped := imaging.Crop(img, smartCropRect(img, width, height, options.faces))
+		return imaging.Resize(cropped, width, height, imaging.Lanczos)
+	}
+
+	width, height := options.width, options.height
+	if width == 0 {
+		width = bounds.Dx()
+	}
+	if height == 0 {
+		height = bounds.Dy()
+	}
+
+	return imaging.Fit(img, width, height, imaging.Lanczos)
+}
+
+// serveResizedPhoto writes the image of a media url resized to the requested size. Images of media whose content has been hashed
+// are kept in its directory in the media cache, and recorded in the manifest of the cache. As no media url references them,
+// they are removed by the garbage collection of the cache once they have not been requested for its grace period,
+// or first when the cache exceeds its budget. Images requested through share links are only kept in the sizes of sharedResizeDimensions.
+func serveResizedPhoto(db *gorm.DB, w http.ResponseWriter, r *http.Request, source *models.MediaURL, sourcePath string, options resizeOptions) {
+	media := source.Media
+	purpose := options.purpose()
+	fileName := string(purpose) + ".jpg"
+
+	var cachedPath, cacheKey string
+	if media.ContentHash != nil {
+		contentCachePath, err := media.CachePathForPurpose(purpose)
+		if err != nil {
+			log.Error(r.Context(), "Serving resized photo", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+		cachedPath = path.Join(contentCachePath, fileName)
+		cacheKey = path.Join(utils.ContentCacheKey(*media.ContentHash), fileName)
+
+		var entry models.CacheEntry
+		if err := db.Where("cache_entries.key = ?", cacheKey).Limit(1).Find(&entry).Error; err != nil {
+			log.Warn(r.Context(), "Getting resized photo from cache", "error", err)
+		} else if fileInfo, err := os.Stat(cachedPath); entry.Key != "" && err == nil && fileInfo.Size() == entry.FileSize {
+			if err := storage.TouchCacheEntry(db, &entry); err != nil {
+				log.Warn(r.Context(), "Updating access time of resized photo", "error", err)
+			}
+
+			serveMediaFile(w, r, entry.ContentType, cachedPath)
+			return
+		}
+	}
+
+	img, err := imaging.Open(sourcePath, imaging.AutoOrientation(true))
+	if err != nil {
+		log.Error(r.Context(), "Opening image to resize", "path", sourcePath, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	var buf bytes.Buffer
+	resized := resizeImage(img, options)
+	if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 80}); err != nil {
+		log.Error(r.Context(), "Encoding resized photo", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	saving := cachedPath != ""
+	if saving {
+		shareToken, err := requestShareToken(db, r)
+		if err != nil {
+			log.Warn(r.Context(), "Getting share token of resized photo", "error", err)
+		}
+		saving = err == nil && (shareToken == nil || options.cachedForShares())
+	}
+
+	if saving {
+		if err := saveResizedPhoto(db, media, purpose, resized.Bounds(), buf.Bytes(), cachedPath, cacheKey); err != nil {
+			log.Warn(r.Context(), "Saving resized photo to cache", "path", cachedPath, "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", string(media_type.TypeJpeg))
+	http.ServeContent(w, r, fileName, time.Now(), bytes.NewReader(buf.Bytes()))
+}
+
+// saveResizedPhoto writes a resized image to the media cache, and records it in the manifest of the cache
+func saveResizedPhoto(db *gorm.DB, media *models.Media, purpose models.MediaPurpose, bounds image.Rectangle, data []byte, cachedPath string, cacheKey string) error {
+	err := utils.WriteFileAtomic(cachedPath, func(tmpPath string) error {
+		return os.WriteFile(tmpPath, data, 0644)
+	})
+	if err != nil {
+		return errors.Wrap(err, "write resized photo")
+	}
+
+	entry := models.CacheEntry{
+		Key:         cacheKey,
+		ContentHash: *media.ContentHash,
+		Purpose:     purpose,
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		ContentType: string(media_type.TypeJpeg),
+		FileSize:    int64(len(data)),
+	}
+
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error; err != nil {
+		return errors.Wrapf(err, "save cache entry (%s)", cacheKey)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResizeOptions(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected resizeOptions
+		resizing bool
+		invalid  bool
+	}{
+		{query: "", resizing: false},
+		{query: "w=800&h=600", expected: resizeOptions{width: 800, height: 600}, resizing: true},
+		{query: "w=800&h=600&fit=cover", expected: resizeOptions{width: 800, height: 600, cover: true}, resizing: true},
+		{query: "h=300&fit=contain", expected: resizeOptions{height: 300}, resizing: true},
+		{query: "w=800&fit=cover", invalid: true},
+		{query: "w=0", invalid: true},
+		{query: "w=100000", invalid: true},
+		{query: "w=abc", invalid: true},
+		{query: "w=800&fit=stretch", invalid: true},
+	}
+
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		options, resizing, err := parseResizeOptions(query)
+		if test.invalid {
+			assert.Error(t, err, test.query)
+			continue
+		}
+
+		assert.NoError(t, err, test.query)
+		assert.Equal(t, test.resizing, resizing, test.query)
+		assert.Equal(t, test.expected, options, test.query)
+	}
+
+	assert.Equal(t, models.MediaPurpose("resized-800x600-cover"), resizeOptions{width: 800, height: 600, cover: true}.purpose())
+	assert.Equal(t, models.MediaPurpose("resized-0x300"), resizeOptions{height: 300}.purpose())
+
+	// Images cropped over faces are cached apart from those cropped before the faces were detected
+	faces := []models.FaceRectangle{{MinX: 0.1, MaxX: 0.2, MinY: 0.1, MaxY: 0.3}}
+	withFaces := resizeOptions{width: 800, height: 600, cover: true, faces: faces}.purpose()
+	assert.Regexp(t, `^resized-800x600-cover-faces[0-9a-f]{8}$`, withFaces)
+	movedFaces := []models.FaceRectangle{{MinX: 0.5, MaxX: 0.6, MinY: 0.1, MaxY: 0.3}}
+	assert.NotEqual(t, withFaces, resizeOptions{width: 800, height: 600, cover: true, faces: movedFaces}.purpose())
+}
+
+func TestResizeImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1200, 800))
+
+	assert.Equal(t, image.Rect(0, 0, 600, 400), resizeImage(img, resizeOptions{width: 600, height: 600}).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 450, 300), resizeImage(img, resizeOptions{height: 300}).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 300, 300), resizeImage(img, resizeOptions{width: 300, height: 300, cover: true}).Bounds())
+
+	// Images are not scaled up
+	assert.Equal(t, image.Rect(0, 0, 1200, 800), resizeImage(img, resizeOptions{width: 2400}).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 800, 800), resizeImage(img, resizeOptions{width: 2000, height: 2000, cover: true}).Bounds())
+}
+
+func TestResizeOptionsCachedForShares(t *testing.T) {
+	assert.True(t, resizeOptions{width: 400, height: 400, cover: true}.cachedForShares())
+	assert.True(t, resizeOptions{height: 800}.cachedForShares())
+	assert.False(t, resizeOptions{width: 400, height: 401}.cachedForShares())
+	assert.False(t, resizeOptions{width: 1234}.cachedForShares())
+}
+
+func TestServeResizedPhotoOrientation(t *testing.T) {
+	var encoded bytes.Buffer
+	if !assert.NoError(t, jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 400, 200)), nil)) {
+		return
+	}
+
+	// Phones save portrait photos landscape, with an orientation of 6 to rotate them by 90 degrees
+	data := append([]byte{0xff, jpegMarkerSOI}, orientationEXIFSegment(6)...)
+	data = append(data, encoded.Bytes()[2:]...)
+
+	sourcePath := path.Join(t.TempDir(), "portrait.jpg")
+	if !assert.NoError(t, os.WriteFile(sourcePath, data, 0644)) {
+		return
+	}
+
+	// Media without a content hash is not cached, so no database is needed
+	source := &models.MediaURL{Media: &models.Media{Type: models.MediaTypePhoto}, Purpose: models.MediaOriginal, ContentType: "image/jpeg"}
+
+	rec := httptest.NewRecorder()
+	serveResizedPhoto(nil, rec, httptest.NewRequest("GET", "/photo/portrait.jpg?h=100", nil), source, sourcePath, resizeOptions{height: 100})
+
+	resized, err := jpeg.Decode(rec.Body)
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 50, 100), resized.Bounds())
+	}
+}
@@ -1,15 +1,23 @@
 package routes
 
 import (
-	"log"
+	"bytes"
 	"net/http"
 	"os"
+	"path"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/scanner/svg"
+	"github.com/photoview/photoview/api/stats"
+	"github.com/photoview/photoview/api/storage"
 )
 
 func RegisterPhotoRoutes(db *gorm.DB, router *mux.Router) {
@@ -29,41 +37,262 @@ func RegisterPhotoRoutes(db *gorm.DB, router *mux.Router) {
 
 		if success, response, status, err := authenticateMedia(media, db, r); !success {
 			if err != nil {
-				log.Printf("WARN: error authenticating photo: %s\n", err)
+				log.Warn(r.Context(), "Error authenticating photo", "error", err)
 			}
 			w.WriteHeader(status)
 			w.Write([]byte(response))
 			return
 		}
 
+		// Photos served through share links are watermarked, if their owner has enabled a watermark
+		watermark, err := shareWatermark(db, r)
+		if err != nil {
+			log.Error(r.Context(), "Getting watermark of shared photo", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+		watermarked := watermark.appliesTo(media, mediaURL.ContentType)
+
+		// Metadata such as GPS coordinates is removed from photos served through share links set to strip it
+		stripping, err := shareStripsMetadata(db, r)
+		if err != nil {
+			log.Error(r.Context(), "Getting metadata option of shared photo", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+		stripping = stripping && !watermarked && media.Type == models.MediaTypePhoto && mediaURL.ContentType != string(media_type.TypeSvg)
+
+		// Images requested in another size are resized from the smallest image of the media that is large enough
+		resize, resizing, err := parseResizeOptions(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if resizing {
+			source, err := resizeSource(db, media, resize)
+			if err != nil {
+				log.Error(r.Context(), "Serving resized photo", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("internal server error"))
+				return
+			}
+			if source == nil {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("404"))
+				return
+			}
+			mediaURL = *source
+
+			if resize.cover {
+				if resize.faces, err = mediaFaceRectangles(db, media.ID); err != nil {
+					log.Warn(r.Context(), "Getting faces of photo to crop it", "error", err)
+				}
+			}
+		} else if mediaURL.Purpose.HasAVIFVariant() && !watermarked && !stripping {
+			// Clients that accept AVIF images are served the AVIF variant of the image, if it has been encoded
+			w.Header().Add("Vary", "Accept")
+
+			if acceptsContentType(r, string(media_type.TypeAvif)) {
+				var variant models.MediaURL
+				err := db.Where("media_id = ? AND purpose = ?", mediaURL.MediaID, mediaURL.Purpose.AVIFVariant()).Limit(1).Find(&variant).Error
+				if err != nil {
+					log.Warn(r.Context(), "Getting AVIF variant of photo", "error", err)
+				} else if variant.ID != 0 {
+					variant.Media = media
+					if variant.CachedFileComplete() {
+						mediaURL = variant
+					}
+				}
+			}
+		}
+
+		// Originals in formats that can't be decoded to draw the watermark over are replaced by their web version
+		if watermarked {
+			replacement, err := watermarkableMediaURL(db, &mediaURL)
+			if err != nil {
+				log.Error(r.Context(), "Getting web version of shared photo", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("internal server error"))
+				return
+			}
+			if replacement == nil {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("photo can not be watermarked"))
+				return
+			}
+			mediaURL = *replacement
+		}
+
+		// Originals in formats metadata can't be removed from are replaced by their web version
+		if stripping && !canStripMetadata(mediaURL.ContentType) {
+			replacement, err := strippableMediaURL(db, &mediaURL)
+			if err != nil {
+				log.Error(r.Context(), "Getting web version of shared photo", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("internal server error"))
+				return
+			}
+			if replacement == nil {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("metadata can not be removed from photo"))
+				return
+			}
+			mediaURL = *replacement
+		}
+
 		cachedPath, err := mediaURL.CachedPath()
 		if err != nil {
-			log.Printf("ERROR: %s\n", err)
+			log.Error(r.Context(), "Serving photo", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("internal server error"))
 			return
 		}
 
-		if _, err := os.Stat(cachedPath); os.IsNotExist((err)) {
+		inColdStorage, err := mediaInColdStorage(db, media)
+		if err != nil {
+			log.Error(r.Context(), "Serving photo", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		if inColdStorage {
+			var ok bool
+			if cachedPath, ok = resolveColdStoragePath(db, w, r, &mediaURL, cachedPath); !ok {
+				return
+			}
+		}
+
+		// Cached files that have not been written completely are generated again, like missing ones
+		cacheHit := true
+		if _, err := os.Stat(cachedPath); os.IsNotExist((err)) || (mediaURL.Purpose != models.MediaOriginal && !mediaURL.CachedFileComplete()) {
+			cacheHit = false
 			// err := db.Transaction(func(tx *gorm.DB) error {
 			if err = scanner.ProcessSingleMedia(db, media); err != nil {
-				log.Printf("ERROR: processing image not found in cache (%s): %s\n", cachedPath, err)
+				log.Error(r.Context(), "Processing image not found in cache", "path", cachedPath, "error", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte("internal server error"))
 				return
 			}
 
+			// Processing moves the files of media that had not been hashed into the directory of its content
+			if !inColdStorage {
+				if err := db.First(&mediaURL, mediaURL.ID).Error; err == nil {
+					cachedPath, _ = mediaURL.CachedPath()
+				}
+			}
+
 			if _, err = os.Stat(cachedPath); err != nil {
-				log.Printf("ERROR: after reprocessing image not found in cache (%s): %s\n", cachedPath, err)
+				log.Error(r.Context(), "After reprocessing image not found in cache", "path", cachedPath, "error", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte("internal server error"))
 				return
 			}
 		}
 
+		// Originals are not cached, so they don't count towards the hit rate of the cache
+		if mediaURL.Purpose != models.MediaOriginal {
+			stats.RecordCacheAccess(cacheHit)
+		}
+
+		if err := storage.TouchMediaURL(db, &mediaURL); err != nil {
+			log.Warn(r.Context(), "Updating access time of media url", "error", err)
+		}
+
+		if watermarked {
+			var resizeWatermarked *resizeOptions
+			if resizing {
+				resizeWatermarked = &resize
+			}
+			serveWatermarkedPhoto(w, r, watermark, cachedPath, resizeWatermarked)
+			return
+		}
+
 		// Allow caching the resource for 1 day
 		w.Header().Set("Cache-Control", "private, max-age=86400, immutable")
 
-		http.ServeFile(w, r, cachedPath)
+		if resizing {
+			serveResizedPhoto(db, w, r, &mediaURL, cachedPath, resize)
+			return
+		}
+
+		// Resized photos are encoded without metadata already
+		if stripping && canStripMetadata(mediaURL.ContentType) {
+			serveStrippedPhoto(w, r, &mediaURL, cachedPath)
+			return
+		}
+
+		serveMediaFile(w, r, mediaURL.ContentType, cachedPath)
 	})
 }
+
+// acceptsContentType returns whether the Accept header of a request lists the content type, without a quality of zero.
+// Wildcards are not matched, as browsers send them for all images.
+func acceptsContentType(r *http.Request, contentType string) bool {
+	for _, header := range r.Header.Values("Accept") {
+		for _, accepted := range strings.Split(header, ",") {
+			params := strings.Split(accepted, ";")
+			if !strings.EqualFold(strings.TrimSpace(params[0]), contentType) {
+				continue
+			}
+
+			for _, param := range params[1:] {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.TrimSpace(name) != "q" {
+					continue
+				}
+				if quality, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && quality <= 0 {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// serveMediaFile writes a file of a media. SVG images are sanitized as they are served, as browsers run their scripts
+// on the origin of photoview when they are opened directly, such as from shared links.
+func serveMediaFile(w http.ResponseWriter, r *http.Request, contentType string, filePath string) {
+	if contentType != string(media_type.TypeSvg) {
+		http.ServeFile(w, r, filePath)
+		return
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Error(r.Context(), "Opening SVG image to serve", "path", filePath, "error", err)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404"))
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		log.Error(r.Context(), "Reading file stats of SVG image", "path", filePath, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	var sanitized bytes.Buffer
+	if err := svg.Sanitize(&sanitized, file); err != nil {
+		log.Error(r.Context(), "Sanitizing SVG image", "path", filePath, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src data:")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	http.ServeContent(w, r, path.Base(filePath), fileInfo.ModTime(), bytes.NewReader(sanitized.Bytes()))
+}
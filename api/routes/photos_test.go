@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeMediaFile(t *testing.T) {
+	dir := t.TempDir()
+	svgPath := path.Join(dir, "image.svg")
+	if err := os.WriteFile(svgPath, []byte(`<svg onload="alert(1)"><script>alert(2)</script><rect/></svg>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	serveMediaFile(w, httptest.NewRequest("GET", "/api/photo/image.svg", nil), "image/svg+xml", svgPath)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "<svg><rect></rect></svg>", w.Body.String())
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Security-Policy"), "default-src 'none'")
+
+	jpegPath := path.Join(dir, "image.jpg")
+	if err := os.WriteFile(jpegPath, []byte("<svg onload=\"alert(1)\"></svg>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Other files are served as they are
+	w = httptest.NewRecorder()
+	serveMediaFile(w, httptest.NewRequest("GET", "/api/photo/image.jpg", nil), "image/jpeg", jpegPath)
+	assert.Equal(t, "<svg onload=\"alert(1)\"></svg>", w.Body.String())
+}
+
+func TestAcceptsContentType(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected bool
+	}{
+		{"", false},
+		{"image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8", true},
+		{"image/webp,*/*", false},
+		{"image/*", false},
+		{"IMAGE/AVIF", true},
+		{"image/webp, image/avif;q=0.5", true},
+		{"image/avif;q=0", false},
+		{"image/avif; q=0.0, image/jpeg", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/photo/image.jpg", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+
+		assert.Equal(t, test.expected, acceptsContentType(r, "image/avif"), test.accept)
+	}
+}
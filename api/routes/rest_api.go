@@ -0,0 +1,546 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/dataloader"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/graphql/models/actions"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/maintenance"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type restAlbum struct {
+	ID            int    `json:"id"`
+	Title         string `json:"title"`
+	ParentAlbumID *int   `json:"parentAlbumId"`
+}
+
+type restAlbumDetails struct {
+	restAlbum
+	SubAlbums []restAlbum `json:"subAlbums"`
+	Media     []restMedia `json:"media"`
+}
+
+type restMedia struct {
+	ID       int              `json:"id"`
+	Title    string           `json:"title"`
+	Type     models.MediaType `json:"type"`
+	AlbumID  int              `json:"albumId"`
+	DateShot time.Time        `json:"dateShot"`
+	Favorite bool             `json:"favorite"`
+	// Urls of the files of the media, an url is left out when the file has not been generated
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"`
+	HighResURL   string `json:"highResUrl,omitempty"`
+	OriginalURL  string `json:"originalUrl,omitempty"`
+	VideoURL     string `json:"videoUrl,omitempty"`
+	// MotionPhotoURL is the url of the video of a motion photo
+	MotionPhotoURL string `json:"motionPhotoUrl,omitempty"`
+}
+
+type restShareRequest struct {
+	Expire        *time.Time `json:"expire"`
+	Password      *string    `json:"password"`
+	StripMetadata *bool      `json:"stripMetadata"`
+}
+
+type restShare struct {
+	Token         string     `json:"token"`
+	URL           string     `json:"url"`
+	Expire        *time.Time `json:"expire"`
+	HasPassword   bool       `json:"hasPassword"`
+	StripMetadata bool       `json:"stripMetadata"`
+}
+
+type restErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// restError is an error with the http status to respond with
+type restError struct {
+	status  int
+	message string
+}
+
+func (e restError) Error() string {
+	return e.message
+}
+
+// restHandler handles an authenticated request, returning the value to respond with as JSON
+type restHandler func(db *gorm.DB, user *models.User, r *http.Request) (interface{}, error)
+
+// restParameter is a query parameter of an endpoint
+type restParameter struct {
+	name        string
+	description string
+	schemaType  string
+}
+
+// restEndpoint is an operation of the REST API, the OpenAPI description is generated from these
+type restEndpoint struct {
+	method  string
+	path    string
+	summary string
+	query   []restParameter
+	// request is a value of the type of the JSON request body, nil if the operation takes none
+	request interface{}
+	// upload is set for operations taking files as a multipart form
+	upload bool
+	// response is a value of the type of the response body
+	response interface{}
+	status   int
+	handler  restHandler
+}
+
+var restEndpoints = []restEndpoint{
+	{
+		method:   http.MethodGet,
+		path:     "/albums",
+		summary:  "List the albums of the user",
+		query:    []restParameter{{"onlyRoot", "Only list the top level albums", "boolean"}, {"showEmpty", "Include albums without media", "boolean"}},
+		response: []restAlbum{},
+		status:   http.StatusOK,
+		handler:  restListAlbums,
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/albums/{id}",
+		summary:  "Get an album along with its sub albums and media",
+		response: restAlbumDetails{},
+		status:   http.StatusOK,
+		handler:  restGetAlbum,
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/albums/{id}/media",
+		summary:  "Upload photos and videos into an album, the files are sent as multipart form fields named `files`",
+		upload:   true,
+		response: uploadResponse{},
+		status:   http.StatusCreated,
+		handler:  restUploadMedia,
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/albums/{id}/shares",
+		summary:  "Share an album publicly, optionally protected by a password",
+		request:  restShareRequest{},
+		response: restShare{},
+		status:   http.StatusCreated,
+		handler:  restShareAlbum,
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/media/{id}",
+		summary:  "Get a photo or video",
+		response: restMedia{},
+		status:   http.StatusOK,
+		handler:  restGetMedia,
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/media/{id}/shares",
+		summary:  "Share a photo or video publicly, optionally protected by a password",
+		request:  restShareRequest{},
+		response: restShare{},
+		status:   http.StatusCreated,
+		handler:  restShareMedia,
+	},
+}
+
+// RegisterRESTRoutes registers a REST API for the most common operations, for integrations where GraphQL is impractical.
+// The API is described by an OpenAPI document served at /openapi.json.
+func RegisterRESTRoutes(db *gorm.DB, router *mux.Router) {
+	router.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		serverURL := absoluteURL(r, strings.TrimSuffix(r.URL.Path, "/openapi.json"))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openAPIDocument(restEndpoints, serverURL))
+	}).Methods(http.MethodGet)
+
+	for _, endpoint := range restEndpoints {
+		router.HandleFunc(endpoint.path, serveREST(db, endpoint)).Methods(endpoint.method)
+	}
+}
+
+func serveREST(db *gorm.DB, endpoint restEndpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := restUser(r)
+		if err != nil {
+			writeRESTError(w, r, err)
+			return
+		}
+
+		response, err := endpoint.handler(db.WithContext(r.Context()), user, r)
+		if err != nil {
+			writeRESTError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(endpoint.status)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func writeRESTError(w http.ResponseWriter, r *http.Request, err error) {
+	var restErr restError
+	if !errors.As(err, &restErr) {
+		log.Error(r.Context(), "Handling rest api request", "error", err)
+		restErr = restError{http.StatusInternalServerError, "internal server error"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(restErr.status)
+	json.NewEncoder(w).Encode(restErrorResponse{Error: restErr.message})
+}
+
+// restUser returns the user of the auth cookie, or of the access token in the Authorization header
+func restUser(r *http.Request) (*models.User, error) {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return user, nil
+	}
+
+	bearer := r.Header.Get("Authorization")
+	if bearer == "" {
+		return nil, restError{http.StatusUnauthorized, "authorization required"}
+	}
+
+	token, err := auth.TokenFromBearer(&bearer)
+	if err != nil {
+		return nil, restError{http.StatusUnauthorized, err.Error()}
+	}
+
+	user, err := dataloader.For(r.Context()).UserFromAccessToken.Load(*token)
+	if err != nil {
+		return nil, restError{http.StatusUnauthorized, "invalid authorization token"}
+	}
+
+	return user, nil
+}
+
+func restPathID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, restError{http.StatusBadRequest, "invalid id"}
+	}
+
+	return id, nil
+}
+
+// restOwnedAlbum returns the album of the id in the path, if it is owned by the user
+func restOwnedAlbum(db *gorm.DB, user *models.User, r *http.Request) (*models.Album, error) {
+	id, err := restPathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var album models.Album
+	if err := db.First(&album, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, restError{http.StatusNotFound, "album not found"}
+		}
+		return nil, err
+	}
+
+	ownsAlbum, err := user.OwnsAlbum(db, &album)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ownsAlbum {
+		return nil, restError{http.StatusNotFound, "album not found"}
+	}
+
+	return &album, nil
+}
+
+func restListAlbums(db *gorm.DB, user *models.User, r *http.Request) (interface{}, error) {
+	onlyRoot := r.URL.Query().Get("onlyRoot") == "true"
+	showEmpty := r.URL.Query().Get("showEmpty") == "true"
+
+	albums, err := actions.MyAlbums(db, user, &models.Ordering{}, nil, &onlyRoot, &showEmpty, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]restAlbum, 0, len(albums))
+	for _, album := range albums {
+		result = append(result, newRESTAlbum(album))
+	}
+
+	return result, nil
+}
+
+func restGetAlbum(db *gorm.DB, user *models.User, r *http.Request) (interface{}, error) {
+	album, err := restOwnedAlbum(db, user, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var subAlbums []*models.Album
+	if err := db.Where("parent_album_id = ?", album.ID).Order("title").Find(&subAlbums).Error; err != nil {
+		return nil, errors.Wrap(err, "get sub albums")
+	}
+
+	var media []*models.Media
+	if err := db.Where("album_id = ?", album.ID).Order("date_shot, title").Preload("MediaURL").Find(&media).Error; err != nil {
+		return nil, errors.Wrap(err, "get album media")
+	}
+
+	favorites, err := restFavorites(db, user, media)
+	if err != nil {
+		return nil, err
+	}
+
+	details := restAlbumDetails{
+		restAlbum: newRESTAlbum(album),
+		SubAlbums: make([]restAlbum, 0, len(subAlbums)),
+		Media:     make([]restMedia, 0, len(media)),
+	}
+
+	for _, subAlbum := range subAlbums {
+		details.SubAlbums = append(details.SubAlbums, newRESTAlbum(subAlbum))
+	}
+
+	for _, m := range media {
+		details.Media = append(details.Media, newRESTMedia(r, m, favorites[m.ID]))
+	}
+
+	return details, nil
+}
+
+func restGetMedia(db *gorm.DB, user *models.User, r *http.Request) (interface{}, error) {
+	id, err := restPathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var media models.Media
+	if err := db.Preload("MediaURL").First(&media, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, restError{http.StatusNotFound, "media not found"}
+		}
+		return nil, err
+	}
+
+	var album models.Album
+	if err := db.First(&album, media.AlbumID).Error; err != nil {
+		return nil, err
+	}
+
+	ownsAlbum, err := user.OwnsAlbum(db, &album)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ownsAlbum {
+		return nil, restError{http.StatusNotFound, "media not found"}
+	}
+
+	favorites, err := restFavorites(db, user, []*models.Media{&media})
+	if err != nil {
+		return nil, err
+	}
+
+	return newRESTMedia(r, &media, favorites[media.ID]), nil
+}
+
+func restUploadMedia(db *gorm.DB, user *models.User, r *http.Request) (interface{}, error) {
+	if maintenance.Enabled() {
+		return nil, restError{http.StatusServiceUnavailable, maintenance.ErrMaintenance.Error()}
+	}
+
+	album, err := restOwnedAlbum(db, user, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := limitUploadBody(r); err != nil {
+		return nil, restError{http.StatusRequestEntityTooLarge, err.Error()}
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, restError{http.StatusBadRequest, "expected multipart form"}
+	}
+
+	response := uploadResponse{
+		Media:  make([]uploadedMedia, 0),
+		Errors: make([]uploadError, 0),
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, restError{http.StatusBadRequest, "invalid multipart form"}
+		}
+
+		if part.FileName() == "" {
+			continue
+		}
+
+		media, err := receiveMultipartFile(db, album, part.FileName(), part)
+		if err != nil {
+			log.Warn(r.Context(), "Upload failed", "filename", part.FileName(), "error", err)
+			response.Errors = append(response.Errors, uploadError{Filename: part.FileName(), Error: err.Error()})
+			continue
+		}
+
+		response.Media = append(response.Media, uploadedMedia{ID: media.ID, Title: media.Title, Filename: part.FileName()})
+	}
+
+	if len(response.Media) == 0 {
+		messages := make([]string, 0, len(response.Errors))
+		for _, uploadErr := range response.Errors {
+			messages = append(messages, fmt.Sprintf("%s: %s", uploadErr.Filename, uploadErr.Error))
+		}
+		return nil, restError{http.StatusBadRequest, "no media was uploaded " + strings.Join(messages, ", ")}
+	}
+
+	processUploadedMedia(r.Context(), album)
+
+	return response, nil
+}
+
+func restShareAlbum(db *gorm.DB, user *models.User, r *http.Request) (interface{}, error) {
+	album, err := restOwnedAlbum(db, user, r)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := decodeRESTShareRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	shareToken, err := actions.AddAlbumShare(db, user, album.ID, request.Expire, request.Password, request.StripMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRESTShare(r, shareToken), nil
+}
+
+func restShareMedia(db *gorm.DB, user *models.User, r *http.Request) (interface{}, error) {
+	id, err := restPathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := decodeRESTShareRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	shareToken, err := actions.AddMediaShare(db, user, id, request.Expire, request.Password, request.StripMetadata)
+	if errors.Is(err, auth.ErrUnauthorized) {
+		return nil, restError{http.StatusNotFound, "media not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newRESTShare(r, shareToken), nil
+}
+
+func decodeRESTShareRequest(r *http.Request) (*restShareRequest, error) {
+	var request restShareRequest
+	if r.ContentLength == 0 {
+		return &request, nil
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		return nil, restError{http.StatusBadRequest, "invalid request body: " + err.Error()}
+	}
+
+	return &request, nil
+}
+
+// restFavorites returns which of the media the user has marked as favorite
+func restFavorites(db *gorm.DB, user *models.User, media []*models.Media) (map[int]bool, error) {
+	mediaIDs := make([]int, 0, len(media))
+	for _, m := range media {
+		mediaIDs = append(mediaIDs, m.ID)
+	}
+
+	favorites := make(map[int]bool)
+	if len(mediaIDs) == 0 {
+		return favorites, nil
+	}
+
+	var favoriteIDs []int
+	err := db.Model(&models.UserMediaData{}).
+		Where("user_id = ? AND favorite = ?", user.ID, true).
+		Where("media_id IN (?)", mediaIDs).
+		Pluck("media_id", &favoriteIDs).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get favorites")
+	}
+
+	for _, id := range favoriteIDs {
+		favorites[id] = true
+	}
+
+	return favorites, nil
+}
+
+func newRESTAlbum(album *models.Album) restAlbum {
+	return restAlbum{
+		ID:            album.ID,
+		Title:         album.Title,
+		ParentAlbumID: album.ParentAlbumID,
+	}
+}
+
+func newRESTMedia(r *http.Request, media *models.Media, favorite bool) restMedia {
+	result := restMedia{
+		ID:       media.ID,
+		Title:    media.Title,
+		Type:     media.Type,
+		AlbumID:  media.AlbumID,
+		DateShot: media.DateShot,
+		Favorite: favorite,
+	}
+
+	for _, mediaURL := range media.MediaURL {
+		fileURL := absoluteURL(r, mediaURL.URL())
+
+		switch mediaURL.Purpose {
+		case models.PhotoThumbnail, models.VideoThumbnail:
+			result.ThumbnailURL = fileURL
+		case models.PhotoHighRes:
+			result.HighResURL = fileURL
+		case models.MediaOriginal:
+			result.OriginalURL = fileURL
+		case models.VideoWeb:
+			result.VideoURL = fileURL
+		case models.MotionVideo:
+			result.MotionPhotoURL = fileURL
+		}
+	}
+
+	return result
+}
+
+func newRESTShare(r *http.Request, shareToken *models.ShareToken) restShare {
+	return restShare{
+		Token:         shareToken.Value,
+		URL:           uiURL(r, "share/"+shareToken.Value),
+		Expire:        shareToken.Expire,
+		HasPassword:   shareToken.Password != nil,
+		StripMetadata: shareToken.StripsMetadata(),
+	}
+}
@@ -0,0 +1,208 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/dataloader"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRESTAPI(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	user, err := models.RegisterUser(db, "username", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{Title: "Holiday", Path: "/photos/holiday"}
+	otherAlbum := models.Album{Title: "Other", Path: "/photos/other"}
+	if !assert.NoError(t, db.Create(&[]*models.Album{&album, &otherAlbum}).Error) {
+		return
+	}
+
+	if !assert.NoError(t, db.Model(&user).Association("Albums").Append(&album)) {
+		return
+	}
+
+	media := []models.Media{
+		{Title: "beach.jpg", Path: "/photos/holiday/beach.jpg", AlbumID: album.ID, Type: models.MediaTypePhoto},
+		{Title: "secret.jpg", Path: "/photos/other/secret.jpg", AlbumID: otherAlbum.ID, Type: models.MediaTypePhoto},
+	}
+	if !assert.NoError(t, db.Create(&media).Error) {
+		return
+	}
+
+	mediaURLs := []models.MediaURL{
+		{MediaID: media[0].ID, MediaName: "beach_thumb.jpg", Purpose: models.PhotoThumbnail, ContentType: "image/jpeg"},
+		{MediaID: media[0].ID, MediaName: "beach.jpg", Purpose: models.MediaOriginal, ContentType: "image/jpeg"},
+	}
+	if !assert.NoError(t, db.Create(&mediaURLs).Error) {
+		return
+	}
+
+	accessToken, err := user.GenerateAccessToken(db)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	router := mux.NewRouter()
+	router.Use(dataloader.Middleware(db))
+	RegisterRESTRoutes(db, router.PathPrefix("/rest/v1").Subrouter())
+	RegisterPhotoRoutes(db, router.PathPrefix("/photo").Subrouter())
+
+	request := func(method string, url string, body string, authorized bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if authorized {
+			req.Header.Set("Authorization", "Bearer "+accessToken.Value)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		rr := request(http.MethodGet, "/rest/v1/albums", "", false)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.JSONEq(t, `{"error": "authorization required"}`, rr.Body.String())
+	})
+
+	t.Run("List albums", func(t *testing.T) {
+		rr := request(http.MethodGet, "/rest/v1/albums?showEmpty=true", "", true)
+		if !assert.Equal(t, http.StatusOK, rr.Code) {
+			return
+		}
+
+		var albums []restAlbum
+		if assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &albums)) && assert.Len(t, albums, 1) {
+			assert.Equal(t, "Holiday", albums[0].Title)
+		}
+	})
+
+	t.Run("Get album", func(t *testing.T) {
+		rr := request(http.MethodGet, "/rest/v1/albums/"+strconv.Itoa(album.ID), "", true)
+		if !assert.Equal(t, http.StatusOK, rr.Code) {
+			return
+		}
+
+		var details restAlbumDetails
+		if assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &details)) && assert.Len(t, details.Media, 1) {
+			assert.Equal(t, "Holiday", details.Title)
+			assert.Equal(t, "beach.jpg", details.Media[0].Title)
+		}
+
+		rr = request(http.MethodGet, "/rest/v1/albums/"+strconv.Itoa(otherAlbum.ID), "", true)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Get media", func(t *testing.T) {
+		rr := request(http.MethodGet, "/rest/v1/media/"+strconv.Itoa(media[0].ID), "", true)
+		if !assert.Equal(t, http.StatusOK, rr.Code) {
+			return
+		}
+
+		var result restMedia
+		if assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result)) {
+			assert.Equal(t, album.ID, result.AlbumID)
+			assert.Contains(t, result.ThumbnailURL, "/photo/beach_thumb.jpg")
+			assert.Contains(t, result.OriginalURL, "/photo/beach.jpg")
+			assert.Empty(t, result.HighResURL)
+		}
+
+		rr = request(http.MethodGet, "/rest/v1/media/"+strconv.Itoa(media[1].ID), "", true)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Fetch media url with bearer token", func(t *testing.T) {
+		photoPath := path.Join(t.TempDir(), "beach.jpg")
+		if !assert.NoError(t, os.WriteFile(photoPath, []byte("IMAGE DATA"), 0644)) {
+			return
+		}
+
+		if !assert.NoError(t, db.Model(&media[0]).Update("path", photoPath).Error) {
+			return
+		}
+
+		rr := request(http.MethodGet, "/rest/v1/media/"+strconv.Itoa(media[0].ID), "", true)
+		var result restMedia
+		if !assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result)) {
+			return
+		}
+
+		originalURL, err := url.Parse(result.OriginalURL)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		rr = request(http.MethodGet, originalURL.Path, "", true)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "IMAGE DATA", rr.Body.String())
+
+		rr = request(http.MethodGet, originalURL.Path, "", false)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("Share album", func(t *testing.T) {
+		rr := request(http.MethodPost, "/rest/v1/albums/"+strconv.Itoa(album.ID)+"/shares", `{"password": "secret"}`, true)
+		if !assert.Equal(t, http.StatusCreated, rr.Code) {
+			return
+		}
+
+		var share restShare
+		if assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &share)) {
+			assert.NotEmpty(t, share.Token)
+			assert.True(t, share.HasPassword)
+			assert.Contains(t, share.URL, "/share/"+share.Token)
+		}
+
+		rr = request(http.MethodPost, "/rest/v1/albums/"+strconv.Itoa(otherAlbum.ID)+"/shares", "", true)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Share media of other user", func(t *testing.T) {
+		rr := request(http.MethodPost, "/rest/v1/media/"+strconv.Itoa(media[1].ID)+"/shares", "", true)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("OpenAPI document", func(t *testing.T) {
+		rr := request(http.MethodGet, "/rest/v1/openapi.json", "", false)
+		if !assert.Equal(t, http.StatusOK, rr.Code) {
+			return
+		}
+
+		var document struct {
+			OpenAPI string                            `json:"openapi"`
+			Paths   map[string]map[string]interface{} `json:"paths"`
+			Servers []struct {
+				URL string `json:"url"`
+			} `json:"servers"`
+			Components struct {
+				Schemas map[string]interface{} `json:"schemas"`
+			} `json:"components"`
+		}
+		if !assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &document)) {
+			return
+		}
+
+		assert.Equal(t, "3.0.3", document.OpenAPI)
+		assert.Contains(t, document.Paths["/albums/{id}/shares"], "post")
+		assert.Contains(t, document.Paths["/media/{id}"], "get")
+		assert.Contains(t, document.Components.Schemas, "AlbumDetails")
+		assert.Contains(t, document.Components.Schemas, "Media")
+		if assert.Len(t, document.Servers, 1) {
+			assert.Equal(t, "http://example.com/rest/v1", document.Servers[0].URL)
+		}
+	})
+}
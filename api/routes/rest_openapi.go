@@ -0,0 +1,229 @@
+package routes
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var restPathParameterRegex = regexp.MustCompile(`{([^}]+)}`)
+
+// openAPIDocument generates an OpenAPI 3 description of the REST endpoints,
+// the schemas are derived from the json tags of the request and response types
+func openAPIDocument(endpoints []restEndpoint, serverURL string) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+
+	for _, endpoint := range endpoints {
+		pathItem, ok := paths[endpoint.path].(map[string]interface{})
+		if !ok {
+			pathItem = make(map[string]interface{})
+			paths[endpoint.path] = pathItem
+		}
+
+		parameters := make([]interface{}, 0)
+		for _, match := range restPathParameterRegex.FindAllStringSubmatch(endpoint.path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "integer"},
+			})
+		}
+
+		for _, param := range endpoint.query {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.name,
+				"in":          "query",
+				"description": param.description,
+				"schema":      map[string]interface{}{"type": param.schemaType},
+			})
+		}
+
+		operation := map[string]interface{}{
+			"summary":    endpoint.summary,
+			"parameters": parameters,
+		}
+
+		if endpoint.request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": openAPISchema(reflect.TypeOf(endpoint.request), schemas),
+					},
+				},
+			}
+		}
+
+		if endpoint.upload {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"multipart/form-data": map[string]interface{}{
+						"schema": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"files": map[string]interface{}{
+									"type":  "array",
+									"items": map[string]interface{}{"type": "string", "format": "binary"},
+								},
+							},
+						},
+					},
+				},
+			}
+		}
+
+		errorResponse := map[string]interface{}{
+			"description": "Error",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": openAPISchema(reflect.TypeOf(restErrorResponse{}), schemas),
+				},
+			},
+		}
+
+		operation["responses"] = map[string]interface{}{
+			strconv.Itoa(endpoint.status): map[string]interface{}{
+				"description": http.StatusText(endpoint.status),
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": openAPISchema(reflect.TypeOf(endpoint.response), schemas),
+					},
+				},
+			},
+			"default": errorResponse,
+		}
+
+		pathItem[strings.ToLower(endpoint.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Photoview REST API",
+			"description": "REST interface for the most common operations of Photoview, the complete API is available through GraphQL.",
+			"version":     "1.0.0",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": serverURL},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"bearerAuth": []string{}},
+			map[string]interface{}{"cookieAuth": []string{}},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "An access token obtained from the authorizeUser GraphQL mutation",
+				},
+				"cookieAuth": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": "auth-token",
+				},
+			},
+		},
+	}
+}
+
+// openAPISchema returns the schema of a go type, named struct types are added to the components
+// and referenced, to keep the document readable
+func openAPISchema(t reflect.Type, components map[string]interface{}) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		schema := openAPISchema(t.Elem(), components)
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": openAPISchema(t.Elem(), components)}
+	case reflect.Struct:
+		name := openAPISchemaName(t)
+		if _, exists := components[name]; !exists {
+			// Reserve the name before recursing, in case of self referencing types
+			components[name] = nil
+			components[name] = openAPIObject(t, components)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func openAPIObject(t reflect.Type, components map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			// Fields of embedded structs are flattened into the object, like encoding/json does
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				addFields(field.Type)
+				continue
+			}
+
+			tag := field.Tag.Get("json")
+			if tag == "-" || !field.IsExported() {
+				continue
+			}
+
+			name, options, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = field.Name
+			}
+
+			properties[name] = openAPISchema(field.Type, components)
+			if field.Type.Kind() != reflect.Ptr && !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+// openAPISchemaName turns the name of a go type into a schema name, such as restAlbumDetails into AlbumDetails
+func openAPISchemaName(t reflect.Type) string {
+	name := strings.TrimPrefix(t.Name(), "rest")
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
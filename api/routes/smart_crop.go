@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/photoview/photoview/api/graphql/models"
+)
+
+// smartCropSampleSize is the size images are scaled down to, to find their most detailed part
+const smartCropSampleSize = 64
+
+// smartCropRect returns the largest rectangle of the aspect ratio of width to height inside the image,
+// placed over the faces in it, or over its most detailed part if it has none, instead of its center.
+// The faces are relative to the size of the image.
+func smartCropRect(img image.Image, width, height int, faces []models.FaceRectangle) image.Rectangle {
+	bounds := img.Bounds()
+
+	// The crop is moved along the dimension the image is wider or taller than the aspect ratio in
+	horizontal := bounds.Dx()*height > bounds.Dy()*width
+	cropSize, imageSize := bounds.Dy(), bounds.Dx()
+	if horizontal {
+		cropSize = int(math.Round(float64(bounds.Dy()*width) / float64(height)))
+	} else {
+		cropSize, imageSize = int(math.Round(float64(bounds.Dx()*height)/float64(width))), bounds.Dy()
+	}
+	if cropSize < 1 {
+		cropSize = 1
+	}
+
+	maxOffset := imageSize - cropSize
+	var offset int
+	if maxOffset > 0 {
+		if len(faces) > 0 {
+			offset = faceCropOffset(faces, horizontal, imageSize, cropSize)
+		} else {
+			offset = entropyCropOffset(img, horizontal, imageSize, cropSize)
+		}
+
+		if offset < 0 {
+			offset = 0
+		} else if offset > maxOffset {
+			offset = maxOffset
+		}
+	}
+
+	if horizontal {
+		return image.Rect(bounds.Min.X+offset, bounds.Min.Y, bounds.Min.X+offset+cropSize, bounds.Max.Y)
+	}
+	return image.Rect(bounds.Min.X, bounds.Min.Y+offset, bounds.Max.X, bounds.Min.Y+offset+cropSize)
+}
+
+// faceCropOffset centers the crop on the faces. If they don't all fit, it starts at the upper or leftmost of them,
+// so the heads of people in a group are not cut in half.
+func faceCropOffset(faces []models.FaceRectangle, horizontal bool, imageSize, cropSize int) int {
+	minEdge, maxEdge := math.Inf(1), math.Inf(-1)
+	for _, face := range faces {
+		low, high := face.MinY, face.MaxY
+		if horizontal {
+			low, high = face.MinX, face.MaxX
+		}
+
+		minEdge = math.Min(minEdge, low)
+		maxEdge = math.Max(maxEdge, high)
+	}
+
+	low, high := minEdge*float64(imageSize), maxEdge*float64(imageSize)
+	if high-low > float64(cropSize) {
+		return int(math.Round(low))
+	}
+
+	return int(math.Round((low+high)/2 - float64(cropSize)/2))
+}
+
+// entropyCropOffset places the crop where the brightness of the image varies the most, which is where its subject usually is,
+// rather than over an even sky or background. Unless another part of the image is more detailed, it is kept in the center.
+func entropyCropOffset(img image.Image, horizontal bool, imageSize, cropSize int) int {
+	sample := imaging.Grayscale(imaging.Fit(img, smartCropSampleSize, smartCropSampleSize, imaging.Box))
+	sampleBounds := sample.Bounds()
+
+	sampleSize := sampleBounds.Dy()
+	if horizontal {
+		sampleSize = sampleBounds.Dx()
+	}
+	sampleCrop := int(math.Round(float64(cropSize) * float64(sampleSize) / float64(imageSize)))
+	if sampleCrop < 1 {
+		sampleCrop = 1
+	}
+
+	maxOffset, sampleMaxOffset := imageSize-cropSize, sampleSize-sampleCrop
+	if sampleMaxOffset <= 0 {
+		return maxOffset / 2
+	}
+
+	window := func(offset int) image.Rectangle {
+		if horizontal {
+			return image.Rect(offset, 0, offset+sampleCrop, sampleBounds.Dy())
+		}
+		return image.Rect(0, offset, sampleBounds.Dx(), offset+sampleCrop)
+	}
+
+	centerEntropy := grayEntropy(sample, window(sampleMaxOffset/2))
+	best, bestEntropy := -1, centerEntropy+1e-9
+	for offset := 0; offset <= sampleMaxOffset; offset++ {
+		if entropy := grayEntropy(sample, window(offset)); entropy > bestEntropy {
+			best, bestEntropy = offset, entropy
+		}
+	}
+
+	if best < 0 {
+		return maxOffset / 2
+	}
+	return int(math.Round(float64(best) * float64(maxOffset) / float64(sampleMaxOffset)))
+}
+
+// grayEntropy returns the Shannon entropy of the brightness of a rectangle of a grayscale image
+func grayEntropy(img *image.NRGBA, rect image.Rectangle) float64 {
+	var bins [256]int
+	total := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			bins[img.Pix[img.PixOffset(x, y)]]++
+			total++
+		}
+	}
+
+	var entropy float64
+	for _, count := range bins {
+		if count > 0 {
+			p := float64(count) / float64(total)
+			entropy -= p * math.Log2(p)
+		}
+	}
+
+	return entropy
+}
+
+// mediaFaceRectangles returns the rectangles of the faces detected in a media
+func mediaFaceRectangles(db *gorm.DB, mediaID int) ([]models.FaceRectangle, error) {
+	var faces []*models.ImageFace
+	if err := db.Select("rectangle").Where("media_id = ?", mediaID).Order("id").Find(&faces).Error; err != nil {
+		return nil, errors.Wrap(err, "get faces of media")
+	}
+
+	rectangles := make([]models.FaceRectangle, len(faces))
+	for i, face := range faces {
+		rectangles[i] = face.Rectangle
+	}
+
+	return rectangles, nil
+}
@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmartCropRectEntropy(t *testing.T) {
+	// A wide image of an even sky, with a detailed subject on the right
+	img := imaging.New(900, 300, color.NRGBA{120, 160, 220, 255})
+	random := rand.New(rand.NewSource(1))
+	for y := 0; y < 300; y++ {
+		for x := 600; x < 900; x++ {
+			value := uint8(random.Intn(256))
+			img.SetNRGBA(x, y, color.NRGBA{value, value, value, 255})
+		}
+	}
+
+	crop := smartCropRect(img, 100, 100, nil)
+	assert.Equal(t, 300, crop.Dx())
+	assert.GreaterOrEqual(t, crop.Min.X, 560, "the crop is over the subject")
+
+	// Even images are cropped in the center
+	even := imaging.New(300, 900, color.White)
+	assert.Equal(t, image.Rect(0, 300, 300, 600), smartCropRect(even, 100, 100, nil))
+
+	// Images of the aspect ratio are not cropped
+	assert.Equal(t, image.Rect(0, 0, 300, 900), smartCropRect(even, 100, 300, nil))
+}
+
+func TestSmartCropRectFaces(t *testing.T) {
+	img := imaging.New(400, 1200, color.White)
+
+	// The crop is placed over a face at the top of a tall photo
+	face := models.FaceRectangle{MinX: 0.3, MaxX: 0.6, MinY: 0.05, MaxY: 0.15}
+	assert.Equal(t, image.Rect(0, 0, 400, 400), smartCropRect(img, 100, 100, []models.FaceRectangle{face}))
+
+	// Faces are kept together
+	lower := models.FaceRectangle{MinX: 0.3, MaxX: 0.6, MinY: 0.4, MaxY: 0.5}
+	middle := models.FaceRectangle{MinX: 0.3, MaxX: 0.6, MinY: 0.3, MaxY: 0.4}
+	assert.Equal(t, image.Rect(0, 280, 400, 680), smartCropRect(img, 100, 100, []models.FaceRectangle{lower, middle}))
+
+	// Faces that don't fit together are cropped from the top one
+	assert.Equal(t, image.Rect(0, 60, 400, 460), smartCropRect(img, 100, 100, []models.FaceRectangle{lower, face}))
+}
+
+func TestResizeImageSmartCrop(t *testing.T) {
+	img := imaging.New(1200, 400, color.Black)
+	face := models.FaceRectangle{MinX: 0.85, MaxX: 0.95, MinY: 0.4, MaxY: 0.6}
+	for y := 160; y < 240; y++ {
+		for x := 1020; x < 1140; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+		}
+	}
+
+	resized := imaging.Clone(resizeImage(img, resizeOptions{width: 100, height: 100, cover: true, faces: []models.FaceRectangle{face}}))
+	assert.Equal(t, image.Rect(0, 0, 100, 100), resized.Bounds())
+	assert.Equal(t, uint8(255), resized.NRGBAAt(75, 50).R, "the face is in the thumbnail")
+}
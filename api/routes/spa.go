@@ -1,52 +1,68 @@
 package routes
 
 import (
+	"bytes"
+	"io/fs"
 	"net/http"
-	"os"
 	"path"
-	"path/filepath"
+	"strings"
 )
 
 // SpaHandler implements the http.Handler interface, so we can use it
-// to respond to HTTP requests. The path to the static directory and
-// path to the index file within that static directory are used to
-// serve the SPA in the given static directory.
+// to respond to HTTP requests. The file system of the static files and
+// path to the index file within it are used to serve the SPA,
+// either from a directory or from the assets embedded into the binary.
 type SpaHandler struct {
-	staticPath string
-	indexPath  string
+	staticFS  fs.FS
+	indexPath string
 }
 
-func NewSpaHandler(staticPath string, indexPath string) SpaHandler {
+func NewSpaHandler(staticFS fs.FS, indexPath string) SpaHandler {
 	return SpaHandler{
-		indexPath:  indexPath,
-		staticPath: staticPath,
+		indexPath: indexPath,
+		staticFS:  staticFS,
 	}
 }
 
-// ServeHTTP inspects the URL path to locate a file within the static dir
-// on the SPA handler. If a file is found, it will be served. If not, the
-// file located at the index path on the SPA handler will be served. This
+// Directory of the files built by vite, which are named by the hash of their content
+const hashedAssetsDir = "assets/"
+
+// ServeHTTP inspects the URL path to locate a file within the static files
+// of the SPA handler. If a file is found, it will be served. If not, the
+// index file of the SPA handler will be served. This
 // is suitable behavior for serving an SPA (single page application).
 func (h SpaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// get the clean path to prevent directory traversal
-	servePath := path.Clean(r.URL.Path)
-
-	// prepend the path with the path to the static directory
-	servePath = filepath.Join(h.staticPath, servePath)
+	servePath := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if servePath == "" {
+		servePath = h.indexPath
+	}
 
-	// check whether a file exists at the given path
-	_, err := os.Stat(servePath)
-	if os.IsNotExist(err) {
-		// file does not exist, serve index.html
-		http.ServeFile(w, r, filepath.Join(h.staticPath, h.indexPath))
+	// check whether a file exists at the given path, if not serve index.html
+	fileInfo, err := fs.Stat(h.staticFS, servePath)
+	if err != nil || fileInfo.IsDir() {
+		servePath = h.indexPath
+		fileInfo, err = fs.Stat(h.staticFS, servePath)
+	}
+	if err != nil {
+		http.Error(w, "ui has not been built", http.StatusNotFound)
 		return
-	} else if err != nil {
-		// if we got an error (that wasn't that the file doesn't exist) stating the
-		// file, return a 500 internal server error and stop
+	}
+
+	content, err := fs.ReadFile(h.staticFS, servePath)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// otherwise, use http.FileServer to serve the static dir
-	http.FileServer(http.Dir(h.staticPath)).ServeHTTP(w, r)
+	// Hashed files never change, everything else, such as index.html and the service worker, is revalidated on every request,
+	// so a new version of the ui is picked up right away
+	if strings.HasPrefix(servePath, hashedAssetsDir) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	// Embedded files have no modification time, so only files served from a directory are revalidated by it
+	http.ServeContent(w, r, servePath, fileInfo.ModTime(), bytes.NewReader(content))
 }
@@ -0,0 +1,52 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/photoview/photoview/api/routes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpaHandler(t *testing.T) {
+	uiFS := fstest.MapFS{
+		"index.html":             {Data: []byte("<html>index</html>")},
+		"assets/index-a1b2c3.js": {Data: []byte("console.log('ui')")},
+		"service-worker.js":      {Data: []byte("self.addEventListener()")},
+	}
+
+	handler := http.StripPrefix("/photos", routes.NewSpaHandler(uiFS, "index.html"))
+
+	tests := []struct {
+		name         string
+		url          string
+		body         string
+		cacheControl string
+	}{
+		{"Index", "/photos/", "<html>index</html>", "no-cache"},
+		{"Routes of the ui serve the index", "/photos/album/12", "<html>index</html>", "no-cache"},
+		{"Hashed assets are cached", "/photos/assets/index-a1b2c3.js", "console.log('ui')", "public, max-age=31536000, immutable"},
+		{"Other files are revalidated", "/photos/service-worker.js", "self.addEventListener()", "no-cache"},
+		{"Directories serve the index", "/photos/assets", "<html>index</html>", "no-cache"},
+		{"Paths outside the ui serve the index", "/photos/../../etc/passwd", "<html>index</html>", "no-cache"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", test.url, nil))
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, test.body, rr.Body.String())
+			assert.Equal(t, test.cacheControl, rr.Header().Get("Cache-Control"))
+		})
+	}
+
+	t.Run("Missing ui", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		routes.NewSpaHandler(fstest.MapFS{}, "index.html").ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
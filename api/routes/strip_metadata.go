@@ -0,0 +1,320 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"gorm.io/gorm"
+)
+
+// canStripMetadata returns whether metadata can be removed from images of the content type without encoding them again.
+// GIF images are included, as they have no place for EXIF data.
+func canStripMetadata(contentType string) bool {
+	switch media_type.MediaType(contentType) {
+	case media_type.TypeJpeg, media_type.TypePng, media_type.TypeWebp, media_type.TypeGif:
+		return true
+	}
+
+	return false
+}
+
+// stripMetadata writes an image without its EXIF, XMP, IPTC and text metadata, which include GPS coordinates
+// and the serial numbers of cameras. The pixels are copied as they are, and the orientation of JPEG images is kept.
+func stripMetadata(out io.Writer, data []byte, contentType string) error {
+	switch media_type.MediaType(contentType) {
+	case media_type.TypeJpeg:
+		return stripJPEGMetadata(out, data)
+	case media_type.TypePng:
+		return stripPNGMetadata(out, data)
+	case media_type.TypeWebp:
+		return stripWebPMetadata(out, data)
+	case media_type.TypeGif:
+		_, err := out.Write(data)
+		return err
+	}
+
+	return errors.Errorf("can not strip metadata of %s images", contentType)
+}
+
+const (
+	jpegMarkerSOI  = 0xd8
+	jpegMarkerSOS  = 0xda
+	jpegMarkerAPP0 = 0xe0
+	jpegMarkerAPP1 = 0xe1
+	jpegMarkerAPP2 = 0xe2
+	// APP14 is written by Adobe, it is needed to decode the colors of CMYK images
+	jpegMarkerAPP14 = 0xee
+	jpegMarkerAPP15 = 0xef
+	jpegMarkerCOM   = 0xfe
+)
+
+func stripJPEGMetadata(out io.Writer, data []byte) error {
+	if len(data) < 2 || data[0] != 0xff || data[1] != jpegMarkerSOI {
+		return errors.New("not a JPEG image")
+	}
+
+	var head, segments bytes.Buffer
+	head.Write(data[:2])
+	orientation := 1
+
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xff {
+			return errors.New("invalid JPEG segment")
+		}
+
+		marker := data[i+1]
+		if marker == 0xff {
+			// Fill byte before a marker
+			i++
+			continue
+		}
+
+		if marker == jpegMarkerSOS {
+			segments.Write(data[i:])
+			break
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			return errors.New("truncated JPEG segment")
+		}
+		segment := data[i:end]
+		payload := segment[4:]
+
+		switch {
+		case marker == jpegMarkerAPP0 && head.Len() == 2 && segments.Len() == 0:
+			// The JFIF header must follow the start of the image
+			head.Write(segment)
+		case marker == jpegMarkerAPP1:
+			if bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(payload[6:])
+			}
+		case marker == jpegMarkerAPP2:
+			if bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")) {
+				segments.Write(segment)
+			}
+		case marker == jpegMarkerAPP14:
+			segments.Write(segment)
+		case marker >= jpegMarkerAPP0 && marker <= jpegMarkerAPP15, marker == jpegMarkerCOM:
+			// Other application data and comments are removed
+		default:
+			segments.Write(segment)
+		}
+
+		i = end
+	}
+
+	if orientation != 1 {
+		head.Write(orientationEXIFSegment(orientation))
+	}
+
+	if _, err := out.Write(head.Bytes()); err != nil {
+		return err
+	}
+	_, err := out.Write(segments.Bytes())
+	return err
+}
+
+// exifOrientation reads the orientation of the first directory of EXIF data, 1 if it has none
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for entry := 0; entry < count; entry++ {
+		start := offset + 2 + entry*12
+		if start+12 > len(tiff) {
+			break
+		}
+
+		if order.Uint16(tiff[start:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[start+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+
+	return 1
+}
+
+// orientationEXIFSegment returns an APP1 segment with EXIF data holding only the orientation
+func orientationEXIFSegment(orientation int) []byte {
+	// A big endian TIFF header, and a directory of one entry: the orientation tag of one short
+	payload := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+	binary.BigEndian.PutUint16(payload[24:], uint16(orientation))
+
+	segment := []byte{0xff, jpegMarkerAPP1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// Chunks of PNG images holding metadata
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNGMetadata(out io.Writer, data []byte) error {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return errors.New("not a PNG image")
+	}
+
+	var stripped bytes.Buffer
+	stripped.WriteString(signature)
+
+	for i := len(signature); i < len(data); {
+		if i+8 > len(data) {
+			return errors.New("invalid PNG chunk")
+		}
+
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return errors.New("truncated PNG chunk")
+		}
+
+		chunkType := string(data[i+4 : i+8])
+		if !pngMetadataChunks[chunkType] {
+			stripped.Write(data[i:end])
+		}
+
+		i = end
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	_, err := out.Write(stripped.Bytes())
+	return err
+}
+
+// Flags of the VP8X chunk of WebP images, telling which metadata chunks the image has
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+func stripWebPMetadata(out io.Writer, data []byte) error {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return errors.New("not a WebP image")
+	}
+
+	var stripped bytes.Buffer
+	stripped.Write(data[:12])
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return errors.New("invalid WebP chunk")
+		}
+
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if end > len(data) || end < i {
+			return errors.New("truncated WebP chunk")
+		}
+
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+			stripped.Write(chunk)
+		default:
+			stripped.Write(data[i:end])
+		}
+
+		i = end
+	}
+
+	result := stripped.Bytes()
+	binary.LittleEndian.PutUint32(result[4:], uint32(len(result)-8))
+
+	_, err := out.Write(result)
+	return err
+}
+
+// strippableMediaURL returns the media url to serve instead of an original in a format metadata can't be removed from,
+// which is its web version, or nil if it has none. Other media urls are generated without metadata, and are served as they are.
+func strippableMediaURL(db *gorm.DB, mediaURL *models.MediaURL) (*models.MediaURL, error) {
+	if mediaURL.Purpose != models.MediaOriginal || canStripMetadata(mediaURL.ContentType) {
+		return mediaURL, nil
+	}
+
+	return highResMediaURL(db, mediaURL)
+}
+
+// highResMediaURL returns the web version of the photo of a media url, or nil if it has none
+func highResMediaURL(db *gorm.DB, mediaURL *models.MediaURL) (*models.MediaURL, error) {
+	var highRes models.MediaURL
+	if err := db.Where("media_id = ? AND purpose = ?", mediaURL.MediaID, models.PhotoHighRes).Limit(1).Find(&highRes).Error; err != nil {
+		return nil, errors.Wrap(err, "get web version of photo")
+	}
+	if highRes.ID == 0 {
+		return nil, nil
+	}
+
+	highRes.Media = mediaURL.Media
+	return &highRes, nil
+}
+
+// serveStrippedPhoto writes a photo without its metadata
+func serveStrippedPhoto(w http.ResponseWriter, r *http.Request, mediaURL *models.MediaURL, filePath string) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Error(r.Context(), "Reading photo to strip metadata", "path", filePath, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	var stripped bytes.Buffer
+	if err := stripMetadata(&stripped, data, mediaURL.ContentType); err != nil {
+		log.Error(r.Context(), "Stripping metadata of photo", "path", filePath, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		log.Error(r.Context(), "Reading file stats of photo", "path", filePath, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaURL.ContentType)
+	http.ServeContent(w, r, path.Base(filePath), fileInfo.ModTime(), bytes.NewReader(stripped.Bytes()))
+}
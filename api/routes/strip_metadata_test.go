@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/photoview/photoview/api/scanner/exif"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripJPEGMetadata(t *testing.T) {
+	data, err := os.ReadFile("../scanner/exif/test_data/bird.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var stripped bytes.Buffer
+	if !assert.NoError(t, stripMetadata(&stripped, data, "image/jpeg")) {
+		return
+	}
+
+	strippedPath := path.Join(t.TempDir(), "bird.jpg")
+	if !assert.NoError(t, os.WriteFile(strippedPath, stripped.Bytes(), 0644)) {
+		return
+	}
+
+	original, err := exif.NewInternalExifParser().ParseExif("../scanner/exif/test_data/bird.jpg")
+	if assert.NoError(t, err) {
+		assert.NotNil(t, original.GPSLatitude)
+	}
+
+	parsed, err := exif.NewInternalExifParser().ParseExif(strippedPath)
+	if assert.NoError(t, err) && parsed != nil {
+		assert.Nil(t, parsed.GPSLatitude)
+		assert.Nil(t, parsed.Camera)
+	}
+
+	// The pixels are left as they were
+	originalImage, err := jpeg.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	strippedImage, err := jpeg.Decode(bytes.NewReader(stripped.Bytes()))
+	if assert.NoError(t, err) {
+		assert.Equal(t, originalImage, strippedImage)
+	}
+}
+
+func TestStripJPEGMetadataKeepsOrientation(t *testing.T) {
+	var encoded bytes.Buffer
+	if !assert.NoError(t, jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8)), nil)) {
+		return
+	}
+
+	// EXIF data with an orientation of 6, and a comment, are inserted after the start of the image
+	exifSegment := orientationEXIFSegment(6)
+	comment := []byte{0xff, jpegMarkerCOM, 0x00, 0x07, 'h', 'o', 'm', 'e', '!'}
+	data := append([]byte{0xff, jpegMarkerSOI}, exifSegment...)
+	data = append(data, comment...)
+	data = append(data, encoded.Bytes()[2:]...)
+
+	var stripped bytes.Buffer
+	if !assert.NoError(t, stripMetadata(&stripped, data, "image/jpeg")) {
+		return
+	}
+
+	assert.NotContains(t, stripped.String(), "home!")
+	assert.True(t, bytes.Contains(stripped.Bytes(), exifSegment))
+	assert.Equal(t, 6, exifOrientation(exifSegment[10:]))
+
+	_, err := jpeg.Decode(bytes.NewReader(stripped.Bytes()))
+	assert.NoError(t, err)
+
+	// Images without an orientation get no EXIF data
+	stripped.Reset()
+	if assert.NoError(t, stripMetadata(&stripped, encoded.Bytes(), "image/jpeg")) {
+		assert.NotContains(t, stripped.String(), "Exif")
+	}
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	var encoded bytes.Buffer
+	if !assert.NoError(t, png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8)))) {
+		return
+	}
+
+	// A text chunk is inserted after the header chunk, which is 25 bytes long following the 8 byte signature
+	text := []byte("Comment\x00taken at home")
+	chunk := make([]byte, 4, len(text)+12)
+	binary.BigEndian.PutUint32(chunk, uint32(len(text)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, text...)
+	chunk = append(chunk, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(chunk[len(chunk)-4:], crc32.ChecksumIEEE(chunk[4:len(chunk)-4]))
+
+	data := append([]byte{}, encoded.Bytes()[:33]...)
+	data = append(data, chunk...)
+	data = append(data, encoded.Bytes()[33:]...)
+
+	var stripped bytes.Buffer
+	if !assert.NoError(t, stripMetadata(&stripped, data, "image/png")) {
+		return
+	}
+
+	assert.Equal(t, encoded.Bytes(), stripped.Bytes())
+}
+
+func TestStripWebPMetadata(t *testing.T) {
+	riffChunk := func(fourCC string, data []byte) []byte {
+		chunk := append([]byte(fourCC), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+		chunk = append(chunk, data...)
+		if len(data)%2 == 1 {
+			chunk = append(chunk, 0)
+		}
+		return chunk
+	}
+
+	vp8x := riffChunk("VP8X", []byte{webpFlagEXIF | webpFlagXMP, 0, 0, 0, 7, 0, 0, 7, 0, 0})
+	vp8 := riffChunk("VP8 ", []byte("image data"))
+
+	var chunks []byte
+	chunks = append(chunks, vp8x...)
+	chunks = append(chunks, vp8...)
+	chunks = append(chunks, riffChunk("EXIF", []byte("GPS coordinates"))...)
+	chunks = append(chunks, riffChunk("XMP ", []byte("<xmp/>"))...)
+
+	data := append([]byte("RIFF\x00\x00\x00\x00WEBP"), chunks...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+
+	var stripped bytes.Buffer
+	if !assert.NoError(t, stripMetadata(&stripped, data, "image/webp")) {
+		return
+	}
+
+	result := stripped.Bytes()
+	assert.Equal(t, uint32(len(result)-8), binary.LittleEndian.Uint32(result[4:]))
+	assert.Equal(t, byte(0), result[20], "metadata flags are cleared")
+	assert.Equal(t, vp8, result[12+len(vp8x):])
+	assert.NotContains(t, stripped.String(), "GPS")
+	assert.NotContains(t, stripped.String(), "xmp")
+}
+
+func TestCanStripMetadata(t *testing.T) {
+	assert.True(t, canStripMetadata("image/jpeg"))
+	assert.True(t, canStripMetadata("image/webp"))
+	assert.False(t, canStripMetadata("image/heic"))
+	assert.Error(t, stripMetadata(&bytes.Buffer{}, []byte("data"), "image/heic"))
+	assert.Error(t, stripMetadata(&bytes.Buffer{}, []byte("not a jpeg"), "image/jpeg"))
+}
@@ -0,0 +1,512 @@
+package routes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/scanner/scanner_queue"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Version of the tus resumable upload protocol, see https://tus.io/protocols/resumable-upload
+const tusVersion = "1.0.0"
+
+// Largest size in megabytes of uploads, if it has not been set
+const defaultMaxUploadSize = 4096
+
+var errUploadTooLarge = errors.New("upload is larger than the max upload size")
+
+type uploadedMedia struct {
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	Filename string `json:"filename"`
+}
+
+type uploadError struct {
+	Filename string `json:"filename"`
+	Error    string `json:"error"`
+}
+
+type uploadResponse struct {
+	Media  []uploadedMedia `json:"media"`
+	Errors []uploadError   `json:"errors"`
+}
+
+// RegisterUploadRoutes registers the endpoints for uploading media into an album,
+// either as a multipart form, or resumable in chunks using the tus protocol.
+func RegisterUploadRoutes(db *gorm.DB, router *mux.Router) {
+	router.Use(maintenanceMiddleware)
+
+	router.HandleFunc("/album/{album_id}", func(w http.ResponseWriter, r *http.Request) {
+		album, status, err := authenticateUpload(db, r, mux.Vars(r)["album_id"])
+		if err != nil {
+			w.WriteHeader(status)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if err := limitUploadBody(r); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		reader, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("expected multipart form"))
+			return
+		}
+
+		response := uploadResponse{
+			Media:  make([]uploadedMedia, 0),
+			Errors: make([]uploadError, 0),
+		}
+
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("invalid multipart form"))
+				return
+			}
+
+			if part.FileName() == "" {
+				continue
+			}
+
+			media, err := receiveMultipartFile(db, album, part.FileName(), part)
+			if err != nil {
+				log.Warn(r.Context(), "Upload failed", "filename", part.FileName(), "error", err)
+				response.Errors = append(response.Errors, uploadError{Filename: part.FileName(), Error: err.Error()})
+				continue
+			}
+
+			response.Media = append(response.Media, uploadedMedia{ID: media.ID, Title: media.Title, Filename: part.FileName()})
+		}
+
+		if len(response.Media) > 0 {
+			processUploadedMedia(r.Context(), album)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if len(response.Media) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(response)
+	}).Methods(http.MethodPost)
+
+	tusRouter := router.PathPrefix("/files").Subrouter()
+	tusRouter.Use(tusMiddleware)
+
+	tusRouter.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
+		metadata := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+
+		// Devices backing up their camera roll upload into their own album
+		var device *models.Device
+		var album *models.Album
+		var status int
+		var err error
+		if deviceID, ok := metadata["deviceId"]; ok {
+			device, album, status, err = authenticateDeviceUpload(db, r, deviceID)
+		} else {
+			album, status, err = authenticateUpload(db, r, metadata["albumId"])
+		}
+
+		if err != nil {
+			w.WriteHeader(status)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		var checksum *string
+		if value, ok := metadata["checksum"]; ok {
+			value = strings.ToLower(value)
+			checksum = &value
+
+			if device != nil {
+				existing, err := deviceAssetMedia(db, device, value)
+				if err != nil {
+					log.Error(r.Context(), "Checking if asset has been backed up", "error", err)
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte("internal server error"))
+					return
+				}
+
+				if existing != nil {
+					w.Header().Set("Photoview-Media-Id", strconv.Itoa(*existing))
+					w.WriteHeader(http.StatusConflict)
+					w.Write([]byte("file has already been backed up"))
+					return
+				}
+			}
+		}
+
+		size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil || size <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid Upload-Length header"))
+			return
+		}
+
+		if maxSize := maxUploadSize(); maxSize > 0 && size > maxSize {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(errUploadTooLarge.Error()))
+			return
+		}
+
+		filename, err := scanner.ValidUploadFilename(metadata["filename"])
+		if err != nil {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		session := models.UploadSession{
+			Token:    utils.GenerateToken() + utils.GenerateToken(),
+			UserID:   auth.UserFromContext(r.Context()).ID,
+			AlbumID:  album.ID,
+			Filename: filename,
+			Size:     size,
+			Checksum: checksum,
+		}
+
+		if device != nil {
+			session.DeviceID = &device.ID
+		}
+
+		if err := createPartialFile(&session); err != nil {
+			log.Error(r.Context(), "Creating upload file", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		if err := db.Create(&session).Error; err != nil {
+			log.Error(r.Context(), "Inserting upload session into database", "error", err)
+			os.Remove(session.PartialPath())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		w.Header().Set("Location", path.Join(r.URL.Path, session.Token))
+		w.WriteHeader(http.StatusCreated)
+	}).Methods(http.MethodPost)
+
+	tusRouter.HandleFunc("/{token}", func(w http.ResponseWriter, r *http.Request) {
+		session, status, err := uploadSessionFromRequest(db, r)
+		if err != nil {
+			w.WriteHeader(status)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeUploadSessionHeaders(w, session)
+		w.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodHead)
+
+	tusRouter.HandleFunc("/{token}", func(w http.ResponseWriter, r *http.Request) {
+		session, status, err := uploadSessionFromRequest(db, r)
+		if err != nil {
+			w.WriteHeader(status)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			w.Write([]byte("expected content type application/offset+octet-stream"))
+			return
+		}
+
+		offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil || offset != session.Offset || session.Completed() {
+			writeUploadSessionHeaders(w, session)
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte("upload offset does not match"))
+			return
+		}
+
+		// Chunks never go beyond the declared size of the upload, which is limited when it is created
+		r.Body = http.MaxBytesReader(w, r.Body, session.Size-session.Offset)
+		received, err := appendUploadChunk(session, r.Body)
+		session.Offset += received
+
+		if dbErr := db.Model(session).Update("offset", session.Offset).Error; dbErr != nil {
+			log.Error(r.Context(), "Updating upload session offset", "error", dbErr)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		if err != nil {
+			log.Warn(r.Context(), "Upload chunk interrupted", "filename", session.Filename, "error", err)
+			writeUploadSessionHeaders(w, session)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("upload chunk interrupted"))
+			return
+		}
+
+		if session.Completed() {
+			if err := verifyUploadChecksum(session); err != nil {
+				log.Warn(r.Context(), "Upload failed verification", "filename", session.Filename, "error", err)
+
+				// Start over, as it is unknown which part of the file is corrupt
+				session.Offset = 0
+				if err := db.Model(session).Update("offset", 0).Error; err != nil {
+					log.Error(r.Context(), "Resetting upload session offset", "error", err)
+				}
+				os.Truncate(session.PartialPath(), 0)
+
+				writeUploadSessionHeaders(w, session)
+				w.WriteHeader(statusChecksumMismatch)
+				w.Write([]byte("checksum mismatch"))
+				return
+			}
+
+			media, err := scanner.ImportMediaFile(db, session.Album, session.PartialPath(), session.Filename)
+			if err != nil {
+				log.Error(r.Context(), "Importing uploaded media", "filename", session.Filename, "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("could not import uploaded media"))
+				return
+			}
+
+			session.MediaID = &media.ID
+			if err := db.Model(session).Update("media_id", media.ID).Error; err != nil {
+				log.Error(r.Context(), "Updating upload session media", "error", err)
+			}
+
+			if session.DeviceID != nil {
+				if err := recordDeviceAsset(db, session, media); err != nil {
+					log.Error(r.Context(), "Recording backed up device asset", "error", err)
+				}
+			}
+
+			processUploadedMedia(r.Context(), session.Album)
+		}
+
+		writeUploadSessionHeaders(w, session)
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods(http.MethodPatch)
+
+	tusRouter.HandleFunc("/{token}", func(w http.ResponseWriter, r *http.Request) {
+		session, status, err := uploadSessionFromRequest(db, r)
+		if err != nil {
+			w.WriteHeader(status)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if !session.Completed() {
+			os.Remove(session.PartialPath())
+		}
+
+		if err := db.Delete(session).Error; err != nil {
+			log.Error(r.Context(), "Deleting upload session", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods(http.MethodDelete)
+}
+
+func tusMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", "creation,termination")
+		if maxSize := maxUploadSize(); maxSize > 0 {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(maxSize, 10))
+		}
+
+		if r.Header.Get("Tus-Resumable") != tusVersion {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxUploadSize returns the largest size in bytes of an upload, or 0 if uploads are not limited
+func maxUploadSize() int64 {
+	return int64(utils.EnvMaxUploadSize.GetNumber(defaultMaxUploadSize, 0, 1048576)) * 1024 * 1024
+}
+
+// limitUploadBody limits the body of a multipart upload to the max upload size, reading it fails once it goes beyond it.
+// An error is returned if the request declares a larger body up front.
+func limitUploadBody(r *http.Request) error {
+	maxSize := maxUploadSize()
+	if maxSize == 0 {
+		return nil
+	}
+
+	if r.ContentLength > maxSize {
+		return errUploadTooLarge
+	}
+
+	r.Body = http.MaxBytesReader(nil, r.Body, maxSize)
+	return nil
+}
+
+// authenticateUpload makes sure the request is made by a logged in user, owning the album with the given id.
+// Share tokens can not be used to upload media.
+func authenticateUpload(db *gorm.DB, r *http.Request, albumID string) (*models.Album, int, error) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return nil, http.StatusUnauthorized, auth.ErrUnauthorized
+	}
+
+	var album models.Album
+	if err := db.First(&album, "id = ?", albumID).Error; err != nil {
+		return nil, http.StatusNotFound, errors.New("album not found")
+	}
+
+	ownsAlbum, err := user.OwnsAlbum(db, &album)
+	if err != nil {
+		log.Error(r.Context(), "Checking album owner for upload", "error", err)
+		return nil, http.StatusInternalServerError, errors.New("internal server error")
+	}
+
+	if !ownsAlbum {
+		return nil, http.StatusForbidden, errors.New("invalid credentials")
+	}
+
+	return &album, http.StatusOK, nil
+}
+
+func uploadSessionFromRequest(db *gorm.DB, r *http.Request) (*models.UploadSession, int, error) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return nil, http.StatusUnauthorized, auth.ErrUnauthorized
+	}
+
+	var session models.UploadSession
+	err := db.Joins("Album").Where("upload_sessions.token = ?", mux.Vars(r)["token"]).Where("upload_sessions.user_id = ?", user.ID).First(&session).Error
+	if err != nil {
+		return nil, http.StatusNotFound, errors.New("upload not found")
+	}
+
+	return &session, http.StatusOK, nil
+}
+
+func writeUploadSessionHeaders(w http.ResponseWriter, session *models.UploadSession) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.Size, 10))
+
+	if session.MediaID != nil {
+		w.Header().Set("Photoview-Media-Id", strconv.Itoa(*session.MediaID))
+	}
+}
+
+// parseTusMetadata decodes the Upload-Metadata header, a comma separated list of keys and base64 encoded values
+func parseTusMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 {
+			continue
+		}
+
+		value := ""
+		if len(fields) > 1 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		}
+
+		metadata[fields[0]] = value
+	}
+
+	return metadata
+}
+
+func createPartialFile(session *models.UploadSession) error {
+	if err := os.MkdirAll(path.Dir(session.PartialPath()), os.ModePerm); err != nil {
+		return err
+	}
+
+	file, err := os.Create(session.PartialPath())
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// appendUploadChunk appends the body to the partial upload file, never exceeding the declared size of the upload.
+// The number of bytes written is returned, even if the body was interrupted.
+func appendUploadChunk(session *models.UploadSession, body io.Reader) (int64, error) {
+	file, err := os.OpenFile(session.PartialPath(), os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(session.Offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return io.Copy(file, io.LimitReader(body, session.Size-session.Offset))
+}
+
+func receiveMultipartFile(db *gorm.DB, album *models.Album, filename string, content io.Reader) (*models.Media, error) {
+	if _, err := scanner.ValidUploadFilename(filename); err != nil {
+		return nil, err
+	}
+
+	uploadDir := path.Join(utils.MediaCachePath(), "uploads")
+	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	tmpFile, err := os.CreateTemp(uploadDir, "multipart_*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := io.Copy(tmpFile, content); err != nil {
+		tmpFile.Close()
+		return nil, errors.Wrap(err, "receive uploaded file")
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return nil, err
+	}
+
+	return scanner.ImportMediaFile(db, album, tmpFile.Name(), filename)
+}
+
+// processUploadedMedia queues the album to be scanned, generating thumbnails and metadata for the uploaded media
+func processUploadedMedia(ctx context.Context, album *models.Album) {
+	if err := scanner_queue.AddAlbumToQueue(ctx, album); err != nil {
+		log.Warn(ctx, "Could not queue album for scanning after upload", "error", err)
+	}
+}
@@ -0,0 +1,178 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadRoutes(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	user, err := models.RegisterUser(db, "username", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{
+		Title: "uploads",
+		Path:  t.TempDir(),
+	}
+
+	if !assert.NoError(t, db.Model(&user).Association("Albums").Append(&album)) {
+		return
+	}
+
+	router := mux.NewRouter()
+	RegisterUploadRoutes(db, router.PathPrefix("/upload").Subrouter())
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		req = req.WithContext(auth.AddUserToContext(req.Context(), user))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Multipart upload", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, _ := form.CreateFormFile("files", "photo.jpg")
+		part.Write([]byte("IMAGE DATA"))
+		part, _ = form.CreateFormFile("files", "notes.txt")
+		part.Write([]byte("TEXT"))
+		form.Close()
+
+		req := httptest.NewRequest("POST", fmt.Sprintf("/upload/album/%d", album.ID), body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		rec := serve(req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response uploadResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Len(t, response.Media, 1)
+		assert.Len(t, response.Errors, 1)
+
+		assert.FileExists(t, path.Join(album.Path, "photo.jpg"))
+		assert.NoFileExists(t, path.Join(album.Path, "notes.txt"))
+
+		var media models.Media
+		assert.NoError(t, db.First(&media, response.Media[0].ID).Error)
+		assert.Equal(t, album.ID, media.AlbumID)
+	})
+
+	t.Run("Multipart upload to album not owned", func(t *testing.T) {
+		otherAlbum := models.Album{Title: "other", Path: t.TempDir()}
+		assert.NoError(t, db.Save(&otherAlbum).Error)
+
+		req := httptest.NewRequest("POST", fmt.Sprintf("/upload/album/%d", otherAlbum.ID), strings.NewReader(""))
+		rec := serve(req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Resumable upload", func(t *testing.T) {
+		content := []byte("VIDEO DATA IN TWO CHUNKS")
+		metadata := fmt.Sprintf("filename %s,albumId %s",
+			base64.StdEncoding.EncodeToString([]byte("photo.jpg")),
+			base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(album.ID))))
+
+		req := httptest.NewRequest("POST", "/upload/files", nil)
+		req.Header.Set("Tus-Resumable", tusVersion)
+		req.Header.Set("Upload-Length", fmt.Sprint(len(content)))
+		req.Header.Set("Upload-Metadata", metadata)
+		rec := serve(req)
+
+		if !assert.Equal(t, http.StatusCreated, rec.Code) {
+			return
+		}
+		location := rec.Header().Get("Location")
+
+		patch := func(offset int, chunk []byte) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("PATCH", location, bytes.NewReader(chunk))
+			req.Header.Set("Tus-Resumable", tusVersion)
+			req.Header.Set("Content-Type", "application/offset+octet-stream")
+			req.Header.Set("Upload-Offset", fmt.Sprint(offset))
+			return serve(req)
+		}
+
+		rec = patch(0, content[:10])
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "10", rec.Header().Get("Upload-Offset"))
+
+		rec = patch(5, content[5:])
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		req = httptest.NewRequest("HEAD", location, nil)
+		req.Header.Set("Tus-Resumable", tusVersion)
+		rec = serve(req)
+		assert.Equal(t, "10", rec.Header().Get("Upload-Offset"))
+
+		rec = patch(10, content[10:])
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("Photoview-Media-Id"))
+
+		// A file with the same name was uploaded in the multipart test
+		uploaded, err := os.ReadFile(path.Join(album.Path, "photo (1).jpg"))
+		assert.NoError(t, err)
+		assert.Equal(t, content, uploaded)
+	})
+
+	t.Run("Uploads larger than the max upload size", func(t *testing.T) {
+		t.Setenv(utils.EnvMaxUploadSize.GetName(), "1")
+
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, _ := form.CreateFormFile("files", "large.jpg")
+		part.Write(bytes.Repeat([]byte("X"), 2*1024*1024))
+		form.Close()
+
+		req := httptest.NewRequest("POST", fmt.Sprintf("/upload/album/%d", album.ID), bytes.NewReader(body.Bytes()))
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		rec := serve(req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		// Bodies of unknown length are cut off at the max upload size
+		req = httptest.NewRequest("POST", fmt.Sprintf("/upload/album/%d", album.ID), io.MultiReader(body))
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		rec = serve(req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoFileExists(t, path.Join(album.Path, "large.jpg"))
+
+		metadata := fmt.Sprintf("filename %s,albumId %s",
+			base64.StdEncoding.EncodeToString([]byte("large.jpg")),
+			base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(album.ID))))
+
+		req = httptest.NewRequest("POST", "/upload/files", nil)
+		req.Header.Set("Tus-Resumable", tusVersion)
+		req.Header.Set("Upload-Length", fmt.Sprint(2*1024*1024))
+		req.Header.Set("Upload-Metadata", metadata)
+		rec = serve(req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, fmt.Sprint(1024*1024), rec.Header().Get("Tus-Max-Size"))
+	})
+
+	t.Run("Resumable upload without tus header", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/upload/files", nil)
+		rec := serve(req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	})
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/photoview/photoview/api/utils"
+)
+
+// absoluteURL resolves a url relative to the host the request was made to, which is given by the public url of the server if it is set.
+// Otherwise, behind a reverse proxy set to be trusted, it is given by the X-Forwarded-Host and X-Forwarded-Proto headers.
+// The headers of other clients are ignored, so they can't have links made to another host.
+func absoluteURL(r *http.Request, rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.IsAbs() {
+		return rawURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if publicURL := utils.PublicURL(); publicURL != nil {
+		scheme = publicURL.Scheme
+		host = publicURL.Host
+	} else if utils.IsTrustedProxy(r.RemoteAddr) {
+		if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstHeaderValue(r, "X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	base := url.URL{Scheme: scheme, Host: host, Path: "/"}
+	return base.ResolveReference(parsed).String()
+}
+
+// firstHeaderValue returns the first of the comma separated values of a header, which has been set by the proxy closest to the client
+func firstHeaderValue(r *http.Request, header string) string {
+	value, _, _ := strings.Cut(r.Header.Get(header), ",")
+	return strings.TrimSpace(value)
+}
+
+// uiURL returns the absolute url of a page in the web interface
+func uiURL(r *http.Request, page string) string {
+	uiEndpoint := utils.UiEndpointUrl()
+	if uiEndpoint == nil {
+		return absoluteURL(r, path.Join(utils.PublicBasePath(), page))
+	}
+
+	pageURL := *uiEndpoint
+	pageURL.Path = path.Join(pageURL.Path, page)
+	return absoluteURL(r, pageURL.String())
+}
@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbsoluteURL(t *testing.T) {
+	t.Setenv(utils.EnvServeUI.GetName(), "1")
+
+	tests := []struct {
+		name           string
+		publicURL      string
+		trustedProxies string
+		headers        map[string]string
+		url            string
+		expected       string
+	}{
+		{"Host of the request", "", "", nil, "/api/photo/thumbnail.jpg", "http://photoview:4001/api/photo/thumbnail.jpg"},
+		{"Public url", "https://home.example.com/photos", "", nil, "/photos/api/photo/thumbnail.jpg", "https://home.example.com/photos/api/photo/thumbnail.jpg"},
+		{"Forwarded host of a trusted proxy", "", "192.0.2.0/24", map[string]string{"X-Forwarded-Host": "photos.example.org, proxy", "X-Forwarded-Proto": "https"},
+			"/share/abc", "https://photos.example.org/share/abc"},
+		{"Forwarded host of another client", "", "10.0.0.1", map[string]string{"X-Forwarded-Host": "evil.example.org", "X-Forwarded-Proto": "https"},
+			"/share/abc", "http://photoview:4001/share/abc"},
+		{"Public url over forwarded host", "https://home.example.com/photos", "192.0.2.0/24", map[string]string{"X-Forwarded-Host": "photos.example.org"},
+			"/photos/share/abc", "https://home.example.com/photos/share/abc"},
+		{"Absolute urls are kept", "https://home.example.com", "", nil, "https://cdn.example.com/a.jpg", "https://cdn.example.com/a.jpg"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(utils.EnvPublicURL.GetName(), test.publicURL)
+			t.Setenv(utils.EnvTrustedProxies.GetName(), test.trustedProxies)
+
+			// Requests made by httptest come from 192.0.2.1
+			r := httptest.NewRequest("GET", "http://photoview:4001/api/feed", nil)
+			for header, value := range test.headers {
+				r.Header.Set(header, value)
+			}
+
+			assert.Equal(t, test.expected, absoluteURL(r, test.url))
+		})
+	}
+
+	t.Run("Pages of the ui are below the public url", func(t *testing.T) {
+		t.Setenv(utils.EnvPublicURL.GetName(), "https://home.example.com/photos")
+
+		r := httptest.NewRequest("GET", "http://photoview:4001/api/feed", nil)
+		assert.Equal(t, "https://home.example.com/photos/share/abc", uiURL(r, "share/abc"))
+	})
+}
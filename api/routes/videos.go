@@ -1,16 +1,15 @@
 package routes
 
 import (
-	"log"
 	"net/http"
 	"os"
-	"path"
-	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner"
-	"github.com/photoview/photoview/api/utils"
+	"github.com/photoview/photoview/api/stats"
+	"github.com/photoview/photoview/api/storage"
 	"gorm.io/gorm"
 )
 
@@ -31,42 +30,58 @@ func RegisterVideoRoutes(db *gorm.DB, router *mux.Router) {
 
 		if success, response, status, err := authenticateMedia(media, db, r); !success {
 			if err != nil {
-				log.Printf("WARN: error authenticating video: %s\n", err)
+				log.Warn(r.Context(), "Error authenticating video", "error", err)
 			}
 			w.WriteHeader(status)
 			w.Write([]byte(response))
 			return
 		}
 
-		var cachedPath string
+		if mediaURL.Purpose != models.VideoWeb && mediaURL.Purpose != models.MotionVideo {
+			log.Error(r.Context(), "Can not handle media_purpose for video", "purpose", mediaURL.Purpose)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
 
-		if mediaURL.Purpose == models.VideoWeb {
-			cachedPath = path.Join(utils.MediaCachePath(), strconv.Itoa(int(media.AlbumID)), strconv.Itoa(int(mediaURL.MediaID)), mediaURL.MediaName)
-		} else {
-			log.Printf("ERROR: Can not handle media_purpose for video: %s\n", mediaURL.Purpose)
+		cachedPath, err := mediaURL.CachedPath()
+		if err != nil {
+			log.Error(r.Context(), "Serving video", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("internal server error"))
 			return
 		}
 
-		if _, err := os.Stat(cachedPath); err != nil {
-			if os.IsNotExist(err) {
-				if err := scanner.ProcessSingleMedia(db, media); err != nil {
-					log.Printf("ERROR: processing video not found in cache: %s\n", err)
-					w.WriteHeader(http.StatusInternalServerError)
-					w.Write([]byte("internal server error"))
-					return
-				}
+		// Cached files that have not been written completely are generated again, like missing ones
+		cacheHit := true
+		if !mediaURL.CachedFileComplete() {
+			cacheHit = false
+			if err := scanner.ProcessSingleMedia(db, media); err != nil {
+				log.Error(r.Context(), "Processing video not found in cache", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("internal server error"))
+				return
+			}
+
+			// Processing moves the files of media that had not been hashed into the directory of its content
+			if err := db.First(&mediaURL, mediaURL.ID).Error; err == nil {
+				cachedPath, _ = mediaURL.CachedPath()
+			}
 
-				if _, err := os.Stat(cachedPath); err != nil {
-					log.Printf("ERROR: after reprocessing video not found in cache: %s\n", err)
-					w.WriteHeader(http.StatusInternalServerError)
-					w.Write([]byte("internal server error"))
-					return
-				}
+			if _, err := os.Stat(cachedPath); err != nil {
+				log.Error(r.Context(), "After reprocessing video not found in cache", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("internal server error"))
+				return
 			}
 		}
 
+		stats.RecordCacheAccess(cacheHit)
+
+		if err := storage.TouchMediaURL(db, &mediaURL); err != nil {
+			log.Warn(r.Context(), "Updating access time of media url", "error", err)
+		}
+
 		http.ServeFile(w, r, cachedPath)
 	})
 }
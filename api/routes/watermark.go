@@ -0,0 +1,271 @@
+package routes
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"gorm.io/gorm"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_type"
+
+	// WebP images are decoded to draw watermarks over them, the other formats are decoded by imaging
+	_ "golang.org/x/image/webp"
+)
+
+// The size of a watermark relative to the width of the photo it is drawn over
+const watermarkRelativeWidth = 0.25
+
+// watermarkDrawer draws the watermark of a user over photos
+type watermarkDrawer struct {
+	watermark *models.Watermark
+	// overlay is the decoded PNG of an image watermark, nil for a text watermark
+	overlay image.Image
+}
+
+// watermarkOverlay is the decoded PNG of an image watermark, as it was when the watermark was last updated
+type watermarkOverlay struct {
+	updatedAt time.Time
+	image     image.Image
+}
+
+// watermarkOverlays caches the decoded images of watermarks by the id of the watermark,
+// so they are not decoded again for every photo served through a share link
+var watermarkOverlays = struct {
+	sync.Mutex
+	byWatermark map[int]watermarkOverlay
+}{byWatermark: make(map[int]watermarkOverlay)}
+
+// shareWatermark returns the watermark to draw over media served through a share link,
+// or nil if the media is requested by a logged in user or the owner of the share has no watermark enabled.
+// The share token of the request must already have been authenticated.
+func shareWatermark(db *gorm.DB, r *http.Request) (*watermarkDrawer, error) {
+	shareToken, err := requestShareToken(db, r)
+	if shareToken == nil || err != nil {
+		return nil, err
+	}
+
+	var watermarks []*models.Watermark
+	if err := db.Where("user_id = ? AND enabled = ?", shareToken.OwnerID, true).Limit(1).Find(&watermarks).Error; err != nil {
+		return nil, errors.Wrap(err, "get watermark of share owner")
+	}
+	if len(watermarks) == 0 {
+		return nil, nil
+	}
+
+	return newWatermarkDrawer(db, watermarks[0])
+}
+
+func newWatermarkDrawer(db *gorm.DB, watermark *models.Watermark) (*watermarkDrawer, error) {
+	drawer := &watermarkDrawer{watermark: watermark}
+
+	if watermark.ImageMediaID != nil {
+		overlay, err := watermarkOverlayImage(db, watermark)
+		if err != nil {
+			return nil, err
+		}
+		drawer.overlay = overlay
+	} else if watermark.Text == nil {
+		return nil, errors.New("watermark has neither a text nor an image")
+	}
+
+	return drawer, nil
+}
+
+// watermarkOverlayImage returns the decoded image of an image watermark, which is decoded again once the watermark is updated
+func watermarkOverlayImage(db *gorm.DB, watermark *models.Watermark) (image.Image, error) {
+	watermarkOverlays.Lock()
+	cached, found := watermarkOverlays.byWatermark[watermark.ID]
+	watermarkOverlays.Unlock()
+	if found && cached.updatedAt.Equal(watermark.UpdatedAt) {
+		return cached.image, nil
+	}
+
+	var media models.Media
+	if err := db.First(&media, *watermark.ImageMediaID).Error; err != nil {
+		return nil, errors.Wrap(err, "get image of watermark")
+	}
+
+	overlay, err := imaging.Open(media.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open image of watermark")
+	}
+
+	watermarkOverlays.Lock()
+	watermarkOverlays.byWatermark[watermark.ID] = watermarkOverlay{updatedAt: watermark.UpdatedAt, image: overlay}
+	watermarkOverlays.Unlock()
+
+	return overlay, nil
+}
+
+// canWatermark returns whether images of the content type can be decoded to draw a watermark over them.
+// Originals in other formats, such as RAW and HEIC images, are watermarked from their web version instead.
+func canWatermark(contentType string) bool {
+	switch media_type.MediaType(contentType) {
+	case media_type.TypeJpeg, media_type.TypePng, media_type.TypeGif, media_type.TypeBmp, media_type.TypeTiff, media_type.TypeWebp:
+		return true
+	}
+
+	return false
+}
+
+// watermarkableMediaURL returns the media url to draw the watermark over instead of an original in a format that can't be decoded,
+// which is its web version, or nil if it has none
+func watermarkableMediaURL(db *gorm.DB, mediaURL *models.MediaURL) (*models.MediaURL, error) {
+	if canWatermark(mediaURL.ContentType) {
+		return mediaURL, nil
+	}
+
+	return highResMediaURL(db, mediaURL)
+}
+
+// appliesTo returns whether the watermark is drawn over a media file, which is not the case for videos and SVG images
+func (drawer *watermarkDrawer) appliesTo(media *models.Media, contentType string) bool {
+	return drawer != nil && media.Type == models.MediaTypePhoto && contentType != string(media_type.TypeSvg)
+}
+
+// draw returns a copy of the image with the watermark drawn over it
+func (drawer *watermarkDrawer) draw(img image.Image) (*image.NRGBA, error) {
+	var overlay image.Image
+	width := int(float64(img.Bounds().Dx()) * watermarkRelativeWidth)
+	if drawer.overlay != nil {
+		overlay = imaging.Resize(drawer.overlay, width, 0, imaging.Lanczos)
+	} else {
+		var err error
+		if overlay, err = renderWatermarkText(*drawer.watermark.Text, width); err != nil {
+			return nil, err
+		}
+	}
+
+	return applyWatermark(img, overlay, drawer.watermark.Position, drawer.watermark.Opacity), nil
+}
+
+// renderWatermarkText renders white text with a dark shadow, so it is visible on both light and dark photos,
+// sized to fit the width
+func renderWatermarkText(text string, width int) (image.Image, error) {
+	parsed, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse watermark font")
+	}
+
+	// Text is first measured in a font of a known size, and the size is then scaled to fit the width
+	const measureSize = 100
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: measureSize, DPI: 72})
+	if err != nil {
+		return nil, errors.Wrap(err, "create watermark font face")
+	}
+	measured := font.MeasureString(face, text).Ceil()
+	face.Close()
+	if measured == 0 {
+		return nil, errors.New("watermark text is empty")
+	}
+
+	size := float64(measureSize) * float64(width) / float64(measured)
+	if size < 8 {
+		size = 8
+	}
+
+	face, err = opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, errors.Wrap(err, "create watermark font face")
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	shadow := int(size/24) + 1
+	bounds := image.Rect(0, 0, font.MeasureString(face, text).Ceil()+shadow, (metrics.Ascent+metrics.Descent).Ceil()+shadow)
+	rendered := image.NewNRGBA(bounds)
+
+	textDrawer := font.Drawer{Dst: rendered, Face: face}
+	for _, layer := range []struct {
+		color  color.Color
+		offset int
+	}{{color.NRGBA{0, 0, 0, 160}, shadow}, {color.White, 0}} {
+		textDrawer.Src = image.NewUniform(layer.color)
+		textDrawer.Dot = fixed.Point26_6{X: fixed.I(layer.offset), Y: metrics.Ascent + fixed.I(layer.offset)}
+		textDrawer.DrawString(text)
+	}
+
+	return rendered, nil
+}
+
+// applyWatermark returns a copy of the image with the overlay drawn over it at the position, with the opacity
+func applyWatermark(img image.Image, overlay image.Image, position models.WatermarkPosition, opacity float64) *image.NRGBA {
+	result := imaging.Clone(img)
+	bounds := result.Bounds()
+	overlayBounds := overlay.Bounds()
+
+	margin := bounds.Dx() / 40
+	if bounds.Dy()/40 < margin {
+		margin = bounds.Dy() / 40
+	}
+
+	var x, y int
+	switch position {
+	case models.WatermarkPositionTopLeft:
+		x, y = margin, margin
+	case models.WatermarkPositionTopRight:
+		x, y = bounds.Dx()-overlayBounds.Dx()-margin, margin
+	case models.WatermarkPositionCenter:
+		x, y = (bounds.Dx()-overlayBounds.Dx())/2, (bounds.Dy()-overlayBounds.Dy())/2
+	case models.WatermarkPositionBottomLeft:
+		x, y = margin, bounds.Dy()-overlayBounds.Dy()-margin
+	default:
+		x, y = bounds.Dx()-overlayBounds.Dx()-margin, bounds.Dy()-overlayBounds.Dy()-margin
+	}
+
+	target := image.Rect(x, y, x+overlayBounds.Dx(), y+overlayBounds.Dy())
+	mask := image.NewUniform(color.Alpha{A: uint8(opacity * 255)})
+	draw.DrawMask(result, target, overlay, overlayBounds.Min, mask, image.Point{}, draw.Over)
+
+	return result
+}
+
+// writeWatermarkedPhoto writes a photo with the watermark drawn over it encoded as JPEG, resized first if requested
+func writeWatermarkedPhoto(out io.Writer, drawer *watermarkDrawer, sourcePath string, resize *resizeOptions) error {
+	img, err := imaging.Open(sourcePath, imaging.AutoOrientation(true))
+	if err != nil {
+		return errors.Wrap(err, "open photo to watermark")
+	}
+
+	if resize != nil {
+		img = resizeImage(img, *resize)
+	}
+
+	watermarked, err := drawer.draw(img)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(jpeg.Encode(out, watermarked, &jpeg.Options{Quality: 90}), "encode watermarked photo")
+}
+
+// serveWatermarkedPhoto writes a photo with the watermark drawn over it.
+// Watermarked photos are not cached, as the watermark can be changed at any time.
+func serveWatermarkedPhoto(w http.ResponseWriter, r *http.Request, drawer *watermarkDrawer, sourcePath string, resize *resizeOptions) {
+	var buf bytes.Buffer
+	if err := writeWatermarkedPhoto(&buf, drawer, sourcePath, resize); err != nil {
+		log.Error(r.Context(), "Serving watermarked photo", "path", sourcePath, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+		return
+	}
+
+	w.Header().Set("Content-Type", string(media_type.TypeJpeg))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeContent(w, r, "watermarked.jpg", time.Now(), bytes.NewReader(buf.Bytes()))
+}
@@ -0,0 +1,196 @@
+package routes
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/photoview/photoview/api/graphql/auth"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/graphql/models/actions"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyWatermark(t *testing.T) {
+	img := imaging.New(400, 200, color.Black)
+	overlay := imaging.New(40, 20, color.White)
+
+	tests := []struct {
+		position models.WatermarkPosition
+		inside   image.Point
+	}{
+		{models.WatermarkPositionTopLeft, image.Pt(15, 15)},
+		{models.WatermarkPositionTopRight, image.Pt(385, 15)},
+		{models.WatermarkPositionCenter, image.Pt(200, 100)},
+		{models.WatermarkPositionBottomLeft, image.Pt(15, 185)},
+		{models.WatermarkPositionBottomRight, image.Pt(385, 185)},
+	}
+
+	for _, test := range tests {
+		result := applyWatermark(img, overlay, test.position, 1)
+		assert.Equal(t, img.Bounds(), result.Bounds())
+		assert.Equal(t, color.NRGBA{255, 255, 255, 255}, result.NRGBAAt(test.inside.X, test.inside.Y), test.position)
+
+		// The watermark is drawn only at its position
+		for _, other := range tests {
+			if other.position != test.position {
+				assert.Equal(t, color.NRGBA{0, 0, 0, 255}, result.NRGBAAt(other.inside.X, other.inside.Y), test.position)
+			}
+		}
+	}
+
+	// The original image is not changed
+	assert.Equal(t, color.NRGBA{0, 0, 0, 255}, img.NRGBAAt(385, 185))
+
+	half := applyWatermark(img, overlay, models.WatermarkPositionBottomRight, 0.5)
+	assert.InDelta(t, 128, int(half.NRGBAAt(385, 185).R), 2)
+
+	invisible := applyWatermark(img, overlay, models.WatermarkPositionBottomRight, 0)
+	assert.Equal(t, color.NRGBA{0, 0, 0, 255}, invisible.NRGBAAt(385, 185))
+}
+
+func TestWatermarkDrawerText(t *testing.T) {
+	text := "© Photographer"
+	drawer := &watermarkDrawer{watermark: &models.Watermark{
+		Text:     &text,
+		Position: models.WatermarkPositionCenter,
+		Opacity:  1,
+	}}
+
+	img := imaging.New(800, 600, color.Black)
+	result, err := drawer.draw(img)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Some pixels in the center of the image are drawn white, and the corners are left as they were
+	bright := 0
+	for y := 250; y < 350; y++ {
+		for x := 300; x < 500; x++ {
+			if result.NRGBAAt(x, y).R > 200 {
+				bright++
+			}
+		}
+	}
+	assert.Greater(t, bright, 100)
+	assert.Equal(t, color.NRGBA{0, 0, 0, 255}, result.NRGBAAt(5, 5))
+	assert.Equal(t, color.NRGBA{0, 0, 0, 255}, result.NRGBAAt(795, 595))
+
+	// The text is sized relative to the width of the photo
+	rendered, err := renderWatermarkText(text, 200)
+	if assert.NoError(t, err) {
+		assert.InDelta(t, 200, rendered.Bounds().Dx(), 20)
+	}
+}
+
+func TestShareWatermark(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	user, err := models.RegisterUser(db, "username", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{Title: "my_album", Path: "/photos"}
+	if !assert.NoError(t, db.Model(&user).Association("Albums").Append(&album)) {
+		return
+	}
+
+	shareToken, err := actions.AddAlbumShare(db, user, album.ID, nil, nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	shareRequest := httptest.NewRequest("GET", fmt.Sprintf("/photo/image.jpg?token=%s", shareToken.Value), nil)
+
+	drawer, err := shareWatermark(db, shareRequest)
+	assert.NoError(t, err)
+	assert.Nil(t, drawer, "no watermark has been set")
+
+	text := "Proof"
+	watermark := models.Watermark{UserID: user.ID, Enabled: true, Text: &text, Position: models.WatermarkPositionCenter, Opacity: 0.5}
+	if !assert.NoError(t, db.Save(&watermark).Error) {
+		return
+	}
+
+	drawer, err = shareWatermark(db, shareRequest)
+	if assert.NoError(t, err) && assert.NotNil(t, drawer) {
+		assert.Equal(t, watermark.ID, drawer.watermark.ID)
+		assert.True(t, drawer.appliesTo(&models.Media{Type: models.MediaTypePhoto}, "image/jpeg"))
+		assert.False(t, drawer.appliesTo(&models.Media{Type: models.MediaTypeVideo}, "video/mp4"))
+		assert.False(t, drawer.appliesTo(&models.Media{Type: models.MediaTypePhoto}, "image/svg+xml"))
+	}
+
+	// The owner sees the media without the watermark
+	ownerRequest := shareRequest.WithContext(auth.AddUserToContext(shareRequest.Context(), user))
+	drawer, err = shareWatermark(db, ownerRequest)
+	assert.NoError(t, err)
+	assert.Nil(t, drawer)
+
+	// A disabled watermark is not drawn
+	if !assert.NoError(t, db.Model(&watermark).Update("enabled", false).Error) {
+		return
+	}
+	drawer, err = shareWatermark(db, shareRequest)
+	assert.NoError(t, err)
+	assert.Nil(t, drawer)
+}
+
+func TestCanWatermark(t *testing.T) {
+	assert.True(t, canWatermark(string(media_type.TypeJpeg)))
+	assert.True(t, canWatermark(string(media_type.TypeWebp)))
+	assert.False(t, canWatermark(string(media_type.TypeHeic)))
+	assert.False(t, canWatermark(string(media_type.TypeCR2)))
+}
+
+func TestWatermarkOverlayImage(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	user, err := models.RegisterUser(db, "username", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	imagePath := path.Join(t.TempDir(), "watermark.png")
+	if !assert.NoError(t, imaging.Save(imaging.New(40, 20, color.White), imagePath)) {
+		return
+	}
+
+	album := models.Album{Title: "my_album", Path: path.Dir(imagePath)}
+	if !assert.NoError(t, db.Save(&album).Error) {
+		return
+	}
+	media := models.Media{Title: "watermark.png", Path: imagePath, AlbumID: album.ID, Type: models.MediaTypePhoto}
+	if !assert.NoError(t, db.Save(&media).Error) {
+		return
+	}
+
+	watermark := models.Watermark{UserID: user.ID, Enabled: true, ImageMediaID: &media.ID, Position: models.WatermarkPositionCenter, Opacity: 0.5}
+	if !assert.NoError(t, db.Save(&watermark).Error) {
+		return
+	}
+
+	drawer, err := newWatermarkDrawer(db, &watermark)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 40, drawer.overlay.Bounds().Dx())
+	}
+
+	// The decoded image is used until the watermark is updated
+	if !assert.NoError(t, os.Remove(imagePath)) {
+		return
+	}
+	_, err = newWatermarkDrawer(db, &watermark)
+	assert.NoError(t, err)
+
+	watermark.UpdatedAt = watermark.UpdatedAt.Add(time.Second)
+	_, err = newWatermarkDrawer(db, &watermark)
+	assert.Error(t, err)
+}
@@ -0,0 +1,490 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/net/webdav"
+	"gorm.io/gorm"
+)
+
+// RegisterWebDAVRoutes exposes the albums of the authenticated user as a WebDAV file system,
+// with albums as folders and the original media files inside them.
+// Media can only be added, and only if PHOTOVIEW_WEBDAV_WRITABLE is enabled. Existing files are never modified.
+func RegisterWebDAVRoutes(db *gorm.DB, router *mux.Router, prefix string) {
+	lockSystem := webdav.NewMemLS()
+
+	router.Use(maintenanceMiddleware)
+
+	router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := requestUser(db, r)
+		if user == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Photoview"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("unauthorized"))
+			return
+		}
+
+		// Read for every request, as it can be changed through the settings api
+		writable := utils.EnvWebDAVWritable.GetBool()
+
+		fileSystem := &libraryFS{db: db.WithContext(r.Context()), user: user, writable: writable}
+
+		if r.Method == http.MethodPut {
+			if err := limitUploadBody(r); err != nil {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			fileSystem.uploadBody = &uploadBody{ReadCloser: r.Body}
+			r.Body = fileSystem.uploadBody
+		}
+
+		// SVG images can run scripts when opened in a browser, so they are sanitized like on the photo route
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			if svgPath := fileSystem.svgMediaPath(strings.TrimPrefix(r.URL.Path, prefix)); svgPath != "" {
+				serveMediaFile(w, r, string(media_type.TypeSvg), svgPath)
+				return
+			}
+		}
+
+		handler := webdav.Handler{
+			Prefix:     prefix,
+			FileSystem: fileSystem,
+			LockSystem: lockSystem,
+			Logger: func(r *http.Request, err error) {
+				if err != nil && !os.IsNotExist(err) && !os.IsPermission(err) {
+					log.Warn(r.Context(), "Handling webdav request", "method", r.Method, "path", r.URL.Path, "error", err)
+				}
+			},
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// libraryFS implements webdav.FileSystem on top of the albums owned by a user
+type libraryFS struct {
+	db       *gorm.DB
+	user     *models.User
+	writable bool
+	// uploadBody is the body of a PUT request, files uploaded by it are only imported if it was read completely
+	uploadBody *uploadBody
+}
+
+// uploadBody records whether reading the body of an upload failed, such as when it goes beyond the max upload size
+// or the client disconnects, so the incomplete file is not imported
+type uploadBody struct {
+	io.ReadCloser
+	err error
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// libraryNode is either the root of the library, an album or a media
+type libraryNode struct {
+	name  string
+	album *models.Album
+	media *models.Media
+}
+
+func (n *libraryNode) isDir() bool {
+	return n.media == nil
+}
+
+func (l *libraryFS) children(node *libraryNode) ([]*libraryNode, error) {
+	if node.media != nil {
+		return nil, os.ErrInvalid
+	}
+
+	var albums []*models.Album
+	var media []*models.Media
+
+	if node.album == nil {
+		if err := l.user.FillAlbums(l.db); err != nil {
+			return nil, err
+		}
+
+		ownedIDs := make(map[int]bool, len(l.user.Albums))
+		for _, album := range l.user.Albums {
+			ownedIDs[album.ID] = true
+		}
+
+		// Albums without an owned parent are shown at the root
+		for i := range l.user.Albums {
+			album := &l.user.Albums[i]
+			if album.ParentAlbumID == nil || !ownedIDs[*album.ParentAlbumID] {
+				albums = append(albums, album)
+			}
+		}
+	} else {
+		if err := l.db.Where("parent_album_id = ?", node.album.ID).Order("id").Find(&albums).Error; err != nil {
+			return nil, errors.Wrap(err, "get sub albums")
+		}
+
+		if err := l.db.Where("album_id = ?", node.album.ID).Order("id").Find(&media).Error; err != nil {
+			return nil, errors.Wrap(err, "get album media")
+		}
+	}
+
+	children := make([]*libraryNode, 0, len(albums)+len(media))
+	taken := make(map[string]bool)
+
+	uniqueName := func(name string, id int) string {
+		if taken[name] {
+			name = fmt.Sprintf("%s (%d)", name, id)
+		}
+		taken[name] = true
+		return name
+	}
+
+	for _, album := range albums {
+		children = append(children, &libraryNode{name: uniqueName(path.Base(album.Path), album.ID), album: album})
+	}
+
+	for _, m := range media {
+		children = append(children, &libraryNode{name: uniqueName(path.Base(m.Path), m.ID), media: m})
+	}
+
+	return children, nil
+}
+
+// resolve looks up the node with the given slash separated name, starting from the root of the library
+func (l *libraryFS) resolve(name string) (*libraryNode, error) {
+	node := &libraryNode{name: "/"}
+
+	for _, segment := range strings.Split(strings.Trim(name, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+
+		children, err := l.children(node)
+		if err != nil {
+			if err == os.ErrInvalid {
+				return nil, os.ErrNotExist
+			}
+			return nil, err
+		}
+
+		var next *libraryNode
+		for _, child := range children {
+			if child.name == segment {
+				next = child
+				break
+			}
+		}
+
+		if next == nil {
+			return nil, os.ErrNotExist
+		}
+		node = next
+	}
+
+	return node, nil
+}
+
+// svgMediaPath returns the path of the file of a media in the library, if it is an SVG image
+func (l *libraryFS) svgMediaPath(name string) string {
+	node, err := l.resolve(name)
+	if err != nil || node.media == nil {
+		return ""
+	}
+
+	// The content type of files served over WebDAV is given by their extension
+	if mediaType, _ := media_type.GetExtensionMediaType(path.Ext(node.media.Path)); mediaType != media_type.TypeSvg {
+		return ""
+	}
+
+	return node.media.Path
+}
+
+func (l *libraryFS) stat(node *libraryNode) (os.FileInfo, error) {
+	if node.media != nil {
+		info, err := os.Stat(node.media.Path)
+		if err != nil {
+			return nil, err
+		}
+		return renamedFileInfo{info, node.name}, nil
+	}
+
+	mode := fs.ModeDir | 0555
+	if l.writable {
+		mode |= 0200
+	}
+
+	modTime := time.Time{}
+	if node.album != nil {
+		modTime = node.album.UpdatedAt
+	}
+
+	return directoryInfo{name: node.name, mode: mode, modTime: modTime}, nil
+}
+
+func (l *libraryFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	node, err := l.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.stat(node)
+}
+
+func (l *libraryFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	writing := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+
+	node, err := l.resolve(name)
+	if err == os.ErrNotExist && writing && flag&os.O_CREATE != 0 {
+		return l.createFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if writing {
+		return nil, os.ErrPermission
+	}
+
+	info, err := l.stat(node)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.isDir() {
+		return &directoryFile{fs: l, node: node, info: info}, nil
+	}
+
+	file, err := os.Open(node.media.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mediaFile{File: file, info: info}, nil
+}
+
+// createFile starts writing a new media file, which is imported into the album when the file is closed
+func (l *libraryFS) createFile(name string) (webdav.File, error) {
+	if !l.writable {
+		return nil, os.ErrPermission
+	}
+
+	parent, err := l.resolve(path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+
+	if parent.album == nil {
+		return nil, os.ErrPermission
+	}
+
+	filename, err := scanner.ValidUploadFilename(path.Base(name))
+	if err != nil {
+		return nil, os.ErrPermission
+	}
+
+	uploadDir := path.Join(utils.MediaCachePath(), "uploads")
+	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+		return nil, errors.Wrap(err, "create upload directory")
+	}
+
+	file, err := os.CreateTemp(uploadDir, "webdav-*")
+	if err != nil {
+		return nil, errors.Wrap(err, "create upload file")
+	}
+
+	return &uploadFile{File: file, fs: l, album: parent.album, filename: filename}, nil
+}
+
+func (l *libraryFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if !l.writable {
+		return os.ErrPermission
+	}
+
+	if _, err := l.resolve(name); err == nil {
+		return os.ErrExist
+	}
+
+	parent, err := l.resolve(path.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	if parent.album == nil {
+		return os.ErrPermission
+	}
+
+	dirName := path.Base(name)
+	if strings.HasPrefix(dirName, ".") {
+		return os.ErrPermission
+	}
+
+	_, err = parent.album.EnsureSubAlbum(l.db, dirName)
+	return err
+}
+
+func (l *libraryFS) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+func (l *libraryFS) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+type directoryInfo struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (d directoryInfo) Name() string       { return d.name }
+func (d directoryInfo) Size() int64        { return 0 }
+func (d directoryInfo) Mode() fs.FileMode  { return d.mode }
+func (d directoryInfo) ModTime() time.Time { return d.modTime }
+func (d directoryInfo) IsDir() bool        { return true }
+func (d directoryInfo) Sys() any           { return nil }
+
+// renamedFileInfo presents a file under the name it has in the library
+type renamedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (r renamedFileInfo) Name() string {
+	return r.name
+}
+
+type directoryFile struct {
+	fs      *libraryFS
+	node    *libraryNode
+	info    os.FileInfo
+	entries []os.FileInfo
+	read    bool
+}
+
+func (d *directoryFile) Close() error                                 { return nil }
+func (d *directoryFile) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (d *directoryFile) Write(p []byte) (int, error)                  { return 0, os.ErrPermission }
+func (d *directoryFile) Seek(offset int64, whence int) (int64, error) { return 0, nil }
+func (d *directoryFile) Stat() (os.FileInfo, error)                   { return d.info, nil }
+
+func (d *directoryFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.read {
+		children, err := d.fs.children(d.node)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, child := range children {
+			info, err := d.fs.stat(child)
+			if err != nil {
+				// Skip media that is missing or unreachable on disk
+				continue
+			}
+			d.entries = append(d.entries, info)
+		}
+		d.read = true
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+// mediaFile is a read only original media file
+type mediaFile struct {
+	*os.File
+	info os.FileInfo
+}
+
+func (m *mediaFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (m *mediaFile) Stat() (os.FileInfo, error) {
+	return m.info, nil
+}
+
+// uploadFile is a new media file, written to the media cache until it is closed
+type uploadFile struct {
+	*os.File
+	fs       *libraryFS
+	album    *models.Album
+	filename string
+}
+
+func (u *uploadFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (u *uploadFile) Stat() (os.FileInfo, error) {
+	info, err := u.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return renamedFileInfo{info, u.filename}, nil
+}
+
+func (u *uploadFile) Close() error {
+	tempPath := u.File.Name()
+	info, err := u.File.Stat()
+	if closeErr := u.File.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	// Some clients create an empty file before uploading the content
+	if info.Size() == 0 {
+		return os.Remove(tempPath)
+	}
+
+	if body := u.fs.uploadBody; body != nil && body.err != nil {
+		os.Remove(tempPath)
+		return errors.Wrapf(body.err, "receive %s uploaded through webdav", u.filename)
+	}
+
+	if _, err := scanner.ImportMediaFile(u.fs.db, u.album, tempPath, u.filename); err != nil {
+		os.Remove(tempPath)
+		return errors.Wrapf(err, "import %s uploaded through webdav", u.filename)
+	}
+
+	processUploadedMedia(u.fs.db.Statement.Context, u.album)
+	return nil
+}
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebDAV(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	password := "1234"
+	user, err := models.RegisterUser(db, "username", &password, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootPath := t.TempDir()
+	if !assert.NoError(t, os.WriteFile(path.Join(rootPath, "photo.jpg"), []byte("IMAGE DATA"), 0644)) {
+		return
+	}
+
+	rootAlbum := models.Album{
+		Title: "root",
+		Path:  rootPath,
+	}
+
+	if !assert.NoError(t, db.Model(&user).Association("Albums").Append(&rootAlbum)) {
+		return
+	}
+
+	subAlbum := models.Album{
+		Title:         "sub",
+		Path:          path.Join(rootPath, "sub"),
+		ParentAlbumID: &rootAlbum.ID,
+	}
+
+	if !assert.NoError(t, db.Model(&user).Association("Albums").Append(&subAlbum)) {
+		return
+	}
+
+	media := models.Media{
+		Title:   "photo.jpg",
+		Path:    path.Join(rootPath, "photo.jpg"),
+		AlbumID: rootAlbum.ID,
+	}
+
+	if !assert.NoError(t, db.Create(&media).Error) {
+		return
+	}
+
+	svgImage := `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(document.cookie)</script><rect width="10" height="10"/></svg>`
+	if !assert.NoError(t, os.WriteFile(path.Join(rootPath, "drawing.svg"), []byte(svgImage), 0644)) {
+		return
+	}
+
+	svgMedia := models.Media{
+		Title:   "drawing.svg",
+		Path:    path.Join(rootPath, "drawing.svg"),
+		AlbumID: rootAlbum.ID,
+	}
+
+	if !assert.NoError(t, db.Create(&svgMedia).Error) {
+		return
+	}
+
+	router := mux.NewRouter()
+	RegisterWebDAVRoutes(db, router.PathPrefix("/webdav").Subrouter(), "/webdav")
+
+	serve := func(method string, target string, authenticate bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if authenticate {
+			req.SetBasicAuth("username", password)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		rec := serve("PROPFIND", "/webdav/", false)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("List album tree", func(t *testing.T) {
+		rec := serve("PROPFIND", "/webdav/", true)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+
+		rootName := path.Base(rootPath)
+		body := rec.Body.String()
+		assert.Contains(t, body, "/webdav/"+rootName+"/")
+		assert.NotContains(t, body, "/webdav/sub/", "expected sub album to not be listed at the root")
+
+		rec = serve("PROPFIND", "/webdav/"+rootName+"/", true)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		body = rec.Body.String()
+		assert.Contains(t, body, "/webdav/"+rootName+"/sub/")
+		assert.Contains(t, body, "/webdav/"+rootName+"/photo.jpg")
+	})
+
+	t.Run("Download media", func(t *testing.T) {
+		rec := serve("GET", "/webdav/"+path.Base(rootPath)+"/photo.jpg", true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "IMAGE DATA", rec.Body.String())
+
+		rec = serve("GET", "/webdav/"+path.Base(rootPath)+"/missing.jpg", true)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Download SVG image", func(t *testing.T) {
+		rec := serve("GET", "/webdav/"+path.Base(rootPath)+"/drawing.svg", true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "<script")
+		assert.Contains(t, rec.Body.String(), "<rect")
+		assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "default-src 'none'")
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	})
+
+	t.Run("Read only by default", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/webdav/"+path.Base(rootPath)+"/new.jpg", strings.NewReader("NEW"))
+		req.SetBasicAuth("username", password)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.NotEqual(t, http.StatusCreated, rec.Code)
+
+		assert.NoFileExists(t, path.Join(rootPath, "new.jpg"))
+
+		rec = serve("DELETE", "/webdav/"+path.Base(rootPath)+"/photo.jpg", true)
+		assert.NotEqual(t, http.StatusNoContent, rec.Code)
+		assert.FileExists(t, path.Join(rootPath, "photo.jpg"))
+	})
+
+	t.Run("Upload size is limited", func(t *testing.T) {
+		t.Setenv(string(utils.EnvWebDAVWritable), "1")
+		t.Setenv(string(utils.EnvMaxUploadSize), "1")
+
+		target := "/webdav/" + path.Base(rootPath) + "/large.jpg"
+		body := strings.Repeat("A", 1024*1024+1)
+
+		req := httptest.NewRequest("PUT", target, strings.NewReader(body))
+		req.SetBasicAuth("username", password)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.NoFileExists(t, path.Join(rootPath, "large.jpg"))
+
+		// Without a content length the upload is cut off while it is received
+		req = httptest.NewRequest("PUT", target, io.NopCloser(strings.NewReader(body)))
+		req.ContentLength = -1
+		req.SetBasicAuth("username", password)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.NotEqual(t, http.StatusCreated, rec.Code)
+		assert.NoFileExists(t, path.Join(rootPath, "large.jpg"))
+	})
+}
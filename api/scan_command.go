@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/scanner/exif"
+	"github.com/photoview/photoview/api/scanner/face_detection"
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/photoview/photoview/api/scanner/scan_profile"
+	"github.com/photoview/photoview/api/scanner/scan_report"
+	"github.com/photoview/photoview/api/scanner/scanner_queue"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// runScanCommand scans the albums of a user, or of all users, and returns once the scan has finished.
+// If a server sharing the database is scanning, the scan starts once it has finished.
+// With -dry-run nothing is scanned, instead the albums and media the scan would add, change and remove are listed.
+// With -profile the time spent in each stage of the scan is printed once it has finished.
+//
+//	photoview scan -user admin
+//	photoview scan -dry-run -path /mnt/nas/photos
+//	photoview scan -profile
+func runScanCommand(db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	username := flags.String("user", "", "only scan the albums of this user")
+	dryRun := flags.Bool("dry-run", false, "list what the scan would change, without changing anything")
+	rootPath := flags.String("path", "", "with -dry-run, also list what adding this directory as a root album would add")
+	profile := flags.Bool("profile", false, "print the time spent walking directories, reading files, generating thumbnails and querying the database")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dryRun {
+		if *profile {
+			return errors.New("-profile cannot be used with -dry-run")
+		}
+		return runDryRunScan(db, *username, *rootPath)
+	}
+	if *rootPath != "" {
+		return errors.New("-path can only be used with -dry-run")
+	}
+
+	if *profile {
+		scan_profile.Enable()
+		if err := db.Use(scan_profile.GormPlugin{}); err != nil {
+			return errors.Wrap(err, "measure database queries")
+		}
+	}
+
+	if err := initializeMediaProcessing(db); err != nil {
+		return err
+	}
+
+	scan_report.InitializeScanReports(db)
+	if err := scanner_queue.InitializeScannerQueue(db); err != nil {
+		return errors.Wrap(err, "initialize scanner queue")
+	}
+
+	ctx := db.Statement.Context
+	startedAt := time.Now()
+	cpuSelf, cpuChildren, _ := scan_profile.CPUTime()
+
+	if *username != "" {
+		var user models.User
+		if err := db.Where("username = ?", *username).First(&user).Error; err != nil {
+			return errors.Wrapf(err, "find user %s", *username)
+		}
+
+		if err := scanner_queue.AddUserToQueue(ctx, &user); err != nil {
+			return err
+		}
+	} else {
+		if err := scanner_queue.AddAllToQueue(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Waits for the queued albums to be scanned
+	scanner_queue.CloseScannerQueue()
+
+	var newMedia, failures int64
+	if err := db.Model(&models.Media{}).Where("created_at >= ?", startedAt).Count(&newMedia).Error; err != nil {
+		return errors.Wrap(err, "count new media")
+	}
+	if err := db.Model(&models.ScanFailure{}).Where("created_at >= ?", startedAt).Count(&failures).Error; err != nil {
+		return errors.Wrap(err, "count scan failures")
+	}
+
+	duration := time.Since(startedAt)
+	log.Info(ctx, "Scan completed", "new_media", newMedia, "failures", failures, "duration", duration)
+
+	if *profile {
+		printScanProfile(duration, cpuSelf, cpuChildren)
+	}
+
+	return nil
+}
+
+// printScanProfile prints the time spent in each stage of the scan, and which resource most of the time was spent on.
+// The CPU time used before the scan started is subtracted from the CPU time of the scan.
+func printScanProfile(duration time.Duration, cpuSelfBefore time.Duration, cpuChildrenBefore time.Duration) {
+	// The resource limiting each stage of the scan
+	resources := map[scan_profile.Stage]string{
+		scan_profile.StageDirectoryWalk: "disk",
+		scan_profile.StageTypeDetection: "disk",
+		scan_profile.StageContentHash:   "disk",
+		scan_profile.StageEXIF:          "disk",
+		scan_profile.StageThumbnails:    "CPU",
+		scan_profile.StageVideoEncoding: "CPU",
+		scan_profile.StageDatabaseRead:  "database",
+		scan_profile.StageDatabaseWrite: "database",
+	}
+
+	resourceTotals := make(map[string]time.Duration)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tRESOURCE\tCOUNT\tTOTAL\tAVERAGE\tMAX")
+	for _, timing := range scan_profile.Timings() {
+		resourceTotals[resources[timing.Stage]] += timing.Total
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", timing.Stage, resources[timing.Stage], timing.Count,
+			roundDuration(timing.Total), roundDuration(timing.Average()), roundDuration(timing.Max))
+	}
+	w.Flush()
+
+	fmt.Printf("\nScan took %s with %d CPUs", roundDuration(duration), runtime.NumCPU())
+	if cpuSelf, cpuChildren, ok := scan_profile.CPUTime(); ok {
+		cpuSelf -= cpuSelfBefore
+		cpuChildren -= cpuChildrenBefore
+		fmt.Printf(", using %s of CPU time in the server and %s in ffmpeg, exiftool and other programs",
+			roundDuration(cpuSelf), roundDuration(cpuChildren))
+	}
+	fmt.Println()
+	fmt.Println("Stages run by multiple workers at once are measured separately, so their total can exceed the duration of the scan")
+
+	busiest := ""
+	for _, resource := range []string{"disk", "CPU", "database"} {
+		if busiest == "" || resourceTotals[resource] > resourceTotals[busiest] {
+			busiest = resource
+		}
+	}
+	if resourceTotals[busiest] > 0 {
+		fmt.Printf("Most of the measured time was spent waiting on the %s\n", busiest)
+	}
+}
+
+// roundDuration rounds durations to milliseconds, or to microseconds if they are shorter than a second
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// runDryRunScan prints what scanning the albums of a user, or of all users, would add, change and remove
+func runDryRunScan(db *gorm.DB, username string, rootPath string) error {
+	var users []*models.User
+	query := db.Order("id")
+	if username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if err := query.Find(&users).Error; err != nil {
+		return errors.Wrap(err, "get users from database")
+	}
+	if username != "" && len(users) == 0 {
+		return errors.Errorf("find user %s: user not found", username)
+	}
+
+	var rootPaths []string
+	if rootPath != "" {
+		rootPaths = append(rootPaths, rootPath)
+	}
+
+	report, err := scanner.DryRun(db, users, rootPaths)
+	if err != nil {
+		return err
+	}
+
+	printPaths := func(prefix string, paths []string) {
+		for _, path := range paths {
+			fmt.Printf("%s %s\n", prefix, path)
+		}
+	}
+
+	printPaths("+ album", report.AddedAlbums)
+	printPaths("- album", report.RemovedAlbums)
+	printPaths("+", report.AddedMedia)
+	printPaths("~", report.ChangedMedia)
+	printPaths("-", report.RemovedMedia)
+	for _, err := range report.Errors {
+		fmt.Printf("! %s\n", err)
+	}
+
+	fmt.Printf("\nAlbums: %d added, %d removed\n", len(report.AddedAlbums), len(report.RemovedAlbums))
+	fmt.Printf("Media: %d added, %d changed, %d removed, %d unchanged\n",
+		len(report.AddedMedia), len(report.ChangedMedia), len(report.RemovedMedia), report.UnchangedMedia)
+	fmt.Printf("Errors: %d\n", len(report.Errors))
+
+	return nil
+}
+
+// initializeMediaProcessing initializes the tools the scanner uses to process media, as the server does on startup
+func initializeMediaProcessing(db *gorm.DB) error {
+	executable_worker.InitializeExecutableWorkers()
+	exif.InitializeEXIFParser()
+
+	if err := face_detection.InitializeFaceDetector(db); err != nil {
+		return errors.Wrap(err, "initialize face detector")
+	}
+
+	return nil
+}
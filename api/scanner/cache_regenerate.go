@@ -0,0 +1,60 @@
+package scanner
+
+import (
+	"context"
+	"os"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// RegenerateCachedFiles processes the media of the query again, generating their cached files that are missing or incomplete.
+// If purge is true, the cached files that already exist are removed first, so all of them are generated again,
+// such as after changing the quality of thumbnails. The query must preload the media urls of the media.
+func RegenerateCachedFiles(ctx context.Context, db *gorm.DB, query *gorm.DB, purge bool) (processed int, failed int, err error) {
+	var batch []*models.Media
+	err = query.FindInBatches(&batch, 100, func(tx *gorm.DB, _ int) error {
+		for _, media := range batch {
+			if purge {
+				RemoveCachedFiles(media)
+			}
+
+			if err := ProcessSingleMedia(db, media); err != nil {
+				log.Warn(ctx, "Regenerating cached files of media", "media_id", media.ID, "path", media.Path, "error", err)
+				failed++
+				continue
+			}
+			processed++
+		}
+
+		log.Info(ctx, "Regenerating cached files", "processed", processed, "failed", failed)
+		return nil
+	}).Error
+	if err != nil {
+		return processed, failed, errors.Wrap(err, "get media from database")
+	}
+
+	return processed, failed, nil
+}
+
+// RemoveCachedFiles removes the cached files of the media, which are then generated again as they are missing
+func RemoveCachedFiles(media *models.Media) {
+	for i := range media.MediaURL {
+		mediaURL := &media.MediaURL[i]
+		if mediaURL.Purpose == models.MediaOriginal {
+			continue
+		}
+
+		mediaURL.Media = media
+		cachedPath, err := mediaURL.CachedPath()
+		if err != nil {
+			continue
+		}
+
+		if err := os.Remove(cachedPath); err != nil && !os.IsNotExist(err) {
+			log.Warn(context.Background(), "Removing cached file", "path", cachedPath, "error", err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package cache_warmup generates the missing thumbnails of the albums most likely to be browsed first,
+// in the background on startup, so the first browse after an upgrade or after the media cache has been wiped
+// doesn't have to wait for every thumbnail to be generated on request.
+package cache_warmup
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/photoview/photoview/api/cluster"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/maintenance"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Number of albums warmed up when not configured, half of them most recently captured and half most viewed
+const defaultWarmupAlbums = 20
+
+// How long after startup the warm-up begins, so it doesn't compete with the rest of the server starting
+const warmupDelay = 30 * time.Second
+
+// Period the views of albums are counted over, to find the most viewed albums
+const viewsPeriod = 30 * 24 * time.Hour
+
+// InitializeCacheWarmup starts warming up the media cache in the background, unless it has been disabled
+func InitializeCacheWarmup(db *gorm.DB) {
+	albumCount := warmupAlbumCount()
+	if albumCount <= 0 {
+		return
+	}
+
+	go func() {
+		time.Sleep(warmupDelay)
+
+		// Only one of the instances sharing the database warms up the cache they share
+		claimed, err := cluster.TryAcquire(db, "cache-warmup", time.Hour)
+		if err != nil {
+			log.Warn(context.Background(), "Claiming media cache warm-up", "error", err)
+		}
+		if !claimed {
+			return
+		}
+
+		ctx := context.Background()
+		start := time.Now()
+		generated, err := WarmUp(ctx, db, albumCount)
+		if err != nil {
+			log.Warn(ctx, "Warming up media cache", "error", err)
+			return
+		}
+
+		log.Info(ctx, "Media cache warmed up", "generated", generated, "duration", time.Since(start))
+	}()
+}
+
+func warmupAlbumCount() int {
+	value := utils.EnvCacheWarmupAlbums.GetValue()
+	if value == "" {
+		return defaultWarmupAlbums
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		log.Warn(context.Background(), "Invalid number of albums to warm up", "variable", utils.EnvCacheWarmupAlbums.GetName(), "value", value)
+		return defaultWarmupAlbums
+	}
+
+	return count
+}
+
+// WarmUp generates the missing thumbnails of the given number of albums, most recently captured and most viewed,
+// and returns the number of media that have been processed. It stops early when maintenance is started.
+func WarmUp(ctx context.Context, db *gorm.DB, albumCount int) (int, error) {
+	albumIDs, err := warmupAlbums(db, albumCount, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	generated := 0
+	for _, albumID := range albumIDs {
+		var media []*models.Media
+		err := db.Preload("MediaURL").
+			Where("album_id = ?", albumID).
+			Order("date_shot DESC").
+			Find(&media).Error
+		if err != nil {
+			return generated, errors.Wrapf(err, "get media of album (%d)", albumID)
+		}
+
+		for _, m := range media {
+			if ctx.Err() != nil {
+				return generated, ctx.Err()
+			}
+
+			if maintenance.Enabled() {
+				log.Info(ctx, "Media cache warm-up stopped for maintenance")
+				return generated, nil
+			}
+
+			if thumbnailCached(m) {
+				continue
+			}
+
+			if err := scanner.ProcessSingleMedia(db, m); err != nil {
+				log.Warn(ctx, "Generating thumbnail of media while warming up cache", "media_id", m.ID, "error", err)
+				continue
+			}
+			generated++
+		}
+	}
+
+	return generated, nil
+}
+
+// warmupAlbums returns the ids of the albums to warm up, alternating between the most recently captured albums
+// and the albums with the most cached files viewed recently, without duplicates
+func warmupAlbums(db *gorm.DB, albumCount int, now time.Time) ([]int, error) {
+	var captured []int
+	err := db.Model(&models.Media{}).
+		Select("album_id").
+		Group("album_id").
+		Order("MAX(date_shot) DESC").
+		Limit(albumCount).
+		Pluck("album_id", &captured).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get most recently captured albums")
+	}
+
+	var viewed []int
+	err = db.Model(&models.MediaURL{}).
+		Select("media.album_id").
+		Joins("JOIN media ON media.id = media_urls.media_id").
+		Where("media_urls.last_accessed_at > ?", now.Add(-viewsPeriod)).
+		Group("media.album_id").
+		Order("COUNT(*) DESC").
+		Limit(albumCount).
+		Pluck("media.album_id", &viewed).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get most viewed albums")
+	}
+
+	albumIDs := make([]int, 0, albumCount)
+	seen := make(map[int]bool)
+	for i := 0; len(albumIDs) < albumCount && (i < len(captured) || i < len(viewed)); i++ {
+		for _, list := range [][]int{captured, viewed} {
+			if i < len(list) && !seen[list[i]] && len(albumIDs) < albumCount {
+				seen[list[i]] = true
+				albumIDs = append(albumIDs, list[i])
+			}
+		}
+	}
+
+	return albumIDs, nil
+}
+
+// thumbnailCached returns whether the thumbnail of the media has been generated and is complete in the media cache
+func thumbnailCached(media *models.Media) bool {
+	if len(media.MediaURL) == 0 {
+		return false
+	}
+
+	thumbnail, err := media.GetThumbnail()
+	if err != nil || thumbnail == nil {
+		return false
+	}
+
+	return thumbnail.CachedFileComplete()
+}
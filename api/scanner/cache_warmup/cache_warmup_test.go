@@ -0,0 +1,55 @@
+package cache_warmup
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestWarmupAlbums(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	now := time.Now()
+	albums := make([]models.Album, 4)
+	for i := range albums {
+		albums[i] = models.Album{Title: "album", Path: "/photos/album" + string(rune('a'+i))}
+		if !assert.NoError(t, db.Save(&albums[i]).Error) {
+			return
+		}
+	}
+
+	// Albums are captured in order, the last one most recently, while only the first one has been viewed
+	for i, album := range albums {
+		media := models.Media{
+			Title:    "photo.jpg",
+			Path:     album.Path + "/photo.jpg",
+			AlbumID:  album.ID,
+			DateShot: now.AddDate(0, 0, i-len(albums)),
+		}
+		if !assert.NoError(t, db.Save(&media).Error) {
+			return
+		}
+
+		if i == 0 {
+			viewedAt := now.Add(-time.Hour)
+			thumbnail := models.MediaURL{MediaID: media.ID, MediaName: "thumbnail.jpg", Purpose: models.PhotoThumbnail, LastAccessedAt: &viewedAt}
+			assert.NoError(t, db.Save(&thumbnail).Error)
+		}
+	}
+
+	albumIDs, err := warmupAlbums(db, 2, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{albums[3].ID, albums[0].ID}, albumIDs)
+
+	albumIDs, err = warmupAlbums(db, 3, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{albums[3].ID, albums[0].ID, albums[2].ID}, albumIDs)
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+
+package scanner
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// directoryID identifies a directory regardless of the path it is reached through, such as through a symlink
+type directoryID struct {
+	device uint64
+	inode  uint64
+}
+
+// getDirectoryID returns the identity of the directory at the path, following symlinks
+func getDirectoryID(dirPath string) (directoryID, error) {
+	fileInfo, err := os.Stat(dirPath)
+	if err != nil {
+		return directoryID{}, errors.Wrapf(err, "could not stat %s", dirPath)
+	}
+
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return directoryID{}, errors.Errorf("could not get inode of %s", dirPath)
+	}
+
+	return directoryID{device: uint64(stat.Dev), inode: uint64(stat.Ino)}, nil
+}
@@ -0,0 +1,23 @@
+package scanner
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// directoryID identifies a directory regardless of the path it is reached through, such as through a symlink.
+// Inodes are not available on windows, so directories are identified by the path with all symlinks resolved.
+type directoryID struct {
+	path string
+}
+
+// getDirectoryID returns the identity of the directory at the path, following symlinks
+func getDirectoryID(dirPath string) (directoryID, error) {
+	resolvedPath, err := filepath.EvalSymlinks(dirPath)
+	if err != nil {
+		return directoryID{}, errors.Wrapf(err, "could not resolve %s", dirPath)
+	}
+
+	return directoryID{path: resolvedPath}, nil
+}
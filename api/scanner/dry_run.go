@@ -0,0 +1,287 @@
+package scanner
+
+import (
+	"container/list"
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/path_exclusion"
+	"github.com/photoview/photoview/api/scanner/scanner_cache"
+	"github.com/photoview/photoview/api/scanner/scanner_tasks/processing_tasks"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	ignore "github.com/sabhiram/go-gitignore"
+	"gorm.io/gorm"
+)
+
+// DryRunReport lists the paths of the albums and media a scan would add, change and remove
+type DryRunReport struct {
+	AddedAlbums   []string
+	RemovedAlbums []string
+	AddedMedia    []string
+	// ChangedMedia have changed since they were processed, or have not been processed completely
+	ChangedMedia   []string
+	RemovedMedia   []string
+	UnchangedMedia int
+	// Errors are the directories that could not be read, which a scan would report as failures
+	Errors []error
+}
+
+// dryRun collects the report of a dry run over several users and paths, counting albums shared between users once
+type dryRun struct {
+	db           *gorm.DB
+	report       *DryRunReport
+	seenAlbums   map[string]bool
+	foundAlbums  map[string]bool
+	removedPaths map[string]bool
+}
+
+// DryRun walks the root albums of the users the way a scan does, and reports what scanning them would add, change and remove,
+// without changing the database or the media cache. Root paths not yet added to any user can also be given,
+// such as a network share, to see what adding them would add to the library.
+func DryRun(db *gorm.DB, users []*models.User, rootPaths []string) (*DryRunReport, error) {
+	run := dryRun{
+		db:           db,
+		report:       &DryRunReport{},
+		seenAlbums:   make(map[string]bool),
+		foundAlbums:  make(map[string]bool),
+		removedPaths: make(map[string]bool),
+	}
+
+	for _, user := range users {
+		if err := run.walkUser(user); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(rootPaths) > 0 {
+		album_cache := scanner_cache.MakeAlbumCache()
+		if err := LoadExcludePatterns(db, nil, album_cache); err != nil {
+			return nil, err
+		}
+
+		if err := run.walk(rootPaths, album_cache); err != nil {
+			return nil, err
+		}
+	}
+
+	for albumPath := range run.removedPaths {
+		if !run.foundAlbums[albumPath] {
+			run.report.RemovedAlbums = append(run.report.RemovedAlbums, albumPath)
+		}
+	}
+
+	if len(run.report.RemovedAlbums) > 0 {
+		var removedMedia []string
+		err := db.Model(&models.Media{}).
+			Joins("JOIN albums ON albums.id = media.album_id").
+			Where("albums.path IN (?)", run.report.RemovedAlbums).
+			Pluck("media.path", &removedMedia).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "get media of removed albums from database")
+		}
+		run.report.RemovedMedia = append(run.report.RemovedMedia, removedMedia...)
+	}
+
+	sort.Strings(run.report.AddedAlbums)
+	sort.Strings(run.report.RemovedAlbums)
+	sort.Strings(run.report.AddedMedia)
+	sort.Strings(run.report.ChangedMedia)
+	sort.Strings(run.report.RemovedMedia)
+
+	return run.report, nil
+}
+
+func (run *dryRun) walkUser(user *models.User) error {
+	// The albums are loaded again, as those filled in the user may have changed since
+	var userAlbums []*models.Album
+	if err := run.db.Model(user).Association("Albums").Find(&userAlbums); err != nil {
+		return errors.Wrap(err, "get albums of user from database")
+	}
+
+	userAlbumIDs := make([]int, len(userAlbums))
+	for i, album := range userAlbums {
+		userAlbumIDs[i] = album.ID
+	}
+
+	var userRootAlbums []*models.Album
+	if err := run.db.Where("id IN (?)", userAlbumIDs).Where("parent_album_id IS NULL OR parent_album_id NOT IN (?)", userAlbumIDs).Find(&userRootAlbums).Error; err != nil {
+		return errors.Wrap(err, "get root albums of user from database")
+	}
+
+	rootPaths := make([]string, 0, len(userRootAlbums))
+	for _, album := range userRootAlbums {
+		rootPaths = append(rootPaths, album.Path)
+	}
+
+	album_cache := scanner_cache.MakeAlbumCache()
+	if err := LoadExcludePatterns(run.db, []int{user.ID}, album_cache); err != nil {
+		return err
+	}
+
+	found := len(run.foundAlbums)
+	if err := run.walk(rootPaths, album_cache); err != nil {
+		return err
+	}
+
+	// As when scanning, the albums of a user are only removed if some of them were found
+	if len(run.foundAlbums) > found || len(rootPaths) == 0 {
+		for _, album := range userAlbums {
+			run.removedPaths[album.Path] = true
+		}
+	}
+
+	return nil
+}
+
+// walk finds the albums below the root paths as findAlbums does, and compares their media with the database
+func (run *dryRun) walk(rootPaths []string, album_cache *scanner_cache.AlbumScannerCache) error {
+	ctx := run.db.Statement.Context
+
+	scanQueue := list.New()
+	for _, rootPath := range rootPaths {
+		if _, err := os.Stat(rootPath); err != nil {
+			run.report.Errors = append(run.report.Errors, errors.Wrapf(err, "read root album directory (%s)", rootPath))
+			continue
+		}
+		scanQueue.PushBack(albumScanInfo{path: rootPath})
+	}
+
+	visitedDirs := make(map[directoryID]bool)
+
+	for scanQueue.Front() != nil {
+		albumInfo := scanQueue.Front().Value.(albumScanInfo)
+		scanQueue.Remove(scanQueue.Front())
+
+		albumPath := albumInfo.path
+		albumIgnore := albumInfo.ignore
+
+		dirID, err := getDirectoryID(albumPath)
+		if err != nil {
+			run.report.Errors = append(run.report.Errors, err)
+			continue
+		}
+		if visitedDirs[dirID] {
+			continue
+		}
+		visitedDirs[dirID] = true
+
+		dirContent, err := ioutil.ReadDir(albumPath)
+		if err != nil {
+			run.report.Errors = append(run.report.Errors, errors.Wrapf(err, "read directory (%s)", albumPath))
+			continue
+		}
+
+		if ignore.CompileIgnoreLines(albumIgnore...).MatchesPath(albumPath+"/") || album_cache.IsPathExcluded(albumPath) {
+			continue
+		}
+
+		photoviewIgnore, err := getPhotoviewIgnore(ctx, albumPath)
+		if err != nil {
+			log.Warn(ctx, "Failed to get ignore file", "path", albumPath, "error", err)
+		} else {
+			albumIgnore = append(albumIgnore, photoviewIgnore...)
+		}
+
+		mediaPaths := make([]string, 0)
+		ignoreEntries := ignore.CompileIgnoreLines(albumIgnore...)
+
+		for _, item := range dirContent {
+			itemPath := path.Join(albumPath, item.Name())
+
+			isDirSymlink, err := utils.IsDirSymlink(itemPath)
+			if err != nil {
+				run.report.Errors = append(run.report.Errors, errors.Wrapf(err, "could not check for symlink target of %s", itemPath))
+				continue
+			}
+
+			if item.IsDir() || isDirSymlink {
+				if !path_exclusion.IsSkippedDirectory(item.Name()) && directoryContainsPhotos(ctx, itemPath, album_cache, albumIgnore) {
+					scanQueue.PushBack(albumScanInfo{path: itemPath, ignore: albumIgnore})
+				}
+				continue
+			}
+
+			if album_cache.IsPathMedia(itemPath) && !ignoreEntries.MatchesPath(itemPath) && !processing_tasks.HasRawCounterpart(itemPath) &&
+				!processing_tasks.IsLivePhotoVideo(ctx, itemPath) {
+				mediaPaths = append(mediaPaths, itemPath)
+			}
+		}
+
+		if err := run.compareAlbum(ctx, albumPath, mediaPaths); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compareAlbum compares the media found in the directory of an album with the media of the album in the database
+func (run *dryRun) compareAlbum(ctx context.Context, albumPath string, mediaPaths []string) error {
+	run.foundAlbums[albumPath] = true
+	if run.seenAlbums[albumPath] {
+		return nil
+	}
+	run.seenAlbums[albumPath] = true
+
+	var albums []*models.Album
+	if err := run.db.Where("path_hash = ?", models.MD5Hash(albumPath)).Find(&albums).Error; err != nil {
+		return errors.Wrap(err, "get album from database")
+	}
+
+	if len(albums) == 0 {
+		run.report.AddedAlbums = append(run.report.AddedAlbums, albumPath)
+		run.report.AddedMedia = append(run.report.AddedMedia, mediaPaths...)
+		return nil
+	}
+
+	var albumMedia []*models.Media
+	if err := run.db.Where("album_id = ?", albums[0].ID).Find(&albumMedia).Error; err != nil {
+		return errors.Wrap(err, "get media of album from database")
+	}
+
+	existingMedia := make(map[string]*models.Media, len(albumMedia))
+	for _, media := range albumMedia {
+		existingMedia[media.Path] = media
+	}
+
+	for _, mediaPath := range mediaPaths {
+		media, found := existingMedia[mediaPath]
+		if !found {
+			run.report.AddedMedia = append(run.report.AddedMedia, mediaPath)
+			continue
+		}
+		delete(existingMedia, mediaPath)
+
+		if mediaFileChanged(media) {
+			run.report.ChangedMedia = append(run.report.ChangedMedia, mediaPath)
+		} else {
+			run.report.UnchangedMedia++
+		}
+	}
+
+	for mediaPath := range existingMedia {
+		run.report.RemovedMedia = append(run.report.RemovedMedia, mediaPath)
+	}
+
+	return nil
+}
+
+// mediaFileChanged returns whether the file of a media differs from the file that was processed, without resetting the media
+func mediaFileChanged(media *models.Media) bool {
+	if media.FileModTime == nil || media.FileSize == nil {
+		return true
+	}
+
+	stat, err := os.Stat(media.Path)
+	if err != nil {
+		return true
+	}
+
+	return stat.ModTime().Unix() != media.FileModTime.Unix() || stat.Size() != *media.FileSize
+}
@@ -0,0 +1,86 @@
+package scanner_test
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	photo, err := os.ReadFile("./test_data/lilac_lilac_bush_lilac.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootPath := t.TempDir()
+	for _, name := range []string{"photo.jpg", "trip/kept.jpg", "trip/edited.jpg", "trip/deleted.jpg", "old/photo.jpg"} {
+		assert.NoError(t, os.MkdirAll(path.Dir(path.Join(rootPath, name)), 0755))
+		assert.NoError(t, os.WriteFile(path.Join(rootPath, name), photo, 0644))
+	}
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	report, err := scanner.DryRun(db, []*models.User{user}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{path.Join(rootPath, "old"), path.Join(rootPath, "trip")}, report.AddedAlbums)
+	assert.Len(t, report.AddedMedia, 5)
+	assert.Empty(t, report.RemovedAlbums)
+
+	var mediaCount int64
+	assert.NoError(t, db.Model(&models.Media{}).Count(&mediaCount).Error)
+	assert.EqualValues(t, 0, mediaCount, "a dry run does not add media")
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	assert.NoError(t, os.RemoveAll(path.Join(rootPath, "old")))
+	assert.NoError(t, os.Remove(path.Join(rootPath, "trip/deleted.jpg")))
+	assert.NoError(t, os.WriteFile(path.Join(rootPath, "trip/added.jpg"), photo, 0644))
+	edited := time.Now().Add(time.Hour)
+	assert.NoError(t, os.Chtimes(path.Join(rootPath, "trip/edited.jpg"), edited, edited))
+
+	newPath := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(newPath, "new.jpg"), photo, 0644))
+
+	var albumsBefore, mediaBefore int64
+	assert.NoError(t, db.Model(&models.Album{}).Count(&albumsBefore).Error)
+	assert.NoError(t, db.Model(&models.Media{}).Count(&mediaBefore).Error)
+
+	report, err = scanner.DryRun(db, []*models.User{user}, []string{newPath})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{newPath}, report.AddedAlbums)
+	assert.Equal(t, []string{path.Join(rootPath, "old")}, report.RemovedAlbums)
+	assert.Equal(t, []string{path.Join(rootPath, "trip/added.jpg"), path.Join(newPath, "new.jpg")}, report.AddedMedia)
+	assert.Equal(t, []string{path.Join(rootPath, "trip/edited.jpg")}, report.ChangedMedia)
+	assert.Equal(t, []string{path.Join(rootPath, "old/photo.jpg"), path.Join(rootPath, "trip/deleted.jpg")}, report.RemovedMedia)
+	assert.Equal(t, 2, report.UnchangedMedia)
+	assert.Empty(t, report.Errors)
+
+	var albumsAfter, mediaAfter int64
+	assert.NoError(t, db.Model(&models.Album{}).Count(&albumsAfter).Error)
+	assert.NoError(t, db.Model(&models.Media{}).Count(&mediaAfter).Error)
+	assert.Equal(t, albumsBefore, albumsAfter, "a dry run does not change albums")
+	assert.Equal(t, mediaBefore, mediaAfter, "a dry run does not change media")
+}
@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"context"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/path_exclusion"
+	"github.com/photoview/photoview/api/scanner/scanner_cache"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// LoadExcludePatterns caches the patterns of files and directories excluded from a scan of the albums of the users,
+// which are those set by PHOTOVIEW_EXCLUDE_PATTERNS and those of the preferences of the users.
+// Invalid patterns are logged and skipped, so they don't stop the scan.
+func LoadExcludePatterns(db *gorm.DB, userIDs []int, album_cache *scanner_cache.AlbumScannerCache) error {
+	ctx := db.Statement.Context
+
+	patterns := path_exclusion.New()
+	addPatterns(ctx, patterns, path_exclusion.GlobalPatterns())
+
+	if len(userIDs) > 0 {
+		var preferences []*models.UserPreferences
+		if err := db.Where("user_id IN (?)", userIDs).Find(&preferences).Error; err != nil {
+			return errors.Wrap(err, "get preferences of users from database")
+		}
+
+		for _, userPreferences := range preferences {
+			addPatterns(ctx, patterns, userPreferences.ExcludePatterns())
+		}
+	}
+
+	album_cache.SetExcludePatterns(patterns)
+	return nil
+}
+
+// LoadAlbumExcludePatterns caches the patterns of files and directories excluded from a scan of an album,
+// which are those of all the owners of the album
+func LoadAlbumExcludePatterns(db *gorm.DB, album *models.Album, album_cache *scanner_cache.AlbumScannerCache) error {
+	var ownerIDs []int
+	if err := db.Model(&models.UserAlbums{}).Where("album_id = ?", album.ID).Pluck("user_id", &ownerIDs).Error; err != nil {
+		return errors.Wrap(err, "get owners of album from database")
+	}
+
+	return LoadExcludePatterns(db, ownerIDs, album_cache)
+}
+
+func addPatterns(ctx context.Context, patterns *path_exclusion.Patterns, values []string) {
+	for _, value := range values {
+		if err := patterns.Add(value); err != nil {
+			log.Warn(ctx, "Skipping invalid exclusion pattern", "error", err)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package exif
 
 import (
-	"log"
+	"context"
 
+	"github.com/photoview/photoview/api/scanner/media_encoding"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/scanner/scan_profile"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 )
 
 type ExifParser interface {
@@ -20,10 +24,10 @@ func InitializeEXIFParser() {
 	exiftoolParser, err := NewExiftoolParser()
 
 	if err != nil {
-		log.Printf("Failed to get exiftool, using internal exif parser instead: %v\n", err)
+		log.Warn(context.Background(), "Failed to get exiftool, using internal exif parser instead", "error", err)
 		globalExifParser = NewInternalExifParser()
 	} else {
-		log.Println("Found exiftool")
+		log.Info(context.Background(), "Found exiftool")
 		globalExifParser = exiftoolParser
 	}
 }
@@ -48,11 +52,27 @@ func SaveEXIF(tx *gorm.DB, media *models.Media) (*models.MediaEXIF, error) {
 		return nil, errors.New("No exif parser initialized")
 	}
 
+	endEXIF := scan_profile.Start(scan_profile.StageEXIF)
 	exif, err := globalExifParser.ParseExif(media.Path)
+	endEXIF()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse exif data")
 	}
 
+	// The pages of multi-page TIFF images are not part of their EXIF data
+	if mediaType, err := media_type.GetMediaType(media.Path); err == nil && mediaType != nil && *mediaType == media_type.TypeTiff {
+		pages, err := media_encoding.TIFFPageCount(media.Path)
+		if err != nil {
+			log.Warn(context.Background(), "Counting pages of TIFF image", "path", media.Path, "error", err)
+		} else if pages > 1 {
+			if exif == nil {
+				exif = &models.MediaEXIF{}
+			}
+			pageCount := int64(pages)
+			exif.PageCount = &pageCount
+		}
+	}
+
 	if exif == nil {
 		return nil, nil
 	}
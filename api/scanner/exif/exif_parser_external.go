@@ -1,13 +1,14 @@
 package exif
 
 import (
-	"log"
+	"context"
 	"math"
 	"time"
 
 	"github.com/barasher/go-exiftool"
 	"github.com/photoview/photoview/api/dataloader"
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 )
 
 type externalExifParser struct {
@@ -21,7 +22,7 @@ func NewExiftoolParser() (ExifParser, error) {
 	et, err := exiftool.NewExiftool(exiftool.NoPrintConversion(), exiftool.Buffer(buf, 64*1024))
 
 	if err != nil {
-		log.Printf("Error initializing ExifTool: %s\n", err)
+		log.Error(context.Background(), "Error initializing ExifTool", "error", err)
 		return nil, err
 	}
 
@@ -70,7 +71,7 @@ func (p *externalExifParser) ParseExif(media_path string) (returnExif *models.Me
 		p.et = et
 
 		if err != nil {
-			log.Printf("Error initializing ExifTool: %s\n", err)
+			log.Error(context.Background(), "Error initializing ExifTool", "error", err)
 			return nil, err
 		}
 	}
@@ -1,13 +1,14 @@
 package exif
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"math/big"
-	"os"
 	"time"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
 	"github.com/pkg/errors"
 	"github.com/xor-gate/goexif2/exif"
 	"github.com/xor-gate/goexif2/mknote"
@@ -21,17 +22,18 @@ func NewInternalExifParser() ExifParser {
 }
 
 func (p internalExifParser) ParseExif(media_path string) (returnExif *models.MediaEXIF, returnErr error) {
-	photoFile, err := os.Open(media_path)
+	photoFile, err := scanner_io.Open(context.Background(), media_path)
 	if err != nil {
 		return nil, err
 	}
+	defer photoFile.Close()
 
 	exif.RegisterParsers(mknote.All...)
 
 	// Recover if exif.Decode panics
 	defer func() {
 		if err := recover(); err != nil {
-			log.Printf("Recovered from panic: Exif decoding: %s\n", err)
+			log.Error(context.Background(), "Recovered from panic: Exif decoding", "path", media_path, "error", err)
 			returnErr = errors.New(fmt.Sprintf("Exif decoding panicked: %s\n", err))
 		}
 	}()
@@ -88,11 +90,11 @@ func (p internalExifParser) ParseExif(media_path string) (returnExif *models.Med
 
 	isoTag, err := exifTags.Get(exif.ISOSpeedRatings)
 	if err != nil {
-		log.Printf("WARN: Could not read ISOSpeedRatings from EXIF: %v\n", media_path)
+		log.Warn(context.Background(), "Could not read ISOSpeedRatings from EXIF", "path", media_path)
 	} else {
 		iso, err := isoTag.Int(0)
 		if err != nil {
-			log.Printf("WARN: Could not parse EXIF ISOSpeedRatings as integer: %v\n", media_path)
+			log.Warn(context.Background(), "Could not parse EXIF ISOSpeedRatings as integer", "path", media_path)
 		} else {
 			iso64 := int64(iso)
 			newExif.Iso = &iso64
@@ -113,7 +115,7 @@ func (p internalExifParser) ParseExif(media_path string) (returnExif *models.Med
 			if err == nil {
 				focalLength, err := focalLengthTag.Int(1)
 				if err != nil {
-					log.Printf("WARN: Could not parse EXIF FocalLength as rational or integer: %v\n%s\n", media_path, err)
+					log.Warn(context.Background(), "Could not parse EXIF FocalLength as rational or integer", "path", media_path, "error", err)
 				} else {
 					focalLenFloat := float64(focalLength)
 					newExif.FocalLength = &focalLenFloat
@@ -165,7 +167,7 @@ func (p *internalExifParser) readStringTag(tags *exif.Exif, name exif.FieldName,
 		return &value, nil
 	}
 
-	log.Printf("WARN: EXIF tag %s returned null: %s\n", name, media_path)
+	log.Warn(context.Background(), "EXIF tag returned null", "tag", name, "path", media_path)
 	return nil, errors.New("exif tag returned null")
 }
 
@@ -184,7 +186,7 @@ func (p *internalExifParser) readRationalTag(tags *exif.Exif, name exif.FieldNam
 		return value, nil
 	}
 
-	log.Printf("WARN: EXIF tag %s returned null: %s\n", name, media_path)
+	log.Warn(context.Background(), "EXIF tag returned null", "tag", name, "path", media_path)
 	return nil, errors.New("exif tag returned null")
 }
 
@@ -203,6 +205,6 @@ func (p *internalExifParser) readIntegerTag(tags *exif.Exif, name exif.FieldName
 		return &value, nil
 	}
 
-	log.Printf("WARN: EXIF tag %s returned null: %s\n", name, media_path)
+	log.Warn(context.Background(), "EXIF tag returned null", "tag", name, "path", media_path)
 	return nil, errors.New("exif tag returned null")
 }
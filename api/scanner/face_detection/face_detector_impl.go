@@ -3,11 +3,16 @@
 package face_detection
 
 import (
-	"log"
+	"bytes"
+	"image/jpeg"
+	"os"
 	"sync"
 
 	"github.com/Kagami/go-face"
+	"github.com/disintegration/imaging"
+	"github.com/photoview/photoview/api/features"
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/utils"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
@@ -22,12 +27,13 @@ type faceDetector struct {
 }
 
 func InitializeFaceDetector(db *gorm.DB) error {
-	if utils.EnvDisableFaceRecognition.GetBool() {
-		log.Printf("Face detection disabled (%s=1)\n", utils.EnvDisableFaceRecognition.GetName())
+	if !features.Enabled(models.FeatureFaceRecognition) {
+		log.Info(db.Statement.Context, "Face detection disabled", "env", utils.EnvDisableFaceRecognition.GetName()+"=1")
+		GlobalFaceDetector = nil
 		return nil
 	}
 
-	log.Println("Initializing face detector")
+	log.Info(db.Statement.Context, "Initializing face detector")
 
 	rec, err := face.NewRecognizer(utils.FaceRecognitionModelsPath())
 	if err != nil {
@@ -111,8 +117,13 @@ func (fd *faceDetector) DetectFaces(db *gorm.DB, media *models.Media) error {
 		return err
 	}
 
+	thumbnail, err := thumbnailJPEG(thumbnailURL.ContentType, thumbnailPath)
+	if err != nil {
+		return err
+	}
+
 	fd.mutex.Lock()
-	faces, err := fd.rec.RecognizeFile(thumbnailPath)
+	faces, err := fd.rec.Recognize(thumbnail)
 	fd.mutex.Unlock()
 
 	if err != nil {
@@ -126,6 +137,26 @@ func (fd *faceDetector) DetectFaces(db *gorm.DB, media *models.Media) error {
 	return nil
 }
 
+// thumbnailJPEG reads a thumbnail as a JPEG image, as the recognizer only reads JPEG images.
+// Thumbnails of other formats, such as WebP, are converted to JPEG.
+func thumbnailJPEG(contentType string, thumbnailPath string) ([]byte, error) {
+	if contentType == "image/jpeg" {
+		return os.ReadFile(thumbnailPath)
+	}
+
+	img, err := imaging.Open(thumbnailPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode thumbnail to detect faces")
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, errors.Wrap(err, "encode thumbnail to detect faces")
+	}
+
+	return buf.Bytes(), nil
+}
+
 func (fd *faceDetector) classifyDescriptor(descriptor face.Descriptor) int32 {
 	return int32(fd.rec.ClassifyThreshold(descriptor, 0.2))
 }
@@ -151,7 +182,7 @@ func (fd *faceDetector) classifyFace(db *gorm.DB, face *face.Face, media *models
 
 	// If no match add it new to samples
 	if match < 0 {
-		log.Println("No match, assigning new face")
+		log.Debug(db.Statement.Context, "No match, assigning new face")
 
 		faceGroup = models.FaceGroup{
 			ImageFaces: []models.ImageFace{imageFace},
@@ -162,7 +193,7 @@ func (fd *faceDetector) classifyFace(db *gorm.DB, face *face.Face, media *models
 		}
 
 	} else {
-		log.Println("Found match")
+		log.Debug(db.Statement.Context, "Found match", "face_group_id", match)
 
 		if err := db.First(&faceGroup, int(match)).Error; err != nil {
 			return err
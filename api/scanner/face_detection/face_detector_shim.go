@@ -3,12 +3,12 @@
 package face_detection
 
 import (
-	"log"
+	"github.com/photoview/photoview/api/log"
 
 	"gorm.io/gorm"
 )
 
 func InitializeFaceDetector(db *gorm.DB) error {
-	log.Printf("Face detection disabled (at build-time)")
+	log.Info(db.Statement.Context, "Face detection disabled (at build-time)")
 	return nil
 }
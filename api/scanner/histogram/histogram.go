@@ -0,0 +1,90 @@
+package histogram
+
+import (
+	"image"
+	"os"
+
+	"github.com/disintegration/imaging"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	// Image decoders of the formats thumbnails are encoded in
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Compute counts the pixels of each brightness and color value of an image.
+// Transparent pixels are not counted, and luminance is weighted by the Rec. 709 coefficients.
+func Compute(img image.Image) *models.MediaHistogram {
+	histogram := &models.MediaHistogram{}
+	nrgba := imaging.Clone(img)
+
+	var pixels, luminanceSum int64
+	for y := 0; y < nrgba.Rect.Dy(); y++ {
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+nrgba.Rect.Dx()*4]
+		for i := 0; i < len(row); i += 4 {
+			if row[i+3] == 0 {
+				continue
+			}
+
+			r, g, b := row[i], row[i+1], row[i+2]
+			luminance := (2126*int(r) + 7152*int(g) + 722*int(b) + 5000) / 10000
+
+			histogram.Red[r]++
+			histogram.Green[g]++
+			histogram.Blue[b]++
+			histogram.Luminance[luminance]++
+
+			pixels++
+			luminanceSum += int64(luminance)
+		}
+	}
+
+	if pixels > 0 {
+		histogram.MeanLuminance = float64(luminanceSum) / float64(pixels) / (models.HistogramBinCount - 1)
+	}
+
+	return histogram
+}
+
+// SaveHistogram computes the histogram of a photo from its thumbnail and saves it, replacing the one it had
+func SaveHistogram(db *gorm.DB, media *models.Media) (*models.MediaHistogram, error) {
+	var thumbnail models.MediaURL
+	if err := db.Where("media_id = ? AND purpose = ?", media.ID, models.PhotoThumbnail).First(&thumbnail).Error; err != nil {
+		return nil, errors.Wrap(err, "get thumbnail of photo")
+	}
+	thumbnail.Media = media
+
+	thumbnailPath, err := thumbnail.CachedPath()
+	if err != nil {
+		return nil, err
+	}
+
+	imageFile, err := os.Open(thumbnailPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "open thumbnail of photo")
+	}
+	defer imageFile.Close()
+
+	img, _, err := image.Decode(imageFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode thumbnail of photo")
+	}
+
+	histogram := Compute(img)
+	histogram.MediaID = media.ID
+
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "media_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"luminance", "red", "green", "blue", "mean_luminance", "updated_at"}),
+	}).Create(histogram).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "save histogram of photo (%s)", media.Path)
+	}
+
+	return histogram, nil
+}
@@ -0,0 +1,44 @@
+package histogram_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/photoview/photoview/api/scanner/histogram"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompute(t *testing.T) {
+	// Half of the image is red, the other half white, and one pixel is transparent
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			if x < 5 {
+				img.SetNRGBA(x, y, color.NRGBA{255, 0, 0, 255})
+			} else {
+				img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+			}
+		}
+	}
+	img.SetNRGBA(9, 9, color.NRGBA{})
+
+	result := histogram.Compute(img)
+
+	assert.EqualValues(t, 99, result.Red[255])
+	assert.EqualValues(t, 50, result.Green[0])
+	assert.EqualValues(t, 49, result.Green[255])
+	assert.EqualValues(t, 50, result.Blue[0])
+	assert.EqualValues(t, 50, result.Luminance[54], "red has a luminance of 0.2126")
+	assert.EqualValues(t, 49, result.Luminance[255])
+	assert.InDelta(t, (50*54+49*255)/99.0/255.0, result.MeanLuminance, 0.0001)
+
+	var total int
+	for _, count := range result.Luminance.Ints() {
+		total += count
+	}
+	assert.Equal(t, 99, total)
+
+	empty := histogram.Compute(image.NewNRGBA(image.Rect(0, 0, 2, 2)))
+	assert.Zero(t, empty.MeanLuminance)
+}
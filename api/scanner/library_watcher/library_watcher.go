@@ -0,0 +1,294 @@
+// Package library_watcher watches the root paths of the users for changes, and queues the albums that have changed to be scanned
+// right away, instead of waiting for the next periodic scan.
+// File systems that don't report changes, such as network shares, are still only scanned periodically.
+package library_watcher
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/path_exclusion"
+	"github.com/photoview/photoview/api/scanner/scanner_queue"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// How long the library has to be left alone after a change before it is scanned,
+// so copying many files scans the album once they have all been copied
+const settleDelay = 2 * time.Second
+
+// The longest a change waits to be scanned, when changes keep coming
+const maxDelay = 30 * time.Second
+
+// How often the root paths are checked for paths added since the watcher started
+const refreshInterval = 5 * time.Minute
+
+type libraryWatcher struct {
+	db      *gorm.DB
+	watcher *fsnotify.Watcher
+	// Directories being watched, and root paths whose directories are being watched
+	watched map[string]bool
+	roots   map[string]bool
+	// Directories whose content has changed since they were last queued
+	changed map[string]bool
+}
+
+// InitializeLibraryWatcher starts watching the root paths of all users, if enabled by PHOTOVIEW_WATCH_LIBRARY.
+// If the platform can't watch files, the library is only scanned periodically.
+func InitializeLibraryWatcher(db *gorm.DB) error {
+	if !utils.EnvWatchLibrary.GetBool() {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Warn(ctx, "Watching library not supported, it is only scanned periodically", "error", err)
+		return nil
+	}
+
+	watcher := &libraryWatcher{
+		db:      db,
+		watcher: fsWatcher,
+		watched: make(map[string]bool),
+		roots:   make(map[string]bool),
+		changed: make(map[string]bool),
+	}
+
+	if err := watcher.refreshRoots(ctx); err != nil {
+		fsWatcher.Close()
+		return err
+	}
+
+	go watcher.run(ctx)
+
+	return nil
+}
+
+func (w *libraryWatcher) run(ctx context.Context) {
+	refresh := time.NewTicker(refreshInterval)
+	defer refresh.Stop()
+
+	var flush <-chan time.Time
+	var firstChange time.Time
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if !w.handleEvent(ctx, event) {
+				continue
+			}
+
+			if flush == nil {
+				firstChange = time.Now()
+			}
+
+			// Every change pushes the scan back, up to the longest delay since the first of them
+			delay := settleDelay
+			if remaining := maxDelay - time.Since(firstChange); remaining < delay {
+				delay = remaining
+			}
+			flush = time.After(delay)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// Changes have been missed, so everything is scanned
+				log.Warn(ctx, "Too many changes in library to watch, scanning all albums")
+				if err := scanner_queue.AddAllToQueue(ctx); err != nil {
+					log.Error(ctx, "Queueing albums of library", "error", err)
+				}
+				continue
+			}
+
+			log.Warn(ctx, "Watching library", "error", err)
+
+		case <-flush:
+			flush = nil
+			w.flush(ctx)
+
+		case <-refresh.C:
+			if err := w.refreshRoots(ctx); err != nil {
+				log.Warn(ctx, "Refreshing watched root paths", "error", err)
+			}
+		}
+	}
+}
+
+// handleEvent records the directory changed by an event, and returns whether the event changed anything
+func (w *libraryWatcher) handleEvent(ctx context.Context, event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+
+	log.Debug(ctx, "Library changed", "path", event.Name, "op", event.Op.String())
+
+	if event.Has(fsnotify.Create) {
+		if stat, err := os.Stat(event.Name); err == nil && stat.IsDir() {
+			// A new directory is a new album, which is found by scanning the albums of its owners
+			w.watchTree(ctx, event.Name)
+			w.changed[event.Name] = true
+			return true
+		}
+	}
+
+	if (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) && w.watched[event.Name] {
+		// The watches of removed directories are removed along with them
+		for dir := range w.watched {
+			if dir == event.Name || isWithin(dir, event.Name) {
+				delete(w.watched, dir)
+			}
+		}
+		w.changed[event.Name] = true
+		return true
+	}
+
+	w.changed[path.Dir(event.Name)] = true
+	return true
+}
+
+// flush queues the albums of the changed directories to be scanned.
+// Directories that have been added or removed change the tree of albums, so all albums of their owners are scanned.
+func (w *libraryWatcher) flush(ctx context.Context) {
+	ownerIDs := make(map[int]bool)
+
+	for dir := range w.changed {
+		delete(w.changed, dir)
+
+		var album models.Album
+		err := w.db.Where("path_hash = ?", models.MD5Hash(dir)).Limit(1).Find(&album).Error
+		if err != nil {
+			log.Error(ctx, "Finding album of changed directory", "path", dir, "error", err)
+			continue
+		}
+
+		if album.ID != 0 && isDir(dir) {
+			if err := scanner_queue.AddAlbumToQueue(ctx, &album); err != nil {
+				log.Error(ctx, "Queueing changed album", "album_id", album.ID, "error", err)
+			}
+			continue
+		}
+
+		owners, err := w.ownersOfPath(dir)
+		if err != nil {
+			log.Error(ctx, "Finding owners of changed directory", "path", dir, "error", err)
+			continue
+		}
+
+		for _, owner := range owners {
+			ownerIDs[owner] = true
+		}
+	}
+
+	for ownerID := range ownerIDs {
+		var user models.User
+		if err := w.db.First(&user, ownerID).Error; err != nil {
+			log.Error(ctx, "Finding owner of changed directory", "user_id", ownerID, "error", err)
+			continue
+		}
+
+		if err := scanner_queue.AddUserToQueue(ctx, &user); err != nil {
+			log.Error(ctx, "Queueing albums of user", "user_id", ownerID, "error", err)
+		}
+	}
+}
+
+// ownersOfPath returns the ids of the users owning the closest album containing the path
+func (w *libraryWatcher) ownersOfPath(dir string) ([]int, error) {
+	for current := path.Dir(dir); current != path.Dir(current); current = path.Dir(current) {
+		var album models.Album
+		if err := w.db.Where("path_hash = ?", models.MD5Hash(current)).Limit(1).Find(&album).Error; err != nil {
+			return nil, err
+		}
+
+		if album.ID == 0 {
+			continue
+		}
+
+		var ownerIDs []int
+		if err := w.db.Table("user_albums").Where("album_id = ?", album.ID).Pluck("user_id", &ownerIDs).Error; err != nil {
+			return nil, err
+		}
+
+		return ownerIDs, nil
+	}
+
+	return []int{}, nil
+}
+
+// refreshRoots watches the root paths that aren't watched yet, such as of users created since the watcher started
+func (w *libraryWatcher) refreshRoots(ctx context.Context) error {
+	var rootPaths []string
+	if err := w.db.Model(&models.Album{}).Where("parent_album_id IS NULL").Pluck("path", &rootPaths).Error; err != nil {
+		return errors.Wrap(err, "get root albums from database")
+	}
+
+	for _, rootPath := range rootPaths {
+		if w.roots[rootPath] {
+			continue
+		}
+
+		w.roots[rootPath] = true
+		if w.watchTree(ctx, rootPath) {
+			log.Info(ctx, "Watching library for changes", "path", rootPath)
+		}
+	}
+
+	return nil
+}
+
+// watchTree watches a directory and all directories below it, and returns whether all of them are being watched.
+// Directories that can't be watched, such as when the limit of watches has been reached, are only scanned periodically.
+func (w *libraryWatcher) watchTree(ctx context.Context, root string) bool {
+	err := filepath.WalkDir(root, func(dir string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() || w.watched[dir] {
+			return nil
+		}
+
+		// Changes to directories that are not scanned don't need a scan
+		if dir != root && path_exclusion.IsSkippedDirectory(entry.Name()) {
+			return filepath.SkipDir
+		}
+
+		if err := w.watcher.Add(dir); err != nil {
+			return errors.Wrapf(err, "watch directory (%s)", dir)
+		}
+		w.watched[dir] = true
+
+		return nil
+	})
+
+	if err != nil {
+		log.Warn(ctx, "Could not watch all of the library, the rest of it is only scanned periodically", "path", root, "error", err)
+		return false
+	}
+
+	return true
+}
+
+func isDir(dir string) bool {
+	stat, err := os.Stat(dir)
+	return err == nil && stat.IsDir()
+}
+
+// isWithin returns whether the path is below the directory
+func isWithin(filePath, dir string) bool {
+	return strings.HasPrefix(filePath, dir+"/")
+}
@@ -0,0 +1,71 @@
+package library_watcher_test
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/scanner/library_watcher"
+	"github.com/photoview/photoview/api/scanner/scanner_queue"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestLibraryWatcher(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "test_user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootPath := t.TempDir()
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	if !assert.NoError(t, scanner_queue.InitializeScannerQueue(db)) {
+		return
+	}
+	defer scanner_queue.CloseScannerQueue()
+
+	t.Setenv(utils.EnvWatchLibrary.GetName(), "1")
+	if !assert.NoError(t, library_watcher.InitializeLibraryWatcher(db)) {
+		return
+	}
+
+	photo, err := os.ReadFile("../test_data/lilac_lilac_bush_lilac.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mediaCount := func() int64 {
+		var count int64
+		assert.NoError(t, db.Model(&models.Media{}).Count(&count).Error)
+		return count
+	}
+
+	// Media added to an album is scanned
+	assert.NoError(t, os.WriteFile(path.Join(rootPath, "photo.jpg"), photo, 0644))
+	assert.Eventually(t, func() bool { return mediaCount() == 1 }, 20*time.Second, 250*time.Millisecond)
+
+	// New directories are scanned as new albums, along with the media added to them
+	albumPath := path.Join(rootPath, "album")
+	assert.NoError(t, os.Mkdir(albumPath, 0755))
+	assert.NoError(t, os.WriteFile(path.Join(albumPath, "photo.jpg"), photo, 0644))
+	assert.Eventually(t, func() bool { return mediaCount() == 2 }, 20*time.Second, 250*time.Millisecond)
+
+	// Media removed from an album is removed
+	assert.NoError(t, os.Remove(path.Join(rootPath, "photo.jpg")))
+	assert.Eventually(t, func() bool { return mediaCount() == 1 }, 20*time.Second, 250*time.Millisecond)
+}
@@ -0,0 +1,131 @@
+// Package live_photo pairs the photos and videos of Apple Live Photos, which are saved as a HEIC or JPEG photo
+// and a MOV video of the same name, sharing a content identifier.
+package live_photo
+
+import (
+	"context"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
+	"github.com/photoview/photoview/api/scanner/scanner_utils"
+	"github.com/pkg/errors"
+)
+
+// identifierSearchSize is how much of the start and the end of a file is searched for content identifiers.
+// Photos store it in the maker notes of their EXIF data, videos in the metadata of their moov box,
+// which is at the start or the end of the file.
+const identifierSearchSize = 512 * 1024
+
+// Content identifiers are upper case UUIDs, stored as text in both files
+var identifierRegex = regexp.MustCompile(`[0-9A-F]{8}-[0-9A-F]{4}-[0-9A-F]{4}-[0-9A-F]{4}-[0-9A-F]{12}`)
+
+// stillTypes are the types of the photos of live photos
+var stillTypes = []media_type.MediaType{media_type.TypeHeic, media_type.TypeJpeg}
+
+// VideoOfStill returns the path of the video next to a photo with the same name, if the photo is of a type of live photos
+func VideoOfStill(photoPath string) *string {
+	if !hasExtensionOf(photoPath, stillTypes...) {
+		return nil
+	}
+
+	return counterpart(photoPath, media_type.TypeMOV)
+}
+
+// StillOfVideo returns the path of the photo next to a MOV video with the same name
+func StillOfVideo(videoPath string) *string {
+	if !hasExtensionOf(videoPath, media_type.TypeMOV) {
+		return nil
+	}
+
+	return counterpart(videoPath, stillTypes...)
+}
+
+// IsPair returns whether a photo and a video are the parts of the same live photo, by their content identifiers
+func IsPair(ctx context.Context, photoPath string, videoPath string) (bool, error) {
+	photoIdentifiers, err := contentIdentifiers(ctx, photoPath)
+	if err != nil || len(photoIdentifiers) == 0 {
+		return false, err
+	}
+
+	videoIdentifiers, err := contentIdentifiers(ctx, videoPath)
+	if err != nil {
+		return false, err
+	}
+
+	for identifier := range videoIdentifiers {
+		if photoIdentifiers[identifier] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// contentIdentifiers returns the UUIDs stored near the start and the end of a file
+func contentIdentifiers(ctx context.Context, filePath string) (map[string]bool, error) {
+	file, err := scanner_io.Open(ctx, filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open file to read its content identifier (%s)", filePath)
+	}
+	defer file.Close()
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get size of file (%s)", filePath)
+	}
+
+	offsets := []int64{0}
+	if size > identifierSearchSize {
+		offsets = append(offsets, size-identifierSearchSize)
+	}
+
+	identifiers := make(map[string]bool)
+	for _, offset := range offsets {
+		data := make([]byte, identifierSearchSize)
+		n, err := file.ReadAt(data, offset)
+		if err != nil && err != io.EOF {
+			return nil, errors.Wrapf(err, "read content identifier of file (%s)", filePath)
+		}
+
+		for _, identifier := range identifierRegex.FindAll(data[:n], -1) {
+			identifiers[string(identifier)] = true
+		}
+	}
+
+	return identifiers, nil
+}
+
+func hasExtensionOf(filePath string, mediaTypes ...media_type.MediaType) bool {
+	extType, found := media_type.GetExtensionMediaType(path.Ext(filePath))
+	if !found {
+		return false
+	}
+
+	for _, mediaType := range mediaTypes {
+		if extType == mediaType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// counterpart returns the path of a file next to the given file with the same name, and an extension of the given types
+func counterpart(filePath string, mediaTypes ...media_type.MediaType) *string {
+	pathWithoutExt := strings.TrimSuffix(filePath, path.Ext(filePath))
+
+	for _, mediaType := range mediaTypes {
+		for _, ext := range mediaType.FileExtensions() {
+			testPath := pathWithoutExt + ext
+			if scanner_utils.FileExists(testPath) {
+				return &testPath
+			}
+		}
+	}
+
+	return nil
+}
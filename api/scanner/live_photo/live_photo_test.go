@@ -0,0 +1,70 @@
+package live_photo_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/photoview/photoview/api/scanner/live_photo"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.UnitTestRun(m))
+}
+
+const identifier = "0A4BC9E3-1F2D-4C7A-9B3E-5D6F7A8B9C0D"
+
+func writeFile(t *testing.T, filePath string, content []byte) {
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCounterparts(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, path.Join(dir, "IMG_0001.HEIC"), nil)
+	writeFile(t, path.Join(dir, "IMG_0001.MOV"), nil)
+	writeFile(t, path.Join(dir, "IMG_0002.jpg"), nil)
+	writeFile(t, path.Join(dir, "IMG_0003.mp4"), nil)
+
+	video := live_photo.VideoOfStill(path.Join(dir, "IMG_0001.HEIC"))
+	if assert.NotNil(t, video) {
+		assert.Equal(t, path.Join(dir, "IMG_0001.MOV"), *video)
+	}
+
+	still := live_photo.StillOfVideo(path.Join(dir, "IMG_0001.MOV"))
+	if assert.NotNil(t, still) {
+		assert.Equal(t, path.Join(dir, "IMG_0001.HEIC"), *still)
+	}
+
+	assert.Nil(t, live_photo.VideoOfStill(path.Join(dir, "IMG_0002.jpg")))
+	assert.Nil(t, live_photo.StillOfVideo(path.Join(dir, "IMG_0003.mp4")))
+}
+
+func TestIsPair(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	photoPath := path.Join(dir, "photo.heic")
+	writeFile(t, photoPath, []byte("\x00\x00Apple iOS\x00"+identifier+"\x00"))
+
+	// The identifier of videos is in their moov box, which may be at the end of the file
+	videoPath := path.Join(dir, "photo.mov")
+	video := make([]byte, 2*1024*1024)
+	copy(video[len(video)-100:], "com.apple.quicktime.content.identifier"+identifier)
+	writeFile(t, videoPath, video)
+
+	otherPath := path.Join(dir, "other.mov")
+	writeFile(t, otherPath, []byte("com.apple.quicktime.content.identifier"+"11111111-2222-3333-4444-555555555555"))
+
+	paired, err := live_photo.IsPair(ctx, photoPath, videoPath)
+	assert.NoError(t, err)
+	assert.True(t, paired)
+
+	paired, err = live_photo.IsPair(ctx, photoPath, otherPath)
+	assert.NoError(t, err)
+	assert.False(t, paired)
+}
@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"os"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/exif"
+	"github.com/photoview/photoview/api/scanner/face_detection"
+	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/scanner/scanner_tasks"
+	"github.com/photoview/photoview/api/scanner/scanner_tasks/processing_tasks"
+	"github.com/pkg/errors"
+)
+
+// mediaUpToDate returns whether a media has been processed since its file last changed, so scanning it again can be skipped.
+// Media whose file has changed since it was processed are reset, so they are processed again from scratch.
+func mediaUpToDate(ctx scanner_task.TaskContext, media *models.Media) (bool, error) {
+	// Media processed before the state of their file was recorded
+	if media.FileModTime == nil || media.FileSize == nil {
+		return false, nil
+	}
+
+	stat, err := os.Stat(media.Path)
+	if err != nil {
+		return false, errors.Wrapf(err, "stat media file (%s)", media.Path)
+	}
+
+	if stat.ModTime().Unix() != media.FileModTime.Unix() || stat.Size() != *media.FileSize {
+		log.Info(ctx, "Media file changed since it was processed", "path", media.Path)
+		return false, resetChangedMedia(ctx, media)
+	}
+
+	// The sidecar of a raw photo changes its generated images, and so do the configured sizes of the scaled copies of photos,
+	// which are handled while processing the photo
+	if media.Type == models.MediaTypePhoto {
+		mediaType, err := ctx.GetCache().GetMediaType(media.Path)
+		if err != nil {
+			return false, errors.Wrapf(err, "get type of media (%s)", media.Path)
+		}
+
+		if mediaType.IsRaw() && processing_tasks.SideCarChanged(ctx, media) {
+			return false, nil
+		}
+
+		scaledChanged, err := processing_tasks.ScaledPhotosChanged(ctx.GetDB(), media)
+		if err != nil || scaledChanged {
+			return false, err
+		}
+
+		avifChanged, err := processing_tasks.AVIFVariantsChanged(ctx.GetDB(), media)
+		if err != nil || avifChanged {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// resetChangedMedia removes what has been derived from the previous content of the file of a media,
+// its cached files, faces, histogram, exif and video metadata, then reads the metadata of the new content
+func resetChangedMedia(ctx scanner_task.TaskContext, media *models.Media) error {
+	err := ctx.DatabaseTransaction(func(ctx scanner_task.TaskContext) error {
+		db := ctx.GetDB()
+
+		// The cached files of the previous content are collected as garbage once no media uses them
+		if err := db.Where("media_id = ?", media.ID).Delete(&models.MediaURL{}).Error; err != nil {
+			return errors.Wrap(err, "delete media urls of changed media")
+		}
+
+		if err := db.Where("media_id = ?", media.ID).Delete(&models.ImageFace{}).Error; err != nil {
+			return errors.Wrap(err, "delete faces of changed media")
+		}
+
+		if err := db.Where("media_id = ?", media.ID).Delete(&models.MediaHistogram{}).Error; err != nil {
+			return errors.Wrap(err, "delete histogram of changed media")
+		}
+
+		exifID, videoMetadataID := media.ExifID, media.VideoMetadataID
+		err := db.Model(media).Updates(map[string]interface{}{
+			"exif_id":           nil,
+			"video_metadata_id": nil,
+			"content_hash":      nil,
+			"blurhash":          nil,
+			"file_mod_time":     nil,
+			"file_size":         nil,
+		}).Error
+		if err != nil {
+			return errors.Wrap(err, "reset changed media")
+		}
+
+		// Metadata is deleted once the media no longer references it, as deleting it cascades to the media
+		if exifID != nil {
+			if err := db.Delete(&models.MediaEXIF{}, *exifID).Error; err != nil {
+				return errors.Wrap(err, "delete exif of changed media")
+			}
+		}
+
+		if videoMetadataID != nil {
+			if err := db.Delete(&models.VideoMetadata{}, *videoMetadataID).Error; err != nil {
+				return errors.Wrap(err, "delete video metadata of changed media")
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	media.MediaURL = nil
+	media.Exif, media.ExifID = nil, nil
+	media.VideoMetadata, media.VideoMetadataID = nil, nil
+	media.ContentHash, media.Blurhash = nil, nil
+	media.FileModTime, media.FileSize = nil, nil
+
+	if face_detection.GlobalFaceDetector != nil {
+		if err := face_detection.GlobalFaceDetector.ReloadFacesFromDatabase(ctx.GetDB()); err != nil {
+			return errors.Wrap(err, "reload faces from database")
+		}
+	}
+
+	if _, err := exif.SaveEXIF(ctx.GetDB(), media); err != nil {
+		log.Warn(ctx, "SaveEXIF failed", "media", media.Title, "error", err)
+	}
+
+	if media.Type == models.MediaTypeVideo {
+		if err := scanner_tasks.ScanVideoMetadata(ctx.GetDB(), media); err != nil {
+			log.Warn(ctx, "ScanVideoMetadata failed", "media", media.Title, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// recordMediaFile saves the modification time and size the file of a media had when it was processed
+func recordMediaFile(ctx scanner_task.TaskContext, media *models.Media, stat os.FileInfo) error {
+	modTime, size := stat.ModTime(), stat.Size()
+
+	err := ctx.GetDB().Model(media).Updates(map[string]interface{}{
+		"file_mod_time": modTime,
+		"file_size":     size,
+	}).Error
+	if err != nil {
+		return errors.Wrapf(err, "record file of media (%s)", media.Path)
+	}
+
+	media.FileModTime, media.FileSize = &modTime, &size
+	return nil
+}
@@ -0,0 +1,72 @@
+package scanner_test
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalRescan(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "test_user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	albumPath := t.TempDir()
+	photoPath := path.Join(albumPath, "photo.jpg")
+	copyFile := func(source string) {
+		data, err := os.ReadFile(source)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(photoPath, data, 0644))
+	}
+	copyFile("./test_data/lilac_lilac_bush_lilac.jpg")
+
+	if _, err := scanner.NewRootAlbum(db, albumPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	getMedia := func() *models.Media {
+		var media models.Media
+		assert.NoError(t, db.Preload("MediaURL").Where("path = ?", photoPath).First(&media).Error)
+		return &media
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	media := getMedia()
+	if !assert.NotNil(t, media.FileModTime) || !assert.NotNil(t, media.FileSize) {
+		return
+	}
+	assert.Len(t, media.MediaURL, 2)
+	contentHash := media.ContentHash
+
+	// Unchanged media are not processed again, so the removed thumbnail is not generated by the rescan
+	assert.NoError(t, db.Where("media_id = ? AND purpose = ?", media.ID, models.PhotoThumbnail).Delete(&models.MediaURL{}).Error)
+
+	test_utils.RunScannerOnUser(t, db, user)
+	assert.Len(t, getMedia().MediaURL, 1)
+
+	// Changed media are processed again from scratch
+	copyFile("./test_data/buttercup_close_summer_yellow.jpg")
+	later := media.FileModTime.Add(time.Minute)
+	assert.NoError(t, os.Chtimes(photoPath, later, later))
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	media = getMedia()
+	assert.Len(t, media.MediaURL, 2)
+	assert.NotEqual(t, contentHash, media.ContentHash)
+	if assert.NotNil(t, media.FileModTime) {
+		assert.Equal(t, later.Unix(), media.FileModTime.Unix())
+	}
+}
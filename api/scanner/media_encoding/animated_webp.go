@@ -0,0 +1,161 @@
+package media_encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/webp"
+)
+
+// maxWebPChunkSize is the largest chunk of a WebP image that is read, frames are much smaller than this
+const maxWebPChunkSize = 64 * 1024 * 1024
+
+// decodeAnimatedWebP decodes the first frame of an animated WebP image, as the WebP decoder only supports still images.
+// The frame is drawn on a canvas of the size of the animation, as it may be smaller than it.
+func decodeAnimatedWebP(imagePath string) (image.Image, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open animated webp (%s)", imagePath)
+	}
+	defer file.Close()
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, errors.Wrapf(err, "could not read header of animated webp (%s)", imagePath)
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return nil, errors.Errorf("not a webp image (%s)", imagePath)
+	}
+
+	var canvas image.Rectangle
+	for {
+		chunkType, chunk, err := readWebPChunk(file)
+		if err == io.EOF {
+			return nil, errors.Errorf("animated webp has no frames (%s)", imagePath)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read animated webp (%s)", imagePath)
+		}
+
+		switch chunkType {
+		case "VP8X":
+			if len(chunk) < 10 {
+				return nil, errors.Errorf("invalid extended header of webp (%s)", imagePath)
+			}
+			canvas = image.Rect(0, 0, int(uint24(chunk[4:7]))+1, int(uint24(chunk[7:10]))+1)
+		case "ANMF":
+			frame, offset, err := decodeWebPFrame(chunk)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not decode first frame of animated webp (%s)", imagePath)
+			}
+
+			if canvas.Empty() || frame.Bounds().Add(offset) == canvas {
+				return frame, nil
+			}
+
+			result := image.NewNRGBA(canvas)
+			draw.Draw(result, frame.Bounds().Add(offset), frame, frame.Bounds().Min, draw.Over)
+			return result, nil
+		}
+	}
+}
+
+// decodeWebPFrame decodes the frame of an animation frame chunk, by wrapping its image data in a still WebP image.
+// It returns the frame and its offset on the canvas.
+func decodeWebPFrame(chunk []byte) (image.Image, image.Point, error) {
+	// The frame header has its offset, size, duration and flags, its image data chunks follow
+	if len(chunk) < 16 {
+		return nil, image.Point{}, errors.New("invalid frame header")
+	}
+	offset := image.Pt(int(uint24(chunk[0:3]))*2, int(uint24(chunk[3:6]))*2)
+	width, height := uint24(chunk[6:9])+1, uint24(chunk[9:12])+1
+
+	reader := bytes.NewReader(chunk[16:])
+	var alpha, data []byte
+	for data == nil {
+		chunkType, frameChunk, err := readWebPChunk(reader)
+		if err != nil {
+			return nil, image.Point{}, errors.Wrap(err, "read image data of frame")
+		}
+
+		switch chunkType {
+		case "ALPH":
+			alpha = webPChunk(chunkType, frameChunk)
+		case "VP8 ", "VP8L":
+			data = webPChunk(chunkType, frameChunk)
+		}
+	}
+
+	var still []byte
+	if alpha != nil {
+		// Lossy images with transparency need the extended header
+		extended := make([]byte, 10)
+		const alphaBit = 1 << 4
+		extended[0] = alphaBit
+		putUint24(extended[4:7], width-1)
+		putUint24(extended[7:10], height-1)
+		still = append(webPChunk("VP8X", extended), alpha...)
+	}
+	still = append(still, data...)
+
+	riff := make([]byte, 12, 12+len(still))
+	copy(riff, "RIFF")
+	binary.LittleEndian.PutUint32(riff[4:8], uint32(4+len(still)))
+	copy(riff[8:], "WEBP")
+
+	frame, err := webp.Decode(bytes.NewReader(append(riff, still...)))
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+
+	return frame, offset, nil
+}
+
+// readWebPChunk reads the type and the data of the next chunk of a WebP image
+func readWebPChunk(r io.Reader) (string, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(header[4:8])
+	if size > maxWebPChunkSize {
+		return "", nil, errors.Errorf("webp chunk too large (%d bytes)", size)
+	}
+
+	// Chunks are padded to an even size
+	data := make([]byte, size+size%2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", nil, err
+	}
+
+	return string(header[0:4]), data[:size], nil
+}
+
+// webPChunk encodes a chunk of a WebP image
+func webPChunk(chunkType string, data []byte) []byte {
+	chunk := make([]byte, 8, 8+len(data)+1)
+	copy(chunk, chunkType)
+	binary.LittleEndian.PutUint32(chunk[4:8], uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
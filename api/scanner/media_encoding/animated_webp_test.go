@@ -0,0 +1,139 @@
+package media_encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/stretchr/testify/assert"
+)
+
+// bitWriter writes the least significant bits first, as in lossless WebP bitstreams
+type bitWriter struct {
+	data  []byte
+	nbits uint
+}
+
+func (w *bitWriter) write(value uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		if w.nbits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte((value>>i)&1) << (w.nbits % 8)
+		w.nbits++
+	}
+}
+
+// losslessWebPData encodes a lossless WebP bitstream of a single color,
+// all of its prefix codes have a single symbol so the pixels take no bits
+func losslessWebPData(width, height int, c color.NRGBA) []byte {
+	w := &bitWriter{data: []byte{0x2f}, nbits: 8}
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	w.write(1, 1) // alpha is used
+	w.write(0, 3) // version
+	w.write(0, 1) // no transforms
+	w.write(0, 1) // no color cache
+	w.write(0, 1) // no meta prefix codes
+
+	for _, symbol := range []uint8{c.G, c.R, c.B, c.A} {
+		w.write(1, 1) // simple code
+		w.write(0, 1) // of one symbol
+		w.write(1, 1) // of eight bits
+		w.write(uint32(symbol), 8)
+	}
+
+	// Distance code
+	w.write(1, 1)
+	w.write(0, 1)
+	w.write(0, 1)
+	w.write(0, 1)
+
+	return w.data
+}
+
+func riffWebP(chunks ...[]byte) []byte {
+	content := bytes.Join(chunks, nil)
+	riff := make([]byte, 12)
+	copy(riff, "RIFF")
+	binary.LittleEndian.PutUint32(riff[4:8], uint32(4+len(content)))
+	copy(riff[8:], "WEBP")
+	return append(riff, content...)
+}
+
+func animationFrame(x, y, width, height int, c color.NRGBA) []byte {
+	header := make([]byte, 16)
+	putUint24(header[0:3], uint32(x/2))
+	putUint24(header[3:6], uint32(y/2))
+	putUint24(header[6:9], uint32(width-1))
+	putUint24(header[9:12], uint32(height-1))
+	putUint24(header[12:15], 100)
+	return webPChunk("ANMF", append(header, webPChunk("VP8L", losslessWebPData(width, height, c))...))
+}
+
+func animatedWebP(frames ...[]byte) []byte {
+	extended := make([]byte, 10)
+	extended[0] = 1<<1 | 1<<4
+	putUint24(extended[4:7], 40-1)
+	putUint24(extended[7:10], 30-1)
+
+	chunks := [][]byte{webPChunk("VP8X", extended), webPChunk("ANIM", make([]byte, 6))}
+	return riffWebP(append(chunks, frames...)...)
+}
+
+func TestAnimatedWebP(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+
+	dir := t.TempDir()
+	animatedPath := path.Join(dir, "animated.webp")
+	if err := os.WriteFile(animatedPath, animatedWebP(animationFrame(0, 0, 40, 30, red), animationFrame(0, 0, 40, 30, blue)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stillPath := path.Join(dir, "still.webp")
+	if err := os.WriteFile(stillPath, riffWebP(webPChunk("VP8L", losslessWebPData(40, 30, blue))), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	animated, err := media_type.IsAnimated(animatedPath)
+	assert.NoError(t, err)
+	assert.True(t, animated)
+
+	animated, err = media_type.IsAnimated(stillPath)
+	assert.NoError(t, err)
+	assert.False(t, animated)
+
+	frame, err := openThumbnailImage(animatedPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 40, 30), frame.Bounds())
+		assert.Equal(t, red, color.NRGBAModel.Convert(frame.At(20, 15)))
+	}
+
+	still, err := openThumbnailImage(stillPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, blue, color.NRGBAModel.Convert(still.At(20, 15)))
+	}
+}
+
+func TestAnimatedWebPSmallFrame(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+
+	animatedPath := path.Join(t.TempDir(), "animated.webp")
+	if err := os.WriteFile(animatedPath, animatedWebP(animationFrame(10, 4, 20, 10, red)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := decodeAnimatedWebP(animatedPath)
+	if assert.NoError(t, err) {
+		// The frame is drawn on a transparent canvas of the size of the animation
+		assert.Equal(t, image.Rect(0, 0, 40, 30), frame.Bounds())
+		assert.Equal(t, red, color.NRGBAModel.Convert(frame.At(15, 8)))
+		assert.Equal(t, color.NRGBA{}, color.NRGBAModel.Convert(frame.At(5, 5)))
+	}
+}
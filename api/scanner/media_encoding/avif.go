@@ -0,0 +1,38 @@
+package media_encoding
+
+import (
+	"context"
+
+	"github.com/disintegration/imaging"
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+// Quantizer range of AVIF images, roughly matching the quality of the generated JPEG images at a smaller size
+const (
+	avifMinQuantizer = 18
+	avifMaxQuantizer = 32
+)
+
+// EncodeAVIFEnabled returns whether AVIF copies of the thumbnails and web copies of photos should be encoded,
+// which requires avifenc to be installed
+func EncodeAVIFEnabled() bool {
+	return utils.EnvEncodeAVIF.GetBool() && executable_worker.AvifencCli.IsInstalled()
+}
+
+// EncodeAVIF encodes an AVIF copy of a generated JPEG or WebP image
+func EncodeAVIF(ctx context.Context, inputPath string, outputPath string) error {
+	if !executable_worker.AvifencCli.IsInstalled() {
+		return errors.New("could not encode AVIF image as avifenc was not found")
+	}
+
+	inputImage, err := imaging.Open(inputPath)
+	if err != nil {
+		return errors.Wrapf(err, "open image to encode as AVIF (%s)", inputPath)
+	}
+
+	return withLosslessCopy(inputImage, "photoview-avif", func(losslessPath string) error {
+		return executable_worker.AvifencCli.EncodeAvif(ctx, losslessPath, outputPath, avifMinQuantizer, avifMaxQuantizer)
+	})
+}
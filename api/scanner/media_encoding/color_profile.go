@@ -0,0 +1,400 @@
+package media_encoding
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+)
+
+// maxColorProfileSize limits the size of the ICC profiles that are read
+const maxColorProfileSize = 4 * 1024 * 1024
+
+const (
+	iccSignature = "ICC_PROFILE\x00"
+	// tiffTagICCProfile is the TIFF tag holding the ICC profile of the image
+	tiffTagICCProfile = 0x8773
+)
+
+// xyzD50ToLinearSRGB converts colors of the profile connection space of ICC profiles, XYZ relative to D50,
+// to linear sRGB values, with Bradford chromatic adaptation to D65
+var xyzD50ToLinearSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// colorProfile is an RGB matrix/TRC ICC profile, such as the profiles of sRGB, Adobe RGB, ProPhoto RGB and Display P3,
+// which converts colors with a tone curve for each channel and a matrix
+type colorProfile struct {
+	// curves are the tone response curves of the red, green and blue channels, to linear values
+	curves [3]func(float64) float64
+	// matrix converts the linear values to XYZ values, its columns are the colorants of the channels
+	matrix [3][3]float64
+}
+
+// imageFile is an image file that can be read at offsets
+type imageFile interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// readColorProfile reads the ICC profile embedded in a JPEG, PNG, TIFF or WebP image, it returns nil if it has none
+func readColorProfile(file imageFile) ([]byte, error) {
+	header := make([]byte, 12)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "read image header")
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8}):
+		return jpegColorProfile(file)
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return pngColorProfile(file)
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return tiffColorProfile(file)
+	case len(header) == 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return webPColorProfile(file)
+	}
+
+	return nil, nil
+}
+
+// jpegColorProfile joins the chunks of the ICC profile of a JPEG image, which are stored in numbered APP2 segments
+func jpegColorProfile(r io.ReaderAt) ([]byte, error) {
+	segments, err := readJPEGSegments(r)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := map[byte][]byte{}
+	for _, segment := range segments {
+		if segment.marker == jpegMarkerAPP2 && bytes.HasPrefix(segment.data, []byte(iccSignature)) && len(segment.data) > len(iccSignature)+2 {
+			chunks[segment.data[len(iccSignature)]] = segment.data[len(iccSignature)+2:]
+		}
+	}
+
+	numbers := make([]int, 0, len(chunks))
+	for number := range chunks {
+		numbers = append(numbers, int(number))
+	}
+	sort.Ints(numbers)
+
+	var profile []byte
+	for _, number := range numbers {
+		profile = append(profile, chunks[byte(number)]...)
+	}
+
+	return profile, nil
+}
+
+// pngColorProfile reads the compressed ICC profile of a PNG image, from its chunk before the image data
+func pngColorProfile(r io.ReaderAt) ([]byte, error) {
+	offset := int64(8)
+	header := make([]byte, 8)
+
+	for {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return nil, nil
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		switch string(header[4:8]) {
+		case "IDAT", "IEND":
+			return nil, nil
+		case "iCCP":
+			if length > maxColorProfileSize {
+				return nil, errors.New("png color profile too large")
+			}
+
+			data := make([]byte, length)
+			if _, err := r.ReadAt(data, offset+8); err != nil {
+				return nil, errors.Wrap(err, "read png color profile")
+			}
+
+			// The profile has a name, and a byte of the compression method before its compressed data
+			nameEnd := bytes.IndexByte(data, 0)
+			if nameEnd < 0 || nameEnd+2 > len(data) {
+				return nil, errors.New("invalid png color profile")
+			}
+
+			reader, err := zlib.NewReader(bytes.NewReader(data[nameEnd+2:]))
+			if err != nil {
+				return nil, errors.Wrap(err, "decompress png color profile")
+			}
+			defer reader.Close()
+
+			return io.ReadAll(io.LimitReader(reader, maxColorProfileSize))
+		}
+
+		offset += 8 + length + 4
+	}
+}
+
+// tiffColorProfile reads the ICC profile of the first directory of a TIFF image
+func tiffColorProfile(r io.ReaderAt) ([]byte, error) {
+	tiff, err := newTIFFReader(r)
+	if err != nil || tiff == nil {
+		return nil, err
+	}
+
+	ifd, _, err := tiff.readIFD(tiff.firstIFD)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, found := ifd[tiffTagICCProfile]
+	if !found || entry.count <= 4 {
+		return nil, nil
+	}
+	if entry.count > maxColorProfileSize {
+		return nil, errors.New("tiff color profile too large")
+	}
+
+	profile := make([]byte, entry.count)
+	if _, err := r.ReadAt(profile, int64(tiff.order.Uint32(entry.value))); err != nil {
+		return nil, errors.Wrap(err, "read tiff color profile")
+	}
+
+	return profile, nil
+}
+
+// webPColorProfile reads the ICC profile chunk of a WebP image
+func webPColorProfile(r io.ReadSeeker) ([]byte, error) {
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	for {
+		chunkType, data, err := readWebPChunk(r)
+		if err != nil {
+			return nil, nil
+		}
+
+		switch chunkType {
+		case "ICCP":
+			return data, nil
+		case "VP8 ", "VP8L", "ANMF":
+			// The profile comes before the image data
+			return nil, nil
+		}
+	}
+}
+
+// parseColorProfile parses an RGB matrix/TRC ICC profile, it returns nil if the profile is of another kind
+func parseColorProfile(data []byte) (*colorProfile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, errors.New("invalid ICC profile")
+	}
+
+	if string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		return nil, nil
+	}
+
+	tags := map[string][]byte{}
+	tagCount := int(binary.BigEndian.Uint32(data[128:]))
+	for i := 0; i < tagCount && 132+i*12+12 <= len(data); i++ {
+		entry := data[132+i*12:]
+		offset, size := int(binary.BigEndian.Uint32(entry[4:])), int(binary.BigEndian.Uint32(entry[8:]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			return nil, errors.New("invalid ICC profile tag")
+		}
+		tags[string(entry[0:4])] = data[offset : offset+size]
+	}
+
+	profile := &colorProfile{}
+	for channel, prefix := range []string{"r", "g", "b"} {
+		colorant, found := tags[prefix+"XYZ"]
+		if !found || len(colorant) < 20 || string(colorant[0:4]) != "XYZ " {
+			return nil, nil
+		}
+		for i := 0; i < 3; i++ {
+			profile.matrix[i][channel] = s15Fixed16(colorant[8+i*4:])
+		}
+
+		curve, err := parseToneCurve(tags[prefix+"TRC"])
+		if err != nil || curve == nil {
+			return nil, err
+		}
+		profile.curves[channel] = curve
+	}
+
+	return profile, nil
+}
+
+// parseToneCurve parses a curve or parametric curve of an ICC profile, it returns nil if the curve is missing
+func parseToneCurve(data []byte) (func(float64) float64, error) {
+	if len(data) < 12 {
+		return nil, nil
+	}
+
+	switch string(data[0:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(data[8:]))
+		switch {
+		case count == 0:
+			return func(x float64) float64 { return x }, nil
+		case count == 1 && len(data) >= 14:
+			gamma := float64(binary.BigEndian.Uint16(data[12:])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }, nil
+		case len(data) >= 12+count*2:
+			table := make([]float64, count)
+			for i := range table {
+				table[i] = float64(binary.BigEndian.Uint16(data[12+i*2:])) / 0xFFFF
+			}
+			return func(x float64) float64 {
+				position := x * float64(count-1)
+				i := int(position)
+				if i >= count-1 {
+					return table[count-1]
+				}
+				return table[i] + (table[i+1]-table[i])*(position-float64(i))
+			}, nil
+		}
+	case "para":
+		functionType := binary.BigEndian.Uint16(data[8:])
+		paramCounts := []int{1, 3, 4, 5, 7}
+		if int(functionType) >= len(paramCounts) || len(data) < 12+paramCounts[functionType]*4 {
+			return nil, errors.Errorf("unsupported ICC parametric curve %d", functionType)
+		}
+
+		// Missing parameters have the values that make the curve the same as the simpler ones
+		p := [7]float64{1, 1, 0, 0, math.Inf(-1), 0, 0}
+		for i := 0; i < paramCounts[functionType]; i++ {
+			p[i] = s15Fixed16(data[12+i*4:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+
+		switch functionType {
+		case 0:
+			return func(x float64) float64 { return math.Pow(x, g) }, nil
+		case 1, 2:
+			return func(x float64) float64 {
+				if x >= -b/a {
+					return math.Pow(a*x+b, g) + c
+				}
+				return c
+			}, nil
+		default:
+			return func(x float64) float64 {
+				if x >= d {
+					return math.Pow(a*x+b, g) + e
+				}
+				return c*x + f
+			}, nil
+		}
+	}
+
+	return nil, errors.Errorf("unsupported ICC curve type %q", data[0:4])
+}
+
+func s15Fixed16(data []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(data))) / 65536
+}
+
+// toSRGB converts an image of the color profile to sRGB. Images already in sRGB, or a color space close to it,
+// are returned as they are. Colors outside of the sRGB gamut are clipped.
+func (profile *colorProfile) toSRGB(img image.Image) image.Image {
+	var matrix [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				matrix[i][j] += xyzD50ToLinearSRGB[i][k] * profile.matrix[k][j]
+			}
+		}
+	}
+
+	if profile.isSRGB(matrix) {
+		return img
+	}
+
+	var curves [3][256]float64
+	for channel := range curves {
+		for value := range curves[channel] {
+			curves[channel][value] = profile.curves[channel](float64(value) / 255)
+		}
+	}
+
+	var encode [4096]uint8
+	for i := range encode {
+		encode[i] = uint8(math.Round(srgbEncode(float64(i)/float64(len(encode)-1)) * 255))
+	}
+
+	result := imaging.Clone(img)
+	for i := 0; i+4 <= len(result.Pix); i += 4 {
+		pixel := result.Pix[i : i+3]
+		linear := [3]float64{curves[0][pixel[0]], curves[1][pixel[1]], curves[2][pixel[2]]}
+
+		for channel := 0; channel < 3; channel++ {
+			value := matrix[channel][0]*linear[0] + matrix[channel][1]*linear[1] + matrix[channel][2]*linear[2]
+			value = math.Max(0, math.Min(1, value))
+			pixel[channel] = encode[int(value*float64(len(encode)-1)+0.5)]
+		}
+	}
+
+	return result
+}
+
+// isSRGB returns whether the colors of the profile are the colors of sRGB, when its matrix to linear sRGB is
+// close to the identity matrix and its curves are close to the curve of sRGB
+func (profile *colorProfile) isSRGB(matrix [3][3]float64) bool {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			identity := 0.0
+			if i == j {
+				identity = 1
+			}
+			if math.Abs(matrix[i][j]-identity) > 0.02 {
+				return false
+			}
+		}
+	}
+
+	for _, curve := range profile.curves {
+		for _, x := range []float64{0.02, 0.25, 0.5, 0.75} {
+			if math.Abs(curve(x)-srgbDecode(x)) > 0.01 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func srgbDecode(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+func srgbEncode(x float64) float64 {
+	if x <= 0.0031308 {
+		return x * 12.92
+	}
+	return 1.055*math.Pow(x, 1/2.4) - 0.055
+}
+
+// convertToSRGB converts a decoded image to sRGB, by the ICC profile embedded in the file it was decoded from.
+// Images without a profile, or with a profile that is not supported, are returned as they are.
+func convertToSRGB(img image.Image, file imageFile) (image.Image, error) {
+	data, err := readColorProfile(file)
+	if err != nil || len(data) == 0 {
+		return img, err
+	}
+
+	profile, err := parseColorProfile(data)
+	if err != nil || profile == nil {
+		return img, err
+	}
+
+	return profile.toSRGB(img), nil
+}
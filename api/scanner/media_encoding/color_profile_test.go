@@ -0,0 +1,111 @@
+package media_encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testColorProfile builds a matrix/TRC ICC profile, with the colorants of its channels and a gamma curve for all of them
+func testColorProfile(colorants [3][3]float64, gamma float64) []byte {
+	fixed := func(value float64) []byte {
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint32(data, uint32(int32(value*65536)))
+		return data
+	}
+
+	var colorantTags [][]byte
+	for _, colorant := range colorants {
+		tag := []byte("XYZ \x00\x00\x00\x00")
+		for _, value := range colorant {
+			tag = append(tag, fixed(value)...)
+		}
+		colorantTags = append(colorantTags, tag)
+	}
+	curve := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01")
+	curve = append(curve, byte(gamma), byte((gamma-float64(int(gamma)))*256), 0, 0)
+
+	tagData := append(bytes.Join(colorantTags, nil), curve...)
+	offset := 128 + 4 + 6*12
+
+	profile := make([]byte, offset)
+	copy(profile[16:], "RGB XYZ ")
+	copy(profile[36:], "acsp")
+	binary.BigEndian.PutUint32(profile[128:], 6)
+	for i, signature := range []string{"rXYZ", "gXYZ", "bXYZ", "rTRC", "gTRC", "bTRC"} {
+		entry := profile[132+i*12:]
+		copy(entry, signature)
+		if i < 3 {
+			binary.BigEndian.PutUint32(entry[4:], uint32(offset+i*20))
+			binary.BigEndian.PutUint32(entry[8:], 20)
+		} else {
+			binary.BigEndian.PutUint32(entry[4:], uint32(offset+60))
+			binary.BigEndian.PutUint32(entry[8:], 14)
+		}
+	}
+	profile = append(profile, tagData...)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+
+	return profile
+}
+
+var adobeRGBColorants = [3][3]float64{{0.6097, 0.3111, 0.0195}, {0.2053, 0.6257, 0.0609}, {0.1492, 0.0632, 0.7446}}
+
+func TestColorProfileToSRGB(t *testing.T) {
+	profile, err := parseColorProfile(testColorProfile(adobeRGBColorants, 2.2))
+	if !assert.NoError(t, err) || !assert.NotNil(t, profile) {
+		return
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{R: 128, G: 128, B: 128, A: 255})
+	img.Set(1, 0, color.NRGBA{R: 64, G: 128, B: 64, A: 255})
+
+	converted := profile.toSRGB(img).(*image.NRGBA)
+
+	// Grays stay gray, saturated colors of Adobe RGB are more saturated in sRGB
+	gray := converted.NRGBAAt(0, 0)
+	assert.InDelta(t, 128, int(gray.R), 1)
+	assert.InDelta(t, 128, int(gray.G), 1)
+	assert.InDelta(t, 128, int(gray.B), 1)
+
+	green := converted.NRGBAAt(1, 0)
+	assert.Greater(t, int(green.G)-int(green.R), 64)
+	assert.Equal(t, uint8(255), green.A)
+}
+
+func TestColorProfileSRGB(t *testing.T) {
+	srgbColorants := [3][3]float64{{0.4361, 0.2225, 0.0139}, {0.3851, 0.7169, 0.0971}, {0.1431, 0.0606, 0.7141}}
+	profile, err := parseColorProfile(testColorProfile(srgbColorants, 2.2))
+	if !assert.NoError(t, err) || !assert.NotNil(t, profile) {
+		return
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	assert.Same(t, img, profile.toSRGB(img))
+}
+
+func TestJPEGColorProfile(t *testing.T) {
+	profile := testColorProfile(adobeRGBColorants, 2.2)
+
+	// The profile is split in two chunks, of which the second one comes first in the file
+	chunk := func(number byte, data []byte) []byte {
+		return jpegSegmentBytes(jpegMarkerAPP2, append([]byte(iccSignature+string([]byte{number, 2})), data...))
+	}
+	data := testJPEG(t, 8, 8)
+	file := append([]byte{0xFF, 0xD8}, chunk(2, profile[100:])...)
+	file = append(file, chunk(1, profile[:100])...)
+	file = append(file, data[2:]...)
+
+	read, err := readColorProfile(bytes.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, profile, read)
+
+	read, err = readColorProfile(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Empty(t, read)
+}
@@ -0,0 +1,75 @@
+package media_encoding
+
+import (
+	"image"
+	"image/color"
+)
+
+// bayerMatrix is the threshold map of ordered dithering, of 8x8 pixels
+var bayerMatrix = [8][8]uint8{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// ditherTo8Bit converts images of 16 bits per channel, such as 16-bit TIFF and PNG images, to 8 bits per channel
+// with ordered dithering. Converting them by dropping their low bits shows bands in smooth gradients, such as skies.
+// Images of 8 bits per channel are returned as they are.
+func ditherTo8Bit(img image.Image) image.Image {
+	bounds := img.Bounds()
+
+	switch src := img.(type) {
+	case *image.Gray16:
+		dst := image.NewGray(bounds)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				dst.Pix[dst.PixOffset(x, y)] = dither16(src.Gray16At(x, y).Y, x, y)
+			}
+		}
+		return dst
+	case *image.NRGBA64:
+		dst := image.NewNRGBA(bounds)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				setDithered(dst, x, y, src.NRGBA64At(x, y))
+			}
+		}
+		return dst
+	case *image.RGBA64:
+		dst := image.NewNRGBA(bounds)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				setDithered(dst, x, y, color.NRGBA64Model.Convert(src.RGBA64At(x, y)).(color.NRGBA64))
+			}
+		}
+		return dst
+	}
+
+	return img
+}
+
+func setDithered(dst *image.NRGBA, x, y int, c color.NRGBA64) {
+	i := dst.PixOffset(x, y)
+	dst.Pix[i+0] = dither16(c.R, x, y)
+	dst.Pix[i+1] = dither16(c.G, x, y)
+	dst.Pix[i+2] = dither16(c.B, x, y)
+	dst.Pix[i+3] = dither16(c.A, x, y)
+}
+
+// dither16 converts a value of 16 bits to 8 bits, rounding it up when the fraction it is above the lower 8-bit value
+// exceeds the threshold of the pixel
+func dither16(value uint16, x, y int) uint8 {
+	scaled := uint32(value) * 0xFF
+	result, fraction := scaled/0xFFFF, (scaled%0xFFFF)>>8
+
+	threshold := uint32(bayerMatrix[y&7][x&7])*4 + 2
+	if fraction >= threshold && result < 0xFF {
+		result++
+	}
+	return uint8(result)
+}
@@ -0,0 +1,41 @@
+package media_encoding
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDitherTo8Bit(t *testing.T) {
+	// Half way between the 8-bit values 0x12 and 0x13
+	value := uint16(0x12*257 + 128)
+
+	gray := image.NewGray16(image.Rect(0, 0, 8, 8))
+	for i := 0; i < 64; i++ {
+		gray.SetGray16(i%8, i/8, color.Gray16{value})
+	}
+
+	dithered, ok := ditherTo8Bit(gray).(*image.Gray)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	// Half of the pixels are rounded up, so the average keeps the value of the 16-bit image
+	counts := map[uint8]int{}
+	for _, pixel := range dithered.Pix {
+		counts[pixel]++
+	}
+	assert.Equal(t, map[uint8]int{0x12: 32, 0x13: 32}, counts)
+
+	// The extremes are kept
+	rgba := image.NewNRGBA64(image.Rect(0, 0, 2, 1))
+	rgba.SetNRGBA64(0, 0, color.NRGBA64{0, 0xFFFF, 0, 0xFFFF})
+	rgba.SetNRGBA64(1, 0, color.NRGBA64{0xFFFF, 0, 0xFFFF, 0xFFFF})
+	assert.Equal(t, []uint8{0, 0xFF, 0, 0xFF, 0xFF, 0, 0xFF, 0xFF}, ditherTo8Bit(rgba).(*image.NRGBA).Pix)
+
+	// Images of 8 bits are not converted
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	assert.Same(t, img, ditherTo8Bit(img))
+}
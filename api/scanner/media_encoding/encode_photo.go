@@ -5,15 +5,19 @@ import (
 	"image"
 	"image/jpeg"
 	"os"
+	"path"
 	"time"
 
 	"github.com/disintegration/imaging"
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
 	"github.com/photoview/photoview/api/scanner/media_encoding/media_utils"
 	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
 	"github.com/photoview/photoview/api/utils"
 	"github.com/pkg/errors"
+	"golang.org/x/image/tiff"
 	"gopkg.in/vansante/go-ffprobe.v2"
 
 	_ "github.com/strukturag/libheif/go/heif"
@@ -30,42 +34,111 @@ var thumbFilter = map[models.ThumbnailFilter]imaging.ResampleFilter{
 	models.ThumbnailFilterLanczos:	imaging.Lanczos,
 }
 
-func EncodeThumbnail(db *gorm.DB, inputPath string, outputPath string) (*media_utils.PhotoDimensions, error) {
+// EncodeScaledPhoto encodes a copy of the photo in the given format, scaled down to the given size of its longest side,
+// on the graphics card if it is enabled, or with the configured image backend, and the filter configured for thumbnails
+func EncodeScaledPhoto(db *gorm.DB, inputPath string, outputPath string, size int, format ImageFormat) (*media_utils.PhotoDimensions, error) {
+	ctx := db.Statement.Context
+	quality := ThumbnailQuality()
+
+	// Photos that fail to be scaled on the graphics card are scaled by the configured image backend instead
+	if acceleration, enabled := GPUScalingEnabled(); enabled && gpuCanScale(inputPath) {
+		dimensions, err := encodeScaledPhotoGPU(ctx, inputPath, outputPath, size, format, quality, acceleration)
+		if err == nil {
+			return dimensions, nil
+		}
+
+		log.Warn(ctx, "Scaling photo on the graphics card, scaling it with the image backend instead", "path", inputPath, "error", err)
+	}
+
+	// Images libvips fails to read are scaled by the built-in image pipeline instead
+	if ConfiguredImageBackend() == ImageBackendVips {
+		dimensions, err := encodeScaledPhotoVips(ctx, inputPath, outputPath, size, format, quality)
+		if err == nil {
+			return dimensions, nil
+		}
+
+		log.Warn(ctx, "Scaling photo with libvips, scaling it with the built-in image pipeline instead", "path", inputPath, "error", err)
+	}
 
 	var siteInfo models.SiteInfo
 	if err := db.First(&siteInfo).Error; err != nil {
 		return nil, err
 	}
 
-	inputImage, err := imaging.Open(inputPath, imaging.AutoOrientation(true))
+	return encodeScaledPhotoGo(ctx, inputPath, outputPath, size, format, quality, thumbFilter[siteInfo.ThumbnailMethod])
+}
+
+// encodeScaledPhotoGo scales a photo down to the given size of its longest side with the built-in image pipeline,
+// and returns the dimensions of the scaled copy
+func encodeScaledPhotoGo(ctx context.Context, inputPath string, outputPath string, size int, format ImageFormat, quality int, filter imaging.ResampleFilter) (*media_utils.PhotoDimensions, error) {
+	inputImage, err := openThumbnailImage(inputPath)
 	if err != nil {
 		return nil, err
 	}
 
 	dimensions := media_utils.PhotoDimensionsFromRect(inputImage.Bounds())
-	dimensions = dimensions.ThumbnailScale()
+	dimensions = dimensions.ScaleToFit(size)
 
-	thumbImage := imaging.Resize(inputImage, dimensions.Width, dimensions.Height, thumbFilter[siteInfo.ThumbnailMethod])
-	if err = encodeImageJPEG(thumbImage, outputPath, 60); err != nil {
+	thumbImage := imaging.Resize(inputImage, dimensions.Width, dimensions.Height, filter)
+	if err = encodeImage(ctx, thumbImage, outputPath, format, quality); err != nil {
 		return nil, err
 	}
 
 	return &dimensions, nil
 }
 
-func encodeImageJPEG(image image.Image, outputPath string, jpegQuality int) error {
-	photo_file, err := os.Create(outputPath)
+// ScaleWebVersion scales the web version of a photo down in place, to the given size of its longest side in the given format,
+// and returns its new dimensions. The gain map of the web version is not kept.
+func ScaleWebVersion(ctx context.Context, filePath string, format ImageFormat, size int) (*media_utils.PhotoDimensions, error) {
+	return encodeScaledPhotoGo(ctx, filePath, filePath, size, format, WebVersionQuality(), imaging.Lanczos)
+}
+
+// openThumbnailImage decodes the photo a thumbnail is generated from. Of animated WebP images the first frame is decoded,
+// animated PNG images are decoded as their default image, which is usually their first frame.
+// Images of 16 bits per channel are dithered to 8 bits, and images with an embedded color profile are converted to sRGB,
+// before they are scaled down.
+func openThumbnailImage(inputPath string) (image.Image, error) {
+	mediaType, err := media_type.GetMediaType(inputPath)
+	if err == nil && mediaType != nil && *mediaType == media_type.TypeWebp {
+		animated, err := media_type.IsAnimated(inputPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if animated {
+			return decodeAnimatedWebP(inputPath)
+		}
+	}
+
+	inputImage, err := imaging.Open(inputPath, imaging.AutoOrientation(true))
 	if err != nil {
-		return errors.Wrapf(err, "could not create file: %s", outputPath)
+		return nil, err
 	}
-	defer photo_file.Close()
 
-	err = jpeg.Encode(photo_file, image, &jpeg.Options{Quality: jpegQuality})
+	file, err := os.Open(inputPath)
 	if err != nil {
-		return err
+		return nil, errors.Wrapf(err, "open image to read its color profile (%s)", inputPath)
 	}
+	defer file.Close()
 
-	return nil
+	return convertToSRGB(ditherTo8Bit(inputImage), file)
+}
+
+func encodeImageJPEG(image image.Image, outputPath string, jpegQuality int) error {
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		photo_file, err := os.Create(tmpPath)
+		if err != nil {
+			return errors.Wrapf(err, "could not create file: %s", outputPath)
+		}
+		defer photo_file.Close()
+
+		err = jpeg.Encode(photo_file, image, &jpeg.Options{Quality: jpegQuality})
+		if err != nil {
+			return err
+		}
+
+		return photo_file.Close()
+	})
 }
 
 // EncodeMediaData is used to easily decode media data, with a cache so expensive operations are not repeated
@@ -98,7 +171,8 @@ func (img *EncodeMediaData) ContentType() (*media_type.MediaType, error) {
 	return imgType, nil
 }
 
-func (img *EncodeMediaData) EncodeHighRes(outputPath string) error {
+// EncodeHighRes encodes the web copy of a photo browsers can't display, in the given format
+func (img *EncodeMediaData) EncodeHighRes(ctx context.Context, outputPath string, format ImageFormat) error {
 	contentType, err := img.ContentType()
 	if err != nil {
 		return err
@@ -108,30 +182,128 @@ func (img *EncodeMediaData) EncodeHighRes(outputPath string) error {
 		return errors.New("could not convert photo as file format is not supported")
 	}
 
+	if *contentType == media_type.TypeSvg {
+		return img.encodeSVG(ctx, outputPath, format)
+	}
+
 	// Use darktable if there is no counterpart JPEG file to use instead
 	if contentType.IsRaw() && img.CounterpartPath == nil {
+		if encoded, err := img.encodeRawPreview(ctx, *contentType, outputPath, format); err != nil || encoded {
+			return err
+		}
+
 		if executable_worker.DarktableCli.IsInstalled() {
-			err := executable_worker.DarktableCli.EncodeJpeg(img.Media.Path, outputPath, 70)
+			err := executable_worker.DarktableCli.EncodeJpeg(ctx, img.Media.Path, outputPath, WebVersionQuality())
 			if err != nil {
 				return err
 			}
+
+			if err := convertJPEGFile(ctx, outputPath, format, WebVersionQuality()); err != nil {
+				return err
+			}
 		} else {
 			return errors.New("could not convert photo as no RAW converter was found")
 		}
 	} else {
-		image, err := img.photoImage()
+		image, err := img.photoImage(ctx)
 		if err != nil {
 			return err
 		}
 
-		encodeImageJPEG(image, outputPath, 70)
+		// Gain maps are only kept in JPEG images, photos encoded in other formats show in SDR
+		if format != ImageFormatJPEG {
+			return encodeImage(ctx, image, outputPath, format, WebVersionQuality())
+		}
+
+		if err := img.encodeHighResJPEG(ctx, image, outputPath); err != nil {
+			return errors.Wrap(err, "encode high-res jpeg")
+		}
 	}
 
 	return nil
 }
 
+// encodeHighResJPEG encodes the decoded photo as its high-res photo. The gain map of photos with one is kept,
+// so they still show in HDR on displays that support it, instead of only as their SDR rendition.
+func (img *EncodeMediaData) encodeHighResJPEG(ctx context.Context, image image.Image, outputPath string) error {
+	gainMap, err := img.gainMap(ctx)
+	if err != nil {
+		log.Warn(ctx, "Reading gain map of photo, it is encoded without it", "path", img.Media.Path, "error", err)
+		gainMap = nil
+	}
+
+	// Photos with a gain map are kept as baseline JPEG images, as rewriting them would not keep the gain map after the image
+	if gainMap == nil {
+		if err := encodeImageJPEG(image, outputPath, WebVersionQuality()); err != nil {
+			return err
+		}
+
+		return makeProgressiveJPEG(ctx, outputPath)
+	}
+
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		photoFile, err := os.Create(tmpPath)
+		if err != nil {
+			return errors.Wrapf(err, "could not create file: %s", outputPath)
+		}
+		defer photoFile.Close()
+
+		if err := encodeJPEGWithGainMap(photoFile, image, gainMap, WebVersionQuality()); err != nil {
+			return err
+		}
+
+		return photoFile.Close()
+	})
+}
+
+// gainMap reads the gain map of the JPEG file the photo is decoded from, it returns nil if it has none
+func (img *EncodeMediaData) gainMap(ctx context.Context) (*gainMapJPEG, error) {
+	photoPath := img.Media.Path
+	if img.CounterpartPath != nil {
+		photoPath = *img.CounterpartPath
+	}
+
+	if mediaType, found := media_type.GetExtensionMediaType(path.Ext(photoPath)); !found || mediaType != media_type.TypeJpeg {
+		return nil, nil
+	}
+
+	file, err := scanner_io.Open(ctx, photoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readGainMap(file)
+}
+
+// encodeRawPreview encodes the preview embedded in a raw photo by the camera, which is much faster than developing the photo,
+// and works for formats darktable cannot develop, such as CR3 files with versions before 3.8.
+// It returns false if the photo has no preview large enough that it can read, it is developed then.
+func (img *EncodeMediaData) encodeRawPreview(ctx context.Context, contentType media_type.MediaType, outputPath string, format ImageFormat) (bool, error) {
+	preview, err := extractRawPreview(ctx, img.Media.Path, contentType)
+	if err != nil {
+		log.Warn(ctx, "Reading preview of raw photo, it is developed instead", "path", img.Media.Path, "error", err)
+		return false, nil
+	}
+	if preview == nil {
+		return false, nil
+	}
+
+	previewImage, err := preview.decode()
+	if err != nil {
+		log.Warn(ctx, "Decoding preview of raw photo, it is developed instead", "path", img.Media.Path, "error", err)
+		return false, nil
+	}
+
+	if err := encodeImage(ctx, previewImage, outputPath, format, WebVersionQuality()); err != nil {
+		return false, errors.Wrap(err, "encode high-res photo from raw preview")
+	}
+
+	return true, nil
+}
+
 // photoImage reads and decodes the image file and saves it in a cache so the photo in only decoded once
-func (img *EncodeMediaData) photoImage() (image.Image, error) {
+func (img *EncodeMediaData) photoImage(ctx context.Context) (image.Image, error) {
 	if img._photoImage != nil {
 		return img._photoImage, nil
 	}
@@ -143,7 +315,7 @@ func (img *EncodeMediaData) photoImage() (image.Image, error) {
 		photoPath = img.Media.Path
 	}
 
-	photoImg, err := img.decodeImage(photoPath)
+	photoImg, err := img.decodeImage(ctx, photoPath)
 	if err != nil {
 		return nil, utils.HandleError("image decoding", err)
 	}
@@ -152,8 +324,8 @@ func (img *EncodeMediaData) photoImage() (image.Image, error) {
 	return img._photoImage, nil
 }
 
-func (img *EncodeMediaData) decodeImage(imagePath string) (image.Image, error) {
-	file, err := os.Open(imagePath)
+func (img *EncodeMediaData) decodeImage(ctx context.Context, imagePath string) (image.Image, error) {
+	file, err := scanner_io.Open(ctx, imagePath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to open file to decode image (%s)", imagePath)
 	}
@@ -171,6 +343,19 @@ func (img *EncodeMediaData) decodeImage(imagePath string) (image.Image, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to decode HEIF image (%s)", imagePath)
 		}
+	} else if *mediaType == media_type.TypePsd {
+		decodedImage, err = decodePSD(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode photoshop document (%s)", imagePath)
+		}
+	} else if *mediaType == media_type.TypeTiff {
+		// Only the first page of multi-page TIFF images is decoded. The file is passed to the decoder as it is,
+		// as it reads the whole file into memory when it can't read at offsets.
+		decodedImage, err = tiff.Decode(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode TIFF image (%s)", imagePath)
+		}
+		decodedImage = orientImage(decodedImage, media_utils.ReadOrientation(file))
 	} else {
 		decodedImage, err = imaging.Decode(file, imaging.AutoOrientation(true))
 		if err != nil {
@@ -178,7 +363,7 @@ func (img *EncodeMediaData) decodeImage(imagePath string) (image.Image, error) {
 		}
 	}
 
-	return decodedImage, nil
+	return convertToSRGB(ditherTo8Bit(decodedImage), file)
 }
 
 func (enc *EncodeMediaData) VideoMetadata() (*ffprobe.ProbeData, error) {
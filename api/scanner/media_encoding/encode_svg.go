@@ -0,0 +1,76 @@
+package media_encoding
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path"
+
+	"github.com/disintegration/imaging"
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
+	"github.com/photoview/photoview/api/scanner/svg"
+	"github.com/pkg/errors"
+)
+
+// svgHighResSize is the size of the square the high resolution photos of SVG images are rasterized to fit in
+const svgHighResSize = 2048
+
+// encodeSVG rasterizes an SVG image to a high resolution photo. A sanitized copy is rasterized, so the rasterizer does
+// not load the files the image refers to. It is drawn on a white background, as JPEG images have no transparency.
+func (img *EncodeMediaData) encodeSVG(ctx context.Context, outputPath string, format ImageFormat) error {
+	if !executable_worker.RsvgCli.IsInstalled() {
+		return errors.New("could not convert SVG image as rsvg-convert was not found")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "photoview-svg")
+	if err != nil {
+		return errors.Wrap(err, "create temporary directory for SVG image")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sanitizedPath := path.Join(tmpDir, "sanitized.svg")
+	if err := sanitizeSVGFile(ctx, img.Media.Path, sanitizedPath); err != nil {
+		return err
+	}
+
+	rasterPath := path.Join(tmpDir, "raster.png")
+	if err := executable_worker.RsvgCli.EncodePng(ctx, sanitizedPath, rasterPath, svgHighResSize); err != nil {
+		return err
+	}
+
+	raster, err := imaging.Open(rasterPath)
+	if err != nil {
+		return errors.Wrap(err, "decode rasterized SVG image")
+	}
+
+	background := imaging.New(raster.Bounds().Dx(), raster.Bounds().Dy(), color.White)
+	flattened := imaging.Overlay(background, raster, image.Pt(0, 0), 1)
+
+	if err := encodeImage(ctx, flattened, outputPath, format, WebVersionQuality()); err != nil {
+		return errors.Wrap(err, "encode high-res photo of SVG image")
+	}
+
+	return nil
+}
+
+func sanitizeSVGFile(ctx context.Context, inputPath string, outputPath string) error {
+	input, err := scanner_io.Open(ctx, inputPath)
+	if err != nil {
+		return errors.Wrapf(err, "open SVG image (%s)", inputPath)
+	}
+	defer input.Close()
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return errors.Wrap(err, "create sanitized SVG image")
+	}
+	defer output.Close()
+
+	if err := svg.Sanitize(output, input); err != nil {
+		return errors.Wrapf(err, "sanitize SVG image (%s)", inputPath)
+	}
+
+	return output.Close()
+}
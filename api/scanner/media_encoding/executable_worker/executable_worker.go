@@ -1,25 +1,45 @@
 package executable_worker
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
+	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/photoview/photoview/api/features"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/tracing"
 	"github.com/photoview/photoview/api/utils"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 	"gopkg.in/vansante/go-ffprobe.v2"
 )
 
 func InitializeExecutableWorkers() {
 	DarktableCli = newDarktableWorker()
 	FfmpegCli = newFfmpegWorker()
+	RsvgCli = newRsvgWorker()
+	CwebpCli = newCwebpWorker()
+	AvifencCli = newAvifencWorker()
+	JpegtranCli = newJpegtranWorker()
+	VipsCli = newVipsWorker()
 }
 
 var DarktableCli *DarktableWorker = nil
 var FfmpegCli *FfmpegWorker = nil
+var RsvgCli *RsvgWorker = nil
+var CwebpCli *CwebpWorker = nil
+var AvifencCli *AvifencWorker = nil
+var JpegtranCli *JpegtranWorker = nil
+var VipsCli *VipsWorker = nil
 
 type ExecutableWorker interface {
 	Path() string
@@ -31,25 +51,49 @@ type DarktableWorker struct {
 
 type FfmpegWorker struct {
 	path string
+	// hardwareEncoders are the hardware encoders ffmpeg was built with
+	hardwareEncoders map[string]bool
+	// hardwareFilters are the filters ffmpeg was built with scaling frames on graphics devices
+	hardwareFilters map[string]bool
+}
+
+type RsvgWorker struct {
+	path string
+}
+
+type CwebpWorker struct {
+	path string
+}
+
+type AvifencWorker struct {
+	path string
+}
+
+type JpegtranWorker struct {
+	path string
+}
+
+type VipsWorker struct {
+	path string
 }
 
 func newDarktableWorker() *DarktableWorker {
-	if utils.EnvDisableRawProcessing.GetBool() {
-		log.Printf("Executable worker disabled (%s=1): darktable\n", utils.EnvDisableRawProcessing.GetName())
+	if !features.Enabled(models.FeatureRawProcessing) {
+		log.Info(context.Background(), "Executable worker disabled: darktable", "env", utils.EnvDisableRawProcessing.GetName()+"=1")
 		return nil
 	}
 
 	path, err := exec.LookPath("darktable-cli")
 	if err != nil {
-		log.Println("Executable worker not found: darktable")
+		log.Info(context.Background(), "Executable worker not found: darktable")
 	} else {
 		version, err := exec.Command(path, "--version").Output()
 		if err != nil {
-			log.Printf("Error getting version of darktable: %s\n", err)
+			log.Error(context.Background(), "Error getting version of darktable", "error", err)
 			return nil
 		}
 
-		log.Printf("Found executable worker: darktable (%s)\n", strings.Split(string(version), "\n")[0])
+		log.Info(context.Background(), "Found executable worker: darktable", "version", strings.Split(string(version), "\n")[0])
 
 		return &DarktableWorker{
 			path: path,
@@ -60,24 +104,142 @@ func newDarktableWorker() *DarktableWorker {
 }
 
 func newFfmpegWorker() *FfmpegWorker {
-	if utils.EnvDisableVideoEncoding.GetBool() {
-		log.Printf("Executable worker disabled (%s=1): ffmpeg\n", utils.EnvDisableVideoEncoding.GetName())
+	if !features.Enabled(models.FeatureVideoTranscoding) {
+		log.Info(context.Background(), "Executable worker disabled: ffmpeg", "env", utils.EnvDisableVideoEncoding.GetName()+"=1")
 		return nil
 	}
 
 	path, err := exec.LookPath("ffmpeg")
 	if err != nil {
-		log.Println("Executable worker not found: ffmpeg")
+		log.Info(context.Background(), "Executable worker not found: ffmpeg")
 	} else {
 		version, err := exec.Command(path, "-version").Output()
 		if err != nil {
-			log.Printf("Error getting version of ffmpeg: %s\n", err)
+			log.Error(context.Background(), "Error getting version of ffmpeg", "error", err)
 			return nil
 		}
 
-		log.Printf("Found executable worker: ffmpeg (%s)\n", strings.Split(string(version), "\n")[0])
+		log.Info(context.Background(), "Found executable worker: ffmpeg", "version", strings.Split(string(version), "\n")[0])
+
+		encoders, err := exec.Command(path, "-hide_banner", "-encoders").Output()
+		if err != nil {
+			log.Warn(context.Background(), "Error listing encoders of ffmpeg, videos are encoded with the processor", "error", err)
+		}
+
+		filters, err := exec.Command(path, "-hide_banner", "-filters").Output()
+		if err != nil {
+			log.Warn(context.Background(), "Error listing filters of ffmpeg, photos are scaled with the processor", "error", err)
+		}
 
 		return &FfmpegWorker{
+			path:             path,
+			hardwareEncoders: hardwareEncoders(string(encoders)),
+			hardwareFilters:  hardwareFilters(string(filters)),
+		}
+	}
+
+	return nil
+}
+
+func newRsvgWorker() *RsvgWorker {
+	path, err := exec.LookPath("rsvg-convert")
+	if err != nil {
+		log.Info(context.Background(), "Executable worker not found: rsvg-convert")
+	} else {
+		version, err := exec.Command(path, "--version").Output()
+		if err != nil {
+			log.Error(context.Background(), "Error getting version of rsvg-convert", "error", err)
+			return nil
+		}
+
+		log.Info(context.Background(), "Found executable worker: rsvg-convert", "version", strings.Split(string(version), "\n")[0])
+
+		return &RsvgWorker{
+			path: path,
+		}
+	}
+
+	return nil
+}
+
+func newCwebpWorker() *CwebpWorker {
+	path, err := exec.LookPath("cwebp")
+	if err != nil {
+		log.Info(context.Background(), "Executable worker not found: cwebp")
+	} else {
+		version, err := exec.Command(path, "-version").Output()
+		if err != nil {
+			log.Error(context.Background(), "Error getting version of cwebp", "error", err)
+			return nil
+		}
+
+		log.Info(context.Background(), "Found executable worker: cwebp", "version", strings.Split(string(version), "\n")[0])
+
+		return &CwebpWorker{
+			path: path,
+		}
+	}
+
+	return nil
+}
+
+func newAvifencWorker() *AvifencWorker {
+	path, err := exec.LookPath("avifenc")
+	if err != nil {
+		log.Info(context.Background(), "Executable worker not found: avifenc")
+	} else {
+		version, err := exec.Command(path, "--version").Output()
+		if err != nil {
+			log.Error(context.Background(), "Error getting version of avifenc", "error", err)
+			return nil
+		}
+
+		log.Info(context.Background(), "Found executable worker: avifenc", "version", strings.Split(string(version), "\n")[0])
+
+		return &AvifencWorker{
+			path: path,
+		}
+	}
+
+	return nil
+}
+
+func newJpegtranWorker() *JpegtranWorker {
+	path, err := exec.LookPath("jpegtran")
+	if err != nil {
+		log.Info(context.Background(), "Executable worker not found: jpegtran")
+	} else {
+		// jpegtran prints its version to stderr
+		version, err := exec.Command(path, "-version").CombinedOutput()
+		if err != nil {
+			log.Error(context.Background(), "Error getting version of jpegtran", "error", err)
+			return nil
+		}
+
+		log.Info(context.Background(), "Found executable worker: jpegtran", "version", strings.Split(string(version), "\n")[0])
+
+		return &JpegtranWorker{
+			path: path,
+		}
+	}
+
+	return nil
+}
+
+func newVipsWorker() *VipsWorker {
+	path, err := exec.LookPath("vipsthumbnail")
+	if err != nil {
+		log.Info(context.Background(), "Executable worker not found: vipsthumbnail")
+	} else {
+		version, err := exec.Command(path, "--vips-version").Output()
+		if err != nil {
+			log.Error(context.Background(), "Error getting version of vipsthumbnail", "error", err)
+			return nil
+		}
+
+		log.Info(context.Background(), "Found executable worker: vipsthumbnail", "version", strings.Split(string(version), "\n")[0])
+
+		return &VipsWorker{
 			path: path,
 		}
 	}
@@ -93,71 +255,352 @@ func (worker *FfmpegWorker) IsInstalled() bool {
 	return worker != nil
 }
 
-func (worker *DarktableWorker) EncodeJpeg(inputPath string, outputPath string, jpegQuality int) error {
+func (worker *DarktableWorker) EncodeJpeg(ctx context.Context, inputPath string, outputPath string, jpegQuality int) error {
 	tmpDir, err := ioutil.TempDir("/tmp", "photoview-darktable")
 	if err != nil {
-		log.Fatal(err)
+		return errors.Wrap(err, "create temporary directory for darktable")
 	}
 	defer os.RemoveAll(tmpDir)
 
-	args := []string{
-		inputPath,
-		outputPath,
-		"--core",
-		"--conf",
-		fmt.Sprintf("plugins/imageio/format/jpeg/quality=%d", jpegQuality),
-		"--configdir",
-		tmpDir,
-	}
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		args := []string{
+			inputPath,
+			tmpPath,
+			"--core",
+			"--conf",
+			fmt.Sprintf("plugins/imageio/format/jpeg/quality=%d", jpegQuality),
+			"--configdir",
+			tmpDir,
+		}
+
+		if err := runCommand(ctx, worker.path, args...); err != nil {
+			return errors.Wrapf(err, "encoding image using: %s %v", worker.path, args)
+		}
+
+		return nil
+	})
+}
 
-	cmd := exec.Command(worker.path, args...)
+func (worker *RsvgWorker) IsInstalled() bool {
+	return worker != nil
+}
+
+// EncodePng rasterizes an SVG image to a PNG image that fits in a square of the given size, keeping its aspect ratio
+func (worker *RsvgWorker) EncodePng(ctx context.Context, inputPath string, outputPath string, size int) error {
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		args := []string{
+			"--format", "png",
+			"--width", strconv.Itoa(size),
+			"--height", strconv.Itoa(size),
+			"--keep-aspect-ratio",
+			"--output", tmpPath,
+			inputPath,
+		}
+
+		if err := runCommand(ctx, worker.path, args...); err != nil {
+			return errors.Wrapf(err, "rasterizing image using: %s %v", worker.path, args)
+		}
+
+		return nil
+	})
+}
+
+func (worker *CwebpWorker) IsInstalled() bool {
+	return worker != nil
+}
+
+// EncodeWebp encodes a PNG, JPEG or TIFF image as a lossy WebP image of the given quality, without its metadata
+func (worker *CwebpWorker) EncodeWebp(ctx context.Context, inputPath string, outputPath string, quality int) error {
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		args := []string{
+			"-quiet",
+			"-q", strconv.Itoa(quality),
+			"-metadata", "none",
+			inputPath,
+			"-o", tmpPath,
+		}
+
+		if err := runCommand(ctx, worker.path, args...); err != nil {
+			return errors.Wrapf(err, "encoding image using: %s %v", worker.path, args)
+		}
+
+		return nil
+	})
+}
+
+func (worker *AvifencWorker) IsInstalled() bool {
+	return worker != nil
+}
+
+// EncodeAvif encodes a PNG or JPEG image as an AVIF image, with the given range of quantizers from 0, lossless, to 63
+func (worker *AvifencWorker) EncodeAvif(ctx context.Context, inputPath string, outputPath string, minQuantizer int, maxQuantizer int) error {
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		args := []string{
+			"--speed", "6",
+			"--min", strconv.Itoa(minQuantizer),
+			"--max", strconv.Itoa(maxQuantizer),
+			"--ignore-exif",
+			"--ignore-xmp",
+			inputPath,
+			tmpPath,
+		}
+
+		if err := runCommand(ctx, worker.path, args...); err != nil {
+			return errors.Wrapf(err, "encoding image using: %s %v", worker.path, args)
+		}
+
+		return nil
+	})
+}
+
+func (worker *JpegtranWorker) IsInstalled() bool {
+	return worker != nil
+}
 
-	if err := cmd.Run(); err != nil {
-		return errors.Wrapf(err, "encoding image using: %s %v", worker.path, args)
+// MakeProgressive rewrites a JPEG image as a progressive JPEG in place, without decoding it again,
+// so browsers can show it at a low resolution before it has loaded completely
+func (worker *JpegtranWorker) MakeProgressive(ctx context.Context, filePath string) error {
+	return utils.WriteFileAtomic(filePath, func(tmpPath string) error {
+		args := []string{
+			"-progressive",
+			"-optimize",
+			"-copy", "all",
+			"-outfile", tmpPath,
+			filePath,
+		}
+
+		if err := runCommand(ctx, worker.path, args...); err != nil {
+			return errors.Wrapf(err, "making image progressive using: %s %v", worker.path, args)
+		}
+
+		return nil
+	})
+}
+
+func (worker *VipsWorker) IsInstalled() bool {
+	return worker != nil
+}
+
+// Thumbnail scales an image down to fit inside a square of the given size, without scaling it up, in sRGB.
+// The format of the output is given by the extension of the output path, with the save options, such as "[Q=60,strip]".
+func (worker *VipsWorker) Thumbnail(ctx context.Context, inputPath string, outputPath string, size int, saveOptions string) error {
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		args := []string{
+			inputPath,
+			"--size", fmt.Sprintf("%dx%d>", size, size),
+			"--export-profile", "srgb",
+			"-o", tmpPath + saveOptions,
+		}
+
+		if err := runCommand(ctx, worker.path, args...); err != nil {
+			return errors.Wrapf(err, "scaling image using: %s %v", worker.path, args)
+		}
+
+		return nil
+	})
+}
+
+// EncodeWebVideo transcodes a video to a web video of the given codec, which browsers can play.
+// The configured hardware acceleration is used if ffmpeg has an encoder of the codec for it,
+// videos are encoded with the processor if it has none or it fails.
+// The progress of the encoding, from 0 to 1 of the duration of the video, is reported to onProgress as it changes.
+func (worker *FfmpegWorker) EncodeWebVideo(ctx context.Context, inputPath string, outputPath string, codec VideoCodec, durationSeconds float64, onProgress func(progress float64)) error {
+	if acceleration := VideoHardwareAcceleration(); acceleration != HardwareAccelerationNone {
+		encoder := acceleration.encoder(codec)
+		args, found := webVideoArgs(inputPath, codec, acceleration)
+
+		switch {
+		case !found:
+			log.Warn(ctx, "Hardware acceleration has no encoder of the codec, encoding video with the processor",
+				"acceleration", acceleration, "codec", codec)
+		case !worker.hardwareEncoders[encoder]:
+			log.Warn(ctx, "ffmpeg has no hardware encoder, encoding video with the processor", "encoder", encoder)
+		default:
+			err := worker.encodeWebVideo(ctx, args, outputPath, durationSeconds, onProgress)
+			if err == nil {
+				return nil
+			}
+			log.Warn(ctx, "Encoding video with hardware acceleration failed, encoding it with the processor",
+				"path", inputPath, "encoder", encoder, "error", err)
+		}
 	}
 
-	return nil
+	args, _ := webVideoArgs(inputPath, codec, HardwareAccelerationNone)
+	return worker.encodeWebVideo(ctx, args, outputPath, durationSeconds, onProgress)
 }
 
-func (worker *FfmpegWorker) EncodeMp4(inputPath string, outputPath string) error {
-	args := []string{
-		"-i",
-		inputPath,
-		"-vcodec", "h264",
-		"-acodec", "aac",
-		"-vf", "scale='min(1080,iw)':'min(1080,ih)':force_original_aspect_ratio=decrease:force_divisible_by=2",
-		"-movflags", "+faststart+use_metadata_tags",
-		outputPath,
+func (worker *FfmpegWorker) encodeWebVideo(ctx context.Context, args []string, outputPath string, durationSeconds float64, onProgress func(progress float64)) error {
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		args := append(args, "-progress", "pipe:1", "-nostats", tmpPath)
+
+		err := runCommandWithProgress(ctx, worker.path, args, func(outTimeSeconds float64) {
+			if onProgress != nil && durationSeconds > 0 {
+				onProgress(math.Min(outTimeSeconds/durationSeconds, 1))
+			}
+		})
+		if err != nil {
+			return errors.Wrapf(err, "encoding video using: %s", worker.path)
+		}
+
+		return nil
+	})
+}
+
+// HasHardwareScaler returns whether ffmpeg can scale images on the graphics device of the hardware acceleration
+func (worker *FfmpegWorker) HasHardwareScaler(acceleration HardwareAcceleration) bool {
+	return worker != nil && worker.hardwareFilters[acceleration.scaler()]
+}
+
+// ScaleImage scales an image to the given dimensions on the graphics device of the hardware acceleration,
+// and encodes it as a JPEG image of about the given quality from 0 to 100, whatever the extension of the output path.
+// The EXIF orientation and the color profile of the image are not applied.
+func (worker *FfmpegWorker) ScaleImage(ctx context.Context, inputPath string, outputPath string, width int, height int, quality int, acceleration HardwareAcceleration) error {
+	args, found := scaleImageArgs(inputPath, width, height, acceleration)
+	if !found {
+		return errors.Errorf("hardware acceleration has no scaler: %s", acceleration)
 	}
 
-	cmd := exec.Command(worker.path, args...)
+	args = append(args, "-c:v", "mjpeg", "-q:v", strconv.Itoa(jpegQScale(quality)), "-f", "image2")
+
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		args := append(args, "-update", "1", tmpPath)
+
+		if err := runCommand(ctx, worker.path, args...); err != nil {
+			return errors.Wrapf(err, "scaling image using: %s %v", worker.path, args)
+		}
 
-	if err := cmd.Run(); err != nil {
-		return errors.Wrapf(err, "encoding video using: %s", worker.path)
+		return nil
+	})
+}
+
+// jpegQScale returns the quantizer scale of the JPEG encoder of ffmpeg, from 2 to 31, closest to the quality from 0 to 100
+func jpegQScale(quality int) int {
+	qscale := 2 + (100-quality)*29/100
+	if qscale < 2 {
+		return 2
+	}
+	if qscale > 31 {
+		return 31
 	}
 
-	return nil
+	return qscale
 }
 
-func (worker *FfmpegWorker) EncodeVideoThumbnail(inputPath string, outputPath string, probeData *ffprobe.ProbeData) error {
+// videoThumbnailFrames is the number of frames the thumbnail of a video is picked from
+const videoThumbnailFrames = 30
+
+// EncodeVideoThumbnail encodes the most representative of the frames a quarter into a video, so thumbnails are not
+// of black frames or frames in the middle of a transition. Frames are scaled before they are compared, to limit the memory used.
+func (worker *FfmpegWorker) EncodeVideoThumbnail(ctx context.Context, inputPath string, outputPath string, probeData *ffprobe.ProbeData) error {
 
 	thumbnailOffsetSeconds := fmt.Sprintf("%d", int(probeData.Format.DurationSeconds*0.25))
 
-	args := []string{
-		"-ss", thumbnailOffsetSeconds, // grab frame at time offset
-		"-i",
-		inputPath,
-		"-vframes", "1", // output one frame
-		"-an", // disable audio
-		"-vf", "scale='min(1024,iw)':'min(1024,ih)':force_original_aspect_ratio=decrease:force_divisible_by=2",
-		outputPath,
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		args := []string{
+			"-ss", thumbnailOffsetSeconds, // grab frame at time offset
+			"-i",
+			inputPath,
+			"-vframes", "1", // output one frame
+			"-an", // disable audio
+			"-vf", fmt.Sprintf("scale='min(1024,iw)':'min(1024,ih)':force_original_aspect_ratio=decrease:force_divisible_by=2,thumbnail=%d", videoThumbnailFrames),
+			tmpPath,
+		}
+
+		if err := runCommand(ctx, worker.path, args...); err != nil {
+			return errors.Wrapf(err, "encoding video using: %s", worker.path)
+		}
+
+		return nil
+	})
+}
+
+// Videos with frames further apart in their scrub sprite than this are only decoded at their keyframes,
+// which is much faster, and close enough to the time of the frames
+const scrubSpriteKeyframeInterval = 10.0
+
+// EncodeScrubSprite encodes frames of a video at even intervals, scaled to the frame width, into a single image
+// with the frames laid out in a grid of the given number of columns
+func (worker *FfmpegWorker) EncodeScrubSprite(ctx context.Context, inputPath string, outputPath string, durationSeconds float64,
+	frames int, columns int, frameWidth int) error {
+
+	if durationSeconds <= 0 {
+		return errors.New("video has no duration")
 	}
 
-	cmd := exec.Command(worker.path, args...)
+	rows := (frames + columns - 1) / columns
+	frameRate := float64(frames) / durationSeconds
+
+	return utils.WriteFileAtomic(outputPath, func(tmpPath string) error {
+		args := []string{}
+		if durationSeconds/float64(frames) >= scrubSpriteKeyframeInterval {
+			args = append(args, "-skip_frame", "nokey")
+		}
+
+		args = append(args,
+			"-i", inputPath,
+			"-an",
+			"-vf", fmt.Sprintf("fps=%f,scale=%d:-2,tile=%dx%d", frameRate, frameWidth, columns, rows),
+			"-frames:v", "1",
+			"-q:v", "5",
+			tmpPath,
+		)
+
+		if err := runCommand(ctx, worker.path, args...); err != nil {
+			return errors.Wrapf(err, "encoding scrub sprite of video using: %s", worker.path)
+		}
 
-	if err := cmd.Run(); err != nil {
-		return errors.Wrapf(err, "encoding video using: %s", worker.path)
+		return nil
+	})
+}
+
+// runCommandWithProgress runs ffmpeg with its progress written to standard output,
+// and reports the time of the output written so far in seconds to onProgress
+func runCommandWithProgress(ctx context.Context, path string, args []string, onProgress func(outTimeSeconds float64)) error {
+	_, span := tracing.Start(ctx, "exec "+filepath.Base(path),
+		attribute.String("exec.path", path),
+		attribute.StringSlice("exec.args", args))
+
+	cmd := exec.Command(path, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		tracing.EndSpan(span, err)
+		return err
 	}
 
-	return nil
+	if err := cmd.Start(); err != nil {
+		tracing.EndSpan(span, err)
+		return err
+	}
+
+	// Progress is written as key=value lines, out_time_us is the time of the output in microseconds
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found || key != "out_time_us" {
+			continue
+		}
+
+		if microseconds, err := strconv.ParseInt(value, 10, 64); err == nil && microseconds >= 0 {
+			onProgress(float64(microseconds) / 1e6)
+		}
+	}
+	// Keep reading after a line too long to scan, so ffmpeg is not blocked writing its progress
+	io.Copy(io.Discard, stdout)
+
+	err = cmd.Wait()
+	tracing.EndSpan(span, err)
+
+	return err
+}
+
+// runCommand runs an external program, recording it as a span of the trace of the context
+func runCommand(ctx context.Context, path string, args ...string) error {
+	_, span := tracing.Start(ctx, "exec "+filepath.Base(path),
+		attribute.String("exec.path", path),
+		attribute.StringSlice("exec.args", args))
+
+	err := exec.Command(path, args...).Run()
+	tracing.EndSpan(span, err)
+
+	return err
 }
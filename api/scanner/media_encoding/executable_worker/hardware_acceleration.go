@@ -0,0 +1,158 @@
+package executable_worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+)
+
+// HardwareAcceleration is the hardware web videos are encoded with, set by PHOTOVIEW_VIDEO_HARDWARE_ACCELERATION
+type HardwareAcceleration string
+
+const (
+	// HardwareAccelerationNone encodes web videos with the processor
+	HardwareAccelerationNone HardwareAcceleration = "none"
+	// HardwareAccelerationVAAPI encodes web videos with the Video Acceleration API of Intel and AMD graphics on Linux
+	HardwareAccelerationVAAPI HardwareAcceleration = "vaapi"
+	// HardwareAccelerationNVENC encodes web videos with NVIDIA graphics
+	HardwareAccelerationNVENC HardwareAcceleration = "nvenc"
+	// HardwareAccelerationQSV encodes web videos with Intel Quick Sync Video
+	HardwareAccelerationQSV HardwareAcceleration = "qsv"
+)
+
+// defaultVAAPIDevice is the render node of the first graphics device on Linux
+const defaultVAAPIDevice = "/dev/dri/renderD128"
+
+// VideoHardwareAcceleration returns the configured hardware acceleration of web videos, or none if it is not set or invalid
+func VideoHardwareAcceleration() HardwareAcceleration {
+	value := utils.EnvVideoHardwareAcceleration.GetValue()
+	switch acceleration := HardwareAcceleration(strings.ToLower(value)); acceleration {
+	case "", HardwareAccelerationNone:
+		return HardwareAccelerationNone
+	case HardwareAccelerationVAAPI, HardwareAccelerationNVENC, HardwareAccelerationQSV:
+		return acceleration
+	}
+
+	log.Warn(context.Background(), "Invalid video hardware acceleration, encoding web videos with the processor",
+		"env", utils.EnvVideoHardwareAcceleration.GetName(), "value", value)
+	return HardwareAccelerationNone
+}
+
+// vaapiDevice returns the configured device of VAAPI
+func vaapiDevice() string {
+	if device := utils.EnvVideoVAAPIDevice.GetValue(); device != "" {
+		return device
+	}
+
+	return defaultVAAPIDevice
+}
+
+// encoder returns the ffmpeg encoder of the codec with the hardware acceleration, or an empty string if it has none
+func (acceleration HardwareAcceleration) encoder(codec VideoCodec) string {
+	switch acceleration {
+	case HardwareAccelerationNone:
+		if codec == VideoCodecVP9 {
+			return "libvpx-vp9"
+		}
+		return "h264"
+	case HardwareAccelerationVAAPI:
+		return string(codec) + "_vaapi"
+	case HardwareAccelerationNVENC:
+		// NVENC can decode VP9, but not encode it
+		if codec == VideoCodecH264 {
+			return "h264_nvenc"
+		}
+	case HardwareAccelerationQSV:
+		return string(codec) + "_qsv"
+	}
+
+	return ""
+}
+
+// hardwareEncoders lists the hardware encoders ffmpeg was built with, from the output of ffmpeg -encoders
+func hardwareEncoders(encodersOutput string) map[string]bool {
+	encoders := make(map[string]bool)
+	for _, line := range strings.Split(encodersOutput, "\n") {
+		// Encoders are listed as their capabilities, followed by their name and description
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		name := fields[1]
+		for _, suffix := range []string{"_vaapi", "_nvenc", "_qsv"} {
+			if strings.HasSuffix(name, suffix) {
+				encoders[name] = true
+			}
+		}
+	}
+
+	return encoders
+}
+
+// scaler returns the ffmpeg filter scaling frames on the graphics device of the hardware acceleration,
+// or an empty string if it has none
+func (acceleration HardwareAcceleration) scaler() string {
+	switch acceleration {
+	case HardwareAccelerationVAAPI:
+		return "scale_vaapi"
+	case HardwareAccelerationNVENC:
+		return "scale_cuda"
+	case HardwareAccelerationQSV:
+		return "scale_qsv"
+	}
+
+	return ""
+}
+
+// hardwareFilters lists the filters of ffmpeg scaling frames on graphics devices, from the output of ffmpeg -filters
+func hardwareFilters(filtersOutput string) map[string]bool {
+	filters := make(map[string]bool)
+	for _, line := range strings.Split(filtersOutput, "\n") {
+		// Filters are listed as their capabilities, followed by their name, their inputs and outputs, and description
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		name := fields[1]
+		for _, scaler := range []string{"scale_vaapi", "scale_cuda", "scale_qsv"} {
+			if name == scaler {
+				filters[name] = true
+			}
+		}
+	}
+
+	return filters
+}
+
+// scaleImageArgs are the arguments of ffmpeg scaling an image to the given dimensions on the graphics device of the hardware acceleration,
+// up to the arguments of its output. Frames are uploaded to the device, scaled there and downloaded again to be encoded.
+// It returns false if the hardware acceleration has no scaler.
+func scaleImageArgs(inputPath string, width int, height int, acceleration HardwareAcceleration) ([]string, bool) {
+	scaler := acceleration.scaler()
+	if scaler == "" {
+		return nil, false
+	}
+
+	var args []string
+	upload := "hwupload"
+
+	switch acceleration {
+	case HardwareAccelerationVAAPI:
+		args = []string{"-vaapi_device", vaapiDevice()}
+	case HardwareAccelerationNVENC:
+		args = []string{"-init_hw_device", "cuda=gpu", "-filter_hw_device", "gpu"}
+	case HardwareAccelerationQSV:
+		args = []string{"-init_hw_device", "qsv=gpu", "-filter_hw_device", "gpu"}
+		upload = "hwupload=extra_hw_frames=64"
+	}
+
+	filter := fmt.Sprintf("format=nv12,%s,%s=w=%d:h=%d,hwdownload,format=nv12", upload, scaler, width, height)
+	args = append(args, "-i", inputPath, "-vf", filter, "-frames:v", "1", "-map_metadata", "-1")
+
+	return args, true
+}
@@ -0,0 +1,118 @@
+package executable_worker
+
+import (
+	"context"
+	"strings"
+
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+)
+
+// VideoCodec is the codec videos browsers can't play are transcoded to, set by PHOTOVIEW_VIDEO_CODEC
+type VideoCodec string
+
+const (
+	// VideoCodecH264 encodes web videos as H.264 in MP4 files, which all browsers can play
+	VideoCodecH264 VideoCodec = "h264"
+	// VideoCodecVP9 encodes web videos as VP9 in WebM files, which are smaller but much slower to encode
+	VideoCodecVP9 VideoCodec = "vp9"
+)
+
+// WebVideoCodec returns the configured codec of web videos, or H.264 if it is not set or invalid
+func WebVideoCodec() VideoCodec {
+	value := utils.EnvVideoCodec.GetValue()
+	switch VideoCodec(strings.ToLower(value)) {
+	case "", VideoCodecH264:
+		return VideoCodecH264
+	case VideoCodecVP9:
+		return VideoCodecVP9
+	}
+
+	log.Warn(context.Background(), "Invalid video codec, encoding web videos as h264",
+		"env", utils.EnvVideoCodec.GetName(), "value", value)
+	return VideoCodecH264
+}
+
+// VideoCodecOfContentType returns the codec of web videos of the given content type,
+// so web videos missing from the cache are encoded again in the format they were saved as
+func VideoCodecOfContentType(contentType string) VideoCodec {
+	if contentType == "video/webm" {
+		return VideoCodecVP9
+	}
+
+	return VideoCodecH264
+}
+
+// Extension is the file extension of web videos of the codec
+func (codec VideoCodec) Extension() string {
+	if codec == VideoCodecVP9 {
+		return ".webm"
+	}
+
+	return ".mp4"
+}
+
+// ContentType is the content type of web videos of the codec
+func (codec VideoCodec) ContentType() string {
+	if codec == VideoCodecVP9 {
+		return "video/webm"
+	}
+
+	return "video/mp4"
+}
+
+// webVideoScale scales web videos down to at most 1080 pixels on their longest side
+const webVideoScale = "scale='min(1080,iw)':'min(1080,ih)':force_original_aspect_ratio=decrease:force_divisible_by=2"
+
+// webVideoArgs are the arguments of ffmpeg encoding a video to a web video of the codec with the given hardware acceleration,
+// up to the arguments of its output. 8-bit colors are used, as most browsers can't play 10-bit videos.
+// It returns false if the hardware acceleration has no encoder of the codec.
+func webVideoArgs(inputPath string, codec VideoCodec, acceleration HardwareAcceleration) ([]string, bool) {
+	encoder := acceleration.encoder(codec)
+	if encoder == "" {
+		return nil, false
+	}
+
+	bitrate := utils.EnvVideoBitrate.GetValue()
+
+	var args []string
+	filter := webVideoScale
+	pixelFormat := "yuv420p"
+
+	switch acceleration {
+	case HardwareAccelerationVAAPI:
+		// Frames are scaled in memory and uploaded to the device to be encoded
+		args = []string{"-vaapi_device", vaapiDevice()}
+		filter += ",format=nv12,hwupload"
+		pixelFormat = ""
+	case HardwareAccelerationQSV:
+		pixelFormat = "nv12"
+	}
+
+	args = append(args, "-i", inputPath, "-vcodec", encoder)
+
+	switch codec {
+	case VideoCodecVP9:
+		args = append(args, "-acodec", "libopus")
+		if acceleration == HardwareAccelerationNone {
+			args = append(args, "-row-mt", "1")
+			if bitrate == "" {
+				// Constant quality of VP9 requires a bitrate of 0
+				args = append(args, "-crf", "32", "-b:v", "0")
+			}
+		}
+	default:
+		args = append(args, "-acodec", "aac", "-movflags", "+faststart+use_metadata_tags")
+	}
+
+	if bitrate != "" {
+		args = append(args, "-b:v", bitrate)
+	}
+
+	args = append(args, "-vf", filter)
+	if pixelFormat != "" {
+		args = append(args, "-pix_fmt", pixelFormat)
+	}
+
+	return args, true
+}
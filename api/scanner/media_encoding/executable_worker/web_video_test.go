@@ -0,0 +1,141 @@
+package executable_worker
+
+import (
+	"testing"
+
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebVideoCodec(t *testing.T) {
+	setEnv := func(variable utils.EnvironmentVariable, value string) {
+		variable.SetOverride(&value)
+		t.Cleanup(func() { variable.SetOverride(nil) })
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		setEnv(utils.EnvVideoCodec, "")
+		setEnv(utils.EnvVideoBitrate, "")
+
+		codec := WebVideoCodec()
+		assert.Equal(t, VideoCodecH264, codec)
+		assert.Equal(t, ".mp4", codec.Extension())
+		assert.Equal(t, "video/mp4", codec.ContentType())
+		args, found := webVideoArgs("in.mkv", codec, HardwareAccelerationNone)
+		assert.True(t, found)
+		assert.NotContains(t, args, "-b:v")
+		assert.Equal(t, "yuv420p", args[indexOf(args, "-pix_fmt")+1], "10-bit videos are encoded with 8-bit colors")
+	})
+
+	t.Run("VP9 with bitrate", func(t *testing.T) {
+		setEnv(utils.EnvVideoCodec, "VP9")
+		setEnv(utils.EnvVideoBitrate, "2500k")
+
+		codec := WebVideoCodec()
+		assert.Equal(t, VideoCodecVP9, codec)
+		assert.Equal(t, "video/webm", codec.ContentType())
+		assert.Equal(t, VideoCodecVP9, VideoCodecOfContentType(codec.ContentType()))
+
+		args, _ := webVideoArgs("in.mkv", codec, HardwareAccelerationNone)
+		assert.Contains(t, args, "libvpx-vp9")
+		assert.NotContains(t, args, "-crf")
+		assert.Equal(t, "2500k", args[indexOf(args, "-b:v")+1])
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		setEnv(utils.EnvVideoCodec, "divx")
+		assert.Equal(t, VideoCodecH264, WebVideoCodec())
+	})
+}
+
+func TestHardwareAcceleration(t *testing.T) {
+	t.Run("VAAPI", func(t *testing.T) {
+		acceleration := HardwareAccelerationVAAPI
+		args, found := webVideoArgs("in.mkv", VideoCodecH264, acceleration)
+		if !assert.True(t, found) {
+			return
+		}
+
+		assert.Equal(t, []string{"-vaapi_device", defaultVAAPIDevice, "-i", "in.mkv", "-vcodec", "h264_vaapi"}, args[:6],
+			"device is set before the input")
+		assert.Equal(t, webVideoScale+",format=nv12,hwupload", args[indexOf(args, "-vf")+1])
+		assert.NotContains(t, args, "-pix_fmt", "frames on the device have no pixel format")
+	})
+
+	t.Run("NVENC has no VP9 encoder", func(t *testing.T) {
+		_, found := webVideoArgs("in.mkv", VideoCodecVP9, HardwareAccelerationNVENC)
+		assert.False(t, found)
+
+		args, found := webVideoArgs("in.mkv", VideoCodecH264, HardwareAccelerationNVENC)
+		assert.True(t, found)
+		assert.Contains(t, args, "h264_nvenc")
+	})
+
+	t.Run("Configuration", func(t *testing.T) {
+		value := "QSV"
+		utils.EnvVideoHardwareAcceleration.SetOverride(&value)
+		defer utils.EnvVideoHardwareAcceleration.SetOverride(nil)
+
+		assert.Equal(t, HardwareAccelerationQSV, VideoHardwareAcceleration())
+	})
+
+	t.Run("Available encoders", func(t *testing.T) {
+		output := `Encoders:
+ V..... = Video
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ V....D h264_nvenc           NVIDIA NVENC H.264 encoder (codec h264)
+ V....D h264_vaapi           H.264/AVC (VAAPI) (codec h264)
+ A....D aac                  AAC (Advanced Audio Coding)`
+
+		assert.Equal(t, map[string]bool{"h264_nvenc": true, "h264_vaapi": true}, hardwareEncoders(output))
+	})
+
+	t.Run("Image scaling", func(t *testing.T) {
+		_, found := scaleImageArgs("in.jpg", 1024, 768, HardwareAccelerationNone)
+		assert.False(t, found)
+
+		args, found := scaleImageArgs("in.jpg", 1024, 768, HardwareAccelerationVAAPI)
+		if !assert.True(t, found) {
+			return
+		}
+		assert.Equal(t, []string{"-vaapi_device", defaultVAAPIDevice, "-i", "in.jpg"}, args[:4], "device is set before the input")
+		assert.Equal(t, "format=nv12,hwupload,scale_vaapi=w=1024:h=768,hwdownload,format=nv12", args[indexOf(args, "-vf")+1])
+
+		args, _ = scaleImageArgs("in.jpg", 1024, 768, HardwareAccelerationNVENC)
+		assert.Contains(t, args[indexOf(args, "-vf")+1], "scale_cuda=w=1024:h=768")
+		assert.Less(t, indexOf(args, "-filter_hw_device"), indexOf(args, "-i"))
+	})
+
+	t.Run("Available filters", func(t *testing.T) {
+		output := `Filters:
+  T.. = Timeline support
+  ------
+ ..C scale             V->V       Scale the input video size and/or convert the image format.
+ ... scale_cuda        V->V       GPU accelerated video resizer
+ ... scale_vaapi       V->V       Scale to/from VAAPI surfaces.
+ ... deinterlace_vaapi V->V       Deinterlacing of VAAPI surfaces`
+
+		assert.Equal(t, map[string]bool{"scale_cuda": true, "scale_vaapi": true}, hardwareFilters(output))
+
+		worker := &FfmpegWorker{hardwareFilters: hardwareFilters(output)}
+		assert.True(t, worker.HasHardwareScaler(HardwareAccelerationVAAPI))
+		assert.False(t, worker.HasHardwareScaler(HardwareAccelerationQSV))
+		assert.False(t, worker.HasHardwareScaler(HardwareAccelerationNone))
+	})
+
+	t.Run("JPEG quality", func(t *testing.T) {
+		assert.Equal(t, 2, jpegQScale(100))
+		assert.Equal(t, 13, jpegQScale(60))
+		assert.Equal(t, 31, jpegQScale(0))
+	})
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,311 @@
+package media_encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+)
+
+// gainMapNamespace is the XMP namespace of the metadata of gain maps, as used by Ultra HDR and Adobe gain map JPEG files.
+// HDR displays brighten the primary image, which is the SDR rendition of the photo, by the gain map.
+const gainMapNamespace = "http://ns.adobe.com/hdr-gain-map/1.0/"
+
+// maxGainMapSize limits the size of the gain maps that are read
+const maxGainMapSize = 64 * 1024 * 1024
+
+const (
+	jpegMarkerSOS  = 0xDA
+	jpegMarkerEOI  = 0xD9
+	jpegMarkerAPP1 = 0xE1
+	jpegMarkerAPP2 = 0xE2
+
+	xmpSignature  = "http://ns.adobe.com/xap/1.0/\x00"
+	exifSignature = "Exif\x00\x00"
+	mpfSignature  = "MPF\x00"
+
+	// mpfTagImageList is the tag of the Multi-Picture Format holding the list of the images of the file
+	mpfTagImageList = 0xB002
+)
+
+// gainMapJPEG is the gain map of a JPEG photo, which is kept in the web copies generated from the photo
+type gainMapJPEG struct {
+	// jpeg is the JPEG file of the gain map, with its XMP metadata
+	jpeg []byte
+	// orientation is the EXIF orientation of the photo, the gain map is rotated the same way as the primary image
+	orientation int
+}
+
+// jpegSegment is a marker segment of the header of a JPEG file
+type jpegSegment struct {
+	marker byte
+	// offset is the offset of the data of the segment in the file, after its marker and length
+	offset int64
+	data   []byte
+}
+
+// readJPEGSegments reads the marker segments of a JPEG file before its image data
+func readJPEGSegments(r io.ReaderAt) ([]jpegSegment, error) {
+	header := make([]byte, 4)
+	if _, err := r.ReadAt(header[:2], 0); err != nil {
+		return nil, err
+	}
+	if header[0] != 0xFF || header[1] != 0xD8 {
+		return nil, errors.New("not a jpeg file")
+	}
+
+	var segments []jpegSegment
+	offset := int64(2)
+	for {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return nil, errors.Wrap(err, "read jpeg segment")
+		}
+		if header[0] != 0xFF {
+			return nil, errors.New("invalid jpeg segment")
+		}
+
+		marker := header[1]
+		if marker == jpegMarkerSOS || marker == jpegMarkerEOI {
+			return segments, nil
+		}
+
+		length := int64(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 {
+			return nil, errors.New("invalid jpeg segment length")
+		}
+
+		data := make([]byte, length-2)
+		if _, err := r.ReadAt(data, offset+4); err != nil {
+			return nil, errors.Wrap(err, "read jpeg segment")
+		}
+
+		segments = append(segments, jpegSegment{marker: marker, offset: offset + 4, data: data})
+		offset += 2 + length
+	}
+}
+
+// readGainMap reads the gain map of a JPEG photo, it returns nil if the photo has none.
+// Gain maps are stored as secondary images of the Multi-Picture Format, described by XMP metadata.
+func readGainMap(r io.ReaderAt) (*gainMapJPEG, error) {
+	segments, err := readJPEGSegments(r)
+	if err != nil {
+		return nil, err
+	}
+
+	hasGainMap := false
+	orientation := 0
+	var imageList []byte
+	var imageListOffset int64
+
+	for _, segment := range segments {
+		switch {
+		case segment.marker == jpegMarkerAPP1 && bytes.HasPrefix(segment.data, []byte(xmpSignature)):
+			hasGainMap = hasGainMap || bytes.Contains(segment.data, []byte(gainMapNamespace))
+		case segment.marker == jpegMarkerAPP1 && bytes.HasPrefix(segment.data, []byte(exifSignature)):
+			tiff, err := newTIFFReader(bytes.NewReader(segment.data[len(exifSignature):]))
+			if err != nil || tiff == nil {
+				continue
+			}
+			if ifd, _, err := tiff.readIFD(tiff.firstIFD); err == nil {
+				orientation = tiff.orientation(ifd)
+			}
+		case segment.marker == jpegMarkerAPP2 && bytes.HasPrefix(segment.data, []byte(mpfSignature)):
+			// The offsets of the images are relative to the header of the Multi-Picture Format data
+			imageListOffset = segment.offset + int64(len(mpfSignature))
+			imageList, err = mpfImageList(segment.data[len(mpfSignature):])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !hasGainMap {
+		return nil, nil
+	}
+
+	// The first image is the primary image, the gain map is the secondary image with the gain map metadata
+	for i := 16; i+16 <= len(imageList); i += 16 {
+		size := int64(binary.BigEndian.Uint32(imageList[i+4:]))
+		offset := imageListOffset + int64(binary.BigEndian.Uint32(imageList[i+8:]))
+		if size <= 0 || size > maxGainMapSize {
+			continue
+		}
+
+		data := make([]byte, size)
+		if _, err := r.ReadAt(data, offset); err != nil {
+			continue
+		}
+
+		if bytes.HasPrefix(data, []byte{0xFF, 0xD8}) && bytes.Contains(data, []byte(gainMapNamespace)) {
+			return &gainMapJPEG{jpeg: data, orientation: orientation}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// mpfImageList reads the entries of the images of the Multi-Picture Format data, 16 bytes each
+func mpfImageList(data []byte) ([]byte, error) {
+	tiff, err := newTIFFReader(bytes.NewReader(data))
+	if err != nil || tiff == nil {
+		return nil, errors.New("invalid multi-picture format data")
+	}
+
+	ifd, _, err := tiff.readIFD(tiff.firstIFD)
+	if err != nil {
+		return nil, errors.Wrap(err, "read multi-picture format data")
+	}
+
+	entry, found := ifd[mpfTagImageList]
+	if !found || entry.count <= 4 {
+		return nil, nil
+	}
+
+	offset := int64(tiff.order.Uint32(entry.value))
+	if offset+int64(entry.count) > int64(len(data)) {
+		return nil, errors.New("invalid multi-picture format image list")
+	}
+	list := data[offset : offset+int64(entry.count)]
+
+	// Entries are converted to big endian, to be read the same way for both byte orders
+	if tiff.order == binary.LittleEndian {
+		converted := make([]byte, len(list))
+		for i := 0; i+16 <= len(list); i += 16 {
+			for field := 0; field < 12; field += 4 {
+				binary.BigEndian.PutUint32(converted[i+field:], binary.LittleEndian.Uint32(list[i+field:]))
+			}
+		}
+		list = converted
+	}
+
+	return list, nil
+}
+
+// encodeJPEGWithGainMap encodes an image that was decoded from a photo with a gain map,
+// as an Ultra HDR JPEG image with the gain map of the photo, so it shows the same on HDR displays
+func encodeJPEGWithGainMap(w io.Writer, img image.Image, gainMap *gainMapJPEG, jpegQuality int) error {
+	var primary bytes.Buffer
+	if err := jpeg.Encode(&primary, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return err
+	}
+
+	gainMapData, err := gainMap.oriented(jpegQuality)
+	if err != nil {
+		return err
+	}
+
+	// The primary image describes the gain map in its XMP metadata, and lists it as its secondary image
+	xmp := jpegSegmentBytes(jpegMarkerAPP1, []byte(xmpSignature+fmt.Sprintf(gainMapContainerXMP, len(gainMapData))))
+
+	mpfOffset := 2 + len(xmp) + 4 + len(mpfSignature)
+	mpfLength := len(mpfSignature) + len(mpfHeader(0, 0, 0))
+	primarySize := 2 + len(xmp) + 4 + mpfLength + primary.Len() - 2
+	mpf := jpegSegmentBytes(jpegMarkerAPP2, append([]byte(mpfSignature), mpfHeader(primarySize, len(gainMapData), primarySize-mpfOffset)...))
+
+	for _, part := range [][]byte{{0xFF, 0xD8}, xmp, mpf, primary.Bytes()[2:], gainMapData} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// oriented returns the gain map rotated as the primary image was when it was decoded.
+// Gain maps of rotated photos are encoded again, with the XMP metadata of the gain map.
+func (gainMap *gainMapJPEG) oriented(jpegQuality int) ([]byte, error) {
+	if gainMap.orientation <= 1 {
+		return gainMap.jpeg, nil
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(gainMap.jpeg))
+	if err != nil {
+		return nil, errors.Wrap(err, "decode gain map")
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, orientImage(img, gainMap.orientation), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, errors.Wrap(err, "encode gain map")
+	}
+
+	segments, err := readJPEGSegments(bytes.NewReader(gainMap.jpeg))
+	if err != nil {
+		return nil, errors.Wrap(err, "read metadata of gain map")
+	}
+
+	result := []byte{0xFF, 0xD8}
+	for _, segment := range segments {
+		if segment.marker == jpegMarkerAPP1 && bytes.HasPrefix(segment.data, []byte(xmpSignature)) {
+			result = append(result, jpegSegmentBytes(segment.marker, segment.data)...)
+		}
+	}
+
+	return append(result, encoded.Bytes()[2:]...), nil
+}
+
+// gainMapContainerXMP is the XMP metadata of the primary image of an Ultra HDR JPEG image, formatted with the length of the gain map
+const gainMapContainerXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/">` +
+	`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+	`<rdf:Description xmlns:Container="http://ns.google.com/photos/1.0/container/" xmlns:Item="http://ns.google.com/photos/1.0/container/item/" ` +
+	`xmlns:hdrgm="` + gainMapNamespace + `" hdrgm:Version="1.0">` +
+	`<Container:Directory><rdf:Seq>` +
+	`<rdf:li rdf:parseType="Resource"><Container:Item Item:Semantic="Primary" Item:Mime="image/jpeg"/></rdf:li>` +
+	`<rdf:li rdf:parseType="Resource"><Container:Item Item:Semantic="GainMap" Item:Mime="image/jpeg" Item:Length="%d"/></rdf:li>` +
+	`</rdf:Seq></Container:Directory>` +
+	`</rdf:Description></rdf:RDF></x:xmpmeta>`
+
+// mpfHeader builds the big endian Multi-Picture Format data of an image with a gain map,
+// given the size of the primary image, and the size and the offset of the gain map from the start of the data
+func mpfHeader(primarySize int, gainMapSize int, gainMapOffset int) []byte {
+	const entryCount = 3
+	imageListOffset := 8 + 2 + entryCount*12 + 4
+
+	data := make([]byte, imageListOffset+2*16)
+	copy(data, "MM\x00*")
+	binary.BigEndian.PutUint32(data[4:], 8)
+	binary.BigEndian.PutUint16(data[8:], entryCount)
+
+	imageListValue := make([]byte, 4)
+	binary.BigEndian.PutUint32(imageListValue, uint32(imageListOffset))
+
+	entries := []struct {
+		tag, fieldType uint16
+		count          uint32
+		value          []byte
+	}{
+		// Version, of the undefined type
+		{0xB000, 7, 4, []byte("0100")},
+		// Number of images, of the long type
+		{0xB001, 4, 1, []byte{0, 0, 0, 2}},
+		{mpfTagImageList, 7, 2 * 16, imageListValue},
+	}
+	for i, entry := range entries {
+		field := data[10+i*12:]
+		binary.BigEndian.PutUint16(field, entry.tag)
+		binary.BigEndian.PutUint16(field[2:], entry.fieldType)
+		binary.BigEndian.PutUint32(field[4:], entry.count)
+		copy(field[8:12], entry.value)
+	}
+
+	// The primary image is at the start of the file, its offset is 0
+	const primaryImageAttribute = 0x030000
+	list := data[imageListOffset:]
+	binary.BigEndian.PutUint32(list, primaryImageAttribute)
+	binary.BigEndian.PutUint32(list[4:], uint32(primarySize))
+	binary.BigEndian.PutUint32(list[20:], uint32(gainMapSize))
+	binary.BigEndian.PutUint32(list[24:], uint32(gainMapOffset))
+
+	return data
+}
+
+// jpegSegmentBytes encodes a marker segment of a JPEG file
+func jpegSegmentBytes(marker byte, data []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(data)+2))
+	return append(segment, data...)
+}
@@ -0,0 +1,89 @@
+package media_encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testGainMap builds the JPEG file of a gain map with its XMP metadata
+func testGainMap(t *testing.T, width, height int) []byte {
+	metadata := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description xmlns:hdrgm="` + gainMapNamespace + `" hdrgm:Version="1.0" hdrgm:GainMapMax="2.0"/></rdf:RDF></x:xmpmeta>`
+
+	data := testJPEG(t, width, height)
+	return append(append([]byte{0xFF, 0xD8}, jpegSegmentBytes(jpegMarkerAPP1, []byte(xmpSignature+metadata))...), data[2:]...)
+}
+
+// exifOrientation builds the APP1 segment of EXIF data with the given orientation
+func exifOrientation(orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	copy(tiff, "II*\x00")
+	binary.LittleEndian.PutUint32(tiff[4:], 8)
+	binary.LittleEndian.PutUint16(tiff[8:], 1)
+	binary.LittleEndian.PutUint16(tiff[10:], tiffTagOrientation)
+	binary.LittleEndian.PutUint16(tiff[12:], 3)
+	binary.LittleEndian.PutUint32(tiff[14:], 1)
+	binary.LittleEndian.PutUint16(tiff[18:], orientation)
+	return jpegSegmentBytes(jpegMarkerAPP1, append([]byte(exifSignature), tiff...))
+}
+
+func TestGainMap(t *testing.T) {
+	gainMap := testGainMap(t, 40, 20)
+
+	var photo bytes.Buffer
+	if err := encodeJPEGWithGainMap(&photo, image.NewGray(image.Rect(0, 0, 80, 40)), &gainMapJPEG{jpeg: gainMap}, 70); !assert.NoError(t, err) {
+		return
+	}
+
+	// The primary image is a regular JPEG image
+	config, err := jpeg.DecodeConfig(bytes.NewReader(photo.Bytes()))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 80, config.Width)
+	}
+
+	read, err := readGainMap(bytes.NewReader(photo.Bytes()))
+	if assert.NoError(t, err) && assert.NotNil(t, read) {
+		assert.Equal(t, gainMap, read.jpeg)
+		assert.Equal(t, 0, read.orientation)
+	}
+
+	// The gain map is the last part of the file
+	assert.True(t, bytes.HasSuffix(photo.Bytes(), gainMap))
+
+	noGainMap, err := readGainMap(bytes.NewReader(testJPEG(t, 80, 40)))
+	assert.NoError(t, err)
+	assert.Nil(t, noGainMap)
+}
+
+func TestGainMapOrientation(t *testing.T) {
+	var photo bytes.Buffer
+	if err := encodeJPEGWithGainMap(&photo, image.NewGray(image.Rect(0, 0, 80, 40)), &gainMapJPEG{jpeg: testGainMap(t, 40, 20)}, 70); !assert.NoError(t, err) {
+		return
+	}
+
+	// Photos rotated by their EXIF data have their gain map rotated as well
+	rotated := append(append([]byte{0xFF, 0xD8}, exifOrientation(6)...), photo.Bytes()[2:]...)
+
+	read, err := readGainMap(bytes.NewReader(rotated))
+	if !assert.NoError(t, err) || !assert.NotNil(t, read) {
+		return
+	}
+	assert.Equal(t, 6, read.orientation)
+
+	oriented, err := read.oriented(70)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config, err := jpeg.DecodeConfig(bytes.NewReader(oriented))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 20, config.Width)
+		assert.Equal(t, 40, config.Height)
+	}
+	assert.Contains(t, string(oriented), `hdrgm:GainMapMax="2.0"`)
+}
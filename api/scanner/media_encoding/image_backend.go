@@ -0,0 +1,131 @@
+package media_encoding
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/photoview/photoview/api/features"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/photoview/photoview/api/scanner/media_encoding/media_utils"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/utils"
+)
+
+// ImageBackend is what scales photos down to their thumbnails and scaled copies
+type ImageBackend string
+
+const (
+	// ImageBackendGo decodes photos completely in memory, and scales them with the configured thumbnail filter
+	ImageBackendGo ImageBackend = "go"
+	// ImageBackendVips scales photos with vipsthumbnail, which shrinks JPEG images while decoding them,
+	// so it is much faster and uses much less memory on large photos
+	ImageBackendVips ImageBackend = "vips"
+)
+
+// ConfiguredImageBackend returns the configured backend for scaling photos,
+// or the built-in one if it is not set or invalid, or if it is libvips and vipsthumbnail is not installed
+func ConfiguredImageBackend() ImageBackend {
+	value := utils.EnvImageBackend.GetValue()
+	switch ImageBackend(strings.ToLower(value)) {
+	case "", ImageBackendGo:
+		return ImageBackendGo
+	case ImageBackendVips:
+		if executable_worker.VipsCli.IsInstalled() {
+			return ImageBackendVips
+		}
+
+		log.Warn(context.Background(), "vipsthumbnail is not installed, scaling photos with the built-in image pipeline",
+			"env", utils.EnvImageBackend.GetName(), "value", value)
+		return ImageBackendGo
+	}
+
+	log.Warn(context.Background(), "Invalid image backend, scaling photos with the built-in image pipeline",
+		"env", utils.EnvImageBackend.GetName(), "value", value)
+	return ImageBackendGo
+}
+
+// vipsSaveOptions returns the options vipsthumbnail saves images of the format with
+func vipsSaveOptions(format ImageFormat, quality int) string {
+	if format == ImageFormatWebP {
+		return "[Q=" + strconv.Itoa(quality) + ",strip]"
+	}
+
+	return "[Q=" + strconv.Itoa(quality) + ",strip,interlace]"
+}
+
+// encodeScaledPhotoVips scales a photo down to the given size of its longest side with vipsthumbnail,
+// and returns the dimensions of the scaled copy
+func encodeScaledPhotoVips(ctx context.Context, inputPath string, outputPath string, size int, format ImageFormat, quality int) (*media_utils.PhotoDimensions, error) {
+	if err := executable_worker.VipsCli.Thumbnail(ctx, inputPath, outputPath, size, vipsSaveOptions(format, quality)); err != nil {
+		return nil, err
+	}
+
+	return media_utils.GetPhotoDimensions(outputPath)
+}
+
+// GPUScalingEnabled returns whether photos are scaled down on the graphics card, and the hardware acceleration they are scaled with.
+// It is the graphics card web videos are encoded with, if ffmpeg has a scaler for it.
+func GPUScalingEnabled() (executable_worker.HardwareAcceleration, bool) {
+	if !features.Enabled(models.FeatureGpuThumbnails) {
+		return executable_worker.HardwareAccelerationNone, false
+	}
+
+	acceleration := executable_worker.VideoHardwareAcceleration()
+	if acceleration == executable_worker.HardwareAccelerationNone {
+		log.Warn(context.Background(), "Scaling photos on the graphics card needs video hardware acceleration, scaling them with the image backend",
+			"env", utils.EnvVideoHardwareAcceleration.GetName())
+		return acceleration, false
+	}
+
+	if !executable_worker.FfmpegCli.HasHardwareScaler(acceleration) {
+		log.Warn(context.Background(), "ffmpeg has no scaler for the graphics card, scaling photos with the image backend",
+			"acceleration", acceleration)
+		return acceleration, false
+	}
+
+	return acceleration, true
+}
+
+// gpuCanScale returns whether a photo can be scaled on the graphics card, which is only done for JPEG photos in their normal orientation,
+// as ffmpeg doesn't apply their EXIF orientation
+func gpuCanScale(inputPath string) bool {
+	mediaType, err := media_type.GetMediaType(inputPath)
+	if err != nil || mediaType == nil || *mediaType != media_type.TypeJpeg {
+		return false
+	}
+
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	return media_utils.ReadOrientation(file) == 1
+}
+
+// encodeScaledPhotoGPU scales a photo down to the given size of its longest side on the graphics card of the hardware acceleration,
+// and returns the dimensions of the scaled copy
+func encodeScaledPhotoGPU(ctx context.Context, inputPath string, outputPath string, size int, format ImageFormat, quality int,
+	acceleration executable_worker.HardwareAcceleration) (*media_utils.PhotoDimensions, error) {
+
+	dimensions, err := media_utils.GetPhotoDimensions(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	scaled := dimensions.ScaleToFit(size)
+
+	if err := executable_worker.FfmpegCli.ScaleImage(ctx, inputPath, outputPath, scaled.Width, scaled.Height, quality, acceleration); err != nil {
+		return nil, err
+	}
+
+	if err := convertJPEGFile(ctx, outputPath, format, quality); err != nil {
+		os.Remove(outputPath)
+		return nil, err
+	}
+
+	return &scaled, nil
+}
@@ -0,0 +1,121 @@
+package media_encoding
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfiguredImageBackend(t *testing.T) {
+	vips := executable_worker.VipsCli
+	t.Cleanup(func() { executable_worker.VipsCli = vips })
+
+	t.Setenv(utils.EnvImageBackend.GetName(), "")
+	assert.Equal(t, ImageBackendGo, ConfiguredImageBackend())
+
+	t.Setenv(utils.EnvImageBackend.GetName(), "Vips")
+	executable_worker.VipsCli = &executable_worker.VipsWorker{}
+	assert.Equal(t, ImageBackendVips, ConfiguredImageBackend())
+
+	// Photos are scaled by the built-in image pipeline when vipsthumbnail is missing
+	executable_worker.VipsCli = nil
+	assert.Equal(t, ImageBackendGo, ConfiguredImageBackend())
+
+	t.Setenv(utils.EnvImageBackend.GetName(), "imagemagick")
+	assert.Equal(t, ImageBackendGo, ConfiguredImageBackend())
+}
+
+func TestVipsSaveOptions(t *testing.T) {
+	assert.Equal(t, "[Q=60,strip,interlace]", vipsSaveOptions(ImageFormatJPEG, 60))
+	assert.Equal(t, "[Q=60,strip]", vipsSaveOptions(ImageFormatWebP, 60))
+}
+
+func TestGPUScalingEnabled(t *testing.T) {
+	ffmpeg := executable_worker.FfmpegCli
+	t.Cleanup(func() { executable_worker.FfmpegCli = ffmpeg })
+	executable_worker.FfmpegCli = nil
+
+	t.Setenv(utils.EnvEnableGPUThumbnails.GetName(), "")
+	t.Setenv(utils.EnvVideoHardwareAcceleration.GetName(), "vaapi")
+	_, enabled := GPUScalingEnabled()
+	assert.False(t, enabled, "scaling photos on the graphics card is experimental")
+
+	// Photos are scaled by the image backend when ffmpeg has no scaler for the graphics card
+	t.Setenv(utils.EnvEnableGPUThumbnails.GetName(), "1")
+	_, enabled = GPUScalingEnabled()
+	assert.False(t, enabled)
+
+	t.Setenv(utils.EnvVideoHardwareAcceleration.GetName(), "none")
+	_, enabled = GPUScalingEnabled()
+	assert.False(t, enabled)
+}
+
+// BenchmarkScalePhoto compares scaling a large photo down to a thumbnail with the built-in image pipeline, libvips and the graphics card.
+// The graphics card is set by PHOTOVIEW_VIDEO_HARDWARE_ACCELERATION.
+//
+//	go test -bench ScalePhoto ./scanner/media_encoding
+func BenchmarkScalePhoto(b *testing.B) {
+	executable_worker.InitializeExecutableWorkers()
+
+	inputPath := path.Join(b.TempDir(), "photo.jpg")
+	photo := image.NewRGBA(image.Rect(0, 0, 6000, 4000))
+	for y := 0; y < 4000; y++ {
+		for x := 0; x < 6000; x++ {
+			photo.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x + y), A: 255})
+		}
+	}
+
+	file, err := os.Create(inputPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := jpeg.Encode(file, photo, &jpeg.Options{Quality: 90}); err != nil {
+		b.Fatal(err)
+	}
+	file.Close()
+
+	ctx := context.Background()
+	outputPath := path.Join(b.TempDir(), "thumbnail.jpg")
+
+	b.Run("go", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := encodeScaledPhotoGo(ctx, inputPath, outputPath, 1024, ImageFormatJPEG, 60, imaging.Lanczos); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("vips", func(b *testing.B) {
+		if !executable_worker.VipsCli.IsInstalled() {
+			b.Skip("vipsthumbnail is not installed")
+		}
+
+		for i := 0; i < b.N; i++ {
+			if _, err := encodeScaledPhotoVips(ctx, inputPath, outputPath, 1024, ImageFormatJPEG, 60); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("gpu", func(b *testing.B) {
+		acceleration := executable_worker.VideoHardwareAcceleration()
+		if !executable_worker.FfmpegCli.HasHardwareScaler(acceleration) {
+			b.Skip("ffmpeg has no scaler for the graphics card of the video hardware acceleration")
+		}
+
+		for i := 0; i < b.N; i++ {
+			if _, err := encodeScaledPhotoGPU(ctx, inputPath, outputPath, 1024, ImageFormatJPEG, 60, acceleration); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package media_encoding
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+// ImageFormat is the format the thumbnails and web copies of photos are encoded in
+type ImageFormat string
+
+const (
+	ImageFormatJPEG ImageFormat = "jpeg"
+	ImageFormatWebP ImageFormat = "webp"
+)
+
+// GeneratedImageFormat returns the configured format of the thumbnails and web copies of photos,
+// or JPEG if it is not set or invalid, or if it is WebP and cwebp is not installed
+func GeneratedImageFormat() ImageFormat {
+	value := utils.EnvImageFormat.GetValue()
+	switch ImageFormat(strings.ToLower(value)) {
+	case "", ImageFormatJPEG:
+		return ImageFormatJPEG
+	case ImageFormatWebP:
+		if executable_worker.CwebpCli.IsInstalled() {
+			return ImageFormatWebP
+		}
+
+		log.Warn(context.Background(), "cwebp is not installed, encoding photos as jpeg",
+			"env", utils.EnvImageFormat.GetName(), "value", value)
+		return ImageFormatJPEG
+	}
+
+	log.Warn(context.Background(), "Invalid image format, encoding photos as jpeg",
+		"env", utils.EnvImageFormat.GetName(), "value", value)
+	return ImageFormatJPEG
+}
+
+// ImageFormatOfContentType returns the format of generated photos of the given content type,
+// so photos missing from the cache are encoded again in the format they were saved as
+func ImageFormatOfContentType(contentType string) ImageFormat {
+	if contentType == string(media_type.TypeWebp) {
+		return ImageFormatWebP
+	}
+
+	return ImageFormatJPEG
+}
+
+// Extension is the file extension of generated photos of the format
+func (format ImageFormat) Extension() string {
+	if format == ImageFormatWebP {
+		return ".webp"
+	}
+
+	return ".jpg"
+}
+
+// ContentType is the content type of generated photos of the format
+func (format ImageFormat) ContentType() string {
+	if format == ImageFormatWebP {
+		return string(media_type.TypeWebp)
+	}
+
+	return string(media_type.TypeJpeg)
+}
+
+// encodeImage encodes an image in the given format. WebP images are encoded by cwebp, from a lossless copy of the image.
+func encodeImage(ctx context.Context, image image.Image, outputPath string, format ImageFormat, quality int) error {
+	if format != ImageFormatWebP {
+		if err := encodeImageJPEG(image, outputPath, quality); err != nil {
+			return err
+		}
+
+		return makeProgressiveJPEG(ctx, outputPath)
+	}
+
+	if !executable_worker.CwebpCli.IsInstalled() {
+		return errors.New("could not encode WebP image as cwebp was not found")
+	}
+
+	return withLosslessCopy(image, "photoview-webp", func(losslessPath string) error {
+		return executable_worker.CwebpCli.EncodeWebp(ctx, losslessPath, outputPath, quality)
+	})
+}
+
+// withLosslessCopy writes a temporary PNG copy of an image, for encoders that can not read it from memory
+func withLosslessCopy(image image.Image, tmpPrefix string, encode func(losslessPath string) error) error {
+	tmpDir, err := os.MkdirTemp("", tmpPrefix)
+	if err != nil {
+		return errors.Wrap(err, "create temporary directory for lossless copy of image")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	losslessPath := path.Join(tmpDir, "image.png")
+	losslessFile, err := os.Create(losslessPath)
+	if err != nil {
+		return errors.Wrap(err, "create lossless copy of image")
+	}
+	defer losslessFile.Close()
+
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(losslessFile, image); err != nil {
+		return errors.Wrap(err, "encode lossless copy of image")
+	}
+	if err := losslessFile.Close(); err != nil {
+		return errors.Wrap(err, "write lossless copy of image")
+	}
+
+	return encode(losslessPath)
+}
+
+// makeProgressiveJPEG rewrites a generated JPEG image as a progressive JPEG, so large images render incrementally
+// over slow connections. Images are left as baseline JPEG images if jpegtran is not installed.
+func makeProgressiveJPEG(ctx context.Context, filePath string) error {
+	if !executable_worker.JpegtranCli.IsInstalled() {
+		return nil
+	}
+
+	return executable_worker.JpegtranCli.MakeProgressive(ctx, filePath)
+}
+
+// convertJPEGFile converts a JPEG image written by another program, such as darktable, to the given format in place
+func convertJPEGFile(ctx context.Context, filePath string, format ImageFormat, quality int) error {
+	if format != ImageFormatWebP {
+		return makeProgressiveJPEG(ctx, filePath)
+	}
+
+	if !executable_worker.CwebpCli.IsInstalled() {
+		return errors.New("could not encode WebP image as cwebp was not found")
+	}
+
+	return executable_worker.CwebpCli.EncodeWebp(ctx, filePath, filePath, quality)
+}
+
+// ThumbnailQuality returns the quality, from 1 to 100, of the thumbnails and scaled copies of photos, 60 unless it is configured
+func ThumbnailQuality() int {
+	return utils.EnvThumbnailQuality.GetNumber(60, 1, 100)
+}
+
+// WebVersionQuality returns the quality, from 1 to 100, of the web versions of photos browsers can't display, 70 unless it is configured
+func WebVersionQuality() int {
+	return utils.EnvWebVersionQuality.GetNumber(70, 1, 100)
+}
@@ -0,0 +1,90 @@
+package media_encoding
+
+import (
+	"context"
+	"image"
+	"path"
+	"testing"
+
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratedImageFormat(t *testing.T) {
+	setFormat := func(value string) {
+		utils.EnvImageFormat.SetOverride(&value)
+		t.Cleanup(func() { utils.EnvImageFormat.SetOverride(nil) })
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		setFormat("")
+
+		format := GeneratedImageFormat()
+		assert.Equal(t, ImageFormatJPEG, format)
+		assert.Equal(t, ".jpg", format.Extension())
+		assert.Equal(t, "image/jpeg", format.ContentType())
+	})
+
+	t.Run("WebP", func(t *testing.T) {
+		setFormat("WebP")
+
+		cwebp := executable_worker.CwebpCli
+		t.Cleanup(func() { executable_worker.CwebpCli = cwebp })
+
+		// Photos are encoded as JPEG when cwebp is missing
+		executable_worker.CwebpCli = nil
+		assert.Equal(t, ImageFormatJPEG, GeneratedImageFormat())
+
+		executable_worker.CwebpCli = &executable_worker.CwebpWorker{}
+		format := GeneratedImageFormat()
+		assert.Equal(t, ImageFormatWebP, format)
+		assert.Equal(t, ".webp", format.Extension())
+		assert.Equal(t, ImageFormatWebP, ImageFormatOfContentType(format.ContentType()))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		setFormat("gif")
+		assert.Equal(t, ImageFormatJPEG, GeneratedImageFormat())
+	})
+}
+
+func TestEncodeImageWithoutCwebp(t *testing.T) {
+	cwebp := executable_worker.CwebpCli
+	executable_worker.CwebpCli = nil
+	t.Cleanup(func() { executable_worker.CwebpCli = cwebp })
+
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	outputPath := path.Join(t.TempDir(), "image.webp")
+
+	assert.Error(t, encodeImage(context.Background(), img, outputPath, ImageFormatWebP, 60))
+	assert.NoError(t, encodeImage(context.Background(), img, outputPath, ImageFormatJPEG, 60))
+}
+
+func TestEncodeAVIFEnabled(t *testing.T) {
+	avifenc := executable_worker.AvifencCli
+	t.Cleanup(func() { executable_worker.AvifencCli = avifenc })
+	executable_worker.AvifencCli = &executable_worker.AvifencWorker{}
+
+	t.Setenv(utils.EnvEncodeAVIF.GetName(), "0")
+	assert.False(t, EncodeAVIFEnabled())
+
+	t.Setenv(utils.EnvEncodeAVIF.GetName(), "1")
+	assert.True(t, EncodeAVIFEnabled())
+
+	// Photos are not encoded as AVIF when avifenc is missing
+	executable_worker.AvifencCli = nil
+	assert.False(t, EncodeAVIFEnabled())
+	assert.Error(t, EncodeAVIF(context.Background(), "image.jpg", path.Join(t.TempDir(), "image.avif")))
+}
+
+func TestMakeProgressiveJPEGWithoutJpegtran(t *testing.T) {
+	jpegtran := executable_worker.JpegtranCli
+	executable_worker.JpegtranCli = nil
+	t.Cleanup(func() { executable_worker.JpegtranCli = jpegtran })
+
+	// Images are kept as baseline JPEG images when jpegtran is missing
+	outputPath := path.Join(t.TempDir(), "image.jpg")
+	assert.NoError(t, encodeImage(context.Background(), image.NewGray(image.Rect(0, 0, 8, 8)), outputPath, ImageFormatJPEG, 60))
+	assert.NoError(t, makeProgressiveJPEG(context.Background(), outputPath))
+}
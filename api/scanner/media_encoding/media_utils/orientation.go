@@ -0,0 +1,103 @@
+package media_utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// tagOrientation is the TIFF tag of the EXIF orientation of an image
+const tagOrientation = 0x0112
+
+// ReadOrientation reads the EXIF orientation of a JPEG or TIFF image, from 1 to 8.
+// It returns 1, the normal orientation, if the image has none or is of another format.
+func ReadOrientation(r io.ReaderAt) int {
+	header := make([]byte, 4)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return 1
+	}
+
+	if header[0] == 0xFF && header[1] == 0xD8 {
+		return jpegOrientation(r)
+	}
+
+	return tiffOrientation(r, 0)
+}
+
+// OrientationSwapsDimensions returns whether images of the EXIF orientation are rotated by a quarter turn,
+// so their width and height are swapped when they are displayed
+func OrientationSwapsDimensions(orientation int) bool {
+	return orientation >= 5 && orientation <= 8
+}
+
+// jpegOrientation reads the orientation from the EXIF data in the APP1 segment of a JPEG image
+func jpegOrientation(r io.ReaderAt) int {
+	offset := int64(2)
+	marker := make([]byte, 4)
+	for {
+		if _, err := r.ReadAt(marker, offset); err != nil || marker[0] != 0xFF {
+			return 1
+		}
+
+		// Start of scan, the EXIF data comes before the image data
+		if marker[1] == 0xDA {
+			return 1
+		}
+
+		length := int64(binary.BigEndian.Uint16(marker[2:]))
+		if marker[1] == 0xE1 && length >= 8 {
+			exifHeader := make([]byte, 6)
+			if _, err := r.ReadAt(exifHeader, offset+4); err == nil && bytes.Equal(exifHeader, []byte("Exif\x00\x00")) {
+				return tiffOrientation(io.NewSectionReader(r, offset+10, length-8), 0)
+			}
+		}
+
+		offset += 2 + length
+	}
+}
+
+// tiffOrientation reads the orientation tag of the first directory of the TIFF data at the given offset
+func tiffOrientation(r io.ReaderAt, offset int64) int {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(header[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(header[2:4]) != 42 {
+		return 1
+	}
+
+	ifdOffset := offset + int64(order.Uint32(header[4:8]))
+	count := make([]byte, 2)
+	if _, err := r.ReadAt(count, ifdOffset); err != nil {
+		return 1
+	}
+
+	entry := make([]byte, 12)
+	for i := 0; i < int(order.Uint16(count)); i++ {
+		if _, err := r.ReadAt(entry, ifdOffset+2+int64(i)*12); err != nil {
+			return 1
+		}
+
+		if order.Uint16(entry[0:2]) != tagOrientation {
+			continue
+		}
+
+		orientation := int(order.Uint16(entry[8:10]))
+		if orientation < 1 || orientation > 8 {
+			return 1
+		}
+		return orientation
+	}
+
+	return 1
+}
@@ -3,6 +3,8 @@ package media_utils
 import (
 	"image"
 	"os"
+
+	"github.com/photoview/photoview/api/utils"
 )
 
 type PhotoDimensions struct {
@@ -17,11 +19,19 @@ func GetPhotoDimensions(imagePath string) (*PhotoDimensions, error) {
 	}
 	defer photoFile.Close()
 
-	config, _, err := image.DecodeConfig(photoFile)
+	config, format, err := image.DecodeConfig(photoFile)
 	if err != nil {
 		return nil, err
 	}
 
+	// Photos are displayed, and their thumbnails generated, rotated to their EXIF orientation
+	if (format == "jpeg" || format == "tiff") && OrientationSwapsDimensions(ReadOrientation(photoFile)) {
+		return &PhotoDimensions{
+			Width:  config.Height,
+			Height: config.Width,
+		}, nil
+	}
+
 	return &PhotoDimensions{
 		Width:  config.Width,
 		Height: config.Height,
@@ -35,17 +45,34 @@ func PhotoDimensionsFromRect(rect image.Rectangle) PhotoDimensions {
 	}
 }
 
+// ThumbnailSize returns the size of the longest side of the thumbnails of photos, 1024 pixels unless it is configured
+func ThumbnailSize() int {
+	return utils.EnvThumbnailMaxSize.GetNumber(1024, 128, 4096)
+}
+
+// WebVersionMaxSize returns the largest size of the longest side of the web versions of photos,
+// or 0 if they are kept at the full size of the photos, which is the default
+func WebVersionMaxSize() int {
+	return utils.EnvWebVersionMaxSize.GetNumber(0, 512, 16384)
+}
+
 func (dimensions *PhotoDimensions) ThumbnailScale() PhotoDimensions {
+	return dimensions.ScaleToFit(ThumbnailSize())
+}
+
+// ScaleToFit returns the dimensions scaled down to the given size of their longest side, keeping their aspect ratio.
+// Dimensions that fit already are not scaled up.
+func (dimensions *PhotoDimensions) ScaleToFit(size int) PhotoDimensions {
 	aspect := float64(dimensions.Width) / float64(dimensions.Height)
 
 	var width, height int
 
 	if aspect > 1 {
-		width = 1024
-		height = int(1024 / aspect)
+		width = size
+		height = int(float64(size) / aspect)
 	} else {
-		width = int(1024 * aspect)
-		height = 1024
+		width = int(float64(size) * aspect)
+		height = size
 	}
 
 	if width > dimensions.Width {
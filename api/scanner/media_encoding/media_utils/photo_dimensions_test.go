@@ -0,0 +1,60 @@
+package media_utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeOrientedJPEG writes a JPEG image of the given size, with an EXIF orientation if it is not zero
+func writeOrientedJPEG(t *testing.T, width, height int, orientation uint16) string {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := encoded.Bytes()
+
+	if orientation != 0 {
+		tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+		tiff = append(tiff, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, byte(orientation>>8), byte(orientation), 0x00, 0x00)
+		tiff = append(tiff, 0x00, 0x00, 0x00, 0x00)
+
+		app1 := []byte{0xFF, 0xE1, 0x00, 0x00}
+		binary.BigEndian.PutUint16(app1[2:], uint16(2+6+len(tiff)))
+		app1 = append(append(app1, []byte("Exif\x00\x00")...), tiff...)
+
+		data = append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+	}
+
+	imagePath := path.Join(t.TempDir(), "image.jpg")
+	if err := os.WriteFile(imagePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return imagePath
+}
+
+func TestGetPhotoDimensionsOrientation(t *testing.T) {
+	tests := []struct {
+		orientation uint16
+		expected    PhotoDimensions
+	}{
+		{0, PhotoDimensions{Width: 40, Height: 20}},
+		{1, PhotoDimensions{Width: 40, Height: 20}},
+		{3, PhotoDimensions{Width: 40, Height: 20}},
+		{6, PhotoDimensions{Width: 20, Height: 40}},
+		{8, PhotoDimensions{Width: 20, Height: 40}},
+	}
+
+	for _, test := range tests {
+		dimensions, err := GetPhotoDimensions(writeOrientedJPEG(t, 40, 20, test.orientation))
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, *dimensions, "orientation %d", test.orientation)
+	}
+}
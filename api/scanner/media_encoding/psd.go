@@ -0,0 +1,312 @@
+package media_encoding
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// maxPSDPixels limits the size of the composite images of Photoshop documents that are decoded,
+// larger documents use their embedded thumbnail instead
+const maxPSDPixels = 256 * 1024 * 1024
+
+// Color modes of Photoshop documents
+const (
+	psdModeGrayscale = 1
+	psdModeRGB       = 3
+	psdModeCMYK      = 4
+)
+
+// psdResourceThumbnail is the image resource holding the JPEG thumbnail of a Photoshop document
+const psdResourceThumbnail = 1036
+
+// psdHeader is the header of a Photoshop document, version 1 for PSD and version 2 for PSB files
+type psdHeader struct {
+	Signature [4]byte
+	Version   uint16
+	Reserved  [6]byte
+	Channels  uint16
+	Height    uint32
+	Width     uint32
+	Depth     uint16
+	Mode      uint16
+}
+
+// psdReader reads the sections of a Photoshop document in order
+type psdReader struct {
+	r      io.ReaderAt
+	offset int64
+	// psb is whether the document is a large document, which has wider lengths than PSD files
+	psb bool
+}
+
+func (p *psdReader) read(n int64) ([]byte, error) {
+	data := make([]byte, n)
+	if _, err := p.r.ReadAt(data, p.offset); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	p.offset += n
+	return data, nil
+}
+
+// length reads the length of a section, which is 8 bytes long for some sections of PSB files
+func (p *psdReader) length(wide bool) (int64, error) {
+	if wide && p.psb {
+		data, err := p.read(8)
+		if err != nil {
+			return 0, err
+		}
+		return int64(binary.BigEndian.Uint64(data) & math.MaxInt64), nil
+	}
+
+	data, err := p.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint32(data)), nil
+}
+
+// decodePSD decodes the composite image of a Photoshop document, a PSD or PSB file. Photoshop saves it next to the layers,
+// unless Maximize Compatibility is disabled. For color modes or bit depths that are not supported,
+// the JPEG thumbnail embedded in the document is decoded instead.
+func decodePSD(r io.ReaderAt) (image.Image, error) {
+	p := &psdReader{r: r}
+
+	headerData, err := p.read(26)
+	if err != nil {
+		return nil, errors.Wrap(err, "read photoshop header")
+	}
+
+	var header psdHeader
+	if err := binary.Read(bytes.NewReader(headerData), binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Signature[:]) != "8BPS" || (header.Version != 1 && header.Version != 2) {
+		return nil, errors.New("not a photoshop document")
+	}
+	p.psb = header.Version == 2
+
+	colorModeLength, err := p.length(false)
+	if err != nil {
+		return nil, errors.Wrap(err, "read photoshop color mode data")
+	}
+	p.offset += colorModeLength
+
+	resourcesLength, err := p.length(false)
+	if err != nil {
+		return nil, errors.Wrap(err, "read photoshop image resources")
+	}
+	resources := &psdReader{r: io.NewSectionReader(r, p.offset, resourcesLength)}
+	p.offset += resourcesLength
+
+	layersLength, err := p.length(true)
+	if err != nil {
+		return nil, errors.Wrap(err, "read photoshop layers")
+	}
+	p.offset += layersLength
+
+	composite, err := p.composite(header)
+	if err == nil {
+		return composite, nil
+	}
+
+	thumbnail, thumbnailErr := psdThumbnail(resources)
+	if thumbnailErr != nil {
+		return nil, errors.Wrapf(err, "decode composite of photoshop document, and its thumbnail (%s)", thumbnailErr)
+	}
+	if thumbnail == nil {
+		return nil, errors.Wrap(err, "decode composite of photoshop document without a thumbnail")
+	}
+
+	return thumbnail, nil
+}
+
+// composite decodes the composite image data, the last section of the document.
+// Its channels are stored one after the other, uncompressed or compressed with PackBits.
+func (p *psdReader) composite(header psdHeader) (image.Image, error) {
+	var channels int
+	switch header.Mode {
+	case psdModeGrayscale:
+		channels = 1
+	case psdModeRGB:
+		channels = 3
+	case psdModeCMYK:
+		channels = 4
+	default:
+		return nil, errors.Errorf("unsupported photoshop color mode %d", header.Mode)
+	}
+
+	if header.Depth != 8 && header.Depth != 16 {
+		return nil, errors.Errorf("unsupported photoshop bit depth %d", header.Depth)
+	}
+	if int(header.Channels) < channels {
+		return nil, errors.Errorf("photoshop document has too few channels (%d)", header.Channels)
+	}
+
+	width, height := int(header.Width), int(header.Height)
+	if width == 0 || height == 0 || int64(width)*int64(height) > maxPSDPixels {
+		return nil, errors.Errorf("unsupported photoshop document size %dx%d", width, height)
+	}
+
+	compressionData, err := p.read(2)
+	if err != nil {
+		return nil, errors.Wrap(err, "read compression of composite")
+	}
+	compression := binary.BigEndian.Uint16(compressionData)
+
+	rowLength := width * int(header.Depth) / 8
+	data := bufio.NewReader(io.NewSectionReader(p.r, p.offset, math.MaxInt64-p.offset))
+
+	switch compression {
+	case 0:
+	case 1:
+		// The lengths of the compressed rows of all channels precede the data
+		countSize := int64(2)
+		if p.psb {
+			countSize = 4
+		}
+		if _, err := data.Discard(int(int64(header.Channels) * int64(height) * countSize)); err != nil {
+			return nil, errors.Wrap(err, "read row lengths of composite")
+		}
+	default:
+		return nil, errors.Errorf("unsupported photoshop compression %d", compression)
+	}
+
+	planes := make([][]byte, channels)
+	row := make([]byte, rowLength)
+	for c := range planes {
+		planes[c] = make([]byte, width*height)
+
+		for y := 0; y < height; y++ {
+			if compression == 0 {
+				_, err = io.ReadFull(data, row)
+			} else {
+				err = unpackBits(data, row)
+			}
+			if err != nil {
+				return nil, errors.Wrap(err, "read composite")
+			}
+
+			plane := planes[c][y*width : (y+1)*width]
+			if header.Depth == 8 {
+				copy(plane, row)
+			} else {
+				// Samples of 16 bits are big endian, their most significant byte is kept
+				for x := range plane {
+					plane[x] = row[x*2]
+				}
+			}
+		}
+	}
+
+	bounds := image.Rect(0, 0, width, height)
+	switch header.Mode {
+	case psdModeGrayscale:
+		return &image.Gray{Pix: planes[0], Stride: width, Rect: bounds}, nil
+	case psdModeRGB:
+		img := image.NewRGBA(bounds)
+		for i := 0; i < width*height; i++ {
+			img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = planes[0][i], planes[1][i], planes[2][i], 0xff
+		}
+		return img, nil
+	default:
+		// Photoshop stores the inks of CMYK documents inverted, so a value of 255 is no ink
+		img := image.NewCMYK(bounds)
+		for i := 0; i < width*height; i++ {
+			for c := 0; c < 4; c++ {
+				img.Pix[i*4+c] = 0xff - planes[c][i]
+			}
+		}
+		return img, nil
+	}
+}
+
+// unpackBits decompresses a row compressed with PackBits
+func unpackBits(r *bufio.Reader, row []byte) error {
+	for i := 0; i < len(row); {
+		header, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		n := int(int8(header))
+		switch {
+		case n >= 0:
+			if i+n+1 > len(row) {
+				return errors.New("packbits literal run overflows row")
+			}
+			if _, err := io.ReadFull(r, row[i:i+n+1]); err != nil {
+				return err
+			}
+			i += n + 1
+		case n > -128:
+			value, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if i+1-n > len(row) {
+				return errors.New("packbits repeat run overflows row")
+			}
+			for j := 0; j < 1-n; j++ {
+				row[i+j] = value
+			}
+			i += 1 - n
+		}
+	}
+
+	return nil
+}
+
+// psdThumbnail decodes the JPEG thumbnail of the image resources of a Photoshop document, it returns nil if it has none
+func psdThumbnail(resources *psdReader) (image.Image, error) {
+	for {
+		blockHeader, err := resources.read(6)
+		if err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if string(blockHeader[:4]) != "8BIM" {
+			return nil, errors.New("invalid photoshop image resource")
+		}
+		id := binary.BigEndian.Uint16(blockHeader[4:])
+
+		// The name of the resource is a pascal string padded to an even length
+		nameLength, err := resources.read(1)
+		if err != nil {
+			return nil, err
+		}
+		resources.offset += int64(nameLength[0]) + int64(1-nameLength[0]%2)
+
+		size, err := resources.length(false)
+		if err != nil {
+			return nil, err
+		}
+
+		if id != psdResourceThumbnail {
+			resources.offset += size + size%2
+			continue
+		}
+
+		// The JPEG data follows a header of 28 bytes, with the format and size of the thumbnail
+		if size <= 28 {
+			return nil, errors.New("invalid photoshop thumbnail")
+		}
+		data, err := resources.read(size)
+		if err != nil {
+			return nil, err
+		}
+
+		return jpeg.Decode(bytes.NewReader(data[28:]))
+	}
+}
@@ -0,0 +1,97 @@
+package media_encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPSD builds a Photoshop document with the given image resources and composite image data
+func testPSD(version uint16, mode uint16, channels uint16, width, height uint32, resources []byte, composite []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, psdHeader{
+		Signature: [4]byte{'8', 'B', 'P', 'S'},
+		Version:   version,
+		Channels:  channels,
+		Height:    height,
+		Width:     width,
+		Depth:     8,
+		Mode:      mode,
+	})
+
+	binary.Write(&buf, binary.BigEndian, uint32(0))
+	binary.Write(&buf, binary.BigEndian, uint32(len(resources)))
+	buf.Write(resources)
+	if version == 2 {
+		binary.Write(&buf, binary.BigEndian, uint64(0))
+	} else {
+		binary.Write(&buf, binary.BigEndian, uint32(0))
+	}
+	buf.Write(composite)
+
+	return buf.Bytes()
+}
+
+func TestDecodePSDComposite(t *testing.T) {
+	// Uncompressed RGB image of 2x1 pixels, with the channels one after the other
+	raw := []byte{0, 0, 0xff, 0x10, 0x20, 0x30, 0x40, 0x50}
+	img, err := decodePSD(bytes.NewReader(testPSD(1, psdModeRGB, 3, 2, 1, nil, raw)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 2, 1), img.Bounds())
+		assert.Equal(t, color.RGBA{0xff, 0x20, 0x40, 0xff}, color.RGBAModel.Convert(img.At(0, 0)))
+		assert.Equal(t, color.RGBA{0x10, 0x30, 0x50, 0xff}, color.RGBAModel.Convert(img.At(1, 0)))
+	}
+
+	// Grayscale image of 4x1 pixels of a large document, compressed with a repeat run and a literal run,
+	// the row lengths are 4 bytes long
+	rle := []byte{0, 1, 0, 0, 0, 4, 0xff, 0x80, 1, 0x10, 0x20}
+	img, err = decodePSD(bytes.NewReader(testPSD(2, psdModeGrayscale, 1, 4, 1, nil, rle)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 4, 1), img.Bounds())
+		for x, gray := range []uint8{0x80, 0x80, 0x10, 0x20} {
+			assert.Equal(t, color.Gray{gray}, color.GrayModel.Convert(img.At(x, 0)))
+		}
+	}
+
+	// CMYK image of a single white pixel, as inks are stored inverted
+	img, err = decodePSD(bytes.NewReader(testPSD(1, psdModeCMYK, 4, 1, 1, nil, []byte{0, 0, 0xff, 0xff, 0xff, 0xff})))
+	if assert.NoError(t, err) {
+		assert.Equal(t, color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBAModel.Convert(img.At(0, 0)))
+	}
+}
+
+func TestDecodePSDThumbnail(t *testing.T) {
+	thumbnail := testJPEG(t, 16, 8)
+
+	var resources bytes.Buffer
+	// A resource without a name, before the thumbnail
+	resources.WriteString("8BIM")
+	binary.Write(&resources, binary.BigEndian, uint16(1005))
+	resources.Write([]byte{0, 0})
+	binary.Write(&resources, binary.BigEndian, uint32(3))
+	resources.Write([]byte{1, 2, 3, 0})
+
+	resources.WriteString("8BIM")
+	binary.Write(&resources, binary.BigEndian, uint16(psdResourceThumbnail))
+	resources.Write([]byte{3, 'a', 'b', 'c'})
+	binary.Write(&resources, binary.BigEndian, uint32(28+len(thumbnail)))
+	resources.Write(make([]byte, 28))
+	resources.Write(thumbnail)
+
+	// Lab documents are not supported, their thumbnail is used instead
+	const modeLab = 9
+	img, err := decodePSD(bytes.NewReader(testPSD(1, modeLab, 3, 2, 1, resources.Bytes(), []byte{0, 0, 1, 2, 3, 4, 5, 6})))
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 16, 8), img.Bounds())
+	}
+
+	_, err = decodePSD(bytes.NewReader(testPSD(1, modeLab, 3, 2, 1, nil, []byte{0, 0, 1, 2, 3, 4, 5, 6})))
+	assert.Error(t, err)
+
+	_, err = decodePSD(bytes.NewReader([]byte("not a photoshop document")))
+	assert.Error(t, err)
+}
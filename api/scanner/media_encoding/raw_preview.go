@@ -0,0 +1,543 @@
+package media_encoding
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"io"
+
+	"github.com/disintegration/imaging"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
+	"github.com/pkg/errors"
+)
+
+// minRawPreviewSize is the smallest length of the longest side of a preview of a raw photo that is used,
+// so thumbnails are never upscaled from previews. Raw photos with smaller previews are developed instead.
+const minRawPreviewSize = 1024
+
+// rawPreview is the JPEG preview embedded in a raw photo by the camera
+type rawPreview struct {
+	jpeg []byte
+	// orientation is the EXIF orientation of the photo, as previews usually have no EXIF data of their own.
+	// It is 0 if it is unknown, the orientation of the EXIF data of the preview is used then.
+	orientation int
+}
+
+// previewLocation is where a JPEG preview is stored in a raw file
+type previewLocation struct {
+	offset int64
+	length int64
+}
+
+// readPreviewsFunc finds the JPEG previews of a raw file, and the orientation of the photo
+type readPreviewsFunc func(r io.ReaderAt, size int64) ([]previewLocation, int, error)
+
+// extractRawPreview reads the largest JPEG preview embedded in a raw photo, it returns nil if the format is not supported,
+// or the photo has no preview large enough to be used instead of developing the photo
+func extractRawPreview(ctx context.Context, filePath string, mediaType media_type.MediaType) (*rawPreview, error) {
+	var readPreviews readPreviewsFunc
+	switch mediaType {
+	case media_type.TypeCR3:
+		readPreviews = cr3Previews
+	case media_type.TypeRAF:
+		readPreviews = rafPreviews
+	case media_type.TypeORF:
+		// Olympus files store their previews in their maker notes, which are not read
+		return nil, nil
+	default:
+		if !mediaType.IsRaw() {
+			return nil, nil
+		}
+		readPreviews = tiffPreviews
+	}
+
+	file, err := scanner_io.Open(ctx, filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open raw photo to read its preview (%s)", filePath)
+	}
+	defer file.Close()
+
+	preview, err := readRawPreview(file, readPreviews)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read preview of raw photo (%s)", filePath)
+	}
+
+	return preview, nil
+}
+
+// readRawPreview reads the largest of the previews found by readPreviews
+func readRawPreview(r io.ReaderAt, readPreviews readPreviewsFunc) (*rawPreview, error) {
+	size, err := readerSize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	locations, orientation, err := readPreviews(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var largest *previewLocation
+	largestArea := 0
+	for i, location := range locations {
+		if location.offset < 0 || location.length <= 0 || location.offset+location.length > size {
+			continue
+		}
+
+		// Data such as the lossless JPEG of the raw image itself cannot be decoded, and is skipped
+		config, err := jpeg.DecodeConfig(io.NewSectionReader(r, location.offset, location.length))
+		if err != nil || config.Width < minRawPreviewSize && config.Height < minRawPreviewSize {
+			continue
+		}
+
+		if area := config.Width * config.Height; area > largestArea {
+			largest = &locations[i]
+			largestArea = area
+		}
+	}
+
+	if largest == nil {
+		return nil, nil
+	}
+
+	preview := &rawPreview{
+		jpeg:        make([]byte, largest.length),
+		orientation: orientation,
+	}
+	if _, err := r.ReadAt(preview.jpeg, largest.offset); err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+// decode decodes the preview, rotated to the orientation of the photo
+func (preview *rawPreview) decode() (image.Image, error) {
+	if preview.orientation == 0 {
+		img, err := imaging.Decode(bytes.NewReader(preview.jpeg), imaging.AutoOrientation(true))
+		return img, errors.Wrap(err, "decode preview of raw photo")
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(preview.jpeg))
+	if err != nil {
+		return nil, errors.Wrap(err, "decode preview of raw photo")
+	}
+
+	return orientImage(img, preview.orientation), nil
+}
+
+// orientImage rotates and flips an image as described by its EXIF orientation
+func orientImage(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		img = imaging.FlipH(img)
+	case 3:
+		img = imaging.Rotate180(img)
+	case 4:
+		img = imaging.FlipV(img)
+	case 5:
+		img = imaging.Transpose(img)
+	case 6:
+		img = imaging.Rotate270(img)
+	case 7:
+		img = imaging.Transverse(img)
+	case 8:
+		img = imaging.Rotate90(img)
+	}
+
+	return img
+}
+
+// rafPreviews finds the preview of a Fujifilm RAF file, whose offset and length are stored in its header.
+// The orientation of the photo is in the EXIF data of the preview.
+func rafPreviews(r io.ReaderAt, size int64) ([]previewLocation, int, error) {
+	header := make([]byte, 92)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, 0, err
+	}
+
+	if !bytes.HasPrefix(header, []byte("FUJIFILMCCD-RAW")) {
+		return nil, 0, errors.New("invalid RAF header")
+	}
+
+	return []previewLocation{{
+		offset: int64(binary.BigEndian.Uint32(header[84:])),
+		length: int64(binary.BigEndian.Uint32(header[88:])),
+	}}, 0, nil
+}
+
+var (
+	// cr3MetadataUUID is the box of the moov box of CR3 files, that holds their TIFF tags and thumbnail
+	cr3MetadataUUID = []byte{0x85, 0xc0, 0xb6, 0x87, 0x82, 0x0f, 0x11, 0xe0, 0x81, 0x11, 0xf4, 0xce, 0x46, 0x2b, 0x6a, 0x48}
+	// cr3PreviewUUID is the top level box of CR3 files, that holds their PRVW preview
+	cr3PreviewUUID = []byte{0xea, 0xf4, 0x2b, 0x5e, 0x1c, 0x98, 0x4b, 0x88, 0xb9, 0xfb, 0xb7, 0xdc, 0x40, 0x6e, 0x4d, 0x16}
+)
+
+// isoBox is a box of an ISO base media file, the container format of CR3 files
+type isoBox struct {
+	boxType string
+	// offset and size of the content of the box, after its header
+	offset int64
+	size   int64
+}
+
+// readISOBoxes reads the headers of the boxes from offset to end
+func readISOBoxes(r io.ReaderAt, offset, end int64) ([]isoBox, error) {
+	var boxes []isoBox
+
+	for offset+8 <= end {
+		header := make([]byte, 16)
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			// The box extends to the end of its parent
+			size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:], offset+8); err != nil {
+				return nil, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			headerSize = 16
+		}
+
+		if size < headerSize || offset+size > end {
+			return nil, errors.Errorf("invalid size of box at offset %d", offset)
+		}
+
+		boxes = append(boxes, isoBox{
+			boxType: string(header[4:8]),
+			offset:  offset + headerSize,
+			size:    size - headerSize,
+		})
+		offset += size
+	}
+
+	return boxes, nil
+}
+
+// findUUIDBox finds the uuid box with the given extended type, and returns it without its extended type
+func findUUIDBox(r io.ReaderAt, boxes []isoBox, uuid []byte) (*isoBox, error) {
+	for _, box := range boxes {
+		if box.boxType != "uuid" || box.size < 16 {
+			continue
+		}
+
+		extendedType := make([]byte, 16)
+		if _, err := r.ReadAt(extendedType, box.offset); err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(extendedType, uuid) {
+			return &isoBox{boxType: box.boxType, offset: box.offset + 16, size: box.size - 16}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// cr3Previews finds the PRVW preview of a CR3 file, which is about 1620 pixels wide,
+// and reads the orientation of the photo from the TIFF tags of its CMT1 box
+func cr3Previews(r io.ReaderAt, size int64) ([]previewLocation, int, error) {
+	boxes, err := readISOBoxes(r, 0, size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	previewBox, err := findUUIDBox(r, boxes, cr3PreviewUUID)
+	if err != nil || previewBox == nil {
+		return nil, 0, err
+	}
+
+	// The preview box starts with 8 bytes of unknown use, followed by the PRVW box
+	previewBoxes, err := readISOBoxes(r, previewBox.offset+8, previewBox.offset+previewBox.size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var locations []previewLocation
+	for _, box := range previewBoxes {
+		if box.boxType != "PRVW" || box.size < 16 {
+			continue
+		}
+
+		// The PRVW box holds its width and height, followed by the length of its JPEG at offset 12
+		header := make([]byte, 16)
+		if _, err := r.ReadAt(header, box.offset); err != nil {
+			return nil, 0, err
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[12:]))
+		if length > box.size-16 {
+			return nil, 0, errors.New("invalid length of PRVW preview")
+		}
+
+		locations = append(locations, previewLocation{offset: box.offset + 16, length: length})
+	}
+
+	orientation, err := cr3Orientation(r, boxes)
+	if err != nil {
+		orientation = 0
+	}
+
+	return locations, orientation, nil
+}
+
+// cr3Orientation reads the orientation tag of the CMT1 box of a CR3 file, which holds the first directory of a TIFF file
+func cr3Orientation(r io.ReaderAt, boxes []isoBox) (int, error) {
+	for _, box := range boxes {
+		if box.boxType != "moov" {
+			continue
+		}
+
+		moovBoxes, err := readISOBoxes(r, box.offset, box.offset+box.size)
+		if err != nil {
+			return 0, err
+		}
+
+		metadataBox, err := findUUIDBox(r, moovBoxes, cr3MetadataUUID)
+		if err != nil || metadataBox == nil {
+			return 0, err
+		}
+
+		metadataBoxes, err := readISOBoxes(r, metadataBox.offset, metadataBox.offset+metadataBox.size)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, tagsBox := range metadataBoxes {
+			if tagsBox.boxType != "CMT1" {
+				continue
+			}
+
+			tiff, err := newTIFFReader(io.NewSectionReader(r, tagsBox.offset, tagsBox.size))
+			if err != nil || tiff == nil {
+				return 0, err
+			}
+
+			ifd, _, err := tiff.readIFD(tiff.firstIFD)
+			if err != nil {
+				return 0, err
+			}
+
+			return tiff.orientation(ifd), nil
+		}
+	}
+
+	return 0, nil
+}
+
+// TIFF tags read to find the previews of raw files
+const (
+	tiffTagCompression                 = 0x0103
+	tiffTagPhotometricInterpretation   = 0x0106
+	tiffTagStripOffsets                = 0x0111
+	tiffTagOrientation                 = 0x0112
+	tiffTagStripByteCounts             = 0x0117
+	tiffTagSubIFDs                     = 0x014A
+	tiffTagJPEGInterchangeFormat       = 0x0201
+	tiffTagJPEGInterchangeFormatLength = 0x0202
+)
+
+// maxTIFFDirectories limits the directories read from a TIFF file, so files with directories linked in a loop are read in time
+const maxTIFFDirectories = 32
+
+// tiffPreviews finds the JPEG previews of raw files based on TIFF, such as CR2, NEF, ARW and DNG files.
+// They are either stored as the JPEG thumbnail of a directory, or as the single strip of a directory compressed with JPEG.
+func tiffPreviews(r io.ReaderAt, size int64) ([]previewLocation, int, error) {
+	tiff, err := newTIFFReader(r)
+	if err != nil || tiff == nil {
+		return nil, 0, err
+	}
+
+	var locations []previewLocation
+	orientation := 0
+
+	pending := []int64{tiff.firstIFD}
+	visited := make(map[int64]bool)
+	for len(pending) > 0 && len(visited) < maxTIFFDirectories {
+		offset := pending[0]
+		pending = pending[1:]
+		if offset <= 0 || offset >= size || visited[offset] {
+			continue
+		}
+		visited[offset] = true
+
+		ifd, next, err := tiff.readIFD(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if offset == tiff.firstIFD {
+			orientation = tiff.orientation(ifd)
+		}
+
+		if jpegOffset, found := tiff.value(ifd, tiffTagJPEGInterchangeFormat); found {
+			if jpegLength, found := tiff.value(ifd, tiffTagJPEGInterchangeFormatLength); found {
+				locations = append(locations, previewLocation{offset: int64(jpegOffset), length: int64(jpegLength)})
+			}
+		}
+
+		// Directories of the raw image itself are skipped, their lossless JPEG data is large and cannot be decoded
+		compression, _ := tiff.value(ifd, tiffTagCompression)
+		photometric, _ := tiff.value(ifd, tiffTagPhotometricInterpretation)
+		if (compression == 6 || compression == 7) && photometric != 32803 && photometric != 34892 {
+			offsets, _ := tiff.values(ifd[tiffTagStripOffsets])
+			lengths, _ := tiff.values(ifd[tiffTagStripByteCounts])
+			if len(offsets) == 1 && len(lengths) == 1 {
+				locations = append(locations, previewLocation{offset: int64(offsets[0]), length: int64(lengths[0])})
+			}
+		}
+
+		if subIFDs, err := tiff.values(ifd[tiffTagSubIFDs]); err == nil {
+			for _, subIFD := range subIFDs {
+				pending = append(pending, int64(subIFD))
+			}
+		}
+		pending = append(pending, next)
+	}
+
+	return locations, orientation, nil
+}
+
+// tiffReader reads the directories of a TIFF file
+type tiffReader struct {
+	r        io.ReaderAt
+	order    binary.ByteOrder
+	firstIFD int64
+}
+
+// tiffEntry is an entry of a TIFF directory
+type tiffEntry struct {
+	fieldType uint16
+	count     uint32
+	// value holds values of up to 4 bytes, or the offset of larger values
+	value []byte
+}
+
+// newTIFFReader reads the header of a TIFF file, it returns nil if the file is not a TIFF file
+func newTIFFReader(r io.ReaderAt) (*tiffReader, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+
+	tiff := &tiffReader{r: r}
+	switch string(header[:4]) {
+	case "II*\x00":
+		tiff.order = binary.LittleEndian
+	case "MM\x00*":
+		tiff.order = binary.BigEndian
+	default:
+		return nil, nil
+	}
+
+	tiff.firstIFD = int64(tiff.order.Uint32(header[4:]))
+	return tiff, nil
+}
+
+// readIFD reads the entries of the directory at offset, and returns the offset of the next directory
+func (tiff *tiffReader) readIFD(offset int64) (map[uint16]tiffEntry, int64, error) {
+	countBytes := make([]byte, 2)
+	if _, err := tiff.r.ReadAt(countBytes, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "read TIFF directory")
+	}
+
+	count := int64(tiff.order.Uint16(countBytes))
+	data := make([]byte, count*12+4)
+	if _, err := tiff.r.ReadAt(data, offset+2); err != nil {
+		return nil, 0, errors.Wrap(err, "read TIFF directory")
+	}
+
+	ifd := make(map[uint16]tiffEntry, count)
+	for i := int64(0); i < count; i++ {
+		entry := data[i*12 : i*12+12]
+		ifd[tiff.order.Uint16(entry)] = tiffEntry{
+			fieldType: tiff.order.Uint16(entry[2:]),
+			count:     tiff.order.Uint32(entry[4:]),
+			value:     entry[8:12],
+		}
+	}
+
+	return ifd, int64(tiff.order.Uint32(data[count*12:])), nil
+}
+
+// values reads the values of an entry of the integer types SHORT, LONG or IFD
+func (tiff *tiffReader) values(entry tiffEntry) ([]uint32, error) {
+	var width uint32
+	switch entry.fieldType {
+	case 3:
+		width = 2
+	case 4, 13:
+		width = 4
+	default:
+		return nil, errors.Errorf("unsupported TIFF field type %d", entry.fieldType)
+	}
+
+	if entry.count > maxTIFFDirectories*16 {
+		return nil, errors.Errorf("too many values of TIFF entry (%d)", entry.count)
+	}
+
+	data := entry.value
+	if entry.count*width > 4 {
+		data = make([]byte, entry.count*width)
+		if _, err := tiff.r.ReadAt(data, int64(tiff.order.Uint32(entry.value))); err != nil {
+			return nil, errors.Wrap(err, "read values of TIFF entry")
+		}
+	}
+
+	values := make([]uint32, entry.count)
+	for i := range values {
+		if width == 2 {
+			values[i] = uint32(tiff.order.Uint16(data[i*2:]))
+		} else {
+			values[i] = tiff.order.Uint32(data[i*4:])
+		}
+	}
+
+	return values, nil
+}
+
+// value reads the single integer value of a tag of a directory
+func (tiff *tiffReader) value(ifd map[uint16]tiffEntry, tag uint16) (uint32, bool) {
+	entry, found := ifd[tag]
+	if !found {
+		return 0, false
+	}
+
+	values, err := tiff.values(entry)
+	if err != nil || len(values) != 1 {
+		return 0, false
+	}
+
+	return values[0], true
+}
+
+// orientation reads the orientation tag of a directory, or 0 if it has none
+func (tiff *tiffReader) orientation(ifd map[uint16]tiffEntry) int {
+	orientation, found := tiff.value(ifd, tiffTagOrientation)
+	if !found || orientation < 1 || orientation > 8 {
+		return 0
+	}
+
+	return int(orientation)
+}
+
+// readerSize returns the size of the file or section read by r
+func readerSize(r io.ReaderAt) (int64, error) {
+	switch sized := r.(type) {
+	case interface{ Size() int64 }:
+		return sized.Size(), nil
+	case io.Seeker:
+		return sized.Seek(0, io.SeekEnd)
+	}
+
+	return 0, errors.New("size of reader is unknown")
+}
@@ -0,0 +1,216 @@
+package media_encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func isoTestBox(boxType string, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	box := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(box, uint32(8+len(body)))
+	copy(box[4:], boxType)
+	return append(box, body...)
+}
+
+// cr3TestFile builds a CR3 file holding only the boxes of its orientation and preview
+func cr3TestFile(t *testing.T, orientation uint16) []byte {
+	previewJPEG := testJPEG(t, 1200, 600)
+
+	tiff := make([]byte, 26)
+	copy(tiff, "II*\x00")
+	binary.LittleEndian.PutUint32(tiff[4:], 8)
+	binary.LittleEndian.PutUint16(tiff[8:], 1)
+	binary.LittleEndian.PutUint16(tiff[10:], tiffTagOrientation)
+	binary.LittleEndian.PutUint16(tiff[12:], 3)
+	binary.LittleEndian.PutUint32(tiff[14:], 1)
+	binary.LittleEndian.PutUint16(tiff[18:], orientation)
+
+	prvwHeader := make([]byte, 16)
+	binary.BigEndian.PutUint16(prvwHeader[6:], 1200)
+	binary.BigEndian.PutUint16(prvwHeader[8:], 600)
+	binary.BigEndian.PutUint32(prvwHeader[12:], uint32(len(previewJPEG)))
+
+	return bytes.Join([][]byte{
+		isoTestBox("ftyp", []byte("crx \x00\x00\x00\x01crx isom")),
+		isoTestBox("moov", isoTestBox("uuid", cr3MetadataUUID, isoTestBox("CMT1", tiff))),
+		isoTestBox("uuid", cr3PreviewUUID, make([]byte, 8), isoTestBox("PRVW", prvwHeader, previewJPEG)),
+		isoTestBox("mdat", make([]byte, 32)),
+	}, nil)
+}
+
+// tiffTestEntry is an entry of a directory of a test TIFF file, of type LONG unless it is the orientation
+type tiffTestEntry struct {
+	tag   uint16
+	value uint32
+}
+
+// tiffTestFile builds a little endian TIFF file of the given directories, each directory is linked to the next as a sub directory
+// of the first, and data is appended after the directories at the offset given to makeDirectories
+func tiffTestFile(makeDirectories func(dataOffset uint32) [][]tiffTestEntry, data []byte) []byte {
+	const maxDirectorySize = 2 + 8*12 + 4
+	directoryCount := len(makeDirectories(0))
+	dataOffset := uint32(8 + directoryCount*maxDirectorySize)
+	directories := makeDirectories(dataOffset)
+
+	file := make([]byte, dataOffset)
+	copy(file, "II*\x00")
+	binary.LittleEndian.PutUint32(file[4:], 8)
+
+	for i, directory := range directories {
+		offset := 8 + i*maxDirectorySize
+		if i == 0 && len(directories) > 1 {
+			directory = append(directory, tiffTestEntry{tiffTagSubIFDs, uint32(8 + maxDirectorySize)})
+		}
+		if i > 0 && i < len(directories)-1 {
+			// Further directories are linked as the next directory of the previous one
+			binary.LittleEndian.PutUint32(file[offset+2+len(directory)*12:], uint32(8+(i+1)*maxDirectorySize))
+		}
+
+		binary.LittleEndian.PutUint16(file[offset:], uint16(len(directory)))
+		for j, entry := range directory {
+			fieldType := uint16(4)
+			if entry.tag == tiffTagOrientation || entry.tag == tiffTagCompression || entry.tag == tiffTagPhotometricInterpretation {
+				fieldType = 3
+			}
+
+			raw := file[offset+2+j*12:]
+			binary.LittleEndian.PutUint16(raw, entry.tag)
+			binary.LittleEndian.PutUint16(raw[2:], fieldType)
+			binary.LittleEndian.PutUint32(raw[4:], 1)
+			if fieldType == 3 {
+				binary.LittleEndian.PutUint16(raw[8:], uint16(entry.value))
+			} else {
+				binary.LittleEndian.PutUint32(raw[8:], entry.value)
+			}
+		}
+	}
+
+	return append(file, data...)
+}
+
+func TestCR3Preview(t *testing.T) {
+	t.Run("Rotated", func(t *testing.T) {
+		preview, err := readRawPreview(bytes.NewReader(cr3TestFile(t, 6)), cr3Previews)
+		if !assert.NoError(t, err) || !assert.NotNil(t, preview) {
+			return
+		}
+
+		assert.Equal(t, 6, preview.orientation)
+
+		img, err := preview.decode()
+		if assert.NoError(t, err) {
+			assert.Equal(t, image.Rect(0, 0, 600, 1200), img.Bounds(), "preview is rotated to the orientation of the photo")
+		}
+	})
+
+	t.Run("Not rotated", func(t *testing.T) {
+		preview, err := readRawPreview(bytes.NewReader(cr3TestFile(t, 1)), cr3Previews)
+		if !assert.NoError(t, err) || !assert.NotNil(t, preview) {
+			return
+		}
+
+		img, err := preview.decode()
+		if assert.NoError(t, err) {
+			assert.Equal(t, image.Rect(0, 0, 1200, 600), img.Bounds())
+		}
+	})
+
+	t.Run("No preview", func(t *testing.T) {
+		preview, err := readRawPreview(bytes.NewReader(isoTestBox("ftyp", []byte("crx \x00\x00\x00\x01crx isom"))), cr3Previews)
+		assert.NoError(t, err)
+		assert.Nil(t, preview)
+	})
+
+	t.Run("Invalid box", func(t *testing.T) {
+		_, err := readRawPreview(bytes.NewReader([]byte("\x00\x00\x10\x00ftypcrx ")), cr3Previews)
+		assert.Error(t, err)
+	})
+}
+
+func TestTIFFPreview(t *testing.T) {
+	thumbnail := testJPEG(t, 160, 120)
+	preview := testJPEG(t, 1200, 800)
+	rawData := []byte("\xff\xd8\xff\xc3 lossless raw data")
+
+	file := tiffTestFile(func(dataOffset uint32) [][]tiffTestEntry {
+		previewOffset := dataOffset + uint32(len(thumbnail))
+		rawOffset := previewOffset + uint32(len(preview))
+
+		return [][]tiffTestEntry{
+			{
+				{tiffTagOrientation, 8},
+				{tiffTagJPEGInterchangeFormat, dataOffset},
+				{tiffTagJPEGInterchangeFormatLength, uint32(len(thumbnail))},
+			},
+			{
+				{tiffTagCompression, 6},
+				{tiffTagStripOffsets, previewOffset},
+				{tiffTagStripByteCounts, uint32(len(preview))},
+			},
+			{
+				{tiffTagCompression, 7},
+				{tiffTagPhotometricInterpretation, 32803},
+				{tiffTagStripOffsets, rawOffset},
+				{tiffTagStripByteCounts, uint32(len(rawData))},
+			},
+		}
+	}, bytes.Join([][]byte{thumbnail, preview, rawData}, nil))
+
+	result, err := readRawPreview(bytes.NewReader(file), tiffPreviews)
+	if !assert.NoError(t, err) || !assert.NotNil(t, result) {
+		return
+	}
+
+	assert.Equal(t, preview, result.jpeg, "largest preview is used")
+	assert.Equal(t, 8, result.orientation)
+
+	t.Run("Only small previews", func(t *testing.T) {
+		file := tiffTestFile(func(dataOffset uint32) [][]tiffTestEntry {
+			return [][]tiffTestEntry{{
+				{tiffTagJPEGInterchangeFormat, dataOffset},
+				{tiffTagJPEGInterchangeFormatLength, uint32(len(thumbnail))},
+			}}
+		}, thumbnail)
+
+		result, err := readRawPreview(bytes.NewReader(file), tiffPreviews)
+		assert.NoError(t, err)
+		assert.Nil(t, result, "photos with small previews are developed instead")
+	})
+
+	t.Run("Not a TIFF file", func(t *testing.T) {
+		result, err := readRawPreview(bytes.NewReader([]byte("IIU\x00\x08\x00\x00\x00")), tiffPreviews)
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestRAFPreview(t *testing.T) {
+	preview := testJPEG(t, 1600, 1067)
+
+	header := make([]byte, 100)
+	copy(header, "FUJIFILMCCD-RAW 0201FF383501")
+	binary.BigEndian.PutUint32(header[84:], uint32(len(header)))
+	binary.BigEndian.PutUint32(header[88:], uint32(len(preview)))
+
+	result, err := readRawPreview(bytes.NewReader(append(header, preview...)), rafPreviews)
+	if !assert.NoError(t, err) || !assert.NotNil(t, result) {
+		return
+	}
+
+	assert.Equal(t, preview, result.jpeg)
+	assert.Equal(t, 0, result.orientation, "orientation is read from the EXIF data of the preview")
+}
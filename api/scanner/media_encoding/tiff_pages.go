@@ -0,0 +1,53 @@
+package media_encoding
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// maxTIFFPages limits the pages counted of a TIFF file, so files with directories linked in a loop are read in time
+const maxTIFFPages = 10000
+
+const (
+	tiffTagNewSubfileType = 0x00FE
+	// reducedResolutionBit marks directories of thumbnails of other pages
+	reducedResolutionBit = 1 << 0
+)
+
+// TIFFPageCount counts the pages of a TIFF file, which are the chain of its directories excluding thumbnails.
+// Only the directories are read, not the images of the pages.
+func TIFFPageCount(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "open TIFF file to count its pages (%s)", path)
+	}
+	defer file.Close()
+
+	tiff, err := newTIFFReader(file)
+	if err != nil {
+		return 0, errors.Wrapf(err, "read header of TIFF file (%s)", path)
+	}
+	if tiff == nil {
+		return 0, nil
+	}
+
+	pages := 0
+	visited := make(map[int64]bool)
+	for offset := tiff.firstIFD; offset != 0 && !visited[offset] && len(visited) < maxTIFFPages; {
+		visited[offset] = true
+
+		ifd, next, err := tiff.readIFD(offset)
+		if err != nil {
+			return 0, errors.Wrapf(err, "count pages of TIFF file (%s)", path)
+		}
+
+		if subfileType, _ := tiff.value(ifd, tiffTagNewSubfileType); subfileType&reducedResolutionBit == 0 {
+			pages++
+		}
+
+		offset = next
+	}
+
+	return pages, nil
+}
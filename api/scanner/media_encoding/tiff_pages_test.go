@@ -0,0 +1,75 @@
+package media_encoding
+
+import (
+	"encoding/binary"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// multiPageTIFF builds a little endian TIFF file of directories holding only their subfile type,
+// linked in the order of the given subfile types
+func multiPageTIFF(subfileTypes ...uint32) []byte {
+	data := make([]byte, 8)
+	copy(data, "II*\x00")
+	binary.LittleEndian.PutUint32(data[4:], 8)
+
+	for i, subfileType := range subfileTypes {
+		ifd := make([]byte, 2+12+4)
+		binary.LittleEndian.PutUint16(ifd[0:], 1)
+		binary.LittleEndian.PutUint16(ifd[2:], tiffTagNewSubfileType)
+		binary.LittleEndian.PutUint16(ifd[4:], 4)
+		binary.LittleEndian.PutUint32(ifd[6:], 1)
+		binary.LittleEndian.PutUint32(ifd[10:], subfileType)
+		if i < len(subfileTypes)-1 {
+			binary.LittleEndian.PutUint32(ifd[14:], uint32(len(data)+len(ifd)))
+		}
+		data = append(data, ifd...)
+	}
+
+	return data
+}
+
+func TestTIFFPageCount(t *testing.T) {
+	const pageBit = 1 << 1
+
+	tests := []struct {
+		name         string
+		subfileTypes []uint32
+		pages        int
+	}{
+		{"single page", []uint32{0}, 1},
+		{"multiple pages", []uint32{pageBit, pageBit, pageBit}, 3},
+		{"pages with thumbnails", []uint32{pageBit, reducedResolutionBit, pageBit, reducedResolutionBit}, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tiffPath := path.Join(t.TempDir(), "image.tiff")
+			if err := os.WriteFile(tiffPath, multiPageTIFF(test.subfileTypes...), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			pages, err := TIFFPageCount(tiffPath)
+			assert.NoError(t, err)
+			assert.Equal(t, test.pages, pages)
+		})
+	}
+
+	t.Run("directories linked in a loop", func(t *testing.T) {
+		data := multiPageTIFF(pageBit, pageBit)
+		// Link the last directory back to the first one
+		binary.LittleEndian.PutUint32(data[len(data)-4:], 8)
+
+		tiffPath := path.Join(t.TempDir(), "loop.tiff")
+		if err := os.WriteFile(tiffPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		pages, err := TIFFPageCount(tiffPath)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, pages)
+	})
+}
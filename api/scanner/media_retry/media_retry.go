@@ -0,0 +1,131 @@
+// Package media_retry keeps track of the media that failed to be processed, such as files with corrupt exif
+// or files that could not be read because of a transient error, so they are retried with an exponential backoff
+// instead of being left until the next full scan.
+package media_retry
+
+import (
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxAttempts is the number of times a media is retried automatically, after which it is only retried when forced
+const MaxAttempts = 8
+
+// Delay before the first retry, which doubles with every attempt up to maxDelay
+const (
+	initialDelay = time.Minute
+	maxDelay     = 24 * time.Hour
+)
+
+// backoff returns the delay before retrying a media that has been retried the given number of times
+func backoff(attempts int) time.Duration {
+	delay := initialDelay
+	for i := 0; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// RecordFailure adds a media that failed to be processed to the retry queue, or updates the error of a media already in it.
+// Failures of media being retried do not change when they are retried next, as the attempt has already been counted.
+func RecordFailure(db *gorm.DB, albumID int, mediaPath string, failure error) error {
+	nextAttempt := time.Now().Add(backoff(0))
+	retry := models.MediaRetry{
+		AlbumID:       albumID,
+		MediaPath:     mediaPath,
+		MediaPathHash: models.MD5Hash(mediaPath),
+		LastError:     failure.Error(),
+		NextAttemptAt: &nextAttempt,
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "media_path_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"album_id", "last_error", "updated_at"}),
+	}).Create(&retry).Error
+	if err != nil {
+		return errors.Wrapf(err, "add media to retry queue (%s)", mediaPath)
+	}
+
+	return nil
+}
+
+// RecordSuccess removes a media that has been processed from the retry queue, if it is in it
+func RecordSuccess(db *gorm.DB, mediaPath string) error {
+	if err := db.Where("media_path_hash = ?", models.MD5Hash(mediaPath)).Delete(&models.MediaRetry{}).Error; err != nil {
+		return errors.Wrapf(err, "remove media from retry queue (%s)", mediaPath)
+	}
+
+	return nil
+}
+
+// ClaimDue counts an attempt for every media due to be retried at the given time, schedules their next attempt,
+// and returns the ids of the albums to be scanned to retry them
+func ClaimDue(db *gorm.DB, now time.Time) ([]int, error) {
+	var albumIDs []int
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var due []*models.MediaRetry
+		if err := tx.Where("next_attempt_at <= ?", now).Find(&due).Error; err != nil {
+			return errors.Wrap(err, "get media due to be retried")
+		}
+
+		seenAlbums := make(map[int]bool)
+		for _, retry := range due {
+			attempts := retry.Attempts + 1
+
+			var nextAttempt *time.Time
+			if attempts < MaxAttempts {
+				next := now.Add(backoff(attempts))
+				nextAttempt = &next
+			}
+
+			err := tx.Model(retry).Updates(map[string]interface{}{
+				"attempts":        attempts,
+				"next_attempt_at": nextAttempt,
+			}).Error
+			if err != nil {
+				return errors.Wrap(err, "schedule next attempt of media")
+			}
+
+			if !seenAlbums[retry.AlbumID] {
+				seenAlbums[retry.AlbumID] = true
+				albumIDs = append(albumIDs, retry.AlbumID)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return albumIDs, nil
+}
+
+// RetryAll resets the attempts of every media in the retry queue, including those whose attempts have run out,
+// and returns the ids of the albums to be scanned to retry them right away
+func RetryAll(db *gorm.DB) ([]int, error) {
+	var albumIDs []int
+	if err := db.Model(&models.MediaRetry{}).Distinct("album_id").Order("album_id").Pluck("album_id", &albumIDs).Error; err != nil {
+		return nil, errors.Wrap(err, "get albums of media to be retried")
+	}
+
+	nextAttempt := time.Now().Add(backoff(0))
+	err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&models.MediaRetry{}).Updates(map[string]interface{}{
+		"attempts":        0,
+		"next_attempt_at": nextAttempt,
+	}).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "reset attempts of media to be retried")
+	}
+
+	return albumIDs, nil
+}
@@ -0,0 +1,77 @@
+package media_retry_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/media_retry"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestMediaRetry(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	assert.NoError(t, db.Save(&album).Error)
+
+	assert.NoError(t, media_retry.RecordFailure(db, album.ID, "/photos/corrupt.jpg", errors.New("decode exif: unexpected EOF")))
+	assert.NoError(t, media_retry.RecordFailure(db, album.ID, "/photos/corrupt.jpg", errors.New("decode exif: invalid tag")))
+	assert.NoError(t, media_retry.RecordFailure(db, album.ID, "/photos/locked.jpg", errors.New("open: resource busy")))
+
+	var retries []*models.MediaRetry
+	assert.NoError(t, db.Order("id").Find(&retries).Error)
+	if assert.Len(t, retries, 2, "a media failing again is kept once") {
+		assert.Equal(t, "decode exif: invalid tag", retries[0].LastError)
+		assert.Equal(t, 0, retries[0].Attempts)
+	}
+
+	albumIDs, err := media_retry.ClaimDue(db, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, albumIDs, "media are not retried right after failing")
+
+	now := time.Now().Add(2 * time.Minute)
+	albumIDs, err = media_retry.ClaimDue(db, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{album.ID}, albumIDs)
+
+	var retry models.MediaRetry
+	assert.NoError(t, db.Where("media_path = ?", "/photos/corrupt.jpg").First(&retry).Error)
+	assert.Equal(t, 1, retry.Attempts)
+	if assert.NotNil(t, retry.NextAttemptAt) {
+		assert.WithinDuration(t, now.Add(2*time.Minute), *retry.NextAttemptAt, time.Second, "the delay doubles with every attempt")
+	}
+
+	albumIDs, err = media_retry.ClaimDue(db, now)
+	assert.NoError(t, err)
+	assert.Empty(t, albumIDs, "claimed media are not retried again until their next attempt")
+
+	assert.NoError(t, media_retry.RecordSuccess(db, "/photos/locked.jpg"))
+
+	for i := 1; i < media_retry.MaxAttempts; i++ {
+		now = now.Add(24 * time.Hour)
+		_, err := media_retry.ClaimDue(db, now)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, db.Order("id").Find(&retries).Error)
+	if assert.Len(t, retries, 1, "processed media are removed from the retry queue") {
+		assert.Equal(t, media_retry.MaxAttempts, retries[0].Attempts)
+		assert.Nil(t, retries[0].NextAttemptAt, "media are not retried once their attempts have run out")
+	}
+
+	albumIDs, err = media_retry.RetryAll(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{album.ID}, albumIDs)
+
+	assert.NoError(t, db.First(&retry, retries[0].ID).Error)
+	assert.Equal(t, 0, retry.Attempts)
+	assert.NotNil(t, retry.NextAttemptAt, "forcing a retry resumes the automatic retries")
+}
@@ -1,22 +1,35 @@
 package scanner
 
 import (
+	"os"
+
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/scanner/media_encoding"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
 	"github.com/photoview/photoview/api/scanner/scanner_tasks"
+	"github.com/photoview/photoview/api/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func scanMedia(ctx scanner_task.TaskContext, media *models.Media, mediaData *media_encoding.EncodeMediaData, mediaIndex int, mediaTotal int) error {
+// scanMedia processes a single media of an album, and returns whether any of its cached files have been updated
+func scanMedia(ctx scanner_task.TaskContext, media *models.Media, mediaData *media_encoding.EncodeMediaData, mediaIndex int, mediaTotal int) (changed bool, err error) {
+	ctx, span := ctx.StartSpan("scanner.process_media",
+		attribute.Int("media.id", media.ID),
+		attribute.String("media.path", media.Path))
+	defer func() { tracing.EndSpan(span, err) }()
+
 	newCtx, err := scanner_tasks.Tasks.BeforeProcessMedia(ctx, mediaData)
 	if err != nil {
-		return errors.Wrapf(err, "before process media (%s)", media.Path)
+		return false, errors.Wrapf(err, "before process media (%s)", media.Path)
 	}
 
+	// The file is checked before it is processed, so changes made while processing it are noticed by the next scan
+	stat, statErr := os.Stat(media.Path)
+
 	mediaCachePath, err := media.CachePath()
 	if err != nil {
-		return errors.Wrapf(err, "cache directory error (%s)", media.Path)
+		return false, errors.Wrapf(err, "cache directory error (%s)", media.Path)
 	}
 
 	transactionError := newCtx.DatabaseTransaction(func(ctx scanner_task.TaskContext) error {
@@ -24,6 +37,7 @@ func scanMedia(ctx scanner_task.TaskContext, media *models.Media, mediaData *med
 		if err != nil {
 			return errors.Wrapf(err, "process media (%s)", media.Path)
 		}
+		changed = len(updatedURLs) > 0
 
 		if err = scanner_tasks.Tasks.AfterProcessMedia(newCtx, mediaData, updatedURLs, mediaIndex, mediaTotal); err != nil {
 			return errors.Wrap(err, "after process media")
@@ -33,8 +47,14 @@ func scanMedia(ctx scanner_task.TaskContext, media *models.Media, mediaData *med
 	})
 
 	if transactionError != nil {
-		return errors.Wrap(transactionError, "process media database transaction")
+		return false, errors.Wrap(transactionError, "process media database transaction")
+	}
+
+	if statErr == nil {
+		if err := recordMediaFile(newCtx, media, stat); err != nil {
+			return changed, err
+		}
 	}
 
-	return nil
+	return changed, nil
 }
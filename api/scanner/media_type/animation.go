@@ -0,0 +1,75 @@
+package media_type
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// maxPNGChunks is how many chunks of a PNG file are read looking for the animation control chunk,
+// which comes before the image data
+const maxPNGChunks = 64
+
+// IsAnimated returns whether the photo is an animated WebP or PNG (APNG) image.
+// Their originals are served to keep the animation, and only their first frame is used for thumbnails.
+func IsAnimated(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not open file to determine animation %s", path)
+	}
+	defer file.Close()
+
+	head := make([]byte, 21)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "could not read file to determine animation %s", path)
+	}
+	head = head[:n]
+
+	switch {
+	case len(head) == 21 && string(head[0:4]) == "RIFF" && string(head[8:16]) == "WEBPVP8X":
+		// The flags of the extended format have a bit for animations
+		const animationBit = 1 << 1
+		return head[20]&animationBit != 0, nil
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return isAnimatedPNG(file)
+	}
+
+	return false, nil
+}
+
+// isAnimatedPNG returns whether a PNG file has an animation control chunk before its image data
+func isAnimatedPNG(file io.ReadSeeker) (bool, error) {
+	offset := int64(8)
+	chunkHeader := make([]byte, 8)
+
+	for i := 0; i < maxPNGChunks; i++ {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(file, chunkHeader); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return false, nil
+			}
+			return false, err
+		}
+
+		switch string(chunkHeader[4:8]) {
+		case "acTL":
+			return true, nil
+		case "IDAT", "IEND":
+			return false, nil
+		}
+
+		// The length of a chunk excludes its header and its checksum
+		offset += 8 + int64(binary.BigEndian.Uint32(chunkHeader[0:4])) + 4
+	}
+
+	return false, nil
+}
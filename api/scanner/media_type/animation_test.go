@@ -0,0 +1,55 @@
+package media_type
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk[0:4], uint32(len(data)))
+	copy(chunk[4:8], chunkType)
+	chunk = append(chunk, data...)
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, crc32.ChecksumIEEE(chunk[4:]))
+	return append(chunk, checksum...)
+}
+
+func TestIsAnimatedPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	still := buf.Bytes()
+
+	// The animation control chunk follows the header chunk, which is 25 bytes long after the signature
+	animationControl := make([]byte, 8)
+	binary.BigEndian.PutUint32(animationControl[0:4], 1)
+	animated := append(append(append([]byte{}, still[:33]...), pngChunk("acTL", animationControl)...), still[33:]...)
+
+	dir := t.TempDir()
+	stillPath := path.Join(dir, "still.png")
+	animatedPath := path.Join(dir, "animated.png")
+	assert.NoError(t, os.WriteFile(stillPath, still, 0644))
+	assert.NoError(t, os.WriteFile(animatedPath, animated, 0644))
+
+	isAnimated, err := IsAnimated(stillPath)
+	assert.NoError(t, err)
+	assert.False(t, isAnimated)
+
+	isAnimated, err = IsAnimated(animatedPath)
+	assert.NoError(t, err)
+	assert.True(t, isAnimated)
+
+	// Animated PNG images still decode as their default image
+	_, err = png.Decode(bytes.NewReader(animated))
+	assert.NoError(t, err)
+}
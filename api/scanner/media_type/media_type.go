@@ -20,8 +20,12 @@ const (
 	TypePng  MediaType = "image/png"
 	TypeTiff MediaType = "image/tiff"
 	TypeWebp MediaType = "image/webp"
+	TypeAvif MediaType = "image/avif"
 	TypeBmp  MediaType = "image/bmp"
 	TypeHeic MediaType = "image/heic"
+	TypeGif  MediaType = "image/gif"
+	TypePsd  MediaType = "image/vnd.adobe.photoshop"
+	TypeSvg  MediaType = "image/svg+xml"
 
 	// Raw formats
 	TypeDNG MediaType = "image/x-adobe-dng"
@@ -78,13 +82,18 @@ var SupportedMimetypes = [...]MediaType{
 	TypeWebp,
 	TypeBmp,
 	TypeHeic,
+	TypeGif,
+	TypePsd,
 }
 
+// WebMimetypes are photo types that can be shown directly in the browser, their originals are used instead of
+// generating high resolution photos, so animated GIF, WebP and PNG images keep their animation
 var WebMimetypes = [...]MediaType{
 	TypeJpeg,
 	TypePng,
 	TypeWebp,
 	TypeBmp,
+	TypeGif,
 }
 
 var RawMimeTypes = [...]MediaType{
@@ -152,6 +161,10 @@ var fileExtensions = map[string]MediaType{
 	".tiff": TypeTiff,
 	".bmp":  TypeBmp,
 	".heic": TypeHeic,
+	".gif":  TypeGif,
+	".psd":  TypePsd,
+	".psb":  TypePsd,
+	".svg":  TypeSvg,
 
 	// RAW formats
 	".dng": TypeDNG,
@@ -260,6 +273,10 @@ func (imgType *MediaType) IsSupported() bool {
 		return true
 	}
 
+	if executable_worker.RsvgCli.IsInstalled() && *imgType == TypeSvg {
+		return true
+	}
+
 	if executable_worker.FfmpegCli.IsInstalled() && imgType.IsVideo() {
 		return true
 	}
@@ -292,14 +309,23 @@ func GetMediaType(path string) (*MediaType, error) {
 	}
 	defer file.Close()
 
-	head := make([]byte, 261)
-	if _, err := file.Read(head); err != nil {
+	head := make([]byte, rawHeaderSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
 		if err == io.EOF {
 			return nil, nil
 		}
 
 		return nil, errors.Wrapf(err, "could not read file to determine content-type: %s", path)
 	}
+	head = head[:n]
+
+	if rawType, found := rawTypeFromHeader(head); found {
+		if rawType.IsSupported() {
+			return &rawType, nil
+		}
+		return nil, nil
+	}
 
 	_imgType, err := filetype.Image(head)
 	if err != nil {
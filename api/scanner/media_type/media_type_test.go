@@ -37,6 +37,16 @@ func TestMediaTypeFromExtension(t *testing.T) {
 	assert.Equal(t, media_type.TypePng, pngType)
 }
 
+func TestMediaTypeGIF(t *testing.T) {
+	gifType, found := media_type.GetExtensionMediaType(".gif")
+
+	assert.True(t, found)
+	assert.Equal(t, media_type.TypeGif, gifType)
+	assert.True(t, gifType.IsBasicTypeSupported())
+	assert.True(t, gifType.IsWebCompatible(), "the original is shown, so animations are kept")
+	assert.False(t, gifType.IsVideo())
+}
+
 func TestMediaTypeGetExtensions(t *testing.T) {
 	assert.ElementsMatch(t, []string{".jpg", ".JPG", ".jpeg", ".JPEG"}, media_type.TypeJpeg.FileExtensions())
 }
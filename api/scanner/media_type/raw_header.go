@@ -0,0 +1,88 @@
+package media_type
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+// rawHeaderSize is how much of the start of a file is read to detect its type, raw files based on TIFF are told apart
+// by the camera maker in their first directory of tags, which is usually within the first few hundred bytes
+const rawHeaderSize = 4096
+
+// rawTypeFromHeader detects the type of raw files whose extension is not recognized, from the start of the file.
+// Most raw formats are based on TIFF, and are told apart from plain TIFF files by their tags.
+func rawTypeFromHeader(head []byte) (MediaType, bool) {
+	switch {
+	case bytes.HasPrefix(head, []byte("FUJIFILMCCD-RAW")):
+		return TypeRAF, true
+	case bytes.HasPrefix(head, []byte("IIRO")), bytes.HasPrefix(head, []byte("IIRS")), bytes.HasPrefix(head, []byte("MMOR")):
+		return TypeORF, true
+	case len(head) >= 12 && string(head[4:12]) == "ftypcrx ":
+		// CR3 files are ISO base media files, of the brand crx
+		return TypeCR3, true
+	}
+
+	return tiffRawType(head)
+}
+
+// tiffRawType detects raw files based on TIFF, by the tags of their first image file directory
+func tiffRawType(head []byte) (MediaType, bool) {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(head, []byte("II*\x00")):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(head, []byte("MM\x00*")):
+		order = binary.BigEndian
+	default:
+		return "", false
+	}
+
+	if len(head) < 8 {
+		return "", false
+	}
+
+	ifdOffset := int(order.Uint32(head[4:8]))
+	if ifdOffset < 8 || ifdOffset+2 > len(head) {
+		return "", false
+	}
+
+	const (
+		tagMake       = 0x010F
+		tagDNGVersion = 0xC612
+	)
+
+	cameraMake := ""
+	entryCount := int(order.Uint16(head[ifdOffset:]))
+	for i := 0; i < entryCount; i++ {
+		entry := ifdOffset + 2 + i*12
+		if entry+12 > len(head) {
+			break
+		}
+
+		switch order.Uint16(head[entry:]) {
+		case tagDNGVersion:
+			return TypeDNG, true
+		case tagMake:
+			// Values of up to 4 bytes are stored in the entry itself, longer values at an offset
+			length := int(order.Uint32(head[entry+4:]))
+			valueOffset := entry + 8
+			if length > 4 {
+				valueOffset = int(order.Uint32(head[entry+8:]))
+			}
+			if length < 0 || valueOffset+length > len(head) {
+				continue
+			}
+			cameraMake = strings.ToUpper(strings.TrimRight(string(head[valueOffset:valueOffset+length]), "\x00 "))
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(cameraMake, "NIKON"):
+		return TypeNEF, true
+	case strings.HasPrefix(cameraMake, "SONY"):
+		return TypeARW, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,77 @@
+package media_type
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tiffHeader builds the start of a little endian TIFF file, with a single directory holding the given tags
+func tiffHeader(cameraMake string, dng bool) []byte {
+	head := make([]byte, 256)
+	copy(head, "II*\x00")
+	binary.LittleEndian.PutUint32(head[4:], 8)
+
+	entries := 1
+	if dng {
+		entries = 2
+	}
+	binary.LittleEndian.PutUint16(head[8:], uint16(entries))
+
+	makeOffset := 128
+	entry := head[10:]
+	binary.LittleEndian.PutUint16(entry[0:], 0x010F)
+	binary.LittleEndian.PutUint16(entry[2:], 2)
+	binary.LittleEndian.PutUint32(entry[4:], uint32(len(cameraMake)+1))
+	binary.LittleEndian.PutUint32(entry[8:], uint32(makeOffset))
+	copy(head[makeOffset:], cameraMake)
+
+	if dng {
+		entry = head[22:]
+		binary.LittleEndian.PutUint16(entry[0:], 0xC612)
+		binary.LittleEndian.PutUint16(entry[2:], 1)
+		binary.LittleEndian.PutUint32(entry[4:], 4)
+		copy(entry[8:], []byte{1, 4, 0, 0})
+	}
+
+	return head
+}
+
+func TestRawTypeFromHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		head     []byte
+		expected MediaType
+	}{
+		{"Nikon", tiffHeader("NIKON CORPORATION", false), TypeNEF},
+		{"Sony", tiffHeader("SONY", false), TypeARW},
+		{"DNG", tiffHeader("Canon", true), TypeDNG},
+		{"Olympus", []byte("IIRO\x08\x00\x00\x00"), TypeORF},
+		{"Fuji", []byte("FUJIFILMCCD-RAW 0201FF383501"), TypeRAF},
+		{"Canon CR3", []byte("\x00\x00\x00\x18ftypcrx \x00\x00\x00\x01crx isom"), TypeCR3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rawType, found := rawTypeFromHeader(test.head)
+			assert.True(t, found)
+			assert.Equal(t, test.expected, rawType)
+		})
+	}
+
+	t.Run("Plain TIFF", func(t *testing.T) {
+		_, found := rawTypeFromHeader(tiffHeader("Scanner Inc", false))
+		assert.False(t, found)
+	})
+
+	t.Run("Other ISO base media", func(t *testing.T) {
+		_, found := rawTypeFromHeader([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"))
+		assert.False(t, found)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		_, found := rawTypeFromHeader(tiffHeader("NIKON", false)[:20])
+		assert.False(t, found)
+	})
+}
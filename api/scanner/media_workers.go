@@ -0,0 +1,134 @@
+package scanner
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/photoview/photoview/api/database/drivers"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_encoding"
+	"github.com/photoview/photoview/api/scanner/media_retry"
+	"github.com/photoview/photoview/api/scanner/scan_progress"
+	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/scanner/scanner_utils"
+	"github.com/photoview/photoview/api/utils"
+	"gorm.io/gorm"
+)
+
+// MediaWorkers returns the number of media of an album processed at the same time, set by PHOTOVIEW_SCANNER_WORKERS.
+// It applies to every album being scanned, of which there are as many at a time as the concurrent workers of the scanner queue.
+// SQLite databases only support a single writer, so their media are always processed one at a time.
+func MediaWorkers(db *gorm.DB) int {
+	value := utils.EnvScannerWorkers.GetValue()
+	if value == "" {
+		return 1
+	}
+
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 1 {
+		log.Warn(context.Background(), "Invalid number of scanner workers, processing one media at a time",
+			"env", utils.EnvScannerWorkers.GetName(), "value", value)
+		return 1
+	}
+
+	if workers > 1 && drivers.SQLITE.MatchDatabase(db) {
+		return 1
+	}
+
+	return workers
+}
+
+// processAlbumMedia processes the media of an album that have changed since they were last processed with a pool of workers,
+// and returns the media whose cached files have been updated
+func processAlbumMedia(ctx scanner_task.TaskContext, albumMedia []*models.Media) []*models.Media {
+	type mediaJob struct {
+		index int
+		media *models.Media
+	}
+
+	jobs := make(chan mediaJob)
+	changedLock := sync.Mutex{}
+	changedMedia := make([]*models.Media, 0)
+
+	scan_progress.MediaFound(len(albumMedia))
+
+	workers := MediaWorkers(ctx.GetDB())
+	if workers > len(albumMedia) {
+		workers = len(albumMedia)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				changed := processAlbumMediaJob(ctx, job.media, job.index, len(albumMedia))
+				scan_progress.MediaProcessed()
+
+				if changed {
+					changedLock.Lock()
+					changedMedia = append(changedMedia, job.media)
+					changedLock.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i, media := range albumMedia {
+		// The remaining media are left for the next scan once the scan has been cancelled
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- mediaJob{index: i, media: media}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return changedMedia
+}
+
+// processAlbumMediaJob processes a media unless it is unchanged since it was last processed,
+// and returns whether its cached files have been updated
+func processAlbumMediaJob(ctx scanner_task.TaskContext, media *models.Media, index int, total int) bool {
+	upToDate, err := mediaUpToDate(ctx, media)
+	if err != nil {
+		scanner_utils.ScannerMediaError(ctx, media.Path, "Error checking media for changes for album (%d) file (%s): %s\n", ctx.GetAlbum().ID, media.Path, err)
+		recordRetry(ctx, media.Path, err)
+		return false
+	}
+	if upToDate {
+		return false
+	}
+
+	mediaData := media_encoding.NewEncodeMediaData(media)
+
+	// Media whose processing is interrupted by the scan being cancelled are processed again by the next scan
+	changed, err := scanMedia(ctx, media, &mediaData, index, total)
+	if err != nil {
+		if ctx.Err() == nil {
+			scanner_utils.ScannerMediaError(ctx, media.Path, "Error scanning media for album (%d) file (%s): %s\n", ctx.GetAlbum().ID, media.Path, err)
+			recordRetry(ctx, media.Path, err)
+		}
+	} else {
+		if media.FileSize != nil {
+			scan_progress.BytesProcessed(*media.FileSize)
+		}
+
+		if err := media_retry.RecordSuccess(ctx.GetDB(), media.Path); err != nil {
+			log.Warn(ctx, "Removing media from retry queue", "path", media.Path, "error", err)
+		}
+	}
+
+	return changed
+}
+
+// recordRetry adds a media that failed to be processed to the retry queue, so it is processed again before the next full scan
+func recordRetry(ctx scanner_task.TaskContext, mediaPath string, failure error) {
+	if err := media_retry.RecordFailure(ctx.GetDB(), ctx.GetAlbum().ID, mediaPath, failure); err != nil {
+		log.Warn(ctx, "Adding media to retry queue", "path", mediaPath, "error", err)
+	}
+}
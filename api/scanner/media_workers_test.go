@@ -0,0 +1,31 @@
+package scanner_test
+
+import (
+	"testing"
+
+	"github.com/photoview/photoview/api/database/drivers"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMediaWorkers(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	t.Setenv(utils.EnvScannerWorkers.GetName(), "")
+	assert.Equal(t, 1, scanner.MediaWorkers(db))
+
+	t.Setenv(utils.EnvScannerWorkers.GetName(), "invalid")
+	assert.Equal(t, 1, scanner.MediaWorkers(db))
+
+	t.Setenv(utils.EnvScannerWorkers.GetName(), "0")
+	assert.Equal(t, 1, scanner.MediaWorkers(db))
+
+	t.Setenv(utils.EnvScannerWorkers.GetName(), "4")
+	if drivers.SQLITE.MatchDatabase(db) {
+		assert.Equal(t, 1, scanner.MediaWorkers(db), "sqlite only supports a single writer")
+	} else {
+		assert.Equal(t, 4, scanner.MediaWorkers(db))
+	}
+}
@@ -0,0 +1,163 @@
+// Package motion_photo finds the short videos embedded at the end of the JPEG files of motion photos,
+// taken by Google Pixel phones (MVIMG and MP files) and Samsung phones.
+package motion_photo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// Video is where the video of a motion photo is stored in its file
+type Video struct {
+	Offset int64
+	Length int64
+}
+
+const (
+	// xmpSearchSize is how much of the start of a file is searched for XMP data, which is stored in its first segments
+	xmpSearchSize = 128 * 1024
+	// trailerSearchSize is how much of the end of a file is searched for the trailer Samsung phones append to photos
+	trailerSearchSize = 64 * 1024
+)
+
+var (
+	// Pixel phones before the Pixel 4 record the offset of the video from the end of the file
+	microVideoOffsetRegex = regexp.MustCompile(`MicroVideoOffset\s*=\s*"(\d+)"`)
+	// Later phones list the video as an item of the container of the file, with its length
+	containerItemRegex = regexp.MustCompile(`<Container:Item\b[^>]*>`)
+	itemSemanticRegex  = regexp.MustCompile(`Item:Semantic\s*=\s*"MotionPhoto"`)
+	itemLengthRegex    = regexp.MustCompile(`Item:Length\s*=\s*"(\d+)"`)
+
+	samsungVideoMarker = []byte("MotionPhoto_Data")
+)
+
+// Find finds the video of a motion photo of the given size, it returns nil if the photo is not a motion photo
+func Find(r io.ReaderAt, size int64) (*Video, error) {
+	head, err := readAt(r, 0, xmpSearchSize, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if length := xmpVideoLength(head); length > 0 && length < size {
+		return videoAt(r, size-length, size)
+	}
+
+	tail, err := readAt(r, size-trailerSearchSize, trailerSearchSize, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasSuffix(tail, []byte("SEFT")) && bytes.Contains(tail, samsungVideoMarker) {
+		data, err := readAt(r, 0, size, size)
+		if err != nil {
+			return nil, err
+		}
+
+		// The video follows its name, in the first of the data blocks of the trailer
+		if index := bytes.Index(data, samsungVideoMarker); index >= 0 {
+			return videoAt(r, int64(index+len(samsungVideoMarker)), size)
+		}
+	}
+
+	return nil, nil
+}
+
+// xmpVideoLength reads the length of the video of a motion photo from its XMP data, or 0 if it has none
+func xmpVideoLength(head []byte) int64 {
+	if match := microVideoOffsetRegex.FindSubmatch(head); match != nil {
+		length, _ := strconv.ParseInt(string(match[1]), 10, 64)
+		return length
+	}
+
+	for _, item := range containerItemRegex.FindAll(head, -1) {
+		if !itemSemanticRegex.Match(item) {
+			continue
+		}
+
+		if match := itemLengthRegex.FindSubmatch(item); match != nil {
+			length, _ := strconv.ParseInt(string(match[1]), 10, 64)
+			return length
+		}
+	}
+
+	return 0
+}
+
+// videoAt returns the video starting at offset, its length is that of its boxes, as data can follow it.
+// It returns nil if no MP4 video starts at offset.
+func videoAt(r io.ReaderAt, offset int64, size int64) (*Video, error) {
+	header := make([]byte, 8)
+	end := offset
+
+	for end+8 <= size {
+		if _, err := r.ReadAt(header, end); err != nil {
+			return nil, err
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(header))
+		if end == offset && string(header[4:]) != "ftyp" {
+			return nil, nil
+		}
+		if !isBoxType(header[4:]) {
+			break
+		}
+
+		switch boxSize {
+		case 0:
+			// The box extends to the end of the file
+			boxSize = size - end
+		case 1:
+			largeSize := make([]byte, 8)
+			if _, err := r.ReadAt(largeSize, end+8); err != nil {
+				return nil, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(largeSize))
+		}
+
+		if boxSize < 8 || end+boxSize > size {
+			break
+		}
+		end += boxSize
+	}
+
+	if end == offset {
+		return nil, nil
+	}
+
+	return &Video{Offset: offset, Length: end - offset}, nil
+}
+
+// isBoxType returns whether the bytes are the type of an ISO base media box, which are printable characters
+func isBoxType(boxType []byte) bool {
+	for _, c := range boxType {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readAt reads up to length bytes at offset, within a file of the given size
+func readAt(r io.ReaderAt, offset int64, length int64, size int64) ([]byte, error) {
+	if offset < 0 {
+		length += offset
+		offset = 0
+	}
+	if offset+length > size {
+		length = size - offset
+	}
+	if length <= 0 {
+		return []byte{}, nil
+	}
+
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,80 @@
+package motion_photo_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/photoview/photoview/api/scanner/motion_photo"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.UnitTestRun(m))
+}
+
+func box(boxType string, content []byte) []byte {
+	result := make([]byte, 8, 8+len(content))
+	binary.BigEndian.PutUint32(result, uint32(8+len(content)))
+	copy(result[4:], boxType)
+	return append(result, content...)
+}
+
+// testVideo is the boxes of a minimal MP4 file
+var testVideo = bytes.Join([][]byte{
+	box("ftyp", []byte("mp42\x00\x00\x00\x00isommp42")),
+	box("moov", make([]byte, 24)),
+	box("mdat", make([]byte, 100)),
+}, nil)
+
+func jpegWithXMP(xmp string) []byte {
+	return append([]byte("\xff\xd8\xff\xe1\x00\x00http://ns.adobe.com/xap/1.0/\x00"+xmp), []byte("\xff\xd9")...)
+}
+
+func find(t *testing.T, file []byte) *motion_photo.Video {
+	video, err := motion_photo.Find(bytes.NewReader(file), int64(len(file)))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return video
+}
+
+func TestFind(t *testing.T) {
+	t.Run("Google MVIMG", func(t *testing.T) {
+		photo := jpegWithXMP(fmt.Sprintf(`<rdf:Description GCamera:MicroVideo="1" GCamera:MicroVideoVersion="1" GCamera:MicroVideoOffset="%d"/>`, len(testVideo)))
+		file := append(photo, testVideo...)
+
+		assert.Equal(t, &motion_photo.Video{Offset: int64(len(photo)), Length: int64(len(testVideo))}, find(t, file))
+	})
+
+	t.Run("Google motion photo", func(t *testing.T) {
+		photo := jpegWithXMP(fmt.Sprintf(`<Container:Directory><rdf:Seq>
+<rdf:li rdf:parseType="Resource"><Container:Item Item:Mime="image/jpeg" Item:Semantic="Primary" Item:Length="0" Item:Padding="0"/></rdf:li>
+<rdf:li rdf:parseType="Resource"><Container:Item Item:Length="%d" Item:Mime="video/mp4" Item:Semantic="MotionPhoto"/></rdf:li>
+</rdf:Seq></Container:Directory>`, len(testVideo)))
+		file := append(photo, testVideo...)
+
+		assert.Equal(t, &motion_photo.Video{Offset: int64(len(photo)), Length: int64(len(testVideo))}, find(t, file))
+	})
+
+	t.Run("Samsung", func(t *testing.T) {
+		photo := jpegWithXMP("")
+		trailer := []byte("\x00\x00\x30\x0a\x10\x00\x00\x00SEFH\x01\x00\x00\x00SEFT")
+		file := bytes.Join([][]byte{photo, []byte("MotionPhoto_Data"), testVideo, trailer}, nil)
+
+		assert.Equal(t, &motion_photo.Video{Offset: int64(len(photo) + 16), Length: int64(len(testVideo))}, find(t, file),
+			"the trailer after the video is left out")
+	})
+
+	t.Run("Plain photo", func(t *testing.T) {
+		assert.Nil(t, find(t, jpegWithXMP(`<rdf:Description xmp:Rating="5"/>`)))
+	})
+
+	t.Run("Offset not at a video", func(t *testing.T) {
+		photo := jpegWithXMP(`<rdf:Description GCamera:MicroVideoOffset="4"/>`)
+		assert.Nil(t, find(t, photo))
+	})
+}
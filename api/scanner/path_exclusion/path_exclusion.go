@@ -0,0 +1,156 @@
+// Package path_exclusion matches paths against patterns of files and directories that are never scanned,
+// such as the junk left behind by operating systems and applications.
+package path_exclusion
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+// Patterns starting with this prefix are regular expressions, other patterns are globs
+const RegexPrefix = "regex:"
+
+// Patterns is a compiled set of exclusion patterns, a path is excluded if it matches any of them
+type Patterns struct {
+	// names are matched against the name of a file or directory, paths against its absolute path
+	names []*regexp.Regexp
+	paths []*regexp.Regexp
+}
+
+func New() *Patterns {
+	return &Patterns{}
+}
+
+// Add compiles a pattern and adds it to the set.
+// Globs without a slash, such as Thumbs.db, match names at any depth,
+// other globs match absolute paths, where ** matches any number of directories, such as **/.cache/**.
+// Patterns starting with regex: are regular expressions searched for in absolute paths.
+func (p *Patterns) Add(pattern string) error {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return errors.New("exclusion pattern is empty")
+	}
+
+	if strings.HasPrefix(pattern, RegexPrefix) {
+		expression, err := regexp.Compile(strings.TrimPrefix(pattern, RegexPrefix))
+		if err != nil {
+			return errors.Wrapf(err, "invalid exclusion pattern (%s)", pattern)
+		}
+
+		p.paths = append(p.paths, expression)
+		return nil
+	}
+
+	expression, err := regexp.Compile(globExpression(pattern))
+	if err != nil {
+		return errors.Wrapf(err, "invalid exclusion pattern (%s)", pattern)
+	}
+
+	if strings.Contains(pattern, "/") {
+		p.paths = append(p.paths, expression)
+	} else {
+		p.names = append(p.names, expression)
+	}
+
+	return nil
+}
+
+// Matches returns whether the file or directory at the absolute path is excluded
+func (p *Patterns) Matches(filePath string) bool {
+	if p == nil {
+		return false
+	}
+
+	name := path.Base(filePath)
+	for _, expression := range p.names {
+		if expression.MatchString(name) {
+			return true
+		}
+	}
+
+	for _, expression := range p.paths {
+		if expression.MatchString(filePath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate returns an error if the pattern can not be compiled
+func Validate(pattern string) error {
+	return New().Add(pattern)
+}
+
+// GlobalPatterns returns the patterns set by PHOTOVIEW_EXCLUDE_PATTERNS, which apply to the albums of all users
+func GlobalPatterns() []string {
+	patterns := make([]string, 0)
+	for _, pattern := range strings.Split(utils.EnvExcludePatterns.GetValue(), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+// systemDirectories are directories created by operating systems, NAS and sync software, in lower case,
+// which contain recycled files, snapshots and generated thumbnails instead of photos of the library
+var systemDirectories = map[string]bool{
+	"#recycle":                  true,
+	"#snapshot":                 true,
+	"@eadir":                    true,
+	"@recycle":                  true,
+	"@recently-snapshot":        true,
+	"@sharebin":                 true,
+	"@tmp":                      true,
+	"$recycle.bin":              true,
+	"system volume information": true,
+	"lost+found":                true,
+	"__macosx":                  true,
+}
+
+// IsSkippedDirectory returns whether a directory with the given name is skipped by scans,
+// as it is hidden, such as .git and .stfolder, or a well known system directory, such as @eaDir and #recycle.
+// They are scanned like any other directory if PHOTOVIEW_SCAN_HIDDEN_DIRECTORIES is set.
+func IsSkippedDirectory(name string) bool {
+	if utils.EnvScanHiddenDirectories.GetBool() {
+		return false
+	}
+
+	return strings.HasPrefix(name, ".") || systemDirectories[strings.ToLower(name)]
+}
+
+// globExpression translates a glob into an anchored regular expression,
+// where * and ? match within a single path element and ** matches across elements
+func globExpression(glob string) string {
+	var expression strings.Builder
+	expression.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			expression.WriteString("(.*/)?")
+			i += 2
+		case glob[i:] == "/**":
+			expression.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			expression.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			expression.WriteString("[^/]*")
+		case glob[i] == '?':
+			expression.WriteString("[^/]")
+		default:
+			expression.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	expression.WriteString("$")
+	return expression.String()
+}
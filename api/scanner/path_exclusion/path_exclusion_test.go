@@ -0,0 +1,77 @@
+package path_exclusion_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/photoview/photoview/api/scanner/path_exclusion"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.UnitTestRun(m))
+}
+
+func TestPatterns(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		excluded bool
+	}{
+		{"Thumbs.db", "/photos/2020/Thumbs.db", true},
+		{"Thumbs.db", "/photos/Thumbs.db.jpg", false},
+		{"*.tmp", "/photos/trip/upload.tmp", true},
+		{"@eaDir", "/photos/trip/@eaDir", true},
+		{"**/.cache/**", "/photos/trip/.cache", true},
+		{"**/.cache/**", "/photos/trip/.cache/thumb/photo.jpg", true},
+		{"**/.cache/**", "/photos/trip/cache/photo.jpg", false},
+		{"/photos/exports/*", "/photos/exports/photo.jpg", true},
+		{"/photos/exports/*", "/photos/exports/2020/photo.jpg", false},
+		{"/photos/**/raw", "/photos/raw", true},
+		{"/photos/**/raw", "/photos/2020/trip/raw", true},
+		{"/photos/photo?.jpg", "/photos/photo1.jpg", true},
+		{"regex:/tmp-[0-9]+/", "/photos/tmp-42/photo.jpg", true},
+		{"regex:/tmp-[0-9]+/", "/photos/tmp-new/photo.jpg", false},
+	}
+
+	for _, test := range tests {
+		patterns := path_exclusion.New()
+		if !assert.NoError(t, patterns.Add(test.pattern)) {
+			continue
+		}
+
+		assert.Equal(t, test.excluded, patterns.Matches(test.path), "pattern %q on path %q", test.pattern, test.path)
+	}
+
+	var nilPatterns *path_exclusion.Patterns
+	assert.False(t, nilPatterns.Matches("/photos/Thumbs.db"), "nothing is excluded without patterns")
+
+	assert.Error(t, path_exclusion.Validate(""))
+	assert.Error(t, path_exclusion.Validate("regex:tmp-[0-9"))
+	assert.NoError(t, path_exclusion.Validate("[abc].jpg"))
+}
+
+func TestGlobalPatterns(t *testing.T) {
+	t.Setenv(utils.EnvExcludePatterns.GetName(), "Thumbs.db, **/.cache/** ,,")
+
+	assert.Equal(t, []string{"Thumbs.db", "**/.cache/**"}, path_exclusion.GlobalPatterns())
+}
+
+func TestIsSkippedDirectory(t *testing.T) {
+	for _, name := range []string{".git", ".stfolder", "#recycle", "@eaDir", "$RECYCLE.BIN", "System Volume Information"} {
+		assert.True(t, path_exclusion.IsSkippedDirectory(name), name)
+	}
+
+	for _, name := range []string{"2020", "recycle", "eaDir", "trip.git"} {
+		assert.False(t, path_exclusion.IsSkippedDirectory(name), name)
+	}
+
+	include := "1"
+	utils.EnvScanHiddenDirectories.SetOverride(&include)
+	defer utils.EnvScanHiddenDirectories.SetOverride(nil)
+
+	assert.False(t, path_exclusion.IsSkippedDirectory(".git"))
+	assert.False(t, path_exclusion.IsSkippedDirectory("@eaDir"))
+}
@@ -1,17 +1,20 @@
 package periodic_scanner
 
 import (
-	"log"
+	"context"
 	"sync"
 	"time"
 
+	"github.com/photoview/photoview/api/cluster"
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner/scanner_queue"
 	"gorm.io/gorm"
 )
 
 type periodicScanner struct {
 	ticker         *time.Ticker
+	interval       time.Duration
 	ticker_changed chan bool
 	mutex          *sync.Mutex
 	db             *gorm.DB
@@ -46,6 +49,7 @@ func InitializePeriodicScanner(db *gorm.DB) error {
 	}
 
 	go scanIntervalRunner()
+	go retryRunner()
 
 	ChangePeriodicScanInterval(scanInterval)
 	return nil
@@ -55,9 +59,9 @@ func ChangePeriodicScanInterval(duration time.Duration) {
 	var new_ticker *time.Ticker = nil
 	if duration > 0 {
 		new_ticker = time.NewTicker(duration)
-		log.Printf("Periodic scan interval changed: %s", duration.String())
+		log.Info(context.Background(), "Periodic scan interval changed", "interval", duration)
 	} else {
-		log.Print("Periodic scan interval changed: disabled")
+		log.Info(context.Background(), "Periodic scan interval changed", "interval", "disabled")
 	}
 
 	{
@@ -69,24 +73,66 @@ func ChangePeriodicScanInterval(duration time.Duration) {
 		}
 
 		mainPeriodicScanner.ticker = new_ticker
+		mainPeriodicScanner.interval = duration
 		mainPeriodicScanner.ticker_changed <- true
 	}
 }
 
 func scanIntervalRunner() {
+	ctx := context.Background()
+
 	for {
-		log.Print("Scan interval runner: Waiting for signal")
+		log.Debug(ctx, "Scan interval runner: Waiting for signal")
 		if mainPeriodicScanner.ticker != nil {
 			select {
 			case <-mainPeriodicScanner.ticker_changed:
-				log.Print("Scan interval runner: New ticker detected")
+				log.Debug(ctx, "Scan interval runner: New ticker detected")
 			case <-mainPeriodicScanner.ticker.C:
-				log.Print("Scan interval runner: Starting periodic scan")
-				scanner_queue.AddAllToQueue()
+				if !claimPeriodicScan(ctx) {
+					continue
+				}
+
+				log.Info(ctx, "Scan interval runner: Starting periodic scan")
+				scanner_queue.AddAllToQueue(log.WithRequestID(ctx, log.NewRequestID()))
 			}
 		} else {
 			<-mainPeriodicScanner.ticker_changed
-			log.Print("Scan interval runner: New ticker detected")
+			log.Debug(ctx, "Scan interval runner: New ticker detected")
+		}
+	}
+}
+
+// How often the media that failed to be processed are checked for being due to be retried
+const retryCheckInterval = time.Minute
+
+// retryRunner scans the albums of media that failed to be processed once they are due to be retried
+func retryRunner() {
+	ctx := context.Background()
+
+	for range time.Tick(retryCheckInterval) {
+		if _, err := scanner_queue.RetryFailedMedia(log.WithRequestID(ctx, log.NewRequestID()), false); err != nil {
+			log.Error(ctx, "Retry runner: Retrying failed media", "error", err)
 		}
 	}
 }
+
+// claimPeriodicScan returns whether this instance should start the periodic scan,
+// as only one of the instances sharing the database starts it every interval
+func claimPeriodicScan(ctx context.Context) bool {
+	mainPeriodicScanner.mutex.Lock()
+	interval := mainPeriodicScanner.interval
+	mainPeriodicScanner.mutex.Unlock()
+
+	// The lease expires a bit before the next tick, so the instance that scanned last can claim the next scan
+	claimed, err := cluster.TryAcquire(mainPeriodicScanner.db, "periodic-scan", interval-interval/10)
+	if err != nil {
+		log.Error(ctx, "Scan interval runner: Claiming periodic scan", "error", err)
+		return false
+	}
+
+	if !claimed {
+		log.Debug(ctx, "Scan interval runner: Periodic scan started by another instance")
+	}
+
+	return claimed
+}
@@ -0,0 +1,63 @@
+// Package scan_history keeps the statistics of finished scans, such as the number of media added and how long the scan took,
+// so admins can follow the growth of the library and the performance of scans over time.
+package scan_history
+
+import (
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Number of scans kept, the statistics of older scans are deleted when a scan finishes
+const keepScans = 1000
+
+// Save records the statistics of a finished scan from its final progress, and deletes the statistics of the oldest scans
+func Save(db *gorm.DB, progress *models.ScanProgress) (*models.ScanStatistics, error) {
+	if progress.StartedAt == nil || progress.FinishedAt == nil {
+		return nil, errors.New("scan has not finished")
+	}
+
+	var mediaCount int64
+	if err := db.Model(&models.Media{}).Count(&mediaCount).Error; err != nil {
+		return nil, errors.Wrap(err, "count media of library")
+	}
+
+	statistics := models.ScanStatistics{
+		StartedAt:      *progress.StartedAt,
+		FinishedAt:     *progress.FinishedAt,
+		AlbumsScanned:  progress.AlbumsScanned,
+		FilesSeen:      progress.MediaTotal,
+		MediaAdded:     progress.MediaAdded,
+		MediaRemoved:   progress.MediaRemoved,
+		BytesProcessed: int64(progress.BytesProcessed),
+		FailureCount:   progress.FailureCount,
+		MediaCount:     mediaCount,
+	}
+
+	if err := db.Create(&statistics).Error; err != nil {
+		return nil, errors.Wrap(err, "save scan statistics")
+	}
+
+	if err := deleteOldStatistics(db); err != nil {
+		return &statistics, err
+	}
+
+	return &statistics, nil
+}
+
+func deleteOldStatistics(db *gorm.DB) error {
+	var ids []int
+	if err := db.Model(&models.ScanStatistics{}).Order("id DESC").Pluck("id", &ids).Error; err != nil {
+		return errors.Wrap(err, "get statistics of scans")
+	}
+
+	if len(ids) <= keepScans {
+		return nil
+	}
+
+	if err := db.Where("id IN ?", ids[keepScans:]).Delete(&models.ScanStatistics{}).Error; err != nil {
+		return errors.Wrap(err, "delete statistics of old scans")
+	}
+
+	return nil
+}
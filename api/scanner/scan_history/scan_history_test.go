@@ -0,0 +1,56 @@
+package scan_history_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/scan_history"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestSaveScanStatistics(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	assert.NoError(t, db.Save(&album).Error)
+	for _, mediaPath := range []string{"/photos/a.jpg", "/photos/b.jpg"} {
+		assert.NoError(t, db.Save(&models.Media{Title: mediaPath, Path: mediaPath, AlbumID: album.ID}).Error)
+	}
+
+	_, err := scan_history.Save(db, &models.ScanProgress{Running: true})
+	assert.Error(t, err, "scans in progress are not saved")
+
+	startedAt := time.Now().Add(-90 * time.Second)
+	finishedAt := time.Now()
+	statistics, err := scan_history.Save(db, &models.ScanProgress{
+		StartedAt:      &startedAt,
+		FinishedAt:     &finishedAt,
+		AlbumsScanned:  1,
+		MediaTotal:     2,
+		MediaAdded:     2,
+		MediaRemoved:   1,
+		BytesProcessed: 4096,
+		FailureCount:   1,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var saved models.ScanStatistics
+	assert.NoError(t, db.First(&saved, statistics.ID).Error)
+	assert.Equal(t, 1, saved.AlbumsScanned)
+	assert.Equal(t, 2, saved.FilesSeen)
+	assert.Equal(t, 2, saved.MediaAdded)
+	assert.Equal(t, 1, saved.MediaRemoved)
+	assert.EqualValues(t, 4096, saved.BytesProcessed)
+	assert.Equal(t, 1, saved.FailureCount)
+	assert.EqualValues(t, 2, saved.MediaCount, "the size of the library is recorded")
+	assert.InDelta(t, 90, saved.Duration(), 1)
+}
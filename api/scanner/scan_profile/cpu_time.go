@@ -0,0 +1,25 @@
+//go:build !windows
+
+package scan_profile
+
+import (
+	"syscall"
+	"time"
+)
+
+// CPUTime returns the CPU time used by the server so far, and by the programs it has run and waited for,
+// such as ffmpeg and exiftool
+func CPUTime() (self time.Duration, children time.Duration, ok bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, 0, false
+	}
+	self = time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+
+	if err := syscall.Getrusage(syscall.RUSAGE_CHILDREN, &usage); err != nil {
+		return 0, 0, false
+	}
+	children = time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+
+	return self, children, true
+}
@@ -0,0 +1,8 @@
+package scan_profile
+
+import "time"
+
+// CPUTime is not measured on Windows
+func CPUTime() (self time.Duration, children time.Duration, ok bool) {
+	return 0, 0, false
+}
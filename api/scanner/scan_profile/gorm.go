@@ -0,0 +1,63 @@
+package scan_profile
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const gormEndKey = "scan_profile:end"
+
+// GormPlugin measures the time spent in every database query while profiling is enabled
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "scan_profile"
+}
+
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+
+	errs := []error{
+		callback.Create().Before("gorm:create").Register("scan_profile:before_create", startQuery(StageDatabaseWrite)),
+		callback.Create().After("gorm:create").Register("scan_profile:after_create", endQuery),
+		callback.Query().Before("gorm:query").Register("scan_profile:before_query", startQuery(StageDatabaseRead)),
+		callback.Query().After("gorm:query").Register("scan_profile:after_query", endQuery),
+		callback.Update().Before("gorm:update").Register("scan_profile:before_update", startQuery(StageDatabaseWrite)),
+		callback.Update().After("gorm:update").Register("scan_profile:after_update", endQuery),
+		callback.Delete().Before("gorm:delete").Register("scan_profile:before_delete", startQuery(StageDatabaseWrite)),
+		callback.Delete().After("gorm:delete").Register("scan_profile:after_delete", endQuery),
+		callback.Row().Before("gorm:row").Register("scan_profile:before_row", startQuery(StageDatabaseRead)),
+		callback.Row().After("gorm:row").Register("scan_profile:after_row", endQuery),
+		callback.Raw().Before("gorm:raw").Register("scan_profile:before_raw", startQuery(StageDatabaseWrite)),
+		callback.Raw().After("gorm:raw").Register("scan_profile:after_raw", endQuery),
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return errors.Wrap(err, "register scan profile callbacks")
+		}
+	}
+
+	return nil
+}
+
+func startQuery(stage Stage) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if !Enabled() {
+			return
+		}
+
+		db.InstanceSet(gormEndKey, Start(stage))
+	}
+}
+
+func endQuery(db *gorm.DB) {
+	value, found := db.InstanceGet(gormEndKey)
+	if !found {
+		return
+	}
+
+	if end, ok := value.(func()); ok {
+		end()
+	}
+}
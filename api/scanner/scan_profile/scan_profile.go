@@ -0,0 +1,135 @@
+// Package scan_profile measures the time the scanner spends in each stage of a scan, such as walking directories,
+// reading EXIF data, generating thumbnails and writing to the database, so users can find out whether their scans
+// are limited by the CPU, the disk or the database. Measuring is enabled by `photoview scan -profile`,
+// until then it costs next to nothing.
+package scan_profile
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stage is a part of the work of the scanner whose time is measured
+type Stage string
+
+const (
+	// StageDirectoryWalk is reading directories to find the albums and media files
+	StageDirectoryWalk Stage = "directory walk"
+	// StageTypeDetection is reading the start of files to detect whether they are photos or videos
+	StageTypeDetection Stage = "type detection"
+	// StageContentHash is reading media files to hash their content
+	StageContentHash Stage = "content hashing"
+	// StageEXIF is reading and parsing the EXIF data of media files
+	StageEXIF Stage = "exif"
+	// StageThumbnails is generating thumbnails and high resolution photos
+	StageThumbnails Stage = "thumbnails"
+	// StageVideoEncoding is encoding videos for the web
+	StageVideoEncoding Stage = "video encoding"
+	// StageDatabaseRead is querying the database
+	StageDatabaseRead Stage = "database reads"
+	// StageDatabaseWrite is creating, updating and deleting rows in the database
+	StageDatabaseWrite Stage = "database writes"
+)
+
+// Stages are the measured stages, in the order they are reported
+var Stages = []Stage{
+	StageDirectoryWalk,
+	StageTypeDetection,
+	StageContentHash,
+	StageEXIF,
+	StageThumbnails,
+	StageVideoEncoding,
+	StageDatabaseRead,
+	StageDatabaseWrite,
+}
+
+// Timing is the time spent in a stage of the scan
+type Timing struct {
+	Stage Stage
+	// Count is how many times the stage was run, such as the number of files whose type was detected
+	Count int
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Average is the average time a single run of the stage took
+func (t Timing) Average() time.Duration {
+	if t.Count == 0 {
+		return 0
+	}
+
+	return t.Total / time.Duration(t.Count)
+}
+
+var (
+	enabled int32
+
+	timingsLock = &sync.Mutex{}
+	timings     = make(map[Stage]*Timing)
+)
+
+// Enable starts measuring the stages of scans
+func Enable() {
+	atomic.StoreInt32(&enabled, 1)
+}
+
+// Enabled returns whether the stages of scans are measured
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+// Start starts measuring a run of a stage, and returns the function ending it.
+// Stages run at the same time by multiple workers are measured separately, so their total can exceed the duration of the scan.
+func Start(stage Stage) (end func()) {
+	if !Enabled() {
+		return func() {}
+	}
+
+	startedAt := time.Now()
+	return func() {
+		record(stage, time.Since(startedAt))
+	}
+}
+
+func record(stage Stage, duration time.Duration) {
+	timingsLock.Lock()
+	defer timingsLock.Unlock()
+
+	timing, found := timings[stage]
+	if !found {
+		timing = &Timing{Stage: stage}
+		timings[stage] = timing
+	}
+
+	timing.Count++
+	timing.Total += duration
+	if duration > timing.Max {
+		timing.Max = duration
+	}
+}
+
+// Timings returns the time spent in every stage so far, in the order of Stages
+func Timings() []Timing {
+	timingsLock.Lock()
+	defer timingsLock.Unlock()
+
+	result := make([]Timing, 0, len(Stages))
+	for _, stage := range Stages {
+		if timing, found := timings[stage]; found {
+			result = append(result, *timing)
+		} else {
+			result = append(result, Timing{Stage: stage})
+		}
+	}
+
+	return result
+}
+
+// Reset discards the times measured so far
+func Reset() {
+	timingsLock.Lock()
+	defer timingsLock.Unlock()
+
+	timings = make(map[Stage]*Timing)
+}
@@ -0,0 +1,43 @@
+package scan_profile_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/scanner/scan_profile"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.UnitTestRun(m))
+}
+
+func TestTimings(t *testing.T) {
+	defer scan_profile.Reset()
+
+	// Nothing is measured until profiling is enabled
+	scan_profile.Start(scan_profile.StageEXIF)()
+	assert.Zero(t, scan_profile.Timings()[3].Count)
+
+	scan_profile.Enable()
+
+	end := scan_profile.Start(scan_profile.StageEXIF)
+	time.Sleep(20 * time.Millisecond)
+	end()
+	scan_profile.Start(scan_profile.StageEXIF)()
+
+	timings := scan_profile.Timings()
+	assert.Len(t, timings, len(scan_profile.Stages), "every stage is reported, even if it did not run")
+
+	exif := timings[3]
+	assert.Equal(t, scan_profile.StageEXIF, exif.Stage)
+	assert.Equal(t, 2, exif.Count)
+	assert.GreaterOrEqual(t, exif.Total, 20*time.Millisecond)
+	assert.GreaterOrEqual(t, exif.Max, 20*time.Millisecond)
+	assert.Equal(t, exif.Total/2, exif.Average())
+
+	assert.Zero(t, timings[0].Count)
+	assert.Zero(t, timings[0].Average())
+}
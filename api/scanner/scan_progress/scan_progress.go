@@ -0,0 +1,185 @@
+// Package scan_progress keeps track of the progress of the running scan, such as the album being scanned
+// and the number of media processed, and publishes it to subscribers so a progress bar can be shown.
+package scan_progress
+
+import (
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/utils"
+)
+
+// How often the progress is published to subscribers at most while a scan is running
+const publishInterval = 500 * time.Millisecond
+
+type ProgressChannel = chan *models.ScanProgress
+
+var (
+	progressLock    = &sync.Mutex{}
+	progress        = models.ScanProgress{}
+	publishThrottle = utils.NewThrottle(publishInterval)
+
+	listeners      = make(map[int]ProgressChannel)
+	nextListenerID = 0
+
+	// pendingMediaRemoved are media removed before the albums of a scan are queued,
+	// such as the media of deleted directories, counted once the scan starts
+	pendingMediaRemoved = 0
+)
+
+// Get returns the progress of the running scan, or of the latest scan if none is running
+func Get() *models.ScanProgress {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+
+	return copyProgress()
+}
+
+// Subscribe registers a channel receiving the progress whenever it changes.
+// The channel should be buffered, a progress not yet received is replaced by newer progress.
+func Subscribe(channel ProgressChannel) int {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+
+	nextListenerID++
+	listeners[nextListenerID] = channel
+
+	return nextListenerID
+}
+
+func Unsubscribe(listenerID int) {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+
+	delete(listeners, listenerID)
+}
+
+// AlbumQueued counts an album added to the scanner queue, starting a new scan if none is running
+func AlbumQueued() {
+	update(func() {
+		if !progress.Running {
+			startedAt := time.Now()
+			progress = models.ScanProgress{Running: true, StartedAt: &startedAt, MediaRemoved: pendingMediaRemoved}
+			pendingMediaRemoved = 0
+		}
+		progress.AlbumsTotal++
+	})
+}
+
+// AlbumStarted marks the album at the path as the one being scanned
+func AlbumStarted(albumPath string) {
+	update(func() {
+		progress.CurrentAlbum = &albumPath
+	})
+}
+
+// AlbumScanned counts an album that has finished being scanned
+func AlbumScanned() {
+	update(func() {
+		progress.AlbumsScanned++
+	})
+}
+
+// MediaFound adds the media found in an album to the media to be processed
+func MediaFound(count int) {
+	update(func() {
+		progress.MediaTotal += count
+	})
+}
+
+// MediaProcessed counts a media that has been processed, or skipped as it is unchanged
+func MediaProcessed() {
+	update(func() {
+		progress.MediaProcessed++
+	})
+}
+
+// MediaAdded counts a media found for a new file
+func MediaAdded() {
+	update(func() {
+		progress.MediaAdded++
+	})
+}
+
+// MediaRemoved counts media deleted as their files no longer exist
+func MediaRemoved(count int) {
+	if count == 0 {
+		return
+	}
+
+	update(func() {
+		if progress.Running {
+			progress.MediaRemoved += count
+		} else {
+			pendingMediaRemoved += count
+		}
+	})
+}
+
+// BytesProcessed adds the size of the file of a media that has been processed
+func BytesProcessed(size int64) {
+	update(func() {
+		progress.BytesProcessed += int(size)
+	})
+}
+
+// FailureRecorded counts a failure added to the report of the scan
+func FailureRecorded() {
+	update(func() {
+		progress.FailureCount++
+	})
+}
+
+// Completed finishes the running scan, publishes its final progress right away and returns it.
+// It returns nil if no scan was running.
+func Completed() *models.ScanProgress {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+
+	if !progress.Running {
+		return nil
+	}
+
+	finishedAt := time.Now()
+	progress.Running = false
+	progress.CurrentAlbum = nil
+	progress.FinishedAt = &finishedAt
+
+	publish()
+
+	return copyProgress()
+}
+
+// update changes the progress and publishes it, unless it has been published recently
+func update(change func()) {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+
+	change()
+	publishThrottle.Trigger(publish)
+}
+
+// Progress lock should be held prior to calling this function
+func publish() {
+	for _, listener := range listeners {
+		current := copyProgress()
+
+		// Replace progress the listener has not received yet, only the latest progress is of interest
+		select {
+		case <-listener:
+		default:
+		}
+
+		select {
+		case listener <- current:
+		default:
+		}
+	}
+}
+
+// Progress lock should be held prior to calling this function
+func copyProgress() *models.ScanProgress {
+	result := progress
+	return &result
+}
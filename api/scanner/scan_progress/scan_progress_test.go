@@ -0,0 +1,70 @@
+package scan_progress_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/photoview/photoview/api/scanner/scan_progress"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.UnitTestRun(m))
+}
+
+func TestScanProgress(t *testing.T) {
+	channel := make(scan_progress.ProgressChannel, 1)
+	listenerID := scan_progress.Subscribe(channel)
+	defer scan_progress.Unsubscribe(listenerID)
+
+	// Media of deleted directories are removed before the albums of the scan are queued
+	scan_progress.MediaRemoved(2)
+
+	scan_progress.AlbumQueued()
+	scan_progress.AlbumQueued()
+	scan_progress.AlbumStarted("/photos/album")
+	scan_progress.MediaFound(3)
+	scan_progress.MediaAdded()
+	scan_progress.MediaProcessed()
+	scan_progress.BytesProcessed(1000)
+	scan_progress.MediaProcessed()
+	scan_progress.FailureRecorded()
+	scan_progress.AlbumScanned()
+
+	current := scan_progress.Get()
+	assert.True(t, current.Running)
+	assert.NotNil(t, current.StartedAt)
+	if assert.NotNil(t, current.CurrentAlbum) {
+		assert.Equal(t, "/photos/album", *current.CurrentAlbum)
+	}
+	assert.Equal(t, 1, current.AlbumsScanned)
+	assert.Equal(t, 2, current.AlbumsTotal)
+	assert.Equal(t, 2, current.MediaProcessed)
+	assert.Equal(t, 3, current.MediaTotal)
+	assert.Equal(t, 1, current.FailureCount)
+	assert.Equal(t, 1, current.MediaAdded)
+	assert.Equal(t, 2, current.MediaRemoved)
+	assert.Equal(t, 1000, current.BytesProcessed)
+
+	final := scan_progress.Completed()
+	if assert.NotNil(t, final) {
+		assert.False(t, final.Running)
+		assert.Equal(t, 2, final.MediaRemoved)
+	}
+	assert.Nil(t, scan_progress.Completed(), "no scan is running once it has completed")
+
+	select {
+	case published := <-channel:
+		assert.False(t, published.Running, "completion is published right away")
+		assert.Nil(t, published.CurrentAlbum)
+		assert.NotNil(t, published.FinishedAt)
+		assert.Equal(t, 2, published.MediaProcessed)
+	default:
+		assert.Fail(t, "progress should be published on completion")
+	}
+
+	scan_progress.AlbumQueued()
+	assert.Equal(t, 1, scan_progress.Get().AlbumsTotal, "a new scan starts counting from zero")
+	assert.Equal(t, 0, scan_progress.Get().MediaProcessed)
+}
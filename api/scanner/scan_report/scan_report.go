@@ -0,0 +1,160 @@
+// Package scan_report persists the failures of every scan, such as corrupt files, decode errors and failed transcodes,
+// so they can be reviewed by admins instead of only being written to the server logs.
+package scan_report
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/scan_progress"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Number of reports kept, older reports are deleted when a scan finishes
+const keepReports = 50
+
+var (
+	reportLock    = &sync.Mutex{}
+	reportDB      *gorm.DB
+	currentReport *models.ScanReport
+)
+
+// InitializeScanReports enables recording the failures of scans, until then failures are only logged
+func InitializeScanReports(db *gorm.DB) {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+
+	reportDB = db
+	currentReport = nil
+}
+
+// ScanStarted starts a new report, unless a scan is already in progress
+func ScanStarted() {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+
+	if _, err := startReport(); err != nil {
+		log.Warn(context.Background(), "Starting scan report", "error", err)
+	}
+}
+
+// Report lock should be held prior to calling this function
+func startReport() (*models.ScanReport, error) {
+	if reportDB == nil {
+		return nil, nil
+	}
+
+	if currentReport != nil {
+		return currentReport, nil
+	}
+
+	report := models.ScanReport{}
+	if err := reportDB.Create(&report).Error; err != nil {
+		return nil, errors.Wrap(err, "save scan report")
+	}
+
+	currentReport = &report
+	return currentReport, nil
+}
+
+// RecordFailure adds a failure to the report of the current scan, starting one if no scan is in progress.
+// The album and media path are optional, and describe what failed to be scanned.
+func RecordFailure(albumID *int, mediaPath *string, message string) {
+	recordFailure(models.ScanFailure{
+		AlbumID:   albumID,
+		MediaPath: mediaPath,
+		Message:   message,
+	})
+}
+
+// RecordDirectoryFailure is like RecordFailure, for a directory that could not be read and has been skipped
+func RecordDirectoryFailure(albumID *int, directoryPath string, message string) {
+	recordFailure(models.ScanFailure{
+		AlbumID:       albumID,
+		DirectoryPath: &directoryPath,
+		Message:       message,
+	})
+}
+
+func recordFailure(failure models.ScanFailure) {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+
+	report, err := startReport()
+	if err != nil || report == nil {
+		if err != nil {
+			log.Warn(context.Background(), "Starting scan report", "error", err)
+		}
+		return
+	}
+
+	failure.ScanReportID = report.ID
+
+	err = reportDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&failure).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(report).Update("failure_count", gorm.Expr("failure_count + 1")).Error
+	})
+	if err != nil {
+		log.Warn(context.Background(), "Recording scan failure", "error", err)
+		return
+	}
+
+	report.FailureCount++
+	scan_progress.FailureRecorded()
+}
+
+// ScanCompleted finishes the report of the current scan and deletes the oldest reports.
+// It returns the finished report, or nil if no scan was in progress.
+func ScanCompleted() (*models.ScanReport, error) {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+
+	report := currentReport
+	if reportDB == nil || report == nil {
+		return nil, nil
+	}
+	currentReport = nil
+
+	finishedAt := time.Now()
+	report.FinishedAt = &finishedAt
+	if err := reportDB.Model(report).Update("finished_at", finishedAt).Error; err != nil {
+		return nil, errors.Wrap(err, "finish scan report")
+	}
+
+	if err := deleteOldReports(reportDB); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+func deleteOldReports(db *gorm.DB) error {
+	var reportIDs []int
+	if err := db.Model(&models.ScanReport{}).Order("id DESC").Pluck("id", &reportIDs).Error; err != nil {
+		return errors.Wrap(err, "get scan reports")
+	}
+
+	if len(reportIDs) <= keepReports {
+		return nil
+	}
+	oldReportIDs := reportIDs[keepReports:]
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("scan_report_id IN ?", oldReportIDs).Delete(&models.ScanFailure{}).Error; err != nil {
+			return errors.Wrap(err, "delete failures of old scan reports")
+		}
+
+		if err := tx.Where("id IN ?", oldReportIDs).Delete(&models.ScanReport{}).Error; err != nil {
+			return errors.Wrap(err, "delete old scan reports")
+		}
+
+		return nil
+	})
+}
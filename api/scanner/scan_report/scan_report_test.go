@@ -0,0 +1,82 @@
+package scan_report_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/scan_report"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestScanReport(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	scan_report.InitializeScanReports(db)
+	defer scan_report.InitializeScanReports(nil)
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	assert.NoError(t, db.Save(&album).Error)
+
+	report, err := scan_report.ScanCompleted()
+	assert.NoError(t, err)
+	assert.Nil(t, report, "no report without a scan in progress")
+
+	scan_report.ScanStarted()
+	mediaPath := "/photos/corrupt.jpg"
+	scan_report.RecordFailure(&album.ID, &mediaPath, "decode image: unexpected EOF")
+	scan_report.RecordFailure(nil, nil, "generate blurhashes: failed")
+
+	report, err = scan_report.ScanCompleted()
+	if !assert.NoError(t, err) || !assert.NotNil(t, report) {
+		return
+	}
+
+	assert.Equal(t, 2, report.FailureCount)
+	assert.NotNil(t, report.FinishedAt)
+
+	var saved models.ScanReport
+	assert.NoError(t, db.First(&saved, report.ID).Error)
+	assert.Equal(t, 2, saved.FailureCount)
+	assert.NotNil(t, saved.FinishedAt)
+
+	var failures []*models.ScanFailure
+	assert.NoError(t, db.Where("scan_report_id = ?", report.ID).Order("id").Find(&failures).Error)
+	if assert.Len(t, failures, 2) {
+		assert.Equal(t, &album.ID, failures[0].AlbumID)
+		assert.Equal(t, &mediaPath, failures[0].MediaPath)
+		assert.Equal(t, "decode image: unexpected EOF", failures[0].Message)
+		assert.Nil(t, failures[1].AlbumID)
+		assert.Nil(t, failures[1].MediaPath)
+	}
+
+	// A failure outside of a scan starts a new report
+	scan_report.RecordFailure(nil, nil, "cleanup failed")
+	next, err := scan_report.ScanCompleted()
+	if assert.NoError(t, err) && assert.NotNil(t, next) {
+		assert.NotEqual(t, report.ID, next.ID)
+		assert.Equal(t, 1, next.FailureCount)
+	}
+}
+
+func TestDeleteOldScanReports(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	scan_report.InitializeScanReports(db)
+	defer scan_report.InitializeScanReports(nil)
+
+	for i := 0; i < 55; i++ {
+		scan_report.RecordFailure(nil, nil, "failure")
+		_, err := scan_report.ScanCompleted()
+		assert.NoError(t, err)
+	}
+
+	var reportCount, failureCount int64
+	assert.NoError(t, db.Model(&models.ScanReport{}).Count(&reportCount).Error)
+	assert.NoError(t, db.Model(&models.ScanFailure{}).Count(&failureCount).Error)
+	assert.EqualValues(t, 50, reportCount)
+	assert.EqualValues(t, 50, failureCount)
+}
@@ -1,28 +1,34 @@
 package scanner
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"path"
+	"path/filepath"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner/media_encoding"
+	"github.com/photoview/photoview/api/scanner/scan_profile"
+	"github.com/photoview/photoview/api/scanner/scan_progress"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
 	"github.com/photoview/photoview/api/scanner/scanner_tasks"
 	"github.com/photoview/photoview/api/scanner/scanner_utils"
+	"github.com/photoview/photoview/api/tracing"
 	"github.com/photoview/photoview/api/utils"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
 )
 
+// NewRootAlbum adds a root path to the user. If another user already has an album of the directory,
+// even if it is reached through a different path such as a symlink, that album is shared with the user instead,
+// so the media in it are only scanned and cached once. A new root album within the directory of an existing album
+// is added below it, so it is found by scans of the existing album instead of becoming a second album of the directory.
 func NewRootAlbum(db *gorm.DB, rootPath string, owner *models.User) (*models.Album, error) {
 
-	if !ValidRootPath(rootPath) {
-		return nil, ErrorInvalidRootPath
-	}
-
 	if !path.IsAbs(rootPath) {
 		wd, err := os.Getwd()
 		if err != nil {
@@ -32,18 +38,26 @@ func NewRootAlbum(db *gorm.DB, rootPath string, owner *models.User) (*models.Alb
 		rootPath = path.Join(wd, rootPath)
 	}
 
+	// Root paths within a subtree mapped to a storage backend, are read from the backend
+	rootPath, err := models.ResolveStoragePath(db, rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ValidRootPath(rootPath) {
+		return nil, ErrorInvalidRootPath
+	}
+
 	owners := []models.User{
 		*owner,
 	}
 
-	var matchedAlbums []models.Album
-	if err := db.Where("path_hash = ?", models.MD5Hash(rootPath)).Find(&matchedAlbums).Error; err != nil {
+	album, err := findAlbumOfDirectory(db, rootPath)
+	if err != nil {
 		return nil, err
 	}
 
-	if len(matchedAlbums) > 0 {
-		album := matchedAlbums[0]
-
+	if album != nil {
 		var matchedUserAlbumCount int64
 		if err := db.Table("user_albums").Where("user_id = ?", owner.ID).Where("album_id = ?", album.ID).Count(&matchedUserAlbumCount).Error; err != nil {
 			return nil, err
@@ -53,11 +67,11 @@ func NewRootAlbum(db *gorm.DB, rootPath string, owner *models.User) (*models.Alb
 			return nil, errors.New(fmt.Sprintf("user already owns a path containing this path: %s", rootPath))
 		}
 
-		if err := db.Model(&owner).Association("Albums").Append(&album); err != nil {
+		if err := db.Model(&owner).Association("Albums").Append(album); err != nil {
 			return nil, errors.Wrap(err, "add owner to already existing album")
 		}
 
-		return &album, nil
+		return album, nil
 	} else {
 		album := models.Album{
 			Title:  path.Base(rootPath),
@@ -65,6 +79,21 @@ func NewRootAlbum(db *gorm.DB, rootPath string, owner *models.User) (*models.Alb
 			Owners: owners,
 		}
 
+		var parentAlbums []models.Album
+		if err := db.Where("path_hash = ?", models.MD5Hash(path.Dir(rootPath))).Find(&parentAlbums).Error; err != nil {
+			return nil, err
+		}
+
+		if len(parentAlbums) > 0 && parentAlbums[0].Path != rootPath {
+			album.ParentAlbumID = &parentAlbums[0].ID
+
+			var parentOwners []models.User
+			if err := db.Model(&parentAlbums[0]).Association("Owners").Find(&parentOwners, "user_albums.user_id <> ?", owner.ID); err != nil {
+				return nil, errors.Wrap(err, "get owners of parent album")
+			}
+			album.Owners = append(album.Owners, parentOwners...)
+		}
+
 		if err := db.Create(&album).Error; err != nil {
 			return nil, err
 		}
@@ -73,19 +102,64 @@ func NewRootAlbum(db *gorm.DB, rootPath string, owner *models.User) (*models.Alb
 	}
 }
 
+// findAlbumOfDirectory returns the album of the directory at the path, or nil if it has none.
+// Albums reached through a different path, such as a symlink, are found by the path with symlinks resolved,
+// or by the identity of the directory for root albums.
+func findAlbumOfDirectory(db *gorm.DB, dirPath string) (*models.Album, error) {
+	paths := []string{dirPath}
+	if resolvedPath, err := filepath.EvalSymlinks(dirPath); err == nil && resolvedPath != dirPath {
+		paths = append(paths, resolvedPath)
+	}
+
+	for _, albumPath := range paths {
+		var matchedAlbums []*models.Album
+		if err := db.Where("path_hash = ?", models.MD5Hash(albumPath)).Find(&matchedAlbums).Error; err != nil {
+			return nil, err
+		}
+
+		if len(matchedAlbums) > 0 {
+			return matchedAlbums[0], nil
+		}
+	}
+
+	dirID, err := getDirectoryID(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var rootAlbums []*models.Album
+	if err := db.Where("parent_album_id IS NULL").Find(&rootAlbums).Error; err != nil {
+		return nil, errors.Wrap(err, "get root albums from database")
+	}
+
+	for _, album := range rootAlbums {
+		// Root albums that cannot be read are no match, they are reported by the scans of their owners
+		if albumDirID, err := getDirectoryID(album.Path); err == nil && albumDirID == dirID {
+			return album, nil
+		}
+	}
+
+	return nil, nil
+}
+
 var ErrorInvalidRootPath = errors.New("invalid root path")
 
 func ValidRootPath(rootPath string) bool {
 	_, err := os.Stat(rootPath)
 	if err != nil {
-		log.Printf("Warn: invalid root path: '%s'\n%s\n", rootPath, err)
+		log.Warn(context.Background(), "Invalid root path", "path", rootPath, "error", err)
 		return false
 	}
 
 	return true
 }
 
-func ScanAlbum(ctx scanner_task.TaskContext) error {
+func ScanAlbum(ctx scanner_task.TaskContext) (err error) {
+	ctx, span := ctx.StartSpan("scanner.scan_album",
+		attribute.Int("album.id", ctx.GetAlbum().ID),
+		attribute.String("album.path", ctx.GetAlbum().Path))
+	defer func() { tracing.EndSpan(span, err) }()
+
 	newCtx, err := scanner_tasks.Tasks.BeforeScanAlbum(ctx)
 	if err != nil {
 		return errors.Wrapf(err, "before scan album (%s)", ctx.GetAlbum().Path)
@@ -93,18 +167,18 @@ func ScanAlbum(ctx scanner_task.TaskContext) error {
 	ctx = newCtx
 
 	// Scan for photos
-	albumMedia, err := findMediaForAlbum(ctx)
+	findCtx, findSpan := ctx.StartSpan("scanner.find_media")
+	albumMedia, seenPaths, err := findMediaForAlbum(findCtx)
+	findSpan.SetAttributes(attribute.Int("media.count", len(albumMedia)))
+	tracing.EndSpan(findSpan, err)
 	if err != nil {
 		return errors.Wrapf(err, "find media for album (%s): %s", ctx.GetAlbum().Path, err)
 	}
+	ctx = ctx.WithSeenMediaPaths(seenPaths)
 
-	changedMedia := make([]*models.Media, 0)
-	for i, media := range albumMedia {
-		mediaData := media_encoding.NewEncodeMediaData(media)
-
-		if err := scanMedia(ctx, media, &mediaData, i, len(albumMedia)); err != nil {
-			scanner_utils.ScannerError("Error scanning media for album (%d) file (%s): %s\n", ctx.GetAlbum().ID, media.Path, err)
-		}
+	changedMedia := processAlbumMedia(ctx, albumMedia)
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "scan cancelled")
 	}
 
 	if err := scanner_tasks.Tasks.AfterScanAlbum(ctx, changedMedia, albumMedia); err != nil {
@@ -114,33 +188,47 @@ func ScanAlbum(ctx scanner_task.TaskContext) error {
 	return nil
 }
 
-func findMediaForAlbum(ctx scanner_task.TaskContext) ([]*models.Media, error) {
+// findMediaForAlbum saves the media files in the directory of the album to the database, and returns their media
+// along with the paths of all media files found, including those that failed to be scanned
+func findMediaForAlbum(ctx scanner_task.TaskContext) ([]*models.Media, map[string]bool, error) {
 
 	albumMedia := make([]*models.Media, 0)
+	seenPaths := make(map[string]bool)
 
+	endWalk := scan_profile.Start(scan_profile.StageDirectoryWalk)
 	dirContent, err := ioutil.ReadDir(ctx.GetAlbum().Path)
+	endWalk()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for _, item := range dirContent {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
 		mediaPath := path.Join(ctx.GetAlbum().Path, item.Name())
 
 		isDirSymlink, err := utils.IsDirSymlink(mediaPath)
 		if err != nil {
-			log.Printf("Cannot detect whether %s is symlink to a directory. Pretending it is not", mediaPath)
+			log.Warn(ctx, "Cannot detect whether file is symlink to a directory. Pretending it is not", "path", mediaPath, "error", err)
 			isDirSymlink = false
 		}
 
 		if !item.IsDir() && !isDirSymlink && ctx.GetCache().IsPathMedia(mediaPath) {
 			skip, err := scanner_tasks.Tasks.MediaFound(ctx, item, mediaPath)
 			if err != nil {
-				return nil, err
+				// The media of the file is kept, as it may not have to be skipped
+				seenPaths[mediaPath] = true
+				scanner_utils.ScannerMediaError(ctx, mediaPath, "Error checking media for album (%d): %s\n", ctx.GetAlbum().ID, err)
+				continue
 			}
 			if skip {
 				continue
 			}
+			seenPaths[mediaPath] = true
 
+			mediaAdded := false
 			err = ctx.DatabaseTransaction(func(ctx scanner_task.TaskContext) error {
 				media, isNewMedia, err := ScanMedia(ctx.GetDB(), mediaPath, ctx.GetAlbum().ID, ctx.GetCache())
 				if err != nil {
@@ -150,6 +238,7 @@ func findMediaForAlbum(ctx scanner_task.TaskContext) ([]*models.Media, error) {
 				if err = scanner_tasks.Tasks.AfterMediaFound(ctx, media, isNewMedia); err != nil {
 					return err
 				}
+				mediaAdded = isNewMedia
 
 				albumMedia = append(albumMedia, media)
 
@@ -157,14 +246,19 @@ func findMediaForAlbum(ctx scanner_task.TaskContext) ([]*models.Media, error) {
 			})
 
 			if err != nil {
-				scanner_utils.ScannerError("Error scanning media for album (%d): %s\n", ctx.GetAlbum().ID, err)
+				scanner_utils.ScannerMediaError(ctx, mediaPath, "Error scanning media for album (%d): %s\n", ctx.GetAlbum().ID, err)
+				recordRetry(ctx, mediaPath, err)
 				continue
 			}
+
+			if mediaAdded {
+				scan_progress.MediaAdded()
+			}
 		}
 
 	}
 
-	return albumMedia, nil
+	return albumMedia, seenPaths, nil
 }
 
 func processMedia(ctx scanner_task.TaskContext, mediaData *media_encoding.EncodeMediaData) ([]*models.MediaURL, error) {
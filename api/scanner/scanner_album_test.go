@@ -1,10 +1,13 @@
 package scanner_test
 
 import (
+	"os"
+	"path"
 	"testing"
 
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/scanner/scanner_cache"
 	"github.com/photoview/photoview/api/test_utils"
 	"github.com/stretchr/testify/assert"
 )
@@ -70,3 +73,86 @@ func TestNewRootPath(t *testing.T) {
 	})
 
 }
+
+func TestNewRootPathOverlapping(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	photo, err := os.ReadFile("./test_data/lilac_lilac_bush_lilac.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootPath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(path.Join(rootPath, "photos", "trip"), 0755))
+	assert.NoError(t, os.WriteFile(path.Join(rootPath, "photos", "trip", "photo.jpg"), photo, 0644))
+	assert.NoError(t, os.Symlink(path.Join(rootPath, "photos"), path.Join(rootPath, "link")))
+
+	users := []*models.User{{Username: "user1"}, {Username: "user2"}, {Username: "user3"}}
+	for _, user := range users {
+		if !assert.NoError(t, db.Save(user).Error) {
+			return
+		}
+	}
+
+	// The root of user2 is added before the root containing it is scanned
+	trip, err := scanner.NewRootAlbum(db, path.Join(rootPath, "photos", "trip"), users[1])
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	photos, err := scanner.NewRootAlbum(db, path.Join(rootPath, "photos"), users[0])
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("Root reached through a symlink shares the album", func(t *testing.T) {
+		album, err := scanner.NewRootAlbum(db, path.Join(rootPath, "link"), users[2])
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, photos.ID, album.ID)
+		assert.EqualValues(t, 2, db.Model(album).Association("Owners").Count())
+	})
+
+	t.Run("Root within another root is shared and kept as a root of its owner", func(t *testing.T) {
+		_, errs := scanner.FindAlbumsForUser(db, users[0], scanner_cache.MakeAlbumCache())
+		if !assert.Empty(t, errs) {
+			return
+		}
+
+		var albumCount int64
+		assert.NoError(t, db.Model(&models.Album{}).Where("path LIKE ?", "%/trip").Count(&albumCount).Error)
+		assert.EqualValues(t, 1, albumCount)
+
+		var updatedTrip models.Album
+		if !assert.NoError(t, db.First(&updatedTrip, trip.ID).Error) {
+			return
+		}
+		if assert.NotNil(t, updatedTrip.ParentAlbumID) {
+			assert.Equal(t, photos.ID, *updatedTrip.ParentAlbumID)
+		}
+		assert.EqualValues(t, 2, db.Model(&updatedTrip).Association("Owners").Count())
+
+		albums, errs := scanner.FindAlbumsForUser(db, users[1], scanner_cache.MakeAlbumCache())
+		assert.Empty(t, errs)
+		if assert.Len(t, albums, 1) {
+			assert.Equal(t, trip.ID, albums[0].ID)
+		}
+	})
+
+	t.Run("Root added within an existing album is added below it", func(t *testing.T) {
+		assert.NoError(t, os.MkdirAll(path.Join(rootPath, "photos", "new"), 0755))
+		assert.NoError(t, os.WriteFile(path.Join(rootPath, "photos", "new", "photo.jpg"), photo, 0644))
+
+		album, err := scanner.NewRootAlbum(db, path.Join(rootPath, "photos", "new"), users[1])
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		if assert.NotNil(t, album.ParentAlbumID) {
+			assert.Equal(t, photos.ID, *album.ParentAlbumID)
+		}
+		assert.EqualValues(t, 3, db.Model(album).Association("Owners").Count())
+	})
+}
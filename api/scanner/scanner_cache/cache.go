@@ -1,13 +1,19 @@
 package scanner_cache
 
 import (
-	"log"
+	"context"
 	"os"
 	"path"
+	"strconv"
 	"sync"
 
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_encoding/media_utils"
 	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/scanner/path_exclusion"
+	"github.com/photoview/photoview/api/scanner/scan_profile"
 	"github.com/photoview/photoview/api/scanner/scanner_utils"
+	"github.com/photoview/photoview/api/utils"
 	"github.com/pkg/errors"
 )
 
@@ -15,6 +21,7 @@ type AlbumScannerCache struct {
 	path_contains_photos map[string]bool
 	photo_types          map[string]media_type.MediaType
 	ignore_data          map[string][]string
+	exclude_patterns     *path_exclusion.Patterns
 	mutex                sync.Mutex
 }
 
@@ -79,7 +86,9 @@ func (c *AlbumScannerCache) GetMediaType(path string) (*media_type.MediaType, er
 		return &result, nil
 	}
 
+	endDetection := scan_profile.Start(scan_profile.StageTypeDetection)
 	mediaType, err := media_type.GetMediaType(path)
+	endDetection()
 	if err != nil {
 		return nil, errors.Wrapf(err, "get media type (%s)", path)
 	}
@@ -110,10 +119,32 @@ func (c *AlbumScannerCache) InsertAlbumIgnore(path string, ignore_data []string)
 	c.ignore_data[path] = ignore_data
 }
 
+// SetExcludePatterns sets the patterns of the files and directories excluded from the scan
+func (c *AlbumScannerCache) SetExcludePatterns(patterns *path_exclusion.Patterns) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.exclude_patterns = patterns
+}
+
+// IsPathExcluded returns whether the file or directory at the path matches the exclusion patterns of the scan
+func (c *AlbumScannerCache) IsPathExcluded(path string) bool {
+	c.mutex.Lock()
+	patterns := c.exclude_patterns
+	c.mutex.Unlock()
+
+	return patterns.Matches(path)
+}
+
 func (c *AlbumScannerCache) IsPathMedia(mediaPath string) bool {
+	if c.IsPathExcluded(mediaPath) {
+		log.Debug(context.Background(), "File is excluded", "path", mediaPath)
+		return false
+	}
+
 	mediaType, err := c.GetMediaType(mediaPath)
 	if err != nil {
-		scanner_utils.ScannerError("IsPathMedia (%s): %s", mediaPath, err)
+		scanner_utils.ScannerMediaError(context.Background(), mediaPath, "IsPathMedia (%s): %s", mediaPath, err)
 		return false
 	}
 
@@ -129,9 +160,40 @@ func (c *AlbumScannerCache) IsPathMedia(mediaPath string) bool {
 			return false
 		}
 
+		if isBelowMinimumSize(mediaPath, mediaType, fileStats.Size()) {
+			log.Debug(context.Background(), "File is smaller than the minimum size of media", "path", mediaPath)
+			return false
+		}
+
 		return true
 	}
 
-	log.Printf("File is not a supported media %s\n", mediaPath)
+	log.Debug(context.Background(), "File is not a supported media", "path", mediaPath)
 	return false
 }
+
+// isBelowMinimumSize returns whether an image is smaller than the minimum file size set by PHOTOVIEW_SCANNER_MIN_FILE_SIZE,
+// or has a largest side shorter than the minimum dimension set by PHOTOVIEW_SCANNER_MIN_IMAGE_DIMENSION,
+// such as icons and tracking pixels. Videos are never filtered, nor are images whose dimensions can't be read, such as raw photos.
+func isBelowMinimumSize(mediaPath string, mediaType *media_type.MediaType, fileSize int64) bool {
+	if mediaType.IsVideo() {
+		return false
+	}
+
+	minFileSize, _ := strconv.ParseInt(utils.EnvScannerMinFileSize.GetValue(), 10, 64)
+	if minFileSize > 0 && fileSize < minFileSize {
+		return true
+	}
+
+	minDimension, _ := strconv.Atoi(utils.EnvScannerMinImageDimension.GetValue())
+	if minDimension <= 0 || mediaType.IsRaw() {
+		return false
+	}
+
+	dimensions, err := media_utils.GetPhotoDimensions(mediaPath)
+	if err != nil {
+		return false
+	}
+
+	return dimensions.Width < minDimension && dimensions.Height < minDimension
+}
@@ -0,0 +1,76 @@
+package scanner_cache_test
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/photoview/photoview/api/scanner/scanner_cache"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.UnitTestRun(m))
+}
+
+func TestIsPathMediaMinimumSize(t *testing.T) {
+	dir := t.TempDir()
+
+	writeImage := func(name string, width, height int) string {
+		imagePath := path.Join(dir, name)
+		file, err := os.Create(imagePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		if err := png.Encode(file, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+			t.Fatal(err)
+		}
+
+		return imagePath
+	}
+
+	icon := writeImage("icon.png", 16, 16)
+	panorama := writeImage("panorama.png", 400, 20)
+	photo := writeImage("photo.png", 300, 300)
+
+	t.Run("No minimum", func(t *testing.T) {
+		cache := scanner_cache.MakeAlbumCache()
+		assert.True(t, cache.IsPathMedia(icon))
+		assert.True(t, cache.IsPathMedia(photo))
+	})
+
+	t.Run("Minimum dimension", func(t *testing.T) {
+		minDimension := "256"
+		utils.EnvScannerMinImageDimension.SetOverride(&minDimension)
+		defer utils.EnvScannerMinImageDimension.SetOverride(nil)
+
+		cache := scanner_cache.MakeAlbumCache()
+		assert.False(t, cache.IsPathMedia(icon))
+		assert.True(t, cache.IsPathMedia(panorama), "images are kept if one of their sides is long enough")
+		assert.True(t, cache.IsPathMedia(photo))
+	})
+
+	t.Run("Minimum file size", func(t *testing.T) {
+		photoInfo, err := os.Stat(photo)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		minFileSize := "200"
+		if photoInfo.Size() <= 200 {
+			t.Fatalf("expected test photo to be larger than the minimum file size, it is %d bytes", photoInfo.Size())
+		}
+		utils.EnvScannerMinFileSize.SetOverride(&minFileSize)
+		defer utils.EnvScannerMinFileSize.SetOverride(nil)
+
+		cache := scanner_cache.MakeAlbumCache()
+		assert.False(t, cache.IsPathMedia(icon))
+		assert.True(t, cache.IsPathMedia(photo))
+	})
+}
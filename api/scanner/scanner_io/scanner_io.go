@@ -0,0 +1,206 @@
+// Package scanner_io limits the disk usage of the scanner, so a scan does not saturate slow disks such as those of a NAS,
+// by the number of files read at the same time, the number of thumbnails generated at the same time,
+// and the number of bytes read per second.
+//
+// Only files read by the scanner itself are limited, files read by external tools such as exiftool, ffmpeg and darktable are not.
+package scanner_io
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+)
+
+// Largest read of a throttled file, so reads are spread evenly over time
+const throttledReadSize = 64 * 1024
+
+var (
+	fileReads     = &limiter{variable: utils.EnvScannerMaxFileReads}
+	thumbnailJobs = &limiter{variable: utils.EnvScannerMaxThumbnailJobs}
+	readRate      = &rateLimiter{}
+)
+
+// File is a file opened for reading by the scanner. It holds a file read until it is closed, and its reads are throttled.
+type File struct {
+	file    *os.File
+	ctx     context.Context
+	release func()
+}
+
+// Open opens a file for reading, once fewer files than the maximum number of file reads are open
+func Open(ctx context.Context, filePath string) (*File, error) {
+	release, err := fileReads.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	return &File{file: file, ctx: ctx, release: release}, nil
+}
+
+// Read reads from the file, waiting as long as needed to stay within the read limit
+func (f *File) Read(p []byte) (int, error) {
+	if readLimit() > 0 && len(p) > throttledReadSize {
+		p = p[:throttledReadSize]
+	}
+
+	n, err := f.file.Read(p)
+	if waitErr := readRate.wait(f.ctx, n); waitErr != nil && err == nil {
+		return n, waitErr
+	}
+
+	return n, err
+}
+
+// ReadAt reads from the file at an offset, waiting as long as needed to stay within the read limit
+func (f *File) ReadAt(p []byte, offset int64) (int, error) {
+	n, err := f.file.ReadAt(p, offset)
+	if waitErr := readRate.wait(f.ctx, n); waitErr != nil && err == nil {
+		return n, waitErr
+	}
+
+	return n, err
+}
+
+// Seek sets the offset of the next read from the file
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	return f.file.Seek(offset, whence)
+}
+
+// Close closes the file and frees its file read
+func (f *File) Close() error {
+	f.release()
+	return f.file.Close()
+}
+
+// AcquireThumbnailJob waits until fewer thumbnails than the maximum number of thumbnail jobs are being generated.
+// The returned function must be called once the thumbnail has been generated.
+func AcquireThumbnailJob(ctx context.Context) (release func(), err error) {
+	return thumbnailJobs.acquire(ctx)
+}
+
+// limiter limits the number of operations at the same time, to the value of an environment variable.
+// The value is read whenever an operation starts, so it can be changed while the server is running.
+type limiter struct {
+	variable utils.EnvironmentVariable
+	mutex    sync.Mutex
+	active   int
+	// released is closed when an operation has finished, to wake up the operations waiting for it
+	released chan struct{}
+}
+
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	for {
+		l.mutex.Lock()
+		if l.released == nil {
+			l.released = make(chan struct{})
+		}
+
+		limit := limitValue(l.variable)
+		if limit <= 0 || l.active < limit {
+			l.active++
+			l.mutex.Unlock()
+
+			once := sync.Once{}
+			return func() { once.Do(l.release) }, nil
+		}
+
+		released := l.released
+		l.mutex.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *limiter) release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.active--
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// rateLimiter spreads the bytes read by the scanner over time, to stay within the read limit.
+// Every read reserves the time it takes at the limit, and waits until the reads before it have had theirs.
+type rateLimiter struct {
+	mutex sync.Mutex
+	// next is when the time reserved by the previous reads ends
+	next time.Time
+}
+
+func (r *rateLimiter) wait(ctx context.Context, bytes int) error {
+	limit := readLimit()
+	if limit <= 0 || bytes <= 0 {
+		return nil
+	}
+
+	r.mutex.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	delay := r.next.Sub(now)
+	r.next = r.next.Add(time.Duration(float64(bytes) / float64(limit) * float64(time.Second)))
+	r.mutex.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readLimit returns the bytes per second the scanner may read, set by PHOTOVIEW_SCANNER_READ_LIMIT, or 0 if unlimited
+func readLimit() int {
+	return limitValue(utils.EnvScannerReadLimit)
+}
+
+var (
+	invalidLimitsLock = &sync.Mutex{}
+	// invalidLimits are the invalid values that have been warned about, as limits are read on every file read
+	invalidLimits = make(map[utils.EnvironmentVariable]string)
+)
+
+// limitValue returns the value of a limit set by an environment variable, or 0 if it is not set or invalid
+func limitValue(variable utils.EnvironmentVariable) int {
+	value := variable.GetValue()
+	if value == "" {
+		return 0
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		invalidLimitsLock.Lock()
+		if invalidLimits[variable] != value {
+			invalidLimits[variable] = value
+			log.Warn(context.Background(), "Invalid scanner limit, ignoring it", "env", variable.GetName(), "value", value)
+		}
+		invalidLimitsLock.Unlock()
+		return 0
+	}
+
+	return limit
+}
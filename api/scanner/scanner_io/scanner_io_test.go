@@ -0,0 +1,109 @@
+package scanner_io_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/scanner/scanner_io"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.UnitTestRun(m))
+}
+
+func TestMaxFileReads(t *testing.T) {
+	limit := "1"
+	utils.EnvScannerMaxFileReads.SetOverride(&limit)
+	defer utils.EnvScannerMaxFileReads.SetOverride(nil)
+
+	filePath := path.Join(t.TempDir(), "photo.jpg")
+	assert.NoError(t, os.WriteFile(filePath, []byte("photo"), 0644))
+
+	first, err := scanner_io.Open(context.Background(), filePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = scanner_io.Open(ctx, filePath)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "a second file is not opened while the first is open")
+
+	opened := make(chan error)
+	go func() {
+		second, err := scanner_io.Open(context.Background(), filePath)
+		if err == nil {
+			second.Close()
+		}
+		opened <- err
+	}()
+
+	assert.NoError(t, first.Close())
+
+	select {
+	case err := <-opened:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("second file is not opened after the first has been closed")
+	}
+}
+
+func TestMaxThumbnailJobs(t *testing.T) {
+	limit := "2"
+	utils.EnvScannerMaxThumbnailJobs.SetOverride(&limit)
+	defer utils.EnvScannerMaxThumbnailJobs.SetOverride(nil)
+
+	releaseFirst, err := scanner_io.AcquireThumbnailJob(context.Background())
+	assert.NoError(t, err)
+	releaseSecond, err := scanner_io.AcquireThumbnailJob(context.Background())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = scanner_io.AcquireThumbnailJob(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	releaseFirst()
+	releaseFirst()
+
+	releaseThird, err := scanner_io.AcquireThumbnailJob(context.Background())
+	assert.NoError(t, err)
+
+	_, err = scanner_io.AcquireThumbnailJob(ctx)
+	assert.Error(t, err, "releasing a job twice only frees a single job")
+
+	releaseSecond()
+	releaseThird()
+}
+
+func TestReadLimit(t *testing.T) {
+	limit := "100000"
+	utils.EnvScannerReadLimit.SetOverride(&limit)
+	defer utils.EnvScannerReadLimit.SetOverride(nil)
+
+	filePath := path.Join(t.TempDir(), "photo.jpg")
+	assert.NoError(t, os.WriteFile(filePath, make([]byte, 30000), 0644))
+
+	file, err := scanner_io.Open(context.Background(), filePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer file.Close()
+
+	start := time.Now()
+	content, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	assert.Len(t, content, 30000)
+
+	// The first read is not delayed, the reads after it wait for the bytes read before them
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
@@ -2,14 +2,16 @@ package scanner
 
 import (
 	"context"
-	"log"
 	"os"
 	"path"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner/media_encoding"
 	"github.com/photoview/photoview/api/scanner/scanner_cache"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/scanner/scanner_tasks/processing_tasks"
+	"github.com/photoview/photoview/api/scanner/scanner_utils"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
@@ -33,7 +35,7 @@ func ScanMedia(tx *gorm.DB, mediaPath string, albumId int, cache *scanner_cache.
 		}
 	}
 
-	log.Printf("Scanning media: %s\n", mediaPath)
+	log.Info(tx.Statement.Context, "Scanning media", "path", mediaPath)
 
 	mediaType, err := cache.GetMediaType(mediaPath)
 	if err != nil {
@@ -53,6 +55,26 @@ func ScanMedia(tx *gorm.DB, mediaPath string, albumId int, cache *scanner_cache.
 		return nil, false, err
 	}
 
+	movedMedia, err := findMovedMedia(tx, mediaPath, stat.Size(), mediaType.IsRaw())
+	if err != nil {
+		return nil, false, err
+	}
+
+	if movedMedia != nil {
+		log.Info(tx.Statement.Context, "Media has been moved", "from", movedMedia.Path, "to", mediaPath)
+
+		movedMedia.Title = mediaName
+		movedMedia.Path = mediaPath
+		movedMedia.AlbumID = albumId
+		movedMedia.MissingSince = nil
+
+		if err := tx.Save(movedMedia).Error; err != nil {
+			return nil, false, errors.Wrap(err, "could not update moved media in database")
+		}
+
+		return movedMedia, false, nil
+	}
+
 	media := models.Media{
 		Title:    mediaName,
 		Path:     mediaPath,
@@ -68,6 +90,45 @@ func ScanMedia(tx *gorm.DB, mediaPath string, albumId int, cache *scanner_cache.
 	return &media, true, nil
 }
 
+// findMovedMedia returns the media whose file has been moved or renamed to the given path, or nil if there is none.
+// It is a media of the same content whose file is missing from where it was, the file is only hashed
+// if there is such a media of the same size.
+func findMovedMedia(tx *gorm.DB, mediaPath string, fileSize int64, isRaw bool) (*models.Media, error) {
+	var candidates []*models.Media
+	err := tx.Where("file_size = ? AND content_hash IS NOT NULL", fileSize).Find(&candidates).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "find moved media in database")
+	}
+
+	missing := make([]*models.Media, 0, len(candidates))
+	for _, candidate := range candidates {
+		// Media of files that have not been scanned since they were moved are not marked missing yet
+		if candidate.MissingSince != nil || !scanner_utils.FileExists(candidate.Path) {
+			missing = append(missing, candidate)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	contentHash, sideCarPath, err := processing_tasks.HashMediaFile(tx.Statement.Context, mediaPath, isRaw)
+	if err != nil {
+		// The file is scanned as new media instead
+		log.Warn(tx.Statement.Context, "Hashing content of media to find whether it has been moved", "path", mediaPath, "error", err)
+		return nil, nil
+	}
+
+	for _, candidate := range missing {
+		if *candidate.ContentHash == contentHash {
+			candidate.SideCarPath = sideCarPath
+			return candidate, nil
+		}
+	}
+
+	return nil, nil
+}
+
 // ProcessSingleMedia processes a single media, might be used to reprocess media with corrupted cache
 // Function waits for processing to finish before returning.
 func ProcessSingleMedia(db *gorm.DB, media *models.Media) error {
@@ -81,7 +142,7 @@ func ProcessSingleMedia(db *gorm.DB, media *models.Media) error {
 	media_data := media_encoding.NewEncodeMediaData(media)
 
 	task_context := scanner_task.NewTaskContext(context.Background(), db, &album, album_cache)
-	if err := scanMedia(task_context, media, &media_data, 0, 1); err != nil {
+	if _, err := scanMedia(task_context, media, &media_data, 0, 1); err != nil {
 		return errors.Wrap(err, "single media scan")
 	}
 
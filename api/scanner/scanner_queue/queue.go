@@ -3,17 +3,25 @@ package scanner_queue
 import (
 	"context"
 	"fmt"
-	"log"
 	"sync"
 	"time"
 
+	"github.com/photoview/photoview/api/cluster"
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/graphql/notification"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/notifier"
 	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/scanner/media_retry"
+	"github.com/photoview/photoview/api/scanner/scan_history"
+	"github.com/photoview/photoview/api/scanner/scan_progress"
+	"github.com/photoview/photoview/api/scanner/scan_report"
 	"github.com/photoview/photoview/api/scanner/scanner_cache"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/scanner/scanner_tasks/cleanup_tasks"
 	"github.com/photoview/photoview/api/scanner/scanner_utils"
 	"github.com/photoview/photoview/api/utils"
+	"github.com/photoview/photoview/api/webhooks"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
@@ -23,18 +31,22 @@ type ScannerJob struct {
 	ctx scanner_task.TaskContext
 	// album *models.Album
 	// cache *scanner_cache.AlbumScannerCache
+	// order is what the job is ordered by among the jobs waiting on the queue
+	order jobOrder
+	// user is the user whose scan queued the job, nil if the album was queued by itself
+	user *models.User
 }
 
 func NewScannerJob(ctx scanner_task.TaskContext) ScannerJob {
 	return ScannerJob{
-		ctx,
+		ctx: ctx,
 	}
 }
 
 func (job *ScannerJob) Run(db *gorm.DB) {
 	err := scanner.ScanAlbum(job.ctx)
-	if err != nil {
-		scanner_utils.ScannerError("Failed to scan album: %v", err)
+	if err != nil && job.ctx.Err() == nil {
+		scanner_utils.ScannerError(job.ctx, "Failed to scan album: %v", err)
 	}
 }
 
@@ -51,10 +63,20 @@ type ScannerQueue struct {
 	settings    ScannerQueueSettings
 	close_chan  *chan bool
 	running     bool
+	// paused queues stop starting new jobs, such as during maintenance
+	paused bool
+	// cancel_funcs stop the jobs on the queue, by the id of the album they scan
+	cancel_funcs map[int]context.CancelFunc
 }
 
 var global_scanner_queue ScannerQueue
 
+// scannerLease is held while this instance is scanning, when multiple instances share the database
+var scannerLease = cluster.NewLease("scanner", time.Minute)
+
+// How often an instance waiting for another instance to finish scanning checks whether it has finished
+const scannerLeaseRetryInterval = 30 * time.Second
+
 func InitializeScannerQueue(db *gorm.DB) error {
 
 	var concurrentWorkers int
@@ -66,7 +88,7 @@ func InitializeScannerQueue(db *gorm.DB) error {
 		concurrentWorkers = site_info.ConcurrentWorkers
 	}
 
-	log.Printf("Initializing scanner queue with %d workers", concurrentWorkers)
+	log.Info(context.Background(), "Initializing scanner queue", "workers", concurrentWorkers)
 
 	global_scanner_queue = ScannerQueue{
 		idle_chan:   make(chan bool, 1),
@@ -91,7 +113,7 @@ func ChangeScannerConcurrentWorkers(newMaxWorkers int) {
 	global_scanner_queue.mutex.Lock()
 	defer global_scanner_queue.mutex.Unlock()
 
-	log.Printf("Scanner max concurrent workers changed to: %d", newMaxWorkers)
+	log.Info(context.Background(), "Scanner max concurrent workers changed", "workers", newMaxWorkers)
 	global_scanner_queue.settings.max_concurrent_tasks = newMaxWorkers
 }
 
@@ -100,7 +122,7 @@ func (queue *ScannerQueue) startBackgroundWorker() {
 	notifyThrottle := utils.NewThrottle(500 * time.Millisecond)
 
 	for {
-		log.Println("Queue waiting")
+		log.Debug(context.Background(), "Queue waiting")
 		<-queue.idle_chan
 
 		queue.mutex.Lock()
@@ -109,6 +131,14 @@ func (queue *ScannerQueue) startBackgroundWorker() {
 		queue.mutex.Unlock()
 
 		if should_stop {
+			deleteMissingMedia(queue.db)
+
+			// The report of a scan the queue is closed right after is completed here, as the queue is not idle again
+			if _, err := scan_report.ScanCompleted(); err != nil {
+				log.Warn(context.Background(), "Finishing scan report", "error", err)
+			}
+			completeScanProgress(queue.db)
+
 			*queue.close_chan <- true
 			break
 		}
@@ -116,7 +146,30 @@ func (queue *ScannerQueue) startBackgroundWorker() {
 		queue.processQueue(&notifyThrottle)
 	}
 
-	log.Println("Scanner background worker stopped")
+	log.Info(context.Background(), "Scanner background worker stopped")
+}
+
+// deleteMissingMedia deletes the media not found again by the scan once it has finished,
+// as their files have been deleted rather than moved
+func deleteMissingMedia(db *gorm.DB) {
+	deleted, err := cleanup_tasks.DeleteMissingMedia(db)
+	if err != nil {
+		scanner_utils.ScannerError(context.Background(), "Failed to delete missing media: %v", err)
+	} else if deleted > 0 {
+		log.Info(context.Background(), "Deleted media of removed files", "count", deleted)
+	}
+}
+
+// completeScanProgress finishes the progress of the scan, and saves its statistics to the scan history
+func completeScanProgress(db *gorm.DB) {
+	progress := scan_progress.Completed()
+	if progress == nil {
+		return
+	}
+
+	if _, err := scan_history.Save(db, progress); err != nil {
+		log.Warn(context.Background(), "Saving scan statistics", "error", err)
+	}
 }
 
 func (queue *ScannerQueue) CloseBackgroundWorker() {
@@ -127,28 +180,38 @@ func (queue *ScannerQueue) CloseBackgroundWorker() {
 
 	queue.notify()
 
-	log.Println("Waiting for scanner background worker to finish all jobs...")
+	log.Info(context.Background(), "Waiting for scanner background worker to finish all jobs...")
 	<-close_chan
 }
 
 func (queue *ScannerQueue) processQueue(notifyThrottle *utils.Throttle) {
-	log.Println("Queue waiting for lock")
+	// Only one instance sharing the database scans at a time, the others keep their jobs until the lease is free
+	leased := queue.acquireScannerLease()
+
+	log.Debug(context.Background(), "Queue waiting for lock")
 	queue.mutex.Lock()
-	log.Printf("Queue running: in_progress: %d, max_tasks: %d, queue_len: %d\n", len(queue.in_progress), queue.settings.max_concurrent_tasks, len(queue.up_next))
+	log.Debug(context.Background(), "Queue running",
+		"in_progress", len(queue.in_progress),
+		"max_tasks", queue.settings.max_concurrent_tasks,
+		"queue_len", len(queue.up_next))
 
-	for len(queue.in_progress) < queue.settings.max_concurrent_tasks && len(queue.up_next) > 0 {
-		log.Println("Queue starting job")
+	for leased && !queue.paused && len(queue.in_progress) < queue.settings.max_concurrent_tasks && len(queue.up_next) > 0 {
 		nextJob := queue.up_next[0]
 		queue.up_next = queue.up_next[1:]
 		queue.in_progress = append(queue.in_progress, nextJob)
+		scan_report.ScanStarted()
+		scan_progress.AlbumStarted(nextJob.ctx.GetAlbum().Path)
 
 		go func() {
-			log.Println("Starting job")
+			log.Debug(nextJob.ctx, "Starting job")
 			nextJob.Run(queue.db)
-			log.Println("Job finished")
+			log.Debug(nextJob.ctx, "Job finished")
+			scan_progress.AlbumScanned()
 
-			// Delete finished job from queue
+			// Delete finished job from queue. Its context is not cancelled,
+			// as tasks of the job such as face detection keep running in the background after it has finished.
 			queue.mutex.Lock()
+			delete(queue.cancel_funcs, nextJob.ctx.GetAlbum().ID)
 			for i, x := range queue.in_progress {
 				if x == nextJob {
 					queue.in_progress[i] = queue.in_progress[len(queue.in_progress)-1]
@@ -164,10 +227,17 @@ func (queue *ScannerQueue) processQueue(notifyThrottle *utils.Throttle) {
 
 	in_progress_length := len(global_scanner_queue.in_progress)
 	up_next_length := len(global_scanner_queue.up_next)
+	paused := queue.paused
 
 	queue.mutex.Unlock()
 
 	if in_progress_length+up_next_length == 0 {
+		if err := scannerLease.Release(queue.db); err != nil {
+			log.Warn(context.Background(), "Releasing scanner lease", "error", err)
+		}
+
+		deleteMissingMedia(queue.db)
+
 		notification.BroadcastNotification(&models.Notification{
 			Key:      "global-scanner-progress",
 			Type:     models.NotificationTypeMessage,
@@ -177,7 +247,7 @@ func (queue *ScannerQueue) processQueue(notifyThrottle *utils.Throttle) {
 		})
 
 		if err := scanner.GenerateBlurhashes(queue.db); err != nil {
-			scanner_utils.ScannerError("Failed to generate blurhashes: %v", err)
+			scanner_utils.ScannerError(context.Background(), "Failed to generate blurhashes: %v", err)
 		}
 
 		notification.BroadcastNotification(&models.Notification{
@@ -187,6 +257,36 @@ func (queue *ScannerQueue) processQueue(notifyThrottle *utils.Throttle) {
 			Content:  "All jobs have been scanned",
 			Positive: true,
 		})
+
+		report, err := scan_report.ScanCompleted()
+		if err != nil {
+			log.Warn(context.Background(), "Finishing scan report", "error", err)
+		}
+
+		completeScanProgress(queue.db)
+		webhooks.ScanCompleted()
+		notifier.ScanCompleted(report)
+	} else if !leased && in_progress_length == 0 {
+		// Try again later, as there is no job to finish that notifies the queue
+		time.AfterFunc(scannerLeaseRetryInterval, func() { queue.notify() })
+
+		notifyThrottle.Trigger(func() {
+			notification.BroadcastNotification(&models.Notification{
+				Key:     "global-scanner-progress",
+				Type:    models.NotificationTypeMessage,
+				Header:  "Scanner waiting",
+				Content: fmt.Sprintf("%d jobs waiting until another server instance has finished scanning", up_next_length),
+			})
+		})
+	} else if paused {
+		notifyThrottle.Trigger(func() {
+			notification.BroadcastNotification(&models.Notification{
+				Key:     "global-scanner-progress",
+				Type:    models.NotificationTypeMessage,
+				Header:  "Scanner paused",
+				Content: fmt.Sprintf("%d jobs in progress\n%d jobs waiting until maintenance has finished", in_progress_length, up_next_length),
+			})
+		})
 	} else {
 		notifyThrottle.Trigger(func() {
 			notification.BroadcastNotification(&models.Notification{
@@ -199,6 +299,137 @@ func (queue *ScannerQueue) processQueue(notifyThrottle *utils.Throttle) {
 	}
 }
 
+// acquireScannerLease returns whether this instance may start scanner jobs, which is always the case while jobs are in progress
+func (queue *ScannerQueue) acquireScannerLease() bool {
+	queue.mutex.Lock()
+	hasJobs := len(queue.up_next) > 0 && !queue.paused
+	queue.mutex.Unlock()
+
+	if !hasJobs || scannerLease.Held() {
+		return true
+	}
+
+	acquired, err := scannerLease.Acquire(queue.db)
+	if err != nil {
+		log.Error(context.Background(), "Acquiring scanner lease", "error", err)
+		return false
+	}
+
+	if !acquired {
+		log.Debug(context.Background(), "Scanner lease held by another instance")
+	}
+
+	return acquired
+}
+
+// PauseScannerQueue stops the scanner from starting new jobs, jobs already in progress are finished.
+// Jobs can still be added to the queue, and are started once the queue is resumed.
+func PauseScannerQueue() {
+	global_scanner_queue.mutex.Lock()
+	defer global_scanner_queue.mutex.Unlock()
+
+	log.Info(context.Background(), "Scanner queue paused")
+	global_scanner_queue.paused = true
+}
+
+// ResumeScannerQueue starts the jobs added to the queue while it was paused
+func ResumeScannerQueue() {
+	global_scanner_queue.mutex.Lock()
+	defer global_scanner_queue.mutex.Unlock()
+
+	log.Info(context.Background(), "Scanner queue resumed", "queue_len", len(global_scanner_queue.up_next))
+	global_scanner_queue.paused = false
+
+	if len(global_scanner_queue.up_next) > 0 {
+		global_scanner_queue.notify()
+	}
+}
+
+// CancelScan stops the running scan, by removing the jobs waiting on the queue and cancelling the jobs in progress.
+// Jobs in progress stop as soon as they notice, and the database transactions they are in are rolled back.
+// It returns the number of jobs removed from the queue and of jobs cancelled.
+func CancelScan(ctx context.Context) (removed int, cancelled int) {
+	return cancelJobs(ctx, func(job *ScannerJob) bool { return true })
+}
+
+// CancelUserScan stops the jobs queued by the scan of the given user, like CancelScan.
+// Jobs of albums queued by themselves or by the scans of other users keep running.
+func CancelUserScan(ctx context.Context, userID int) (removed int, cancelled int) {
+	return cancelJobs(ctx, func(job *ScannerJob) bool { return job.user != nil && job.user.ID == userID })
+}
+
+// cancelJobs removes the matching jobs waiting on the queue and cancels the matching jobs in progress
+func cancelJobs(ctx context.Context, match func(job *ScannerJob) bool) (removed int, cancelled int) {
+	global_scanner_queue.mutex.Lock()
+	defer global_scanner_queue.mutex.Unlock()
+
+	kept := make([]ScannerJob, 0, len(global_scanner_queue.up_next))
+	for i := range global_scanner_queue.up_next {
+		job := &global_scanner_queue.up_next[i]
+		if !match(job) {
+			kept = append(kept, *job)
+			continue
+		}
+
+		if cancel, found := global_scanner_queue.cancel_funcs[job.ctx.GetAlbum().ID]; found {
+			cancel()
+			delete(global_scanner_queue.cancel_funcs, job.ctx.GetAlbum().ID)
+		}
+		removed++
+	}
+	global_scanner_queue.up_next = kept
+
+	for i := range global_scanner_queue.in_progress {
+		job := &global_scanner_queue.in_progress[i]
+		if !match(job) {
+			continue
+		}
+
+		if cancel, found := global_scanner_queue.cancel_funcs[job.ctx.GetAlbum().ID]; found {
+			cancel()
+		}
+		cancelled++
+	}
+
+	log.Info(ctx, "Scan cancelled", "removed_jobs", removed, "cancelled_jobs", cancelled)
+
+	// Lets the queue finish the scan if no jobs were in progress
+	global_scanner_queue.notify()
+
+	return removed, cancelled
+}
+
+// QueuedJobs returns the jobs on the scanner queue, the jobs in progress followed by the waiting jobs in scan order
+func QueuedJobs() []*models.ScannerQueueJob {
+	global_scanner_queue.mutex.Lock()
+	defer global_scanner_queue.mutex.Unlock()
+
+	jobs := make([]*models.ScannerQueueJob, 0, len(global_scanner_queue.in_progress)+len(global_scanner_queue.up_next))
+	for _, job := range global_scanner_queue.in_progress {
+		jobs = append(jobs, &models.ScannerQueueJob{Album: job.ctx.GetAlbum(), User: job.user, Running: true})
+	}
+	for _, job := range global_scanner_queue.up_next {
+		jobs = append(jobs, &models.ScannerQueueJob{Album: job.ctx.GetAlbum(), User: job.user, Running: false})
+	}
+
+	return jobs
+}
+
+// WaitForJobsInProgress blocks until no jobs are in progress, which after pausing the queue means the scanner is idle
+func WaitForJobsInProgress() {
+	for {
+		global_scanner_queue.mutex.Lock()
+		in_progress_length := len(global_scanner_queue.in_progress)
+		global_scanner_queue.mutex.Unlock()
+
+		if in_progress_length == 0 {
+			return
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
 // Notifies the queue that the jobs has changed
 func (queue *ScannerQueue) notify() bool {
 	select {
@@ -209,7 +440,9 @@ func (queue *ScannerQueue) notify() bool {
 	}
 }
 
-func AddAllToQueue() error {
+// AddAllToQueue adds the albums of all users to the scanner queue.
+// The scan is logged with the fields of the given context, such as the id of the request that started it.
+func AddAllToQueue(ctx context.Context) error {
 
 	var users []*models.User
 	result := global_scanner_queue.db.Find(&users)
@@ -217,9 +450,13 @@ func AddAllToQueue() error {
 		return errors.Wrap(result.Error, "get all users from database")
 	}
 
+	// Albums shared between users are only added for the first user they are found for,
+	// so they are not scanned again once they have finished for that user.
+	// A user failing to be added does not stop the albums of the other users from being scanned
+	added := make(map[int]bool)
 	for _, user := range users {
-		if err := AddUserToQueue(user); err != nil {
-			return errors.Wrapf(err, "failed to add user for scanning (%d)", user.ID)
+		if err := addUserToQueue(ctx, user, added); err != nil {
+			scanner_utils.ScannerError(ctx, "Failed to add user for scanning (%d): %v", user.ID, err)
 		}
 	}
 
@@ -227,23 +464,166 @@ func AddAllToQueue() error {
 }
 
 // AddUserToQueue finds all root albums owned by the given user and adds them to the scanner queue.
+// Directories that cannot be read are recorded in the scan report, and the albums that were found are scanned regardless.
 // Function does not block.
-func AddUserToQueue(user *models.User) error {
+func AddUserToQueue(ctx context.Context, user *models.User) error {
+	return addUserToQueue(ctx, user, make(map[int]bool))
+}
+
+// addUserToQueue adds the albums of the user to the scanner queue, except those already added, and records the albums it adds
+func addUserToQueue(ctx context.Context, user *models.User, added map[int]bool) error {
+	defer lockUserScan(user.ID)()
+
 	album_cache := scanner_cache.MakeAlbumCache()
-	albums, album_errors := scanner.FindAlbumsForUser(global_scanner_queue.db, user, album_cache)
-	for _, err := range album_errors {
-		return errors.Wrapf(err, "find albums for user (user_id: %d)", user.ID)
+	if err := scanner.LoadExcludePatterns(global_scanner_queue.db.WithContext(ctx), []int{user.ID}, album_cache); err != nil {
+		return err
+	}
+
+	albums, album_errors := scanner.FindAlbumsForUser(global_scanner_queue.db.WithContext(ctx), user, album_cache)
+	scanner_utils.ScannerErrors(ctx, album_errors, "Failed to find albums for user (user_id: %d): %v", user.ID)
+
+	newAlbums := make([]*models.Album, 0, len(albums))
+	for _, album := range albums {
+		if !added[album.ID] {
+			added[album.ID] = true
+			newAlbums = append(newAlbums, album)
+		}
 	}
 
+	global_scanner_queue.addFoundJobs(ctx, user, newAlbums, album_cache, len(album_errors) > 0)
+
+	return nil
+}
+
+// AddAlbumToQueue adds a single album to the scanner queue, such as when new media has been uploaded to it.
+// Function does not block.
+func AddAlbumToQueue(ctx context.Context, album *models.Album) error {
 	global_scanner_queue.mutex.Lock()
+	defer global_scanner_queue.mutex.Unlock()
+
+	if global_scanner_queue.db == nil {
+		return errors.New("scanner queue has not been initialized")
+	}
+
+	album_cache := scanner_cache.MakeAlbumCache()
+	if err := scanner.LoadAlbumIgnore(global_scanner_queue.db.WithContext(ctx), album, album_cache); err != nil {
+		return err
+	}
+	if err := scanner.LoadAlbumExcludePatterns(global_scanner_queue.db.WithContext(ctx), album, album_cache); err != nil {
+		return err
+	}
+
+	return global_scanner_queue.addJob(&ScannerJob{
+		ctx: newJobContext(ctx, album, album_cache),
+	})
+}
+
+// AddAlbumTreeToQueue adds an album and the albums below it to the scanner queue, including albums of new directories,
+// such as when a user rescans a single album. Function does not block.
+func AddAlbumTreeToQueue(ctx context.Context, user *models.User, album *models.Album) error {
+	if global_scanner_queue.db == nil {
+		return errors.New("scanner queue has not been initialized")
+	}
+
+	defer lockUserScan(user.ID)()
+
+	album_cache := scanner_cache.MakeAlbumCache()
+	if err := scanner.LoadAlbumExcludePatterns(global_scanner_queue.db.WithContext(ctx), album, album_cache); err != nil {
+		return err
+	}
+
+	albums, album_errors := scanner.FindSubAlbums(global_scanner_queue.db.WithContext(ctx), user, album, album_cache)
+	scanner_utils.ScannerErrors(ctx, album_errors, "Failed to find sub albums of album (album_id: %d): %v", album.ID)
+
+	global_scanner_queue.addFoundJobs(ctx, user, albums, album_cache, len(album_errors) > 0)
+
+	return nil
+}
+
+// addFoundJobs adds the albums found for a scan to the queue.
+// If failures were recorded while finding them, the queue is notified even if no albums were found,
+// so the report of the scan is completed.
+func (queue *ScannerQueue) addFoundJobs(ctx context.Context, user *models.User, albums []*models.Album, album_cache *scanner_cache.AlbumScannerCache, failed bool) {
+	queue.mutex.Lock()
 	for _, album := range albums {
-		global_scanner_queue.addJob(&ScannerJob{
-			ctx: scanner_task.NewTaskContext(context.Background(), global_scanner_queue.db, album, album_cache),
+		queue.addJob(&ScannerJob{
+			ctx:  newJobContext(ctx, album, album_cache),
+			user: user,
 		})
 	}
-	global_scanner_queue.mutex.Unlock()
+	queue.mutex.Unlock()
 
-	return nil
+	if failed {
+		queue.notify()
+	}
+}
+
+// RetryFailedMedia adds the albums of the media in the retry queue that are due to be retried to the scanner queue,
+// or of every media in the retry queue if forced. It returns the number of albums added. Function does not block.
+func RetryFailedMedia(ctx context.Context, force bool) (int, error) {
+	if global_scanner_queue.db == nil {
+		return 0, errors.New("scanner queue has not been initialized")
+	}
+	db := global_scanner_queue.db.WithContext(ctx)
+
+	var albumIDs []int
+	var err error
+	if force {
+		albumIDs, err = media_retry.RetryAll(db)
+	} else {
+		albumIDs, err = media_retry.ClaimDue(db, time.Now())
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if len(albumIDs) == 0 {
+		return 0, nil
+	}
+
+	var albums []*models.Album
+	if err := db.Where("id IN (?)", albumIDs).Find(&albums).Error; err != nil {
+		return 0, errors.Wrap(err, "get albums of media to be retried")
+	}
+
+	for _, album := range albums {
+		if err := AddAlbumToQueue(ctx, album); err != nil {
+			return 0, errors.Wrapf(err, "add album to be retried to queue (album_id: %d)", album.ID)
+		}
+	}
+
+	log.Info(ctx, "Retrying failed media", "albums", len(albums), "forced", force)
+
+	return len(albums), nil
+}
+
+// newJobContext makes the context of a job scanning an album, which is not cancelled along with the context
+// the job was queued from, but is logged with its fields
+// userScans serializes finding the albums of each user, so scans of the same user started at the same time
+// do not create and delete the albums of the user at once. The albums are only scanned once, as jobs of albums
+// already on the queue are not added again.
+var userScans = struct {
+	mutex sync.Mutex
+	locks map[int]*sync.Mutex
+}{locks: make(map[int]*sync.Mutex)}
+
+// lockUserScan waits until no other scan is finding the albums of the user, and returns the function to unlock it
+func lockUserScan(userID int) (unlock func()) {
+	userScans.mutex.Lock()
+	lock, found := userScans.locks[userID]
+	if !found {
+		lock = &sync.Mutex{}
+		userScans.locks[userID] = lock
+	}
+	userScans.mutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+func newJobContext(ctx context.Context, album *models.Album, album_cache *scanner_cache.AlbumScannerCache) scanner_task.TaskContext {
+	jobCtx := log.WithAttrs(log.Detach(ctx), "album_id", album.ID)
+	return scanner_task.NewTaskContext(jobCtx, global_scanner_queue.db, album, album_cache)
 }
 
 // Queue should be locked prior to calling this function
@@ -251,7 +631,21 @@ func (queue *ScannerQueue) addJob(job *ScannerJob) error {
 	if exists, err := queue.jobOnQueue(job); exists || err != nil {
 		return err
 	}
-	queue.up_next = append(queue.up_next, *job)
+
+	if queue.cancel_funcs == nil {
+		queue.cancel_funcs = make(map[int]context.CancelFunc)
+	}
+	jobCtx, cancel := job.ctx.WithCancel()
+	job.ctx = jobCtx
+	queue.cancel_funcs[job.ctx.GetAlbum().ID] = cancel
+
+	// Jobs waiting on the queue are kept in the configured scan order
+	job.order = makeJobOrder(currentScanOrder(), job.ctx.GetAlbum().Path)
+	index := queue.insertIndex(job)
+	queue.up_next = append(queue.up_next, ScannerJob{})
+	copy(queue.up_next[index+1:], queue.up_next[index:])
+	queue.up_next[index] = *job
+	scan_progress.AlbumQueued()
 	queue.notify()
 
 	return nil
@@ -0,0 +1,106 @@
+package scanner_queue
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+)
+
+// ScanOrder is the order the albums waiting on the scanner queue are scanned in, set by PHOTOVIEW_SCANNER_ORDER
+type ScanOrder string
+
+const (
+	// ScanOrderNewestFirst scans the albums of the most recently modified directories first,
+	// so the newest photos show up quickly during a long initial scan
+	ScanOrderNewestFirst ScanOrder = "newest-first"
+	// ScanOrderAlphabetical scans albums in the alphabetical order of their paths
+	ScanOrderAlphabetical ScanOrder = "alphabetical"
+	// ScanOrderLargestLast scans the albums whose directories contain the fewest bytes first
+	ScanOrderLargestLast ScanOrder = "largest-last"
+)
+
+// ScanOrders are the supported orders of the scanner queue, the first is the default
+var ScanOrders = []ScanOrder{ScanOrderNewestFirst, ScanOrderAlphabetical, ScanOrderLargestLast}
+
+// currentScanOrder returns the configured order of the scanner queue, or the default if it is not set or invalid
+func currentScanOrder() ScanOrder {
+	value := utils.EnvScannerOrder.GetValue()
+	if value == "" {
+		return ScanOrderNewestFirst
+	}
+
+	for _, order := range ScanOrders {
+		if strings.EqualFold(value, string(order)) {
+			return order
+		}
+	}
+
+	log.Warn(context.Background(), "Invalid scanner order, scanning newest albums first",
+		"env", utils.EnvScannerOrder.GetName(), "value", value)
+	return ScanOrderNewestFirst
+}
+
+// jobOrder is what a job is ordered by on the queue, read from the directory of its album when it is queued
+type jobOrder struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// makeJobOrder reads what the job is ordered by. Directories that cannot be read are scanned last,
+// their errors are reported when they are scanned.
+func makeJobOrder(order ScanOrder, albumPath string) jobOrder {
+	result := jobOrder{path: albumPath}
+
+	switch order {
+	case ScanOrderNewestFirst:
+		if info, err := os.Stat(albumPath); err == nil {
+			result.modTime = info.ModTime()
+		}
+	case ScanOrderLargestLast:
+		result.size = -1
+		if entries, err := os.ReadDir(albumPath); err == nil {
+			result.size = 0
+			for _, entry := range entries {
+				if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+					result.size += info.Size()
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// before returns whether a job is scanned before another by the given order
+func (a jobOrder) before(b jobOrder, order ScanOrder) bool {
+	switch order {
+	case ScanOrderNewestFirst:
+		return a.modTime.After(b.modTime)
+	case ScanOrderAlphabetical:
+		return a.path < b.path
+	case ScanOrderLargestLast:
+		if a.size < 0 || b.size < 0 {
+			return b.size < 0 && a.size >= 0
+		}
+		return a.size < b.size
+	}
+
+	return false
+}
+
+// insertIndex returns where a job is inserted into the jobs waiting on the queue, which are kept in scan order.
+// Jobs scanned at the same time by the order keep the order they were added in.
+// Queue should be locked prior to calling this function
+func (queue *ScannerQueue) insertIndex(job *ScannerJob) int {
+	order := currentScanOrder()
+
+	return sort.Search(len(queue.up_next), func(i int) bool {
+		return job.order.before(queue.up_next[i].order, order)
+	})
+}
@@ -3,11 +3,16 @@ package scanner_queue
 import (
 	"context"
 	"flag"
+	"os"
+	"path"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/scanner/scanner_cache"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/utils"
 )
 
 var _ = flag.Bool("database", false, "run database integration tests")
@@ -107,3 +112,169 @@ func TestScannerQueue_JobOnQueue(t *testing.T) {
 	}
 
 }
+
+func TestCancelScan(t *testing.T) {
+	global_scanner_queue = ScannerQueue{
+		idle_chan:   make(chan bool, 1),
+		in_progress: make([]ScannerJob, 0),
+		up_next:     make([]ScannerJob, 0),
+		db:          nil,
+	}
+	defer func() { global_scanner_queue = ScannerQueue{} }()
+
+	jobs := []ScannerJob{makeScannerJob(1), makeScannerJob(2), makeScannerJob(3)}
+	for i := range jobs {
+		if err := global_scanner_queue.addJob(&jobs[i]); err != nil {
+			t.Fatalf(".addJob() returned an unexpected error: %s", err)
+		}
+	}
+
+	// The first job is being scanned
+	global_scanner_queue.in_progress = append(global_scanner_queue.in_progress, global_scanner_queue.up_next[0])
+	global_scanner_queue.up_next = global_scanner_queue.up_next[1:]
+
+	removed, cancelled := CancelScan(context.Background())
+	if removed != 2 || cancelled != 1 {
+		t.Errorf("Expected 2 jobs removed and 1 cancelled, got %d removed and %d cancelled", removed, cancelled)
+	}
+
+	if len(global_scanner_queue.up_next) != 0 {
+		t.Errorf("Expected no jobs waiting after cancelling the scan, got %d", len(global_scanner_queue.up_next))
+	}
+
+	for _, job := range jobs {
+		if job.ctx.Err() == nil {
+			t.Errorf("Expected job of album %d to be cancelled", job.ctx.GetAlbum().ID)
+		}
+	}
+
+	// Jobs in progress are removed from the queue by themselves once they have stopped
+	if len(global_scanner_queue.in_progress) != 1 {
+		t.Errorf("Expected the job in progress to remain until it has stopped, got %d jobs in progress", len(global_scanner_queue.in_progress))
+	}
+}
+
+func TestCancelUserScan(t *testing.T) {
+	global_scanner_queue = ScannerQueue{
+		idle_chan:   make(chan bool, 1),
+		in_progress: make([]ScannerJob, 0),
+		up_next:     make([]ScannerJob, 0),
+		db:          nil,
+	}
+	defer func() { global_scanner_queue = ScannerQueue{} }()
+
+	user := &models.User{Username: "user"}
+	user.ID = 1
+	otherUser := &models.User{Username: "other"}
+	otherUser.ID = 2
+
+	jobs := []ScannerJob{makeScannerJob(1), makeScannerJob(2), makeScannerJob(3), makeScannerJob(4)}
+	jobs[0].user = user
+	jobs[1].user = user
+	jobs[2].user = otherUser
+	for i := range jobs {
+		if err := global_scanner_queue.addJob(&jobs[i]); err != nil {
+			t.Fatalf(".addJob() returned an unexpected error: %s", err)
+		}
+	}
+
+	// The first job is being scanned
+	global_scanner_queue.in_progress = append(global_scanner_queue.in_progress, global_scanner_queue.up_next[0])
+	global_scanner_queue.up_next = global_scanner_queue.up_next[1:]
+
+	queued := QueuedJobs()
+	if len(queued) != 4 || !queued[0].Running || queued[0].Album.ID != 1 || queued[1].Running {
+		t.Errorf("Expected the job in progress to be listed before the waiting jobs, got %+v", queued)
+	}
+
+	removed, cancelled := CancelUserScan(context.Background(), user.ID)
+	if removed != 1 || cancelled != 1 {
+		t.Errorf("Expected 1 job removed and 1 cancelled, got %d removed and %d cancelled", removed, cancelled)
+	}
+
+	for i, job := range jobs {
+		cancelledJob := job.ctx.Err() != nil
+		if cancelledJob != (job.user == user) {
+			t.Errorf("Expected only jobs queued for the user to be cancelled, job %d cancelled: %v", i, cancelledJob)
+		}
+	}
+
+	remaining := make([]int, 0)
+	for _, job := range global_scanner_queue.up_next {
+		remaining = append(remaining, job.ctx.GetAlbum().ID)
+	}
+	if !reflect.DeepEqual(remaining, []int{3, 4}) {
+		t.Errorf("Expected the jobs of other scans to keep waiting, got albums %v", remaining)
+	}
+}
+
+func TestScannerQueue_ScanOrder(t *testing.T) {
+	rootPath := t.TempDir()
+
+	makeDirectory := func(id int, name string, size int, modTime time.Time) ScannerJob {
+		dirPath := path.Join(rootPath, name)
+		if err := os.MkdirAll(dirPath, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path.Join(dirPath, "photo.jpg"), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dirPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+
+		album := makeAlbumWithID(id)
+		album.Path = dirPath
+		return NewScannerJob(scanner_task.NewTaskContext(context.Background(), nil, album, scanner_cache.MakeAlbumCache()))
+	}
+
+	now := time.Now()
+	jobs := []ScannerJob{
+		makeDirectory(1, "b", 300, now.Add(-2*time.Hour)),
+		makeDirectory(2, "c", 100, now),
+		makeDirectory(3, "a", 200, now.Add(-time.Hour)),
+	}
+	missing := NewScannerJob(scanner_task.NewTaskContext(context.Background(), nil, makeAlbumWithID(4), scanner_cache.MakeAlbumCache()))
+	jobs = append(jobs, missing)
+
+	tests := []struct {
+		order    string
+		expected []int
+	}{
+		{"", []int{2, 3, 1, 4}},
+		{"newest-first", []int{2, 3, 1, 4}},
+		{"alphabetical", []int{4, 3, 1, 2}},
+		{"largest-last", []int{2, 3, 1, 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.order, func(t *testing.T) {
+			if test.order != "" {
+				utils.EnvScannerOrder.SetOverride(&test.order)
+				defer utils.EnvScannerOrder.SetOverride(nil)
+			}
+
+			queue := ScannerQueue{
+				idle_chan:   make(chan bool, 1),
+				in_progress: make([]ScannerJob, 0),
+				up_next:     make([]ScannerJob, 0),
+			}
+
+			for i := range jobs {
+				job := jobs[i]
+				if err := queue.addJob(&job); err != nil {
+					t.Fatalf(".addJob() returned an unexpected error: %s", err)
+				}
+			}
+
+			albumIDs := make([]int, len(queue.up_next))
+			for i, job := range queue.up_next {
+				albumIDs[i] = job.ctx.GetAlbum().ID
+			}
+
+			if !reflect.DeepEqual(albumIDs, test.expected) {
+				t.Errorf("Expected albums to be scanned in order %v, got %v", test.expected, albumIDs)
+			}
+		})
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"database/sql"
 	"flag"
 	"io/fs"
+	"time"
 
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/scanner/media_encoding"
 	"github.com/photoview/photoview/api/scanner/scanner_cache"
+	"github.com/photoview/photoview/api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
@@ -54,6 +58,7 @@ const (
 	taskCtxKeyAlbum      taskCtxKeyType = "task_album"
 	taskCtxKeyAlbumCache taskCtxKeyType = "task_album_cache"
 	taskCtxKeyDatabase   taskCtxKeyType = "task_database"
+	taskCtxKeySeenPaths  taskCtxKeyType = "task_seen_paths"
 )
 
 func (c TaskContext) GetAlbum() *models.Album {
@@ -68,6 +73,18 @@ func (c TaskContext) GetDB() *gorm.DB {
 	return c.ctx.Value(taskCtxKeyDatabase).(*gorm.DB)
 }
 
+// GetSeenMediaPaths returns the paths of the media files found in the directory of the album by the scan,
+// including files that failed to be scanned, or nil if the album has not been searched for media yet
+func (c TaskContext) GetSeenMediaPaths() map[string]bool {
+	seenPaths, _ := c.ctx.Value(taskCtxKeySeenPaths).(map[string]bool)
+	return seenPaths
+}
+
+// WithSeenMediaPaths stores the paths of the media files found in the directory of the album
+func (c TaskContext) WithSeenMediaPaths(seenPaths map[string]bool) TaskContext {
+	return c.WithValue(taskCtxKeySeenPaths, seenPaths)
+}
+
 func (c TaskContext) DatabaseTransaction(transFunc func(ctx TaskContext) error, opts ...*sql.TxOptions) error {
 	return c.GetDB().Transaction(func(tx *gorm.DB) error {
 		return transFunc(c.WithDB(tx))
@@ -93,6 +110,36 @@ func (c TaskContext) WithDB(db *gorm.DB) TaskContext {
 	return c.WithValue(taskCtxKeyDatabase, db.WithContext(c.ctx))
 }
 
+// StartSpan starts a tracing span for a stage of the scanner.
+// The returned context carries the span, so the stages and database queries made with it become part of it.
+func (c TaskContext) StartSpan(name string, attrs ...attribute.KeyValue) (TaskContext, trace.Span) {
+	spanCtx, span := tracing.Start(c.ctx, name, attrs...)
+
+	newCtx := TaskContext{ctx: spanCtx}
+	if db, ok := c.ctx.Value(taskCtxKeyDatabase).(*gorm.DB); ok {
+		newCtx = newCtx.WithDB(db)
+	}
+
+	return newCtx, span
+}
+
+// WithCancel returns a copy of the context that is done once the returned function is called,
+// stopping the scan of the album it is the context of
+func (c TaskContext) WithCancel() (TaskContext, context.CancelFunc) {
+	cancelCtx, cancel := context.WithCancel(c.ctx)
+
+	newCtx := TaskContext{ctx: cancelCtx}
+	if db, ok := c.ctx.Value(taskCtxKeyDatabase).(*gorm.DB); ok {
+		newCtx = newCtx.WithDB(db)
+	}
+
+	return newCtx, cancel
+}
+
+func (c TaskContext) Deadline() (time.Time, bool) {
+	return c.ctx.Deadline()
+}
+
 func (c TaskContext) Done() <-chan struct{} {
 	return c.ctx.Done()
 }
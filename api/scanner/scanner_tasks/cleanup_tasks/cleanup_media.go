@@ -4,40 +4,60 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"time"
 
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/scanner/face_detection"
+	"github.com/photoview/photoview/api/scanner/scan_progress"
 	"github.com/photoview/photoview/api/scanner/scanner_utils"
 	"github.com/photoview/photoview/api/utils"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
 
-// CleanupMedia removes media entries from the database that are no longer present on the filesystem
-func CleanupMedia(db *gorm.DB, albumId int, albumMedia []*models.Media) []error {
-	albumMediaIds := make([]int, len(albumMedia))
-	for i, media := range albumMedia {
-		albumMediaIds[i] = media.ID
+// CleanupMedia removes the media of an album whose files were not seen by the scan of the album,
+// as they have been deleted or are now ignored, from the database along with their cached files.
+// Media whose files were seen but failed to be scanned are kept.
+// Media whose content has been hashed are marked missing instead, as their files may have been moved,
+// they are removed by DeleteMissingMedia once the scan has finished.
+func CleanupMedia(db *gorm.DB, albumId int, seenPaths map[string]bool) []error {
+	var albumMedia []models.Media
+	if err := db.Select("id, path, content_hash").Where("album_id = ?", albumId).Find(&albumMedia).Error; err != nil {
+		return []error{errors.Wrap(err, "get media files to be deleted from database")}
 	}
 
-	// Will get from database
-	var mediaList []models.Media
-
-	query := db.Where("album_id = ?", albumId)
-
 	// Select media from database that was not found on hard disk
-	if len(albumMedia) > 0 {
-		query = query.Where("NOT id IN (?)", albumMediaIds)
+	mediaList := make([]models.Media, 0)
+	for _, media := range albumMedia {
+		if !seenPaths[media.Path] {
+			mediaList = append(mediaList, media)
+		}
 	}
 
-	if err := query.Find(&mediaList).Error; err != nil {
-		return []error{errors.Wrap(err, "get media files to be deleted from database")}
+	deleteErrors := make([]error, 0)
+
+	var retries []models.MediaRetry
+	if err := db.Select("id, media_path").Where("album_id = ?", albumId).Find(&retries).Error; err != nil {
+		deleteErrors = append(deleteErrors, errors.Wrap(err, "get media to be retried from database"))
 	}
+	for _, retry := range retries {
+		if seenPaths[retry.MediaPath] {
+			continue
+		}
 
-	deleteErrors := make([]error, 0)
+		// Files that have been deleted are no longer retried
+		if err := db.Delete(&retry).Error; err != nil {
+			deleteErrors = append(deleteErrors, errors.Wrap(err, "delete media to be retried from database"))
+		}
+	}
 
+	missingIDs := make([]int, 0)
 	mediaIDs := make([]int, 0)
 	for _, media := range mediaList {
+		if media.ContentHash != nil {
+			missingIDs = append(missingIDs, media.ID)
+			continue
+		}
 
 		mediaIDs = append(mediaIDs, media.ID)
 		cachePath := path.Join(utils.MediaCachePath(), strconv.Itoa(int(albumId)), strconv.Itoa(int(media.ID)))
@@ -48,9 +68,20 @@ func CleanupMedia(db *gorm.DB, albumId int, albumMedia []*models.Media) []error
 
 	}
 
+	if len(missingIDs) > 0 {
+		err := db.Model(&models.Media{}).
+			Where("id IN (?) AND missing_since IS NULL", missingIDs).
+			Update("missing_since", time.Now()).Error
+		if err != nil {
+			deleteErrors = append(deleteErrors, errors.Wrap(err, "mark missing media in database"))
+		}
+	}
+
 	if len(mediaIDs) > 0 {
 		if err := db.Where("id IN (?)", mediaIDs).Delete(models.Media{}).Error; err != nil {
 			deleteErrors = append(deleteErrors, errors.Wrap(err, "delete old media from database"))
+		} else {
+			scan_progress.MediaRemoved(len(mediaIDs))
 		}
 
 		// Reload faces after deleting media
@@ -64,6 +95,24 @@ func CleanupMedia(db *gorm.DB, albumId int, albumMedia []*models.Media) []error
 	return deleteErrors
 }
 
+// DeleteMissingMedia removes the media that are still missing once the scan has finished,
+// as their files have been deleted rather than moved.
+func DeleteMissingMedia(db *gorm.DB) (int64, error) {
+	result := db.Where("missing_since IS NOT NULL").Delete(&models.Media{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "delete missing media from database")
+	}
+	scan_progress.MediaRemoved(int(result.RowsAffected))
+
+	if result.RowsAffected > 0 && face_detection.GlobalFaceDetector != nil {
+		if err := face_detection.GlobalFaceDetector.ReloadFacesFromDatabase(db); err != nil {
+			return result.RowsAffected, errors.Wrap(err, "reload faces from database")
+		}
+	}
+
+	return result.RowsAffected, nil
+}
+
 // DeleteOldUserAlbums finds and deletes old albums in the database and cache that does not exist on the filesystem anymore.
 func DeleteOldUserAlbums(db *gorm.DB, scannedAlbums []*models.Album, user *models.User) []error {
 	if len(scannedAlbums) == 0 {
@@ -96,6 +145,10 @@ func DeleteOldUserAlbums(db *gorm.DB, scannedAlbums []*models.Album, user *model
 
 	deleteErrors := make([]error, 0)
 
+	if err := keepMovableMedia(db, deleteAlbums); err != nil {
+		deleteErrors = append(deleteErrors, err)
+	}
+
 	// Delete old albums from cache
 	deleteAlbumIDs := make([]int, len(deleteAlbums))
 	for i, album := range deleteAlbums {
@@ -107,6 +160,12 @@ func DeleteOldUserAlbums(db *gorm.DB, scannedAlbums []*models.Album, user *model
 		}
 	}
 
+	// The media of the albums are deleted along with them
+	var removedMedia int64
+	if err := db.Model(&models.Media{}).Where("album_id IN (?)", deleteAlbumIDs).Count(&removedMedia).Error; err != nil {
+		deleteErrors = append(deleteErrors, errors.Wrap(err, "count media of old albums"))
+	}
+
 	// Delete old albums from database
 	err := db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Where("album_id IN (?)", deleteAlbumIDs).Delete(&models.UserAlbums{}).Error; err != nil {
@@ -121,8 +180,10 @@ func DeleteOldUserAlbums(db *gorm.DB, scannedAlbums []*models.Album, user *model
 	})
 
 	if err != nil {
-		scanner_utils.ScannerError("Could not delete old albums from database:\n%s\n", err)
+		scanner_utils.ScannerError(db.Statement.Context, "Could not delete old albums from database:\n%s\n", err)
 		deleteErrors = append(deleteErrors, err)
+	} else {
+		scan_progress.MediaRemoved(int(removedMedia))
 	}
 
 	// Reload faces after deleting albums
@@ -134,3 +195,40 @@ func DeleteOldUserAlbums(db *gorm.DB, scannedAlbums []*models.Album, user *model
 
 	return deleteErrors
 }
+
+// keepMovableMedia moves the media whose content has been hashed, out of the albums about to be deleted
+// into their closest album that is kept, and marks them missing. So that they are found again if their
+// directory has been moved or renamed, instead of being deleted along with their album.
+func keepMovableMedia(db *gorm.DB, deleteAlbums []models.Album) error {
+	deleted := make(map[int]*models.Album, len(deleteAlbums))
+	for i := range deleteAlbums {
+		deleted[deleteAlbums[i].ID] = &deleteAlbums[i]
+	}
+
+	for _, album := range deleteAlbums {
+		keptParentID := album.ParentAlbumID
+		for keptParentID != nil {
+			parent, isDeleted := deleted[*keptParentID]
+			if !isDeleted {
+				break
+			}
+			keptParentID = parent.ParentAlbumID
+		}
+
+		if keptParentID == nil {
+			continue
+		}
+
+		err := db.Model(&models.Media{}).
+			Where("album_id = ? AND content_hash IS NOT NULL", album.ID).
+			Updates(map[string]interface{}{
+				"album_id":      *keptParentID,
+				"missing_since": gorm.Expr("COALESCE(missing_since, ?)", time.Now()),
+			}).Error
+		if err != nil {
+			return errors.Wrapf(err, "keep media of deleted album (%s)", album.Path)
+		}
+	}
+
+	return nil
+}
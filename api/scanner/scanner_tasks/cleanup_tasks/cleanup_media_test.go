@@ -1,6 +1,7 @@
 package cleanup_tasks_test
 
 import (
+	"errors"
 	"os"
 	"path"
 	"testing"
@@ -9,6 +10,8 @@ import (
 	"github.com/photoview/photoview/api/database/drivers"
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/scanner/face_detection"
+	"github.com/photoview/photoview/api/scanner/media_retry"
+	"github.com/photoview/photoview/api/scanner/scanner_tasks/cleanup_tasks"
 	"github.com/photoview/photoview/api/test_utils"
 	"github.com/stretchr/testify/assert"
 )
@@ -108,3 +111,39 @@ func TestCleanupMedia(t *testing.T) {
 		assert.Equal(t, 4, countAllMediaURLs())
 	})
 }
+
+func TestCleanupMediaSeenPaths(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	assert.NoError(t, db.Save(&album).Error)
+
+	mediaByPath := make(map[string]*models.Media)
+	for _, mediaPath := range []string{"/photos/kept.jpg", "/photos/failed.jpg", "/photos/deleted.jpg"} {
+		media := models.Media{Title: path.Base(mediaPath), Path: mediaPath, AlbumID: album.ID}
+		assert.NoError(t, db.Save(&media).Error)
+		mediaByPath[mediaPath] = &media
+	}
+
+	deletedCache, err := mediaByPath["/photos/deleted.jpg"].CachePath()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, media_retry.RecordFailure(db, album.ID, "/photos/failed.jpg", errors.New("read failed")))
+	assert.NoError(t, media_retry.RecordFailure(db, album.ID, "/photos/deleted.jpg", errors.New("read failed")))
+
+	// The failed file was seen by the scan, but no media was returned for it
+	seenPaths := map[string]bool{"/photos/kept.jpg": true, "/photos/failed.jpg": true}
+	assert.Empty(t, cleanup_tasks.CleanupMedia(db, album.ID, seenPaths))
+
+	var mediaPaths []string
+	assert.NoError(t, db.Model(&models.Media{}).Order("path").Pluck("path", &mediaPaths).Error)
+	assert.Equal(t, []string{"/photos/failed.jpg", "/photos/kept.jpg"}, mediaPaths, "only the media of deleted files are removed")
+	assert.NoDirExists(t, deletedCache)
+
+	var retryPaths []string
+	assert.NoError(t, db.Model(&models.MediaRetry{}).Pluck("media_path", &retryPaths).Error)
+	assert.Equal(t, []string{"/photos/failed.jpg"}, retryPaths, "deleted files are no longer retried")
+}
@@ -12,9 +12,15 @@ type MediaCleanupTask struct {
 
 func (t MediaCleanupTask) AfterScanAlbum(ctx scanner_task.TaskContext, changedMedia []*models.Media, albumMedia []*models.Media) error {
 
-	cleanup_errors := CleanupMedia(ctx.GetDB(), ctx.GetAlbum().ID, albumMedia)
+	// Without the files seen by the scan, every media of the album would appear to be deleted
+	seenPaths := ctx.GetSeenMediaPaths()
+	if seenPaths == nil {
+		return nil
+	}
+
+	cleanup_errors := CleanupMedia(ctx.GetDB(), ctx.GetAlbum().ID, seenPaths)
 	for _, err := range cleanup_errors {
-		scanner_utils.ScannerError("delete old media: %s", err)
+		scanner_utils.ScannerError(ctx, "delete old media: %s", err)
 	}
 
 	return nil
@@ -1,9 +1,8 @@
 package scanner_tasks
 
 import (
-	"log"
-
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner/exif"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
 )
@@ -20,7 +19,7 @@ func (t ExifTask) AfterMediaFound(ctx scanner_task.TaskContext, media *models.Me
 
 	_, err := exif.SaveEXIF(ctx.GetDB(), media)
 	if err != nil {
-		log.Printf("WARN: SaveEXIF for %s failed: %s\n", media.Title, err)
+		log.Warn(ctx, "SaveEXIF failed", "media", media.Title, "error", err)
 	}
 
 	return nil
@@ -0,0 +1,38 @@
+package scanner_tasks
+
+import (
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/histogram"
+	"github.com/photoview/photoview/api/scanner/media_encoding"
+	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/pkg/errors"
+)
+
+type HistogramTask struct {
+	scanner_task.ScannerTaskBase
+}
+
+func (t HistogramTask) AfterProcessMedia(ctx scanner_task.TaskContext, mediaData *media_encoding.EncodeMediaData, updatedURLs []*models.MediaURL, mediaIndex int, mediaTotal int) error {
+	media := mediaData.Media
+	if media.Type != models.MediaTypePhoto {
+		return nil
+	}
+
+	// Photos processed before histograms were computed get one when they are scanned again
+	if len(updatedURLs) == 0 {
+		var count int64
+		if err := ctx.GetDB().Model(&models.MediaHistogram{}).Where("media_id = ?", media.ID).Count(&count).Error; err != nil {
+			return errors.Wrap(err, "count histograms of photo")
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+
+	if _, err := histogram.SaveHistogram(ctx.GetDB(), media); err != nil {
+		log.Warn(ctx, "SaveHistogram failed", "media", media.Title, "error", err)
+	}
+
+	return nil
+}
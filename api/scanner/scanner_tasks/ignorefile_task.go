@@ -2,8 +2,8 @@ package scanner_tasks
 
 import (
 	"io/fs"
-	"log"
 
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
 	ignore "github.com/sabhiram/go-gitignore"
 )
@@ -28,8 +28,8 @@ func (t IgnorefileTask) BeforeScanAlbum(ctx scanner_task.TaskContext) (scanner_t
 func (t IgnorefileTask) MediaFound(ctx scanner_task.TaskContext, fileInfo fs.FileInfo, mediaPath string) (bool, error) {
 
 	// Match file against ignore data
-	if getAlbumIgnore(ctx).MatchesPath(fileInfo.Name()) {
-		log.Printf("File %s ignored\n", fileInfo.Name())
+	if getAlbumIgnore(ctx).MatchesPath(mediaPath) {
+		log.Debug(ctx, "File ignored", "name", fileInfo.Name())
 		return true, nil
 	}
 
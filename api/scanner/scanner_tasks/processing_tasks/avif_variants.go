@@ -0,0 +1,195 @@
+package processing_tasks
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/media_encoding"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/scanner/scan_profile"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// avifVariantURLs returns the media urls of the generated images of a photo that have an AVIF variant,
+// and of their AVIF variants by the purpose of the image they are a variant of
+func avifVariantURLs(tx *gorm.DB, photo *models.Media) ([]*models.MediaURL, map[models.MediaPurpose]*models.MediaURL, error) {
+	var urls []*models.MediaURL
+	if err := tx.Where("media_id = ?", photo.ID).Find(&urls).Error; err != nil {
+		return nil, nil, errors.Wrapf(err, "get generated images of photo (%s)", photo.Path)
+	}
+
+	bases := make([]*models.MediaURL, 0)
+	variants := make(map[models.MediaPurpose]*models.MediaURL)
+	for _, url := range urls {
+		url.Media = photo
+		if url.Purpose.HasAVIFVariant() {
+			bases = append(bases, url)
+		} else if base, isVariant := url.Purpose.AVIFVariantOf(); isVariant {
+			variants[base] = url
+		}
+	}
+
+	return bases, variants, nil
+}
+
+// AVIFVariantsChanged returns whether the AVIF variants of a processed photo differ from its generated images,
+// such as after encoding them has been enabled or disabled
+func AVIFVariantsChanged(tx *gorm.DB, photo *models.Media) (bool, error) {
+	bases, variants, err := avifVariantURLs(tx, photo)
+	if err != nil {
+		return false, err
+	}
+
+	if !media_encoding.EncodeAVIFEnabled() {
+		return len(variants) > 0, nil
+	}
+
+	if len(bases) != len(variants) {
+		return true, nil
+	}
+	for _, base := range bases {
+		if variants[base.Purpose] == nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// removeAVIFVariants deletes the AVIF variants of the generated images of a photo
+func removeAVIFVariants(tx *gorm.DB, photo *models.Media) error {
+	_, variants, err := avifVariantURLs(tx, photo)
+	if err != nil {
+		return err
+	}
+
+	for _, url := range variants {
+		if err := removeGeneratedPhoto(tx, url); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// processAVIFVariants encodes AVIF variants of the thumbnail, scaled copies and web copy of a photo, if enabled,
+// and removes the variants of images that no longer exist, or all of them if disabled
+func processAVIFVariants(tx *gorm.DB, photo *models.Media, mediaCachePath string) ([]*models.MediaURL, error) {
+	bases, variants, err := avifVariantURLs(tx, photo)
+	if err != nil {
+		return nil, err
+	}
+
+	if !media_encoding.EncodeAVIFEnabled() {
+		return []*models.MediaURL{}, removeAVIFVariants(tx, photo)
+	}
+
+	updatedURLs := make([]*models.MediaURL, 0)
+	for _, base := range bases {
+		variant := variants[base.Purpose]
+		delete(variants, base.Purpose)
+
+		if variant != nil && variant.CachedFileComplete() {
+			continue
+		}
+
+		basePath, err := base.CachedPath()
+		if err != nil {
+			return nil, err
+		}
+
+		if variant != nil {
+			cachedPath, err := variant.CachedPath()
+			if err != nil {
+				return nil, err
+			}
+
+			fmt.Printf("AVIF variant found in database but not in cache, re-encoding photo to cache: %s\n", variant.MediaName)
+			if variant, err = generateSaveAVIFVariant(tx, photo, base, variant.MediaName, path.Dir(cachedPath), basePath, variant); err != nil {
+				return nil, err
+			}
+			updatedURLs = append(updatedURLs, variant)
+			continue
+		}
+
+		purpose := base.Purpose.AVIFVariant()
+		variantName := generateUniqueMediaNamePrefixed(string(purpose), photo.Path, ".avif")
+
+		entry, err := findCacheEntry(tx, photo, purpose)
+		if err != nil {
+			return nil, err
+		}
+
+		if entry != nil {
+			variant, err = mediaURLFromCacheEntry(tx, photo, entry, variantName)
+		} else {
+			variant, err = generateSaveAVIFVariant(tx, photo, base, variantName, mediaCachePath, basePath, nil)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "error processing AVIF variant of %s", base.Purpose)
+		}
+		updatedURLs = append(updatedURLs, variant)
+	}
+
+	// Variants of images that have been removed, such as scaled copies of sizes no longer configured
+	for _, variant := range variants {
+		if err := removeGeneratedPhoto(tx, variant); err != nil {
+			return nil, err
+		}
+	}
+
+	return updatedURLs, nil
+}
+
+// generateSaveAVIFVariant encodes the AVIF variant of a generated image of a photo, and saves its media url,
+// or updates the given media url of a variant missing from the cache
+func generateSaveAVIFVariant(tx *gorm.DB, media *models.Media, base *models.MediaURL, variantName string, photoCachePath string, basePath string, mediaURL *models.MediaURL) (*models.MediaURL, error) {
+	purpose := base.Purpose.AVIFVariant()
+	outputPath, err := cacheFilePath(media, photoCachePath, purpose, variantName)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := scanner_io.AcquireThumbnailJob(tx.Statement.Context)
+	if err != nil {
+		return nil, err
+	}
+	endEncode := scan_profile.Start(scan_profile.StageThumbnails)
+	err = media_encoding.EncodeAVIF(tx.Statement.Context, basePath, outputPath)
+	endEncode()
+	release()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create AVIF variant of cached image")
+	}
+
+	fileStats, err := os.Stat(outputPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading file stats of AVIF variant")
+	}
+
+	if mediaURL == nil {
+		mediaURL = &models.MediaURL{
+			MediaID:     media.ID,
+			MediaName:   variantName,
+			Purpose:     purpose,
+			ContentType: string(media_type.TypeAvif),
+		}
+	}
+	mediaURL.Width = base.Width
+	mediaURL.Height = base.Height
+	mediaURL.FileSize = fileStats.Size()
+
+	if err := saveCacheEntry(tx, media, mediaURL, outputPath); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Save(mediaURL).Error; err != nil {
+		return nil, errors.Wrapf(err, "could not save AVIF variant media url (%d, %s)", media.ID, variantName)
+	}
+
+	return mediaURL, nil
+}
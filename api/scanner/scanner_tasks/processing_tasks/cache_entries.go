@@ -0,0 +1,190 @@
+package processing_tasks
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/media_encoding"
+	"github.com/photoview/photoview/api/scanner/media_encoding/media_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cacheFilePath returns the path a file of the given purpose is generated at in the cache directory of the media.
+// Files of media whose content has been hashed are named by their purpose, so all media with that content share them,
+// and are stored in the cache location of the type of the file.
+func cacheFilePath(media *models.Media, mediaCachePath string, purpose models.MediaPurpose, mediaName string) (string, error) {
+	if media.ContentHash == nil {
+		return path.Join(mediaCachePath, mediaName), nil
+	}
+
+	contentCachePath, err := media.CachePathForPurpose(purpose)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(contentCachePath, string(purpose)+path.Ext(mediaName)), nil
+}
+
+// findCacheEntry returns the file of the given purpose already generated for the content of the media, if it is still complete in the cache
+func findCacheEntry(tx *gorm.DB, media *models.Media, purpose models.MediaPurpose) (*models.CacheEntry, error) {
+	if media.ContentHash == nil {
+		return nil, nil
+	}
+
+	var entries []*models.CacheEntry
+	err := tx.Where("content_hash = ? AND purpose = ?", *media.ContentHash, purpose).
+		Limit(1).
+		Find(&entries).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get cache entry from database")
+	}
+
+	if len(entries) == 0 {
+		return adoptCacheFile(tx, media, purpose)
+	}
+
+	// Files that are missing, or have not been written completely, are generated again
+	fileInfo, err := os.Stat(entries[0].FilePath())
+	if err != nil || fileInfo.Size() != entries[0].FileSize {
+		return nil, nil
+	}
+
+	return entries[0], nil
+}
+
+// originalDimensionsOfContent returns the dimensions of the original of another media with the same content as the media,
+// as the web version in the cache may have been scaled down. It returns nil if there is no such media.
+func originalDimensionsOfContent(tx *gorm.DB, media *models.Media) (*media_utils.PhotoDimensions, error) {
+	if media.ContentHash == nil {
+		return nil, nil
+	}
+
+	var originals []*models.MediaURL
+	err := tx.Joins("JOIN media ON media.id = media_urls.media_id").
+		Where("media.content_hash = ? AND media.id != ? AND media_urls.purpose = ?", *media.ContentHash, media.ID, models.MediaOriginal).
+		Limit(1).
+		Find(&originals).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get original of media with the same content")
+	}
+
+	if len(originals) == 0 {
+		return nil, nil
+	}
+
+	return &media_utils.PhotoDimensions{Width: originals[0].Width, Height: originals[0].Height}, nil
+}
+
+// adoptCacheFile records an image of the purpose that is in the cache directory of the content of the media, but not in the manifest
+// of the cache, such as after the database has been rebuilt, so it is used instead of being generated again.
+// Files are written to the cache atomically, so those found there are complete. It returns nil if there is no such image.
+func adoptCacheFile(tx *gorm.DB, media *models.Media, purpose models.MediaPurpose) (*models.CacheEntry, error) {
+	_, scaled := purpose.ScaledSize()
+	if !scaled && purpose != models.PhotoThumbnail && purpose != models.PhotoHighRes && purpose != models.VideoThumbnail &&
+		purpose != models.VideoSprite {
+		return nil, nil
+	}
+
+	for _, format := range []media_encoding.ImageFormat{media_encoding.ImageFormatJPEG, media_encoding.ImageFormatWebP} {
+		filePath, err := cacheFilePath(media, "", purpose, format.Extension())
+		if err != nil {
+			return nil, err
+		}
+
+		fileInfo, err := os.Stat(filePath)
+		if err != nil {
+			continue
+		}
+
+		dimensions, err := media_utils.GetPhotoDimensions(filePath)
+		if err != nil {
+			continue
+		}
+
+		mediaURL := models.MediaURL{
+			Width:       dimensions.Width,
+			Height:      dimensions.Height,
+			Purpose:     purpose,
+			ContentType: format.ContentType(),
+			FileSize:    fileInfo.Size(),
+		}
+		if err := saveCacheEntry(tx, media, &mediaURL, filePath); err != nil {
+			return nil, err
+		}
+		if mediaURL.CacheKey == nil {
+			return nil, nil
+		}
+
+		var entry models.CacheEntry
+		if err := tx.Where("cache_entries.key = ?", *mediaURL.CacheKey).First(&entry).Error; err != nil {
+			return nil, errors.Wrap(err, "get adopted cache entry")
+		}
+
+		return &entry, nil
+	}
+
+	return nil, nil
+}
+
+// mediaURLFromCacheEntry saves a media url of the media, that shares a file already in the cache
+func mediaURLFromCacheEntry(tx *gorm.DB, media *models.Media, entry *models.CacheEntry, mediaName string) (*models.MediaURL, error) {
+	mediaURL := models.MediaURL{
+		MediaID:     media.ID,
+		MediaName:   mediaName,
+		Width:       entry.Width,
+		Height:      entry.Height,
+		Purpose:     entry.Purpose,
+		ContentType: entry.ContentType,
+		FileSize:    entry.FileSize,
+		CacheKey:    &entry.Key,
+	}
+
+	if err := tx.Create(&mediaURL).Error; err != nil {
+		return nil, errors.Wrapf(err, "insert media url of cache entry (%d, %s)", media.ID, entry.Key)
+	}
+
+	if entry.OrphanedAt != nil {
+		if err := tx.Model(entry).Update("orphaned_at", nil).Error; err != nil {
+			return nil, errors.Wrap(err, "update cache entry")
+		}
+	}
+
+	return &mediaURL, nil
+}
+
+// saveCacheEntry records a generated file in the manifest of the cache, and links the media url to it.
+// It should be called before the media url is saved. Files outside the content addressed part of the cache location are not recorded.
+func saveCacheEntry(tx *gorm.DB, media *models.Media, mediaURL *models.MediaURL, filePath string) error {
+	if media.ContentHash == nil {
+		return nil
+	}
+
+	key, err := filepath.Rel(mediaURL.Purpose.CachePath(), filePath)
+	if err != nil || !strings.HasPrefix(key, utils.ContentCacheDir+string(filepath.Separator)) {
+		return nil
+	}
+	key = filepath.ToSlash(key)
+
+	entry := models.CacheEntry{
+		Key:         key,
+		ContentHash: *media.ContentHash,
+		Purpose:     mediaURL.Purpose,
+		Width:       mediaURL.Width,
+		Height:      mediaURL.Height,
+		ContentType: mediaURL.ContentType,
+		FileSize:    mediaURL.FileSize,
+	}
+
+	if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error; err != nil {
+		return errors.Wrapf(err, "save cache entry (%s)", key)
+	}
+
+	mediaURL.CacheKey = &key
+	return nil
+}
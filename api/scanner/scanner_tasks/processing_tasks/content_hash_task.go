@@ -0,0 +1,169 @@
+package processing_tasks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_encoding"
+	"github.com/photoview/photoview/api/scanner/scan_profile"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
+	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+// ContentHashTask hashes the content of media before it is processed, so its cached files are addressed by its content,
+// and moves the cached files of media processed before into the content addressed part of the cache
+type ContentHashTask struct {
+	scanner_task.ScannerTaskBase
+}
+
+func (t ContentHashTask) BeforeProcessMedia(ctx scanner_task.TaskContext, mediaData *media_encoding.EncodeMediaData) (scanner_task.TaskContext, error) {
+	media := mediaData.Media
+
+	if media.ContentHash == nil {
+		contentHash, err := hashMediaContent(ctx, media.Path, media.SideCarPath)
+		if err != nil {
+			// The media keeps using the directory of its album, until its content can be hashed
+			log.Warn(ctx, "Hashing content of media", "path", media.Path, "error", err)
+			return ctx, nil
+		}
+
+		if err := ctx.GetDB().Model(media).Update("content_hash", contentHash).Error; err != nil {
+			return ctx, errors.Wrapf(err, "save content hash of media (%s)", media.Path)
+		}
+		media.ContentHash = &contentHash
+	}
+
+	if err := migrateLegacyCache(ctx, media); err != nil {
+		return ctx, errors.Wrapf(err, "move cached files of media (%s)", media.Path)
+	}
+
+	return ctx, nil
+}
+
+// HashMediaFile computes the content hash of the media file at the given path, as saved for the media once processed.
+// The sidecar of raw photos is included in the hash, and its path is returned.
+func HashMediaFile(ctx context.Context, mediaPath string, isRaw bool) (string, *string, error) {
+	var sideCarPath *string
+	if isRaw {
+		sideCarPath = scanForSideCarFile(mediaPath)
+	}
+
+	contentHash, err := hashMediaContent(ctx, mediaPath, sideCarPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return contentHash, sideCarPath, nil
+}
+
+// hashMediaContent computes the SHA-256 hash of the original file of a media, and its sidecar if it has one,
+// as the sidecar changes the generated files of raw photos
+func hashMediaContent(ctx context.Context, mediaPath string, sideCarPath *string) (string, error) {
+	defer scan_profile.Start(scan_profile.StageContentHash)()
+
+	h := sha256.New()
+
+	paths := []string{mediaPath}
+	if sideCarPath != nil {
+		paths = append(paths, *sideCarPath)
+	}
+
+	for _, filePath := range paths {
+		if err := hashFile(ctx, h, filePath); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(ctx context.Context, w io.Writer, filePath string) error {
+	f, err := scanner_io.Open(ctx, filePath)
+	if err != nil {
+		return errors.Wrapf(err, "open file to hash (%s)", filePath)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(w, f); err != nil {
+		return errors.Wrapf(err, "hash file (%s)", filePath)
+	}
+
+	return nil
+}
+
+// migrateLegacyCache moves the cached files of a media from the directory of its album,
+// to the directory of its content, or links them to files already generated for the same content
+func migrateLegacyCache(ctx scanner_task.TaskContext, media *models.Media) error {
+	db := ctx.GetDB()
+
+	var legacyURLs []*models.MediaURL
+	err := db.Where("media_id = ? AND cache_key IS NULL AND purpose <> ?", media.ID, models.MediaOriginal).
+		Find(&legacyURLs).Error
+	if err != nil {
+		return errors.Wrap(err, "get media urls from database")
+	}
+
+	if len(legacyURLs) == 0 {
+		return nil
+	}
+
+	for _, mediaURL := range legacyURLs {
+		mediaURL.Media = media
+		legacyPath, err := mediaURL.CachedPath()
+		if err != nil {
+			return err
+		}
+
+		entry, err := findCacheEntry(db, media, mediaURL.Purpose)
+		if err != nil {
+			return err
+		}
+
+		if entry != nil {
+			// Another media with the same content has been processed already
+			mediaURL.CacheKey = &entry.Key
+			if err := os.Remove(legacyPath); err != nil && !os.IsNotExist(err) {
+				log.Warn(ctx, "Removing cached file of media", "path", legacyPath, "error", err)
+			}
+		} else {
+			contentPath, err := cacheFilePath(media, "", mediaURL.Purpose, mediaURL.MediaName)
+			if err != nil {
+				return err
+			}
+
+			if err := os.Rename(legacyPath, contentPath); err != nil {
+				if !os.IsNotExist(err) {
+					// Such as when the cache location of the type of the file is on another file system
+					log.Debug(ctx, "Moving cached file of media, it is generated again instead", "path", legacyPath, "error", err)
+					os.Remove(legacyPath)
+				}
+
+				// The file is generated in the content addressed part of the cache, the next time it is processed
+				key := path.Join(utils.ContentCacheKey(*media.ContentHash), path.Base(contentPath))
+				mediaURL.CacheKey = &key
+			} else if err := saveCacheEntry(db, media, mediaURL, contentPath); err != nil {
+				return err
+			}
+		}
+
+		if err := db.Model(mediaURL).Update("cache_key", mediaURL.CacheKey).Error; err != nil {
+			return errors.Wrap(err, "update cache key of media url")
+		}
+	}
+
+	// Remove the directories of the media and its album, once they are empty
+	legacyMediaPath := path.Join(utils.MediaCachePath(), strconv.Itoa(media.AlbumID), strconv.Itoa(media.ID))
+	os.Remove(legacyMediaPath)
+	os.Remove(path.Dir(legacyMediaPath))
+
+	return nil
+}
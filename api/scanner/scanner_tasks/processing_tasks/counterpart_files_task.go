@@ -68,6 +68,12 @@ func scanForCompressedCounterpartFile(imagePath string) *string {
 	return nil
 }
 
+// HasRawCounterpart returns whether the image is the compressed version of a raw file next to it,
+// which is not scanned as a media of its own
+func HasRawCounterpart(imagePath string) bool {
+	return scanForRawCounterpartFile(imagePath) != nil
+}
+
 func scanForRawCounterpartFile(imagePath string) *string {
 	ext := filepath.Ext(imagePath)
 	fileExtType, found := media_type.GetExtensionMediaType(ext)
@@ -0,0 +1,141 @@
+package processing_tasks
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/live_photo"
+	"github.com/photoview/photoview/api/scanner/media_encoding"
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
+	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+// LivePhotoTask shows the video of an Apple Live Photo as the motion of its photo, instead of as a media of its own
+type LivePhotoTask struct {
+	scanner_task.ScannerTaskBase
+}
+
+// MediaFound skips the videos of live photos
+func (t LivePhotoTask) MediaFound(ctx scanner_task.TaskContext, fileInfo fs.FileInfo, mediaPath string) (skip bool, err error) {
+	return IsLivePhotoVideo(ctx, mediaPath), nil
+}
+
+// IsLivePhotoVideo returns whether the video is the motion of a live photo next to it,
+// which is not scanned as a media of its own
+func IsLivePhotoVideo(ctx context.Context, videoPath string) bool {
+	stillPath := live_photo.StillOfVideo(videoPath)
+	if stillPath == nil {
+		return false
+	}
+
+	paired, err := live_photo.IsPair(ctx, *stillPath, videoPath)
+	if err != nil {
+		log.Warn(ctx, "Pairing video with live photo", "path", videoPath, "error", err)
+		return false
+	}
+
+	return paired
+}
+
+func (t LivePhotoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *media_encoding.EncodeMediaData, mediaCachePath string) ([]*models.MediaURL, error) {
+	photo := mediaData.Media
+	if photo.Type != models.MediaTypePhoto {
+		return []*models.MediaURL{}, nil
+	}
+
+	videoPath := live_photo.VideoOfStill(photo.Path)
+	if videoPath == nil {
+		return []*models.MediaURL{}, nil
+	}
+
+	motionURL, err := makePhotoURLChecker(ctx.GetDB(), photo.ID)(models.MotionVideo)
+	if err != nil {
+		return []*models.MediaURL{}, errors.Wrap(err, "error processing live photo")
+	}
+
+	if motionURL != nil {
+		motionURL.Media = photo
+		if motionURL.CachedFileComplete() {
+			return []*models.MediaURL{}, nil
+		}
+	}
+
+	paired, err := live_photo.IsPair(ctx, photo.Path, *videoPath)
+	if err != nil {
+		return []*models.MediaURL{}, errors.Wrapf(err, "pair live photo with its video (%s)", photo.Path)
+	}
+	if !paired {
+		return []*models.MediaURL{}, nil
+	}
+
+	log.Info(ctx, "Processing video of live photo", "path", *videoPath)
+
+	// Videos are encoded for the web when ffmpeg is installed, as most browsers can't play the HEVC videos of iPhones
+	codec := executable_worker.WebVideoCodec()
+	contentType, extension := "video/quicktime", ".mov"
+	if executable_worker.FfmpegCli.IsInstalled() {
+		contentType, extension = codec.ContentType(), codec.Extension()
+	}
+
+	var motionPath string
+	if motionURL != nil {
+		motionPath, err = motionURL.CachedPath()
+		codec = executable_worker.VideoCodecOfContentType(motionURL.ContentType)
+	} else {
+		motionURL = newMotionVideoURL(photo, contentType, extension)
+		motionPath, err = cacheFilePath(photo, mediaCachePath, models.MotionVideo, motionURL.MediaName)
+	}
+	if err != nil {
+		return []*models.MediaURL{}, err
+	}
+
+	if motionURL.ContentType == "video/quicktime" {
+		err = copyLivePhotoVideo(ctx, *videoPath, motionPath)
+	} else {
+		var probeData *ffprobe.ProbeData
+		probeData, err = ReadVideoMetadata(*videoPath)
+		if err == nil {
+			err = encodeWebVideo(ctx, photo, *videoPath, motionPath, codec, probeData)
+		}
+	}
+	if err != nil {
+		return []*models.MediaURL{}, errors.Wrapf(err, "process video of live photo (%s)", *videoPath)
+	}
+
+	if err := saveMotionVideoURL(ctx, photo, motionURL, motionPath); err != nil {
+		return []*models.MediaURL{}, err
+	}
+
+	return []*models.MediaURL{motionURL}, nil
+}
+
+// copyLivePhotoVideo copies the video of a live photo to the cache as it is
+func copyLivePhotoVideo(ctx scanner_task.TaskContext, videoPath string, motionPath string) error {
+	videoFile, err := scanner_io.Open(ctx, videoPath)
+	if err != nil {
+		return err
+	}
+	defer videoFile.Close()
+
+	return utils.WriteFileAtomic(motionPath, func(tmpPath string) error {
+		motionFile, err := os.Create(tmpPath)
+		if err != nil {
+			return err
+		}
+		defer motionFile.Close()
+
+		if _, err := io.Copy(motionFile, videoFile); err != nil {
+			return err
+		}
+
+		return motionFile.Close()
+	})
+}
@@ -0,0 +1,165 @@
+package processing_tasks
+
+import (
+	"io"
+	"os"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_encoding"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/scanner/motion_photo"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
+	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+)
+
+// MotionPhotoTask extracts the short video embedded in the JPEG file of a motion photo to the cache,
+// so it can be played alongside the photo
+type MotionPhotoTask struct {
+	scanner_task.ScannerTaskBase
+}
+
+func (t MotionPhotoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *media_encoding.EncodeMediaData, mediaCachePath string) ([]*models.MediaURL, error) {
+	photo := mediaData.Media
+	if photo.Type != models.MediaTypePhoto {
+		return []*models.MediaURL{}, nil
+	}
+
+	contentType, err := mediaData.ContentType()
+	if err != nil {
+		return []*models.MediaURL{}, err
+	}
+	if contentType == nil || *contentType != media_type.TypeJpeg {
+		return []*models.MediaURL{}, nil
+	}
+
+	motionURL, err := makePhotoURLChecker(ctx.GetDB(), photo.ID)(models.MotionVideo)
+	if err != nil {
+		return []*models.MediaURL{}, errors.Wrap(err, "error processing motion photo")
+	}
+
+	if motionURL != nil {
+		motionURL.Media = photo
+		if motionURL.CachedFileComplete() {
+			return []*models.MediaURL{}, nil
+		}
+	} else {
+		entry, err := findCacheEntry(ctx.GetDB(), photo, models.MotionVideo)
+		if err != nil {
+			return []*models.MediaURL{}, err
+		}
+
+		if entry != nil {
+			// The video of a photo with the same content has been extracted already
+			mediaURL, err := mediaURLFromCacheEntry(ctx.GetDB(), photo, entry, generateUniqueMediaNamePrefixed("motion", photo.Path, ".mp4"))
+			if err != nil {
+				return []*models.MediaURL{}, err
+			}
+
+			return []*models.MediaURL{mediaURL}, nil
+		}
+	}
+
+	mediaURL, err := extractMotionVideo(ctx, photo, mediaCachePath, motionURL)
+	if err != nil || mediaURL == nil {
+		return []*models.MediaURL{}, err
+	}
+
+	return []*models.MediaURL{mediaURL}, nil
+}
+
+// extractMotionVideo copies the video of a motion photo to the cache, and saves its media url.
+// It returns nil if the photo is not a motion photo.
+func extractMotionVideo(ctx scanner_task.TaskContext, photo *models.Media, mediaCachePath string, mediaURL *models.MediaURL) (*models.MediaURL, error) {
+	file, err := scanner_io.Open(ctx, photo.Path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open motion photo (%s)", photo.Path)
+	}
+	defer file.Close()
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get size of motion photo (%s)", photo.Path)
+	}
+
+	video, err := motion_photo.Find(file, size)
+	if err != nil {
+		return nil, errors.Wrapf(err, "find video of motion photo (%s)", photo.Path)
+	}
+	if video == nil {
+		return nil, nil
+	}
+
+	log.Info(ctx, "Extracting video of motion photo", "path", photo.Path)
+
+	var videoPath string
+	if mediaURL != nil {
+		videoPath, err = mediaURL.CachedPath()
+	} else {
+		mediaURL = newMotionVideoURL(photo, "video/mp4", ".mp4")
+		videoPath, err = cacheFilePath(photo, mediaCachePath, models.MotionVideo, mediaURL.MediaName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = utils.WriteFileAtomic(videoPath, func(tmpPath string) error {
+		videoFile, err := os.Create(tmpPath)
+		if err != nil {
+			return err
+		}
+		defer videoFile.Close()
+
+		if _, err := io.Copy(videoFile, io.NewSectionReader(file, video.Offset, video.Length)); err != nil {
+			return err
+		}
+
+		return videoFile.Close()
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "extract video of motion photo (%s)", photo.Path)
+	}
+
+	if err := saveMotionVideoURL(ctx, photo, mediaURL, videoPath); err != nil {
+		return nil, err
+	}
+
+	return mediaURL, nil
+}
+
+// newMotionVideoURL returns the media url of the video of a motion photo, to be saved once its file has been written
+func newMotionVideoURL(photo *models.Media, contentType string, extension string) *models.MediaURL {
+	return &models.MediaURL{
+		MediaID:     photo.ID,
+		MediaName:   generateUniqueMediaNamePrefixed("motion", photo.Path, extension),
+		Purpose:     models.MotionVideo,
+		ContentType: contentType,
+	}
+}
+
+// saveMotionVideoURL saves the media url of the video of a motion photo, with the dimensions and size of its file
+func saveMotionVideoURL(ctx scanner_task.TaskContext, photo *models.Media, mediaURL *models.MediaURL, videoPath string) error {
+	// The dimensions of the video are only known when ffprobe is installed
+	if stream, err := ReadVideoStreamMetadata(videoPath); err == nil {
+		mediaURL.Width = stream.Width
+		mediaURL.Height = stream.Height
+	}
+
+	fileStats, err := os.Stat(videoPath)
+	if err != nil {
+		return errors.Wrap(err, "reading file stats of video of motion photo")
+	}
+	mediaURL.FileSize = fileStats.Size()
+
+	if err := saveCacheEntry(ctx.GetDB(), photo, mediaURL, videoPath); err != nil {
+		return err
+	}
+
+	if err := ctx.GetDB().Save(mediaURL).Error; err != nil {
+		return errors.Wrapf(err, "save video of motion photo (%s)", photo.Path)
+	}
+
+	return nil
+}
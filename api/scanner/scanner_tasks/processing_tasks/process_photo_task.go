@@ -2,11 +2,10 @@ package processing_tasks
 
 import (
 	"fmt"
-	"log"
-	"os"
 	"path"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner/media_encoding"
 	"github.com/photoview/photoview/api/scanner/media_encoding/media_utils"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
@@ -33,7 +32,7 @@ func (t ProcessPhotoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 	updatedURLs := make([]*models.MediaURL, 0)
 	photo := mediaData.Media
 
-	log.Printf("Processing photo: %s\n", photo.Path)
+	log.Info(ctx, "Processing photo", "path", photo.Path)
 
 	photoURLFromDB := makePhotoURLChecker(ctx.GetDB(), photo.ID)
 
@@ -58,6 +57,9 @@ func (t ProcessPhotoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 	var photoDimensions *media_utils.PhotoDimensions
 	var baseImagePath string = photo.Path
 
+	// Format of the thumbnails and web copies generated now, files missing from the cache are encoded in the format they were saved as
+	format := media_encoding.GeneratedImageFormat()
+
 	// Generate high res jpeg
 	if highResURL == nil {
 
@@ -67,10 +69,24 @@ func (t ProcessPhotoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 		}
 
 		if !contentType.IsWebCompatible() {
-			highresName := generateUniqueMediaNamePrefixed("highres", photo.Path, ".jpg")
-			baseImagePath = path.Join(mediaCachePath, highresName)
+			highresName := generateUniqueMediaNamePrefixed("highres", photo.Path, format.Extension())
+
+			entry, err := findCacheEntry(ctx.GetDB(), photo, models.PhotoHighRes)
+			if err != nil {
+				return []*models.MediaURL{}, err
+			}
 
-			highRes, err := generateSaveHighResJPEG(ctx.GetDB(), photo, mediaData, highresName, baseImagePath, nil)
+			var highRes *models.MediaURL
+			if entry != nil {
+				// Media with the same content has been processed already
+				baseImagePath = entry.FilePath()
+				if highRes, err = mediaURLFromCacheEntry(ctx.GetDB(), photo, entry, highresName); err == nil {
+					photoDimensions, err = originalDimensionsOfContent(ctx.GetDB(), photo)
+				}
+			} else if baseImagePath, err = cacheFilePath(photo, mediaCachePath, models.PhotoHighRes, highresName); err == nil {
+				// The web version may be scaled down, the dimensions of the original are those of the photo before
+				highRes, photoDimensions, err = generateSaveHighResJPEG(ctx.GetDB(), photo, mediaData, highresName, baseImagePath, format, nil)
+			}
 			if err != nil {
 				return []*models.MediaURL{}, err
 			}
@@ -79,16 +95,22 @@ func (t ProcessPhotoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 		}
 	} else {
 		// Verify that highres photo still exists in cache
-		baseImagePath = path.Join(mediaCachePath, highResURL.MediaName)
+		highResURL.Media = photo
+		baseImagePath, err = highResURL.CachedPath()
+		if err != nil {
+			return []*models.MediaURL{}, err
+		}
 
-		if _, err := os.Stat(baseImagePath); os.IsNotExist(err) {
+		if !highResURL.CachedFileComplete() {
 			fmt.Printf("High-res photo found in database but not in cache, re-encoding photo to cache: %s\n", highResURL.MediaName)
-			updatedURLs = append(updatedURLs, highResURL)
 
-			err = mediaData.EncodeHighRes(baseImagePath)
+			highRes, _, err := generateSaveHighResJPEG(ctx.GetDB(), photo, mediaData, highResURL.MediaName, baseImagePath,
+				media_encoding.ImageFormatOfContentType(highResURL.ContentType), highResURL)
 			if err != nil {
-				return []*models.MediaURL{}, errors.Wrap(err, "creating high-res cached image")
+				return []*models.MediaURL{}, err
 			}
+
+			updatedURLs = append(updatedURLs, highRes)
 		}
 	}
 
@@ -113,8 +135,19 @@ func (t ProcessPhotoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 
 	// Save thumbnail to cache
 	if thumbURL == nil {
-		thumbnailName := generateUniqueMediaNamePrefixed("thumbnail", photo.Path, ".jpg")
-		thumbnail, err := generateSaveThumbnailJPEG(ctx.GetDB(), photo, thumbnailName, mediaCachePath, baseImagePath, nil)
+		thumbnailName := generateUniqueMediaNamePrefixed("thumbnail", photo.Path, format.Extension())
+
+		entry, err := findCacheEntry(ctx.GetDB(), photo, models.PhotoThumbnail)
+		if err != nil {
+			return []*models.MediaURL{}, err
+		}
+
+		var thumbnail *models.MediaURL
+		if entry != nil {
+			thumbnail, err = mediaURLFromCacheEntry(ctx.GetDB(), photo, entry, thumbnailName)
+		} else {
+			thumbnail, err = generateSaveThumbnailJPEG(ctx.GetDB(), photo, thumbnailName, mediaCachePath, baseImagePath, format, nil)
+		}
 		if err != nil {
 			return []*models.MediaURL{}, err
 		}
@@ -122,18 +155,38 @@ func (t ProcessPhotoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 		updatedURLs = append(updatedURLs, thumbnail)
 	} else {
 		// Verify that thumbnail photo still exists in cache
-		thumbPath := path.Join(mediaCachePath, thumbURL.MediaName)
+		thumbURL.Media = photo
+		thumbPath, err := thumbURL.CachedPath()
+		if err != nil {
+			return []*models.MediaURL{}, err
+		}
 
-		if _, err := os.Stat(thumbPath); os.IsNotExist(err) {
-			updatedURLs = append(updatedURLs, thumbURL)
+		if !thumbURL.CachedFileComplete() {
 			fmt.Printf("Thumbnail photo found in database but not in cache, re-encoding photo to cache: %s\n", thumbURL.MediaName)
 
-			_, err := media_encoding.EncodeThumbnail(ctx.GetDB(), baseImagePath, thumbPath)
+			thumbnail, err := generateSaveThumbnailJPEG(ctx.GetDB(), photo, thumbURL.MediaName, path.Dir(thumbPath), baseImagePath,
+				media_encoding.ImageFormatOfContentType(thumbURL.ContentType), thumbURL)
 			if err != nil {
-				return []*models.MediaURL{}, errors.Wrap(err, "could not create thumbnail cached image")
+				return []*models.MediaURL{}, err
 			}
+
+			updatedURLs = append(updatedURLs, thumbnail)
 		}
 	}
 
+	// Scaled copies of the configured sizes
+	scaledURLs, err := processScaledPhotos(ctx.GetDB(), photo, mediaCachePath, baseImagePath, format)
+	if err != nil {
+		return []*models.MediaURL{}, err
+	}
+	updatedURLs = append(updatedURLs, scaledURLs...)
+
+	// AVIF variants of the generated images, served to clients that accept them
+	avifURLs, err := processAVIFVariants(ctx.GetDB(), photo, mediaCachePath)
+	if err != nil {
+		return []*models.MediaURL{}, err
+	}
+	updatedURLs = append(updatedURLs, avifURLs...)
+
 	return updatedURLs, nil
 }
@@ -3,16 +3,19 @@ package processing_tasks
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"path"
 	"strings"
 	"time"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/graphql/notification"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner/media_encoding"
 	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
 	"github.com/photoview/photoview/api/scanner/media_encoding/media_utils"
+	"github.com/photoview/photoview/api/scanner/scan_profile"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
 	"github.com/photoview/photoview/api/utils"
 	"github.com/pkg/errors"
@@ -31,7 +34,7 @@ func (t ProcessVideoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 	updatedURLs := make([]*models.MediaURL, 0)
 	video := mediaData.Media
 
-	log.Printf("Processing video: %s", video.Path)
+	log.Info(ctx, "Processing video", "path", video.Path)
 
 	mediaURLFromDB := makePhotoURLChecker(ctx.GetDB(), video.ID)
 
@@ -50,11 +53,21 @@ func (t ProcessVideoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 		return []*models.MediaURL{}, errors.Wrap(err, "error processing video thumbnail")
 	}
 
+	videoSpriteURL, err := mediaURLFromDB(models.VideoSprite)
+	if err != nil {
+		return []*models.MediaURL{}, errors.Wrap(err, "error processing video sprite")
+	}
+
 	videoType, err := mediaData.ContentType()
 	if err != nil {
 		return []*models.MediaURL{}, errors.Wrap(err, "error getting video content type")
 	}
 
+	probeData, err := mediaData.VideoMetadata()
+	if err != nil {
+		return []*models.MediaURL{}, err
+	}
+
 	if videoOriginalURL == nil && videoType.IsWebCompatible() {
 		origVideoPath := video.Path
 		videoMediaName := generateUniqueMediaName(video.Path)
@@ -86,49 +99,99 @@ func (t ProcessVideoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 		updatedURLs = append(updatedURLs, &mediaURL)
 	}
 
-	if videoWebURL == nil && !videoType.IsWebCompatible() {
+	if videoWebURL == nil && (!videoType.IsWebCompatible() || !isWebPlayableStream(probeData)) {
+		codec := executable_worker.WebVideoCodec()
+
 		web_video_name := fmt.Sprintf("web_video_%s_%s", path.Base(video.Path), utils.GenerateToken())
 		web_video_name = strings.ReplaceAll(web_video_name, ".", "_")
 		web_video_name = strings.ReplaceAll(web_video_name, " ", "_")
-		web_video_name = web_video_name + ".mp4"
-
-		webVideoPath := path.Join(mediaCachePath, web_video_name)
+		web_video_name = web_video_name + codec.Extension()
 
-		err = executable_worker.FfmpegCli.EncodeMp4(video.Path, webVideoPath)
+		entry, err := findCacheEntry(ctx.GetDB(), video, models.VideoWeb)
 		if err != nil {
-			return []*models.MediaURL{}, errors.Wrapf(err, "could not encode mp4 video (%s)", video.Path)
+			return []*models.MediaURL{}, err
 		}
 
-		webMetadata, err := ReadVideoStreamMetadata(webVideoPath)
-		if err != nil {
-			return []*models.MediaURL{}, errors.Wrapf(err, "failed to read metadata for encoded web-video (%s)", video.Title)
-		}
+		if entry != nil {
+			// Media with the same content has been encoded already
+			mediaURL, err := mediaURLFromCacheEntry(ctx.GetDB(), video, entry, web_video_name)
+			if err != nil {
+				return []*models.MediaURL{}, err
+			}
+
+			updatedURLs = append(updatedURLs, mediaURL)
+		} else {
+			webVideoPath, err := cacheFilePath(video, mediaCachePath, models.VideoWeb, web_video_name)
+			if err != nil {
+				return []*models.MediaURL{}, err
+			}
+
+			if err := encodeWebVideo(ctx, video, video.Path, webVideoPath, codec, probeData); err != nil {
+				return []*models.MediaURL{}, err
+			}
+
+			webMetadata, err := ReadVideoStreamMetadata(webVideoPath)
+			if err != nil {
+				return []*models.MediaURL{}, errors.Wrapf(err, "failed to read metadata for encoded web-video (%s)", video.Title)
+			}
+
+			fileStats, err := os.Stat(webVideoPath)
+			if err != nil {
+				return []*models.MediaURL{}, errors.Wrap(err, "reading file stats of web-optimized video")
+			}
+
+			mediaURL := models.MediaURL{
+				MediaID:     video.ID,
+				MediaName:   web_video_name,
+				Width:       webMetadata.Width,
+				Height:      webMetadata.Height,
+				Purpose:     models.VideoWeb,
+				ContentType: codec.ContentType(),
+				FileSize:    fileStats.Size(),
+			}
+
+			if err := saveCacheEntry(ctx.GetDB(), video, &mediaURL, webVideoPath); err != nil {
+				return []*models.MediaURL{}, err
+			}
 
-		fileStats, err := os.Stat(webVideoPath)
+			if err := ctx.GetDB().Create(&mediaURL).Error; err != nil {
+				return []*models.MediaURL{}, errors.Wrapf(err, "failed to insert encoded web-video into database (%s)", video.Title)
+			}
+
+			updatedURLs = append(updatedURLs, &mediaURL)
+		}
+	} else if videoWebURL != nil {
+		// Verify that web video still exists in cache
+		videoWebURL.Media = video
+		webVideoPath, err := videoWebURL.CachedPath()
 		if err != nil {
-			return []*models.MediaURL{}, errors.Wrap(err, "reading file stats of web-optimized video")
+			return []*models.MediaURL{}, err
 		}
 
-		mediaURL := models.MediaURL{
-			MediaID:     video.ID,
-			MediaName:   web_video_name,
-			Width:       webMetadata.Width,
-			Height:      webMetadata.Height,
-			Purpose:     models.VideoWeb,
-			ContentType: "video/mp4",
-			FileSize:    fileStats.Size(),
-		}
+		if !videoWebURL.CachedFileComplete() {
+			fmt.Printf("Web video found in database but not in cache, re-encoding video to cache: %s\n", videoWebURL.MediaName)
+			updatedURLs = append(updatedURLs, videoWebURL)
 
-		if err := ctx.GetDB().Create(&mediaURL).Error; err != nil {
-			return []*models.MediaURL{}, errors.Wrapf(err, "failed to insert encoded web-video into database (%s)", video.Title)
-		}
+			codec := executable_worker.VideoCodecOfContentType(videoWebURL.ContentType)
+			if err := encodeWebVideo(ctx, video, video.Path, webVideoPath, codec, probeData); err != nil {
+				return []*models.MediaURL{}, err
+			}
 
-		updatedURLs = append(updatedURLs, &mediaURL)
-	}
+			fileStats, err := os.Stat(webVideoPath)
+			if err != nil {
+				return []*models.MediaURL{}, errors.Wrap(err, "reading file stats of web-optimized video")
+			}
 
-	probeData, err := mediaData.VideoMetadata()
-	if err != nil {
-		return []*models.MediaURL{}, err
+			videoWebURL.FileSize = fileStats.Size()
+
+			if err := saveCacheEntry(ctx.GetDB(), video, videoWebURL, webVideoPath); err != nil {
+				return []*models.MediaURL{}, err
+			}
+
+			if err := ctx.GetDB().Save(videoWebURL).Error; err != nil {
+				return []*models.MediaURL{}, errors.Wrap(err, "updating web video url in database after re-encoding")
+			}
+		}
 	}
 
 	if videoThumbnailURL == nil {
@@ -137,47 +200,72 @@ func (t ProcessVideoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 		video_thumb_name = strings.ReplaceAll(video_thumb_name, " ", "_")
 		video_thumb_name = video_thumb_name + ".jpg"
 
-		thumbImagePath := path.Join(mediaCachePath, video_thumb_name)
-
-		err = executable_worker.FfmpegCli.EncodeVideoThumbnail(video.Path, thumbImagePath, probeData)
+		entry, err := findCacheEntry(ctx.GetDB(), video, models.VideoThumbnail)
 		if err != nil {
-			return []*models.MediaURL{}, errors.Wrapf(err, "failed to generate thumbnail for video (%s)", video.Title)
+			return []*models.MediaURL{}, err
 		}
 
-		thumbDimensions, err := media_utils.GetPhotoDimensions(thumbImagePath)
-		if err != nil {
-			return []*models.MediaURL{}, errors.Wrap(err, "get dimensions of video thumbnail image")
-		}
+		if entry != nil {
+			thumbMediaURL, err := mediaURLFromCacheEntry(ctx.GetDB(), video, entry, video_thumb_name)
+			if err != nil {
+				return []*models.MediaURL{}, err
+			}
 
-		fileStats, err := os.Stat(thumbImagePath)
-		if err != nil {
-			return []*models.MediaURL{}, errors.Wrap(err, "reading file stats of video thumbnail")
-		}
+			updatedURLs = append(updatedURLs, thumbMediaURL)
+		} else {
+			thumbImagePath, err := cacheFilePath(video, mediaCachePath, models.VideoThumbnail, video_thumb_name)
+			if err != nil {
+				return []*models.MediaURL{}, err
+			}
 
-		thumbMediaURL := models.MediaURL{
-			MediaID:     video.ID,
-			MediaName:   video_thumb_name,
-			Width:       thumbDimensions.Width,
-			Height:      thumbDimensions.Height,
-			Purpose:     models.VideoThumbnail,
-			ContentType: "image/jpeg",
-			FileSize:    fileStats.Size(),
-		}
+			err = encodeVideoThumbnail(ctx, video.Path, thumbImagePath, probeData)
+			if err != nil {
+				return []*models.MediaURL{}, errors.Wrapf(err, "failed to generate thumbnail for video (%s)", video.Title)
+			}
 
-		if err := ctx.GetDB().Create(&thumbMediaURL).Error; err != nil {
-			return []*models.MediaURL{}, errors.Wrapf(err, "failed to insert video thumbnail image into database (%s)", video.Title)
-		}
+			thumbDimensions, err := media_utils.GetPhotoDimensions(thumbImagePath)
+			if err != nil {
+				return []*models.MediaURL{}, errors.Wrap(err, "get dimensions of video thumbnail image")
+			}
+
+			fileStats, err := os.Stat(thumbImagePath)
+			if err != nil {
+				return []*models.MediaURL{}, errors.Wrap(err, "reading file stats of video thumbnail")
+			}
+
+			thumbMediaURL := models.MediaURL{
+				MediaID:     video.ID,
+				MediaName:   video_thumb_name,
+				Width:       thumbDimensions.Width,
+				Height:      thumbDimensions.Height,
+				Purpose:     models.VideoThumbnail,
+				ContentType: "image/jpeg",
+				FileSize:    fileStats.Size(),
+			}
+
+			if err := saveCacheEntry(ctx.GetDB(), video, &thumbMediaURL, thumbImagePath); err != nil {
+				return []*models.MediaURL{}, err
+			}
 
-		updatedURLs = append(updatedURLs, &thumbMediaURL)
+			if err := ctx.GetDB().Create(&thumbMediaURL).Error; err != nil {
+				return []*models.MediaURL{}, errors.Wrapf(err, "failed to insert video thumbnail image into database (%s)", video.Title)
+			}
+
+			updatedURLs = append(updatedURLs, &thumbMediaURL)
+		}
 	} else {
 		// Verify that video thumbnail still exists in cache
-		thumbImagePath := path.Join(mediaCachePath, videoThumbnailURL.MediaName)
+		videoThumbnailURL.Media = video
+		thumbImagePath, err := videoThumbnailURL.CachedPath()
+		if err != nil {
+			return []*models.MediaURL{}, err
+		}
 
-		if _, err := os.Stat(thumbImagePath); os.IsNotExist(err) {
+		if !videoThumbnailURL.CachedFileComplete() {
 			fmt.Printf("Video thumbnail found in database but not in cache, re-encoding photo to cache: %s\n", videoThumbnailURL.MediaName)
 			updatedURLs = append(updatedURLs, videoThumbnailURL)
 
-			err = executable_worker.FfmpegCli.EncodeVideoThumbnail(video.Path, thumbImagePath, probeData)
+			err = encodeVideoThumbnail(ctx, video.Path, thumbImagePath, probeData)
 			if err != nil {
 				return []*models.MediaURL{}, errors.Wrapf(err, "failed to generate thumbnail for video (%s)", video.Title)
 			}
@@ -196,15 +284,94 @@ func (t ProcessVideoTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *
 			videoThumbnailURL.Height = thumbDimensions.Height
 			videoThumbnailURL.FileSize = fileStats.Size()
 
+			if err := saveCacheEntry(ctx.GetDB(), video, videoThumbnailURL, thumbImagePath); err != nil {
+				return []*models.MediaURL{}, err
+			}
+
 			if err := ctx.GetDB().Save(videoThumbnailURL).Error; err != nil {
 				return []*models.MediaURL{}, errors.Wrap(err, "updating video thumbnail url in database after re-encoding")
 			}
 		}
 	}
 
+	spriteURL, err := processVideoSprite(ctx, video, mediaCachePath, probeData, videoSpriteURL)
+	if err != nil {
+		return []*models.MediaURL{}, err
+	}
+	if spriteURL != nil {
+		updatedURLs = append(updatedURLs, spriteURL)
+	}
+
 	return updatedURLs, nil
 }
 
+// isWebPlayableStream returns whether browsers can play the video stream of a video in a web compatible container.
+// Most browsers can't play HEVC videos, nor videos with 10-bit colors.
+func isWebPlayableStream(probeData *ffprobe.ProbeData) bool {
+	stream := probeData.FirstVideoStream()
+	if stream == nil {
+		return true
+	}
+
+	switch stream.CodecName {
+	case "h264", "vp8", "vp9", "av1", "theora":
+	default:
+		return false
+	}
+
+	switch stream.PixFmt {
+	case "", "yuv420p", "yuvj420p":
+		return true
+	}
+
+	return false
+}
+
+// encodeWebVideo transcodes the video at videoPath, belonging to the given media, to a web video,
+// broadcasting the progress of the encoding as a notification
+func encodeWebVideo(ctx scanner_task.TaskContext, video *models.Media, videoPath string, webVideoPath string, codec executable_worker.VideoCodec, probeData *ffprobe.ProbeData) error {
+	notificationKey := fmt.Sprintf("video-transcode-%d", video.ID)
+	throttle := utils.NewThrottle(500 * time.Millisecond)
+
+	endEncode := scan_profile.Start(scan_profile.StageVideoEncoding)
+	err := executable_worker.FfmpegCli.EncodeWebVideo(ctx, videoPath, webVideoPath, codec, probeData.Format.DurationSeconds, func(progress float64) {
+		throttle.Trigger(func() {
+			percent := progress * 100.0
+			notification.BroadcastNotification(&models.Notification{
+				Key:      notificationKey,
+				Type:     models.NotificationTypeProgress,
+				Header:   fmt.Sprintf("Transcoding video '%s'", video.Title),
+				Content:  fmt.Sprintf("Encoding a web version of %s", videoPath),
+				Progress: &percent,
+			})
+		})
+	})
+	endEncode()
+
+	notification.BroadcastNotification(&models.Notification{
+		Key:  notificationKey,
+		Type: models.NotificationTypeClose,
+	})
+
+	if err != nil {
+		return errors.Wrapf(err, "could not encode web video (%s)", videoPath)
+	}
+
+	return nil
+}
+
+// encodeVideoThumbnail generates the thumbnail of a video, once fewer thumbnails than the maximum number of thumbnail jobs are being generated
+func encodeVideoThumbnail(ctx scanner_task.TaskContext, videoPath string, thumbImagePath string, probeData *ffprobe.ProbeData) error {
+	release, err := scanner_io.AcquireThumbnailJob(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer scan_profile.Start(scan_profile.StageThumbnails)()
+
+	return executable_worker.FfmpegCli.EncodeVideoThumbnail(ctx, videoPath, thumbImagePath, probeData)
+}
+
 func ReadVideoMetadata(videoPath string) (*ffprobe.ProbeData, error) {
 	ctx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelFn()
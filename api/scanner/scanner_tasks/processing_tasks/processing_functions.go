@@ -2,30 +2,48 @@ package processing_tasks
 
 import (
 	"os"
-	"path"
 
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/scanner/media_encoding"
 	"github.com/photoview/photoview/api/scanner/media_encoding/media_utils"
+	"github.com/photoview/photoview/api/scanner/scan_profile"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
 
-func generateSaveHighResJPEG(tx *gorm.DB, media *models.Media, imageData *media_encoding.EncodeMediaData, highres_name string, imagePath string, mediaURL *models.MediaURL) (*models.MediaURL, error) {
+// generateSaveHighResJPEG encodes the web version of a photo browsers can't display, scaled down to the configured size,
+// and saves its media url. It also returns the dimensions of the photo before it was scaled down.
+func generateSaveHighResJPEG(tx *gorm.DB, media *models.Media, imageData *media_encoding.EncodeMediaData, highres_name string, imagePath string, format media_encoding.ImageFormat, mediaURL *models.MediaURL) (*models.MediaURL, *media_utils.PhotoDimensions, error) {
 
-	err := imageData.EncodeHighRes(imagePath)
+	release, err := scanner_io.AcquireThumbnailJob(tx.Statement.Context)
 	if err != nil {
-		return nil, errors.Wrap(err, "creating high-res cached image")
+		return nil, nil, err
+	}
+	endEncode := scan_profile.Start(scan_profile.StageThumbnails)
+	err = imageData.EncodeHighRes(tx.Statement.Context, imagePath, format)
+	endEncode()
+	release()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "creating high-res cached image")
 	}
 
-	photoDimensions, err := media_utils.GetPhotoDimensions(imagePath)
+	originalDimensions, err := media_utils.GetPhotoDimensions(imagePath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	photoDimensions := originalDimensions
+	if maxSize := media_utils.WebVersionMaxSize(); maxSize > 0 && (photoDimensions.Width > maxSize || photoDimensions.Height > maxSize) {
+		photoDimensions, err = media_encoding.ScaleWebVersion(tx.Statement.Context, imagePath, format, maxSize)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "scaling down high-res cached image")
+		}
 	}
 
 	fileStats, err := os.Stat(imagePath)
 	if err != nil {
-		return nil, errors.Wrap(err, "reading file stats of highres photo")
+		return nil, nil, errors.Wrap(err, "reading file stats of highres photo")
 	}
 
 	if mediaURL == nil {
@@ -36,30 +54,54 @@ func generateSaveHighResJPEG(tx *gorm.DB, media *models.Media, imageData *media_
 			Width:       photoDimensions.Width,
 			Height:      photoDimensions.Height,
 			Purpose:     models.PhotoHighRes,
-			ContentType: "image/jpeg",
+			ContentType: format.ContentType(),
 			FileSize:    fileStats.Size(),
 		}
 
+		if err := saveCacheEntry(tx, media, mediaURL, imagePath); err != nil {
+			return nil, nil, err
+		}
+
 		if err := tx.Create(&mediaURL).Error; err != nil {
-			return nil, errors.Wrapf(err, "could not insert highres media url (%d, %s)", media.ID, highres_name)
+			return nil, nil, errors.Wrapf(err, "could not insert highres media url (%d, %s)", media.ID, highres_name)
 		}
 	} else {
 		mediaURL.Width = photoDimensions.Width
 		mediaURL.Height = photoDimensions.Height
 		mediaURL.FileSize = fileStats.Size()
 
+		if err := saveCacheEntry(tx, media, mediaURL, imagePath); err != nil {
+			return nil, nil, err
+		}
+
 		if err := tx.Save(&mediaURL).Error; err != nil {
-			return nil, errors.Wrapf(err, "could not update media url after side car changes (%d, %s)", media.ID, highres_name)
+			return nil, nil, errors.Wrapf(err, "could not update media url after side car changes (%d, %s)", media.ID, highres_name)
 		}
 	}
 
-	return mediaURL, nil
+	return mediaURL, originalDimensions, nil
 }
 
-func generateSaveThumbnailJPEG(tx *gorm.DB, media *models.Media, thumbnail_name string, photoCachePath string, baseImagePath string, mediaURL *models.MediaURL) (*models.MediaURL, error) {
-	thumbOutputPath := path.Join(photoCachePath, thumbnail_name)
+func generateSaveThumbnailJPEG(tx *gorm.DB, media *models.Media, thumbnail_name string, photoCachePath string, baseImagePath string, format media_encoding.ImageFormat, mediaURL *models.MediaURL) (*models.MediaURL, error) {
+	return generateSaveScaledPhoto(tx, media, models.PhotoThumbnail, media_utils.ThumbnailSize(), thumbnail_name, photoCachePath, baseImagePath, format, mediaURL)
+}
 
-	thumbSize, err := media_encoding.EncodeThumbnail(tx, baseImagePath, thumbOutputPath)
+// generateSaveScaledPhoto encodes a copy of the photo scaled down to the given size in the given format,
+// of the thumbnail or a scaled copy, and saves its media url
+func generateSaveScaledPhoto(tx *gorm.DB, media *models.Media, purpose models.MediaPurpose, size int, thumbnail_name string, photoCachePath string, baseImagePath string, format media_encoding.ImageFormat, mediaURL *models.MediaURL) (*models.MediaURL, error) {
+	thumbOutputPath, err := cacheFilePath(media, photoCachePath, purpose, thumbnail_name)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := scanner_io.AcquireThumbnailJob(tx.Statement.Context)
+	if err != nil {
+		return nil, err
+	}
+	endEncode := scan_profile.Start(scan_profile.StageThumbnails)
+	thumbSize, err := media_encoding.EncodeScaledPhoto(tx, baseImagePath, thumbOutputPath, size, format)
+	endEncode()
+	release()
 	if err != nil {
 		return nil, errors.Wrap(err, "could not create thumbnail cached image")
 	}
@@ -76,11 +118,15 @@ func generateSaveThumbnailJPEG(tx *gorm.DB, media *models.Media, thumbnail_name
 			MediaName:   thumbnail_name,
 			Width:       thumbSize.Width,
 			Height:      thumbSize.Height,
-			Purpose:     models.PhotoThumbnail,
-			ContentType: "image/jpeg",
+			Purpose:     purpose,
+			ContentType: format.ContentType(),
 			FileSize:    fileStats.Size(),
 		}
 
+		if err := saveCacheEntry(tx, media, mediaURL, thumbOutputPath); err != nil {
+			return nil, err
+		}
+
 		if err := tx.Create(&mediaURL).Error; err != nil {
 			return nil, errors.Wrapf(err, "could not insert thumbnail media url (%d, %s)", media.ID, thumbnail_name)
 		}
@@ -89,6 +135,10 @@ func generateSaveThumbnailJPEG(tx *gorm.DB, media *models.Media, thumbnail_name
 		mediaURL.Height = thumbSize.Height
 		mediaURL.FileSize = fileStats.Size()
 
+		if err := saveCacheEntry(tx, media, mediaURL, thumbOutputPath); err != nil {
+			return nil, err
+		}
+
 		if err := tx.Save(&mediaURL).Error; err != nil {
 			return nil, errors.Wrapf(err, "could not update media url after side car changes (%d, %s)", media.ID, thumbnail_name)
 		}
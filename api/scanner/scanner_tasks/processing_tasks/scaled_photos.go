@@ -0,0 +1,174 @@
+package processing_tasks
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/media_encoding"
+	"github.com/photoview/photoview/api/scanner/media_encoding/media_utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// scaledPhotoSizes returns the configured sizes of the scaled copies of a photo of the given dimensions,
+// which are the sizes smaller than the photo, apart from the size of its thumbnail
+func scaledPhotoSizes(width, height int) []int {
+	sizes := make([]int, 0)
+	for _, size := range models.ScaledPhotoSizes() {
+		if size != media_utils.ThumbnailSize() && (size < width || size < height) {
+			sizes = append(sizes, size)
+		}
+	}
+
+	return sizes
+}
+
+// ScaledPhotosChanged returns whether the scaled copies of a processed photo differ from the configured sizes,
+// such as after the configuration has changed
+func ScaledPhotosChanged(tx *gorm.DB, photo *models.Media) (bool, error) {
+	var urls []*models.MediaURL
+	err := tx.Select("purpose", "width", "height").
+		Where("media_id = ? AND (purpose IN (?) OR purpose LIKE ?)", photo.ID,
+			[]models.MediaPurpose{models.MediaOriginal, models.PhotoHighRes}, models.ScaledPhotoPrefix+"%").
+		Find(&urls).Error
+	if err != nil {
+		return false, errors.Wrapf(err, "get scaled copies of photo (%s)", photo.Path)
+	}
+
+	width, height, scaled := 0, 0, make(map[models.MediaPurpose]bool)
+	for _, url := range urls {
+		if _, isScaled := url.Purpose.ScaledSize(); isScaled {
+			scaled[url.Purpose] = true
+		} else if url.Width*url.Height > width*height {
+			width, height = url.Width, url.Height
+		}
+	}
+
+	// Photos without an original have not been processed yet
+	if width == 0 {
+		return false, nil
+	}
+
+	sizes := scaledPhotoSizes(width, height)
+	if len(sizes) != len(scaled) {
+		return true, nil
+	}
+	for _, size := range sizes {
+		if !scaled[models.ScaledPhotoPurpose(size)] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// scaledPhotoURLs returns the media urls of the scaled copies of a photo, of any size
+func scaledPhotoURLs(tx *gorm.DB, photo *models.Media) ([]*models.MediaURL, error) {
+	var urls []*models.MediaURL
+	if err := tx.Where("media_id = ? AND purpose LIKE ?", photo.ID, models.ScaledPhotoPrefix+"%").Find(&urls).Error; err != nil {
+		return nil, errors.Wrapf(err, "get scaled copies of photo (%s)", photo.Path)
+	}
+
+	for _, url := range urls {
+		url.Media = photo
+	}
+
+	return urls, nil
+}
+
+// removeGeneratedPhoto deletes the media url of a scaled copy or AVIF variant of a photo. Files in the content addressed part of the cache
+// are left for other media with the same content, until they are collected as garbage.
+func removeGeneratedPhoto(tx *gorm.DB, url *models.MediaURL) error {
+	if url.CacheKey == nil {
+		if cachedPath, err := url.CachedPath(); err == nil {
+			os.Remove(cachedPath)
+		}
+	}
+
+	if err := tx.Delete(url).Error; err != nil {
+		return errors.Wrapf(err, "delete generated copy of photo (%s)", url.MediaName)
+	}
+
+	return nil
+}
+
+// processScaledPhotos generates the scaled copies of the configured sizes of a photo, that are smaller than the photo
+// and than its thumbnail, in the given format, and removes its copies of sizes that are no longer configured
+func processScaledPhotos(tx *gorm.DB, photo *models.Media, mediaCachePath string, baseImagePath string, format media_encoding.ImageFormat) ([]*models.MediaURL, error) {
+	existing, err := scaledPhotoURLs(tx, photo)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[models.MediaPurpose]int)
+	wantedSizes := make([]int, 0)
+	if len(models.ScaledPhotoSizes()) > 0 {
+		dimensions, err := media_utils.GetPhotoDimensions(baseImagePath)
+		if err != nil {
+			return nil, err
+		}
+
+		wantedSizes = scaledPhotoSizes(dimensions.Width, dimensions.Height)
+		for _, size := range wantedSizes {
+			wanted[models.ScaledPhotoPurpose(size)] = size
+		}
+	}
+
+	updatedURLs := make([]*models.MediaURL, 0)
+	for _, url := range existing {
+		size, found := wanted[url.Purpose]
+		if !found {
+			if err := removeGeneratedPhoto(tx, url); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		delete(wanted, url.Purpose)
+
+		if url.CachedFileComplete() {
+			continue
+		}
+
+		cachedPath, err := url.CachedPath()
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Printf("Scaled photo found in database but not in cache, re-encoding photo to cache: %s\n", url.MediaName)
+		scaled, err := generateSaveScaledPhoto(tx, photo, url.Purpose, size, url.MediaName, path.Dir(cachedPath), baseImagePath,
+			media_encoding.ImageFormatOfContentType(url.ContentType), url)
+		if err != nil {
+			return nil, err
+		}
+		updatedURLs = append(updatedURLs, scaled)
+	}
+
+	for _, size := range wantedSizes {
+		purpose := models.ScaledPhotoPurpose(size)
+		if _, missing := wanted[purpose]; !missing {
+			continue
+		}
+
+		scaledName := generateUniqueMediaNamePrefixed(string(purpose), photo.Path, format.Extension())
+
+		entry, err := findCacheEntry(tx, photo, purpose)
+		if err != nil {
+			return nil, err
+		}
+
+		var scaled *models.MediaURL
+		if entry != nil {
+			scaled, err = mediaURLFromCacheEntry(tx, photo, entry, scaledName)
+		} else {
+			scaled, err = generateSaveScaledPhoto(tx, photo, purpose, size, scaledName, mediaCachePath, baseImagePath, format, nil)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "error processing scaled photo of %d pixels", size)
+		}
+		updatedURLs = append(updatedURLs, scaled)
+	}
+
+	return updatedURLs, nil
+}
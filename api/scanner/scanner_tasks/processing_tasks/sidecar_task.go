@@ -1,15 +1,15 @@
 package processing_tasks
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
 	"os"
-	"path"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner/media_encoding"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
 	"github.com/photoview/photoview/api/scanner/scanner_utils"
@@ -39,7 +39,7 @@ func (t SidecarTask) AfterMediaFound(ctx scanner_task.TaskContext, media *models
 
 	sideCarPath = scanForSideCarFile(media.Path)
 	if sideCarPath != nil {
-		sideCarHash = hashSideCarFile(sideCarPath)
+		sideCarHash = hashSideCarFile(ctx, sideCarPath)
 	}
 
 	// Add sidecar data to media
@@ -69,7 +69,7 @@ func (t SidecarTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *media
 	currentSideCarPath := scanForSideCarFile(photo.Path)
 
 	if currentSideCarPath != nil {
-		currentFileHash = hashSideCarFile(currentSideCarPath)
+		currentFileHash = hashSideCarFile(ctx, currentSideCarPath)
 		if photo.SideCarHash == nil || *photo.SideCarHash != *currentFileHash {
 			sideCarFileHasChanged = true
 		}
@@ -93,11 +93,35 @@ func (t SidecarTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *media
 		return []*models.MediaURL{}, errors.Wrap(err, "sidecar task, get high-res media_url")
 	}
 
+	photo.SideCarHash = currentFileHash
+	photo.SideCarPath = currentSideCarPath
+
+	// The blurhash is generated again from the new thumbnail after the scan
+	photo.Blurhash = nil
+
+	// The sidecar is part of the content of the media, so the images are generated in the cache directory of the new content,
+	// and the previous images are left for other media with the same content, until they are collected as garbage
+	if photo.ContentHash != nil {
+		contentHash, err := hashMediaContent(ctx, photo.Path, photo.SideCarPath)
+		if err != nil {
+			return []*models.MediaURL{}, errors.Wrap(err, "sidecar task, hash content of media")
+		}
+		photo.ContentHash = &contentHash
+
+		if mediaCachePath, err = photo.CachePath(); err != nil {
+			return []*models.MediaURL{}, errors.Wrap(err, "sidecar task, cache directory of media")
+		}
+	}
+
 	// update high res image may be cropped so dimentions and file size can change
-	baseImagePath := path.Join(mediaCachePath, highResURL.MediaName) // update base image path for thumbnail
+	baseImagePath, err := cacheFilePath(photo, mediaCachePath, models.PhotoHighRes, highResURL.MediaName) // update base image path for thumbnail
+	if err != nil {
+		return []*models.MediaURL{}, errors.Wrap(err, "sidecar task, cache path of high-res image")
+	}
 	tempHighResPath := baseImagePath + ".hold"
 	os.Rename(baseImagePath, tempHighResPath)
-	updatedHighRes, err := generateSaveHighResJPEG(ctx.GetDB(), photo, mediaData, highResURL.MediaName, baseImagePath, highResURL)
+	updatedHighRes, _, err := generateSaveHighResJPEG(ctx.GetDB(), photo, mediaData, highResURL.MediaName, baseImagePath,
+		media_encoding.ImageFormatOfContentType(highResURL.ContentType), highResURL)
 	if err != nil {
 		os.Rename(tempHighResPath, baseImagePath)
 		return []*models.MediaURL{}, errors.Wrap(err, "sidecar task, recreating high-res cached image")
@@ -105,18 +129,33 @@ func (t SidecarTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *media
 	os.Remove(tempHighResPath)
 
 	// update thumbnail image may be cropped so dimentions and file size can change
-	thumbPath := path.Join(mediaCachePath, thumbURL.MediaName)
+	thumbPath, err := cacheFilePath(photo, mediaCachePath, models.PhotoThumbnail, thumbURL.MediaName)
+	if err != nil {
+		return []*models.MediaURL{}, errors.Wrap(err, "sidecar task, cache path of thumbnail")
+	}
 	tempThumbPath := thumbPath + ".hold" // hold onto the original image incase for some reason we fail to recreate one with the new settings
 	os.Rename(thumbPath, tempThumbPath)
-	updatedThumbnail, err := generateSaveThumbnailJPEG(ctx.GetDB(), photo, thumbURL.MediaName, mediaCachePath, baseImagePath, thumbURL)
+	updatedThumbnail, err := generateSaveThumbnailJPEG(ctx.GetDB(), photo, thumbURL.MediaName, mediaCachePath, baseImagePath,
+		media_encoding.ImageFormatOfContentType(thumbURL.ContentType), thumbURL)
 	if err != nil {
 		os.Rename(tempThumbPath, thumbPath)
 		return []*models.MediaURL{}, errors.Wrap(err, "recreating thumbnail cached image")
 	}
 	os.Remove(tempThumbPath)
 
-	photo.SideCarHash = currentFileHash
-	photo.SideCarPath = currentSideCarPath
+	// The scaled copies and AVIF variants are generated again by the photo task, from the new high-res image
+	if err := removeAVIFVariants(ctx.GetDB(), photo); err != nil {
+		return []*models.MediaURL{}, errors.Wrap(err, "sidecar task")
+	}
+	scaledURLs, err := scaledPhotoURLs(ctx.GetDB(), photo)
+	if err != nil {
+		return []*models.MediaURL{}, errors.Wrap(err, "sidecar task")
+	}
+	for _, url := range scaledURLs {
+		if err := removeGeneratedPhoto(ctx.GetDB(), url); err != nil {
+			return []*models.MediaURL{}, errors.Wrap(err, "sidecar task")
+		}
+	}
 
 	// save new side car hash
 	if err := ctx.GetDB().Save(&photo).Error; err != nil {
@@ -129,6 +168,17 @@ func (t SidecarTask) ProcessMedia(ctx scanner_task.TaskContext, mediaData *media
 	}, nil
 }
 
+// SideCarChanged returns whether the sidecar of a raw photo has been added, changed or removed since it was processed
+func SideCarChanged(ctx context.Context, photo *models.Media) bool {
+	currentSideCarPath := scanForSideCarFile(photo.Path)
+	if currentSideCarPath == nil || photo.SideCarPath == nil {
+		return currentSideCarPath != nil || photo.SideCarPath != nil
+	}
+
+	currentFileHash := hashSideCarFile(ctx, currentSideCarPath)
+	return photo.SideCarHash == nil || *photo.SideCarHash != *currentFileHash
+}
+
 func scanForSideCarFile(path string) *string {
 	testPath := path + ".xmp"
 
@@ -139,20 +189,20 @@ func scanForSideCarFile(path string) *string {
 	return nil
 }
 
-func hashSideCarFile(path *string) *string {
+func hashSideCarFile(ctx context.Context, path *string) *string {
 	if path == nil {
 		return nil
 	}
 
 	f, err := os.Open(*path)
 	if err != nil {
-		log.Printf("ERROR: %s", err)
+		log.Error(ctx, "Opening sidecar file", "path", *path, "error", err)
 	}
 	defer f.Close()
 
 	h := md5.New()
 	if _, err := io.Copy(h, f); err != nil {
-		log.Printf("ERROR: %s", err)
+		log.Error(ctx, "Hashing sidecar file", "path", *path, "error", err)
 	}
 	hash := hex.EncodeToString(h.Sum(nil))
 	return &hash
@@ -0,0 +1,117 @@
+package processing_tasks
+
+import (
+	"os"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/photoview/photoview/api/scanner/media_encoding/media_utils"
+	"github.com/photoview/photoview/api/scanner/scan_profile"
+	"github.com/photoview/photoview/api/scanner/scanner_io"
+	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/pkg/errors"
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+// processVideoSprite generates the scrub sprite of a video, or generates it again if it is no longer complete in the cache.
+// It returns the media url of the sprite if it has been created or updated, and nil for videos without a duration.
+func processVideoSprite(ctx scanner_task.TaskContext, video *models.Media, mediaCachePath string, probeData *ffprobe.ProbeData,
+	spriteURL *models.MediaURL) (*models.MediaURL, error) {
+
+	if probeData.Format == nil || probeData.Format.DurationSeconds <= 0 || probeData.FirstVideoStream() == nil {
+		return nil, nil
+	}
+
+	if spriteURL != nil {
+		spriteURL.Media = video
+		if spriteURL.CachedFileComplete() {
+			return nil, nil
+		}
+
+		log.Info(ctx, "Video sprite found in database but not in cache, generating it again", "media_name", spriteURL.MediaName)
+
+		spritePath, err := spriteURL.CachedPath()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := saveVideoSprite(ctx, video, spriteURL, spritePath, probeData); err != nil {
+			return nil, err
+		}
+
+		if err := ctx.GetDB().Save(spriteURL).Error; err != nil {
+			return nil, errors.Wrap(err, "updating video sprite url in database after generating it again")
+		}
+
+		return spriteURL, nil
+	}
+
+	spriteName := generateUniqueMediaNamePrefixed("video_sprite", video.Path, ".jpg")
+
+	entry, err := findCacheEntry(ctx.GetDB(), video, models.VideoSprite)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		return mediaURLFromCacheEntry(ctx.GetDB(), video, entry, spriteName)
+	}
+
+	spritePath, err := cacheFilePath(video, mediaCachePath, models.VideoSprite, spriteName)
+	if err != nil {
+		return nil, err
+	}
+
+	spriteURL = &models.MediaURL{
+		MediaID:     video.ID,
+		MediaName:   spriteName,
+		Purpose:     models.VideoSprite,
+		ContentType: "image/jpeg",
+	}
+
+	if err := saveVideoSprite(ctx, video, spriteURL, spritePath, probeData); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.GetDB().Create(spriteURL).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to insert video sprite into database (%s)", video.Title)
+	}
+
+	return spriteURL, nil
+}
+
+// saveVideoSprite encodes the scrub sprite of a video, once fewer thumbnails than the maximum number of thumbnail jobs are being generated,
+// and records its dimensions and size in the media url and the cache
+func saveVideoSprite(ctx scanner_task.TaskContext, video *models.Media, spriteURL *models.MediaURL, spritePath string, probeData *ffprobe.ProbeData) error {
+	release, err := scanner_io.AcquireThumbnailJob(ctx)
+	if err != nil {
+		return err
+	}
+
+	endThumbnails := scan_profile.Start(scan_profile.StageThumbnails)
+	err = executable_worker.FfmpegCli.EncodeScrubSprite(ctx, video.Path, spritePath, probeData.Format.DurationSeconds,
+		models.ScrubSpriteFrames, models.ScrubSpriteColumns, models.ScrubSpriteFrameWidth)
+	endThumbnails()
+	release()
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to generate sprite for video (%s)", video.Title)
+	}
+
+	dimensions, err := media_utils.GetPhotoDimensions(spritePath)
+	if err != nil {
+		return errors.Wrap(err, "get dimensions of video sprite image")
+	}
+
+	fileStats, err := os.Stat(spritePath)
+	if err != nil {
+		return errors.Wrap(err, "reading file stats of video sprite")
+	}
+
+	spriteURL.Width = dimensions.Width
+	spriteURL.Height = dimensions.Height
+	spriteURL.FileSize = fileStats.Size()
+
+	return saveCacheEntry(ctx.GetDB(), video, spriteURL, spritePath)
+}
@@ -14,13 +14,19 @@ var allTasks []scanner_task.ScannerTask = []scanner_task.ScannerTask{
 	NotificationTask{},
 	IgnorefileTask{},
 	processing_tasks.CounterpartFilesTask{},
+	processing_tasks.ContentHashTask{},
 	processing_tasks.SidecarTask{},
 	processing_tasks.ProcessPhotoTask{},
 	processing_tasks.ProcessVideoTask{},
+	processing_tasks.MotionPhotoTask{},
+	processing_tasks.LivePhotoTask{},
 	FaceDetectionTask{},
+	HistogramTask{},
 	ExifTask{},
 	VideoMetadataTask{},
 	cleanup_tasks.MediaCleanupTask{},
+	WebhookTask{},
+	StorageUsageTask{},
 }
 
 type scannerTasks struct {
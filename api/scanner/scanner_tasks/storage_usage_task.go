@@ -0,0 +1,17 @@
+package scanner_tasks
+
+import (
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/storage"
+)
+
+// StorageUsageTask updates the disk space used by an album after it has been scanned,
+// so the usage reported to admins follows the library without a separate full recount
+type StorageUsageTask struct {
+	scanner_task.ScannerTaskBase
+}
+
+func (t StorageUsageTask) AfterScanAlbum(ctx scanner_task.TaskContext, changedMedia []*models.Media, albumMedia []*models.Media) error {
+	return storage.UpdateAlbumStorageUsage(ctx.GetDB(), ctx.GetAlbum().ID)
+}
@@ -2,11 +2,11 @@ package scanner_tasks
 
 import (
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/scanner/scanner_task"
 	"github.com/photoview/photoview/api/scanner/scanner_tasks/processing_tasks"
 	"github.com/pkg/errors"
@@ -25,7 +25,7 @@ func (t VideoMetadataTask) AfterMediaFound(ctx scanner_task.TaskContext, media *
 
 	err := ScanVideoMetadata(ctx.GetDB(), media)
 	if err != nil {
-		log.Printf("WARN: ScanVideoMetadata for %s failed: %s\n", media.Title, err)
+		log.Warn(ctx, "ScanVideoMetadata failed", "media", media.Title, "error", err)
 	}
 
 	return nil
@@ -0,0 +1,54 @@
+package scanner_tasks
+
+import (
+	"sync"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/webhooks"
+)
+
+// WebhookTask notifies webhooks of new media, once for every scanned album rather than for every file
+type WebhookTask struct {
+	scanner_task.ScannerTaskBase
+}
+
+type webhookNewMediaKey struct{}
+
+type newMediaCollector struct {
+	mutex sync.Mutex
+	media []*models.Media
+}
+
+func (t WebhookTask) BeforeScanAlbum(ctx scanner_task.TaskContext) (scanner_task.TaskContext, error) {
+	return ctx.WithValue(webhookNewMediaKey{}, &newMediaCollector{}), nil
+}
+
+func (t WebhookTask) AfterMediaFound(ctx scanner_task.TaskContext, media *models.Media, newMedia bool) error {
+	if !newMedia {
+		return nil
+	}
+
+	// Media imported outside of an album scan, such as uploads, is notified right away
+	collector, ok := ctx.Value(webhookNewMediaKey{}).(*newMediaCollector)
+	if !ok {
+		webhooks.MediaAdded(ctx.GetAlbum(), []*models.Media{media})
+		return nil
+	}
+
+	collector.mutex.Lock()
+	collector.media = append(collector.media, media)
+	collector.mutex.Unlock()
+
+	return nil
+}
+
+func (t WebhookTask) AfterScanAlbum(ctx scanner_task.TaskContext, changedMedia []*models.Media, albumMedia []*models.Media) error {
+	collector, ok := ctx.Value(webhookNewMediaKey{}).(*newMediaCollector)
+	if !ok || len(collector.media) == 0 {
+		return nil
+	}
+
+	webhooks.MediaAdded(ctx.GetAlbum(), collector.media)
+	return nil
+}
@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner/media_type"
+	"github.com/photoview/photoview/api/scanner/scanner_cache"
+	"github.com/photoview/photoview/api/scanner/scanner_task"
+	"github.com/photoview/photoview/api/scanner/scanner_tasks"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var ErrorUnsupportedUpload = errors.New("file type is not supported")
+
+// ValidUploadFilename returns the base name of an uploaded file,
+// or an error if the file name is invalid or its extension is not a supported media type.
+func ValidUploadFilename(filename string) (string, error) {
+	filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if filename == "" || filename == "." || filename == "/" || strings.HasPrefix(filename, ".") {
+		return "", errors.Errorf("invalid file name: %s", filename)
+	}
+
+	mediaType, found := media_type.GetExtensionMediaType(path.Ext(filename))
+	if !found || !mediaType.IsSupported() {
+		return "", ErrorUnsupportedUpload
+	}
+
+	return filename, nil
+}
+
+// ImportMediaFile moves a file, such as an upload, into the directory of the album and adds it to the database.
+// If a file with the same name already exists in the album, a number is appended to the name.
+// The media still needs to be processed by scanning the album afterwards.
+func ImportMediaFile(db *gorm.DB, album *models.Album, srcPath string, filename string) (*models.Media, error) {
+	filename, err := ValidUploadFilename(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaFile, mediaPath, err := createMediaFile(album.Path, filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "create imported file in album")
+	}
+
+	if err := moveFile(srcPath, mediaFile); err != nil {
+		os.Remove(mediaPath)
+		return nil, errors.Wrap(err, "move imported file into album")
+	}
+
+	// Run the same tasks as when the media is found by the scanner, as it will not be considered new when the album is scanned
+	var media *models.Media
+	taskContext := scanner_task.NewTaskContext(context.Background(), db, album, scanner_cache.MakeAlbumCache())
+	err = taskContext.DatabaseTransaction(func(ctx scanner_task.TaskContext) error {
+		var isNewMedia bool
+		media, isNewMedia, err = ScanMedia(ctx.GetDB(), mediaPath, album.ID, ctx.GetCache())
+		if err != nil {
+			return err
+		}
+
+		return scanner_tasks.Tasks.AfterMediaFound(ctx, media, isNewMedia)
+	})
+	if err != nil {
+		os.Remove(mediaPath)
+		return nil, errors.Wrap(err, "add imported media to database")
+	}
+
+	return media, nil
+}
+
+// createMediaFile creates an empty file for a media file in the directory, appending a number to its name
+// while a file with the name already exists. Files are created exclusively, so files imported at the same time
+// with the same name never overwrite each other.
+func createMediaFile(dir string, filename string) (*os.File, string, error) {
+	ext := path.Ext(filename)
+	name := strings.TrimSuffix(filename, ext)
+
+	mediaPath := path.Join(dir, filename)
+	for i := 1; ; i++ {
+		file, err := os.OpenFile(mediaPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return file, mediaPath, nil
+		} else if !os.IsExist(err) {
+			return nil, "", err
+		}
+
+		mediaPath = path.Join(dir, fmt.Sprintf("%s (%d)%s", name, i, ext))
+	}
+}
+
+// moveFile moves the file into the created destination file, by renaming it over the destination,
+// falling back to copying it if source and destination are on different file systems
+func moveFile(src string, dst *os.File) error {
+	if err := os.Rename(src, dst.Name()); err == nil {
+		return dst.Close()
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	defer srcFile.Close()
+
+	if _, err := io.Copy(dst, srcFile); err != nil {
+		dst.Close()
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
@@ -3,12 +3,17 @@ package scanner
 import (
 	"bufio"
 	"container/list"
+	"context"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/path_exclusion"
+	"github.com/photoview/photoview/api/scanner/scan_profile"
 	"github.com/photoview/photoview/api/scanner/scanner_cache"
 	"github.com/photoview/photoview/api/scanner/scanner_tasks/cleanup_tasks"
 	"github.com/photoview/photoview/api/scanner/scanner_utils"
@@ -18,7 +23,9 @@ import (
 	"gorm.io/gorm"
 )
 
-func getPhotoviewIgnore(ignorePath string) ([]string, error) {
+// getPhotoviewIgnore reads the gitignore style rules of the .photoviewignore file in a directory, if it has one.
+// The rules are anchored to the directory, so they can be matched against absolute paths along with the rules of other directories.
+func getPhotoviewIgnore(ctx context.Context, ignorePath string) ([]string, error) {
 	var photoviewIgnore []string
 
 	// Open .photoviewignore file, if exists
@@ -36,15 +43,123 @@ func getPhotoviewIgnore(ignorePath string) ([]string, error) {
 	// Read and save .photoviewignore data
 	scanner := bufio.NewScanner(photoviewIgnoreFile)
 	for scanner.Scan() {
-		photoviewIgnore = append(photoviewIgnore, scanner.Text())
-		log.Printf("Ignore found: %s", scanner.Text())
+		rule, ok := anchorIgnoreRule(ignorePath, scanner.Text())
+		if !ok {
+			continue
+		}
+
+		photoviewIgnore = append(photoviewIgnore, rule)
+		log.Debug(ctx, "Ignore found", "pattern", scanner.Text(), "rule", rule)
 	}
 
 	return photoviewIgnore, scanner.Err()
 }
 
-func FindAlbumsForUser(db *gorm.DB, user *models.User, album_cache *scanner_cache.AlbumScannerCache) ([]*models.Album, []error) {
+// Characters of directory paths with a special meaning in the regular expressions ignore rules are compiled to
+var ignoreRuleEscaper = strings.NewReplacer(
+	"+", `\+`, "(", `\(`, ")", `\)`, "[", `\[`, "]", `\]`,
+	"{", `\{`, "}", `\}`, "^", `\^`, "$", `\$`, "|", `\|`,
+)
+
+// anchorIgnoreRule rewrites a line of the ignore file in a directory into a rule matching absolute paths.
+// As in gitignore files, patterns containing a slash are relative to the directory,
+// and other patterns match files and directories at any depth below it.
+// It returns false for blank lines and comments.
+func anchorIgnoreRule(dir string, line string) (string, bool) {
+	pattern := strings.TrimSpace(strings.TrimRight(line, "\r"))
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return "", false
+	}
+
+	negate := ""
+	if strings.HasPrefix(pattern, "!") {
+		negate = "!"
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\#`) || strings.HasPrefix(pattern, `\!`) {
+		pattern = pattern[1:]
+	}
+
+	base := ignoreRuleEscaper.Replace(strings.TrimSuffix(dir, "/"))
+	if strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		return negate + base + "/" + strings.TrimPrefix(pattern, "/"), true
+	}
+
+	return negate + base + "/**/" + pattern, true
+}
+
+// LoadAlbumIgnore caches the ignore rules of an album scanned on its own,
+// which are those of the ignore files of its directory and of the directories of its parent albums
+func LoadAlbumIgnore(db *gorm.DB, album *models.Album, album_cache *scanner_cache.AlbumScannerCache) error {
+	ctx := db.Statement.Context
+
+	albumIgnore, err := parentAlbumIgnore(db, album)
+	if err != nil {
+		return err
+	}
+
+	photoviewIgnore, err := getPhotoviewIgnore(ctx, album.Path)
+	if err != nil {
+		log.Warn(ctx, "Failed to get ignore file", "path", album.Path, "error", err)
+	} else {
+		albumIgnore = append(albumIgnore, photoviewIgnore...)
+	}
+
+	album_cache.InsertAlbumIgnore(album.Path, albumIgnore)
+	return nil
+}
+
+// parentAlbumIgnore returns the ignore rules of the ignore files in the directories of the parent albums of an album
+func parentAlbumIgnore(db *gorm.DB, album *models.Album) ([]string, error) {
+	ctx := db.Statement.Context
+
+	dirs := []string{}
+	for parentID := album.ParentAlbumID; parentID != nil; {
+		var parent models.Album
+		if err := db.Select("id", "path", "parent_album_id").First(&parent, *parentID).Error; err != nil {
+			return nil, errors.Wrap(err, "get parent album from database")
+		}
+
+		dirs = append(dirs, parent.Path)
+		parentID = parent.ParentAlbumID
+	}
 
+	// Rules are added from the root album down, as when scanning the albums of a user
+	albumIgnore := make([]string, 0)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		photoviewIgnore, err := getPhotoviewIgnore(ctx, dirs[i])
+		if err != nil {
+			log.Warn(ctx, "Failed to get ignore file", "path", dirs[i], "error", err)
+			continue
+		}
+		albumIgnore = append(albumIgnore, photoviewIgnore...)
+	}
+
+	return albumIgnore, nil
+}
+
+// FindSubAlbums finds an album and the albums in the directories below it, adding albums for new directories.
+// Albums of directories that have been removed are left for the next scan of their owners to delete.
+func FindSubAlbums(db *gorm.DB, user *models.User, album *models.Album, album_cache *scanner_cache.AlbumScannerCache) ([]*models.Album, []error) {
+	if _, err := os.Stat(album.Path); err != nil {
+		return nil, []error{scanner_utils.NewDirectoryError(album.Path, err, "read album directory")}
+	}
+
+	albumIgnore, err := parentAlbumIgnore(db, album)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	scanQueue := list.New()
+	scanQueue.PushBack(albumScanInfo{
+		path:   album.Path,
+		parent: nil,
+		ignore: albumIgnore,
+	})
+
+	return findAlbums(db, user, scanQueue, album_cache)
+}
+
+func FindAlbumsForUser(db *gorm.DB, user *models.User, album_cache *scanner_cache.AlbumScannerCache) ([]*models.Album, []error) {
 	if err := user.FillAlbums(db); err != nil {
 		return nil, []error{err}
 	}
@@ -60,25 +175,18 @@ func FindAlbumsForUser(db *gorm.DB, user *models.User, album_cache *scanner_cach
 	}
 
 	scanErrors := make([]error, 0)
-
-	type scanInfo struct {
-		path   string
-		parent *models.Album
-		ignore []string
-	}
-
 	scanQueue := list.New()
 
 	for _, album := range userRootAlbums {
 		// Check if user album directory exists on the file system
 		if _, err := os.Stat(album.Path); err != nil {
 			if os.IsNotExist(err) {
-				scanErrors = append(scanErrors, errors.Errorf("Album directory for user '%s' does not exist '%s'\n", user.Username, album.Path))
+				scanErrors = append(scanErrors, scanner_utils.NewDirectoryError(album.Path, err, fmt.Sprintf("album directory for user '%s' does not exist", user.Username)))
 			} else {
-				scanErrors = append(scanErrors, errors.Errorf("Could not read album directory for user '%s': %s\n", user.Username, album.Path))
+				scanErrors = append(scanErrors, scanner_utils.NewDirectoryError(album.Path, err, fmt.Sprintf("could not read album directory for user '%s'", user.Username)))
 			}
 		} else {
-			scanQueue.PushBack(scanInfo{
+			scanQueue.PushBack(albumScanInfo{
 				path:   album.Path,
 				parent: nil,
 				ignore: nil,
@@ -86,34 +194,84 @@ func FindAlbumsForUser(db *gorm.DB, user *models.User, album_cache *scanner_cach
 		}
 	}
 
+	userAlbums, findErrors := findAlbums(db, user, scanQueue, album_cache)
+	scanErrors = append(scanErrors, findErrors...)
+
+	deleteErrors := cleanup_tasks.DeleteOldUserAlbums(db, userAlbums, user)
+	scanErrors = append(scanErrors, deleteErrors...)
+
+	return userAlbums, scanErrors
+}
+
+// albumScanInfo is a directory to be scanned for albums, along with its parent album and the ignore rules of the directories above it
+type albumScanInfo struct {
+	path   string
+	parent *models.Album
+	ignore []string
+}
+
+// findAlbums walks the directories of the scan queue and their subdirectories containing media,
+// adding albums for new directories and the user as an owner of existing albums.
+// Every directory becomes a single album, even if it can be reached through symlinks, and symlink cycles are skipped.
+func findAlbums(db *gorm.DB, user *models.User, scanQueue *list.List, album_cache *scanner_cache.AlbumScannerCache) ([]*models.Album, []error) {
+	ctx := db.Statement.Context
+
+	scanErrors := make([]error, 0)
 	userAlbums := make([]*models.Album, 0)
 
-	for scanQueue.Front() != nil {
-		albumInfo := scanQueue.Front().Value.(scanInfo)
+	// Symlinked directories are walked after all other directories,
+	// so directories reachable both directly and through a symlink become albums at their own path
+	symlinkQueue := list.New()
+	visitedDirs := make(map[directoryID]bool)
+
+	for scanQueue.Front() != nil || symlinkQueue.Front() != nil {
+		if scanQueue.Front() == nil {
+			scanQueue.PushBack(symlinkQueue.Remove(symlinkQueue.Front()))
+		}
+
+		albumInfo := scanQueue.Front().Value.(albumScanInfo)
 		scanQueue.Remove(scanQueue.Front())
 
 		albumPath := albumInfo.path
 		albumParent := albumInfo.parent
 		albumIgnore := albumInfo.ignore
 
+		dirID, err := getDirectoryID(albumPath)
+		if err != nil {
+			scanErrors = append(scanErrors, scanner_utils.NewDirectoryError(albumPath, err, "identify directory"))
+			continue
+		}
+		if visitedDirs[dirID] {
+			log.Debug(ctx, "Skip, directory has already been scanned through another path", "path", albumPath)
+			continue
+		}
+		visitedDirs[dirID] = true
+
 		// Read path
+		endWalk := scan_profile.Start(scan_profile.StageDirectoryWalk)
 		dirContent, err := ioutil.ReadDir(albumPath)
+		endWalk()
 		if err != nil {
-			scanErrors = append(scanErrors, errors.Wrapf(err, "read directory (%s)", albumPath))
+			scanErrors = append(scanErrors, scanner_utils.NewDirectoryError(albumPath, err, "read directory"))
 			continue
 		}
 
 		// Skip this dir if in ignore list
 		ignorePaths := ignore.CompileIgnoreLines(albumIgnore...)
 		if ignorePaths.MatchesPath(albumPath + "/") {
-			log.Printf("Skip, directroy %s is in ignore file", albumPath)
+			log.Debug(ctx, "Skip, directory is in ignore file", "path", albumPath)
+			continue
+		}
+
+		if album_cache.IsPathExcluded(albumPath) {
+			log.Debug(ctx, "Skip, directory is excluded", "path", albumPath)
 			continue
 		}
 
 		// Update ignore dir list
-		photoviewIgnore, err := getPhotoviewIgnore(albumPath)
+		photoviewIgnore, err := getPhotoviewIgnore(ctx, albumPath)
 		if err != nil {
-			log.Printf("Failed to get ignore file, err = %s", err)
+			log.Warn(ctx, "Failed to get ignore file", "path", albumPath, "error", err)
 		} else {
 			albumIgnore = append(albumIgnore, photoviewIgnore...)
 		}
@@ -122,7 +280,7 @@ func FindAlbumsForUser(db *gorm.DB, user *models.User, album_cache *scanner_cach
 		var album *models.Album
 
 		transErr := db.Transaction(func(tx *gorm.DB) error {
-			log.Printf("Scanning directory: %s", albumPath)
+			log.Debug(ctx, "Scanning directory", "path", albumPath)
 
 			// check if album already exists
 			var albumResult []models.Album
@@ -164,6 +322,14 @@ func FindAlbumsForUser(db *gorm.DB, user *models.User, album_cache *scanner_cach
 			} else {
 				album = &albumResult[0]
 
+				// The root album of another user within this directory is moved below its parent,
+				// it remains a root album for that user, as the user does not own the parent
+				if album.ParentAlbumID == nil && albumParent != nil {
+					if err := tx.Model(album).Update("parent_album_id", albumParent.ID).Error; err != nil {
+						return errors.Wrap(err, "set parent of album")
+					}
+				}
+
 				// Add user as an owner of the album if not already
 				var userAlbumOwner []models.User
 				if err := tx.Model(&album).Association("Owners").Find(&userAlbumOwner, "user_albums.user_id = ?", user.ID); err != nil {
@@ -191,12 +357,23 @@ func FindAlbumsForUser(db *gorm.DB, user *models.User, album_cache *scanner_cach
 			continue
 		}
 
+		// Sub-albums mapped to a storage backend are read from the backend, instead of from this directory
+		mappedDirs, err := models.MappedStorageDirectories(db, albumPath)
+		if err != nil {
+			scanErrors = append(scanErrors, err)
+			continue
+		}
+
 		// Scan for sub-albums
 		for _, item := range dirContent {
+			if _, mapped := mappedDirs[item.Name()]; mapped {
+				continue
+			}
+
 			subalbumPath := path.Join(albumPath, item.Name())
 
-			// Skip if directory is hidden
-			if path.Base(subalbumPath)[0:1] == "." {
+			// Skip if directory is hidden or a system directory
+			if path_exclusion.IsSkippedDirectory(item.Name()) {
 				continue
 			}
 
@@ -206,23 +383,40 @@ func FindAlbumsForUser(db *gorm.DB, user *models.User, album_cache *scanner_cach
 				continue
 			}
 
-			if (item.IsDir() || isDirSymlink) && directoryContainsPhotos(subalbumPath, album_cache, albumIgnore) {
-				scanQueue.PushBack(scanInfo{
+			if (item.IsDir() || isDirSymlink) && directoryContainsPhotos(ctx, subalbumPath, album_cache, albumIgnore) {
+				queue := scanQueue
+				if isDirSymlink {
+					queue = symlinkQueue
+				}
+
+				queue.PushBack(albumScanInfo{
 					path:   subalbumPath,
 					parent: album,
 					ignore: albumIgnore,
 				})
 			}
 		}
-	}
 
-	deleteErrors := cleanup_tasks.DeleteOldUserAlbums(db, userAlbums, user)
-	scanErrors = append(scanErrors, deleteErrors...)
+		for _, backendPath := range mappedDirs {
+			if _, err := os.Stat(backendPath); err != nil {
+				scanErrors = append(scanErrors, scanner_utils.NewDirectoryError(backendPath, err, "read storage backend directory"))
+				continue
+			}
+
+			if directoryContainsPhotos(ctx, backendPath, album_cache, albumIgnore) {
+				scanQueue.PushBack(albumScanInfo{
+					path:   backendPath,
+					parent: album,
+					ignore: albumIgnore,
+				})
+			}
+		}
+	}
 
 	return userAlbums, scanErrors
 }
 
-func directoryContainsPhotos(rootPath string, cache *scanner_cache.AlbumScannerCache, albumIgnore []string) bool {
+func directoryContainsPhotos(ctx context.Context, rootPath string, cache *scanner_cache.AlbumScannerCache, albumIgnore []string) bool {
 
 	if contains_image := cache.AlbumContainsPhotos(rootPath); contains_image != nil {
 		return *contains_image
@@ -232,27 +426,42 @@ func directoryContainsPhotos(rootPath string, cache *scanner_cache.AlbumScannerC
 	scanQueue.PushBack(rootPath)
 
 	scanned_directories := make([]string, 0)
+	visitedDirs := make(map[directoryID]bool)
 
 	for scanQueue.Front() != nil {
 
 		dirPath := scanQueue.Front().Value.(string)
 		scanQueue.Remove(scanQueue.Front())
 
+		// Skip directories reached again through a symlink, which would otherwise be walked forever in case of a cycle
+		dirID, err := getDirectoryID(dirPath)
+		if err != nil {
+			log.Warn(ctx, "Cannot identify directory, skipping it", "path", dirPath, "error", err)
+			continue
+		}
+		if visitedDirs[dirID] {
+			continue
+		}
+		visitedDirs[dirID] = true
+
 		scanned_directories = append(scanned_directories, dirPath)
 
 		// Update ignore dir list
-		photoviewIgnore, err := getPhotoviewIgnore(dirPath)
+		photoviewIgnore, err := getPhotoviewIgnore(ctx, dirPath)
 		if err != nil {
-			log.Printf("Failed to get ignore file, err = %s", err)
+			log.Warn(ctx, "Failed to get ignore file", "path", dirPath, "error", err)
 		} else {
 			albumIgnore = append(albumIgnore, photoviewIgnore...)
 		}
 		ignoreEntries := ignore.CompileIgnoreLines(albumIgnore...)
 
+		// An unreadable directory is skipped, the other directories may still contain photos
+		endWalk := scan_profile.Start(scan_profile.StageDirectoryWalk)
 		dirContent, err := ioutil.ReadDir(dirPath)
+		endWalk()
 		if err != nil {
-			scanner_utils.ScannerError("Could not read directory (%s): %s\n", dirPath, err.Error())
-			return false
+			scanner_utils.ScannerDirectoryError(ctx, dirPath, "Could not read directory (%s): %s\n", dirPath, err.Error())
+			continue
 		}
 
 		for _, fileInfo := range dirContent {
@@ -260,19 +469,31 @@ func directoryContainsPhotos(rootPath string, cache *scanner_cache.AlbumScannerC
 
 			isDirSymlink, err := utils.IsDirSymlink(filePath)
 			if err != nil {
-				log.Printf("Cannot detect whether %s is symlink to a directory. Pretending it is not", filePath)
+				log.Warn(ctx, "Cannot detect whether file is symlink to a directory. Pretending it is not", "path", filePath, "error", err)
 				isDirSymlink = false
 			}
 
 			if fileInfo.IsDir() || isDirSymlink {
+				if path_exclusion.IsSkippedDirectory(fileInfo.Name()) {
+					log.Debug(ctx, "Skip, directory is hidden or a system directory", "path", filePath)
+					continue
+				}
+				if ignoreEntries.MatchesPath(filePath + "/") {
+					log.Debug(ctx, "Skip, directory is in ignore file", "path", filePath)
+					continue
+				}
+				if cache.IsPathExcluded(filePath) {
+					log.Debug(ctx, "Skip, directory is excluded", "path", filePath)
+					continue
+				}
 				scanQueue.PushBack(filePath)
 			} else {
 				if cache.IsPathMedia(filePath) {
-					if ignoreEntries.MatchesPath(fileInfo.Name()) {
-						log.Printf("Match found %s, continue search for media", fileInfo.Name())
+					if ignoreEntries.MatchesPath(filePath) {
+						log.Debug(ctx, "Match found, continue search for media", "name", fileInfo.Name())
 						continue
 					}
-					log.Printf("Insert Album %s %s, contains photo is true", dirPath, rootPath)
+					log.Debug(ctx, "Insert Album, contains photo is true", "path", dirPath, "root_path", rootPath)
 					cache.InsertAlbumPaths(dirPath, rootPath, true)
 					return true
 				}
@@ -282,7 +503,7 @@ func directoryContainsPhotos(rootPath string, cache *scanner_cache.AlbumScannerC
 	}
 
 	for _, scanned_path := range scanned_directories {
-		log.Printf("Insert Album %s, contains photo is false", scanned_path)
+		log.Debug(ctx, "Insert Album, contains photo is false", "path", scanned_path)
 		cache.InsertAlbumPath(scanned_path, false)
 	}
 	return false
@@ -0,0 +1,753 @@
+package scanner_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/otiai10/copy"
+	"github.com/photoview/photoview/api/database"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/photoview/photoview/api/scanner/scan_report"
+	"github.com/photoview/photoview/api/scanner/scanner_cache"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/tiff"
+)
+
+func TestFindSubAlbums(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	photo, err := os.ReadFile("./test_data/lilac_lilac_bush_lilac.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootPath := t.TempDir()
+	addPhoto := func(dir string) {
+		assert.NoError(t, os.MkdirAll(path.Join(rootPath, dir), 0755))
+		assert.NoError(t, os.WriteFile(path.Join(rootPath, dir, "photo.jpg"), photo, 0644))
+	}
+
+	addPhoto("trip")
+
+	user := models.User{Username: "user"}
+	if !assert.NoError(t, db.Save(&user).Error) {
+		return
+	}
+
+	_, err = scanner.NewRootAlbum(db, rootPath, &user)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, errs := scanner.FindAlbumsForUser(db, &user, scanner_cache.MakeAlbumCache())
+	if !assert.Empty(t, errs) {
+		return
+	}
+
+	var trip models.Album
+	if !assert.NoError(t, db.Where("path = ?", path.Join(rootPath, "trip")).First(&trip).Error) {
+		return
+	}
+
+	addPhoto("trip/day1")
+	addPhoto("other")
+
+	albums, errs := scanner.FindSubAlbums(db, &user, &trip, scanner_cache.MakeAlbumCache())
+	if !assert.Empty(t, errs) {
+		return
+	}
+
+	albumPaths := make([]string, len(albums))
+	for i, album := range albums {
+		albumPaths[i] = album.Path
+	}
+	assert.ElementsMatch(t, []string{path.Join(rootPath, "trip"), path.Join(rootPath, "trip/day1")}, albumPaths,
+		"only the album and the directories below it are found")
+
+	var day1 models.Album
+	if assert.NoError(t, db.Preload("Owners").Where("path = ?", path.Join(rootPath, "trip/day1")).First(&day1).Error) {
+		assert.Equal(t, trip.ID, *day1.ParentAlbumID)
+		if assert.Len(t, day1.Owners, 1) {
+			assert.Equal(t, user.ID, day1.Owners[0].ID)
+		}
+	}
+
+	var otherCount int64
+	assert.NoError(t, db.Model(&models.Album{}).Where("path = ?", path.Join(rootPath, "other")).Count(&otherCount).Error)
+	assert.Zero(t, otherCount, "albums outside of the album are not added")
+}
+
+func TestPhotoviewIgnore(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	photo, err := os.ReadFile("./test_data/lilac_lilac_bush_lilac.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootPath := t.TempDir()
+	writeFile := func(name string, data []byte) {
+		assert.NoError(t, os.MkdirAll(path.Dir(path.Join(rootPath, name)), 0755))
+		assert.NoError(t, os.WriteFile(path.Join(rootPath, name), data, 0644))
+	}
+
+	writeFile(".photoviewignore", []byte("# Synology thumbnails\n@eaDir\n/exports\n*_edit.jpg\n"))
+	writeFile("trip/photo.jpg", photo)
+	writeFile("trip/photo_edit.jpg", photo)
+	writeFile("trip/@eaDir/photo.jpg", photo)
+	writeFile("trip/exports/photo.jpg", photo)
+	writeFile("exports/photo.jpg", photo)
+	writeFile("party/.photoviewignore", []byte("work/\n!keep_edit.jpg\n"))
+	writeFile("party/photo.jpg", photo)
+	writeFile("party/keep_edit.jpg", photo)
+	writeFile("party/work/photo.jpg", photo)
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	var albumPaths []string
+	assert.NoError(t, db.Model(&models.Album{}).Order("path").Pluck("path", &albumPaths).Error)
+	assert.Equal(t, []string{
+		rootPath,
+		path.Join(rootPath, "party"),
+		path.Join(rootPath, "trip"),
+		path.Join(rootPath, "trip/exports"),
+	}, albumPaths, "ignored directories do not become albums, patterns with a slash are relative to the ignore file")
+
+	var mediaPaths []string
+	assert.NoError(t, db.Model(&models.Media{}).Order("path").Pluck("path", &mediaPaths).Error)
+	assert.Equal(t, []string{
+		path.Join(rootPath, "party/keep_edit.jpg"),
+		path.Join(rootPath, "party/photo.jpg"),
+		path.Join(rootPath, "trip/exports/photo.jpg"),
+		path.Join(rootPath, "trip/photo.jpg"),
+	}, mediaPaths, "ignored files are skipped, unless they are included again by a negated pattern")
+}
+
+func TestExcludePatterns(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	photo, err := os.ReadFile("./test_data/lilac_lilac_bush_lilac.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootPath := t.TempDir()
+	for _, name := range []string{"trip/photo.jpg", "trip/.cache/photo.jpg", "trip/export.jpg", "junk/photo.jpg"} {
+		assert.NoError(t, os.MkdirAll(path.Dir(path.Join(rootPath, name)), 0755))
+		assert.NoError(t, os.WriteFile(path.Join(rootPath, name), photo, 0644))
+	}
+
+	t.Setenv(utils.EnvExcludePatterns.GetName(), "**/.cache/**")
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	preferences := models.UserPreferences{UserID: user.ID}
+	preferences.SetExcludePatterns([]string{"export.jpg", "regex:/junk$"})
+	if !assert.NoError(t, db.Create(&preferences).Error) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	var albumPaths []string
+	assert.NoError(t, db.Model(&models.Album{}).Order("path").Pluck("path", &albumPaths).Error)
+	assert.Equal(t, []string{rootPath, path.Join(rootPath, "trip")}, albumPaths, "excluded directories do not become albums")
+
+	var mediaPaths []string
+	assert.NoError(t, db.Model(&models.Media{}).Pluck("path", &mediaPaths).Error)
+	assert.Equal(t, []string{path.Join(rootPath, "trip/photo.jpg")}, mediaPaths,
+		"files matching the patterns of the server or of the user are not scanned")
+}
+
+func TestSymlinkedAlbums(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	photo, err := os.ReadFile("./test_data/lilac_lilac_bush_lilac.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootPath := t.TempDir()
+	outsidePath := t.TempDir()
+
+	assert.NoError(t, os.MkdirAll(path.Join(rootPath, "trip"), 0755))
+	assert.NoError(t, os.WriteFile(path.Join(rootPath, "trip/photo.jpg"), photo, 0644))
+	assert.NoError(t, os.WriteFile(path.Join(outsidePath, "photo.jpg"), photo, 0644))
+
+	// A second path to an album, a cycle back to the root, and an album tree outside of the root
+	assert.NoError(t, os.Symlink(path.Join(rootPath, "trip"), path.Join(rootPath, "another_trip")))
+	assert.NoError(t, os.Symlink(rootPath, path.Join(rootPath, "trip/loop")))
+	assert.NoError(t, os.Symlink(outsidePath, path.Join(rootPath, "outside")))
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	var albumPaths []string
+	assert.NoError(t, db.Model(&models.Album{}).Order("path").Pluck("path", &albumPaths).Error)
+	assert.Equal(t, []string{rootPath, path.Join(rootPath, "outside"), path.Join(rootPath, "trip")}, albumPaths,
+		"symlinked directories are scanned once, at their own path if they are in the library")
+
+	var mediaPaths []string
+	assert.NoError(t, db.Model(&models.Media{}).Order("path").Pluck("path", &mediaPaths).Error)
+	assert.Equal(t, []string{path.Join(rootPath, "outside/photo.jpg"), path.Join(rootPath, "trip/photo.jpg")}, mediaPaths)
+}
+
+func TestFindAlbumsFailures(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	scan_report.InitializeScanReports(db)
+	defer scan_report.InitializeScanReports(nil)
+
+	photo, err := os.ReadFile("./test_data/lilac_lilac_bush_lilac.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootPath := t.TempDir()
+	for _, name := range []string{"photo.jpg", "trip/photo.jpg"} {
+		assert.NoError(t, os.MkdirAll(path.Dir(path.Join(rootPath, name)), 0755))
+		assert.NoError(t, os.WriteFile(path.Join(rootPath, name), photo, 0644))
+	}
+	assert.NoError(t, os.Symlink(path.Join(rootPath, "missing"), path.Join(rootPath, "broken")))
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	removedPath := t.TempDir()
+	if _, err := scanner.NewRootAlbum(db, removedPath, user); !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, os.Remove(removedPath))
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	var mediaCount int64
+	assert.NoError(t, db.Model(&models.Media{}).Count(&mediaCount).Error)
+	assert.EqualValues(t, 2, mediaCount, "the scan continues past the directories that cannot be read")
+
+	var report models.ScanReport
+	if !assert.NoError(t, db.Last(&report).Error) {
+		return
+	}
+	assert.NotNil(t, report.FinishedAt, "the report is completed once the scan has finished")
+
+	var reportFailures []*models.ScanFailure
+	assert.NoError(t, db.Where("scan_report_id = ?", report.ID).Order("id").Find(&reportFailures).Error)
+	assert.Equal(t, len(reportFailures), report.FailureCount)
+
+	// Background tasks of the previous tests may still record failures of their own
+	failures := make([]*models.ScanFailure, 0)
+	for _, failure := range reportFailures {
+		if strings.Contains(failure.Message, path.Dir(rootPath)) {
+			failures = append(failures, failure)
+		}
+	}
+
+	if assert.Len(t, failures, 3) {
+		if assert.NotNil(t, failures[0].DirectoryPath) {
+			assert.Equal(t, removedPath, *failures[0].DirectoryPath)
+		}
+		assert.Contains(t, failures[1].Message, path.Join(rootPath, "broken"))
+		assert.Nil(t, failures[1].DirectoryPath)
+		if assert.NotNil(t, failures[2].MediaPath, "the broken symlink is also reported as a file that cannot be read") {
+			assert.Equal(t, path.Join(rootPath, "broken"), *failures[2].MediaPath)
+		}
+	}
+}
+
+func TestMovedMedia(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	rootPath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(path.Join(rootPath, "trip"), 0755))
+	assert.NoError(t, copy.Copy("./test_data/lilac_lilac_bush_lilac.jpg", path.Join(rootPath, "trip/lilac.jpg")))
+	assert.NoError(t, copy.Copy("./test_data/buttercup_close_summer_yellow.jpg", path.Join(rootPath, "buttercup.jpg")))
+	assert.NoError(t, copy.Copy("./test_data/mount_merapi_volcano_indonesia.jpg", path.Join(rootPath, "merapi.jpg")))
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	mediaIDs := func() map[string]int {
+		var allMedia []*models.Media
+		assert.NoError(t, db.Find(&allMedia).Error)
+
+		result := make(map[string]int)
+		for _, media := range allMedia {
+			relPath, _ := filepath.Rel(rootPath, media.Path)
+			result[relPath] = media.ID
+			assert.Nil(t, media.MissingSince)
+		}
+		return result
+	}
+
+	scannedIDs := mediaIDs()
+	if !assert.Len(t, scannedIDs, 3) {
+		return
+	}
+
+	favorite := models.UserMediaData{UserID: user.ID, MediaID: scannedIDs["trip/lilac.jpg"], Favorite: true}
+	if !assert.NoError(t, db.Create(&favorite).Error) {
+		return
+	}
+
+	assert.NoError(t, os.Rename(path.Join(rootPath, "trip"), path.Join(rootPath, "holiday")))
+	assert.NoError(t, os.Rename(path.Join(rootPath, "buttercup.jpg"), path.Join(rootPath, "holiday/yellow.jpg")))
+	assert.NoError(t, os.Remove(path.Join(rootPath, "merapi.jpg")))
+	test_utils.RunScannerOnUser(t, db, user)
+
+	assert.Equal(t, map[string]int{
+		"holiday/lilac.jpg":  scannedIDs["trip/lilac.jpg"],
+		"holiday/yellow.jpg": scannedIDs["buttercup.jpg"],
+	}, mediaIDs(), "moved media are kept, deleted media are removed")
+
+	var favorites []*models.UserMediaData
+	assert.NoError(t, db.Where("favorite = ?", true).Find(&favorites).Error)
+	if assert.Len(t, favorites, 1) {
+		assert.Equal(t, scannedIDs["trip/lilac.jpg"], favorites[0].MediaID, "favorites of moved media are kept")
+	}
+
+	var holiday models.Album
+	if assert.NoError(t, db.Where("path = ?", path.Join(rootPath, "holiday")).First(&holiday).Error) {
+		var albumMediaCount int64
+		assert.NoError(t, db.Model(&models.Media{}).Where("album_id = ?", holiday.ID).Count(&albumMediaCount).Error)
+		assert.EqualValues(t, 2, albumMediaCount)
+	}
+}
+
+func TestAnimatedGIF(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	rootPath := t.TempDir()
+
+	frames := make([]*image.Paletted, 0, 2)
+	for _, c := range []color.Color{color.White, color.Black} {
+		frame := image.NewPaletted(image.Rect(0, 0, 64, 48), color.Palette{color.White, color.Black})
+		draw.Draw(frame, frame.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		frames = append(frames, frame)
+	}
+
+	gifFile, err := os.Create(path.Join(rootPath, "animation.gif"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, gif.EncodeAll(gifFile, &gif.GIF{Image: frames, Delay: []int{10, 10}}))
+	assert.NoError(t, gifFile.Close())
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	var media models.Media
+	if !assert.NoError(t, db.Preload("MediaURL").First(&media).Error) {
+		return
+	}
+	assert.Equal(t, models.MediaTypePhoto, media.Type)
+
+	purposes := make(map[models.MediaPurpose]models.MediaURL)
+	for _, mediaURL := range media.MediaURL {
+		purposes[mediaURL.Purpose] = mediaURL
+	}
+
+	if original, found := purposes[models.MediaOriginal]; assert.True(t, found) {
+		assert.Equal(t, "image/gif", original.ContentType, "the animation is shown from the original")
+		assert.Equal(t, 64, original.Width)
+	}
+	assert.NotContains(t, purposes, models.PhotoHighRes)
+
+	if thumbnail, found := purposes[models.PhotoThumbnail]; assert.True(t, found) {
+		assert.Equal(t, "image/jpeg", thumbnail.ContentType, "the thumbnail is a still of the first frame")
+	}
+}
+
+func TestMotionPhoto(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	rootPath := t.TempDir()
+
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewGray(image.Rect(0, 0, 64, 48)), nil); !assert.NoError(t, err) {
+		return
+	}
+
+	// An MP4 file of its boxes only, appended to the photo after the marker of Samsung phones
+	video := []byte("\x00\x00\x00\x14ftypmp42\x00\x00\x00\x00mp42\x00\x00\x00\x10mdat\x00\x00\x00\x00\x00\x00\x00\x00")
+	motionPhoto := append(append(photo.Bytes(), []byte("MotionPhoto_Data")...), video...)
+	motionPhoto = append(motionPhoto, []byte("SEFHSEFT")...)
+	if !assert.NoError(t, os.WriteFile(path.Join(rootPath, "motion.jpg"), motionPhoto, 0644)) {
+		return
+	}
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	var motionURL models.MediaURL
+	if !assert.NoError(t, db.Preload("Media").Where("purpose = ?", models.MotionVideo).First(&motionURL).Error) {
+		return
+	}
+
+	assert.Equal(t, "video/mp4", motionURL.ContentType)
+	assert.Equal(t, int64(len(video)), motionURL.FileSize)
+
+	cachedPath, err := motionURL.CachedPath()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	extracted, err := os.ReadFile(cachedPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, video, extracted)
+	}
+}
+
+func TestLivePhoto(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	rootPath := t.TempDir()
+	identifier := "0A4BC9E3-1F2D-4C7A-9B3E-5D6F7A8B9C0D"
+
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewGray(image.Rect(0, 0, 64, 48)), nil); !assert.NoError(t, err) {
+		return
+	}
+
+	// The content identifier is stored in the maker notes of the photo and the metadata of the video
+	still := append(photo.Bytes(), []byte(identifier)...)
+	video := []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  com.apple.quicktime.content.identifier" + identifier)
+	if !assert.NoError(t, os.WriteFile(path.Join(rootPath, "IMG_0001.JPG"), still, 0644)) {
+		return
+	}
+	if !assert.NoError(t, os.WriteFile(path.Join(rootPath, "IMG_0001.MOV"), video, 0644)) {
+		return
+	}
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	var media []*models.Media
+	if !assert.NoError(t, db.Find(&media).Error) {
+		return
+	}
+
+	if assert.Len(t, media, 1) {
+		assert.Equal(t, path.Join(rootPath, "IMG_0001.JPG"), media[0].Path)
+	}
+
+	var motionURL models.MediaURL
+	if !assert.NoError(t, db.Preload("Media").Where("purpose = ?", models.MotionVideo).First(&motionURL).Error) {
+		return
+	}
+
+	// Without ffmpeg, the video is served as it is
+	assert.Equal(t, "video/quicktime", motionURL.ContentType)
+
+	cachedPath, err := motionURL.CachedPath()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	copied, err := os.ReadFile(cachedPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, video, copied)
+	}
+}
+
+func TestScaledPhotos(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	rootPath := t.TempDir()
+
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewGray(image.Rect(0, 0, 1600, 1200)), nil); !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, os.WriteFile(path.Join(rootPath, "photo.jpg"), photo.Bytes(), 0644)) {
+		return
+	}
+
+	// The copy of 1024 pixels is the thumbnail, and no copy larger than the photo is generated
+	t.Setenv(utils.EnvThumbnailSizes.GetName(), "256,1024,4096")
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	var scaledURLs []*models.MediaURL
+	if !assert.NoError(t, db.Where("purpose LIKE ?", models.ScaledPhotoPrefix+"%").Find(&scaledURLs).Error) {
+		return
+	}
+
+	if assert.Len(t, scaledURLs, 1) {
+		assert.Equal(t, models.ScaledPhotoPurpose(256), scaledURLs[0].Purpose)
+		assert.Equal(t, 256, scaledURLs[0].Width)
+		assert.Equal(t, 192, scaledURLs[0].Height)
+	}
+
+	// Copies of sizes no longer configured are removed on the next scan
+	t.Setenv(utils.EnvThumbnailSizes.GetName(), "")
+	test_utils.RunScannerOnUser(t, db, user)
+
+	var count int64
+	if assert.NoError(t, db.Model(&models.MediaURL{}).Where("purpose LIKE ?", models.ScaledPhotoPrefix+"%").Count(&count).Error) {
+		assert.Zero(t, count)
+	}
+}
+
+func TestCacheSurvivesDatabaseReset(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	rootPath := t.TempDir()
+	assert.NoError(t, copy.Copy("./test_data/lilac_lilac_bush_lilac.jpg", path.Join(rootPath, "lilac.jpg")))
+
+	scan := func() *models.MediaURL {
+		pass := "1234"
+		user, err := models.RegisterUser(db, "user", &pass, true)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+
+		if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+			return nil
+		}
+
+		test_utils.RunScannerOnUser(t, db, user)
+
+		var thumbnail models.MediaURL
+		if !assert.NoError(t, db.Where("purpose = ?", models.PhotoThumbnail).First(&thumbnail).Error) {
+			return nil
+		}
+		assert.NotNil(t, thumbnail.CacheKey)
+
+		return &thumbnail
+	}
+
+	scanned := scan()
+	if scanned == nil || scanned.CacheKey == nil {
+		return
+	}
+
+	cachedPath := path.Join(models.PhotoThumbnail.CachePath(), *scanned.CacheKey)
+	generated, err := os.Stat(cachedPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The thumbnail in the directory of the content of the photo is used again, instead of being generated again
+	assert.NoError(t, database.ClearDatabase(db))
+	rescanned := scan()
+	if rescanned == nil || rescanned.CacheKey == nil {
+		return
+	}
+
+	assert.Equal(t, *scanned.CacheKey, *rescanned.CacheKey)
+	assert.Equal(t, scanned.Width, rescanned.Width)
+	assert.Equal(t, scanned.Height, rescanned.Height)
+	assert.Equal(t, scanned.FileSize, rescanned.FileSize)
+
+	if adopted, err := os.Stat(cachedPath); assert.NoError(t, err) {
+		assert.Equal(t, generated.ModTime(), adopted.ModTime())
+	}
+}
+
+func TestRegenerateCachedFiles(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	rootPath := t.TempDir()
+	assert.NoError(t, copy.Copy("./test_data/lilac_lilac_bush_lilac.jpg", path.Join(rootPath, "lilac.jpg")))
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	query := db.Model(&models.Media{}).Preload("MediaURL")
+
+	var media models.Media
+	if !assert.NoError(t, query.First(&media).Error) {
+		return
+	}
+
+	thumbnail := func() *models.MediaURL {
+		for i := range media.MediaURL {
+			if media.MediaURL[i].Purpose == models.PhotoThumbnail {
+				media.MediaURL[i].Media = &media
+				return &media.MediaURL[i]
+			}
+		}
+		return nil
+	}()
+	if !assert.NotNil(t, thumbnail) {
+		return
+	}
+
+	scanner.RemoveCachedFiles(&media)
+	assert.False(t, thumbnail.CachedFileComplete())
+
+	processed, failed, err := scanner.RegenerateCachedFiles(db.Statement.Context, db, query, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	assert.Equal(t, 0, failed)
+	assert.True(t, thumbnail.CachedFileComplete())
+}
+
+func TestConfiguredImageSizes(t *testing.T) {
+	test_utils.FilesystemTest(t)
+	db := test_utils.DatabaseTest(t)
+
+	t.Setenv(utils.EnvThumbnailMaxSize.GetName(), "256")
+	t.Setenv(utils.EnvWebVersionMaxSize.GetName(), "600")
+	t.Setenv(utils.EnvWebVersionQuality.GetName(), "50")
+
+	rootPath := t.TempDir()
+
+	// TIFF photos can't be displayed by browsers, so a web version is generated of them
+	var photo bytes.Buffer
+	if err := tiff.Encode(&photo, image.NewGray(image.Rect(0, 0, 1200, 800)), nil); !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, os.WriteFile(path.Join(rootPath, "photo.tiff"), photo.Bytes(), 0644)) {
+		return
+	}
+
+	pass := "1234"
+	user, err := models.RegisterUser(db, "user", &pass, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if _, err := scanner.NewRootAlbum(db, rootPath, user); !assert.NoError(t, err) {
+		return
+	}
+
+	test_utils.RunScannerOnUser(t, db, user)
+
+	var media models.Media
+	if !assert.NoError(t, db.Preload("MediaURL").First(&media).Error) {
+		return
+	}
+
+	purposes := make(map[models.MediaPurpose]models.MediaURL)
+	for _, mediaURL := range media.MediaURL {
+		purposes[mediaURL.Purpose] = mediaURL
+	}
+
+	if highRes, found := purposes[models.PhotoHighRes]; assert.True(t, found) {
+		assert.Equal(t, 600, highRes.Width, "the web version is scaled down to the configured size")
+		assert.Equal(t, 400, highRes.Height)
+	}
+
+	if original, found := purposes[models.MediaOriginal]; assert.True(t, found) {
+		assert.Equal(t, 1200, original.Width, "the original keeps the dimensions of the photo")
+		assert.Equal(t, 800, original.Height)
+	}
+
+	if thumbnail, found := purposes[models.PhotoThumbnail]; assert.True(t, found) {
+		assert.Equal(t, 256, thumbnail.Width)
+	}
+}
@@ -1,8 +1,10 @@
 package scanner_utils
 
 import (
-	"log"
+	"context"
 	"os"
+
+	"github.com/photoview/photoview/api/log"
 )
 
 func FileExists(testPath string) bool {
@@ -12,7 +14,7 @@ func FileExists(testPath string) bool {
 		return false
 	} else if err != nil {
 		// unexpected error logging
-		log.Printf("Error: checking for file existence (%s): %s", testPath, err)
+		log.Warn(context.Background(), "Checking for file existence", "path", testPath, "error", err)
 		return false
 	}
 	return true
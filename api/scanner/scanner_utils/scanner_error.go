@@ -1,18 +1,79 @@
 package scanner_utils
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"strings"
 
 	"github.com/photoview/photoview/api/graphql/models"
 	"github.com/photoview/photoview/api/graphql/notification"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/scan_report"
 	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
 )
 
-func ScannerError(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
+// ScannerError logs an error of the scanner and notifies the users of the web interface about it,
+// it is also recorded in the report of the current scan, which is sent to admins when the scan has finished
+func ScannerError(ctx context.Context, format string, args ...interface{}) {
+	scannerError(ctx, func(message string) {
+		scan_report.RecordFailure(albumID(ctx), nil, message)
+	}, format, args...)
+}
+
+// DirectoryError is an error reading a directory, which is skipped while the scan continues with the other directories
+type DirectoryError struct {
+	Path string
+	Err  error
+}
+
+// NewDirectoryError wraps the error of reading a directory
+func NewDirectoryError(dirPath string, err error, message string) *DirectoryError {
+	return &DirectoryError{Path: dirPath, Err: errors.Wrapf(err, "%s (%s)", message, dirPath)}
+}
+
+func (e *DirectoryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DirectoryError) Unwrap() error {
+	return e.Err
+}
+
+// ScannerMediaError is like ScannerError, for errors processing a single file, such as a corrupt file or a failed transcode
+func ScannerMediaError(ctx context.Context, mediaPath string, format string, args ...interface{}) {
+	scannerError(ctx, func(message string) {
+		scan_report.RecordFailure(albumID(ctx), &mediaPath, message)
+	}, format, args...)
+}
+
+// ScannerDirectoryError is like ScannerError, for directories that could not be read and are skipped
+func ScannerDirectoryError(ctx context.Context, dirPath string, format string, args ...interface{}) {
+	scannerError(ctx, func(message string) {
+		scan_report.RecordDirectoryFailure(albumID(ctx), dirPath, message)
+	}, format, args...)
+}
+
+// ScannerErrors reports errors finding albums, recording errors of directories as failures of those directories
+func ScannerErrors(ctx context.Context, errs []error, format string, args ...interface{}) {
+	for _, err := range errs {
+		errArgs := append(append([]interface{}{}, args...), err)
+
+		var dirErr *DirectoryError
+		if errors.As(err, &dirErr) {
+			ScannerDirectoryError(ctx, dirErr.Path, format, errArgs...)
+		} else {
+			ScannerError(ctx, format, errArgs...)
+		}
+	}
+}
+
+func scannerError(ctx context.Context, record func(message string), format string, args ...interface{}) {
+	message := strings.TrimSpace(fmt.Sprintf(format, args...))
+
+	log.Error(ctx, message)
+	record(message)
 
-	log.Printf("ERROR: %s", message)
 	notification.BroadcastNotification(&models.Notification{
 		Key:      utils.GenerateToken(),
 		Type:     models.NotificationTypeMessage,
@@ -21,3 +82,18 @@ func ScannerError(format string, args ...interface{}) {
 		Negative: true,
 	})
 }
+
+// albumID returns the id of the album being scanned, if the context is the context of a scanner task
+func albumID(ctx context.Context) *int {
+	albumCtx, ok := ctx.(interface{ GetAlbum() *models.Album })
+	if !ok {
+		return nil
+	}
+
+	album := albumCtx.GetAlbum()
+	if album == nil {
+		return nil
+	}
+
+	return &album.ID
+}
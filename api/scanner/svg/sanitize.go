@@ -0,0 +1,167 @@
+// Package svg sanitizes SVG images, which can hold scripts and references to other resources.
+// Browsers run the scripts of SVG images opened directly, on the origin they are served from,
+// so SVG images are sanitized before they are served or rasterized.
+package svg
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// unsafeElements are removed with their content, as they run scripts or embed other documents
+var unsafeElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"object":        true,
+	"embed":         true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// animationElements can set attributes, they are removed when they set one that is not safe
+var animationElements = map[string]bool{
+	"animate":          true,
+	"set":              true,
+	"animatemotion":    true,
+	"animatetransform": true,
+}
+
+// externalURLRegex matches CSS urls and imports that do not refer to an element of the image itself
+var externalURLRegex = regexp.MustCompile(`(?i)url\(\s*['"]?\s*[^#'"\s)]|@import`)
+
+// Sanitize writes the SVG image of r to w without scripts, event handlers and references to other resources.
+// Links are only kept to elements of the image and to embedded images.
+// Comments, processing instructions and document types, which can declare entities, are removed.
+func Sanitize(w io.Writer, r io.Reader) error {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+
+	out := bufio.NewWriter(w)
+	// skipDepth is the depth of the elements in the element being removed, 0 if no element is being removed
+	skipDepth := 0
+	// styleDepth is the depth of the elements in a style element, whose text is checked for external urls
+	styleDepth := 0
+
+	for {
+		// Raw tokens keep the prefixes of names, so they are written the way they were read
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "parse svg")
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+
+			if !isSafeElement(t) {
+				skipDepth = 1
+				continue
+			}
+
+			if styleDepth > 0 || strings.EqualFold(t.Name.Local, "style") {
+				styleDepth++
+			}
+
+			writeStartElement(out, t)
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+
+			if styleDepth > 0 {
+				styleDepth--
+			}
+
+			out.WriteString("</" + qualifiedName(t.Name) + ">")
+		case xml.CharData:
+			if skipDepth > 0 {
+				continue
+			}
+
+			if styleDepth > 0 && externalURLRegex.Match(t) {
+				continue
+			}
+
+			xml.EscapeText(out, t)
+		}
+	}
+
+	return out.Flush()
+}
+
+func isSafeElement(element xml.StartElement) bool {
+	name := strings.ToLower(element.Name.Local)
+	if unsafeElements[name] {
+		return false
+	}
+
+	if animationElements[name] {
+		for _, attr := range element.Attr {
+			if strings.EqualFold(attr.Name.Local, "attributeName") && !isSafeAttributeName(attr.Value) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// isSafeAttributeName returns whether an attribute of the given name is kept, by its name only
+func isSafeAttributeName(name string) bool {
+	local := strings.ToLower(name)
+	if i := strings.LastIndex(local, ":"); i >= 0 {
+		local = local[i+1:]
+	}
+
+	return !strings.HasPrefix(local, "on") && local != "href"
+}
+
+func isSafeAttribute(attr xml.Attr) bool {
+	local := strings.ToLower(attr.Name.Local)
+	value := strings.TrimSpace(attr.Value)
+
+	switch {
+	case strings.HasPrefix(local, "on"):
+		return false
+	case local == "href":
+		return strings.HasPrefix(value, "#") || strings.HasPrefix(strings.ToLower(value), "data:image/")
+	case externalURLRegex.MatchString(value):
+		return false
+	}
+
+	return true
+}
+
+func writeStartElement(out *bufio.Writer, element xml.StartElement) {
+	out.WriteString("<" + qualifiedName(element.Name))
+	for _, attr := range element.Attr {
+		if !isSafeAttribute(attr) {
+			continue
+		}
+
+		out.WriteString(" " + qualifiedName(attr.Name) + `="`)
+		xml.EscapeText(out, []byte(attr.Value))
+		out.WriteString(`"`)
+	}
+	out.WriteString(">")
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,84 @@
+package svg_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/photoview/photoview/api/scanner/svg"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.UnitTestRun(m))
+}
+
+func sanitize(t *testing.T, image string) string {
+	var buf bytes.Buffer
+	if err := svg.Sanitize(&buf, strings.NewReader(image)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name     string
+		image    string
+		expected string
+	}{
+		{
+			"Safe images are kept",
+			`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10"><defs><circle id="c" r="2"/></defs><use xlink:href="#c" fill="url(#g)"/><text>a &lt; b</text></svg>`,
+			`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10"><defs><circle id="c" r="2"></circle></defs><use xlink:href="#c" fill="url(#g)"></use><text>a &lt; b</text></svg>`,
+		},
+		{
+			"Scripts are removed",
+			`<svg><script>alert(1)</script><svg:script>alert(2)</svg:script><rect/></svg>`,
+			`<svg><rect></rect></svg>`,
+		},
+		{
+			"Event handlers are removed",
+			`<svg onload="alert(1)"><rect width="1" OnClick="alert(2)"/></svg>`,
+			`<svg><rect width="1"></rect></svg>`,
+		},
+		{
+			"Links to other resources are removed",
+			`<svg><a href="javascript:alert(1)"><image xlink:href="https://example.com/a.png"/></a><image href="data:image/png;base64,AAAA"/></svg>`,
+			`<svg><a><image></image></a><image href="data:image/png;base64,AAAA"></image></svg>`,
+		},
+		{
+			"Embedded documents are removed",
+			`<svg><foreignObject><iframe src="https://example.com"/></foreignObject></svg>`,
+			`<svg></svg>`,
+		},
+		{
+			"Animations setting links or event handlers are removed",
+			`<svg><a><set attributeName="href" to="javascript:alert(1)"/><animate attributeName="opacity" to="0"/></a></svg>`,
+			`<svg><a><animate attributeName="opacity" to="0"></animate></a></svg>`,
+		},
+		{
+			"External styles are removed",
+			`<svg><style>@import url(https://example.com/a.css);</style><style>rect { fill: red }</style><rect style="fill: url(https://example.com/a.svg#p)"/></svg>`,
+			`<svg><style></style><style>rect { fill: red }</style><rect></rect></svg>`,
+		},
+		{
+			"Document types, comments and processing instructions are removed",
+			`<?xml version="1.0"?><!DOCTYPE svg [<!ENTITY x SYSTEM "file:///etc/passwd">]><!-- comment --><svg></svg>`,
+			`<svg></svg>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, sanitize(t, test.image))
+		})
+	}
+}
+
+func TestSanitizeInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, svg.Sanitize(&buf, strings.NewReader(`<svg><rect width="1`)))
+}
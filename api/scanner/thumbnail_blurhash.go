@@ -3,11 +3,12 @@ package scanner
 import (
 	"fmt"
 	"image"
-	"log"
 	"os"
 
 	"github.com/buckket/go-blurhash"
 	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
 
@@ -25,34 +26,30 @@ func GenerateBlurhashes(db *gorm.DB) error {
 		Where("media_urls.purpose = 'thumbnail' OR media_urls.purpose = 'video-thumbnail'")
 
 	err := query.FindInBatches(&results, 50, func(tx *gorm.DB, batch int) error {
-		log.Printf("generating %d blurhashes", len(results))
-
-		hashes := make([]*string, len(results))
+		log.Info(db.Statement.Context, "Generating blurhashes", "count", len(results))
 
 		for i, row := range results {
 
 			thumbnail, err := row.GetThumbnail()
 			if err != nil {
-				log.Printf("failed to get thumbnail for media to generate blurhash (%d): %v", row.ID, err)
+				log.Warn(db.Statement.Context, "Failed to get thumbnail for media to generate blurhash", "media_id", row.ID, "error", err)
 				processErrors = append(processErrors, err)
 				continue
 			}
 
 			hashStr, err := GenerateBlurhashFromThumbnail(thumbnail)
 			if err != nil {
-				log.Printf("failed to generate blurhash for media (%d): %v", row.ID, err)
+				log.Warn(db.Statement.Context, "Failed to generate blurhash for media", "media_id", row.ID, "error", err)
 				processErrors = append(processErrors, err)
 				continue
 			}
 
-			hashes[i] = &hashStr
 			results[i].Blurhash = &hashStr
 		}
 
-		tx.Save(results)
-		// if err := db.Update("blurhash", hashes).Error; err != nil {
-		// 	return err
-		// }
+		if err := tx.Save(results).Error; err != nil {
+			return errors.Wrap(err, "save blurhashes of media")
+		}
 
 		return nil
 	}).Error
@@ -79,6 +76,7 @@ func GenerateBlurhashFromThumbnail(thumbnail *models.MediaURL) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	defer imageFile.Close()
 
 	imageData, _, err := image.Decode(imageFile)
 	if err != nil {
@@ -1,57 +1,166 @@
 package main
 
 import (
-	"log"
+	"context"
 	"net/http"
+	"os"
 	"path"
+	"strings"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 
 	"github.com/joho/godotenv"
 
+	"github.com/photoview/photoview/api/config"
 	"github.com/photoview/photoview/api/database"
 	"github.com/photoview/photoview/api/dataloader"
+	"github.com/photoview/photoview/api/dlna"
+	"github.com/photoview/photoview/api/features"
+	"github.com/photoview/photoview/api/frontend"
 	"github.com/photoview/photoview/api/graphql/auth"
 	graphql_endpoint "github.com/photoview/photoview/api/graphql/endpoint"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/importer"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/mailin"
+	"github.com/photoview/photoview/api/notifier"
 	"github.com/photoview/photoview/api/routes"
+	"github.com/photoview/photoview/api/scanner/cache_warmup"
 	"github.com/photoview/photoview/api/scanner/exif"
 	"github.com/photoview/photoview/api/scanner/face_detection"
+	"github.com/photoview/photoview/api/scanner/library_watcher"
 	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
 	"github.com/photoview/photoview/api/scanner/periodic_scanner"
+	"github.com/photoview/photoview/api/scanner/scan_report"
 	"github.com/photoview/photoview/api/scanner/scanner_queue"
 	"github.com/photoview/photoview/api/server"
+	"github.com/photoview/photoview/api/settings"
+	"github.com/photoview/photoview/api/stats"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/photoview/photoview/api/tracing"
 	"github.com/photoview/photoview/api/utils"
+	"github.com/photoview/photoview/api/webhooks"
 
 	"github.com/99designs/gqlgen/graphql/playground"
 )
 
 func main() {
+	ctx := context.Background()
 
-	log.Println("Starting Photoview...")
+	envErr := godotenv.Load()
 
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	// Commands are run instead of the server
+	var cmd *command
+	if len(os.Args) > 1 {
+		if isHelp(os.Args[1]) {
+			printUsage(os.Stdout)
+			return
+		}
+
+		if cmd = findCommand(os.Args[1]); cmd == nil {
+			printUsage(os.Stderr)
+			log.Fatal(ctx, "Unknown command", "command", os.Args[1])
+		}
+	}
+
+	if cmd != nil && cmd.name == "config" {
+		if err := runConfigCommand(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(ctx, "Configuration check failed", "error", err)
+		}
+		return
+	}
+
+	// The environment takes precedence over the configuration file
+	configFile := config.FilePath()
+	var configErr error
+	if configFile != "" {
+		configErr = config.Load(configFile)
+	}
+
+	if err := log.InitializeLogging(utils.EnvLogLevel.GetValue(), utils.EnvLogFormat.GetValue()); err != nil {
+		log.Fatal(ctx, "Invalid logging configuration", "error", err)
+	}
+
+	if configErr != nil {
+		log.Fatal(ctx, "Could not load configuration file", "error", configErr)
+	}
+
+	if cmd == nil {
+		log.Info(ctx, "Starting Photoview...")
+	}
+
+	if envErr != nil {
+		log.Info(ctx, "No .env file found")
+	}
+
+	if configFile != "" {
+		log.Info(ctx, "Loaded configuration file", "path", configFile)
 	}
 
 	devMode := utils.DevelopmentMode()
 
+	shutdownTracing, err := tracing.InitializeTracing(ctx)
+	if err != nil {
+		log.Fatal(ctx, "Could not initialize tracing", "error", err)
+	}
+
 	db, err := database.SetupDatabase()
 	if err != nil {
-		log.Panicf("Could not connect to database: %s\n", err)
+		log.Fatal(ctx, "Could not connect to database", "error", err)
 	}
 
 	// Migrate database
 	if err := database.MigrateDatabase(db); err != nil {
-		log.Panicf("Could not migrate database: %s\n", err)
+		log.Fatal(ctx, "Could not migrate database", "error", err)
+	}
+
+	// Settings changed through the api override the environment
+	if err := settings.LoadSettings(db); err != nil {
+		log.Fatal(ctx, "Could not load settings", "error", err)
+	}
+
+	if err := log.InitializeLogging(utils.EnvLogLevel.GetValue(), utils.EnvLogFormat.GetValue()); err != nil {
+		log.Fatal(ctx, "Invalid logging configuration", "error", err)
 	}
 
+	if cmd != nil {
+		if err := cmd.run(db, os.Args[2:]); err != nil {
+			log.Fatal(ctx, "Command failed", "command", cmd.name, "error", err)
+		}
+		return
+	}
+
+	scan_report.InitializeScanReports(db)
+
 	if err := scanner_queue.InitializeScannerQueue(db); err != nil {
-		log.Panicf("Could not initialize scanner queue: %s\n", err)
+		log.Fatal(ctx, "Could not initialize scanner queue", "error", err)
 	}
 
 	if err := periodic_scanner.InitializePeriodicScanner(db); err != nil {
-		log.Panicf("Could not initialize periodic scanner: %s", err)
+		log.Fatal(ctx, "Could not initialize periodic scanner", "error", err)
+	}
+
+	if err := library_watcher.InitializeLibraryWatcher(db); err != nil {
+		log.Fatal(ctx, "Could not initialize library watcher", "error", err)
+	}
+
+	if err := storage.InitializeRetrievalQueue(db); err != nil {
+		log.Fatal(ctx, "Could not initialize cold storage retrieval queue", "error", err)
+	}
+
+	storage.InitializeCacheMonitor(db)
+	stats.InitializeStats(db)
+
+	if err := importer.InitializeImporter(db); err != nil {
+		log.Fatal(ctx, "Could not initialize importer", "error", err)
+	}
+
+	webhooks.InitializeWebhooks(db)
+	notifier.InitializeNotifier(db)
+
+	if err := mailin.InitializeMailIn(db); err != nil {
+		log.Fatal(ctx, "Could not initialize email-in gateway", "error", err)
 	}
 
 	executable_worker.InitializeExecutableWorkers()
@@ -59,11 +168,21 @@ func main() {
 	exif.InitializeEXIFParser()
 
 	if err := face_detection.InitializeFaceDetector(db); err != nil {
-		log.Panicf("Could not initialize face detector: %s\n", err)
+		log.Fatal(ctx, "Could not initialize face detector", "error", err)
+	}
+
+	cache_warmup.InitializeCacheWarmup(db)
+
+	if err := seedDemoOnStartup(ctx, db); err != nil {
+		log.Fatal(ctx, "Could not generate sample library", "error", err)
 	}
 
 	rootRouter := mux.NewRouter()
 
+	rootRouter.Use(server.RequestIDMiddleware)
+	if tracing.Enabled() {
+		rootRouter.Use(server.TracingMiddleware)
+	}
 	rootRouter.Use(dataloader.Middleware(db))
 	rootRouter.Use(auth.Middleware(db))
 	rootRouter.Use(server.LoggingMiddleware)
@@ -92,32 +211,75 @@ func main() {
 	downloadsRouter := endpointRouter.PathPrefix("/download").Subrouter()
 	routes.RegisterDownloadRoutes(db, downloadsRouter)
 
+	uploadRouter := endpointRouter.PathPrefix("/upload").Subrouter()
+	routes.RegisterUploadRoutes(db, uploadRouter)
+
+	castRouter := endpointRouter.PathPrefix("/cast").Subrouter()
+	routes.RegisterCastRoutes(db, castRouter)
+
+	frameRouter := endpointRouter.PathPrefix("/frame").Subrouter()
+	routes.RegisterPhotoFrameRoutes(db, frameRouter)
+
+	feedRouter := endpointRouter.PathPrefix("/feed").Subrouter()
+	routes.RegisterFeedRoutes(db, feedRouter)
+
+	restRouter := endpointRouter.PathPrefix("/rest/v1").Subrouter()
+	routes.RegisterRESTRoutes(db, restRouter)
+
+	webdavRouter := endpointRouter.PathPrefix("/webdav").Subrouter()
+	routes.RegisterWebDAVRoutes(db, webdavRouter, path.Join(apiListenURL.Path, "/webdav"))
+
+	if features.Enabled(models.FeatureDlna) {
+		dlnaRouter := endpointRouter.PathPrefix("/dlna").Subrouter()
+		if err := dlna.InitializeDLNA(db, dlnaRouter, path.Join(apiListenURL.Path, "/dlna"), apiListenURL.Port()); err != nil {
+			log.Fatal(ctx, "Could not initialize DLNA server", "error", err)
+		}
+	}
+
 	shouldServeUI := utils.ShouldServeUI()
 
 	if shouldServeUI {
-		spa := routes.NewSpaHandler(utils.UIPath(), "index.html")
-		rootRouter.PathPrefix("/").Handler(spa)
+		// The ui embedded into the binary is served, unless a directory to serve it from is given
+		uiFS := frontend.Assets()
+		if uiFS == nil || utils.EnvUIPath.GetValue() != "" {
+			uiFS = os.DirFS(utils.UIPath())
+			log.Info(ctx, "Serving ui from directory", "path", utils.UIPath())
+		} else {
+			log.Info(ctx, "Serving embedded ui")
+		}
+
+		uiBasePath := utils.UIBasePath()
+		spa := routes.NewSpaHandler(uiFS, "index.html")
+		rootRouter.PathPrefix(uiBasePath).Handler(http.StripPrefix(strings.TrimSuffix(uiBasePath, "/"), spa))
+
+		if uiBasePath != "/" {
+			rootRouter.Path(strings.TrimSuffix(uiBasePath, "/")).Handler(http.RedirectHandler(uiBasePath, http.StatusMovedPermanently))
+		}
 	}
 
 	if devMode {
-		log.Printf("🚀 Graphql playground ready at %s\n", apiListenURL.String())
+		log.Info(ctx, "🚀 Graphql playground ready", "url", apiListenURL.String())
 	} else {
-		log.Printf("Photoview API endpoint listening at %s\n", apiListenURL.String())
+		log.Info(ctx, "Photoview API endpoint listening", "url", apiListenURL.String())
 
 		apiEndpoint := utils.ApiEndpointUrl()
-		log.Printf("Photoview API public endpoint ready at %s\n", apiEndpoint.String())
+		log.Info(ctx, "Photoview API public endpoint ready", "url", apiEndpoint.String())
 
 		if uiEndpoint := utils.UiEndpointUrl(); uiEndpoint != nil {
-			log.Printf("Photoview UI public endpoint ready at %s\n", uiEndpoint.String())
+			log.Info(ctx, "Photoview UI public endpoint ready", "url", uiEndpoint.String())
+		} else if publicURL := utils.PublicURL(); publicURL != nil {
+			log.Info(ctx, "Photoview UI public endpoint ready", "url", publicURL.String())
 		} else {
-			log.Println("Photoview UI public endpoint ready at /")
+			log.Info(ctx, "Photoview UI public endpoint ready", "url", utils.UIBasePath())
 		}
 
 		if !shouldServeUI {
-			log.Printf("Notice: UI is not served by the the api (%s=0)", utils.EnvServeUI.GetName())
+			log.Info(ctx, "Notice: UI is not served by the api", "env", utils.EnvServeUI.GetName()+"=0")
 		}
 
 	}
 
-	log.Panic(http.ListenAndServe(":"+apiListenURL.Port(), handlers.CompressHandler(rootRouter)))
+	err = server.ListenAndServe(ctx, apiListenURL.Port(), handlers.CompressHandler(rootRouter))
+	shutdownTracing(ctx)
+	log.Fatal(ctx, "HTTP server stopped", "error", err)
 }
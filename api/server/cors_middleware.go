@@ -30,9 +30,9 @@ func CORSMiddleware(devMode bool) mux.MiddlewareFunc {
 
 			corsEnabled := devMode || uiEndpoint != nil
 			if corsEnabled {
-				methods := []string{http.MethodGet, http.MethodPost, http.MethodOptions}
-				requestHeaders := []string{"authorization", "content-type", "content-length", "TokenPassword"}
-				responseHeaders := []string{"content-length"}
+				methods := []string{http.MethodGet, http.MethodPost, http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodDelete, http.MethodPut, "PROPFIND", "MKCOL"}
+				requestHeaders := []string{"authorization", "content-type", "content-length", "TokenPassword", "Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset", "Depth", "Destination", "Overwrite"}
+				responseHeaders := []string{"content-length", "Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Upload-Length", "Upload-Offset", "Photoview-Media-Id"}
 
 				w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
 				w.Header().Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
@@ -40,10 +40,11 @@ func CORSMiddleware(devMode bool) mux.MiddlewareFunc {
 				w.Header().Set("Access-Control-Expose-Headers", strings.Join(responseHeaders, ", "))
 			}
 
-			if req.Method != http.MethodOptions {
-				next.ServeHTTP(w, req)
-			} else {
+			// Only answer CORS preflight requests here, other OPTIONS requests (eg. from WebDAV clients) are passed on
+			if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
 				w.WriteHeader(200)
+			} else {
+				next.ServeHTTP(w, req)
 			}
 		})
 	}
@@ -3,15 +3,45 @@ package server
 import (
 	"bufio"
 	"errors"
-	"fmt"
 	"net"
 	"net/http"
 	"time"
 
 	"github.com/photoview/photoview/api/graphql/auth"
-	"github.com/wsxiaoys/terminal/color"
+	"github.com/photoview/photoview/api/log"
 )
 
+// RequestIDMiddleware gives every request an id, which is included in all messages logged while handling it.
+// An id passed by a reverse proxy in the X-Request-ID header is reused, so logs can be correlated across services.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = log.NewRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(log.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// RequestIDHeader is the http header holding the id of a request
+const RequestIDHeader = "X-Request-ID"
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 64 {
+		return false
+	}
+
+	for _, c := range requestID {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -19,49 +49,23 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		statusWriter := newStatusResponseWriter(&w)
 		next.ServeHTTP(statusWriter, r)
 
-		elapsed := time.Since(start)
-		date := time.Now().Format("2006/01/02 15:04:05")
-
-		status := statusWriter.status
-		var statusColor string
-		switch {
-		case status < 200:
-			statusColor = color.Colorize("b")
-		case status < 300:
-			statusColor = color.Colorize("g")
-		case status < 400:
-			statusColor = color.Colorize("c")
-		case status < 500:
-			statusColor = color.Colorize("y")
-		default:
-			statusColor = color.Colorize("r")
+		args := []interface{}{
+			"method", r.Method,
+			"status", statusWriter.status,
+			"host", r.Host,
+			"path", r.URL.Path,
+			"duration", time.Since(start),
 		}
 
-		method := r.Method
-		var methodColor string
-		switch {
-		case method == http.MethodGet:
-			methodColor = color.Colorize("b")
-		case method == http.MethodPost:
-			methodColor = color.Colorize("g")
-		case method == http.MethodOptions:
-			methodColor = color.Colorize("y")
-		default:
-			methodColor = color.Colorize("r")
+		if user := auth.UserFromContext(r.Context()); user != nil {
+			args = append(args, "user", user.Username)
 		}
 
-		user := auth.UserFromContext(r.Context())
-		userText := "unauthenticated"
-		if user != nil {
-			userText = color.Sprintf("@ruser: %s", user.Username)
+		if statusWriter.status >= 500 {
+			log.Warn(r.Context(), "request", args...)
+		} else {
+			log.Info(r.Context(), "request", args...)
 		}
-
-		statusText := color.Sprintf("%s%s %s%d", methodColor, r.Method, statusColor, status)
-		requestText := fmt.Sprintf("%s%s", r.Host, r.URL.Path)
-		durationText := color.Sprintf("@c%s", elapsed)
-
-		fmt.Printf("%s %s %s %s %s\n", date, statusText, requestText, durationText, userText)
-
 	})
 }
 
@@ -72,9 +76,12 @@ type statusResponseWriter struct {
 }
 
 func newStatusResponseWriter(w *http.ResponseWriter) *statusResponseWriter {
+	// Responses over HTTP/2, such as when serving over TLS, can not be hijacked
+	hijacker, _ := (*w).(http.Hijacker)
+
 	return &statusResponseWriter{
 		ResponseWriter: *w,
-		hijacker:       (*w).(http.Hijacker),
+		hijacker:       hijacker,
 	}
 }
 
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Directory certificates obtained over ACME are stored in, if PHOTOVIEW_TLS_ACME_CACHE is not set
+const defaultACMECache = "./certificates"
+
+// TLSMode is how the server gets its certificate
+type TLSMode int
+
+const (
+	TLSDisabled TLSMode = iota
+	// TLSCertificateFiles serves the certificate and key files given by PHOTOVIEW_TLS_CERT and PHOTOVIEW_TLS_KEY
+	TLSCertificateFiles
+	// TLSACME obtains and renews certificates for the domains in PHOTOVIEW_TLS_ACME_DOMAINS automatically
+	TLSACME
+)
+
+// GetTLSMode returns how TLS has been configured, or an error if it has been configured inconsistently
+func GetTLSMode() (TLSMode, error) {
+	certFile := utils.EnvTLSCert.GetValue()
+	keyFile := utils.EnvTLSKey.GetValue()
+	domains := acmeDomains()
+
+	if (certFile == "") != (keyFile == "") {
+		return TLSDisabled, errors.Errorf("both %s and %s must be set", utils.EnvTLSCert.GetName(), utils.EnvTLSKey.GetName())
+	}
+
+	switch {
+	case certFile != "" && len(domains) > 0:
+		return TLSDisabled, errors.Errorf("%s can not be used along with certificate files", utils.EnvTLSACMEDomains.GetName())
+	case certFile != "":
+		return TLSCertificateFiles, nil
+	case len(domains) > 0:
+		return TLSACME, nil
+	default:
+		return TLSDisabled, nil
+	}
+}
+
+func acmeDomains() []string {
+	domains := make([]string, 0)
+	for _, domain := range strings.Split(utils.EnvTLSACMEDomains.GetValue(), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	return domains
+}
+
+// ListenAndServe serves the handler on the port, over TLS if it has been configured.
+// If PHOTOVIEW_TLS_REDIRECT_PORT is set, plain HTTP requests to that port are redirected to HTTPS,
+// which is also where ACME HTTP-01 challenges are answered.
+func ListenAndServe(ctx context.Context, port string, handler http.Handler) error {
+	mode, err := GetTLSMode()
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: ":" + port, Handler: handler}
+
+	if mode == TLSDisabled {
+		return srv.ListenAndServe()
+	}
+
+	redirect := RedirectToHTTPS(port)
+
+	switch mode {
+	case TLSCertificateFiles:
+		// Certificates are loaded on every handshake, so renewed certificates are picked up without restarting
+		certFile, keyFile := utils.EnvTLSCert.GetValue(), utils.EnvTLSKey.GetValue()
+		if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return errors.Wrap(err, "load tls certificate")
+		}
+
+		srv.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+				return &cert, err
+			},
+		}
+	case TLSACME:
+		cacheDir := utils.EnvTLSACMECache.GetValue()
+		if cacheDir == "" {
+			cacheDir = defaultACMECache
+		}
+
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(acmeDomains()...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      utils.EnvTLSACMEEmail.GetValue(),
+		}
+		if directory := utils.EnvTLSACMEDirectory.GetValue(); directory != "" {
+			manager.Client = &acme.Client{DirectoryURL: directory}
+		}
+
+		srv.TLSConfig = manager.TLSConfig()
+		srv.TLSConfig.MinVersion = tls.VersionTLS12
+		redirect = manager.HTTPHandler(redirect)
+
+		log.Info(ctx, "Obtaining certificates over ACME", "domains", strings.Join(acmeDomains(), ","), "cache", cacheDir)
+	}
+
+	if redirectPort := utils.EnvTLSRedirectPort.GetValue(); redirectPort != "" {
+		go func() {
+			log.Info(ctx, "Redirecting HTTP to HTTPS", "port", redirectPort)
+			if err := http.ListenAndServe(":"+redirectPort, redirect); err != nil {
+				log.Error(ctx, "HTTP redirect server stopped", "error", err)
+			}
+		}()
+	}
+
+	return srv.ListenAndServeTLS("", "")
+}
+
+// RedirectToHTTPS redirects requests to the same url over HTTPS, on the port the server listens for HTTPS on
+func RedirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
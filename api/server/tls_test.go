@@ -0,0 +1,62 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/photoview/photoview/api/server"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTLSMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[utils.EnvironmentVariable]string
+		mode    server.TLSMode
+		invalid bool
+	}{
+		{"Disabled", map[utils.EnvironmentVariable]string{}, server.TLSDisabled, false},
+		{"Certificate files", map[utils.EnvironmentVariable]string{utils.EnvTLSCert: "cert.pem", utils.EnvTLSKey: "key.pem"}, server.TLSCertificateFiles, false},
+		{"ACME", map[utils.EnvironmentVariable]string{utils.EnvTLSACMEDomains: "photos.example.com, www.photos.example.com"}, server.TLSACME, false},
+		{"Certificate without key", map[utils.EnvironmentVariable]string{utils.EnvTLSCert: "cert.pem"}, server.TLSDisabled, true},
+		{"Both", map[utils.EnvironmentVariable]string{utils.EnvTLSCert: "cert.pem", utils.EnvTLSKey: "key.pem", utils.EnvTLSACMEDomains: "photos.example.com"}, server.TLSDisabled, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, variable := range []utils.EnvironmentVariable{utils.EnvTLSCert, utils.EnvTLSKey, utils.EnvTLSACMEDomains} {
+				t.Setenv(variable.GetName(), test.env[variable])
+			}
+
+			mode, err := server.GetTLSMode()
+			assert.Equal(t, test.mode, mode)
+			if test.invalid {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		port     string
+		url      string
+		location string
+	}{
+		{"443", "http://photos.example.com/album/1?page=2", "https://photos.example.com/album/1?page=2"},
+		{"443", "http://photos.example.com:80/", "https://photos.example.com/"},
+		{"8443", "http://photos.example.com:8080/api/graphql", "https://photos.example.com:8443/api/graphql"},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		server.RedirectToHTTPS(test.port).ServeHTTP(rr, httptest.NewRequest("GET", test.url, nil))
+
+		assert.Equal(t, http.StatusMovedPermanently, rr.Code)
+		assert.Equal(t, test.location, rr.Header().Get("Location"))
+	}
+}
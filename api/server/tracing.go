@@ -0,0 +1,46 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// TracingMiddleware records a span for every request, which the spans of resolvers and database queries are part of.
+// The id of the trace is included in all messages logged while handling the request, so logs can be matched with traces.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Route templates rather than paths are used for span names, so requests of different media are grouped together
+		route := r.URL.Path
+		if currentRoute := mux.CurrentRoute(r); currentRoute != nil {
+			if template, err := currentRoute.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx, span := tracing.StartRequest(r, route)
+
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			ctx = log.WithAttrs(ctx, "trace_id", traceID)
+		}
+
+		statusWriter := newStatusResponseWriter(&w)
+		next.ServeHTTP(statusWriter, r.WithContext(ctx))
+
+		status := statusWriter.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+
+		var err error
+		if status >= 500 {
+			err = errors.New(http.StatusText(status))
+		}
+		tracing.EndSpan(span, err)
+	})
+}
@@ -1,11 +1,11 @@
 package server
 
 import (
-	"log"
 	"net/http"
 	"net/url"
 
 	"github.com/gorilla/websocket"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/utils"
 )
 
@@ -26,14 +26,14 @@ func WebsocketUpgrader(devMode bool) websocket.Upgrader {
 
 				originURL, err := url.Parse(r.Header.Get("origin"))
 				if err != nil {
-					log.Printf("Could not parse origin header of websocket request: %s", err)
+					log.Warn(r.Context(), "Could not parse origin header of websocket request", "error", err)
 					return false
 				}
 
 				if uiEndpoint.Host == originURL.Host {
 					return true
 				} else {
-					log.Printf("Not allowing websocket request from %s because it doesn't match PHOTOVIEW_UI_ENDPOINT %s", originURL.Host, uiEndpoint.Host)
+					log.Warn(r.Context(), "Not allowing websocket request because origin doesn't match PHOTOVIEW_UI_ENDPOINT", "origin", originURL.Host, "ui_endpoint", uiEndpoint.Host)
 					return false
 				}
 			}
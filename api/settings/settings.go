@@ -0,0 +1,339 @@
+// Package settings lets admins change configuration, otherwise given by environment variables, through the api.
+// Settings are stored in the settings table, override the environment variable of the same name,
+// and are applied right away, without restarting the server.
+package settings
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner/face_detection"
+	"github.com/photoview/photoview/api/scanner/media_encoding/executable_worker"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type settingKind int
+
+const (
+	kindBool settingKind = iota
+	kindDirectory
+	kindOption
+	kindNumber
+)
+
+// definition describes an environment variable that can be changed at runtime
+type definition struct {
+	variable    utils.EnvironmentVariable
+	description string
+	kind        settingKind
+	// options are the allowed values of a kindOption setting
+	options []string
+	// min and max are the range of the values of a kindNumber setting, a value of 0 is also allowed if zeroAllowed is set
+	min, max    int
+	zeroAllowed bool
+	// apply is called after the value has changed, to reload the parts of the server using it.
+	// Settings read every time they are used need no apply function.
+	apply func(db *gorm.DB) error
+}
+
+var definitions = []*definition{
+	{
+		variable:    utils.EnvMediaCachePath,
+		description: "Path where thumbnails and encoded videos are cached, media already cached is not moved",
+		kind:        kindDirectory,
+	},
+	{
+		variable:    utils.EnvDisableFaceRecognition,
+		description: "Disable face recognition",
+		kind:        kindBool,
+		apply:       face_detection.InitializeFaceDetector,
+	},
+	{
+		variable:    utils.EnvDisableVideoEncoding,
+		description: "Disable encoding of videos with ffmpeg",
+		kind:        kindBool,
+		apply:       reloadExecutableWorkers,
+	},
+	{
+		variable:    utils.EnvDisableRawProcessing,
+		description: "Disable processing of raw photos with darktable",
+		kind:        kindBool,
+		apply:       reloadExecutableWorkers,
+	},
+	{
+		variable:    utils.EnvVideoCodec,
+		description: "Codec of the web versions of videos browsers can't play, vp9 videos are smaller but much slower to encode",
+		kind:        kindOption,
+		options:     []string{"h264", "vp9"},
+	},
+	{
+		variable:    utils.EnvImageFormat,
+		description: "Format of the thumbnails and web copies of photos generated from now on, webp images are smaller and need cwebp",
+		kind:        kindOption,
+		options:     []string{"jpeg", "webp"},
+	},
+	{
+		variable:    utils.EnvEncodeAVIF,
+		description: "Also encode the thumbnails and web copies of photos as AVIF, served to browsers that support it, needs avifenc",
+		kind:        kindBool,
+	},
+	{
+		variable:    utils.EnvImageBackend,
+		description: "What scales photos down to thumbnails: go, the built-in image pipeline, or vips, which needs vipsthumbnail",
+		kind:        kindOption,
+		options:     []string{"go", "vips"},
+	},
+	{
+		variable:    utils.EnvThumbnailQuality,
+		description: "Quality of the thumbnails and scaled copies of photos generated from now on, from 1 to 100",
+		kind:        kindNumber,
+		min:         1,
+		max:         100,
+	},
+	{
+		variable:    utils.EnvThumbnailMaxSize,
+		description: "Size in pixels of the longest side of the thumbnails of photos generated from now on",
+		kind:        kindNumber,
+		min:         128,
+		max:         4096,
+	},
+	{
+		variable:    utils.EnvWebVersionQuality,
+		description: "Quality of the web versions of photos browsers can't display, generated from now on, from 1 to 100",
+		kind:        kindNumber,
+		min:         1,
+		max:         100,
+	},
+	{
+		variable:    utils.EnvWebVersionMaxSize,
+		description: "Largest size in pixels of the longest side of the web versions of photos generated from now on, 0 keeps their full size",
+		kind:        kindNumber,
+		min:         512,
+		max:         16384,
+		zeroAllowed: true,
+	},
+	{
+		variable:    utils.EnvEnableDLNA,
+		description: "Serve media to DLNA players on the local network, takes effect after restarting the server",
+		kind:        kindBool,
+	},
+	{
+		variable:    utils.EnvEnableGPUThumbnails,
+		description: "Scale photos down to thumbnails on the graphics card set by the video hardware acceleration, needs ffmpeg",
+		kind:        kindBool,
+	},
+	{
+		variable:    utils.EnvShareStripMetadata,
+		description: "Remove GPS coordinates and other metadata from photos served through share links, unless the share sets otherwise",
+		kind:        kindBool,
+	},
+	{
+		variable:    utils.EnvWebDAVWritable,
+		description: "Allow media to be uploaded over WebDAV",
+		kind:        kindBool,
+	},
+	{
+		variable:    utils.EnvMaxUploadSize,
+		description: "Largest size in megabytes of the files uploaded through the web interface and the api, 0 doesn't limit it",
+		kind:        kindNumber,
+		min:         1,
+		max:         1048576,
+		zeroAllowed: true,
+	},
+	{
+		variable:    utils.EnvLogLevel,
+		description: "Minimum level of the messages logged by the server",
+		kind:        kindOption,
+		options:     []string{"debug", "info", "warn", "error"},
+		apply:       reloadLogging,
+	},
+	{
+		variable:    utils.EnvLogFormat,
+		description: "Format of the messages logged by the server",
+		kind:        kindOption,
+		options:     []string{"text", "json"},
+		apply:       reloadLogging,
+	},
+}
+
+func reloadExecutableWorkers(db *gorm.DB) error {
+	executable_worker.InitializeExecutableWorkers()
+	return nil
+}
+
+func reloadLogging(db *gorm.DB) error {
+	level := utils.EnvLogLevel.GetValue()
+	if level == "" {
+		level = log.LevelInfo.String()
+	}
+
+	format := utils.EnvLogFormat.GetValue()
+	if format == "" {
+		format = "text"
+	}
+
+	return log.InitializeLogging(level, format)
+}
+
+func findDefinition(key string) *definition {
+	for _, def := range definitions {
+		if def.variable.GetName() == key {
+			return def
+		}
+	}
+
+	return nil
+}
+
+// LoadSettings overrides the environment with the settings stored in the database.
+// It should be called at startup, before the parts of the server using the settings are initialized.
+func LoadSettings(db *gorm.DB) error {
+	var settings []*models.Setting
+	if err := db.Find(&settings).Error; err != nil {
+		return errors.Wrap(err, "get settings from database")
+	}
+
+	for _, setting := range settings {
+		def := findDefinition(setting.Key)
+		if def == nil {
+			log.Warn(db.Statement.Context, "Ignoring unknown setting", "key", setting.Key)
+			continue
+		}
+
+		value := setting.Value
+		def.variable.SetOverride(&value)
+	}
+
+	return nil
+}
+
+// GetSettings returns all settings that can be changed at runtime, with their current values
+func GetSettings() []*models.SiteSetting {
+	result := make([]*models.SiteSetting, len(definitions))
+	for i, def := range definitions {
+		result[i] = def.siteSetting()
+	}
+
+	return result
+}
+
+// UpdateSetting changes the value of a setting and applies it, a nil value removes the setting,
+// so the value of the environment variable is used again
+func UpdateSetting(db *gorm.DB, key string, value *string) (*models.SiteSetting, error) {
+	def := findDefinition(key)
+	if def == nil {
+		return nil, errors.Errorf("unknown setting: %s", key)
+	}
+
+	if value != nil {
+		normalized, err := def.normalize(*value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for %s", key)
+		}
+		value = &normalized
+	}
+
+	previous := def.variable.GetOverride()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if value == nil {
+			if err := tx.Delete(&models.Setting{Key: key}).Error; err != nil {
+				return errors.Wrap(err, "delete setting")
+			}
+		} else {
+			if err := tx.Save(&models.Setting{Key: key, Value: *value}).Error; err != nil {
+				return errors.Wrap(err, "save setting")
+			}
+		}
+
+		def.variable.SetOverride(value)
+
+		if def.apply != nil {
+			if err := def.apply(tx); err != nil {
+				return errors.Wrapf(err, "apply %s", key)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		// Return to the previous value, so the server keeps running with a working configuration
+		def.variable.SetOverride(previous)
+		if def.apply != nil {
+			if err := def.apply(db); err != nil {
+				log.Error(db.Statement.Context, "Could not restore previous setting", "key", key, "error", err)
+			}
+		}
+
+		return nil, err
+	}
+
+	log.Info(db.Statement.Context, "Setting changed", "key", key, "value", def.variable.GetValue())
+	return def.siteSetting(), nil
+}
+
+// normalize validates a value for the setting, and returns it in the form it should be stored in
+func (def *definition) normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	switch def.kind {
+	case kindBool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", errors.New("must be true or false")
+		}
+		if parsed {
+			return "1", nil
+		}
+		return "0", nil
+	case kindOption:
+		value = strings.ToLower(value)
+		for _, option := range def.options {
+			if value == option {
+				return value, nil
+			}
+		}
+		return "", errors.Errorf("must be one of: %s", strings.Join(def.options, ", "))
+	case kindNumber:
+		number, err := strconv.Atoi(value)
+		if err != nil || ((number < def.min || number > def.max) && !(number == 0 && def.zeroAllowed)) {
+			if def.zeroAllowed {
+				return "", errors.Errorf("must be 0 or a number from %d to %d", def.min, def.max)
+			}
+			return "", errors.Errorf("must be a number from %d to %d", def.min, def.max)
+		}
+		return strconv.Itoa(number), nil
+	case kindDirectory:
+		if value == "" {
+			return "", errors.New("must not be empty")
+		}
+		if err := os.MkdirAll(value, 0755); err != nil {
+			return "", errors.Wrap(err, "create directory")
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+func (def *definition) siteSetting() *models.SiteSetting {
+	setting := &models.SiteSetting{
+		Key:         def.variable.GetName(),
+		Description: def.description,
+		Value:       def.variable.GetValue(),
+		Overridden:  def.variable.GetOverride() != nil,
+		Options:     def.options,
+	}
+
+	if envValue := def.variable.GetEnvironmentValue(); envValue != "" {
+		setting.EnvironmentValue = &envValue
+	}
+
+	return setting
+}
@@ -0,0 +1,140 @@
+package settings_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/settings"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func findSetting(key string) *models.SiteSetting {
+	for _, setting := range settings.GetSettings() {
+		if setting.Key == key {
+			return setting
+		}
+	}
+
+	return nil
+}
+
+func TestUpdateSetting(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	defer utils.EnvWebDAVWritable.SetOverride(nil)
+
+	t.Setenv(utils.EnvWebDAVWritable.GetName(), "0")
+
+	setting := findSetting(utils.EnvWebDAVWritable.GetName())
+	if assert.NotNil(t, setting) {
+		assert.False(t, setting.Overridden)
+		assert.Equal(t, "0", setting.Value)
+	}
+
+	value := "true"
+	setting, err := settings.UpdateSetting(db, utils.EnvWebDAVWritable.GetName(), &value)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, setting.Overridden)
+	assert.Equal(t, "1", setting.Value)
+	assert.Equal(t, "0", *setting.EnvironmentValue)
+	assert.True(t, utils.EnvWebDAVWritable.GetBool(), "setting should be applied right away")
+
+	var stored models.Setting
+	if assert.NoError(t, db.First(&stored, "key = ?", utils.EnvWebDAVWritable.GetName()).Error) {
+		assert.Equal(t, "1", stored.Value)
+	}
+
+	t.Run("Loaded at startup", func(t *testing.T) {
+		utils.EnvWebDAVWritable.SetOverride(nil)
+		assert.False(t, utils.EnvWebDAVWritable.GetBool())
+
+		assert.NoError(t, settings.LoadSettings(db))
+		assert.True(t, utils.EnvWebDAVWritable.GetBool())
+	})
+
+	t.Run("Reset to environment", func(t *testing.T) {
+		setting, err := settings.UpdateSetting(db, utils.EnvWebDAVWritable.GetName(), nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.False(t, setting.Overridden)
+		assert.False(t, utils.EnvWebDAVWritable.GetBool())
+
+		var count int64
+		assert.NoError(t, db.Model(&models.Setting{}).Count(&count).Error)
+		assert.EqualValues(t, 0, count)
+	})
+}
+
+func TestUpdateSettingValidation(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	invalid := "maybe"
+	_, err := settings.UpdateSetting(db, utils.EnvWebDAVWritable.GetName(), &invalid)
+	assert.Error(t, err)
+
+	_, err = settings.UpdateSetting(db, utils.EnvLogLevel.GetName(), &invalid)
+	assert.Error(t, err)
+
+	_, err = settings.UpdateSetting(db, utils.EnvDatabaseDriver.GetName(), &invalid)
+	assert.Error(t, err, "settings needing a restart should not be changeable")
+
+	outOfRange := "101"
+	_, err = settings.UpdateSetting(db, utils.EnvThumbnailQuality.GetName(), &outOfRange)
+	assert.Error(t, err)
+
+	_, err = settings.UpdateSetting(db, utils.EnvThumbnailQuality.GetName(), &invalid)
+	assert.Error(t, err)
+
+	var count int64
+	assert.NoError(t, db.Model(&models.Setting{}).Count(&count).Error)
+	assert.EqualValues(t, 0, count)
+}
+
+func TestLogLevelSetting(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	defer log.SetLevel(log.LevelInfo)
+	defer utils.EnvLogLevel.SetOverride(nil)
+
+	level := "DEBUG"
+	setting, err := settings.UpdateSetting(db, utils.EnvLogLevel.GetName(), &level)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "debug", setting.Value)
+	assert.Equal(t, log.LevelDebug, log.GetLevel())
+}
+
+func TestNumberSetting(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	defer utils.EnvWebVersionMaxSize.SetOverride(nil)
+
+	size := " 2048 "
+	setting, err := settings.UpdateSetting(db, utils.EnvWebVersionMaxSize.GetName(), &size)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "2048", setting.Value)
+	assert.Equal(t, 2048, utils.EnvWebVersionMaxSize.GetNumber(0, 512, 16384))
+
+	// Web versions are kept at their full size with a value of 0
+	size = "0"
+	_, err = settings.UpdateSetting(db, utils.EnvWebVersionMaxSize.GetName(), &size)
+	assert.NoError(t, err)
+
+	size = "100"
+	_, err = settings.UpdateSetting(db, utils.EnvWebVersionMaxSize.GetName(), &size)
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// runShareCommand lists the share links of a user, or of all users, with what they share and when they expire.
+//
+//	photoview share list -user admin
+func runShareCommand(db *gorm.DB, args []string) error {
+	if _, err := expectSubcommand(args, "list"); err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("share list", flag.ContinueOnError)
+	username := flags.String("user", "", "only list the shares of this user")
+	expired := flags.Bool("expired", false, "include shares that have expired")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	query := db.Preload("Owner").Preload("Album").Preload("Media").Order("share_tokens.id")
+	if *username != "" {
+		var user models.User
+		if err := db.Where("username = ?", *username).First(&user).Error; err != nil {
+			return errors.Wrapf(err, "find user %s", *username)
+		}
+		query = query.Where("share_tokens.owner_id = ?", user.ID)
+	}
+	if !*expired {
+		query = query.Where("share_tokens.expire IS NULL OR share_tokens.expire > ?", time.Now())
+	}
+
+	var shares []*models.ShareToken
+	if err := query.Find(&shares).Error; err != nil {
+		return errors.Wrap(err, "get shares from database")
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "TOKEN\tOWNER\tSHARED\tEXPIRES\tPASSWORD")
+	for _, share := range shares {
+		shared := "-"
+		if share.Album != nil {
+			shared = "album " + share.Album.Path
+		} else if share.Media != nil {
+			shared = "media " + share.Media.Path
+		}
+
+		expires := "never"
+		if share.Expire != nil {
+			expires = share.Expire.Format("2006-01-02")
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%t\n", share.Value, share.Owner.Username, shared, expires, share.Password != nil)
+	}
+
+	return table.Flush()
+}
@@ -0,0 +1,85 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// How often the cache accesses counted in memory are added to the database
+const cacheAccessFlushInterval = time.Minute
+
+var (
+	cacheAccessLock = &sync.Mutex{}
+	cacheHits       int64
+	cacheMisses     int64
+)
+
+// RecordCacheAccess counts a request for a cached file, a miss being a file that had to be generated again
+func RecordCacheAccess(hit bool) {
+	cacheAccessLock.Lock()
+	defer cacheAccessLock.Unlock()
+
+	if hit {
+		cacheHits++
+	} else {
+		cacheMisses++
+	}
+}
+
+// InitializeStats starts a background worker adding the cache accesses counted in memory to the database
+func InitializeStats(db *gorm.DB) {
+	go func() {
+		for {
+			time.Sleep(cacheAccessFlushInterval)
+
+			if err := FlushCacheAccess(db); err != nil {
+				log.Warn(context.Background(), "Saving cache access statistics", "error", err)
+			}
+		}
+	}()
+}
+
+// FlushCacheAccess adds the cache accesses counted in memory to the statistics of the day in the database
+func FlushCacheAccess(db *gorm.DB) error {
+	cacheAccessLock.Lock()
+	hits, misses := cacheHits, cacheMisses
+	cacheHits, cacheMisses = 0, 0
+	cacheAccessLock.Unlock()
+
+	if hits == 0 && misses == 0 {
+		return nil
+	}
+
+	day := models.CacheAccessStats{
+		Date:   time.Now().Format("2006-01-02"),
+		Hits:   hits,
+		Misses: misses,
+	}
+
+	// Instances sharing the database add to the same row
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "date"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"hits":   gorm.Expr("cache_access_stats.hits + ?", hits),
+			"misses": gorm.Expr("cache_access_stats.misses + ?", misses),
+		}),
+	}).Create(&day).Error
+	if err != nil {
+		// Keep the counts, so they are added with the next flush
+		cacheAccessLock.Lock()
+		cacheHits += hits
+		cacheMisses += misses
+		cacheAccessLock.Unlock()
+
+		return errors.Wrap(err, "save cache access statistics")
+	}
+
+	return nil
+}
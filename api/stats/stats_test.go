@@ -0,0 +1,103 @@
+package stats_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/stats"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestUsageStatistics(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	user, err := models.RegisterUser(db, "user", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	assert.NoError(t, db.Save(&album).Error)
+	assert.NoError(t, db.Model(user).Association("Albums").Append(&album))
+
+	camera, maker := "EOS 5D", "Canon"
+	exif := models.MediaEXIF{Camera: &camera, Maker: &maker}
+	assert.NoError(t, db.Save(&exif).Error)
+
+	media := []models.Media{
+		{Title: "a.jpg", Path: "/photos/a.jpg", AlbumID: album.ID, Type: models.MediaTypePhoto, ExifID: &exif.ID},
+		{Title: "b.jpg", Path: "/photos/b.jpg", AlbumID: album.ID, Type: models.MediaTypePhoto, ExifID: &exif.ID},
+		{Title: "c.mp4", Path: "/photos/c.mp4", AlbumID: album.ID, Type: models.MediaTypeVideo},
+	}
+	assert.NoError(t, db.Save(&media).Error)
+
+	// Added long before the growth period, so it only counts towards the total
+	old := models.Media{Title: "old.jpg", Path: "/photos/old.jpg", AlbumID: album.ID, Type: models.MediaTypePhoto}
+	assert.NoError(t, db.Save(&old).Error)
+	assert.NoError(t, db.Model(&old).UpdateColumn("created_at", time.Now().AddDate(-2, 0, 0)).Error)
+
+	assert.NoError(t, db.Create(&models.ScanReport{FailureCount: 2}).Error)
+
+	stats.RecordCacheAccess(true)
+	stats.RecordCacheAccess(true)
+	stats.RecordCacheAccess(true)
+	stats.RecordCacheAccess(false)
+	assert.NoError(t, stats.FlushCacheAccess(db))
+	stats.RecordCacheAccess(false)
+	assert.NoError(t, stats.FlushCacheAccess(db))
+
+	usage, err := stats.GetUsageStatistics(db, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 1, usage.UserCount)
+	assert.Equal(t, 1, usage.AlbumCount)
+	assert.Equal(t, 3, usage.PhotoCount)
+	assert.Equal(t, 1, usage.VideoCount)
+
+	if assert.Len(t, usage.Growth, 12) {
+		thisMonth := usage.Growth[11]
+		assert.Equal(t, time.Now().Format("2006-01"), thisMonth.Month)
+		assert.Equal(t, 3, thisMonth.Added)
+		assert.Equal(t, 4, thisMonth.Total)
+		assert.Equal(t, 1, usage.Growth[0].Total)
+	}
+
+	if assert.Len(t, usage.ScansPerWeek, 12) {
+		assert.Equal(t, 1, usage.ScansPerWeek[11].Scans)
+		assert.Equal(t, 2, usage.ScansPerWeek[11].Failures)
+		assert.Equal(t, time.Monday, usage.ScansPerWeek[11].WeekStart.Weekday())
+	}
+
+	if assert.Len(t, usage.MostActiveUsers, 1) {
+		assert.Equal(t, user.ID, usage.MostActiveUsers[0].User.ID)
+		assert.Equal(t, "user", usage.MostActiveUsers[0].User.Username)
+		assert.Equal(t, 3, usage.MostActiveUsers[0].MediaAdded)
+	}
+
+	if assert.Len(t, usage.TopCameras, 1) {
+		assert.Equal(t, "EOS 5D", usage.TopCameras[0].Camera)
+		assert.Equal(t, &maker, usage.TopCameras[0].Maker)
+		assert.Equal(t, 2, usage.TopCameras[0].MediaCount)
+	}
+
+	if assert.Len(t, usage.CacheAccess, 1) {
+		assert.EqualValues(t, 3, usage.CacheAccess[0].Hits)
+		assert.EqualValues(t, 2, usage.CacheAccess[0].Misses)
+		assert.InDelta(t, 0.6, usage.CacheAccess[0].HitRate(), 0.001)
+	}
+
+	// Statistics are reused until they expire
+	assert.NoError(t, db.Create(&models.Album{Title: "new", Path: "/new"}).Error)
+	cached, err := stats.GetUsageStatistics(db, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cached.AlbumCount)
+}
@@ -0,0 +1,279 @@
+// Package stats computes statistics of the library and its use for the admin dashboard,
+// and counts the requests for cached files to report the hit rate of the cache.
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/database"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// How long computed statistics are reused, as computing them scans most tables
+const usageStatisticsTTL = 10 * time.Minute
+
+const (
+	growthMonths      = 12
+	scanWeeks         = 12
+	activityDays      = 30
+	cacheAccessDays   = 30
+	mostActiveUsers   = 10
+	topCamerasCount   = 10
+	monthFormat       = "2006-01"
+	cacheAccessFormat = "2006-01-02"
+)
+
+var (
+	usageLock   = &sync.Mutex{}
+	cachedUsage *models.UsageStatistics
+)
+
+// GetUsageStatistics returns the statistics computed within the last 10 minutes, or computes them again.
+// With refresh set, they are always computed again.
+func GetUsageStatistics(db *gorm.DB, refresh bool) (*models.UsageStatistics, error) {
+	usageLock.Lock()
+	defer usageLock.Unlock()
+
+	if !refresh && cachedUsage != nil && time.Since(cachedUsage.ComputedAt) < usageStatisticsTTL {
+		return cachedUsage, nil
+	}
+
+	usage, err := computeUsageStatistics(db, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	cachedUsage = usage
+	return usage, nil
+}
+
+func computeUsageStatistics(db *gorm.DB, now time.Time) (*models.UsageStatistics, error) {
+	usage := models.UsageStatistics{ComputedAt: now}
+
+	var userCount, albumCount int64
+	if err := db.Model(&models.User{}).Count(&userCount).Error; err != nil {
+		return nil, errors.Wrap(err, "count users")
+	}
+	if err := db.Model(&models.Album{}).Count(&albumCount).Error; err != nil {
+		return nil, errors.Wrap(err, "count albums")
+	}
+	usage.UserCount, usage.AlbumCount = int(userCount), int(albumCount)
+
+	var mediaCounts []struct {
+		Type  models.MediaType
+		Count int
+	}
+	if err := db.Model(&models.Media{}).Select("type, COUNT(*) AS count").Group("type").Scan(&mediaCounts).Error; err != nil {
+		return nil, errors.Wrap(err, "count media")
+	}
+	for _, count := range mediaCounts {
+		switch count.Type {
+		case models.MediaTypePhoto:
+			usage.PhotoCount = count.Count
+		case models.MediaTypeVideo:
+			usage.VideoCount = count.Count
+		}
+	}
+
+	var err error
+	if usage.Growth, err = mediaGrowth(db, now); err != nil {
+		return nil, err
+	}
+	if usage.ScansPerWeek, err = scansPerWeek(db, now); err != nil {
+		return nil, err
+	}
+	if usage.MostActiveUsers, err = userActivity(db, now); err != nil {
+		return nil, err
+	}
+	if usage.TopCameras, err = topCameras(db); err != nil {
+		return nil, err
+	}
+	if usage.CacheAccess, err = cacheAccess(db, now); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+func mediaGrowth(db *gorm.DB, now time.Time) ([]*models.MediaGrowth, error) {
+	firstMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -(growthMonths - 1), 0)
+
+	var total int64
+	if err := db.Model(&models.Media{}).Where("created_at < ?", firstMonth).Count(&total).Error; err != nil {
+		return nil, errors.Wrap(err, "count media added before growth period")
+	}
+
+	yearColumn := database.DateExtract(db, database.DateCompYear, "created_at")
+	monthColumn := database.DateExtract(db, database.DateCompMonth, "created_at")
+
+	var months []struct {
+		Year  int
+		Month int
+		Count int
+	}
+	err := db.Model(&models.Media{}).
+		Select(yearColumn+" AS year, "+monthColumn+" AS month, COUNT(*) AS count").
+		Where("created_at >= ?", firstMonth).
+		Group(yearColumn + ", " + monthColumn).
+		Scan(&months).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "count media added every month")
+	}
+
+	added := make(map[string]int, len(months))
+	for _, month := range months {
+		added[fmt.Sprintf("%04d-%02d", month.Year, month.Month)] = month.Count
+	}
+
+	// Months without new media are included, so the growth can be drawn as is
+	growth := make([]*models.MediaGrowth, growthMonths)
+	runningTotal := int(total)
+	for i := range growth {
+		month := firstMonth.AddDate(0, i, 0).Format(monthFormat)
+		runningTotal += added[month]
+		growth[i] = &models.MediaGrowth{
+			Month: month,
+			Added: added[month],
+			Total: runningTotal,
+		}
+	}
+
+	return growth, nil
+}
+
+func scansPerWeek(db *gorm.DB, now time.Time) ([]*models.WeeklyScans, error) {
+	// Weeks start on monday
+	daysSinceMonday := (int(now.Weekday()) + 6) % 7
+	thisWeek := time.Date(now.Year(), now.Month(), now.Day()-daysSinceMonday, 0, 0, 0, 0, now.Location())
+	firstWeek := thisWeek.AddDate(0, 0, -7*(scanWeeks-1))
+
+	var reports []*models.ScanReport
+	if err := db.Where("created_at >= ?", firstWeek).Find(&reports).Error; err != nil {
+		return nil, errors.Wrap(err, "get scan reports")
+	}
+
+	weeks := make([]*models.WeeklyScans, scanWeeks)
+	for i := range weeks {
+		weeks[i] = &models.WeeklyScans{WeekStart: firstWeek.AddDate(0, 0, 7*i)}
+	}
+
+	for _, report := range reports {
+		week := int(report.CreatedAt.In(now.Location()).Sub(firstWeek) / (7 * 24 * time.Hour))
+		if week < 0 || week >= scanWeeks {
+			continue
+		}
+
+		weeks[week].Scans++
+		weeks[week].Failures += report.FailureCount
+	}
+
+	return weeks, nil
+}
+
+func userActivity(db *gorm.DB, now time.Time) ([]*models.UserActivity, error) {
+	since := now.AddDate(0, 0, -activityDays)
+
+	var mediaAdded []struct {
+		UserID int
+		Count  int
+	}
+	err := db.Model(&models.Media{}).
+		Select("user_albums.user_id AS user_id, COUNT(*) AS count").
+		Joins("JOIN user_albums ON user_albums.album_id = media.album_id").
+		Where("media.created_at >= ?", since).
+		Group("user_albums.user_id").
+		Scan(&mediaAdded).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "count media added by users")
+	}
+
+	var logins []struct {
+		UserID int
+		Count  int
+	}
+	err = db.Model(&models.AccessToken{}).
+		Select("user_id, COUNT(*) AS count").
+		Where("created_at >= ?", since).
+		Group("user_id").
+		Scan(&logins).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "count logins of users")
+	}
+
+	activityByUser := make(map[int]*models.UserActivity)
+	activityOf := func(userID int) *models.UserActivity {
+		activity, found := activityByUser[userID]
+		if !found {
+			activity = &models.UserActivity{User: &models.User{}}
+			activity.User.ID = userID
+			activityByUser[userID] = activity
+		}
+		return activity
+	}
+
+	for _, count := range mediaAdded {
+		activityOf(count.UserID).MediaAdded = count.Count
+	}
+	for _, count := range logins {
+		activityOf(count.UserID).Logins = count.Count
+	}
+
+	activities := make([]*models.UserActivity, 0, len(activityByUser))
+	for _, activity := range activityByUser {
+		activities = append(activities, activity)
+	}
+
+	sort.Slice(activities, func(i, j int) bool {
+		a, b := activities[i], activities[j]
+		if a.MediaAdded+a.Logins != b.MediaAdded+b.Logins {
+			return a.MediaAdded+a.Logins > b.MediaAdded+b.Logins
+		}
+		return a.User.ID < b.User.ID
+	})
+
+	if len(activities) > mostActiveUsers {
+		activities = activities[:mostActiveUsers]
+	}
+
+	// Load the users only once the most active are known
+	for _, activity := range activities {
+		if err := db.First(activity.User, activity.User.ID).Error; err != nil {
+			return nil, errors.Wrapf(err, "get active user (%d)", activity.User.ID)
+		}
+	}
+
+	return activities, nil
+}
+
+func topCameras(db *gorm.DB) ([]*models.CameraUsage, error) {
+	var cameras []*models.CameraUsage
+	err := db.Model(&models.Media{}).
+		Select("media_exif.camera AS camera, media_exif.maker AS maker, COUNT(*) AS media_count").
+		Joins("JOIN media_exif ON media_exif.id = media.exif_id").
+		Where("media_exif.camera IS NOT NULL AND media_exif.camera <> ''").
+		Group("media_exif.camera, media_exif.maker").
+		Order("media_count DESC, media_exif.camera").
+		Limit(topCamerasCount).
+		Scan(&cameras).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "count media of cameras")
+	}
+
+	return cameras, nil
+}
+
+func cacheAccess(db *gorm.DB, now time.Time) ([]*models.CacheAccessStats, error) {
+	since := now.AddDate(0, 0, -(cacheAccessDays - 1)).Format(cacheAccessFormat)
+
+	var days []*models.CacheAccessStats
+	if err := db.Where("date >= ?", since).Order("date").Find(&days).Error; err != nil {
+		return nil, errors.Wrap(err, "get cache access statistics")
+	}
+
+	return days, nil
+}
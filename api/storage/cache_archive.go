@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Version of the format of cache archives, archives of other versions are rejected on import
+const cacheArchiveVersion = 1
+
+// Names of the manifest, and the directory of the files, inside a cache archive
+const (
+	cacheArchiveManifest = "manifest.json"
+	cacheArchiveFiles    = "files/"
+)
+
+// cacheManifest is the first file of a cache archive, describing the cached files that follow it
+type cacheManifest struct {
+	Version    int                  `json:"version"`
+	ExportedAt time.Time            `json:"exported_at"`
+	Entries    []*cacheManifestFile `json:"entries"`
+}
+
+type cacheManifestFile struct {
+	Key         string              `json:"key"`
+	ContentHash string              `json:"content_hash"`
+	Purpose     models.MediaPurpose `json:"purpose"`
+	Width       int                 `json:"width"`
+	Height      int                 `json:"height"`
+	ContentType string              `json:"content_type"`
+	FileSize    int64               `json:"file_size"`
+}
+
+// CacheArchiveResult summarizes the export or import of a cache archive
+type CacheArchiveResult struct {
+	Files   int
+	Bytes   int64
+	Skipped int
+}
+
+// ExportCache writes the files of the content addressed cache, and the manifest describing them, as a gzipped tar archive.
+// As files are addressed by the content of their originals, the archive can be imported on another host with the same media,
+// so they don't have to be generated again. Files of media that has not been hashed yet are not exported.
+func ExportCache(db *gorm.DB, w io.Writer) (*CacheArchiveResult, error) {
+	var entries []*models.CacheEntry
+	if err := db.Order("cache_entries.key").Find(&entries).Error; err != nil {
+		return nil, errors.Wrap(err, "get cache entries from database")
+	}
+
+	result := &CacheArchiveResult{}
+	manifest := cacheManifest{
+		Version:    cacheArchiveVersion,
+		ExportedAt: time.Now(),
+		Entries:    make([]*cacheManifestFile, 0, len(entries)),
+	}
+
+	// Only complete files are exported, evicted files and files being written are left out
+	exported := make([]*models.CacheEntry, 0, len(entries))
+	for _, entry := range entries {
+		fileInfo, err := os.Stat(entry.FilePath())
+		if err != nil || fileInfo.Size() != entry.FileSize {
+			result.Skipped++
+			continue
+		}
+
+		exported = append(exported, entry)
+		manifest.Entries = append(manifest.Entries, &cacheManifestFile{
+			Key:         entry.Key,
+			ContentHash: entry.ContentHash,
+			Purpose:     entry.Purpose,
+			Width:       entry.Width,
+			Height:      entry.Height,
+			ContentType: entry.ContentType,
+			FileSize:    entry.FileSize,
+		})
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode cache manifest")
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:    cacheArchiveManifest,
+		Mode:    0644,
+		Size:    int64(len(manifestJSON)),
+		ModTime: manifest.ExportedAt,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "write cache manifest to archive")
+	}
+	if _, err := tarWriter.Write(manifestJSON); err != nil {
+		return nil, errors.Wrap(err, "write cache manifest to archive")
+	}
+
+	for _, entry := range exported {
+		if err := addFileToArchive(tarWriter, entry); err != nil {
+			return nil, err
+		}
+
+		result.Files++
+		result.Bytes += entry.FileSize
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, errors.Wrap(err, "close cache archive")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, errors.Wrap(err, "close cache archive")
+	}
+
+	return result, nil
+}
+
+func addFileToArchive(tarWriter *tar.Writer, entry *models.CacheEntry) error {
+	file, err := os.Open(entry.FilePath())
+	if err != nil {
+		return errors.Wrapf(err, "open cached file (%s)", entry.Key)
+	}
+	defer file.Close()
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:    cacheArchiveFiles + entry.Key,
+		Mode:    0644,
+		Size:    entry.FileSize,
+		ModTime: entry.UpdatedAt,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "write cached file to archive (%s)", entry.Key)
+	}
+
+	// The size in the header has to match, in case the file has been replaced since it was checked
+	if _, err := io.CopyN(tarWriter, file, entry.FileSize); err != nil {
+		return errors.Wrapf(err, "write cached file to archive (%s)", entry.Key)
+	}
+
+	return nil
+}
+
+// ImportCache reads a cache archive written by ExportCache, storing its files in the cache locations of their types,
+// and adding them to the manifest of the cache. Files already in the cache are skipped.
+// Imported files are used by media with the same content once it is scanned, and are collected as garbage if no media uses them.
+func ImportCache(db *gorm.DB, r io.Reader) (*CacheArchiveResult, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "read cache archive")
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+
+	header, err := tarReader.Next()
+	if err != nil {
+		return nil, errors.Wrap(err, "read cache archive")
+	}
+	if header.Name != cacheArchiveManifest {
+		return nil, errors.New("invalid cache archive, it does not start with a manifest")
+	}
+
+	var manifest cacheManifest
+	if err := json.NewDecoder(tarReader).Decode(&manifest); err != nil {
+		return nil, errors.Wrap(err, "decode cache manifest")
+	}
+	if manifest.Version != cacheArchiveVersion {
+		return nil, errors.Errorf("unsupported cache archive version: %d", manifest.Version)
+	}
+
+	manifestFiles := make(map[string]*cacheManifestFile, len(manifest.Entries))
+	for _, file := range manifest.Entries {
+		manifestFiles[file.Key] = file
+	}
+
+	result := &CacheArchiveResult{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, errors.Wrap(err, "read cache archive")
+		}
+
+		key := strings.TrimPrefix(header.Name, cacheArchiveFiles)
+		file, found := manifestFiles[key]
+		if !found || header.Typeflag != tar.TypeReg || !validCacheKey(key) {
+			result.Skipped++
+			continue
+		}
+
+		imported, err := importCachedFile(db, tarReader, file)
+		if err != nil {
+			return result, err
+		}
+
+		if imported {
+			result.Files++
+			result.Bytes += file.FileSize
+		} else {
+			result.Skipped++
+		}
+	}
+
+	return result, nil
+}
+
+// validCacheKey returns whether the key is a path inside the content addressed part of a cache location
+func validCacheKey(key string) bool {
+	return path.Clean(key) == key && strings.HasPrefix(key, utils.ContentCacheDir+"/") && !strings.Contains(key, "..")
+}
+
+func importCachedFile(db *gorm.DB, r io.Reader, file *cacheManifestFile) (bool, error) {
+	entry := models.CacheEntry{
+		Key:         file.Key,
+		ContentHash: file.ContentHash,
+		Purpose:     file.Purpose,
+		Width:       file.Width,
+		Height:      file.Height,
+		ContentType: file.ContentType,
+		FileSize:    file.FileSize,
+	}
+
+	if _, isCached := entry.Purpose.CacheType(); !isCached {
+		return false, nil
+	}
+
+	filePath := entry.FilePath()
+	if fileInfo, err := os.Stat(filePath); err == nil && fileInfo.Size() == entry.FileSize {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(path.Dir(filePath), os.ModePerm); err != nil {
+		return false, errors.Wrap(err, "create directory of cached file")
+	}
+
+	err := utils.WriteFileAtomic(filePath, func(tmpPath string) error {
+		out, err := os.Create(tmpPath)
+		if err != nil {
+			return err
+		}
+
+		written, err := io.Copy(out, r)
+		if err != nil {
+			out.Close()
+			return err
+		}
+
+		if written != entry.FileSize {
+			out.Close()
+			return errors.Errorf("expected %d bytes, got %d", entry.FileSize, written)
+		}
+
+		return out.Close()
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "import cached file (%s)", entry.Key)
+	}
+
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error; err != nil {
+		return false, errors.Wrapf(err, "save cache entry (%s)", entry.Key)
+	}
+
+	return true, nil
+}
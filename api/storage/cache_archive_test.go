@@ -0,0 +1,95 @@
+package storage_test
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheArchive(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	hash := "cc00000000000000000000000000000000000000000000000000000000000000"
+	cachePath, err := utils.CachePathForContent(utils.MediaCachePath(), hash)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	files := map[models.MediaPurpose]string{
+		models.PhotoThumbnail: "thumbnail",
+		models.PhotoHighRes:   "high resolution",
+	}
+	for purpose, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(cachePath, string(purpose)+".jpg"), []byte(content), 0644))
+		assert.NoError(t, db.Create(&models.CacheEntry{
+			Key:         path.Join(utils.ContentCacheKey(hash), string(purpose)+".jpg"),
+			ContentHash: hash,
+			Purpose:     purpose,
+			Width:       1024,
+			Height:      768,
+			ContentType: "image/jpeg",
+			FileSize:    int64(len(content)),
+		}).Error)
+	}
+
+	// Entries of evicted files are not exported
+	assert.NoError(t, db.Create(&models.CacheEntry{
+		Key:         path.Join(utils.ContentCacheKey(hash), "video-web.mp4"),
+		ContentHash: hash,
+		Purpose:     models.VideoWeb,
+		FileSize:    100,
+	}).Error)
+
+	var archive bytes.Buffer
+	result, err := storage.ExportCache(db, &archive)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, result.Files)
+	assert.Equal(t, 1, result.Skipped)
+
+	// Start over as on a new host
+	assert.NoError(t, os.RemoveAll(utils.MediaCachePath()))
+	assert.NoError(t, db.Where("1 = 1").Delete(&models.CacheEntry{}).Error)
+
+	archiveBytes := archive.Bytes()
+	result, err = storage.ImportCache(db, bytes.NewReader(archiveBytes))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, result.Files)
+	assert.Equal(t, int64(len("thumbnail")+len("high resolution")), result.Bytes)
+
+	var entries []*models.CacheEntry
+	assert.NoError(t, db.Order("key").Find(&entries).Error)
+	if assert.Len(t, entries, 2) {
+		for _, entry := range entries {
+			assert.Equal(t, hash, entry.ContentHash)
+			assert.Equal(t, 1024, entry.Width)
+
+			content, err := os.ReadFile(entry.FilePath())
+			assert.NoError(t, err)
+			assert.Equal(t, files[entry.Purpose], string(content))
+		}
+	}
+
+	t.Run("Files already in the cache are skipped", func(t *testing.T) {
+		result, err := storage.ImportCache(db, bytes.NewReader(archiveBytes))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, result.Files)
+		assert.Equal(t, 2, result.Skipped)
+	})
+
+	t.Run("Archives without a manifest are rejected", func(t *testing.T) {
+		_, err := storage.ImportCache(db, bytes.NewReader([]byte("not an archive")))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,427 @@
+package storage
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/cluster"
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// How often the cache usage is checked against the budget
+const cacheMonitorInterval = 15 * time.Minute
+
+// Only update the access time of a cached file, if it hasn't been updated within this duration
+const cacheAccessResolution = time.Hour
+
+// Media purposes of files that are generated by the scanner, and thus can be evicted and regenerated later
+var derivedMediaPurposes = []models.MediaPurpose{
+	models.PhotoThumbnail,
+	models.PhotoHighRes,
+	models.VideoThumbnail,
+	models.VideoWeb,
+	models.MotionVideo,
+	models.VideoSprite,
+}
+
+// derivedPurposes returns the purposes of the derived files, including the scaled copies of photos of the configured sizes
+// and the AVIF variants of the images of photos
+func derivedPurposes() []models.MediaPurpose {
+	return models.WithAVIFVariants(append(append([]models.MediaPurpose{}, derivedMediaPurposes...), models.ScaledPhotoPurposes()...))
+}
+
+var cacheBudgetLock = &sync.Mutex{}
+
+// InitializeCacheMonitor starts a background worker that removes the files left incomplete in the media cache by a crash,
+// and then periodically removes orphaned files and the directories of deleted albums and media from the media cache, and checks its size, evicting the least recently accessed files when the cache exceeds its budget.
+func InitializeCacheMonitor(db *gorm.DB) {
+	go func() {
+		if removed, err := RemoveIncompleteCacheFiles(db); err != nil {
+			log.Error(context.Background(), "Removing incomplete files from media cache", "error", err)
+		} else if removed > 0 {
+			log.Info(context.Background(), "Removed incomplete files from media cache, left by an interrupted generation", "files", removed)
+		}
+
+		for {
+			// Only one of the instances sharing the database checks the budget every interval
+			claimed, err := cluster.TryAcquire(db, "cache-monitor", cacheMonitorInterval-cacheMonitorInterval/10)
+			if err != nil {
+				log.Error(context.Background(), "Claiming media cache budget check", "error", err)
+			}
+
+			if claimed {
+				if _, err := CollectCacheGarbage(db, CacheGarbageGracePeriod); err != nil {
+					log.Error(context.Background(), "Collecting media cache garbage", "error", err)
+				}
+
+				if orphaned, err := RemoveOrphanedCacheDirectories(db, func(float64) {}); err != nil {
+					log.Error(context.Background(), "Removing orphaned directories from media cache", "error", err)
+				} else if orphaned.Albums+orphaned.Media > 0 {
+					log.Info(context.Background(), "Removed the cache of deleted albums and media",
+						"albums", orphaned.Albums, "media", orphaned.Media, "bytes", orphaned.Bytes)
+				}
+
+				if _, err := CheckCacheBudget(db); err != nil {
+					log.Error(context.Background(), "Checking media cache budget", "error", err)
+				}
+			}
+
+			time.Sleep(cacheMonitorInterval)
+		}
+	}()
+}
+
+// GetCacheUsage computes the current disk usage of the media cache, and of every type of cached files
+func GetCacheUsage(db *gorm.DB) (*models.CacheUsage, error) {
+	siteInfo, err := models.GetSiteInfo(db)
+	if err != nil {
+		return nil, err
+	}
+
+	usedBytes := int64(0)
+	for _, location := range cacheLocations() {
+		size, err := directorySize(location)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compute media cache size (%s)", location)
+		}
+		usedBytes += size
+	}
+
+	usage := models.CacheUsage{
+		UsedBytes:        int(usedBytes),
+		BudgetBytes:      int(siteInfo.CacheBudget),
+		WarningThreshold: siteInfo.CacheWarningThreshold,
+		Warning:          siteInfo.CacheBudget > 0 && float64(usedBytes) > float64(siteInfo.CacheBudget)*siteInfo.CacheWarningThreshold,
+		ComputedAt:       time.Now(),
+		Types:            make([]*models.CacheTypeUsage, 0, len(models.AllCacheType)),
+	}
+
+	if free, total, err := diskSpace(utils.MediaCachePath()); err == nil {
+		freeBytes, totalBytes := int(free), int(total)
+		usage.DiskFreeBytes = &freeBytes
+		usage.DiskTotalBytes = &totalBytes
+	}
+
+	for _, cacheType := range models.AllCacheType {
+		typeBytes, err := cacheTypeSize(cacheType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compute size of cached files of type %s", cacheType)
+		}
+
+		budget, _ := siteInfo.CacheTypeBudget(cacheType)
+		typeUsage := &models.CacheTypeUsage{
+			Type:        cacheType,
+			Path:        cacheType.Path(),
+			UsedBytes:   int(typeBytes),
+			BudgetBytes: int(budget),
+			Warning:     budget > 0 && float64(typeBytes) > float64(budget)*siteInfo.CacheWarningThreshold,
+		}
+
+		if free, total, err := diskSpace(cacheType.Path()); err == nil {
+			freeBytes, totalBytes := int(free), int(total)
+			typeUsage.DiskFreeBytes = &freeBytes
+			typeUsage.DiskTotalBytes = &totalBytes
+		}
+
+		usage.Types = append(usage.Types, typeUsage)
+	}
+
+	return &usage, nil
+}
+
+// CheckCacheBudget computes the cache usage, evicts the least recently accessed files if the budget of the cache,
+// or the budget of a type of cached files, is exceeded, and logs a warning if the usage is still above the warning threshold.
+func CheckCacheBudget(db *gorm.DB) (*models.CacheUsage, error) {
+	cacheBudgetLock.Lock()
+	defer cacheBudgetLock.Unlock()
+
+	usage, err := GetCacheUsage(db)
+	if err != nil {
+		return nil, err
+	}
+
+	evicted := false
+
+	if usage.BudgetBytes > 0 && usage.UsedBytes > usage.BudgetBytes {
+		target := int64(float64(usage.BudgetBytes) * usage.WarningThreshold)
+		freed, err := evictCache(db, int64(usage.UsedBytes)-target, derivedPurposes(), false)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Info(db.Statement.Context, "Media cache exceeded budget, evicted least recently accessed files", "freed_bytes", freed)
+		evicted = true
+	}
+
+	for _, typeUsage := range usage.Types {
+		if typeUsage.BudgetBytes <= 0 || typeUsage.UsedBytes <= typeUsage.BudgetBytes {
+			continue
+		}
+
+		// Files evicted for the budget of the whole cache are not accounted for, the usage is recomputed afterwards anyway
+		target := int64(float64(typeUsage.BudgetBytes) * usage.WarningThreshold)
+		freed, err := evictCache(db, int64(typeUsage.UsedBytes)-target, typeUsage.Type.Purposes(), true)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Info(db.Statement.Context, "Cached files exceeded the budget of their type, evicted least recently accessed files",
+			"type", typeUsage.Type,
+			"freed_bytes", freed)
+		evicted = true
+	}
+
+	if evicted {
+		if usage, err = GetCacheUsage(db); err != nil {
+			return nil, err
+		}
+	}
+
+	if usage.Warning {
+		log.Warn(db.Statement.Context, "Media cache usage is above the warning threshold of the budget",
+			"used_bytes", usage.UsedBytes,
+			"budget_bytes", usage.BudgetBytes,
+			"warning_threshold", usage.WarningThreshold)
+	}
+
+	for _, typeUsage := range usage.Types {
+		if typeUsage.Warning {
+			log.Warn(db.Statement.Context, "Usage of cached files is above the warning threshold of the budget of their type",
+				"type", typeUsage.Type,
+				"used_bytes", typeUsage.UsedBytes,
+				"budget_bytes", typeUsage.BudgetBytes,
+				"warning_threshold", usage.WarningThreshold)
+		}
+	}
+
+	return usage, nil
+}
+
+// evictCache deletes derived files of the given purposes from the cache, least recently accessed first,
+// until at least the given amount of bytes has been freed. If addressedByContent is set, only files addressed by their content are deleted.
+// Evicted files are regenerated when they are requested again.
+func evictCache(db *gorm.DB, bytesToFree int64, purposes []models.MediaPurpose, addressedByContent bool) (int64, error) {
+	const batchSize = 200
+
+	freed := int64(0)
+	offset := 0
+
+	// Images resized on demand are thumbnails, they are evicted first as they are the quickest to generate again
+	for _, purpose := range purposes {
+		if purpose != models.PhotoThumbnail {
+			continue
+		}
+
+		var err error
+		if freed, err = evictResizedPhotos(db, bytesToFree); err != nil {
+			return freed, err
+		}
+	}
+
+	for freed < bytesToFree {
+		query := db.Joins("Media").Where("media_urls.purpose IN (?)", purposes)
+		if addressedByContent {
+			query = query.Where("media_urls.cache_key IS NOT NULL")
+		}
+
+		var mediaURLs []*models.MediaURL
+		err := query.
+			Order("COALESCE(media_urls.last_accessed_at, media_urls.created_at)").
+			Offset(offset).Limit(batchSize).
+			Find(&mediaURLs).Error
+		if err != nil {
+			return freed, errors.Wrap(err, "get media urls to evict from cache")
+		}
+
+		if len(mediaURLs) == 0 {
+			break
+		}
+		offset += len(mediaURLs)
+
+		for _, mediaURL := range mediaURLs {
+			cachedPath, err := mediaURL.CachedPath()
+			if err != nil {
+				continue
+			}
+
+			fileInfo, err := os.Stat(cachedPath)
+			if err != nil {
+				continue
+			}
+
+			if err := os.Remove(cachedPath); err != nil {
+				log.Error(db.Statement.Context, "Evicting file from media cache", "path", cachedPath, "error", err)
+				continue
+			}
+
+			freed += fileInfo.Size()
+			if freed >= bytesToFree {
+				break
+			}
+		}
+	}
+
+	return freed, nil
+}
+
+// evictResizedPhotos deletes the images resized on demand from the cache, least recently accessed first,
+// until at least the given amount of bytes has been freed. They are only recorded in the manifest of the cache.
+func evictResizedPhotos(db *gorm.DB, bytesToFree int64) (int64, error) {
+	freed := int64(0)
+
+	for freed < bytesToFree {
+		var entries []*models.CacheEntry
+		err := db.Where("purpose LIKE ?", models.ResizedPhotoPrefix+"%").
+			Order("updated_at").
+			Limit(200).
+			Find(&entries).Error
+		if err != nil {
+			return freed, errors.Wrap(err, "get resized photos to evict from cache")
+		}
+
+		if len(entries) == 0 {
+			break
+		}
+
+		for _, entry := range entries {
+			filePath := entry.FilePath()
+			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+				log.Error(db.Statement.Context, "Evicting resized photo from media cache", "path", filePath, "error", err)
+				return freed, nil
+			}
+
+			if err := db.Delete(entry).Error; err != nil {
+				return freed, errors.Wrapf(err, "delete cache entry (%s)", entry.Key)
+			}
+
+			freed += entry.FileSize
+			if freed >= bytesToFree {
+				break
+			}
+		}
+	}
+
+	return freed, nil
+}
+
+// TouchCacheEntry records that the cached file of the given cache entry, which is not referenced by a media url,
+// has been accessed, and that it is still in use
+func TouchCacheEntry(db *gorm.DB, entry *models.CacheEntry) error {
+	now := time.Now()
+	if entry.OrphanedAt == nil && now.Sub(entry.UpdatedAt) < cacheAccessResolution {
+		return nil
+	}
+
+	entry.UpdatedAt = now
+	entry.OrphanedAt = nil
+	return db.Model(&models.CacheEntry{}).Where("cache_entries.key = ?", entry.Key).
+		UpdateColumns(map[string]interface{}{"updated_at": now, "orphaned_at": nil}).Error
+}
+
+// TouchMediaURL records that the cached file of the given media url has been accessed
+func TouchMediaURL(db *gorm.DB, mediaURL *models.MediaURL) error {
+	now := time.Now()
+	if mediaURL.LastAccessedAt != nil && now.Sub(*mediaURL.LastAccessedAt) < cacheAccessResolution {
+		return nil
+	}
+
+	mediaURL.LastAccessedAt = &now
+	return db.Model(&models.MediaURL{}).Where("id = ?", mediaURL.ID).Update("last_accessed_at", now).Error
+}
+
+func directorySize(root string) (int64, error) {
+	size := int64(0)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
+
+// cacheLocations returns the directories cached files are stored in, the media cache and the locations of the types of cached files
+// outside of it, without locations inside of others, so no file is counted twice
+func cacheLocations() []string {
+	candidates := []string{filepath.Clean(utils.MediaCachePath())}
+	for _, cacheType := range models.AllCacheType {
+		candidates = append(candidates, filepath.Clean(cacheType.Path()))
+	}
+
+	locations := make([]string, 0, len(candidates))
+	for i, candidate := range candidates {
+		nested := false
+		for j, other := range candidates {
+			if i == j {
+				continue
+			}
+
+			// Of identical locations only the first is kept
+			if (candidate == other && j < i) || strings.HasPrefix(candidate, other+string(filepath.Separator)) {
+				nested = true
+				break
+			}
+		}
+
+		if !nested {
+			locations = append(locations, candidate)
+		}
+	}
+
+	return locations
+}
+
+// cacheTypeSize sums the size of the cached files of the given type, that are addressed by their content.
+// Files are named by their purpose, so the types of files sharing the same location can be told apart.
+func cacheTypeSize(cacheType models.CacheType) (int64, error) {
+	purposes := make(map[string]bool)
+	for _, purpose := range cacheType.Purposes() {
+		purposes[string(purpose)] = true
+	}
+
+	size := int64(0)
+	err := filepath.WalkDir(filepath.Join(cacheType.Path(), utils.ContentCacheDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		name := d.Name()
+		if !d.Type().IsRegular() || !purposes[strings.TrimSuffix(name, filepath.Ext(name))] {
+			return nil
+		}
+
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
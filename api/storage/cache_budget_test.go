@@ -0,0 +1,215 @@
+package storage_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCheckCacheBudget(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	if !assert.NoError(t, db.Save(&album).Error) {
+		return
+	}
+
+	media := models.Media{Title: "photo.jpg", Path: "/photos/photo.jpg", AlbumID: album.ID}
+	if !assert.NoError(t, db.Save(&media).Error) {
+		return
+	}
+
+	cachePath, err := media.CachePath()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	oldAccess := time.Now().Add(-48 * time.Hour)
+	newAccess := time.Now()
+
+	oldURL := models.MediaURL{MediaID: media.ID, MediaName: "old.jpg", Purpose: models.PhotoThumbnail, LastAccessedAt: &oldAccess}
+	newURL := models.MediaURL{MediaID: media.ID, MediaName: "new.jpg", Purpose: models.PhotoHighRes, LastAccessedAt: &newAccess}
+	assert.NoError(t, db.Save(&oldURL).Error)
+	assert.NoError(t, db.Save(&newURL).Error)
+
+	content := make([]byte, 1000)
+	assert.NoError(t, os.WriteFile(path.Join(cachePath, "old.jpg"), content, 0644))
+	assert.NoError(t, os.WriteFile(path.Join(cachePath, "new.jpg"), content, 0644))
+
+	usage, err := storage.CheckCacheBudget(db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2000, usage.UsedBytes)
+	assert.False(t, usage.Warning)
+
+	err = db.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&models.SiteInfo{}).Updates(map[string]interface{}{
+		"cache_budget":            1500,
+		"cache_warning_threshold": 0.9,
+	}).Error
+	assert.NoError(t, err)
+
+	usage, err = storage.CheckCacheBudget(db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1000, usage.UsedBytes)
+	assert.False(t, usage.Warning)
+
+	assert.NoFileExists(t, path.Join(cachePath, "old.jpg"))
+	assert.FileExists(t, path.Join(cachePath, "new.jpg"))
+}
+
+func TestCacheTypeBudget(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	thumbnailsPath := t.TempDir()
+	t.Setenv(utils.EnvMediaCacheThumbnails.GetName(), thumbnailsPath)
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	if !assert.NoError(t, db.Save(&album).Error) {
+		return
+	}
+
+	content := make([]byte, 1000)
+	addCachedFile := func(media *models.Media, purpose models.MediaPurpose, name string, accessedAt time.Time) string {
+		cachePath, err := media.CachePathForPurpose(purpose)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+
+		key := path.Join(utils.ContentCacheKey(*media.ContentHash), name)
+		mediaURL := models.MediaURL{MediaID: media.ID, MediaName: name + "_" + media.Title, Purpose: purpose, CacheKey: &key, LastAccessedAt: &accessedAt}
+		assert.NoError(t, db.Save(&mediaURL).Error)
+
+		filePath := path.Join(cachePath, name)
+		assert.NoError(t, os.WriteFile(filePath, content, 0644))
+		return filePath
+	}
+
+	oldHash := "aa00000000000000000000000000000000000000000000000000000000000000"
+	newHash := "bb00000000000000000000000000000000000000000000000000000000000000"
+	oldMedia := models.Media{Title: "old.jpg", Path: "/photos/old.jpg", AlbumID: album.ID, ContentHash: &oldHash}
+	newMedia := models.Media{Title: "new.jpg", Path: "/photos/new.jpg", AlbumID: album.ID, ContentHash: &newHash}
+	assert.NoError(t, db.Save(&oldMedia).Error)
+	assert.NoError(t, db.Save(&newMedia).Error)
+
+	oldThumbnail := addCachedFile(&oldMedia, models.PhotoThumbnail, "thumbnail.jpg", time.Now().Add(-48*time.Hour))
+	newThumbnail := addCachedFile(&newMedia, models.PhotoThumbnail, "thumbnail.jpg", time.Now())
+	oldHighRes := addCachedFile(&oldMedia, models.PhotoHighRes, "high-res.jpg", time.Now().Add(-72*time.Hour))
+
+	assert.True(t, strings.HasPrefix(oldThumbnail, thumbnailsPath))
+	assert.True(t, strings.HasPrefix(oldHighRes, utils.MediaCachePath()))
+
+	usage, err := storage.CheckCacheBudget(db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 3000, usage.UsedBytes)
+
+	typeUsage := func(usage *models.CacheUsage, cacheType models.CacheType) *models.CacheTypeUsage {
+		for _, typeUsage := range usage.Types {
+			if typeUsage.Type == cacheType {
+				return typeUsage
+			}
+		}
+		return nil
+	}
+
+	thumbnails := typeUsage(usage, models.CacheTypeThumbnails)
+	if assert.NotNil(t, thumbnails) {
+		assert.Equal(t, thumbnailsPath, thumbnails.Path)
+		assert.Equal(t, 2000, thumbnails.UsedBytes)
+	}
+
+	err = db.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&models.SiteInfo{}).Updates(map[string]interface{}{
+		"thumbnails_cache_budget": 1500,
+		"cache_warning_threshold": 0.9,
+	}).Error
+	assert.NoError(t, err)
+
+	usage, err = storage.CheckCacheBudget(db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2000, usage.UsedBytes)
+	assert.Equal(t, 1000, typeUsage(usage, models.CacheTypeThumbnails).UsedBytes)
+	assert.Equal(t, 1000, typeUsage(usage, models.CacheTypeWebVersions).UsedBytes)
+
+	// Only the least recently accessed thumbnail is evicted, even though the high-res image has been accessed less recently
+	assert.NoFileExists(t, oldThumbnail)
+	assert.FileExists(t, newThumbnail)
+	assert.FileExists(t, oldHighRes)
+}
+
+func TestEvictResizedPhotos(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	contentHash := strings.Repeat("ab", 32)
+	album := models.Album{Title: "album", Path: "/photos"}
+	if !assert.NoError(t, db.Save(&album).Error) {
+		return
+	}
+
+	media := models.Media{Title: "photo.jpg", Path: "/photos/photo.jpg", AlbumID: album.ID, ContentHash: &contentHash}
+	if !assert.NoError(t, db.Save(&media).Error) {
+		return
+	}
+
+	contentPath, err := media.CachePathForPurpose(models.PhotoThumbnail)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	content := make([]byte, 1000)
+	oldAccess := time.Now().Add(-48 * time.Hour)
+	thumbnailKey := path.Join(utils.ContentCacheKey(contentHash), "thumbnail.jpg")
+	thumbnail := models.MediaURL{MediaID: media.ID, MediaName: "thumbnail.jpg", Purpose: models.PhotoThumbnail, CacheKey: &thumbnailKey, LastAccessedAt: &oldAccess}
+	assert.NoError(t, db.Save(&thumbnail).Error)
+	assert.NoError(t, os.WriteFile(path.Join(contentPath, "thumbnail.jpg"), content, 0644))
+
+	// Images resized on demand are evicted before the thumbnails, even when they have been accessed more recently
+	resized := make([]string, 0)
+	for i, purpose := range []models.MediaPurpose{models.ResizedPhotoPurpose(400, 0, false), models.ResizedPhotoPurpose(800, 600, true)} {
+		key := path.Join(utils.ContentCacheKey(contentHash), string(purpose)+".jpg")
+		entry := models.CacheEntry{Key: key, ContentHash: contentHash, Purpose: purpose, ContentType: "image/jpeg", FileSize: 1000,
+			UpdatedAt: time.Now().Add(time.Duration(i-2) * time.Hour)}
+		assert.NoError(t, db.Create(&entry).Error)
+		assert.NoError(t, os.WriteFile(entry.FilePath(), content, 0644))
+		resized = append(resized, entry.FilePath())
+	}
+
+	_, err = models.GetSiteInfo(db)
+	assert.NoError(t, err)
+	err = db.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&models.SiteInfo{}).Updates(map[string]interface{}{
+		"cache_budget":            2500,
+		"cache_warning_threshold": 0.9,
+	}).Error
+	assert.NoError(t, err)
+
+	usage, err := storage.CheckCacheBudget(db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2000, usage.UsedBytes)
+
+	assert.NoFileExists(t, resized[0])
+	assert.FileExists(t, resized[1])
+	assert.FileExists(t, path.Join(contentPath, "thumbnail.jpg"))
+
+	var count int64
+	assert.NoError(t, db.Model(&models.CacheEntry{}).Where("purpose LIKE ?", models.ResizedPhotoPrefix+"%").Count(&count).Error)
+	assert.EqualValues(t, 1, count)
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// How old a temporary file in the media cache has to be, before it is considered left behind by a crash,
+// as other instances sharing the cache may still be writing newer ones
+const incompleteFileAge = time.Hour
+
+// How long a cache entry is kept after the last media url referencing it has been removed,
+// so files are not regenerated when media is moved or deleted and added again shortly after
+const CacheGarbageGracePeriod = 7 * 24 * time.Hour
+
+// CollectCacheGarbage marks the entries of the cache manifest that are no longer referenced by any media url as orphaned,
+// and deletes the entries, and their files, that have been orphaned for longer than the given grace period.
+// It returns the number of entries deleted.
+func CollectCacheGarbage(db *gorm.DB, grace time.Duration) (int, error) {
+	now := time.Now()
+	referenced := db.Model(&models.MediaURL{}).Select("cache_key").Where("cache_key IS NOT NULL")
+
+	err := db.Model(&models.CacheEntry{}).
+		Where("orphaned_at IS NULL AND cache_entries.key NOT IN (?)", referenced).
+		Update("orphaned_at", now).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "mark orphaned cache entries")
+	}
+
+	err = db.Model(&models.CacheEntry{}).
+		Where("orphaned_at IS NOT NULL AND cache_entries.key IN (?)", referenced).
+		Update("orphaned_at", nil).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "unmark referenced cache entries")
+	}
+
+	var expired []*models.CacheEntry
+	if err := db.Where("orphaned_at < ?", now.Add(-grace)).Find(&expired).Error; err != nil {
+		return 0, errors.Wrap(err, "get expired cache entries")
+	}
+
+	deleted := 0
+	for _, entry := range expired {
+		filePath := entry.FilePath()
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			log.Error(db.Statement.Context, "Removing orphaned file from media cache", "path", filePath, "error", err)
+			continue
+		}
+
+		if err := db.Delete(entry).Error; err != nil {
+			return deleted, errors.Wrapf(err, "delete cache entry (%s)", entry.Key)
+		}
+		deleted++
+
+		// Remove the directories of the content and its prefix, once they are empty
+		os.Remove(path.Dir(filePath))
+		os.Remove(path.Dir(path.Dir(filePath)))
+	}
+
+	return deleted, nil
+}
+
+// RemoveIncompleteCacheFiles deletes the temporary files left in the media cache by generating files
+// that were interrupted by a crash, and returns the number of files deleted
+func RemoveIncompleteCacheFiles(db *gorm.DB) (int, error) {
+	removed := 0
+	cutoff := time.Now().Add(-incompleteFileAge)
+
+	for _, location := range cacheLocations() {
+		err := filepath.WalkDir(location, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+
+			if d.IsDir() || !strings.HasPrefix(d.Name(), utils.TempFilePrefix) {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				return nil
+			}
+
+			if err := os.Remove(filePath); err != nil {
+				log.Error(db.Statement.Context, "Removing incomplete file from media cache", "path", filePath, "error", err)
+				return nil
+			}
+			removed++
+
+			return nil
+		})
+		if err != nil {
+			return removed, errors.Wrapf(err, "walk media cache directory (%s)", location)
+		}
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,109 @@
+package storage_test
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectCacheGarbage(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	if !assert.NoError(t, db.Save(&album).Error) {
+		return
+	}
+
+	usedHash := "aa00000000000000000000000000000000000000000000000000000000000000"
+	unusedHash := "bb00000000000000000000000000000000000000000000000000000000000000"
+
+	media := models.Media{Title: "photo.jpg", Path: "/photos/photo.jpg", AlbumID: album.ID, ContentHash: &usedHash}
+	if !assert.NoError(t, db.Save(&media).Error) {
+		return
+	}
+
+	entries := make([]models.CacheEntry, 0)
+	for _, hash := range []string{usedHash, unusedHash} {
+		cachePath, err := utils.CachePathForContent(utils.MediaCachePath(), hash)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, os.WriteFile(path.Join(cachePath, "thumbnail.jpg"), []byte("thumbnail"), 0644))
+
+		entry := models.CacheEntry{
+			Key:         path.Join(utils.ContentCacheKey(hash), "thumbnail.jpg"),
+			ContentHash: hash,
+			Purpose:     models.PhotoThumbnail,
+		}
+		assert.NoError(t, db.Create(&entry).Error)
+		entries = append(entries, entry)
+	}
+
+	mediaURL := models.MediaURL{MediaID: media.ID, MediaName: "thumbnail_photo.jpg", Purpose: models.PhotoThumbnail, CacheKey: &entries[0].Key}
+	assert.NoError(t, db.Save(&mediaURL).Error)
+
+	t.Run("Orphaned entries are kept during the grace period", func(t *testing.T) {
+		deleted, err := storage.CollectCacheGarbage(db, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, deleted)
+
+		var orphaned []*models.CacheEntry
+		assert.NoError(t, db.Where("orphaned_at IS NOT NULL").Find(&orphaned).Error)
+		if assert.Len(t, orphaned, 1) {
+			assert.Equal(t, entries[1].Key, orphaned[0].Key)
+		}
+	})
+
+	t.Run("Orphaned entries are deleted after the grace period", func(t *testing.T) {
+		deleted, err := storage.CollectCacheGarbage(db, -time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+
+		var remaining []*models.CacheEntry
+		assert.NoError(t, db.Find(&remaining).Error)
+		if assert.Len(t, remaining, 1) {
+			assert.Equal(t, entries[0].Key, remaining[0].Key)
+		}
+
+		assert.FileExists(t, entries[0].FilePath())
+		assert.NoFileExists(t, entries[1].FilePath())
+		assert.NoDirExists(t, path.Join(utils.MediaCachePath(), utils.ContentCacheKey(unusedHash)))
+	})
+}
+
+func TestRemoveIncompleteCacheFiles(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	cachePath, err := utils.CachePathForContent(utils.MediaCachePath(), "cc00000000000000000000000000000000000000000000000000000000000000")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	complete := path.Join(cachePath, "thumbnail.jpg")
+	leftBehind := path.Join(cachePath, utils.TempFilePrefix+"abc-high-res.jpg")
+	inProgress := path.Join(cachePath, utils.TempFilePrefix+"def-video-web.mp4")
+
+	for _, filePath := range []string{complete, leftBehind, inProgress} {
+		assert.NoError(t, os.WriteFile(filePath, []byte("data"), 0644))
+	}
+
+	crashedAt := time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, os.Chtimes(leftBehind, crashedAt, crashedAt))
+
+	removed, err := storage.RemoveIncompleteCacheFiles(db)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	assert.FileExists(t, complete)
+	assert.NoFileExists(t, leftBehind)
+	assert.FileExists(t, inProgress)
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// OrphanedCacheResult is what removing the cache directories of deleted albums and media reclaimed
+type OrphanedCacheResult struct {
+	Albums int
+	Media  int
+	Bytes  int64
+}
+
+// RemoveOrphanedCacheDirectories deletes the cache directories of albums and media that no longer exist in the database,
+// which are left behind when media is removed while the server is stopped, and reports the disk space reclaimed.
+// Albums and media are checked again right before their directories are removed, as they may be added while the cache is walked.
+func RemoveOrphanedCacheDirectories(db *gorm.DB, progress func(progress float64)) (*OrphanedCacheResult, error) {
+	ctx := db.Statement.Context
+	result := &OrphanedCacheResult{}
+	cachePath := utils.MediaCachePath()
+
+	albumDirs, err := os.ReadDir(cachePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, errors.Wrap(err, "read media cache directory")
+	}
+
+	var albumIDs []int
+	if err := db.Model(&models.Album{}).Pluck("id", &albumIDs).Error; err != nil {
+		return nil, errors.Wrap(err, "get albums from database")
+	}
+
+	albums := make(map[int]bool, len(albumIDs))
+	for _, id := range albumIDs {
+		albums[id] = true
+	}
+
+	for i, albumDir := range albumDirs {
+		progress(float64(i) / float64(len(albumDirs)))
+
+		// Only directories named by the cache itself are touched, anything else in the cache path is left alone
+		albumID, err := strconv.Atoi(albumDir.Name())
+		if err != nil || !albumDir.IsDir() {
+			continue
+		}
+
+		albumPath := path.Join(cachePath, albumDir.Name())
+
+		if !albums[albumID] {
+			exists, err := recordExists(db, &models.Album{}, albumID)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				continue
+			}
+
+			log.Debug(ctx, "Removing cache of deleted album", "album_id", albumID)
+			size, err := removeCacheDirectory(albumPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "remove cache of album %d", albumID)
+			}
+			result.Albums++
+			result.Bytes += size
+			continue
+		}
+
+		if err := removeOrphanedMediaDirectories(db, albumID, albumPath, result); err != nil {
+			return nil, err
+		}
+	}
+
+	progress(1)
+
+	return result, nil
+}
+
+// removeOrphanedMediaDirectories deletes the cache directories of media that no longer exist in the album
+func removeOrphanedMediaDirectories(db *gorm.DB, albumID int, albumPath string, result *OrphanedCacheResult) error {
+	mediaDirs, err := os.ReadDir(albumPath)
+	if err != nil {
+		return errors.Wrapf(err, "read cache of album %d", albumID)
+	}
+
+	var mediaIDs []int
+	if err := db.Model(&models.Media{}).Where("album_id = ?", albumID).Pluck("id", &mediaIDs).Error; err != nil {
+		return errors.Wrap(err, "get media of album from database")
+	}
+
+	media := make(map[int]bool, len(mediaIDs))
+	for _, id := range mediaIDs {
+		media[id] = true
+	}
+
+	for _, mediaDir := range mediaDirs {
+		mediaID, err := strconv.Atoi(mediaDir.Name())
+		if err != nil || !mediaDir.IsDir() || media[mediaID] {
+			continue
+		}
+
+		exists, err := recordExists(db, &models.Media{}, mediaID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		log.Debug(db.Statement.Context, "Removing cache of deleted media", "album_id", albumID, "media_id", mediaID)
+		size, err := removeCacheDirectory(path.Join(albumPath, mediaDir.Name()))
+		if err != nil {
+			return errors.Wrapf(err, "remove cache of media %d", mediaID)
+		}
+		result.Media++
+		result.Bytes += size
+	}
+
+	return nil
+}
+
+// recordExists returns whether the album or media with the given id exists in the database
+func recordExists(db *gorm.DB, model interface{}, id int) (bool, error) {
+	var count int64
+	if err := db.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, errors.Wrap(err, "check whether cached record exists")
+	}
+
+	return count > 0, nil
+}
+
+// removeCacheDirectory deletes a directory of the cache and returns the size of the files it contained
+func removeCacheDirectory(dirPath string) (int64, error) {
+	size, err := directorySize(dirPath)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.RemoveAll(dirPath); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
@@ -0,0 +1,68 @@
+package storage_test
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveOrphanedCacheDirectories(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	test_utils.FilesystemTest(t)
+
+	albums := make([]*models.Album, 2)
+	for i := range albums {
+		albums[i] = &models.Album{Title: "album", Path: "/photos/" + strconv.Itoa(i)}
+		if !assert.NoError(t, db.Save(albums[i]).Error) {
+			return
+		}
+	}
+
+	media := make([]*models.Media, 2)
+	for i := range media {
+		media[i] = &models.Media{Title: "photo.jpg", Path: "/photos/0/photo" + strconv.Itoa(i) + ".jpg", AlbumID: albums[0].ID}
+		if !assert.NoError(t, db.Save(media[i]).Error) {
+			return
+		}
+	}
+
+	cachePath := utils.MediaCachePath()
+	writeCached := func(dir string, size int) {
+		assert.NoError(t, os.MkdirAll(dir, 0755))
+		assert.NoError(t, os.WriteFile(path.Join(dir, "thumbnail.jpg"), make([]byte, size), 0644))
+	}
+
+	keptMedia := path.Join(cachePath, strconv.Itoa(albums[0].ID), strconv.Itoa(media[0].ID))
+	deletedMedia := path.Join(cachePath, strconv.Itoa(albums[0].ID), strconv.Itoa(media[1].ID))
+	deletedAlbum := path.Join(cachePath, strconv.Itoa(albums[1].ID))
+	unrelated := path.Join(cachePath, "unrelated")
+
+	writeCached(keptMedia, 100)
+	writeCached(deletedMedia, 200)
+	writeCached(path.Join(deletedAlbum, "1"), 300)
+	writeCached(unrelated, 500)
+
+	assert.NoError(t, db.Delete(media[1]).Error)
+	assert.NoError(t, db.Delete(albums[1]).Error)
+
+	result, err := storage.RemoveOrphanedCacheDirectories(db, func(float64) {})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 1, result.Albums)
+	assert.Equal(t, 1, result.Media)
+	assert.EqualValues(t, 500, result.Bytes, "the size of the removed files is reported")
+
+	assert.DirExists(t, keptMedia)
+	assert.NoDirExists(t, deletedMedia)
+	assert.NoDirExists(t, deletedAlbum)
+	assert.DirExists(t, unrelated)
+}
@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type retrievalQueue struct {
+	idle_chan chan bool
+	db        *gorm.DB
+}
+
+var global_retrieval_queue *retrievalQueue = nil
+
+// How long the queue waits before picking up pending retrievals again, after the database failed to update them
+const retrievalRetryInterval = 30 * time.Second
+
+// InitializeRetrievalQueue starts the background worker that retrieves originals from cold storage.
+// Retrievals interrupted by a restart are picked up again.
+func InitializeRetrievalQueue(db *gorm.DB) error {
+	err := db.Model(&models.MediaRetrieval{}).
+		Where("status = ?", models.RetrievalStatusInProgress).
+		Update("status", models.RetrievalStatusPending).Error
+	if err != nil {
+		return errors.Wrap(err, "reset interrupted media retrievals")
+	}
+
+	global_retrieval_queue = &retrievalQueue{
+		idle_chan: make(chan bool, 1),
+		db:        db,
+	}
+
+	go global_retrieval_queue.startBackgroundWorker()
+	global_retrieval_queue.notify()
+
+	return nil
+}
+
+// RetrievedPath returns the path in the media cache, where an original retrieved from cold storage is staged
+func RetrievedPath(media *models.Media) (string, error) {
+	cachePath, err := media.CachePath()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(cachePath, "retrieved_"+path.Base(media.Path)), nil
+}
+
+// RequestRetrieval queues the original of the given media to be retrieved from cold storage.
+// If a retrieval already exists for the media it is returned as is, unless it has failed, in which case it is retried.
+// Function does not block.
+func RequestRetrieval(db *gorm.DB, media *models.Media) (*models.MediaRetrieval, error) {
+	var retrieval models.MediaRetrieval
+	result := db.Where("media_id = ?", media.ID).Limit(1).Find(&retrieval)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "get media retrieval from database")
+	}
+
+	if result.RowsAffected > 0 {
+		if retrieval.Status == models.RetrievalStatusCompleted {
+			// Make sure the staged file has not been removed from the cache since
+			retrievedPath, err := RetrievedPath(media)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := os.Stat(retrievedPath); err == nil {
+				return &retrieval, nil
+			}
+		} else if retrieval.Status != models.RetrievalStatusFailed {
+			return &retrieval, nil
+		}
+
+		retrieval.Status = models.RetrievalStatusPending
+		retrieval.Error = nil
+		retrieval.CompletedAt = nil
+		if err := db.Save(&retrieval).Error; err != nil {
+			return nil, errors.Wrap(err, "requeue media retrieval")
+		}
+	} else {
+		retrieval = models.MediaRetrieval{
+			MediaID: media.ID,
+			Status:  models.RetrievalStatusPending,
+		}
+
+		if err := db.Create(&retrieval).Error; err != nil {
+			return nil, errors.Wrap(err, "insert media retrieval into database")
+		}
+	}
+
+	if global_retrieval_queue != nil {
+		global_retrieval_queue.notify()
+	}
+
+	return &retrieval, nil
+}
+
+func (queue *retrievalQueue) startBackgroundWorker() {
+	for {
+		<-queue.idle_chan
+
+		for {
+			var pending []*models.MediaRetrieval
+			if err := queue.db.Where("status = ?", models.RetrievalStatusPending).Order("created_at").Limit(1).Find(&pending).Error; err != nil {
+				log.Error(context.Background(), "Get pending media retrievals", "error", err)
+				queue.retryLater()
+				break
+			}
+
+			if len(pending) == 0 {
+				break
+			}
+
+			// A retrieval that could not be started stays pending, so it is only picked up again after a while
+			if !queue.processRetrieval(pending[0]) {
+				queue.retryLater()
+				break
+			}
+		}
+	}
+}
+
+// processRetrieval retrieves the original of a pending retrieval. It returns false if the retrieval could not be started,
+// because its status could not be updated.
+func (queue *retrievalQueue) processRetrieval(retrieval *models.MediaRetrieval) bool {
+	ctx := log.WithAttrs(context.Background(), "retrieval_id", retrieval.ID, "media_id", retrieval.MediaID)
+
+	retrieval.Status = models.RetrievalStatusInProgress
+	if err := queue.db.Save(retrieval).Error; err != nil {
+		log.Error(ctx, "Update media retrieval status", "error", err)
+		return false
+	}
+
+	var media models.Media
+	err := queue.db.First(&media, retrieval.MediaID).Error
+	if err == nil {
+		log.Info(ctx, "Retrieving media from cold storage", "path", media.Path)
+		err = stageOriginal(&media)
+	}
+
+	if err != nil {
+		log.Error(ctx, "Retrieving media from cold storage", "error", err)
+		errorMessage := err.Error()
+		retrieval.Status = models.RetrievalStatusFailed
+		retrieval.Error = &errorMessage
+	} else {
+		completedAt := time.Now()
+		retrieval.Status = models.RetrievalStatusCompleted
+		retrieval.CompletedAt = &completedAt
+	}
+
+	if err := queue.db.Save(retrieval).Error; err != nil {
+		log.Error(ctx, "Update media retrieval status", "error", err)
+		queue.saveResultLater(ctx, retrieval)
+	}
+
+	return true
+}
+
+// saveResultLater saves the result of a retrieval again once the retry interval has passed, and keeps retrying
+// until it is saved, as the retrieval otherwise stays in progress and is never requested again
+func (queue *retrievalQueue) saveResultLater(ctx context.Context, retrieval *models.MediaRetrieval) {
+	time.AfterFunc(retrievalRetryInterval, func() {
+		if err := queue.db.Save(retrieval).Error; err != nil {
+			log.Error(ctx, "Update media retrieval status", "error", err)
+			queue.saveResultLater(ctx, retrieval)
+		}
+	})
+}
+
+// stageOriginal copies the original media file into the cache, reading it in full from the cold storage
+func stageOriginal(media *models.Media) error {
+	retrievedPath, err := RetrievedPath(media)
+	if err != nil {
+		return err
+	}
+
+	original, err := os.Open(media.Path)
+	if err != nil {
+		return errors.Wrap(err, "open original media")
+	}
+	defer original.Close()
+
+	return utils.WriteFileAtomic(retrievedPath, func(tmpPath string) error {
+		staged, err := os.Create(tmpPath)
+		if err != nil {
+			return errors.Wrap(err, "create staged media file")
+		}
+
+		if _, err := io.Copy(staged, original); err != nil {
+			staged.Close()
+			return errors.Wrap(err, "copy original media to cache")
+		}
+
+		if err := staged.Close(); err != nil {
+			return errors.Wrap(err, "close staged media file")
+		}
+
+		return nil
+	})
+}
+
+// retryLater notifies the queue once the retry interval has passed, as no new retrieval may notify it before then
+func (queue *retrievalQueue) retryLater() {
+	time.AfterFunc(retrievalRetryInterval, func() { queue.notify() })
+}
+
+// Notifies the queue that new retrievals are pending
+func (queue *retrievalQueue) notify() bool {
+	select {
+	case queue.idle_chan <- true:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Default number of files read from each backend when probing its read latency
+const defaultProbeSampleSize = 20
+
+// Max number of files that can be read from each backend when probing its read latency
+const maxProbeSampleSize = 1000
+
+// Max number of directories listed on each backend when probing its listing speed
+const maxProbeDirectories = 50
+
+// Number of bytes read from each sampled file, enough to parse headers such as exif
+const probeReadSize = 64 * 1024
+
+// DiagnoseStorageBackends probes every configured storage backend concurrently,
+// by listing its directories and reading the beginning of a sample of its files.
+// A sample size of zero reads the default number of files, and at least one file is read.
+func DiagnoseStorageBackends(db *gorm.DB, sampleSize int) ([]*models.StorageDiagnostics, error) {
+	if sampleSize > maxProbeSampleSize {
+		return nil, errors.Errorf("sample size must be at most %d", maxProbeSampleSize)
+	}
+
+	if sampleSize == 0 {
+		sampleSize = defaultProbeSampleSize
+	} else if sampleSize < 1 {
+		sampleSize = 1
+	}
+
+	var backends []*models.StorageBackend
+	if err := db.Order("name").Find(&backends).Error; err != nil {
+		return nil, errors.Wrap(err, "get storage backends from database")
+	}
+
+	results := make([]*models.StorageDiagnostics, len(backends))
+
+	var wg sync.WaitGroup
+	for i, backend := range backends {
+		wg.Add(1)
+		go func(i int, backend *models.StorageBackend) {
+			defer wg.Done()
+			results[i] = probeBackend(backend, sampleSize)
+		}(i, backend)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func probeBackend(backend *models.StorageBackend, sampleSize int) *models.StorageDiagnostics {
+	result := &models.StorageDiagnostics{
+		Backend:    backend,
+		Reachable:  true,
+		ProbedAt:   time.Now(),
+		ErrorCount: 0,
+	}
+
+	recordError := func(err error) {
+		result.ErrorCount++
+		errorMessage := err.Error()
+		result.LastError = &errorMessage
+	}
+
+	// List directories breadth first, collecting sample files to read
+	sampleFiles := make([]string, 0, sampleSize)
+	dirQueue := []string{backend.Path}
+	listingTime := time.Duration(0)
+
+	for len(dirQueue) > 0 && result.ListedDirectories < maxProbeDirectories {
+		dir := dirQueue[0]
+		dirQueue = dirQueue[1:]
+
+		start := time.Now()
+		entries, err := os.ReadDir(dir)
+		listingTime += time.Since(start)
+		result.Probes++
+
+		if err != nil {
+			recordError(errors.Wrapf(err, "list directory (%s)", dir))
+			if dir == backend.Path {
+				result.Reachable = false
+				break
+			}
+			continue
+		}
+
+		result.ListedDirectories++
+		result.ListedEntries += len(entries)
+
+		for _, entry := range entries {
+			entryPath := path.Join(dir, entry.Name())
+			if entry.IsDir() {
+				dirQueue = append(dirQueue, entryPath)
+			} else if entry.Type().IsRegular() && len(sampleFiles) < sampleSize {
+				sampleFiles = append(sampleFiles, entryPath)
+			}
+		}
+	}
+
+	if result.ListedDirectories > 0 {
+		listingMs := durationMs(listingTime) / float64(result.ListedDirectories)
+		result.ListingLatencyMs = &listingMs
+
+		if listingTime > 0 {
+			entriesPerSecond := float64(result.ListedEntries) / listingTime.Seconds()
+			result.ListingEntriesPerSecond = &entriesPerSecond
+		}
+	}
+
+	// Read the beginning of each sample file
+	readTime := time.Duration(0)
+	readCount := 0
+	for _, file := range sampleFiles {
+		elapsed, err := probeRead(file)
+		result.Probes++
+
+		if err != nil {
+			recordError(errors.Wrapf(err, "read file (%s)", file))
+			continue
+		}
+
+		readCount++
+		readTime += elapsed
+
+		if result.MaxReadLatencyMs == nil || durationMs(elapsed) > *result.MaxReadLatencyMs {
+			maxMs := durationMs(elapsed)
+			result.MaxReadLatencyMs = &maxMs
+		}
+	}
+
+	if readCount > 0 {
+		readMs := durationMs(readTime) / float64(readCount)
+		result.ReadLatencyMs = &readMs
+	}
+
+	if result.Probes > 0 {
+		result.ErrorRate = float64(result.ErrorCount) / float64(result.Probes)
+	}
+
+	return result
+}
+
+func probeRead(filePath string) (time.Duration, error) {
+	start := time.Now()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if _, err := io.CopyN(io.Discard, file, probeReadSize); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	return time.Since(start), nil
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
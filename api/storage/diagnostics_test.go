@@ -0,0 +1,70 @@
+package storage_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnoseStorageBackends(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	backendDir := t.TempDir()
+	assert.NoError(t, os.Mkdir(path.Join(backendDir, "sub"), 0755))
+	assert.NoError(t, os.WriteFile(path.Join(backendDir, "a.jpg"), []byte("image"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(backendDir, "sub", "b.jpg"), []byte("image"), 0644))
+
+	backends := []*models.StorageBackend{
+		{Name: "local", Path: backendDir},
+		{Name: "missing", Path: path.Join(backendDir, "missing")},
+	}
+	if !assert.NoError(t, db.Create(&backends).Error) {
+		return
+	}
+
+	results, err := storage.DiagnoseStorageBackends(db, 0)
+	if !assert.NoError(t, err) || !assert.Len(t, results, 2) {
+		return
+	}
+
+	local := results[0]
+	assert.Equal(t, "local", local.Backend.Name)
+	assert.True(t, local.Reachable)
+	assert.Equal(t, 2, local.ListedDirectories)
+	assert.Equal(t, 3, local.ListedEntries)
+	assert.Equal(t, 4, local.Probes)
+	assert.Equal(t, 0, local.ErrorCount)
+	assert.NotNil(t, local.ReadLatencyMs)
+
+	missing := results[1]
+	assert.Equal(t, "missing", missing.Backend.Name)
+	assert.False(t, missing.Reachable)
+	assert.Equal(t, 1, missing.ErrorCount)
+	assert.Equal(t, 1.0, missing.ErrorRate)
+	assert.NotNil(t, missing.LastError)
+}
+
+func TestDiagnoseStorageBackendsSampleSize(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	backendDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(backendDir, "a.jpg"), []byte("image"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(backendDir, "b.jpg"), []byte("image"), 0644))
+	if !assert.NoError(t, db.Create(&models.StorageBackend{Name: "local", Path: backendDir}).Error) {
+		return
+	}
+
+	_, err := storage.DiagnoseStorageBackends(db, 1001)
+	assert.Error(t, err)
+
+	// At least one file is read
+	results, err := storage.DiagnoseStorageBackends(db, -5)
+	if assert.NoError(t, err) && assert.Len(t, results, 1) {
+		assert.Equal(t, 2, results[0].Probes)
+	}
+}
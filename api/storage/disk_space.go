@@ -0,0 +1,18 @@
+//go:build !windows
+
+package storage
+
+import (
+	"syscall"
+)
+
+// diskSpace returns the free and total space in bytes of the filesystem holding the given path
+func diskSpace(path string) (free int64, total int64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+
+	blockSize := int64(stat.Bsize)
+	return int64(stat.Bavail) * blockSize, int64(stat.Blocks) * blockSize, nil
+}
@@ -0,0 +1,9 @@
+package storage
+
+import (
+	"errors"
+)
+
+func diskSpace(path string) (free int64, total int64, err error) {
+	return 0, 0, errors.New("disk space is not supported on windows")
+}
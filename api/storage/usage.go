@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UpdateAlbumStorageUsage recomputes the disk space used by the media of an album.
+// Cached files are counted with the size they had when they were generated, even if they have been evicted since.
+func UpdateAlbumStorageUsage(db *gorm.DB, albumID int) error {
+	usage := models.AlbumStorageUsage{AlbumID: albumID}
+
+	err := db.Model(&models.Media{}).
+		Select("COUNT(DISTINCT media.id) AS media_count, "+
+			"COALESCE(SUM(CASE WHEN media_urls.purpose = ? THEN media_urls.file_size ELSE 0 END), 0) AS original_bytes, "+
+			"COALESCE(SUM(CASE WHEN media_urls.purpose <> ? THEN media_urls.file_size ELSE 0 END), 0) AS cache_bytes",
+			models.MediaOriginal, models.MediaOriginal).
+		Joins("LEFT JOIN media_urls ON media_urls.media_id = media.id").
+		Where("media.album_id = ?", albumID).
+		Scan(&usage).Error
+	if err != nil {
+		return errors.Wrapf(err, "compute storage usage of album (%d)", albumID)
+	}
+
+	usage.AlbumID = albumID
+	usage.UpdatedAt = time.Now()
+
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&usage).Error; err != nil {
+		return errors.Wrapf(err, "save storage usage of album (%d)", albumID)
+	}
+
+	return nil
+}
+
+// RefreshStorageUsage recomputes the disk space used by every album
+func RefreshStorageUsage(db *gorm.DB) error {
+	var albumIDs []int
+	if err := db.Model(&models.Album{}).Pluck("id", &albumIDs).Error; err != nil {
+		return errors.Wrap(err, "get albums from database")
+	}
+
+	for _, albumID := range albumIDs {
+		if err := UpdateAlbumStorageUsage(db, albumID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetUserStorageUsage sums the disk space used by the albums of every user, largest first.
+// Albums shared between users are counted for each of them.
+func GetUserStorageUsage(db *gorm.DB) ([]*models.UserStorageUsage, error) {
+	var users []*models.User
+	if err := db.Order("id").Find(&users).Error; err != nil {
+		return nil, errors.Wrap(err, "get users from database")
+	}
+
+	var rows []struct {
+		UserID        int
+		MediaCount    int
+		OriginalBytes int64
+		CacheBytes    int64
+	}
+
+	err := db.Table("user_albums").
+		Select("user_albums.user_id AS user_id, " +
+			"SUM(album_storage_usages.media_count) AS media_count, " +
+			"SUM(album_storage_usages.original_bytes) AS original_bytes, " +
+			"SUM(album_storage_usages.cache_bytes) AS cache_bytes").
+		Joins("JOIN album_storage_usages ON album_storage_usages.album_id = user_albums.album_id").
+		Group("user_albums.user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "sum storage usage of users")
+	}
+
+	usageByUser := make(map[int]*models.StorageUsage, len(rows))
+	for _, row := range rows {
+		usageByUser[row.UserID] = &models.StorageUsage{
+			MediaCount:    row.MediaCount,
+			OriginalBytes: int(row.OriginalBytes),
+			CacheBytes:    int(row.CacheBytes),
+		}
+	}
+
+	result := make([]*models.UserStorageUsage, len(users))
+	for i, user := range users {
+		usage, found := usageByUser[user.ID]
+		if !found {
+			usage = &models.StorageUsage{}
+		}
+		result[i] = &models.UserStorageUsage{User: user, Usage: usage}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Usage.OriginalBytes+result[i].Usage.CacheBytes > result[j].Usage.OriginalBytes+result[j].Usage.CacheBytes
+	})
+
+	return result, nil
+}
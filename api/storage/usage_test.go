@@ -0,0 +1,74 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/storage"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageUsage(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	owner, err := models.RegisterUser(db, "owner", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	other, err := models.RegisterUser(db, "other", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	album := models.Album{Title: "album", Path: "/photos"}
+	emptyAlbum := models.Album{Title: "empty", Path: "/photos/empty"}
+	assert.NoError(t, db.Save(&album).Error)
+	assert.NoError(t, db.Save(&emptyAlbum).Error)
+	assert.NoError(t, db.Model(owner).Association("Albums").Append(&album, &emptyAlbum))
+
+	media := []models.Media{
+		{Title: "a.jpg", Path: "/photos/a.jpg", AlbumID: album.ID},
+		{Title: "b.jpg", Path: "/photos/b.jpg", AlbumID: album.ID},
+	}
+	assert.NoError(t, db.Save(&media).Error)
+
+	mediaURLs := []models.MediaURL{
+		{MediaID: media[0].ID, MediaName: "a_original", Purpose: models.MediaOriginal, FileSize: 1000},
+		{MediaID: media[0].ID, MediaName: "a_thumbnail", Purpose: models.PhotoThumbnail, FileSize: 20},
+		{MediaID: media[0].ID, MediaName: "a_highres", Purpose: models.PhotoHighRes, FileSize: 300},
+		{MediaID: media[1].ID, MediaName: "b_original", Purpose: models.MediaOriginal, FileSize: 2000},
+	}
+	assert.NoError(t, db.Save(&mediaURLs).Error)
+
+	if !assert.NoError(t, storage.RefreshStorageUsage(db)) {
+		return
+	}
+
+	var usage models.AlbumStorageUsage
+	assert.NoError(t, db.First(&usage, album.ID).Error)
+	assert.Equal(t, 2, usage.MediaCount)
+	assert.EqualValues(t, 3000, usage.OriginalBytes)
+	assert.EqualValues(t, 320, usage.CacheBytes)
+
+	var emptyUsage models.AlbumStorageUsage
+	assert.NoError(t, db.First(&emptyUsage, emptyAlbum.ID).Error)
+	assert.Equal(t, 0, emptyUsage.MediaCount)
+
+	// Recomputing replaces the previous usage
+	assert.NoError(t, db.Delete(&mediaURLs[2]).Error)
+	assert.NoError(t, storage.UpdateAlbumStorageUsage(db, album.ID))
+	assert.NoError(t, db.First(&usage, album.ID).Error)
+	assert.EqualValues(t, 20, usage.CacheBytes)
+
+	users, err := storage.GetUserStorageUsage(db)
+	if !assert.NoError(t, err) || !assert.Len(t, users, 2) {
+		return
+	}
+
+	assert.Equal(t, owner.ID, users[0].User.ID)
+	assert.Equal(t, &models.StorageUsage{MediaCount: 2, OriginalBytes: 3000, CacheBytes: 20}, users[0].Usage)
+	assert.Equal(t, other.ID, users[1].User.ID)
+	assert.Equal(t, &models.StorageUsage{}, users[1].Usage)
+}
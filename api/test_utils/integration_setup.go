@@ -1,13 +1,14 @@
 package test_utils
 
 import (
+	"context"
 	"flag"
-	"log"
 	"path"
 	"runtime"
 	"testing"
 
 	"github.com/joho/godotenv"
+	"github.com/photoview/photoview/api/log"
 	"github.com/photoview/photoview/api/utils"
 	"gorm.io/gorm"
 )
@@ -34,7 +35,7 @@ func IntegrationTestRun(m *testing.M) int {
 
 	_, file, _, ok := runtime.Caller(0)
 	if !ok {
-		log.Fatal("could not get runtime file path")
+		log.Fatal(context.Background(), "Could not get runtime file path")
 	}
 
 	if *integration_flags.Database {
@@ -42,7 +43,7 @@ func IntegrationTestRun(m *testing.M) int {
 		envPath := path.Join(path.Dir(file), "..", "testing.env")
 
 		if err := godotenv.Load(envPath); err != nil {
-			log.Println("No testing.env file found")
+			log.Info(context.Background(), "No testing.env file found")
 		}
 	}
 
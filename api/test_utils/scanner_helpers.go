@@ -1,6 +1,7 @@
 package test_utils
 
 import (
+	"context"
 	"testing"
 
 	"github.com/photoview/photoview/api/graphql/models"
@@ -14,7 +15,7 @@ func RunScannerOnUser(t *testing.T, db *gorm.DB, user *models.User) {
 		return
 	}
 
-	if !assert.NoError(t, scanner_queue.AddUserToQueue(user)) {
+	if !assert.NoError(t, scanner_queue.AddUserToQueue(context.Background(), user)) {
 		return
 	}
 
@@ -27,7 +28,7 @@ func RunScannerAll(t *testing.T, db *gorm.DB) {
 		return
 	}
 
-	if !assert.NoError(t, scanner_queue.AddAllToQueue()) {
+	if !assert.NoError(t, scanner_queue.AddAllToQueue(context.Background())) {
 		return
 	}
 
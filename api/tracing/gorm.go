@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	gormSpanKey          = "tracing:span"
+	gormParentContextKey = "tracing:parent_context"
+)
+
+// GormPlugin records a span for every database query, as a child of the span of the context of the query.
+// Queries should be made with db.WithContext, or the context of a resolver, to be part of the trace of a request.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+
+	errs := []error{
+		callback.Create().Before("gorm:create").Register("tracing:before_create", startQuerySpan("create")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", endQuerySpan),
+		callback.Query().Before("gorm:query").Register("tracing:before_query", startQuerySpan("query")),
+		callback.Query().After("gorm:query").Register("tracing:after_query", endQuerySpan),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", startQuerySpan("update")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", endQuerySpan),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", startQuerySpan("delete")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", endQuerySpan),
+		callback.Row().Before("gorm:row").Register("tracing:before_row", startQuerySpan("row")),
+		callback.Row().After("gorm:row").Register("tracing:after_row", endQuerySpan),
+		callback.Raw().Before("gorm:raw").Register("tracing:before_raw", startQuerySpan("raw")),
+		callback.Raw().After("gorm:raw").Register("tracing:after_raw", endQuerySpan),
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return errors.Wrap(err, "register tracing callbacks")
+		}
+	}
+
+	return nil
+}
+
+func startQuerySpan(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+
+		ctx, span := tracer.Start(db.Statement.Context, "db."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(db.Dialector.Name()),
+				semconv.DBOperationKey.String(operation),
+			))
+
+		db.InstanceSet(gormParentContextKey, db.Statement.Context)
+		db.InstanceSet(gormSpanKey, span)
+		db.Statement.Context = ctx
+	}
+}
+
+func endQuerySpan(db *gorm.DB) {
+	value, found := db.InstanceGet(gormSpanKey)
+	if !found {
+		return
+	}
+
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+
+	// Statements can be reused by the next query, which should not become a child of this one
+	if parent, found := db.InstanceGet(gormParentContextKey); found {
+		if ctx, ok := parent.(context.Context); ok {
+			db.Statement.Context = ctx
+		}
+	}
+
+	span.SetAttributes(
+		semconv.DBStatementKey.String(db.Statement.SQL.String()),
+		semconv.DBSQLTableKey.String(db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+
+	// Finding no record is an expected outcome of many queries, rather than a failure
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+
+	EndSpan(span, err)
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// GraphQLTracer is a gqlgen extension recording a span for every graphql operation,
+// and a child span for every field that is resolved by a resolver function, rather than read from a struct
+type GraphQLTracer struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = GraphQLTracer{}
+
+func (GraphQLTracer) ExtensionName() string {
+	return "OpenTelemetryTracing"
+}
+
+func (GraphQLTracer) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (GraphQLTracer) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+
+	operation := graphql.GetOperationContext(ctx)
+
+	name := operation.OperationName
+	if name == "" {
+		name = "anonymous"
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("graphql.operation.name", name)}
+	if operation.Operation != nil {
+		attrs = append(attrs, attribute.String("graphql.operation.type", string(operation.Operation.Operation)))
+	}
+
+	ctx, span := Start(ctx, "graphql "+name, attrs...)
+
+	response := next(ctx)
+
+	var err error
+	if response != nil && len(response.Errors) > 0 {
+		err = response.Errors
+	}
+	EndSpan(span, err)
+
+	return response
+}
+
+func (GraphQLTracer) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	field := graphql.GetFieldContext(ctx)
+	if field == nil || !field.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := Start(ctx, "resolve "+field.Object+"."+field.Field.Name,
+		attribute.String("graphql.field.path", field.Path().String()))
+
+	result, err := next(ctx)
+	EndSpan(span, err)
+
+	return result, err
+}
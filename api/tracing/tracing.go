@@ -0,0 +1,117 @@
+// Package tracing records OpenTelemetry spans of requests, graphql resolvers, database queries, scans and external programs,
+// exported over OTLP to a collector such as Jaeger or Tempo, to find out where time is spent on slow installs.
+//
+// Tracing is enabled by PHOTOVIEW_TRACING_ENABLED, the collector and sampling are configured
+// by the standard OTEL_EXPORTER_OTLP_* and OTEL_TRACES_SAMPLER environment variables.
+// When tracing is disabled, spans are not recorded and cost next to nothing.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/utils"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/photoview/photoview/api"
+
+// The global tracer forwards to the provider set by InitializeTracing, until then spans are not recorded
+var tracer = otel.Tracer(instrumentationName)
+
+var enabled = false
+
+// InitializeTracing starts exporting spans if tracing is enabled.
+// The returned function flushes the spans not exported yet, and should be called before the server stops.
+func InitializeTracing(ctx context.Context) (func(context.Context) error, error) {
+	if !utils.EnvTracingEnabled.GetBool() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "create otlp trace exporter")
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence over the default service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceNameKey.String("photoview")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithSchemaURL(semconv.SchemaURL),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create tracing resource")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warn(context.Background(), "Exporting traces", "error", err)
+	}))
+
+	enabled = true
+	log.Info(ctx, "Tracing enabled")
+
+	return provider.Shutdown, nil
+}
+
+// Enabled returns whether spans are recorded and exported
+func Enabled() bool {
+	return enabled
+}
+
+// Start starts a span as a child of the span of the context, if any.
+// The span must be ended, usually with EndSpan.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan ends a span, marking it as failed if an error is given
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// TraceID returns the id of the trace of the span in the context, or an empty string if it isn't recorded
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() || !spanContext.IsSampled() {
+		return ""
+	}
+
+	return spanContext.TraceID().String()
+}
+
+// StartRequest starts the span of a request handled by the server,
+// continuing the trace of the client or reverse proxy if it passes one in the request headers
+func StartRequest(r *http.Request, route string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	return tracer.Start(ctx, "HTTP "+r.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPRouteKey.String(route),
+			semconv.HTTPTargetKey.String(r.URL.Path),
+		))
+}
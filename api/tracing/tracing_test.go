@@ -0,0 +1,89 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// The global tracer only forwards to the first provider that is set, so all tests share the same exporter
+var exporter = tracetest.NewInMemoryExporter()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+func TestEndSpan(t *testing.T) {
+	exporter.Reset()
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	_, child := tracing.Start(ctx, "child")
+	tracing.EndSpan(child, errors.New("failed"))
+	tracing.EndSpan(parent, nil)
+
+	assert.NotEmpty(t, tracing.TraceID(ctx))
+	assert.Empty(t, tracing.TraceID(context.Background()))
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+
+	assert.Equal(t, "parent", spans[1].Name)
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+}
+
+func TestGormPlugin(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+	exporter.Reset()
+
+	if !assert.NoError(t, db.Use(tracing.GormPlugin{})) {
+		return
+	}
+
+	ctx, parent := tracing.Start(context.Background(), "request")
+	tx := db.WithContext(ctx)
+
+	var users []*models.User
+	assert.NoError(t, tx.Find(&users).Error)
+
+	// A record not being found is not a failed query
+	var user models.User
+	assert.Error(t, tx.First(&user, 1234).Error)
+
+	tracing.EndSpan(parent, nil)
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+
+	for _, span := range spans[:2] {
+		assert.Equal(t, "db.query", span.Name)
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+		assert.Equal(t, codes.Unset, span.Status.Code)
+	}
+
+	var statement string
+	for _, attr := range spans[0].Attributes {
+		if attr.Key == "db.statement" {
+			statement = attr.Value.AsString()
+		}
+	}
+	assert.Contains(t, statement, "users")
+}
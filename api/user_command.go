@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"github.com/photoview/photoview/api/scanner"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// runUserCommand creates a user, or lists the users.
+// The password is read from standard input if it is not given, so it doesn't show up in the list of processes.
+//
+//	echo $PASSWORD | photoview user create -username admin -admin -root /photos
+//	photoview user list
+func runUserCommand(db *gorm.DB, args []string) error {
+	action, err := expectSubcommand(args, "create", "list")
+	if err != nil {
+		return err
+	}
+
+	if action == "list" {
+		return listUsers(db)
+	}
+
+	flags := flag.NewFlagSet("user create", flag.ContinueOnError)
+	username := flags.String("username", "", "username of the new user")
+	password := flags.String("password", "", "password of the new user, read from standard input if not set")
+	admin := flags.Bool("admin", false, "make the user an admin")
+	rootPath := flags.String("root", "", "path of the media of the user, which is scanned for albums")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *username == "" {
+		flags.Usage()
+		return errors.New("-username is required")
+	}
+
+	if *password == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return errors.Wrap(err, "read password from standard input")
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	if *password == "" {
+		return errors.New("password must not be empty")
+	}
+
+	var user *models.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		user, err = models.RegisterUser(tx, *username, password, *admin)
+		if err != nil {
+			return err
+		}
+
+		if *rootPath != "" {
+			if _, err := scanner.NewRootAlbum(tx, path.Clean(*rootPath), user); err != nil {
+				return errors.Wrapf(err, "add root path %s", *rootPath)
+			}
+		}
+
+		// An admin created from the command line takes the place of the one created by the setup wizard
+		if *admin {
+			// The site info is created on first use, which may not have happened yet
+			if _, err := models.GetSiteInfo(tx); err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE site_info SET initial_setup = false").Error; err != nil {
+				return errors.Wrap(err, "finish initial setup")
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info(db.Statement.Context, "User created", "user_id", user.ID, "username", user.Username, "admin", user.Admin)
+	return nil
+}
+
+func listUsers(db *gorm.DB) error {
+	var users []*models.User
+	if err := db.Preload("Albums").Order("id").Find(&users).Error; err != nil {
+		return errors.Wrap(err, "get users from database")
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tUSERNAME\tADMIN\tROOT PATHS")
+	for _, user := range users {
+		// Root albums are the albums of the user whose parent the user doesn't have
+		albumIDs := make(map[int]bool, len(user.Albums))
+		for _, album := range user.Albums {
+			albumIDs[album.ID] = true
+		}
+
+		rootPaths := make([]string, 0)
+		for _, album := range user.Albums {
+			if album.ParentAlbumID == nil || !albumIDs[*album.ParentAlbumID] {
+				rootPaths = append(rootPaths, album.Path)
+			}
+		}
+		fmt.Fprintf(table, "%d\t%s\t%t\t%s\n", user.ID, user.Username, user.Admin, strings.Join(rootPaths, ", "))
+	}
+
+	return table.Flush()
+}
@@ -1,11 +1,15 @@
 package utils
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net"
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
+
+	"github.com/photoview/photoview/api/log"
 )
 
 func ApiListenUrl() *url.URL {
@@ -15,7 +19,7 @@ func ApiListenUrl() *url.URL {
 
 	apiPrefix := "/"
 	if shouldServeUI {
-		apiPrefix = "/api"
+		apiPrefix = path.Join(UIBasePath(), "api")
 	}
 
 	var listenAddr string
@@ -32,12 +36,17 @@ func ApiListenUrl() *url.URL {
 
 	listenPort, err := strconv.Atoi(listenPortStr)
 	if err != nil {
-		log.Fatalf("%s must be a number: '%s'\n%s", EnvListenPort.GetName(), listenPortStr, err)
+		log.Fatal(context.Background(), EnvListenPort.GetName()+" must be a number", "value", listenPortStr, "error", err)
+	}
+
+	scheme := "http"
+	if EnvTLSCert.GetValue() != "" || EnvTLSACMEDomains.GetValue() != "" {
+		scheme = "https"
 	}
 
-	apiUrl, err := url.Parse(fmt.Sprintf("http://%s:%d", listenAddr, listenPort))
+	apiUrl, err := url.Parse(fmt.Sprintf("%s://%s:%d", scheme, listenAddr, listenPort))
 	if err != nil {
-		log.Fatalf("Could not format api url: %s", err)
+		log.Fatal(context.Background(), "Could not format api url", "error", err)
 	}
 	apiUrl.Path = apiPrefix
 
@@ -49,14 +58,15 @@ func ApiEndpointUrl() *url.URL {
 
 	shouldServeUI := ShouldServeUI()
 	if shouldServeUI {
-		apiEndpointStr = "/"
+		// Relative to the host the ui is reached at, so it can be reached at several domains
+		apiEndpointStr = PublicBasePath()
 	} else {
 		apiEndpointStr = EnvAPIEndpoint.GetValue()
 	}
 
 	apiEndpointURL, err := url.Parse(apiEndpointStr)
 	if err != nil {
-		log.Fatalf("ERROR: Environment variable %s is not a proper url (%s)", EnvAPIEndpoint.GetName(), EnvAPIEndpoint.GetValue())
+		log.Fatal(context.Background(), "Environment variable is not a proper url", "name", EnvAPIEndpoint.GetName(), "value", EnvAPIEndpoint.GetValue())
 	}
 
 	if shouldServeUI {
@@ -74,8 +84,89 @@ func UiEndpointUrl() *url.URL {
 
 	uiEndpointURL, err := url.Parse(EnvUIEndpoint.GetValue())
 	if err != nil {
-		log.Fatalf("ERROR: Environment variable %s is not a proper url (%s)", EnvUIEndpoint.GetName(), EnvUIEndpoint.GetValue())
+		log.Fatal(context.Background(), "Environment variable is not a proper url", "name", EnvUIEndpoint.GetName(), "value", EnvUIEndpoint.GetValue())
 	}
 
 	return uiEndpointURL
 }
+
+// PublicURL returns the url the server is reached at publicly, such as https://home.example.com/photos behind a reverse proxy,
+// or nil if it has not been set
+func PublicURL() *url.URL {
+	value := EnvPublicURL.GetValue()
+	if value == "" {
+		return nil
+	}
+
+	publicURL, err := url.Parse(value)
+	if err != nil || !publicURL.IsAbs() {
+		log.Warn(context.Background(), "Environment variable is not an absolute url", "name", EnvPublicURL.GetName(), "value", value)
+		return nil
+	}
+
+	return publicURL
+}
+
+// ParseNetwork parses an entry of a list of networks, such as the trusted proxies,
+// either an ip address or a network in CIDR notation
+func ParseNetwork(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		return network, err
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip address: %s", value)
+	}
+
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// IsTrustedProxy returns whether the remote address of a request is one of the reverse proxies set to be trusted,
+// whose forwarded headers give the host and scheme the request was made to
+func IsTrustedProxy(remoteAddr string) bool {
+	return inNetworks(remoteAddr, EnvTrustedProxies)
+}
+
+// IsDLNAClient returns whether the remote address of a request is in one of the networks set to access the DLNA server.
+// Media players can not log in, so requests from anywhere else are rejected.
+func IsDLNAClient(remoteAddr string) bool {
+	return inNetworks(remoteAddr, EnvDLNAAllowedNetworks)
+}
+
+// inNetworks returns whether a remote address is in one of the networks of the environment variable
+func inNetworks(remoteAddr string, networks EnvironmentVariable) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, value := range strings.Split(networks.GetValue(), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+
+		network, err := ParseNetwork(value)
+		if err != nil {
+			log.Warn(context.Background(), "Environment variable has an invalid network", "name", networks.GetName(), "value", value)
+			continue
+		}
+
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"os"
+	"path"
+)
+
+// TempFilePrefix is the prefix of the temporary files generated files are written to, before they are given their final name
+const TempFilePrefix = ".tmp-"
+
+// WriteFileAtomic calls write with a temporary path next to the given path, and renames the temporary file to the given path
+// once it has been written, so a file at the given path is always complete, even if the server crashes while it is written.
+// The temporary path has the same extension, as external programs determine the format to write from it.
+func WriteFileAtomic(filePath string, write func(tmpPath string) error) error {
+	tmpPath := path.Join(path.Dir(filePath), TempFilePrefix+GenerateToken()+"-"+path.Base(filePath))
+
+	if err := write(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
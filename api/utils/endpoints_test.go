@@ -0,0 +1,49 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/photoview/photoview/api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicBasePath(t *testing.T) {
+	tests := []struct {
+		name        string
+		publicURL   string
+		uiBasePath  string
+		basePath    string
+		publicPath  string
+		apiEndpoint string
+	}{
+		{"Defaults", "", "", "/", "/", "/api"},
+		{"Base path", "", "photos", "/photos/", "/photos/", "/photos/api"},
+		{"Public url", "https://home.example.com/photos/", "", "/photos/", "/photos/", "/photos/api"},
+		{"Proxy stripping the path", "https://home.example.com/photos", "/", "/", "/photos/", "/photos/api"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(utils.EnvServeUI.GetName(), "1")
+			t.Setenv(utils.EnvPublicURL.GetName(), test.publicURL)
+			t.Setenv(utils.EnvUIBasePath.GetName(), test.uiBasePath)
+
+			assert.Equal(t, test.basePath, utils.UIBasePath())
+			assert.Equal(t, test.publicPath, utils.PublicBasePath())
+			assert.Equal(t, test.apiEndpoint, utils.ApiEndpointUrl().String())
+		})
+	}
+}
+
+func TestIsTrustedProxy(t *testing.T) {
+	t.Setenv(utils.EnvTrustedProxies.GetName(), "127.0.0.1, 10.0.0.0/8, fd00::/8, invalid")
+
+	assert.True(t, utils.IsTrustedProxy("127.0.0.1:51234"))
+	assert.True(t, utils.IsTrustedProxy("10.1.2.3:80"))
+	assert.True(t, utils.IsTrustedProxy("[fd12::1]:80"))
+	assert.False(t, utils.IsTrustedProxy("127.0.0.2:51234"))
+	assert.False(t, utils.IsTrustedProxy("192.0.2.1:1234"))
+
+	t.Setenv(utils.EnvTrustedProxies.GetName(), "")
+	assert.False(t, utils.IsTrustedProxy("127.0.0.1:51234"))
+}
@@ -2,7 +2,10 @@ package utils
 
 import (
 	"os"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // EnvironmentVariable represents the name of an environment variable used to configure Photoview
@@ -13,16 +16,33 @@ const (
 	EnvDevelopmentMode           EnvironmentVariable = "PHOTOVIEW_DEVELOPMENT_MODE"
 	EnvServeUI                   EnvironmentVariable = "PHOTOVIEW_SERVE_UI"
 	EnvUIPath                    EnvironmentVariable = "PHOTOVIEW_UI_PATH"
+	EnvUIBasePath                EnvironmentVariable = "PHOTOVIEW_UI_BASE_PATH"
 	EnvMediaCachePath            EnvironmentVariable = "PHOTOVIEW_MEDIA_CACHE"
 	EnvFaceRecognitionModelsPath EnvironmentVariable = "PHOTOVIEW_FACE_RECOGNITION_MODELS_PATH"
+	EnvLogLevel                  EnvironmentVariable = "PHOTOVIEW_LOG_LEVEL"
+	EnvLogFormat                 EnvironmentVariable = "PHOTOVIEW_LOG_FORMAT"
+	EnvConfigFile                EnvironmentVariable = "PHOTOVIEW_CONFIG_FILE"
 )
 
 // Network related
 const (
-	EnvListenIP    EnvironmentVariable = "PHOTOVIEW_LISTEN_IP"
-	EnvListenPort  EnvironmentVariable = "PHOTOVIEW_LISTEN_PORT"
-	EnvAPIEndpoint EnvironmentVariable = "PHOTOVIEW_API_ENDPOINT"
-	EnvUIEndpoint  EnvironmentVariable = "PHOTOVIEW_UI_ENDPOINT"
+	EnvListenIP       EnvironmentVariable = "PHOTOVIEW_LISTEN_IP"
+	EnvListenPort     EnvironmentVariable = "PHOTOVIEW_LISTEN_PORT"
+	EnvAPIEndpoint    EnvironmentVariable = "PHOTOVIEW_API_ENDPOINT"
+	EnvUIEndpoint     EnvironmentVariable = "PHOTOVIEW_UI_ENDPOINT"
+	EnvPublicURL      EnvironmentVariable = "PHOTOVIEW_PUBLIC_URL"
+	EnvTrustedProxies EnvironmentVariable = "PHOTOVIEW_TRUSTED_PROXIES"
+)
+
+// TLS, either with certificate files or with certificates obtained automatically over ACME, such as from Let's Encrypt
+const (
+	EnvTLSCert          EnvironmentVariable = "PHOTOVIEW_TLS_CERT"
+	EnvTLSKey           EnvironmentVariable = "PHOTOVIEW_TLS_KEY"
+	EnvTLSACMEDomains   EnvironmentVariable = "PHOTOVIEW_TLS_ACME_DOMAINS"
+	EnvTLSACMEEmail     EnvironmentVariable = "PHOTOVIEW_TLS_ACME_EMAIL"
+	EnvTLSACMECache     EnvironmentVariable = "PHOTOVIEW_TLS_ACME_CACHE"
+	EnvTLSACMEDirectory EnvironmentVariable = "PHOTOVIEW_TLS_ACME_DIRECTORY"
+	EnvTLSRedirectPort  EnvironmentVariable = "PHOTOVIEW_TLS_REDIRECT_PORT"
 )
 
 // Database related
@@ -35,9 +55,88 @@ const (
 
 // Feature related
 const (
-	EnvDisableFaceRecognition EnvironmentVariable = "PHOTOVIEW_DISABLE_FACE_RECOGNITION"
-	EnvDisableVideoEncoding   EnvironmentVariable = "PHOTOVIEW_DISABLE_VIDEO_ENCODING"
-	EnvDisableRawProcessing   EnvironmentVariable = "PHOTOVIEW_DISABLE_RAW_PROCESSING"
+	EnvDisableFaceRecognition   EnvironmentVariable = "PHOTOVIEW_DISABLE_FACE_RECOGNITION"
+	EnvDisableVideoEncoding     EnvironmentVariable = "PHOTOVIEW_DISABLE_VIDEO_ENCODING"
+	EnvDisableRawProcessing     EnvironmentVariable = "PHOTOVIEW_DISABLE_RAW_PROCESSING"
+	EnvWebDAVWritable           EnvironmentVariable = "PHOTOVIEW_WEBDAV_WRITABLE"
+	EnvMaxUploadSize            EnvironmentVariable = "PHOTOVIEW_MAX_UPLOAD_SIZE"
+	EnvEnableDLNA               EnvironmentVariable = "PHOTOVIEW_ENABLE_DLNA"
+	EnvEnableGPUThumbnails      EnvironmentVariable = "PHOTOVIEW_ENABLE_GPU_THUMBNAILS"
+	EnvShareStripMetadata       EnvironmentVariable = "PHOTOVIEW_SHARE_STRIP_METADATA"
+	EnvDLNAFriendlyName         EnvironmentVariable = "PHOTOVIEW_DLNA_NAME"
+	EnvDLNAAllowedNetworks      EnvironmentVariable = "PHOTOVIEW_DLNA_ALLOWED_NETWORKS"
+	EnvScannerWorkers           EnvironmentVariable = "PHOTOVIEW_SCANNER_WORKERS"
+	EnvScannerMaxFileReads      EnvironmentVariable = "PHOTOVIEW_SCANNER_MAX_FILE_READS"
+	EnvScannerMaxThumbnailJobs  EnvironmentVariable = "PHOTOVIEW_SCANNER_MAX_THUMBNAIL_JOBS"
+	EnvScannerReadLimit         EnvironmentVariable = "PHOTOVIEW_SCANNER_READ_LIMIT"
+	EnvScannerOrder             EnvironmentVariable = "PHOTOVIEW_SCANNER_ORDER"
+	EnvWatchLibrary             EnvironmentVariable = "PHOTOVIEW_WATCH_LIBRARY"
+	EnvScannerMinFileSize       EnvironmentVariable = "PHOTOVIEW_SCANNER_MIN_FILE_SIZE"
+	EnvScannerMinImageDimension EnvironmentVariable = "PHOTOVIEW_SCANNER_MIN_IMAGE_DIMENSION"
+	EnvScanHiddenDirectories    EnvironmentVariable = "PHOTOVIEW_SCAN_HIDDEN_DIRECTORIES"
+	EnvExcludePatterns          EnvironmentVariable = "PHOTOVIEW_EXCLUDE_PATTERNS"
+)
+
+// Encoding of web videos
+const (
+	EnvVideoCodec                EnvironmentVariable = "PHOTOVIEW_VIDEO_CODEC"
+	EnvVideoBitrate              EnvironmentVariable = "PHOTOVIEW_VIDEO_BITRATE"
+	EnvVideoHardwareAcceleration EnvironmentVariable = "PHOTOVIEW_VIDEO_HARDWARE_ACCELERATION"
+	EnvVideoVAAPIDevice          EnvironmentVariable = "PHOTOVIEW_VIDEO_VAAPI_DEVICE"
+)
+
+// Encoding of thumbnails and web copies of photos
+const (
+	EnvImageFormat       EnvironmentVariable = "PHOTOVIEW_IMAGE_FORMAT"
+	EnvEncodeAVIF        EnvironmentVariable = "PHOTOVIEW_ENCODE_AVIF"
+	EnvImageBackend      EnvironmentVariable = "PHOTOVIEW_IMAGE_BACKEND"
+	EnvThumbnailQuality  EnvironmentVariable = "PHOTOVIEW_THUMBNAIL_QUALITY"
+	EnvThumbnailMaxSize  EnvironmentVariable = "PHOTOVIEW_THUMBNAIL_MAX_SIZE"
+	EnvWebVersionQuality EnvironmentVariable = "PHOTOVIEW_WEB_VERSION_QUALITY"
+	EnvWebVersionMaxSize EnvironmentVariable = "PHOTOVIEW_WEB_VERSION_MAX_SIZE"
+)
+
+// Email-in upload gateway
+const (
+	EnvMailInListen  EnvironmentVariable = "PHOTOVIEW_MAIL_IN_LISTEN"
+	EnvMailInAddress EnvironmentVariable = "PHOTOVIEW_MAIL_IN_ADDRESS"
+	EnvMailInToken   EnvironmentVariable = "PHOTOVIEW_MAIL_IN_TOKEN"
+	EnvMailInSenders EnvironmentVariable = "PHOTOVIEW_MAIL_IN_SENDERS"
+	EnvMailInAlbum   EnvironmentVariable = "PHOTOVIEW_MAIL_IN_ALBUM"
+)
+
+// Outgoing email notifications
+const (
+	EnvSMTPHost     EnvironmentVariable = "PHOTOVIEW_SMTP_HOST"
+	EnvSMTPPort     EnvironmentVariable = "PHOTOVIEW_SMTP_PORT"
+	EnvSMTPUsername EnvironmentVariable = "PHOTOVIEW_SMTP_USERNAME"
+	EnvSMTPPassword EnvironmentVariable = "PHOTOVIEW_SMTP_PASSWORD"
+	EnvSMTPFrom     EnvironmentVariable = "PHOTOVIEW_SMTP_FROM"
+)
+
+// Running multiple instances of the server sharing the same database
+const (
+	EnvInstanceID EnvironmentVariable = "PHOTOVIEW_INSTANCE_ID"
+)
+
+// Media cache
+const (
+	EnvCacheWarmupAlbums         EnvironmentVariable = "PHOTOVIEW_CACHE_WARMUP_ALBUMS"
+	EnvMediaCacheThumbnails      EnvironmentVariable = "PHOTOVIEW_MEDIA_CACHE_THUMBNAILS"
+	EnvMediaCacheWebVersions     EnvironmentVariable = "PHOTOVIEW_MEDIA_CACHE_WEB_VERSIONS"
+	EnvMediaCacheVideoTranscodes EnvironmentVariable = "PHOTOVIEW_MEDIA_CACHE_VIDEO_TRANSCODES"
+	EnvThumbnailSizes            EnvironmentVariable = "PHOTOVIEW_THUMBNAIL_SIZES"
+)
+
+// Tracing, the collector is configured by the standard OTEL_EXPORTER_OTLP_* environment variables
+const (
+	EnvTracingEnabled EnvironmentVariable = "PHOTOVIEW_TRACING_ENABLED"
+)
+
+// Demo mode
+const (
+	EnvDemoMode EnvironmentVariable = "PHOTOVIEW_DEMO_MODE"
+	EnvDemoPath EnvironmentVariable = "PHOTOVIEW_DEMO_PATH"
 )
 
 // GetName returns the name of the environment variable itself
@@ -45,14 +144,57 @@ func (v EnvironmentVariable) GetName() string {
 	return string(v)
 }
 
-// GetValue returns the value of the environment
+// GetValue returns the value of the environment, or the value it has been overridden with by a setting
 func (v EnvironmentVariable) GetValue() string {
+	overridesMutex.RLock()
+	value, overridden := overrides[v]
+	overridesMutex.RUnlock()
+
+	if overridden {
+		return value
+	}
+
+	return v.GetEnvironmentValue()
+}
+
+// GetEnvironmentValue returns the value of the environment, ignoring overrides
+func (v EnvironmentVariable) GetEnvironmentValue() string {
 	return os.Getenv(string(v))
 }
 
+var (
+	overridesMutex sync.RWMutex
+	overrides      = make(map[EnvironmentVariable]string)
+)
+
+// SetOverride overrides the value of the environment variable, a nil value removes the override.
+// Settings changed at runtime are applied this way, see the settings package.
+func (v EnvironmentVariable) SetOverride(value *string) {
+	overridesMutex.Lock()
+	defer overridesMutex.Unlock()
+
+	if value == nil {
+		delete(overrides, v)
+	} else {
+		overrides[v] = *value
+	}
+}
+
+// GetOverride returns the value the environment variable has been overridden with, or nil if it is not overridden
+func (v EnvironmentVariable) GetOverride() *string {
+	overridesMutex.RLock()
+	defer overridesMutex.RUnlock()
+
+	if value, overridden := overrides[v]; overridden {
+		return &value
+	}
+
+	return nil
+}
+
 // GetBool returns the environment variable as a boolean (defaults to false if not defined)
 func (v EnvironmentVariable) GetBool() bool {
-	value := strings.ToLower(os.Getenv(string(v)))
+	value := strings.ToLower(v.GetValue())
 	trueValues := []string{"1", "true"}
 
 	for _, x := range trueValues {
@@ -83,3 +225,55 @@ func UIPath() string {
 
 	return "./ui"
 }
+
+// DemoPath returns the directory the sample library of the demo mode is generated in
+func DemoPath() string {
+	if path := EnvDemoPath.GetValue(); path != "" {
+		return path
+	}
+
+	return "./demo_library"
+}
+
+// UIBasePath returns the path the UI is served at if SERVE_UI=1, with the api below it at api/.
+// It starts and ends with a slash, such as /photos/, and defaults to the path of the public url, or /.
+func UIBasePath() string {
+	basePath := EnvUIBasePath.GetValue()
+	if basePath == "" {
+		if publicURL := PublicURL(); publicURL != nil {
+			basePath = publicURL.Path
+		}
+	}
+
+	return cleanBasePath(basePath)
+}
+
+// PublicBasePath returns the path the UI is reached at publicly if SERVE_UI=1, which is the path of the public url if it is set.
+// It differs from UIBasePath behind a reverse proxy that strips the path before passing requests on.
+func PublicBasePath() string {
+	if publicURL := PublicURL(); publicURL != nil {
+		return cleanBasePath(publicURL.Path)
+	}
+
+	return UIBasePath()
+}
+
+func cleanBasePath(basePath string) string {
+	basePath = path.Clean("/" + basePath)
+	if basePath == "/" {
+		return basePath
+	}
+
+	return basePath + "/"
+}
+
+// GetNumber returns the environment variable as a number from min to max,
+// or the default value if it is not defined or is not a number in that range
+func (v EnvironmentVariable) GetNumber(defaultValue int, min int, max int) int {
+	number, err := strconv.Atoi(strings.TrimSpace(v.GetValue()))
+	if err != nil || number < min || number > max {
+		return defaultValue
+	}
+
+	return number
+}
@@ -37,6 +37,29 @@ func CachePathForMedia(albumID int, mediaID int) (string, error) {
 	return photoCachePath, nil
 }
 
+// ContentCacheDir is the directory inside the media cache, where cached files are addressed by the content of their original
+const ContentCacheDir = "content"
+
+// ContentCacheKey returns the path, relative to the media cache, of the directory of the cached files of the given content hash
+func ContentCacheKey(contentHash string) string {
+	return path.Join(ContentCacheDir, contentHash[0:2], contentHash)
+}
+
+// CachePathForContent is a low-level implementation for Media.CachePath(), for media whose content has been hashed.
+// The directory is created inside the given cache location, such as the media cache or the location of a type of cached files.
+func CachePathForContent(cacheLocation string, contentHash string) (string, error) {
+	if len(contentHash) < 2 {
+		return "", errors.Errorf("invalid content hash: %q", contentHash)
+	}
+
+	contentCachePath := path.Join(cacheLocation, ContentCacheKey(contentHash))
+	if err := os.MkdirAll(contentCachePath, os.ModePerm); err != nil {
+		return "", errors.Wrap(err, "could not make content cache directory")
+	}
+
+	return contentCachePath, nil
+}
+
 var test_cache_path string = ""
 
 func ConfigureTestCache(tmp_dir string) {
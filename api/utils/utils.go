@@ -1,14 +1,16 @@
 package utils
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
-	"log"
 	"math/big"
 	"os"
 	"path"
 	"path/filepath"
 
+	"github.com/photoview/photoview/api/log"
+
 	"github.com/pkg/errors"
 )
 
@@ -23,7 +25,7 @@ func GenerateToken() string {
 
 		n, err := rand.Int(rand.Reader, charLen)
 		if err != nil {
-			log.Fatalf("Could not generate random number: %s\n", err)
+			log.Fatal(context.Background(), "Could not generate random number", "error", err)
 		}
 		b[i] = charset[n.Int64()]
 	}
@@ -40,7 +42,7 @@ func (e PhotoviewError) Error() string {
 }
 
 func HandleError(message string, err error) PhotoviewError {
-	log.Printf("ERROR: %s: %s", message, err)
+	log.Error(context.Background(), message, "error", err)
 	return PhotoviewError{
 		message:  message,
 		original: err,
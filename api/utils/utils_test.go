@@ -1,6 +1,7 @@
 package utils_test
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path"
@@ -75,3 +76,48 @@ func TestIsDirSymlink(t *testing.T) {
 		t.Error("Missing error for non-existant file")
 	}
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	test_utils.FilesystemTest(t)
+
+	dir := t.TempDir()
+	filePath := path.Join(dir, "thumbnail.jpg")
+
+	err := utils.WriteFileAtomic(filePath, func(tmpPath string) error {
+		if path.Ext(tmpPath) != ".jpg" {
+			t.Errorf("expected temporary file to keep extension, got %s", tmpPath)
+		}
+
+		if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+			t.Errorf("expected file not to exist while it is written")
+		}
+
+		return os.WriteFile(tmpPath, []byte("complete"), 0644)
+	})
+	if err != nil {
+		t.Fatalf("write file atomic: %v", err)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil || string(content) != "complete" {
+		t.Errorf("expected file to have been written, got %q (%v)", content, err)
+	}
+
+	err = utils.WriteFileAtomic(filePath, func(tmpPath string) error {
+		os.WriteFile(tmpPath, []byte("trunc"), 0644)
+		return errors.New("interrupted")
+	})
+	if err == nil {
+		t.Errorf("expected error of write to be returned")
+	}
+
+	content, _ = os.ReadFile(filePath)
+	if string(content) != "complete" {
+		t.Errorf("expected failed write to leave the file as it was, got %q", content)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected temporary file of failed write to be removed, got %d files", len(entries))
+	}
+}
@@ -0,0 +1,74 @@
+package webhooks
+
+import (
+	"github.com/photoview/photoview/api/graphql/models"
+)
+
+type albumData struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Path  string `json:"path"`
+}
+
+type mediaData struct {
+	ID    int              `json:"id"`
+	Title string           `json:"title"`
+	Path  string           `json:"path"`
+	Type  models.MediaType `json:"type"`
+}
+
+type mediaAddedData struct {
+	Album albumData   `json:"album"`
+	Media []mediaData `json:"media"`
+}
+
+type shareAccessedData struct {
+	Token   string `json:"token"`
+	OwnerID int    `json:"ownerId"`
+	AlbumID *int   `json:"albumId"`
+	MediaID *int   `json:"mediaId"`
+}
+
+type userCreatedData struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Admin    bool   `json:"admin"`
+}
+
+// ScanCompleted notifies webhooks that the scanner has finished all queued jobs
+func ScanCompleted() {
+	fire(models.WebhookEventScanCompleted, nil)
+}
+
+// MediaAdded notifies webhooks of media newly added to an album
+func MediaAdded(album *models.Album, media []*models.Media) {
+	data := mediaAddedData{
+		Album: albumData{ID: album.ID, Title: album.Title, Path: album.Path},
+		Media: make([]mediaData, 0, len(media)),
+	}
+
+	for _, m := range media {
+		data.Media = append(data.Media, mediaData{ID: m.ID, Title: m.Title, Path: m.Path, Type: m.Type})
+	}
+
+	fire(models.WebhookEventMediaAdded, data)
+}
+
+// ShareAccessed notifies webhooks that a share has been opened
+func ShareAccessed(token *models.ShareToken) {
+	fire(models.WebhookEventShareAccessed, shareAccessedData{
+		Token:   token.Value,
+		OwnerID: token.OwnerID,
+		AlbumID: token.AlbumID,
+		MediaID: token.MediaID,
+	})
+}
+
+// UserCreated notifies webhooks of a new user
+func UserCreated(user *models.User) {
+	fire(models.WebhookEventUserCreated, userCreatedData{
+		ID:       user.ID,
+		Username: user.Username,
+		Admin:    user.Admin,
+	})
+}
@@ -0,0 +1,141 @@
+// Package webhooks notifies configured urls of events in the library,
+// so automations such as Home Assistant or n8n can react to them.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/log"
+	"gorm.io/gorm"
+)
+
+const (
+	// SignatureHeader holds the HMAC-SHA256 signature of the payload, hex encoded and prefixed with "sha256="
+	SignatureHeader = "X-Photoview-Signature"
+	EventHeader     = "X-Photoview-Event"
+)
+
+// Payload is the JSON body posted to webhooks
+type Payload struct {
+	Event     models.WebhookEvent `json:"event"`
+	Timestamp time.Time           `json:"timestamp"`
+	Data      interface{}         `json:"data"`
+}
+
+// Delays before retrying a failed delivery
+var retryDelays = []time.Duration{10 * time.Second, time.Minute}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+var webhooksDB *gorm.DB
+var webhooksLock = &sync.Mutex{}
+
+// InitializeWebhooks enables delivery of events, until then events are discarded
+func InitializeWebhooks(db *gorm.DB) {
+	webhooksLock.Lock()
+	defer webhooksLock.Unlock()
+
+	webhooksDB = db
+}
+
+// fire delivers an event to the webhooks subscribed to it, in the background
+func fire(event models.WebhookEvent, data interface{}) {
+	webhooksLock.Lock()
+	db := webhooksDB
+	webhooksLock.Unlock()
+
+	if db == nil {
+		return
+	}
+
+	go func() {
+		var webhooks []*models.Webhook
+		if err := db.Where("events LIKE ?", "%"+event.String()+"%").Find(&webhooks).Error; err != nil {
+			log.Warn(context.Background(), "Getting webhooks from database", "error", err)
+			return
+		}
+
+		if len(webhooks) == 0 {
+			return
+		}
+
+		body, err := json.Marshal(Payload{
+			Event:     event,
+			Timestamp: time.Now().UTC(),
+			Data:      data,
+		})
+		if err != nil {
+			log.Error(context.Background(), "Encoding webhook payload", "error", err)
+			return
+		}
+
+		for _, webhook := range webhooks {
+			if webhook.SubscribedTo(event) {
+				go deliver(db, webhook, event, body)
+			}
+		}
+	}()
+}
+
+// deliver posts the payload to a webhook, retrying a few times if it fails, and records the outcome
+func deliver(db *gorm.DB, webhook *models.Webhook, event models.WebhookEvent, body []byte) {
+	err := post(webhook, event, body)
+	for i := 0; err != nil && i < len(retryDelays); i++ {
+		time.Sleep(retryDelays[i])
+		err = post(webhook, event, body)
+	}
+
+	updates := map[string]interface{}{}
+	if err != nil {
+		log.Warn(context.Background(), "Delivering event to webhook", "event", event, "webhook", webhook.URL, "error", err)
+		updates["last_error"] = err.Error()
+	} else {
+		updates["last_delivery_at"] = time.Now()
+		updates["last_error"] = nil
+	}
+
+	if err := db.Model(&models.Webhook{}).Where("id = ?", webhook.ID).Updates(updates).Error; err != nil {
+		log.Warn(context.Background(), "Recording webhook delivery", "error", err)
+	}
+}
+
+func post(webhook *models.Webhook, event models.WebhookEvent, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Photoview-Webhook")
+	req.Header.Set(EventHeader, event.String())
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %s", res.Status)
+	}
+
+	return nil
+}
+
+// Sign returns the signature of a payload, as sent in the signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,95 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/photoview/photoview/api/graphql/models"
+	"github.com/photoview/photoview/api/test_utils"
+	"github.com/photoview/photoview/api/webhooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(test_utils.IntegrationTestRun(m))
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	db := test_utils.DatabaseTest(t)
+
+	received := make(chan receivedRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedRequest{header: r.Header, body: body}
+	}))
+	defer server.Close()
+
+	mediaWebhook := models.Webhook{URL: server.URL, Secret: "secret"}
+	mediaWebhook.SetEvents([]models.WebhookEvent{models.WebhookEventMediaAdded, models.WebhookEventScanCompleted})
+
+	userWebhook := models.Webhook{URL: server.URL, Secret: "other secret"}
+	userWebhook.SetEvents([]models.WebhookEvent{models.WebhookEventUserCreated})
+
+	if !assert.NoError(t, db.Create(&[]*models.Webhook{&mediaWebhook, &userWebhook}).Error) {
+		return
+	}
+
+	webhooks.InitializeWebhooks(db)
+	defer webhooks.InitializeWebhooks(nil)
+
+	album := models.Album{Title: "Holiday", Path: "/photos/holiday"}
+	album.ID = 1
+	media := []*models.Media{{Title: "beach.jpg", Path: "/photos/holiday/beach.jpg", Type: models.MediaTypePhoto}}
+
+	webhooks.MediaAdded(&album, media)
+
+	select {
+	case req := <-received:
+		assert.Equal(t, "MEDIA_ADDED", req.header.Get(webhooks.EventHeader))
+		assert.Equal(t, webhooks.Sign("secret", req.body), req.header.Get(webhooks.SignatureHeader))
+
+		var payload struct {
+			Event string `json:"event"`
+			Data  struct {
+				Album struct {
+					Title string `json:"title"`
+				} `json:"album"`
+				Media []interface{} `json:"media"`
+			} `json:"data"`
+		}
+		if assert.NoError(t, json.Unmarshal(req.body, &payload)) {
+			assert.Equal(t, "MEDIA_ADDED", payload.Event)
+			assert.Equal(t, "Holiday", payload.Data.Album.Title)
+			assert.Len(t, payload.Data.Media, 1)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	assert.Eventually(t, func() bool {
+		var delivered models.Webhook
+		return db.First(&delivered, mediaWebhook.ID).Error == nil && delivered.LastDeliveryAt != nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	select {
+	case req := <-received:
+		t.Errorf("expected only the subscribed webhook to be notified, got %s", req.header.Get(webhooks.EventHeader))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t,
+		"sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		webhooks.Sign("key", []byte("The quick brown fox jumps over the lazy dog")))
+}
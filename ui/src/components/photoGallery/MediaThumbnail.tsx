@@ -24,6 +24,21 @@ const StyledPhoto = styled(ProtectedImage)`
   transition: opacity 300ms;
 `
 
+// Thumbnails are cropped square by the server, over the faces or the most detailed part of the photo,
+// at twice the height of the grid for high density screens
+const THUMBNAIL_SIZE = 400
+
+const croppedThumbnailUrl = (url?: string) => {
+  if (url === undefined) return undefined
+
+  const thumbnailUrl = new URL(url, location.origin)
+  thumbnailUrl.searchParams.set('w', String(THUMBNAIL_SIZE))
+  thumbnailUrl.searchParams.set('h', String(THUMBNAIL_SIZE))
+  thumbnailUrl.searchParams.set('fit', 'cover')
+
+  return thumbnailUrl.href
+}
+
 type LazyPhotoProps = {
   src?: string
   blurhash: string | null
@@ -169,12 +184,7 @@ export const MediaThumbnail = ({
     videoIcon = <VideoThumbnailIcon />
   }
 
-  let minWidth = 100
-  if (media.thumbnail) {
-    minWidth = Math.floor(
-      (media.thumbnail.width / media.thumbnail.height) * 200
-    )
-  }
+  const minWidth = 200
 
   return (
     <MediaContainer
@@ -194,7 +204,10 @@ export const MediaThumbnail = ({
           height: `200px`,
         }}
       >
-        <LazyPhoto src={media.thumbnail?.url} blurhash={media.blurhash} />
+        <LazyPhoto
+          src={croppedThumbnailUrl(media.thumbnail?.url)}
+          blurhash={media.blurhash}
+        />
       </div>
       <PhotoOverlay active={active}>
         {videoIcon}
@@ -423,7 +423,7 @@ const SidebarShare = ({
           className="align-middle p-1 ml-2"
           title={t('sidebar.sharing.copy_link', 'Copy Link')}
           onClick={() => {
-            copy(`${location.origin}/share/${share.token}`)
+            copy(`${location.origin}${import.meta.env.BASE_URL}share/${share.token}`)
           }}
         >
           <CopyIcon />